@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// -d.autoimport ：装饰注释 //go:decor x.name 中的包 x 没有在文件中导入时，不报告 package not found ，
+// 而是从包中其他文件的导入里查找名称为 x 的包，把它导入到改写后的文件中。
+// compile 的 importcfg 只包含包的源文件直接导入的包，其他包即使在模块中也无法在编译时导入，
+// 因此只查找包中已经导入过的包。x 同时是包级变量时，它是绑定装饰器的接收者，不会导入。
+
+// 包中所有文件导入的包，导入名称 => 导入路径（排序）。匿名导入和点导入的名称为路径的默认包名。
+type packageImports map[string][]string
+
+// 收集包 pkg 中的导入，skipFile 为不处理的文件（wrapped_code.go），可以为空
+func collectPackageImports(pkg *astPackage, skipFile string) packageImports {
+	imports := packageImports{}
+	for file, f := range pkg.Files {
+		if file == skipFile {
+			continue
+		}
+		for _, spec := range f.Imports {
+			p, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			name := importPathName(p)
+			if spec.Name != nil && spec.Name.Name != "_" && spec.Name.Name != "." {
+				name = spec.Name.Name
+			}
+			if !containsString(imports[name], p) {
+				imports[name] = append(imports[name], p)
+				sort.Strings(imports[name])
+			}
+		}
+	}
+	return imports
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// 包 pkg 的顶层声明的名称：函数（不含方法）、变量、常量和类型
+func packageDeclNames(pkg *astPackage) map[string]bool {
+	declared := map[string]bool{}
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					declared[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch sp := spec.(type) {
+					case *ast.ValueSpec:
+						for _, id := range sp.Names {
+							declared[id.Name] = true
+						}
+					case *ast.TypeSpec:
+						declared[sp.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return declared
+}
+
+// 解析文件 f 中没有导入的装饰器包 x 。x 是包级声明、或包中没有导入过名为 x 的包时返回 "" 。
+// autoImport 为 true 时把找到的包以名称 x 导入 f 并更新 imp ，否则返回提示使用 -d.autoimport 的错误。
+// 包中以同一个名称导入了多个包时返回错误。
+func resolveDecorImport(f *ast.File, imp *importer, imports packageImports, declared map[string]bool, x string, autoImport bool) (string, error) {
+	if declared[x] || len(imports[x]) == 0 {
+		return "", nil
+	}
+	if len(imports[x]) > 1 {
+		return "", errors.New(fmt.Sprintf("%s package not found, it is ambiguous in this package: %s", x, strings.Join(imports[x], ", ")))
+	}
+	p := imports[x][0]
+	if !autoImport {
+		return "", errors.New(fmt.Sprintf("%s package not found, import %q or build with -d.autoimport", x, p))
+	}
+	spec := addImport(f, x, p)
+	imp.nameMap[x] = p
+	imp.pathMap[p] = x
+	imp.pathObjMap[p] = spec
+	return p, nil
+}
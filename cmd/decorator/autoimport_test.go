@@ -0,0 +1,87 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestResolveDecorImport(t *testing.T) {
+	fset := token.NewFileSet()
+	a, err := parser.ParseFile(fset, "a.go", `package p
+
+import (
+	obs "example.com/obs/decor"
+	_ "example.com/metrics"
+	"example.com/a/log"
+)
+
+var registry = obs.Default
+`, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parser.ParseFile(fset, "b.go", `package p
+
+import "example.com/b/log"
+
+//go:decor obs.Logging
+func work() {}
+`, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := parser.ParseFile(fset, "wrapped_code.go", "package p\n\nimport skipped \"example.com/skipped\"\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &astPackage{Name: "p", Files: map[string]*ast.File{"a.go": a, "b.go": b, "wrapped_code.go": c}}
+
+	imports := collectPackageImports(pkg, "wrapped_code.go")
+	want := packageImports{
+		"obs":     {"example.com/obs/decor"},
+		"metrics": {"example.com/metrics"},
+		"log":     {"example.com/a/log", "example.com/b/log"},
+	}
+	if !reflect.DeepEqual(imports, want) {
+		t.Fatalf("collectPackageImports() want %v, but got %v", want, imports)
+	}
+	declared := packageDeclNames(pkg)
+	if !declared["registry"] || !declared["work"] {
+		t.Fatalf("packageDeclNames() got %v", declared)
+	}
+
+	imp := newImporter(b)
+	cas := []struct {
+		x, path, msg string
+	}{
+		{"registry", "", ""},
+		{"missing", "", ""},
+		{"log", "", "log package not found, it is ambiguous in this package: example.com/a/log, example.com/b/log"},
+	}
+	for i, c := range cas {
+		p, err := resolveDecorImport(b, imp, imports, declared, c.x, true)
+		if p != c.path || (err == nil) != (c.msg == "") || (err != nil && err.Error() != c.msg) {
+			t.Fatalf("cas[%d] resolveDecorImport(%s) want %q %q, but got %q %v", i, c.x, c.path, c.msg, p, err)
+		}
+	}
+	if _, err := resolveDecorImport(b, imp, imports, declared, "obs", false); err == nil ||
+		err.Error() != `obs package not found, import "example.com/obs/decor" or build with -d.autoimport` {
+		t.Fatal("resolveDecorImport() without -d.autoimport should suggest it, but got", err)
+	}
+	if len(b.Imports) != 1 {
+		t.Fatal("resolveDecorImport() without -d.autoimport should not change the file")
+	}
+	p, err := resolveDecorImport(b, imp, imports, declared, "obs", true)
+	if err != nil || p != "example.com/obs/decor" {
+		t.Fatal("resolveDecorImport(obs) should import example.com/obs/decor, but got", p, err)
+	}
+	if xPath, ok := imp.importedName("obs"); !ok || xPath != p {
+		t.Fatal("resolveDecorImport() should update the importer")
+	}
+	if name, _ := newImporter(b).importedPath(p); name != "obs" || len(b.Imports) != 2 {
+		t.Fatal("resolveDecorImport() should add the import to the file, got", name, len(b.Imports))
+	}
+}
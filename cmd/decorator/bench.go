@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bench 子命令：比较目标函数被装饰前后的单次调用开销。
+//
+//	decorator bench [-benchtime t] <pkgpath>#<func>
+//
+// 它会为目标函数生成一个被空装饰器（只调用 TargetDo）修饰的副本，和原函数一起写入一个临时的基准测试文件，
+// 再通过 go test -overlay 将该文件"放入"目标包中运行，不会修改用户的源码。
+// 两者的差值就是装饰器机制本身带来的开销（ns/op 和 allocs/op）。
+
+const (
+	benchDecorName  = "decorBenchNop"
+	benchFuncPrefix = "decorBench"
+	benchFileName   = "decorator_bench_test.go"
+)
+
+var benchLineRegexp = regexp.MustCompile(`(?m)^BenchmarkDecorBench(Raw|Decorated)\S*\s+\d+\s+([\d.]+) ns/op\s+[\d.]+ B/op\s+([\d.]+) allocs/op`)
+
+type benchResult struct {
+	nsPerOp,
+	allocsPerOp float64
+}
+
+type benchReport struct {
+	target string
+	raw,
+	decorated benchResult
+}
+
+func (r *benchReport) overheadNs() float64 {
+	return r.decorated.nsPerOp - r.raw.nsPerOp
+}
+
+func (r *benchReport) overheadAllocs() float64 {
+	return r.decorated.allocsPerOp - r.raw.allocsPerOp
+}
+
+func (r *benchReport) String() string {
+	return fmt.Sprintf("decorator bench %s\n"+
+		"  raw:       %10.2f ns/op %6.0f allocs/op\n"+
+		"  decorated: %10.2f ns/op %6.0f allocs/op\n"+
+		"  overhead:  %10.2f ns/op %6.0f allocs/op\n",
+		r.target,
+		r.raw.nsPerOp, r.raw.allocsPerOp,
+		r.decorated.nsPerOp, r.decorated.allocsPerOp,
+		r.overheadNs(), r.overheadAllocs())
+}
+
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	benchtime := fs.String("benchtime", "1s", "run enough iterations of each benchmark to take t, same as go test -benchtime")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: decorator bench [-benchtime t] <pkgpath>#<func>")
+	}
+	report, err := benchTarget(fs.Arg(0), *benchtime)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report)
+	return nil
+}
+
+func benchTarget(target, benchtime string) (*benchReport, error) {
+	pkgPath, funName, ok := strings.Cut(target, "#")
+	if !ok || pkgPath == "" || funName == "" {
+		return nil, errors.New("invalid bench target, want <pkgpath>#<func>: " + target)
+	}
+	pi, err := getPackageInfo(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	src, err := genBenchFile(pkgPath, funName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 基准测试文件和 overlay 配置都写入工作目录，通过 -overlay 映射到目标包目录下
+	dir := path.Join(tempDir, "bench", pkgPath)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	benchFile := filepath.Join(dir, funName+"_"+benchFileName)
+	if err := os.WriteFile(benchFile, src, 0777); err != nil {
+		return nil, err
+	}
+	overlay, err := json.Marshal(map[string]map[string]string{
+		"Replace": {filepath.Join(pi.Dir, benchFileName): benchFile},
+	})
+	if err != nil {
+		return nil, err
+	}
+	overlayFile := filepath.Join(dir, funName+"_overlay.json")
+	if err := os.WriteFile(overlayFile, overlay, 0777); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", "^BenchmarkDecorBench",
+		"-benchmem", "-benchtime", benchtime, "-overlay", overlayFile, ".")
+	cmd.Dir = pi.Dir
+	cmd.Env = os.Environ()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.New("run benchmark fail: " + err.Error() + biSymbol + string(out))
+	}
+	report, err := parseBenchOutput(out)
+	if err != nil {
+		return nil, err
+	}
+	report.target = target
+	return report, nil
+}
+
+// 生成基准测试文件的源码，包括：
+//   - 空装饰器 decorBenchNop
+//   - 目标函数被 decorBenchNop 装饰后的副本 decorBench<func>，它的函数体是对原函数的调用
+//   - BenchmarkDecorBenchRaw 和 BenchmarkDecorBenchDecorated 两个基准测试，参数均为零值
+func genBenchFile(pkgPath, funName string) ([]byte, error) {
+	loadFset, _, file, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 重新解析目标文件，避免修改 pkgILoader 中缓存的 ast
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, loadFset.Position(file.Pos()).Filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var fd *ast.FuncDecl
+	visitAstDecl(f, func(decl *ast.FuncDecl) bool {
+		if decl.Recv == nil && decl.Name.Name == funName {
+			fd = decl
+			return true
+		}
+		return false
+	})
+	if fd == nil {
+		return nil, errors.New("bench target not found: " + pkgPath + "#" + funName)
+	}
+	if fd.Type.TypeParams != nil {
+		return nil, errors.New("bench target can't be a generic function: " + funName)
+	}
+
+	// 为匿名参数生成名字，同时收集调用原函数的实参和基准测试使用的零值参数
+	gi := newGenIdentId()
+	callArgs, zeroArgs := []string{}, []string{}
+	for _, field := range fd.Type.Params.List {
+		if len(field.Names) == 0 {
+			field.Names = []*ast.Ident{{Name: gi.nextStr()}}
+		}
+		for _, id := range field.Names {
+			if id.Name == "_" {
+				id.Name = gi.nextStr()
+			}
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				callArgs = append(callArgs, id.Name+"...")
+				continue
+			}
+			callArgs = append(callArgs, id.Name)
+			zeroArgs = append(zeroArgs, "*new("+typeString(field.Type)+")")
+		}
+	}
+	call := fmt.Sprintf("%s(%s)", funName, stringer(callArgs))
+	if fd.Type.Results != nil && len(fd.Type.Results.List) > 0 {
+		call = "return " + call
+	}
+	body, _, err := getStmtList(call)
+	if err != nil {
+		return nil, err
+	}
+	fd.Doc = nil
+	fd.Body.List = body
+
+	// 和 compile 中一样，用模板生成装饰后的函数体
+	ra := builderReplaceArgs(fd, benchDecorName, nil, gi)
+	rs, err := replace(ra)
+	if err != nil {
+		return nil, err
+	}
+	genStmts, _, err := getStmtList(rs)
+	if err != nil {
+		return nil, err
+	}
+	spliceTargetBody(genStmts, ra, body)
+	fd.Body.List = genStmts
+	fd.Name.Name = benchFuncPrefix + funName
+
+	var decl bytes.Buffer
+	if err := printer.Fprint(&decl, fset, fd); err != nil {
+		return nil, err
+	}
+
+	// 只保留副本中用到的导入
+	imports := []string{`"testing"`, `decor "` + decoratorPackagePath + `"`}
+	imp := newImporter(f)
+	used := map[string]bool{"testing": true, "decor": true}
+	ast.Inspect(fd, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && !used[x.Name] {
+			if pkg, ok := imp.importedName(x.Name); ok {
+				used[x.Name] = true
+				imports = append(imports, x.Name+" "+strconv.Quote(pkg))
+			}
+		}
+		return true
+	})
+
+	src := fmt.Sprintf(`// Code generated by decorator bench. DO NOT EDIT.
+
+package %s
+
+import (
+	%s
+)
+
+func %s(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+%s
+
+func BenchmarkDecorBenchRaw(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		%s(%s)
+	}
+}
+
+func BenchmarkDecorBenchDecorated(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		%s(%s)
+	}
+}
+`, f.Name.Name, strings.Join(imports, "\n\t"), benchDecorName, decl.String(),
+		funName, stringer(zeroArgs), fd.Name.Name, stringer(zeroArgs))
+	return format.Source([]byte(src))
+}
+
+func parseBenchOutput(out []byte) (*benchReport, error) {
+	report := &benchReport{}
+	found := 0
+	for _, m := range benchLineRegexp.FindAllSubmatch(out, -1) {
+		ns, err := strconv.ParseFloat(string(m[2]), 64)
+		if err != nil {
+			return nil, err
+		}
+		allocs, err := strconv.ParseFloat(string(m[3]), 64)
+		if err != nil {
+			return nil, err
+		}
+		if string(m[1]) == "Raw" {
+			report.raw = benchResult{ns, allocs}
+		} else {
+			report.decorated = benchResult{ns, allocs}
+		}
+		found++
+	}
+	if found != 2 {
+		return nil, errors.New("benchmark result not found in output:" + biSymbol + string(out))
+	}
+	return report, nil
+}
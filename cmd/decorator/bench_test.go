@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBenchTarget(t *testing.T) {
+	report, err := benchTarget("github.com/dengsgo/go-decorator/example/usages/externalb#MathIntegerPlus", "100x")
+	if err != nil {
+		t.Fatal("benchTarget() error", err)
+	}
+	if report.raw.nsPerOp <= 0 || report.decorated.nsPerOp <= 0 {
+		t.Fatalf("benchTarget() should report ns/op, but got %+v\n", report)
+	}
+	if !strings.Contains(report.String(), "overhead:") {
+		t.Fatalf("benchTarget() report should contain overhead, but got %s\n", report)
+	}
+
+	failCases := []string{
+		"",
+		"github.com/dengsgo/go-decorator/example/usages/externalb",
+		"github.com/dengsgo/go-decorator/example/usages/externalb#",
+		"github.com/dengsgo/go-decorator/example/usages/externalb#NotFound",
+	}
+	for _, cas := range failCases {
+		if _, err := benchTarget(cas, "1x"); err == nil {
+			t.Fatalf("benchTarget('%s') should err, now = nil, case fail\n", cas)
+		}
+	}
+}
+
+func TestParseBenchOutput(t *testing.T) {
+	out := `goos: linux
+goarch: amd64
+BenchmarkDecorBenchRaw-8         	1000000000	         0.5512 ns/op	       0 B/op	       0 allocs/op
+BenchmarkDecorBenchDecorated-8   	 5927456	       201.1 ns/op	     120 B/op	       4 allocs/op
+PASS
+`
+	report, err := parseBenchOutput([]byte(out))
+	if err != nil {
+		t.Fatal("parseBenchOutput() error", err)
+	}
+	if report.raw.nsPerOp != 0.5512 || report.decorated.nsPerOp != 201.1 || report.overheadAllocs() != 4 {
+		t.Fatalf("parseBenchOutput() result not match, got %+v\n", report)
+	}
+	if _, err := parseBenchOutput([]byte("PASS\n")); err == nil {
+		t.Fatal("parseBenchOutput() should err when result not found, now = nil")
+	}
+}
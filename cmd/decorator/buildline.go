@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build/constraint"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 改写后的文件由 go/printer 以 SourcePos 模式打印，它用 //line 指令保持原来的行号。
+// 但 printer 在输出之后才把 //go:build 和 // +build 行移到文件开头（只有 // +build 时还会补上 //go:build），
+// 已经输出的 //line 指令随之错位，有构建约束的文件中之后所有的行号都会偏移，
+// runtime.Caller 、panic 的调用栈和编译错误都指向错误的行。
+//
+// 这里先从语法树中取出 package 子句之前的构建约束，打印其余部分，再把约束行原样写在文件开头，
+// 随后用 //line 指令把行号恢复为原文件的第一行。//go:generate 和 import "C" 之前的 cgo 注释等
+// 其他注释不会被 printer 移动，原样保留。
+//
+// printer 输出的 //line 指令使用不考虑源文件中 //line 指令的绝对位置。源文件本身有 //line 指令时
+// （如 cgo 生成的文件，见 cgo.go），把它们换算为源文件的指令所指向的位置，否则生成的代码之后的行会指向这个文件本身。
+
+// 打印改写后的文件 f ，构建约束行保持原样并位于文件开头
+func printRewrittenFile(w io.Writer, fset *token.FileSet, f *ast.File) error {
+	var buf bytes.Buffer
+	if lines, comments, doc := splitBuildConstraints(f); len(lines) > 0 {
+		// 只修改打印时使用的注释，语法树打印之后保持不变
+		origComments, origDoc := f.Comments, f.Doc
+		f.Comments, f.Doc = comments, doc
+		defer func() {
+			f.Comments, f.Doc = origComments, origDoc
+		}()
+		for _, line := range lines {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("\n//line " + fset.File(f.Pos()).Name() + ":1\n")
+	}
+	if err := printerCfg.Fprint(&buf, fset, f); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	if hasLineDirectives(f) {
+		out = adjustLineDirectives(out, fset, fset.File(f.Pos()))
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// 取出文件 f 中 package 子句之前的构建约束行，返回约束行和去掉它们之后的注释组、包文档。
+// 没有构建约束时 lines 为空。注释组被复制，f 本身不被修改。
+func splitBuildConstraints(f *ast.File) (lines []string, comments []*ast.CommentGroup, doc *ast.CommentGroup) {
+	doc = f.Doc
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			comments = append(comments, cg)
+			continue
+		}
+		var list []*ast.Comment
+		for _, c := range cg.List {
+			if constraint.IsGoBuild(c.Text) || constraint.IsPlusBuild(c.Text) {
+				lines = append(lines, c.Text)
+				continue
+			}
+			list = append(list, c)
+		}
+		if len(list) == len(cg.List) {
+			comments = append(comments, cg)
+			continue
+		}
+		var copied *ast.CommentGroup
+		if len(list) > 0 {
+			copied = &ast.CommentGroup{List: list}
+			comments = append(comments, copied)
+		}
+		if cg == f.Doc {
+			doc = copied
+		}
+	}
+	return lines, comments, doc
+}
+
+// 文件 f 中是否有 //line 或 /*line 指令
+func hasLineDirectives(f *ast.File) bool {
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//line ") || strings.HasPrefix(c.Text, "/*line ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 把 printer 为文件 tf 输出的 //line tf:N 换算为 tf 中的 //line 指令所指向的位置
+func adjustLineDirectives(out []byte, fset *token.FileSet, tf *token.File) []byte {
+	prefix := "//line " + tf.Name() + ":"
+	lines := bytes.Split(out, []byte("\n"))
+	for i, line := range lines {
+		if !bytes.HasPrefix(line, []byte(prefix)) {
+			continue
+		}
+		n, err := strconv.Atoi(string(line[len(prefix):]))
+		if err != nil || n < 1 || n > tf.LineCount() {
+			continue
+		}
+		p := fset.PositionFor(tf.LineStart(n), true)
+		lines[i] = []byte("//line " + p.Filename + ":" + strconv.Itoa(p.Line))
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestPrintRewrittenFile(t *testing.T) {
+	cas := []struct {
+		name string
+		src  string
+	}{
+		{"none", "package p\n\nfunc F() {}\n"},
+		{"go:build", "//go:build !windows\n\npackage p\n\nfunc F() {}\n"},
+		{"+build", "// +build linux darwin\n// +build amd64\n\npackage p\n\nfunc F() {}\n"},
+		{
+			"header",
+			"// Code generated by x. DO NOT EDIT.\n\n//go:build linux && (amd64 || arm64)\n// +build linux\n// +build amd64 arm64\n\n" +
+				"// Package p 说明\npackage p // import \"x/p\"\n\nfunc F() {}\n",
+		},
+		{
+			"generate",
+			"// Copyright 2024\n\n//go:build tools\n\n//go:generate stringer -type=Kind\n\npackage p\n\n//go:generate echo  \"a  b\"\n\nfunc F() {}\n",
+		},
+		{
+			"cgo",
+			"//go:build cgo\n\npackage p\n\n/*\n#cgo CFLAGS: -DX=1\n#include <stdio.h>\nstatic int   add(int a,  int b) { return a+b; }\n*/\nimport \"C\"\n\n" +
+				"// #include <stdlib.h>\nimport \"C\"\n\n//go:noinline\nfunc F() { _ = C.add(1, 2) }\n",
+		},
+	}
+	for _, c := range cas {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "/src/p.go", c.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		comments := len(f.Comments)
+		var buf bytes.Buffer
+		if err := printRewrittenFile(&buf, fset, f); err != nil {
+			t.Fatalf("%s: printRewrittenFile() error %v", c.name, err)
+		}
+		if len(f.Comments) != comments {
+			t.Fatalf("%s: printRewrittenFile() should not change the comments of the file", c.name)
+		}
+		out := buf.String()
+
+		// 构建约束行原样位于文件开头
+		var want []string
+		for _, line := range strings.Split(c.src, "\n") {
+			if constraint.IsGoBuild(line) || constraint.IsPlusBuild(line) {
+				want = append(want, line)
+			}
+		}
+		if len(want) > 0 && !strings.HasPrefix(out, strings.Join(want, "\n")+"\n\n") {
+			t.Fatalf("%s: printRewrittenFile() should start with the build constraints %q, but got:\n%s", c.name, want, out)
+		}
+		// 其他注释原样保留
+		for _, cg := range f.Comments {
+			for _, cm := range cg.List {
+				if !strings.Contains(out, cm.Text) {
+					t.Fatalf("%s: printRewrittenFile() lost the comment %q:\n%s", c.name, cm.Text, out)
+				}
+			}
+		}
+		// 按 //line 指令得到的行号和原文件一致，go 命令同样能识别其中的构建约束
+		outFset := token.NewFileSet()
+		of, err := parser.ParseFile(outFset, "/tmp/p.go", out, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("%s: printRewrittenFile() output doesn't parse: %v\n%s", c.name, err, out)
+		}
+		for i, decl := range f.Decls {
+			if _, ok := decl.(*ast.FuncDecl); !ok {
+				continue
+			}
+			got, orig := outFset.Position(of.Decls[i].Pos()), fset.Position(decl.Pos())
+			if got.Filename != orig.Filename || got.Line != orig.Line {
+				t.Fatalf("%s: printRewrittenFile() func at %s, want %s:\n%s", c.name, got, orig, out)
+			}
+		}
+		lines, _, _ := splitBuildConstraints(of)
+		if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("%s: printRewrittenFile() output has the build constraints %q, want %q", c.name, lines, want)
+		}
+	}
+}
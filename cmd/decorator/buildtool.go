@@ -1,22 +1,45 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"github.com/dengsgo/go-decorator/cmd/logs"
 	"github.com/dengsgo/go-decorator/decor"
+	"go/ast"
 	"os"
 	"os/exec"
 	"path"
-	"path/filepath"
-	"strings"
+	"regexp"
 )
 
 const (
 	biSymbol             = "\n\t"
+	linknameScanFlag     = "//go:linkname "
+	decoratorPackagePath = "github.com/dengsgo/go-decorator/decor"
+	decorPriorityKey     = "priority"
+	decorWhenKey         = "when"
+	decorAllowMainKey    = "allowMain"
+	typeDecorMethodsKey  = "methods"
+	typeDecorExcludeKey  = "exclude"
+	typeDecorPromotedKey = "promoted"
+)
+
+// 注解的写法，前缀默认为 //go:decor ，可以通过 -d.prefix 或 decor.toml 修改，见 setDecorPrefix
+var (
 	decoratorScanFlag    = "//go:decor "
 	decorLintScanFlag    = "//go:decor-lint "
-	decoratorPackagePath = "github.com/dengsgo/go-decorator/decor"
+	decorDefaultScanFlag = "//go:decor-default "
+	decorAllScanFlag     = "//go:decor-all "
+	decorPureFlag        = "//go:decor-pure"
+	decorDeprecatedFlag  = "//go:decor-deprecated"
+	decorArgsFlag        = "//go:decor-args"
+	decorWrapReturnFlag  = "//go:decor-wrap-return "
 )
 
+// 标记了 //go:decor-pure 的装饰器不能使用的包，它们（或其子包）会产生 I/O
+var decorPureIOPackages = []string{"os", "io/ioutil", "net", "syscall", "log", "database/sql"}
+
 var (
 	tempDir       = path.Join(os.TempDir(), "gobuild_decorator_works")
 	tempGenDir    = tempDir
@@ -37,20 +60,34 @@ func initTempDir() {
 
 func main() {
 	inits()
+	if runSubcommand(flag.Args()) {
+		return
+	}
 	logs.Debug("os.Args", os.Args)
 	logs.Debug("os.Env", os.Environ())
 	if cmdFlag.chainName == "" {
-		logs.Error("currently not in a compilation chain environment and cannot be used")
+		logs.Error("currently not in a compilation chain environment and cannot be used,",
+			"use it with go build -toolexec decorator, run `decorator doctor` to check the setup")
 	}
 	logs.Debug("cmdFlag", cmdFlag)
 	chainName := cmdFlag.chainName
 	chainArgs := cmdFlag.chainArgs
-	toolName := filepath.Base(chainName)
 
 	var err error
-	switch strings.TrimSuffix(toolName, ".exe") {
+	markVersion := false
+	switch chainToolName(chainName) {
 	case "compile":
-		err = compile(chainArgs)
+		// compile -V=full 的输出加上 decorator 的标识，见 toolid.go
+		markVersion = isToolVersionQuery(chainArgs)
+		// 禁用时不改写，见 disable.go
+		if decoratorDisabled() {
+			break
+		}
+		originArgs := append([]string{}, chainArgs...)
+		chainArgs, err = compile(chainArgs)
+		if err == nil && cmdFlag.EmitInlineReport && len(decoratedTargets) > 0 {
+			emitInlineReport(chainName, originArgs, chainArgs, decoratedTargets)
+		}
 	case "link":
 		link(chainArgs)
 		defer func() {
@@ -65,12 +102,110 @@ func main() {
 	}
 	// build
 	cmd := exec.Command(chainName, chainArgs...)
+	var version bytes.Buffer
 	cmd.Stdout = os.Stdout
+	if markVersion {
+		cmd.Stdout = &version
+	}
 	cmd.Stderr = os.Stderr
 	cmd.Env = os.Environ()
 	if cmd.Run() != nil {
 		//logs.Error("run toolchain err", chainName, err)
 	}
+	if markVersion {
+		os.Stdout.WriteString(toolVersion(version.String()))
+	}
+}
+
+var inlineRegexp = regexp.MustCompile(`: can inline (\S+)`)
+
+// 编译原始代码和装饰后的代码各一次（附加 -m ，输出到临时文件），对比两者的内联决策，
+// 输出装饰前可以内联、装饰后不能内联的目标。仅作参考，不影响真正的编译。
+func emitInlineReport(chainName string, originArgs, decoratedArgs, targets []string) {
+	lost, err := inlineReport(chainName, originArgs, decoratedArgs, targets)
+	if err != nil {
+		logs.Warn("inline report fail", err)
+		return
+	}
+	if len(lost) == 0 {
+		logs.Info("inline report: decorated targets keep their inlining decisions")
+		return
+	}
+	for _, name := range lost {
+		logs.Warn("inline report: decorated target is no longer inlinable:", name)
+	}
+}
+
+func inlineReport(chainName string, originArgs, decoratedArgs, targets []string) ([]string, error) {
+	before, err := inlinableSet(chainName, originArgs)
+	if err != nil {
+		return nil, err
+	}
+	after, err := inlinableSet(chainName, decoratedArgs)
+	if err != nil {
+		return nil, err
+	}
+	var lost []string
+	for _, name := range targets {
+		if before[name] && !after[name] {
+			lost = append(lost, name)
+		}
+	}
+	return lost, nil
+}
+
+// 附加 -m 执行一次编译，返回可以内联的函数集合。编译产物写入临时文件，不影响真正的编译。
+func inlinableSet(chainName string, args []string) (map[string]bool, error) {
+	out, err := os.CreateTemp(tempDir, "inline_*.a")
+	if err != nil {
+		return nil, err
+	}
+	_ = out.Close()
+	defer os.Remove(out.Name())
+
+	margs := []string{"-m"}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-o" && i+1 < len(args) {
+			margs = append(margs, "-o", out.Name())
+			i++
+			continue
+		}
+		margs = append(margs, args[i])
+	}
+	cmd := exec.Command(chainName, margs...)
+	cmd.Env = os.Environ()
+	bf, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.New(err.Error() + biSymbol + string(bf))
+	}
+	set := map[string]bool{}
+	for _, m := range inlineRegexp.FindAllSubmatch(bf, -1) {
+		set[string(m[1])] = true
+	}
+	return set, nil
+}
+
+// 返回函数或方法在 -gcflags=-m 输出中的名称，如 plus 、T.m 、(*T).m
+func inlineTargetName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	typ := fd.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		return "(*" + recvTypeName(star.X) + ")." + fd.Name.Name
+	}
+	return recvTypeName(typ) + "." + fd.Name.Name
+}
+
+// 接收者类型名称，泛型类型的类型参数在 -m 输出中显示为 [...]
+func recvTypeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.IndexExpr:
+		return typeString(expr.X) + "[...]"
+	case *ast.IndexListExpr:
+		return typeString(expr.X) + "[...]"
+	}
+	return typeString(expr)
 }
 
 func test(v ...string) string {
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const inlineOriginCode = `package inline
+
+func plus(a, b int) int {
+	return a + b
+}
+`
+
+const inlineDecoratedCode = `package inline
+
+import "github.com/dengsgo/go-decorator/decor"
+
+func nop(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+func plus(a, b int) (_decorGenOut1 int) {
+	_decorGenCtx := &decor.Context{
+		Kind:       decor.KFunc,
+		TargetName: "plus",
+		Receiver:   nil,
+		TargetIn:   []any{a, b},
+		TargetOut:  []any{_decorGenOut1},
+	}
+	_decorGenCtx.Func = func() {
+		_decorGenCtx.TargetOut[0] = func(a, b int) int {
+			return a + b
+		}(func() int { o, _ := _decorGenCtx.TargetIn[0].(int); return o }(),
+			func() int { o, _ := _decorGenCtx.TargetIn[1].(int); return o }())
+	}
+	nop(_decorGenCtx)
+	return func() int { o, _ := _decorGenCtx.TargetOut[0].(int); return o }()
+}
+`
+
+func TestInlineReport(t *testing.T) {
+	goToolDir, err := exec.Command("go", "env", "GOTOOLDIR").Output()
+	if err != nil {
+		t.Skip("toolchain not available", err)
+	}
+	chainName := filepath.Join(strings.TrimSpace(string(goToolDir)), "compile")
+	if _, err := os.Stat(chainName); err != nil {
+		t.Skip("compile tool not available", err)
+	}
+	export, err := exec.Command("go", "list", "-export", "-f", "{{.Export}}", decoratorPackagePath).Output()
+	if err != nil || strings.TrimSpace(string(export)) == "" {
+		t.Skip("decor package export data not available", err)
+	}
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	importcfg := write("importcfg", "packagefile "+decoratorPackagePath+"="+strings.TrimSpace(string(export))+"\n")
+	origin := write("origin.go", inlineOriginCode)
+	decorated := write("decorated.go", inlineDecoratedCode)
+	args := func(file string) []string {
+		return []string{"-o", filepath.Join(dir, "_pkg_.a"), "-p", "inline", "-importcfg", importcfg, "-pack", file}
+	}
+
+	defer func(d string) { tempDir = d }(tempDir)
+	tempDir = dir
+	lost, err := inlineReport(chainName, args(origin), args(decorated), []string{"plus"})
+	if err != nil {
+		t.Fatal("inlineReport() error", err)
+	}
+	if len(lost) != 1 || lost[0] != "plus" {
+		t.Fatalf("inlineReport() should report plus as not inlined, but got %+v\n", lost)
+	}
+}
+
+func TestInlineTargetName(t *testing.T) {
+	src := `package main
+func plus() {}
+func (m T) value() {}
+func (m *T) pointer() {}
+func (m *G[K]) generic() {}
+func (m G[K, V]) generics() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := []string{"plus", "T.value", "(*T).pointer", "(*G[...]).generic", "G[...].generics"}
+	i := 0
+	visitAstDecl(f, func(fd *ast.FuncDecl) bool {
+		if name := inlineTargetName(fd); name != result[i] {
+			t.Fatalf("inlineTargetName() want %s, but got %s\n", result[i], name)
+		}
+		i++
+		return false
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/token"
+	"os"
+	"strings"
+
+	"github.com/dengsgo/go-decorator/cmd/logs"
+)
+
+// cgo 的支持。导入 "C" 的文件不会直接交给 compile ，go build 先用 cgo 把它转换为 $WORK 中的 x.cgo1.go ：
+// import "C" 改为 import _ "unsafe" ，C.xxx 改为 _Cfunc_xxx 等，文件开头的 //line 指令和表达式中的 /*line*/ 注释
+// 指向原文件，其余的代码和注释原样保留，包括 cgo 的序言，它在生成的文件中只是普通的注释。
+//
+// compile 改写这些生成的文件：其中的注解和原文件相同，错误的位置、decor.Context.TargetFile 和行号
+// 都通过 //line 指令指向原文件。-d.skipCgo 时不改写它们，其中被装饰的函数给出警告。
+
+// cgo 生成的文件的后缀
+const cgoGeneratedSuffix = ".cgo1.go"
+
+// 当前编译的包中 cgo 生成的文件到原文件的映射
+var cgoSourceFiles = map[string]string{}
+
+// cgo 生成的文件 file 对应的原文件，即 package 子句之前的 //line 指令中的文件。
+// file 不是 cgo 生成的文件或者找不到指令时返回 false 。
+func cgoSourceFile(file string) (string, bool) {
+	if !strings.HasSuffix(file, cgoGeneratedSuffix) {
+		return "", false
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", false
+	}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if !strings.HasPrefix(line, "//line ") {
+			continue
+		}
+		if name, _, ok := parseLineDirective(line[len("//line "):]); ok && name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// 被编译的文件 file 的原文件，cgo 生成的文件为导入 "C" 的文件，其他文件为它本身
+func compileSourceFile(file string) string {
+	if src, ok := cgoSourceFiles[file]; ok {
+		return src
+	}
+	return file
+}
+
+// -d.skipCgo 时，cgo 生成的文件 file 中被装饰的函数不会被装饰，逐个给出警告
+func warnCgoSkipped(file string) {
+	fset := token.NewFileSet()
+	pkg, err := parserGOFiles(fset, file)
+	if err != nil {
+		logs.Debug("parse cgo file fail", err)
+		return
+	}
+	visit := func(fd *ast.FuncDecl) bool {
+		if len(annotationDecorators(fd.Doc)) > 0 {
+			logs.Warn(fset.Position(fd.Pos()).String()+":", fd.Name.Name,
+				`is not decorated, its file imports "C" and -d.skipCgo is set`)
+		}
+		return false
+	}
+	for _, f := range pkg.Files {
+		visitAstDecl(f, visit)
+		visitAstFuncLitVar(f, visit)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCgoSourceFile(t *testing.T) {
+	dir := t.TempDir()
+	cgo1 := filepath.Join(dir, "p.cgo1.go")
+	src := "// Code generated by cmd/cgo; DO NOT EDIT.\n\n//line /src/p.go:1:1\npackage p\n"
+	if err := os.WriteFile(cgo1, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cgoSourceFile(cgo1); got != "/src/p.go" || !ok {
+		t.Fatalf("cgoSourceFile() got (%q, %v), want /src/p.go", got, ok)
+	}
+	plain := filepath.Join(dir, "p.go")
+	if err := os.WriteFile(plain, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cgoSourceFile(plain); ok {
+		t.Fatal("cgoSourceFile() should ignore files not generated by cgo")
+	}
+}
+
+func TestPrintRewrittenFileLineDirectives(t *testing.T) {
+	// 和 cgo 生成的文件一样，文件开头的 //line 指令指向原文件
+	src := `// Code generated by cmd/cgo; DO NOT EDIT.
+
+//line /src/p.go:1:1
+package p
+
+/*
+static int add(int a, int b) { return a + b; }
+*/
+import _ "unsafe"
+
+func F() int {
+	return 1
+}
+
+func G() int {
+	return (_Cfunc_add)( /*line :17:21*/ 1, 2)
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "/work/b001/p.cgo1.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟改写：在 F 中插入没有位置的语句，printer 在其后输出 //line 指令
+	fd := f.Decls[1].(*ast.FuncDecl)
+	fd.Body.List = append([]ast.Stmt{&ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent("println")}}}, fd.Body.List...)
+
+	var buf bytes.Buffer
+	if err := printRewrittenFile(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	outFset := token.NewFileSet()
+	of, err := parser.ParseFile(outFset, "/tmp/p.go", out, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("printRewrittenFile() output doesn't parse: %v\n%s", err, out)
+	}
+	for i, decl := range f.Decls {
+		if _, ok := decl.(*ast.FuncDecl); !ok {
+			continue
+		}
+		got, orig := outFset.Position(of.Decls[i].Pos()), fset.Position(decl.Pos())
+		if got.Filename != orig.Filename || got.Line != orig.Line {
+			t.Fatalf("printRewrittenFile() func at %s, want %s:\n%s", got, orig, out)
+		}
+	}
+}
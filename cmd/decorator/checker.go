@@ -4,13 +4,15 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"github.com/dengsgo/go-decorator/cmd/logs"
 	"go/ast"
+	"go/build/constraint"
+	"go/constant"
 	"go/parser"
-	"go/printer"
+	"go/scanner"
 	"go/token"
 	"go/types"
-	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -19,6 +21,7 @@ import (
 
 const (
 	msgLintArgsNotFound = "lint arg key not found: "
+	msgPkgNotResolvable = "decorator package not resolvable: "
 	msgLintTypeNotMatch = "lint key '%s' type not match: want %s but got %s"
 	msgLint
 )
@@ -30,7 +33,8 @@ var (
 	errUsedDecorSyntaxError          = errors.New("syntax error using decorator")
 	errCalledDecorNotDecorator       = errors.New("used decor is not a decorator function")
 
-	errLintSyntaxError = errors.New("syntax error using go:decor-lint")
+	errLintSyntaxError    = errors.New("syntax error using go:decor-lint")
+	errDefaultSyntaxError = errors.New("syntax error using go:decor-default")
 )
 
 type linterCheckError struct {
@@ -49,33 +53,6 @@ func (l *linterCheckError) Error() string {
 	return l.msg
 }
 
-// 判断一个函数声明（FuncDecl）是否是装饰器函数。
-// 装饰器函数有特定的签名，比如接收一个特定类型（通常是 *Context）的参数，并且不返回值。
-func isDecoratorFunc(fd *ast.FuncDecl, pkgName string) bool {
-	if pkgName == "" || // 包名为空
-		fd == nil || // 非函数声明
-		fd.Recv != nil || // 有接收者
-		fd.Type == nil || // 没有类型信息
-		fd.Type.Params == nil || // 没有参数列表
-		fd.Type.Params.NumFields() != 1 || // 参数列表包含多个参数
-		fd.Type.Params.List[0] == nil || // 第一个参数为空
-		fd.Type.Params.List[0].Type == nil { // 第一个参数类型为空
-		return false
-	}
-
-	// 取第一个参数类型
-	expr := fd.Type.Params.List[0].Type
-	// 将参数类型信息打印到缓冲区 buffer 中
-	buffer := bytes.NewBuffer([]byte{})
-	err := printer.Fprint(buffer, emptyFset, expr)
-	if err != nil {
-		logs.Debug("funIsDecorator printer.Fprint fail", err)
-		return false
-	}
-	// 比较 buffer.String() 与期望的字符串（*pkgName.Context）是否相同
-	return strings.TrimSpace(buffer.String()) == fmt.Sprintf("*%s.Context", pkgName)
-}
-
 // 注意，这里把 {key:"", name:"", age:100, b: false} 转换为 map[string]string ，
 // 是因为其实现上使用了 go parser 将其转换为 []ast.Expr{} ，内部字面量都是用字符串表示的。
 func parseDecorAndParameters(s string) (string, map[string]string, error) {
@@ -231,7 +208,8 @@ func decorStmtListToMap(exprList []ast.Expr, p *mapV[string, string]) error {
 
 	// 处理每个 *ast.KeyValueExpr 类型的表达式，提取键和值，并根据值的类型进行不同的处理：
 	//	- 如果值是基本字面量（*ast.BasicLit 或 *ast.UnaryExpr），则判断其类型是否为 string、int 或 float，并将其值存入字典 p 中。
-	//	- 如果值是 *ast.Ident（标识符），则检查该值是否为 true 或 false，并将其值存入字典 p 中。
+	//	- 如果值是 *ast.Ident（标识符），它是 true 、false 或当前包中声明的常量，将其名称存入字典 p 中。
+	//	- 如果值是 pkg.Name 形式的限定标识符（*ast.SelectorExpr），它引用具名类型的常量，原样存入字典 p 中。
 	//	- 如果出现重复的键或无效的值类型，将返回错误。
 	consumerKeyValue := func(expr *ast.KeyValueExpr) error {
 		key := ident(expr.Key)
@@ -255,14 +233,26 @@ func decorStmtListToMap(exprList []ast.Expr, p *mapV[string, string]) error {
 			default:
 				return errors.New("invalid parameter type") // error
 			}
-		case *ast.Ident: // 标识符
-			val := ident(value)
-			if val != "true" && val != "false" {
-				return errors.New("invalid parameter value, should be bool")
+		case *ast.CompositeLit: // a: ["b", "c"]
+			val, err := decorListLiteral(value)
+			if err != nil {
+				return errors.New("invalid parameter value, key '" + key + "': " + err.Error())
+			}
+			if !p.put(key, val) {
+				return errors.New("duplicate parameters key '" + key + "'")
 			}
+		case *ast.Ident: // 标识符，true/false 或当前包中的常量，常量由 resolveLocalConstParams 解析
+			val := ident(value)
 			if !p.put(key, val) {
 				return errors.New("duplicate parameters key '" + key + "'")
 			}
+		case *ast.SelectorExpr: // a: log.Debug
+			if ident(value.X) == "" {
+				return errors.New("invalid parameter value, key '" + key + "'")
+			}
+			if !p.put(key, typeString(value)) {
+				return errors.New("duplicate parameters key '" + key + "'")
+			}
 		default:
 			return errors.New("invalid parameter value")
 		}
@@ -442,7 +432,7 @@ func decorStmtListToMap(exprList []ast.Expr, p *mapV[string, string]) error {
 //			   },
 //			}
 func parseDecorParameterStringToExprList(s string) ([]ast.Expr, error) {
-	s = "map[any]any" + s
+	s = "map[any]any" + decorListBrackets(s)
 	stmts, _, err := getStmtList(s)
 	if err != nil {
 		return nil, errUsedDecorSyntaxErrorInvalidP
@@ -468,7 +458,56 @@ func parseDecorParameterStringToExprList(s string) ([]ast.Expr, error) {
 	return clit.Elts, nil
 }
 
-func checkDecorAndGetParam(pkgPath, funName string, annotationMap map[string]string) ([]string, error) {
+// 注解中的列表参数写作 ["a", "b"] ，它不是合法的 Go 表达式。
+// 这里将字符串字面量之外的 [ ] 替换为 { } ，解析为省略类型的复合字面量：
+//
+//	{names: ["a", "b"]} => {names: {"a", "b"}}
+func decorListBrackets(s string) string {
+	src := []byte(s)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var sc scanner.Scanner
+	sc.Init(file, src, nil, 0)
+	b := []byte(s)
+	for {
+		pos, tok, _ := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		switch tok {
+		case token.LBRACK:
+			b[file.Offset(pos)] = '{'
+		case token.RBRACK:
+			b[file.Offset(pos)] = '}'
+		}
+	}
+	return string(b)
+}
+
+// 将列表参数的复合字面量转换为字符串，例如 {"a", "b"} 。
+// 元素只能是字符串或整数，并且类型相同。
+func decorListLiteral(cl *ast.CompositeLit) (string, error) {
+	if cl.Type != nil {
+		return "", errors.New("invalid list value")
+	}
+	elems := make([]string, 0, len(cl.Elts))
+	kind := token.ILLEGAL
+	for _, elt := range cl.Elts {
+		lit := realBasicLit(elt)
+		if lit == nil || (lit.Kind != token.STRING && lit.Kind != token.INT) {
+			return "", errors.New("list elements should be string or int")
+		}
+		if kind != token.ILLEGAL && lit.Kind != kind {
+			return "", errors.New("list elements should be of the same type")
+		}
+		kind = lit.Kind
+		elems = append(elems, lit.Value)
+	}
+	return "{" + strings.Join(elems, ", ") + "}", nil
+}
+
+// targetImp 为目标所在文件的导入，用于解析具名类型的常量的限定标识符，见 bindNamedConstParams ，可以为 nil 。
+func checkDecorAndGetParam(targetImp *importer, pkgPath, funName string, annotationMap map[string]string) ([]string, error) {
 	// 查找指定包路径（pkgPath）中的函数 funName 的声明（decl）
 	fset, decl, file, err := pkgILoader.findFunc(pkgPath, funName)
 	if err != nil {
@@ -488,27 +527,246 @@ func checkDecorAndGetParam(pkgPath, funName string, annotationMap map[string]str
 		return nil, errCalledDecorNotDecorator
 	}
 
-	// 检查第一个参数是否为 *xxx.Context
+	// 检查第一个参数是否为 *xxx.Context 或类型化的上下文 *xxx.ContextNInMOut[...]
 	for _, v := range m {
-		if v.index == 0 && v.typ != fmt.Sprintf("*%s.Context", pkgName) {
+		if _, _, _, ok := decorContextType(v.typ, pkgName); v.index == 0 && !ok {
 			return nil, errors.New("used decor is not a decorator function")
 		}
 	}
 
-	if len(m) == 1 {
+	// 末尾的 opts ...decor.Option 参数接收注解中没有对应形参的其余参数，它不参与具名参数的绑定和检查
+	opts := optionsDecorArg(decl, m, pkgName)
+	if opts != nil {
+		delete(m, opts.name)
+		if restDecorArg(m) != nil {
+			return nil, errors.New(fmt.Sprintf("decorator %s can't take both a map[string]string rest parameter and options", funName))
+		}
+	}
+
+	// 注解中的参数名必须是装饰器的形参，有 rest 参数或 options 时其余的参数由它们接收
+	if restDecorArg(m) == nil && opts == nil {
+		if err := checkUnknownDecorParams(funName, m, annotationMap); err != nil {
+			return nil, err
+		}
+	}
+	if len(m) == 1 && opts == nil {
 		return []string{}, nil
 	}
 	if err := parseLinterFromDocGroup(decl.Doc, m); err != nil {
 		return nil, errors.New(fmt.Sprintf("%s\n\tLint: %s", err.Error(), friendlyIDEPosition(fset, err.pos)))
 	}
+	// 注解中没有传的参数使用装饰器声明的默认值，默认值和注解中的值一样需要通过 lint 检查
+	defaults, derr := parseDecorDefaults(funName, decl.Doc, m)
+	if derr != nil {
+		return nil, errors.New(fmt.Sprintf("%s\n\tLint: %s", derr.Error(), friendlyIDEPosition(fset, derr.pos)))
+	}
+	annotationMap = withDecorDefaults(annotationMap, defaults)
+	annotationMap, err = pkgILoader.resolveLocalConstParams(m, annotationMap)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkParamRelations(m, annotationMap); err != nil {
+		return nil, errors.New(fmt.Sprintf("%s\n\tLint: %s", err.Error(), friendlyIDEPosition(fset, err.pos)))
+	}
+	consts, err := pkgILoader.bindNamedConstParams(pkgPath, imp, targetImp, m, annotationMap)
+	if err != nil {
+		return nil, err
+	}
+
+	// 如果最后一个参数的类型是 map[string]string ，它用来接收注解中没有对应形参的其余参数，
+	// 这些参数以字符串的形式原样转交给装饰器，不参与 lint 检查。
+	rest := restDecorArg(m)
+	if rest != nil {
+		params := make([]string, len(m))
+		params[rest.index] = restParamsLiteral(m, annotationMap)
+		delete(m, rest.name)
+		named, err := bindDecorParams(m, annotationMap, consts)
+		if err != nil {
+			return nil, err
+		}
+		copy(params[1:], named)
+		return params[1:], nil
+	}
+	if opts != nil {
+		named, err := bindDecorParams(m, annotationMap, consts)
+		if err != nil {
+			return nil, err
+		}
+		options, err := optionsLiterals(m, annotationMap)
+		if err != nil {
+			return nil, err
+		}
+		return append(named, options...), nil
+	}
+	return bindDecorParams(m, annotationMap, consts)
+}
+
+// 装饰器的作者可以在装饰器上声明参数的默认值，注解中没有传这些参数时使用它们，而不是类型的零值：
+//
+//	//go:decor-default {level: "info", repeat: false}
+//	func logging(ctx *decor.Context, level string, repeat bool) {}
+//
+// 可以有多行 //go:decor-default ，和 //go:decor-lint 混排。默认值只能是字面量（字符串、数字、
+// true/false 或列表），需要和形参的类型一致并通过装饰器上的 lint 规则。具名类型的形参不支持默认值。
+func parseDecorDefaults(funName string, doc *ast.CommentGroup, m decorArgsMap) (map[string]string, *linterCheckError) {
+	defaults := map[string]string{}
+	if doc == nil {
+		return defaults, nil
+	}
+	for _, c := range doc.List {
+		s, ok := trimDecorDeclFlag(c.Text, decorDefaultScanFlag)
+		if !ok {
+			continue
+		}
+		exprList, err := parseDecorParameterStringToExprList(strings.TrimSpace(s))
+		if err != nil {
+			return nil, newLinterCheckError(errDefaultSyntaxError.Error(), c.Pos())
+		}
+		p := newMapV[string, string]()
+		if err := decorStmtListToMap(exprList, p); err != nil {
+			return nil, newLinterCheckError(errDefaultSyntaxError.Error()+": "+err.Error(), c.Pos())
+		}
+		keys := make([]string, 0, len(p.items))
+		for key := range p.items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := p.items[key]
+			v, ok := m[key]
+			if !ok || v.index == 0 || v == restDecorArg(m) {
+				return nil, newLinterCheckError(fmt.Sprintf("default: decorator %s has no parameter '%s'", funName, key), c.Pos())
+			}
+			if _, ok := defaults[key]; ok {
+				return nil, newLinterCheckError("default: duplicate key '"+key+"'", c.Pos())
+			}
+			if err := v.passDefault(value); err != nil {
+				return nil, newLinterCheckError("default: "+err.Error(), c.Pos())
+			}
+			defaults[key] = value
+		}
+	}
+	return defaults, nil
+}
+
+// 合并注解中的参数和装饰器声明的默认值，返回新的映射，注解中的值优先
+func withDecorDefaults(annotationMap, defaults map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return annotationMap
+	}
+	merged := make(map[string]string, len(annotationMap)+len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range annotationMap {
+		merged[k] = v
+	}
+	return merged
+}
+
+// 检查注解中的参数名是否都是装饰器的形参（不包括第一个参数 *decor.Context ），
+// 不是时报错，并给出编辑距离最近的形参作为建议。
+func checkUnknownDecorParams(funName string, m decorArgsMap, annotationMap map[string]string) error {
+	keys := make([]string, 0, len(annotationMap))
+	for k := range annotationMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	names := make([]string, 0, len(m))
+	for _, v := range m {
+		if v.index > 0 {
+			names = append(names, v.name)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range keys {
+		if v, ok := m[k]; ok && v.index > 0 {
+			continue
+		}
+		msg := fmt.Sprintf("decorator %s has no parameter '%s'", funName, k)
+		if len(names) == 0 {
+			return errors.New(msg + ", it takes no parameters")
+		}
+		if s := suggestName(k, names); s != "" {
+			return errors.New(fmt.Sprintf("%s, did you mean '%s'?", msg, s))
+		}
+		return errors.New(fmt.Sprintf("%s, the parameters are: %s", msg, strings.Join(names, ", ")))
+	}
+	return nil
+}
+
+// 返回 names 中和 s 编辑距离最近的名称，距离超过 s 长度的一半（至少为 1 ，至多为 3 ）时返回空字符串。
+// 大小写不同的名称距离视为 0 。names 已排序，距离相同时返回靠前的名称。
+func suggestName(s string, names []string) string {
+	limit := len(s) / 2
+	if limit < 1 {
+		limit = 1
+	} else if limit > 3 {
+		limit = 3
+	}
+	best, bestDist := "", limit+1
+	for _, name := range names {
+		d := editDistance(strings.ToLower(s), strings.ToLower(name))
+		if d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// Levenshtein 编辑距离
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, x := range vs {
+		if x < v {
+			v = x
+		}
+	}
+	return v
+}
 
+// 按形参名称将注解参数绑定到装饰器的形参上，并进行 lint 检查。
+// consts 为已经由 bindNamedConstParams 绑定的具名类型的参数。
+// 返回的参数值不包括第一个参数 *decor.Context 。
+func bindDecorParams(m decorArgsMap, annotationMap map[string]string, consts map[string]string) ([]string, error) {
 	params := make([]string, len(m))
 	for _, v := range m {
 		// 跳过第一个参数
 		if v.index == 0 {
 			continue
 		}
+		if value, ok := consts[v.name]; ok {
+			params[v.index] = value
+			continue
+		}
 		if value, ok := annotationMap[v.name]; ok {
+			// 检查：限定标识符只能传给具名类型的形参
+			if isQualifiedIdent(value) {
+				return nil, errors.New(fmt.Sprintf("key '%s' value '%s' doesn't match type %s", v.name, value, v.typ))
+			}
+			// 检查：列表参数只能传给切片类型的形参，元素的类型需要和切片的元素类型一致
+			if err := v.passSliceType(value); err != nil {
+				return nil, err
+			}
 			// 检查：如果 v.nonzero 为 true，则要求 value 不能为零，否则报错；
 			if err := v.passNonzeroLint(value); err != nil {
 				return nil, err
@@ -517,7 +775,14 @@ func checkDecorAndGetParam(pkgPath, funName string, annotationMap map[string]str
 			if err := v.passRequiredLint(value); err != nil {
 				return nil, err
 			}
-			// 通过检查，保存到 params 中
+			// 检查：字符串是否满足 pattern 、prefix 、suffix 规则
+			if err := v.passStringLint(value); err != nil {
+				return nil, err
+			}
+			// 通过检查，保存到 params 中，列表参数加上切片类型：{"a"} => []string{"a"}
+			if v.isSlice() {
+				value = v.typ + value
+			}
 			params[v.index] = value
 		} else {
 			// 如果 value 不存在，检查该参数是否运行为空，不许则报错
@@ -525,18 +790,11 @@ func checkDecorAndGetParam(pkgPath, funName string, annotationMap map[string]str
 				return nil, errors.New(fmt.Sprintf("lint: key '%s' can't pass nonzero lint, must have value", v.name))
 			}
 			// 根据参数类型设置默认值
-			switch v.typeKind() {
-			case types.IsInteger:
-				params[v.index] = "0"
-			case types.IsFloat:
-				params[v.index] = "0.0"
-			case types.IsString:
-				params[v.index] = `""`
-			case types.IsBoolean:
-				params[v.index] = "false"
-			default:
+			value, ok := v.zeroValue()
+			if !ok {
 				return nil, errors.New("unsupported types '" + v.typ + "'")
 			}
+			params[v.index] = value
 		}
 	}
 
@@ -544,120 +802,632 @@ func checkDecorAndGetParam(pkgPath, funName string, annotationMap map[string]str
 	return params[1:], nil
 }
 
-// Go 语言的 ast.CommentGroup 表示一组注释，可能包含多个注释行。
-func parseLinterFromDocGroup(doc *ast.CommentGroup, args decorArgsMap) *linterCheckError {
-	// 检查注释是否为空。
-	if doc == nil || doc.List == nil || len(doc.List) == 0 {
-		return nil
-	}
-	// 从后向前遍历注释
-	for i := len(doc.List) - 1; i >= 0; i-- {
-		comment := doc.List[i]
-		// 检查注释是否以指定的标志开头
-		if !strings.HasPrefix(comment.Text, decorLintScanFlag) {
-			break
+// 具名类型的参数：装饰器的形参类型是装饰器所在的包，或装饰器导入的包中声明的具名类型，例如
+//
+//	type Level int
+//
+//	const (
+//		Debug Level = iota
+//		Info
+//	)
+//
+//	func Logging(ctx *decor.Context, level Level) {}
+//
+// 注解中的值需要是该类型的常量，以限定标识符的形式引用：//go:decor log.Logging#{level: log.Debug} ，
+// 限定的包名需要是目标所在的文件（targetImp）导入类型所在的包时使用的名字，生成的代码中原样使用它。
+// 类型声明在当前包中时，包名为当前包的名字，生成的代码中去掉限定。常量通过对类型所在的包做类型检查（go/types）查找；
+// 没有传值时使用零值。返回形参名到参数值的映射，形参的类型不是具名的基本类型时不在其中，交给 bindDecorParams 处理。
+func (d *pkgLoader) bindNamedConstParams(pkgPath string, imp, targetImp *importer, m decorArgsMap, annotationMap map[string]string) (map[string]string, error) {
+	consts := map[string]string{}
+	for _, v := range m {
+		if v.index == 0 || v.isSlice() || v.typeKind() != types.IsUntyped || v == restDecorArg(m) {
+			continue
 		}
-		// 解析注释的剩余部分，过程中会填充 args 中的字段信息
-		if err := resolveLinterFromAnnotation(comment.Text[len(decorLintScanFlag):], args); err != nil {
-			return newLinterCheckError(err.Error(), comment.Pos())
+		// Level 声明在装饰器所在的包中，log.Level 声明在装饰器导入的包 log 中
+		typePkgPath, typeName := pkgPath, v.typ
+		if x, name, ok := strings.Cut(v.typ, "."); ok {
+			path, ok := imp.importedName(x)
+			if !ok {
+				continue
+			}
+			typePkgPath, typeName = path, name
+		}
+		pkg, err := d.typesPkg(typePkgPath)
+		if err != nil {
+			return nil, err
+		}
+		tn, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		basic, ok := tn.Type().Underlying().(*types.Basic)
+		if !ok {
+			continue
+		}
+		value, ok := annotationMap[v.name]
+		if !ok {
+			if v.nonzero {
+				return nil, errors.New(fmt.Sprintf("lint: key '%s' can't pass nonzero lint, must have value", v.name))
+			}
+			consts[v.name] = basicZeroValue(basic)
+			continue
+		}
+		var c *types.Const
+		x, constName, _ := strings.Cut(value, ".")
+		if isQualifiedIdent(value) {
+			c, _ = pkg.Scope().Lookup(constName).(*types.Const)
+		}
+		if c == nil || !types.Identical(c.Type(), tn.Type()) {
+			return nil, errors.New(fmt.Sprintf("key '%s' value '%s' is not a constant of type %s", v.name, value, v.typ))
+		}
+		if typePkgPath == "" {
+			if x != pkg.Name() {
+				return nil, errors.New(fmt.Sprintf("key '%s' value '%s' should be qualified by the package name %s", v.name, value, pkg.Name()))
+			}
+			value = constName
+		} else {
+			// 匿名导入的包不能引用
+			path, ok := "", false
+			if targetImp != nil {
+				path, ok = targetImp.importedName(x)
+				if name, _ := targetImp.importedPath(path); name == "_" {
+					ok = false
+				}
+			}
+			if !ok || path != typePkgPath {
+				return nil, errors.New(fmt.Sprintf("key '%s' value '%s' should be qualified by the name %s is imported with in the file", v.name, value, typePkgPath))
+			}
+		}
+		if err := v.passRequiredLint(value); err != nil {
+			return nil, err
 		}
+		// 字符串常量按它的值检查格式规则
+		if c.Val().Kind() == constant.String {
+			if err := v.passStringLint(c.Val().ExactString()); err != nil {
+				return nil, err
+			}
+		} else if v.format != nil {
+			return nil, errors.New(fmt.Sprintf("lint: key '%s' value '%s' is not a string", v.name, value))
+		}
+		consts[v.name] = value
 	}
-	return nil
+	return consts, nil
 }
 
-func resolveLinterFromAnnotation(s string, args decorArgsMap) error {
-	switch {
-	case strings.HasPrefix(s, "required: "):
-		exprList, err := parseDecorParameterStringToExprList(strings.TrimLeft(s, "required: "))
-		if err != nil {
-			return errLintSyntaxError
+// 当前包中的常量：注解中的值可以是标注的文件所在的包中声明的常量，避免在注解中重复魔法数字，例如
+//
+//	const maxRetries = 3
+//
+//	//go:decor retry#{attempts: maxRetries}
+//
+// 常量通过对当前包做类型检查（go/types）查找。有类型的常量需要和形参的类型一致，无类型的常量需要
+// 能表示为形参的类型。之后按常量的值（字面量）做 lint 检查，生成的代码中也使用它的值，
+// 不受目标函数中同名的参数或变量的影响。剩余参数按常量的值转交。具名类型的形参仍然需要限定标识符，
+// 见 bindNamedConstParams 。返回替换了常量的注解参数，annotationMap 不会被修改。
+func (d *pkgLoader) resolveLocalConstParams(m decorArgsMap, annotationMap map[string]string) (map[string]string, error) {
+	var resolved map[string]string
+	for key, value := range annotationMap {
+		if !token.IsIdentifier(value) || value == "true" || value == "false" {
+			continue
 		}
-		for _, v := range exprList {
-			if err := obtainRequiredLinter(v, args); err != nil {
-				return err
-			}
+		v, ok := m[key]
+		if ok && (v.isSlice() || v.typeKind() == types.IsUntyped) {
+			// 具名类型的形参和列表参数不接受当前包中的常量
+			return nil, errors.New(fmt.Sprintf("key '%s' value '%s' doesn't match type %s", key, value, v.typ))
 		}
-	case strings.HasPrefix(s, "nonzero: "):
-		exprList, err := parseDecorParameterStringToExprList(strings.TrimLeft(s, "nonzero: "))
+		pkg, err := d.typesPkg("")
 		if err != nil {
-			return errLintSyntaxError
+			return nil, err
 		}
-		for _, v := range exprList {
-			// 检查 v 是否非空？若非空设置 args[v].nonzero = true ，否则报错。
-			if err := obtainNonzeroLinter(v, args); err != nil {
-				return err
+		var c *types.Const
+		if pkg != nil {
+			c, _ = pkg.Scope().Lookup(value).(*types.Const)
+		}
+		if c == nil {
+			return nil, errors.New(fmt.Sprintf("key '%s' value '%s' is not a constant declared in this package", key, value))
+		}
+		basic, _ := c.Type().Underlying().(*types.Basic)
+		if basic == nil || c.Val().Kind() == constant.Unknown {
+			return nil, errors.New(fmt.Sprintf("key '%s' value '%s' can't be resolved to a constant of a basic type", key, value))
+		}
+		lit, ok := "", false
+		if v == nil {
+			// 剩余参数
+			lit, ok = constLiteral(c.Val(), basic.Info())
+		} else if localConstAssignable(c.Type(), basic, v.typ) {
+			lit, ok = constLiteral(c.Val(), v.typeKind())
+		}
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("key '%s' value '%s' of type %s doesn't match type %s", key, value, c.Type(), v.typ))
+		}
+		if resolved == nil {
+			resolved = make(map[string]string, len(annotationMap))
+			for k, v := range annotationMap {
+				resolved[k] = v
 			}
 		}
-	default:
-		return errors.New("invalid linter: " + s)
+		resolved[key] = lit
 	}
-	return nil
+	if resolved == nil {
+		return annotationMap, nil
+	}
+	return resolved, nil
 }
 
-func obtainRequiredLinter(v ast.Expr, args decorArgsMap) error {
-	// 初始化 decorArg 结构中的 required 字段
-	initRequiredLinter := func(v *decorArg) {
-		if v.required != nil {
-			return
+// 类型为 typ 的常量是否可以传给类型为 paramType 的形参：有类型的常量需要类型一致，无类型的常量需要类别一致，
+// 整数可以传给浮点数。typ 的底层类型为 basic 。
+func localConstAssignable(typ types.Type, basic *types.Basic, paramType string) bool {
+	if basic.Info()&types.IsUntyped == 0 {
+		if obj := types.Universe.Lookup(paramType); obj != nil {
+			return types.Identical(typ, obj.Type())
 		}
-		v.required = &requiredLinter{}
+		return false
 	}
+	return true
+}
 
-	// 处理不同类型的 ast.Expr 表达式
-	switch expr := v.(type) {
-	case *ast.Ident: // {a}
-		// 如果 v 是一个标识符（例如 {a}），尝试从 args 中查找与 expr.Name（标识符名）对应的 decorArg 。
-
-		// 从 args 中查找与 expr.Name（标识符名）对应的 decorArg ，找不到报错
-		dpt, ok := args[expr.Name]
-		if !ok {
-			return errors.New(msgLintArgsNotFound + expr.Name) // error
+// 常量 val 作为 kind 类别的值的字面量，无法表示时返回 false
+func constLiteral(val constant.Value, kind types.BasicInfo) (string, bool) {
+	switch {
+	case kind&types.IsBoolean != 0:
+		if val.Kind() != constant.Bool {
+			return "", false
 		}
-		// 如果找到，初始化其 required 字段。
-		initRequiredLinter(dpt)
-	case *ast.KeyValueExpr: // {a:{}}
-		// 如果 v 是一个键值对表达式（例如 {a: {}}）
-
-		// 检查键（expr.Key）是否是一个标识符
-		if _, ok := expr.Key.(*ast.Ident); !ok {
-			return errLintSyntaxError
+		return val.String(), true
+	case kind&types.IsString != 0:
+		if val.Kind() != constant.String {
+			return "", false
 		}
-		// 尝试在 args 中找到与该标识符名匹配的 decorArg
-		dpt, ok := args[expr.Key.(*ast.Ident).Name]
-		if !ok {
-			return errors.New(msgLintArgsNotFound + expr.Key.(*ast.Ident).Name)
+		return val.ExactString(), true
+	case kind&types.IsInteger != 0:
+		if val.Kind() != constant.Int && val.Kind() != constant.Float {
+			return "", false
 		}
-		// 检查值是否是复合字面量
-		if _, ok := expr.Value.(*ast.CompositeLit); !ok {
-			return errLintSyntaxError
+		i := constant.ToInt(val)
+		if i.Kind() != constant.Int {
+			return "", false
 		}
+		return i.ExactString(), true
+	case kind&types.IsFloat != 0:
+		if val.Kind() != constant.Int && val.Kind() != constant.Float {
+			return "", false
+		}
+		if val.Kind() == constant.Int {
+			return val.ExactString(), true
+		}
+		f, _ := constant.Float64Val(val)
+		return strconv.FormatFloat(f, 'g', -1, 64), true
+	}
+	return "", false
+}
 
-		for _, lit := range expr.Value.(*ast.CompositeLit).Elts {
-			switch lit := lit.(type) {
-			case *ast.BasicLit, *ast.UnaryExpr: // {a:{"", "", 1, -1}}
-				// 基本字面量和一元表达式
-
-				// 解析基础字面量
-				rlit := realBasicLit(lit)
-				if rlit == nil {
-					return errLintSyntaxError
-				}
-				// 类型匹配检查
-				if (rlit.Kind == token.STRING && dpt.typeKind() != types.IsString) ||
-					(rlit.Kind == token.INT && dpt.typeKind() != types.IsInteger) ||
-					(rlit.Kind == token.FLOAT && dpt.typeKind() != types.IsFloat) {
-					return errors.New(fmt.Sprintf(msgLintTypeNotMatch, dpt.name, dpt.typ, rlit.Kind.String()))
-				}
-				// 将字面量添加到 requiredLinter 的 enum 字段中。
-				initRequiredLinter(dpt)
-				if dpt.required.enum == nil {
-					dpt.required.enum = []string{}
-				}
-				dpt.required.enum = append(dpt.required.enum, rlit.Value)
-			case *ast.Ident: // {a:{true, false}}
-				// 标识符
+// 基本类型的零值字面量
+func basicZeroValue(basic *types.Basic) string {
+	switch info := basic.Info(); {
+	case info&types.IsString != 0:
+		return `""`
+	case info&types.IsBoolean != 0:
+		return "false"
+	}
+	return "0"
+}
 
-				// 只支持 true 或 false
+// 值是否为 pkg.Name 形式的限定标识符
+func isQualifiedIdent(value string) bool {
+	expr, err := parser.ParseExpr(value)
+	if err != nil {
+		return false
+	}
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	_, ok = sel.X.(*ast.Ident)
+	return ok
+}
+
+// 返回装饰器末尾类型为 map[string]string 的剩余参数，没有时返回 nil 。
+func restDecorArg(m decorArgsMap) *decorArg {
+	for _, v := range m {
+		if v.index > 0 && v.index == len(m)-1 && v.typ == "map[string]string" {
+			return v
+		}
+	}
+	return nil
+}
+
+// 将注解中没有对应形参的参数生成 map[string]string 的字面量，key 按字典序排列。
+// 字符串参数会去掉引号，其他类型的参数保留原始的字面值，例如 {a: "x", b: 1} => {"a": "x", "b": "1"} 。
+func restParamsLiteral(m decorArgsMap, annotationMap map[string]string) string {
+	keys := make([]string, 0, len(annotationMap))
+	for k := range annotationMap {
+		if _, ok := m[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	kvs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		value := annotationMap[k]
+		if s, err := strconv.Unquote(value); err == nil {
+			value = s
+		}
+		kvs = append(kvs, strconv.Quote(k)+": "+strconv.Quote(value))
+	}
+	return "map[string]string{" + strings.Join(kvs, ", ") + "}"
+}
+
+// 返回装饰器末尾的可变参数 opts ...decor.Option ，没有时返回 nil ：
+//
+//	func retry(ctx *decor.Context, times int, opts ...decor.Option) {}
+//
+//	//go:decor retry#{times: 3, backoff: "exp", codes: {500, 503}}
+//
+// 注解中没有对应形参的参数按 key 的字典序生成 decor.Option ，作为可变参数传给装饰器。
+// 只识别可变参数，opts []decor.Option 是普通的形参。
+func optionsDecorArg(fd *ast.FuncDecl, m decorArgsMap, pkgName string) *decorArg {
+	list := fd.Type.Params.List
+	last := list[len(list)-1]
+	ellipsis, ok := last.Type.(*ast.Ellipsis)
+	if !ok || len(last.Names) != 1 || typeString(ellipsis.Elt) != pkgName+".Option" {
+		return nil
+	}
+	v := m[last.Names[0].Name]
+	if v == nil || v.index == 0 {
+		return nil
+	}
+	return v
+}
+
+// 将注解中没有对应形参的参数生成 decor.Option 的字面量，key 按字典序排列，例如
+// {backoff: "exp", codes: {500, 503}} => decor.Option{Key: "backoff", Value: "exp"}, decor.Option{Key: "codes", Value: []int{500, 503}} 。
+// 值保持 Go 字面量的默认类型（string 、int 、float64 、bool），列表的元素需要都是字符串或都是整数，
+// 分别生成 []string 和 []int ，空列表生成 []any{} 。
+func optionsLiterals(m decorArgsMap, annotationMap map[string]string) ([]string, error) {
+	keys := make([]string, 0, len(annotationMap))
+	for k := range annotationMap {
+		if _, ok := m[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	options := make([]string, 0, len(keys))
+	for _, k := range keys {
+		value := annotationMap[k]
+		if strings.HasPrefix(value, "{") {
+			typ, ok := optionListType(value)
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("option '%s' value '%s' must be a list of strings or integers", k, value))
+			}
+			value = typ + value
+		}
+		options = append(options, fmt.Sprintf("decor.Option{Key: %s, Value: %s}", strconv.Quote(k), value))
+	}
+	return options, nil
+}
+
+// 列表 {e1, e2} 作为 decor.Option 的值时的类型：[]string 、[]int ，空列表为 []any
+func optionListType(value string) (string, bool) {
+	expr, err := parser.ParseExpr("[]any" + value)
+	if err != nil {
+		return "", false
+	}
+	cl, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return "", false
+	}
+	if len(cl.Elts) == 0 {
+		return "[]any", true
+	}
+	kind := token.ILLEGAL
+	for _, elt := range cl.Elts {
+		lit := realBasicLit(elt)
+		if lit == nil || (lit.Kind != token.STRING && lit.Kind != token.INT) || (kind != token.ILLEGAL && lit.Kind != kind) {
+			return "", false
+		}
+		kind = lit.Kind
+	}
+	if kind == token.STRING {
+		return "[]string", true
+	}
+	return "[]int", true
+}
+
+// 从装饰注释的参数中取出保留的 priority 参数，它控制多个装饰器的包装顺序，不传给装饰器：
+//
+//	//go:decor logging#{priority: 10}
+//
+// priority 必须是整数，默认为 0 ，值越大越靠外层；相同时保持注释的顺序。
+func takeDecorPriority(parameters map[string]string) (int, error) {
+	value, ok := parameters[decorPriorityKey]
+	if !ok {
+		return 0, nil
+	}
+	delete(parameters, decorPriorityKey)
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("decorator priority must be an integer, but got " + value)
+	}
+	return priority, nil
+}
+
+// 从装饰注释的参数中取出保留的 when 参数，它是构建标签风格的表达式，不传给装饰器：
+//
+//	//go:decor metrics#{when: "prod && !debug"}
+//
+// 表达式满足 -d.tags 指定的标签时装饰器才会生效，否则编译时忽略这个装饰器。没有 when 参数时总是生效。
+func takeDecorWhen(parameters map[string]string, tags map[string]bool) (bool, error) {
+	value, ok := parameters[decorWhenKey]
+	if !ok {
+		return true, nil
+	}
+	delete(parameters, decorWhenKey)
+	s, err := strconv.Unquote(value)
+	if err != nil {
+		return false, errors.New("decorator when must be a string, but got " + value)
+	}
+	expr, err := constraint.Parse("//go:build " + s)
+	if err != nil {
+		return false, errors.New("decorator when is not a valid tag expression: " + s)
+	}
+	return expr.Eval(func(tag string) bool { return tags[tag] }), nil
+}
+
+// 从装饰注释的参数中取出保留的 allowMain 参数，它允许装饰器装饰程序的入口 main 和 init ，不传给装饰器：
+//
+//	//go:decor boot#{allowMain: true}
+//	func main() {}
+//
+// 装饰入口会改变程序启动的方式，因此需要每个装饰器显式地声明。
+func takeDecorAllowMain(parameters map[string]string) (bool, error) {
+	value, ok := parameters[decorAllowMainKey]
+	if !ok {
+		return false, nil
+	}
+	delete(parameters, decorAllowMainKey)
+	if value != "true" && value != "false" {
+		return false, errors.New("decorator allowMain must be a bool, but got " + value)
+	}
+	return value == "true", nil
+}
+
+// fd 是否为程序的入口：任意包中的 init 函数，或 main 包中的 main 函数
+func isEntryFunc(f *ast.File, fd *ast.FuncDecl) bool {
+	if fd.Recv != nil || fd.Name == nil {
+		return false
+	}
+	return fd.Name.Name == "init" || fd.Name.Name == "main" && f.Name.Name == "main"
+}
+
+// -d.tags 指定的装饰器标签
+func decorTags() map[string]bool {
+	tags := map[string]bool{}
+	for _, tag := range strings.Split(cmdFlag.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags[tag] = true
+		}
+	}
+	return tags
+}
+
+// 目标函数上的 lint 规则，和装饰注释一起写在目标函数上：
+//
+//	//go:decor retry
+//	//go:decor-lint once: true
+//	func pay(order string) error {}
+//
+// once: 目标函数不是幂等的，只能被执行一次。装饰器调用 TargetDo 超过一次时，生成的代码会在返回前 panic 。
+//
+// assignable: 读取 TargetOut 时，接受能够赋值给返回值类型的值（通过 decor.Assign ），而不要求类型完全相同。
+type targetLint struct {
+	once,
+	assignable bool
+}
+
+func parseTargetLint(doc *ast.CommentGroup) (*targetLint, *linterCheckError) {
+	tl := &targetLint{}
+	if doc == nil {
+		return tl, nil
+	}
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, decorLintScanFlag) {
+			continue
+		}
+		s := c.Text[len(decorLintScanFlag):]
+		key, value, _ := strings.Cut(s, ":")
+		var b *bool
+		switch key = strings.TrimSpace(key); key {
+		case "once":
+			b = &tl.once
+		case "assignable":
+			b = &tl.assignable
+		default:
+			return nil, newLinterCheckError("invalid target linter: "+s, c.Pos())
+		}
+		switch strings.TrimSpace(value) {
+		case "true":
+			*b = true
+		case "false":
+			*b = false
+		default:
+			return nil, newLinterCheckError("target lint "+key+" value must be true or false: "+s, c.Pos())
+		}
+	}
+	return tl, nil
+}
+
+// Go 语言的 ast.CommentGroup 表示一组注释，可能包含多个注释行。
+func parseLinterFromDocGroup(doc *ast.CommentGroup, args decorArgsMap) *linterCheckError {
+	// 检查注释是否为空。
+	if doc == nil || doc.List == nil || len(doc.List) == 0 {
+		return nil
+	}
+	// 从后向前遍历注释
+	for i := len(doc.List) - 1; i >= 0; i-- {
+		comment := doc.List[i]
+		// //go:decor-pure 、//go:decor-default 、//go:decor-deprecated 可以和 lint 注释混排
+		if _, ok := trimDecorDeclFlag(comment.Text, decorDefaultScanFlag); ok || isDecorPureFlag(comment.Text) ||
+			isDecorDeprecatedFlag(comment.Text) {
+			continue
+		}
+		// 检查注释是否以指定的标志开头
+		s, ok := trimDecorDeclFlag(comment.Text, decorLintScanFlag)
+		if !ok {
+			break
+		}
+		// 解析注释的剩余部分，过程中会填充 args 中的字段信息
+		if err := resolveLinterFromAnnotation(s, args); err != nil {
+			return newLinterCheckError(err.Error(), comment.Pos())
+		}
+		// 记录新增的约束所在的注释，报错时指向它
+		for _, v := range args {
+			for _, r := range v.relations {
+				if !r.pos.IsValid() {
+					r.pos = comment.Pos()
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func resolveLinterFromAnnotation(s string, args decorArgsMap) error {
+	switch {
+	case strings.HasPrefix(s, "required: "):
+		exprList, err := parseDecorParameterStringToExprList(strings.TrimLeft(s, "required: "))
+		if err != nil {
+			return errLintSyntaxError
+		}
+		for _, v := range exprList {
+			if err := obtainRequiredLinter(v, args); err != nil {
+				return err
+			}
+		}
+	case strings.HasPrefix(s, "external: "):
+		// 在每处使用装饰器的地方执行，由 checkDecorExternalLint 检查
+		if _, err := parseExternalLint(s); err != nil {
+			return err
+		}
+	case strings.HasPrefix(s, "signature: "):
+		// 约束的是目标函数的签名，由 checkDecorSignature 检查
+		if _, err := parseSignatureLint(s); err != nil {
+			return err
+		}
+	case strings.HasPrefix(s, "comparable: "):
+		// 约束的是目标函数的签名，由 checkDecorComparable 检查
+		if _, err := parseLintBool(s); err != nil {
+			return err
+		}
+	case strings.HasPrefix(s, "pattern: "), strings.HasPrefix(s, "prefix: "), strings.HasPrefix(s, "suffix: "):
+		key, value, _ := strings.Cut(s, ": ")
+		exprList, err := parseDecorParameterStringToExprList(value)
+		if err != nil {
+			return errLintSyntaxError
+		}
+		for _, v := range exprList {
+			if err := obtainStringLinter(key, v, args); err != nil {
+				return err
+			}
+		}
+	case strings.HasPrefix(s, "exclusive: "):
+		exprList, err := parseDecorParameterStringToExprList(strings.TrimPrefix(s, "exclusive: "))
+		if err != nil {
+			return errLintSyntaxError
+		}
+		if err := obtainExclusiveLinter(exprList, args); err != nil {
+			return err
+		}
+	case strings.HasPrefix(s, "requires: "):
+		exprList, err := parseDecorParameterStringToExprList(strings.TrimPrefix(s, "requires: "))
+		if err != nil {
+			return errLintSyntaxError
+		}
+		for _, v := range exprList {
+			if err := obtainRequiresLinter(v, args); err != nil {
+				return err
+			}
+		}
+	case strings.HasPrefix(s, "nonzero: "):
+		exprList, err := parseDecorParameterStringToExprList(strings.TrimLeft(s, "nonzero: "))
+		if err != nil {
+			return errLintSyntaxError
+		}
+		for _, v := range exprList {
+			// 检查 v 是否非空？若非空设置 args[v].nonzero = true ，否则报错。
+			if err := obtainNonzeroLinter(v, args); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("invalid linter: " + s)
+	}
+	return nil
+}
+
+func obtainRequiredLinter(v ast.Expr, args decorArgsMap) error {
+	// 初始化 decorArg 结构中的 required 字段
+	initRequiredLinter := func(v *decorArg) {
+		if v.required != nil {
+			return
+		}
+		v.required = &requiredLinter{}
+	}
+
+	// 处理不同类型的 ast.Expr 表达式
+	switch expr := v.(type) {
+	case *ast.Ident: // {a}
+		// 如果 v 是一个标识符（例如 {a}），尝试从 args 中查找与 expr.Name（标识符名）对应的 decorArg 。
+
+		// 从 args 中查找与 expr.Name（标识符名）对应的 decorArg ，找不到报错
+		dpt, ok := args[expr.Name]
+		if !ok {
+			return errors.New(msgLintArgsNotFound + expr.Name) // error
+		}
+		// 如果找到，初始化其 required 字段。
+		initRequiredLinter(dpt)
+	case *ast.KeyValueExpr: // {a:{}}
+		// 如果 v 是一个键值对表达式（例如 {a: {}}）
+
+		// 检查键（expr.Key）是否是一个标识符
+		if _, ok := expr.Key.(*ast.Ident); !ok {
+			return errLintSyntaxError
+		}
+		// 尝试在 args 中找到与该标识符名匹配的 decorArg
+		dpt, ok := args[expr.Key.(*ast.Ident).Name]
+		if !ok {
+			return errors.New(msgLintArgsNotFound + expr.Key.(*ast.Ident).Name)
+		}
+		// 检查值是否是复合字面量
+		if _, ok := expr.Value.(*ast.CompositeLit); !ok {
+			return errLintSyntaxError
+		}
+
+		for _, lit := range expr.Value.(*ast.CompositeLit).Elts {
+			switch lit := lit.(type) {
+			case *ast.BasicLit, *ast.UnaryExpr: // {a:{"", "", 1, -1}}
+				// 基本字面量和一元表达式
+
+				// 解析基础字面量
+				rlit := realBasicLit(lit)
+				if rlit == nil {
+					return errLintSyntaxError
+				}
+				// 类型匹配检查
+				if (rlit.Kind == token.STRING && dpt.typeKind() != types.IsString) ||
+					(rlit.Kind == token.INT && dpt.typeKind() != types.IsInteger) ||
+					(rlit.Kind == token.FLOAT && dpt.typeKind() != types.IsFloat) {
+					return errors.New(fmt.Sprintf(msgLintTypeNotMatch, dpt.name, dpt.typ, rlit.Kind.String()))
+				}
+				// 将字面量添加到 requiredLinter 的 enum 字段中。
+				initRequiredLinter(dpt)
+				if dpt.required.enum == nil {
+					dpt.required.enum = []string{}
+				}
+				dpt.required.enum = append(dpt.required.enum, rlit.Value)
+			case *ast.Ident: // {a:{true, false}}
+				// 标识符
+
+				// 只支持 true 或 false
 				if lit.Name != "true" && lit.Name != "false" {
 					return errors.New(fmt.Sprintf("lint required key '%s' value must be true or false, but got %s", dpt.name, lit.Name))
 				}
@@ -701,15 +1471,148 @@ func obtainRequiredLinter(v ast.Expr, args decorArgsMap) error {
 					return errors.New(fmt.Sprintf("lint required key '%s' compare %s value canot be convert to float, %s; error: %+v", dpt.name, key, lity.Value, err))
 				}
 
-			default:
-				return errLintSyntaxError
+			default:
+				return errLintSyntaxError
+			}
+		}
+
+	default:
+		return errLintSyntaxError
+	}
+
+	return nil
+}
+
+// 解析字符串参数的格式规则 {a: "value"} 或 {a: {"v1", "v2"}} ，rule 为 pattern 、prefix 或 suffix 。
+// 参数需要是字符串、字符串切片或者具名类型（具名类型的常量在绑定时检查底层类型）。
+func obtainStringLinter(rule string, v ast.Expr, args decorArgsMap) error {
+	expr, ok := v.(*ast.KeyValueExpr)
+	if !ok {
+		return errLintSyntaxError
+	}
+	key, ok := expr.Key.(*ast.Ident)
+	if !ok {
+		return errLintSyntaxError
+	}
+	dpt, ok := args[key.Name]
+	if !ok {
+		return errors.New(msgLintArgsNotFound + key.Name)
+	}
+	elem := *dpt
+	if dpt.isSlice() {
+		elem.typ = dpt.typ[len("[]"):]
+	}
+	if kind := elem.typeKind(); kind != types.IsString && kind != types.IsUntyped {
+		return errors.New(fmt.Sprintf("lint %s key '%s' must be a string parameter, but got %s", rule, dpt.name, dpt.typ))
+	}
+	var lits []ast.Expr
+	if cl, ok := expr.Value.(*ast.CompositeLit); ok {
+		lits = cl.Elts
+	} else {
+		lits = []ast.Expr{expr.Value}
+	}
+	if len(lits) == 0 {
+		return errLintSyntaxError
+	}
+	if dpt.format == nil {
+		dpt.format = &stringLinter{}
+	}
+	for _, lit := range lits {
+		rlit := realBasicLit(lit)
+		if rlit == nil || rlit.Kind != token.STRING {
+			return errors.New(fmt.Sprintf("lint %s key '%s' value must be a string", rule, dpt.name))
+		}
+		value, err := strconv.Unquote(rlit.Value)
+		if err != nil {
+			return errLintSyntaxError
+		}
+		switch rule {
+		case "pattern":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return errors.New(fmt.Sprintf("lint pattern key '%s' value %s is not a valid regexp: %v", dpt.name, rlit.Value, err))
+			}
+			dpt.format.pattern = append(dpt.format.pattern, re)
+		case "prefix":
+			dpt.format.prefix = append(dpt.format.prefix, value)
+		case "suffix":
+			dpt.format.suffix = append(dpt.format.suffix, value)
+		}
+	}
+	return nil
+}
+
+// 解析 exclusive: {a, b} ，a 和 b 不能同时传递，约束记录在第一个参数上
+func obtainExclusiveLinter(exprList []ast.Expr, args decorArgsMap) error {
+	if len(exprList) < 2 {
+		return errors.New("lint exclusive needs at least two keys")
+	}
+	names := make([]string, 0, len(exprList))
+	for _, v := range exprList {
+		id, ok := v.(*ast.Ident)
+		if !ok {
+			return errLintSyntaxError
+		}
+		if _, ok := args[id.Name]; !ok {
+			return errors.New(msgLintArgsNotFound + id.Name)
+		}
+		names = append(names, id.Name)
+	}
+	first := args[names[0]]
+	first.relations = append(first.relations, &paramRelation{rule: "exclusive", owner: first.name, names: names})
+	return nil
+}
+
+// 解析 requires: {a: {b, c}} ，传递了 a 时 b 和 c 也需要传递
+func obtainRequiresLinter(v ast.Expr, args decorArgsMap) error {
+	expr, ok := v.(*ast.KeyValueExpr)
+	if !ok {
+		return errLintSyntaxError
+	}
+	key, ok := expr.Key.(*ast.Ident)
+	if !ok {
+		return errLintSyntaxError
+	}
+	dpt, ok := args[key.Name]
+	if !ok {
+		return errors.New(msgLintArgsNotFound + key.Name)
+	}
+	cl, ok := expr.Value.(*ast.CompositeLit)
+	if !ok || len(cl.Elts) == 0 {
+		return errLintSyntaxError
+	}
+	names := make([]string, 0, len(cl.Elts))
+	for _, elt := range cl.Elts {
+		id, ok := elt.(*ast.Ident)
+		if !ok {
+			return errLintSyntaxError
+		}
+		if _, ok := args[id.Name]; !ok {
+			return errors.New(msgLintArgsNotFound + id.Name)
+		}
+		if id.Name == dpt.name {
+			return errors.New(fmt.Sprintf("lint requires key '%s' can't require itself", dpt.name))
+		}
+		names = append(names, id.Name)
+	}
+	dpt.relations = append(dpt.relations, &paramRelation{rule: "requires", owner: dpt.name, names: names})
+	return nil
+}
+
+// 按形参的顺序检查 exclusive 、requires 约束，返回第一个不满足的约束
+func checkParamRelations(m decorArgsMap, annotationMap map[string]string) *linterCheckError {
+	args := make([]*decorArg, 0, len(m))
+	for _, v := range m {
+		args = append(args, v)
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i].index < args[j].index })
+	for _, v := range args {
+		for _, r := range v.relations {
+			if err := r.check(annotationMap); err != nil {
+				return newLinterCheckError(err.Error(), r.pos)
 			}
 		}
-
-	default:
-		return errLintSyntaxError
 	}
-
 	return nil
 }
 
@@ -750,25 +1653,491 @@ func collDeclFuncParamsAnfTypes(fd *ast.FuncDecl) (m decorArgsMap) {
 		typ := typeString(field.Type)
 		// 当一个参数是多个变量时，如 x, y int ，遍历这些变量
 		for _, id := range field.Names {
-			m[id.Name] = &decorArg{index, id.Name, typ, nil, false}
+			m[id.Name] = &decorArg{index, id.Name, typ, nil, false, nil, nil}
 			index++ // 每处理一个参数，index 加 1
 		}
 	}
 	return m
 }
 
+// 对标记了 //go:decor-pure 的装饰器做保守的纯净性检查：
+// 函数体中不能引用本包的包级变量，也不能调用会产生 I/O 的标准库（见 decorPureIOPackages）。
+// 装饰器没有标记时直接返回 nil 。
+func checkDecorPure(pkgPath, funName string) error {
+	fset, decl, file, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil {
+		return err
+	}
+	if !hasDecorPureFlag(decl.Doc) {
+		return nil
+	}
+	set, err := pkgILoader.loadPkg(pkgPath)
+	if err != nil {
+		return err
+	}
+	globals := map[string]bool{}
+	for _, pkg := range set.pkgs {
+		for _, f := range pkg.Files {
+			for _, d := range f.Decls {
+				gd, ok := d.(*ast.GenDecl)
+				if !ok || gd.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					for _, id := range spec.(*ast.ValueSpec).Names {
+						// var _ = ... 不声明变量
+						if id.Name != "_" {
+							globals[id.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	if pos, msg := decorImpurity(decl, newImporter(file), globals); msg != "" {
+		return errors.New(fmt.Sprintf("decorator %s is marked %s but %s\n\tImpure: %s",
+			funName, decorPureFlag, msg, friendlyIDEPosition(fset, pos)))
+	}
+	return nil
+}
+
+// 装饰器注释 //go:decor-lint comparable: true 要求目标函数的参数和方法的接收者都是可比较的值，
+// 用于以参数为键缓存结果的装饰器（如 decor/cache 的 Memoize ）。
+//
+// 检查是语法上的：指针、切片、map 、函数、通道和可变参数不能通过，具名类型和接口由装饰器在运行时检查。
+// 找不到装饰器时返回 nil ，这个错误由 checkDecorAndGetParam 报告。
+func checkDecorComparable(pkgPath, funName string, target *ast.FuncDecl) error {
+	_, decl, _, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil || !hasDecorComparableLint(decl.Doc) {
+		return nil
+	}
+	if target.Recv != nil {
+		for _, field := range target.Recv.List {
+			if kind := incomparableKind(field.Type); kind != "" {
+				return errors.New(fmt.Sprintf("decorator %s requires comparable parameters, but the receiver is a %s", funName, kind))
+			}
+		}
+	}
+	for i, field := range target.Type.Params.List {
+		if kind := incomparableKind(field.Type); kind != "" {
+			name := strconv.Itoa(i)
+			if len(field.Names) > 0 {
+				name = field.Names[0].Name
+			}
+			return errors.New(fmt.Sprintf("decorator %s requires comparable parameters, but parameter %s is a %s", funName, name, kind))
+		}
+	}
+	return nil
+}
+
+func hasDecorComparableLint(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		s, ok := trimDecorDeclFlag(c.Text, decorLintScanFlag)
+		if ok && strings.HasPrefix(s, "comparable: ") {
+			b, err := parseLintBool(s)
+			return err == nil && b
+		}
+	}
+	return false
+}
+
+// 解析 key: true 形式的 lint 注释的值
+func parseLintBool(s string) (bool, error) {
+	key, value, _ := strings.Cut(s, ":")
+	switch strings.TrimSpace(value) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, errors.New("lint " + strings.TrimSpace(key) + " value must be true or false: " + s)
+}
+
+// 语法上不可比较的类型的种类，如 pointer 、slice ，可比较或无法从语法上判断时为空字符串。
+// 指针虽然可以比较，但比较的是地址而不是指向的值，同样视为不可比较。
+func incomparableKind(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return incomparableKind(t.X)
+	case *ast.StarExpr:
+		return "pointer"
+	case *ast.Ellipsis:
+		return "variadic parameter"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "slice"
+		}
+		return incomparableKind(t.Elt)
+	case *ast.MapType:
+		return "map"
+	case *ast.FuncType:
+		return "func"
+	case *ast.ChanType:
+		return "chan"
+	case *ast.StructType:
+		for _, field := range t.Fields.List {
+			if kind := incomparableKind(field.Type); kind != "" {
+				return kind
+			}
+		}
+	}
+	return ""
+}
+
+// 装饰器标记了 //go:decor-deprecated 时返回提示信息和装饰器声明的位置，说明文字写在标记之后：
+//
+//	//go:decor-deprecated use Tracing instead
+//	func Logging(ctx *decor.Context) {}
+//
+// 找不到装饰器或没有标记时 msg 为空，找不到装饰器的错误由 checkDecorAndGetParam 报告。
+func checkDecorDeprecated(pkgPath, funName string) (msg, declPos string) {
+	fset, decl, _, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil || decl.Doc == nil {
+		return "", ""
+	}
+	for _, c := range decl.Doc.List {
+		if isDecorDeprecatedFlag(c.Text) {
+			msg = "decorator " + funName + " is deprecated"
+			if text, _ := trimDecorDeclFlag(c.Text, decorDeprecatedFlag); strings.TrimSpace(text) != "" {
+				msg += ": " + strings.TrimSpace(text)
+			}
+			return msg, friendlyIDEPosition(fset, decl.Pos())
+		}
+	}
+	return "", ""
+}
+
+// 注释是否为 //go:decor-deprecated 标记，标记之后可以有空白分隔的说明文字
+func isDecorDeprecatedFlag(comment string) bool {
+	s, ok := trimDecorDeclFlag(comment, decorDeprecatedFlag)
+	return ok && (s == "" || s[0] == ' ' || s[0] == '\t')
+}
+
+// 注释是否为 //go:decor-pure 标记
+func isDecorPureFlag(comment string) bool {
+	s, ok := trimDecorDeclFlag(strings.TrimSpace(comment), decorPureFlag)
+	return ok && s == ""
+}
+
+func hasDecorPureFlag(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if isDecorPureFlag(c.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回装饰器中第一个不纯的位置和原因，没有时 msg 为空。
+//
+// 检查是保守的：局部声明的名字会遮蔽同名的包级变量，无论它在哪个作用域中声明。
+func decorImpurity(decl *ast.FuncDecl, imp *importer, globals map[string]bool) (pos token.Pos, msg string) {
+	if decl == nil || decl.Body == nil {
+		return
+	}
+	locals := map[string]bool{}
+	addFields := func(fl *ast.FieldList) {
+		if fl == nil {
+			return
+		}
+		for _, field := range fl.List {
+			for _, id := range field.Names {
+				locals[id.Name] = true
+			}
+		}
+	}
+	addFields(decl.Recv)
+	addFields(decl.Type.Params)
+	addFields(decl.Type.Results)
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, expr := range n.Lhs {
+					if id, ok := expr.(*ast.Ident); ok {
+						locals[id.Name] = true
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				for _, expr := range []ast.Expr{n.Key, n.Value} {
+					if id, ok := expr.(*ast.Ident); ok {
+						locals[id.Name] = true
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, id := range n.Names {
+				locals[id.Name] = true
+			}
+		case *ast.FuncLit:
+			addFields(n.Type.Params)
+			addFields(n.Type.Results)
+		}
+		return true
+	})
+
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		if msg != "" {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// 包名.成员 ，检查是否为 I/O 调用；其他选择器只需检查 X ，Sel 是字段或方法名
+			if x, ok := n.X.(*ast.Ident); ok && !locals[x.Name] {
+				if path, ok := imp.importedName(x.Name); ok {
+					if decorPureIO(path, n.Sel.Name) {
+						pos, msg = n.Pos(), "performs I/O: "+x.Name+"."+n.Sel.Name
+					}
+					return false
+				}
+			}
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.Ident:
+			if globals[n.Name] && !locals[n.Name] {
+				pos, msg = n.Pos(), "references package-level variable: "+n.Name
+			}
+		}
+		return true
+	}
+	ast.Inspect(decl.Body, visit)
+	return
+}
+
+// 判断 path 包中的 name 是否会产生 I/O 。
+func decorPureIO(path, name string) bool {
+	if path == "fmt" {
+		for _, prefix := range []string{"Print", "Fprint", "Scan", "Fscan"} {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range decorPureIOPackages {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 检查装饰器是否接收类型化的上下文，是时返回目标应有的参数和返回值个数
+func checkDecorTypedContext(pkgPath, funName string) (in, out int, typed bool, err error) {
+	_, decl, file, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil {
+		return
+	}
+	pkgName, _ := newImporter(file).importedPath(decoratorPackagePath)
+	if pkgName == "" || decl.Type.Params == nil || len(decl.Type.Params.List) == 0 {
+		return
+	}
+	in, out, typed, _ = decorContextType(typeString(decl.Type.Params.List[0].Type), pkgName)
+	return
+}
+
+// 检查装饰器是否考虑了方法的接收者。
+//
+// 对方法而言，接收者不在 TargetIn 中，而是保存在 Receiver 里。
+// 如果装饰器按下标访问 ctx.TargetIn，却从未访问 ctx.Receiver 或 ctx.Kind，
+// 它很可能是按普通函数编写的（例如把 TargetIn[0] 当作接收者），用在方法上时结果可能不符合预期。
+func checkDecorReceiverAware(pkgPath, funName string) (bool, error) {
+	_, decl, _, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil {
+		return false, err
+	}
+	return decorReceiverAware(decl), nil
+}
+
+func decorReceiverAware(decl *ast.FuncDecl) bool {
+	if decl == nil || decl.Body == nil || decl.Type == nil || decl.Type.Params == nil ||
+		len(decl.Type.Params.List) == 0 || len(decl.Type.Params.List[0].Names) == 0 {
+		return true
+	}
+	ctxName := decl.Type.Params.List[0].Names[0].Name
+
+	// 判断 expr 是否为 ctx.field 形式的字段访问
+	isCtxField := func(expr ast.Expr, fields ...string) bool {
+		sel, ok := expr.(*ast.SelectorExpr)
+		if !ok {
+			return false
+		}
+		if id, ok := sel.X.(*ast.Ident); !ok || id.Name != ctxName {
+			return false
+		}
+		for _, field := range fields {
+			if sel.Sel.Name == field {
+				return true
+			}
+		}
+		return false
+	}
+
+	handled, indexed := false, false
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if isCtxField(n, "Receiver", "Kind") {
+				handled = true
+			}
+		case *ast.IndexExpr:
+			if isCtxField(n.X, "TargetIn") {
+				indexed = true
+			}
+		}
+		return true
+	})
+	return handled || !indexed
+}
+
+// 检查装饰器是否需要调用开始的时间 timed 和链式装饰每一层的耗时 chainTimed ，是时生成的代码才记录它们，
+// 见 decorTimed 和 decorChainTimed
+func checkDecorTimed(pkgPath, funName string) (timed, chainTimed bool, err error) {
+	_, decl, file, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil {
+		return false, false, err
+	}
+	decorName := ""
+	if file != nil {
+		decorName, _ = newImporter(file).importedPath(decoratorPackagePath)
+	}
+	return decorTimed(decl, decorName), decorChainTimed(decl, decorName), nil
+}
+
+// 装饰器在函数体中调用了 ctx.Elapsed 或读取了 ctx.Start ，或者把 ctx 传给了其他函数时返回 true 。
+// 接收 ctx 的辅助函数可能调用 ctx.Elapsed ，无法确定时也记录开始的时间，而不是让它返回 0 ；
+// decor 包中的函数（decorName 为它在文件中的名字），如 decor.In 、decor.LoadT ，不会读取它。
+func decorTimed(decl *ast.FuncDecl, decorName string) bool {
+	return decorUsesCtx(decl, decorName, "Elapsed", "Start")
+}
+
+// 装饰器调用了 ctx.ChainTimings 或读取了 ctx.Chain ，或者把 ctx 传给了其他函数时返回 true ，同 decorTimed
+func decorChainTimed(decl *ast.FuncDecl, decorName string) bool {
+	return decorUsesCtx(decl, decorName, "ChainTimings", "Chain")
+}
+
+// 装饰器的函数体中是否使用了 ctx 的字段或方法 selectors ，或者把 ctx 传给了 decor 包以外的函数
+func decorUsesCtx(decl *ast.FuncDecl, decorName string, selectors ...string) bool {
+	if decl == nil || decl.Body == nil || decl.Type == nil || decl.Type.Params == nil ||
+		len(decl.Type.Params.List) == 0 || len(decl.Type.Params.List[0].Names) == 0 {
+		return false
+	}
+	ctx := decl.Type.Params.List[0].Names[0].Obj
+	if ctx == nil {
+		return false
+	}
+	isCtx := func(expr ast.Expr) bool {
+		id, ok := expr.(*ast.Ident)
+		return ok && id.Obj == ctx
+	}
+	// 作为选择器 ctx.X 和作为 decor 包中函数的参数出现的 ctx ，以及出现的所有 ctx
+	known, total := 0, 0
+	used := false
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if isCtx(n.X) {
+				known++
+				for _, name := range selectors {
+					used = used || n.Sel.Name == name
+				}
+			}
+		case *ast.CallExpr:
+			fun := n.Fun
+			switch f := fun.(type) {
+			case *ast.IndexExpr:
+				fun = f.X
+			case *ast.IndexListExpr:
+				fun = f.X
+			}
+			if sel, ok := fun.(*ast.SelectorExpr); ok && decorName != "" {
+				if id, ok := sel.X.(*ast.Ident); ok && id.Name == decorName && id.Obj == nil {
+					for _, arg := range n.Args {
+						if isCtx(arg) {
+							known++
+						}
+					}
+				}
+			}
+		case *ast.Ident:
+			if n.Obj == ctx {
+				total++
+			}
+		}
+		return !used
+	})
+	return used || known != total
+}
+
 var pkgILoader = newPkgLoader()
 
 type pkgLoader struct {
 	pkg   map[string]*pkgSet
 	funcs map[string]*ast.FuncDecl
+	types map[string]*types.Package // 类型检查的结果，见 typesPkg
 }
 
 func newPkgLoader() *pkgLoader {
 	return &pkgLoader{
 		pkg:   map[string]*pkgSet{},
 		funcs: map[string]*ast.FuncDecl{},
+		types: map[string]*types.Package{},
+	}
+}
+
+// 对包做类型检查，只用于查找包中声明的类型和常量。不加载导入的包，类型检查的错误被忽略，
+// 依赖导入的包的声明可能不完整。包内的测试文件（package 相同的 _test.go）也参与检查，
+// 测试构建时它们和包一起编译，其中声明的常量也可以在注解中使用；外部测试包不包括在内。
+func (d *pkgLoader) typesPkg(pkgPath string) (*types.Package, error) {
+	if pkg, ok := d.types[pkgPath]; ok {
+		return pkg, nil
+	}
+	set, err := d.loadPkg(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	// 缓存只记录了含有装饰器和包级变量的文件，类型和常量可能声明在其他文件中
+	if set.partial {
+		if set, err = parsePkgDir(set.dir, set.goMod, false); err != nil {
+			return nil, err
+		}
+		d.pkg[pkgPath] = set
+	}
+	names := []string{}
+	files := map[string]*ast.File{}
+	for name, pkg := range set.pkgs {
+		if strings.HasSuffix(name, "_test") {
+			continue
+		}
+		for file, f := range pkg.Files {
+			names = append(names, file)
+			files[file] = f
+		}
+	}
+	sort.Strings(names)
+	list := make([]*ast.File, 0, len(names))
+	for _, name := range names {
+		list = append(list, files[name])
 	}
+	conf := types.Config{Error: func(error) {}}
+	pkg, _ := conf.Check(pkgPath, set.fset, list, nil)
+	d.types[pkgPath] = pkg
+	return pkg, nil
+}
+
+// 丢弃当前包（包路径为空）的解析和类型检查结果，projectDir 变化后重新加载
+func (d *pkgLoader) resetCurrentPkg() {
+	delete(d.pkg, "")
+	delete(d.types, "")
 }
 
 func (d *pkgLoader) findFunc(pkgPath, funName string) (fileSet *token.FileSet, target *ast.FuncDecl, file *ast.File, err error) {
@@ -783,12 +2152,37 @@ func (d *pkgLoader) findTarget(pkgPath string, funName string) (fileSet *token.F
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	fileSet, target, afile, err = d.findTargetIn(set, pkgPath, funName)
+	// 只解析了部分文件时找不到，完整解析后再找一次，保证错误信息和没有缓存时一致
+	if err != nil && set.partial {
+		if set, err = parsePkgDir(set.dir, set.goMod, false); err != nil {
+			return nil, nil, nil, err
+		}
+		d.pkg[pkgPath] = set
+		return d.findTargetIn(set, pkgPath, funName)
+	}
+	return
+}
 
-	// 扩展名处理？？？
-	err = errors.New("decorator not found: " + pkgPath + "#" + funName)
-	if ext := filepath.Ext(funName); ext != "" {
-		funName = ext[1:]
+func (d *pkgLoader) findTargetIn(set *pkgSet, pkgPath string, funName string) (fileSet *token.FileSet, target *ast.FuncDecl, afile *ast.File, err error) {
+
+	// pkg.logging 只取 logging ；registry.Logging 是绑定在包级变量 registry 上的方法，
+	// 在其他包中写作 pkg.registry.Logging
+	names := strings.Split(funName, ".")
+	if pkgPath != "" && len(names) > 1 {
+		names = names[1:]
 	}
+	switch len(names) {
+	case 1:
+		return d.findTargetInSet(set, pkgPath, names[0], map[string]bool{})
+	case 2:
+		return d.findBoundTarget(set, pkgPath, names[0], names[1])
+	}
+	return nil, nil, nil, errors.New("decorator not found: " + pkgPath + "#" + funName)
+}
+
+func (d *pkgLoader) findTargetInSet(set *pkgSet, pkgPath, funName string, seen map[string]bool) (fileSet *token.FileSet, target *ast.FuncDecl, afile *ast.File, err error) {
+	err = errors.New("decorator not found: " + pkgPath + "#" + funName)
 
 	//log.Printf("pkgPath: %s, funName: %s, set: %+v \n", pkgPath, funName, set)
 	// 遍历所有包
@@ -812,9 +2206,172 @@ func (d *pkgLoader) findTarget(pkgPath string, funName string) (fileSet *token.F
 			})
 		}
 	}
+	if target == nil {
+		return d.findFuncAlias(set, pkgPath, funName, seen)
+	}
+	return
+}
+
+// 装饰器也可以是一个函数类型的包级变量，例如：
+//
+//	var Logging = logging
+//	var Tracing = func(ctx *decor.Context) { ... }
+//
+// 前者沿着别名找到 logging 的函数声明；后者用函数字面量构造一个函数声明，变量的注释作为它的文档注释，
+// 供后续的签名检查和 lint 使用。变量的值不是函数时报错。
+func (d *pkgLoader) findFuncAlias(set *pkgSet, pkgPath, funName string, seen map[string]bool) (fileSet *token.FileSet, target *ast.FuncDecl, afile *ast.File, err error) {
+	err = errors.New("decorator not found: " + pkgPath + "#" + funName)
+	if pkgPath != "" {
+		// 包能解析但其中没有这个函数，给出包所在的目录，便于确认 replace 之后实际使用的源码
+		err = errors.New("decorator not found: " + pkgPath + "#" + funName + ", package " + pkgPath +
+			" (" + set.dir + ") has no function " + funName)
+	}
+	if seen[funName] {
+		return nil, nil, nil, errors.New("decorator alias cycle: " + pkgPath + "#" + funName)
+	}
+	seen[funName] = true
+	for _, v := range set.pkgs {
+		if v == nil || v.Files == nil {
+			continue
+		}
+		for _, file := range v.Files {
+			for _, decl := range file.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					vs, ok := spec.(*ast.ValueSpec)
+					if !ok {
+						continue
+					}
+					for i, name := range vs.Names {
+						if name.Name != funName {
+							continue
+						}
+						if i >= len(vs.Values) {
+							return nil, nil, nil, errors.New("decorator " + funName + " is not a function value")
+						}
+						switch value := vs.Values[i].(type) {
+						case *ast.Ident: // var Logging = logging
+							return d.findTargetInSet(set, pkgPath, value.Name, seen)
+						case *ast.FuncLit: // var Logging = func(ctx *decor.Context) {}
+							doc := vs.Doc
+							if doc == nil {
+								doc = gd.Doc
+							}
+							return set.fset, &ast.FuncDecl{
+								Doc:  doc,
+								Name: name,
+								Type: value.Type,
+								Body: value.Body,
+							}, file, nil
+						default:
+							return nil, nil, nil, errors.New("decorator " + funName + " is not a function value")
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// 装饰器也可以是包级变量的方法（绑定装饰器），例如：
+//
+//	var registry = &Registry{}
+//
+//	func (r *Registry) Logging(ctx *decor.Context) { ... }
+//
+// 使用 //go:decor registry.Logging 时，根据变量声明的类型或复合字面量的类型找到 Registry 的 Logging 方法。
+// 变量的类型无法从声明中得出时（例如 var registry = NewRegistry()），需要显式声明变量的类型。
+func (d *pkgLoader) findBoundTarget(set *pkgSet, pkgPath, varName, method string) (fileSet *token.FileSet, target *ast.FuncDecl, afile *ast.File, err error) {
+	typeName, found := "", false
+	for _, v := range set.pkgs {
+		if v == nil || v.Files == nil {
+			continue
+		}
+		for _, file := range v.Files {
+			for _, decl := range file.Decls {
+				gd, ok := decl.(*ast.GenDecl)
+				if !ok || gd.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gd.Specs {
+					vs, ok := spec.(*ast.ValueSpec)
+					if !ok {
+						continue
+					}
+					for i, name := range vs.Names {
+						if name.Name != varName {
+							continue
+						}
+						found = true
+						if vs.Type != nil {
+							typeName = boundVarTypeName(vs.Type)
+						} else if i < len(vs.Values) {
+							typeName = boundVarTypeName(vs.Values[i])
+						}
+					}
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, nil, nil, errors.New("decorator not found: " + pkgPath + "#" + varName + "." + method +
+			", " + varName + " is neither an imported package nor a package-level variable")
+	}
+	if typeName == "" {
+		return nil, nil, nil, errors.New("cannot infer the type of decorator variable " + varName + ", declare it with an explicit type")
+	}
+
+	err = errors.New("decorator not found: " + pkgPath + "#" + varName + "." + method + ", " + typeName + " has no method " + method)
+	for _, v := range set.pkgs {
+		if v == nil || v.Files == nil {
+			continue
+		}
+		for _, file := range v.Files {
+			visitAstDecl(file, func(decl *ast.FuncDecl) bool {
+				if decl.Recv == nil || len(decl.Recv.List) != 1 || decl.Name.Name != method {
+					return false
+				}
+				typ := decl.Recv.List[0].Type
+				if star, ok := typ.(*ast.StarExpr); ok {
+					typ = star.X
+				}
+				if name, _, _ := strings.Cut(recvTypeName(typ), "["); name != typeName {
+					return false
+				}
+				afile, target, fileSet, err = file, decl, set.fset, nil
+				return true
+			})
+			if target != nil {
+				return
+			}
+		}
+	}
 	return
 }
 
+// 绑定装饰器的变量的类型名称，支持 T 、*T 、T{} 、&T{} 。无法得出时返回空字符串。
+func boundVarTypeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.StarExpr:
+		return boundVarTypeName(expr.X)
+	case *ast.UnaryExpr:
+		if expr.Op == token.AND {
+			return boundVarTypeName(expr.X)
+		}
+	case *ast.CompositeLit:
+		if expr.Type != nil {
+			return boundVarTypeName(expr.Type)
+		}
+	}
+	return ""
+}
+
 func (d *pkgLoader) loadPkg(pkgPath string) (set *pkgSet, err error) {
 	// 读取缓存
 	if _set, ok := d.pkg[pkgPath]; ok {
@@ -825,11 +2382,13 @@ func (d *pkgLoader) loadPkg(pkgPath string) (set *pkgSet, err error) {
 	// 加载新包
 	pi, err := getPackageInfo(pkgPath) // 获取包的基本信息
 	if err != nil {
-		return nil, err
+		return nil, errors.New(msgPkgNotResolvable + pkgPath + ", " + err.Error())
+	}
+	if pi.Dir == "" {
+		return nil, errors.New(msgPkgNotResolvable + pkgPath + ", it has no source directory")
 	}
-	set = &pkgSet{}
-	set.fset = token.NewFileSet()                                                // 创建一个新的空的文件集合 token.FileSet ，用于管理源代码文件中的位置信息（例如，行号、列号等）。
-	set.pkgs, err = parser.ParseDir(set.fset, pi.Dir, nil, parser.ParseComments) // 解析包的源代码目录，pi.Dir 是包的源代码路径，parser.ParseComments 表示解析时需要考虑注释。
+	// 解析包的源代码目录，pi.Dir 是包的源代码路径。缓存有效时只解析含有装饰器和包级变量的文件。
+	set, err = parsePkgDir(pi.Dir, pi.Module.GoMod, true)
 	if err != nil {
 		return nil, err
 	}
@@ -7,6 +7,7 @@ import (
 	"go/parser"
 	"go/token"
 	"log"
+	"strings"
 	"testing"
 )
 
@@ -55,7 +56,7 @@ func TestCheckDecorAndGetParam(t *testing.T) {
 
 	targetPkg := "github.com/dengsgo/go-decorator/cmd/decorator"
 	for index, c := range cas {
-		param, err := checkDecorAndGetParam(targetPkg, "logging", c.in)
+		param, err := checkDecorAndGetParam(nil, targetPkg, "logging", c.in)
 		if err != nil {
 			t.Fatal("checkDecorAndGetParam should err == nil but got error", err)
 		}
@@ -66,11 +67,333 @@ func TestCheckDecorAndGetParam(t *testing.T) {
 		}
 	}
 
-	_, err := checkDecorAndGetParam("github.com/dengsgo/go-decorator/decor", "find", nil)
+	_, err := checkDecorAndGetParam(nil, "github.com/dengsgo/go-decorator/decor", "find", nil)
 	if err == nil {
 		t.Fatal("checkDecorAndGetParam should return err but got nil")
 	}
 
+	// decorator referenced by a function-valued variable alias
+	for _, alias := range []string{"loggingAlias", "loggingAliasAlias"} {
+		param, err := checkDecorAndGetParam(nil, targetPkg, alias, map[string]string{"a": "1"})
+		if err != nil {
+			t.Fatal("checkDecorAndGetParam alias should err == nil but got error", alias, err)
+		}
+		if len(param) != 3 || param[1] != "1" {
+			t.Fatal("checkDecorAndGetParam alias param not match, got", alias, param)
+		}
+	}
+	if _, err := checkDecorAndGetParam(nil, targetPkg, "notDecoratorVar", nil); err == nil ||
+		err.Error() != "decorator notDecoratorVar is not a function value" {
+		t.Fatal("checkDecorAndGetParam should return not a function value err but got", err)
+	}
+
+	// parameters of nillable types are nil when omitted, they can't be passed from annotations
+	if param, err := checkDecorAndGetParam(nil, targetPkg, "hooked", map[string]string{"name": `"x"`}); err != nil ||
+		strings.Join(param, ",") != `"x",nil,nil,nil,nil,nil,nil` {
+		t.Fatal("checkDecorAndGetParam hooked param not match, got", param, err)
+	}
+	if _, err := checkDecorAndGetParam(nil, targetPkg, "hooked", map[string]string{"onDone": "nil"}); err == nil {
+		t.Fatal("checkDecorAndGetParam hooked should return err for the func parameter but got nil")
+	}
+
+	// keys without a matching parameter
+	unknownCas := []struct {
+		name string
+		in   map[string]string
+		msg  string
+	}{
+		{"logging", map[string]string{"ss": `"value"`}, "decorator logging has no parameter 'ss', did you mean 's'?"},
+		{"logging", map[string]string{"B": "true"}, "decorator logging has no parameter 'B', did you mean 'b'?"},
+		{"logging", map[string]string{"ctx": "1"}, "decorator logging has no parameter 'ctx', the parameters are: a, b, s"},
+		{"logging", map[string]string{"s": `"x"`, "zzz": "1"}, "decorator logging has no parameter 'zzz', the parameters are: a, b, s"},
+		{"pureDecor", map[string]string{"s": `"x"`}, "decorator pureDecor has no parameter 's', it takes no parameters"},
+		{"tagging", map[string]string{"name": `["a"]`}, "decorator tagging has no parameter 'name', did you mean 'names'?"},
+	}
+	for i, c := range unknownCas {
+		if _, err := checkDecorAndGetParam(nil, targetPkg, c.name, c.in); err == nil || err.Error() != c.msg {
+			t.Fatalf("unknownCas[%d] checkDecorAndGetParam should return err %q but got %v", i, c.msg, err)
+		}
+	}
+
+	// constants declared in the current package
+	localConstCas := []struct {
+		in  map[string]string
+		r   []string
+		msg string
+	}{
+		{map[string]string{"a": "levelUntyped"}, []string{`""`, "1", "false"}, ""},
+		{map[string]string{"a": "attemptsInt", "s": "greetingConst"}, []string{`"hello"`, "3", "false"}, ""},
+		{map[string]string{"a": "ratioConst"}, nil, "key 'a' value 'ratioConst' of type untyped float doesn't match type int"},
+		{map[string]string{"a": "greetingConst"}, nil, "key 'a' value 'greetingConst' of type untyped string doesn't match type int"},
+		{map[string]string{"a": "attemptsInt64"}, nil, "key 'a' value 'attemptsInt64' of type int64 doesn't match type int"},
+		{map[string]string{"a": "levelInfo"}, nil, "key 'a' value 'levelInfo' of type logLevel doesn't match type int"},
+		{map[string]string{"a": "notDecoratorVar"}, nil, "key 'a' value 'notDecoratorVar' is not a constant declared in this package"},
+		{map[string]string{"a": "undefinedConst"}, nil, "key 'a' value 'undefinedConst' is not a constant declared in this package"},
+	}
+	for i, c := range localConstCas {
+		param, err := checkDecorAndGetParam(nil, targetPkg, "logging", c.in)
+		if c.msg != "" {
+			if err == nil || err.Error() != c.msg {
+				t.Fatalf("localConstCas[%d] checkDecorAndGetParam should return err %q but got %v", i, c.msg, err)
+			}
+			continue
+		}
+		if err != nil || strings.Join(param, ",") != strings.Join(c.r, ",") {
+			t.Fatalf("localConstCas[%d] checkDecorAndGetParam want %v, but got %v %v", i, c.r, param, err)
+		}
+	}
+	if param, err := checkDecorAndGetParam(nil, targetPkg, "forwarding", map[string]string{"name": "greetingConst", "n": "ratioConst"}); err != nil ||
+		strings.Join(param, ",") != `"hello",map[string]string{"n": "0.5"}` {
+		t.Fatal("checkDecorAndGetParam should resolve constants passed to rest, but got", param, err)
+	}
+	if _, err := checkDecorAndGetParam(nil, targetPkg, "leveled", map[string]string{"level": "levelInfo"}); err == nil ||
+		err.Error() != "key 'level' value 'levelInfo' doesn't match type logLevel" {
+		t.Fatal("checkDecorAndGetParam should reject unqualified constants for named types, but got", err)
+	}
+
+	// decorator bound to a package-level variable
+	for _, name := range []string{"decorator.boundRegistry.logging", "decorator.boundRegistryTyped.logging"} {
+		param, err := checkDecorAndGetParam(nil, targetPkg, name, map[string]string{"level": `"debug"`})
+		if err != nil {
+			t.Fatal("checkDecorAndGetParam bound should err == nil but got error", name, err)
+		}
+		if len(param) != 1 || param[0] != `"debug"` {
+			t.Fatal("checkDecorAndGetParam bound param not match, got", name, param)
+		}
+	}
+	boundErrCas := []struct {
+		name, msg string
+	}{
+		{"decorator.boundRegistry.logging", "lint"},
+		{"decorator.boundRegistryUntyped.logging", "cannot infer the type of decorator variable boundRegistryUntyped"},
+		{"decorator.boundRegistry.missing", "boundDecorators has no method missing"},
+		{"decorator.noSuchRegistry.logging", "noSuchRegistry is neither an imported package nor a package-level variable"},
+	}
+	for i, c := range boundErrCas {
+		if _, err := checkDecorAndGetParam(nil, targetPkg, c.name, map[string]string{"level": `""`}); err == nil ||
+			!strings.Contains(err.Error(), c.msg) {
+			t.Fatalf("boundErrCas[%d] checkDecorAndGetParam(%s) should return err contains %q but got %v", i, c.name, c.msg, err)
+		}
+	}
+
+	// unmatched keys are collected into the trailing map[string]string param
+	restCas := []struct {
+		in map[string]string
+		r  []string
+	}{
+		{
+			map[string]string{"name": `"x"`},
+			[]string{`"x"`, `map[string]string{}`},
+		},
+		{
+			map[string]string{"name": `"x"`, "timeout": "10", "env": `"prod"`, "retry": "true"},
+			[]string{`"x"`, `map[string]string{"env": "prod", "retry": "true", "timeout": "10"}`},
+		},
+	}
+	for index, c := range restCas {
+		param, err := checkDecorAndGetParam(nil, targetPkg, "forwarding", c.in)
+		if err != nil {
+			t.Fatal("checkDecorAndGetParam rest should err == nil but got error", err)
+		}
+		if len(param) != len(c.r) {
+			t.Fatal("checkDecorAndGetParam rest param len not match, got", param, "case index:", index)
+		}
+		for i, v := range c.r {
+			if param[i] != v {
+				t.Fatalf("checkDecorAndGetParam rest should param == r but got: %s != %s, case index: %+v, i: %+v", param[i], v, index, i)
+			}
+		}
+	}
+	if _, err := checkDecorAndGetParam(nil, targetPkg, "forwarding", map[string]string{"env": `"prod"`}); err == nil {
+		t.Fatal("checkDecorAndGetParam rest should still lint named params but got nil")
+	}
+
+	// unmatched keys are passed as decor.Option to the trailing variadic options param
+	optionsCas := []struct {
+		name string
+		in   map[string]string
+		r    []string
+		msg  string
+	}{
+		{"optioned", map[string]string{"times": "3"}, []string{"3"}, ""},
+		{
+			"optioned",
+			map[string]string{"times": "3", "backoff": `"exp"`, "codes": "{500, 503}", "jitter": "0.5", "tags": `{"a"}`, "none": "{}", "n": "ratioConst"},
+			[]string{"3", `decor.Option{Key: "backoff", Value: "exp"}`, `decor.Option{Key: "codes", Value: []int{500, 503}}`,
+				`decor.Option{Key: "jitter", Value: 0.5}`, `decor.Option{Key: "n", Value: 0.5}`, `decor.Option{Key: "none", Value: []any{}}`,
+				`decor.Option{Key: "tags", Value: []string{"a"}}`},
+			"",
+		},
+		{"optionedOnly", map[string]string{}, []string{}, ""},
+		{"optionedOnly", map[string]string{"opts": "true"}, []string{`decor.Option{Key: "opts", Value: true}`}, ""},
+		{"optioned", map[string]string{"times": "0"}, nil, "lint: key 'times' value '0' can't pass lint gte:1"},
+		{"optioned", map[string]string{"times": "1", "mixed": `{1, "a"}`}, nil, `option 'mixed' value '{1, "a"}' must be a list of strings or integers`},
+		{"optioned", map[string]string{"times": "1", "x": "missingConst"}, nil, "key 'x' value 'missingConst' is not a constant declared in this package"},
+		{"optionedRest", map[string]string{}, nil, "decorator optionedRest can't take both a map[string]string rest parameter and options"},
+	}
+	for i, c := range optionsCas {
+		param, err := checkDecorAndGetParam(nil, targetPkg, c.name, c.in)
+		if c.msg != "" {
+			if err == nil || err.Error() != c.msg {
+				t.Fatalf("optionsCas[%d] checkDecorAndGetParam should return err %q but got %v", i, c.msg, err)
+			}
+			continue
+		}
+		if err != nil || strings.Join(param, ",") != strings.Join(c.r, ",") {
+			t.Fatalf("optionsCas[%d] checkDecorAndGetParam want %v, but got %v %v", i, c.r, param, err)
+		}
+	}
+
+	// list values are passed to slice params
+	listCas := []struct {
+		in map[string]string
+		r  []string
+	}{
+		{
+			map[string]string{"names": `{"a", "c"}`},
+			[]string{`[]string{"a", "c"}`, "nil"},
+		},
+		{
+			map[string]string{"names": `{"b"}`, "ports": "{80, 443}"},
+			[]string{`[]string{"b"}`, "[]int{80, 443}"},
+		},
+		{
+			map[string]string{"names": `{"a"}`, "ports": "{}"},
+			[]string{`[]string{"a"}`, "[]int{}"},
+		},
+	}
+	for index, c := range listCas {
+		param, err := checkDecorAndGetParam(nil, targetPkg, "tagging", c.in)
+		if err != nil {
+			t.Fatal("checkDecorAndGetParam list should err == nil but got error", err)
+		}
+		for i, v := range c.r {
+			if param[i] != v {
+				t.Fatalf("checkDecorAndGetParam list should param == r but got: %s != %s, case index: %+v, i: %+v", param[i], v, index, i)
+			}
+		}
+	}
+	for i, in := range []map[string]string{
+		{"names": "{}"},                       // nonzero
+		{"names": `{"d"}`},                    // enum
+		{"names": `{"a"}`, "ports": "{0}"},    // range
+		{"names": `"a"`},                      // not a list
+		{"names": "{1}"},                      // element type
+		{"names": `{"a"}`, "ports": `{"80"}`}, // element type
+	} {
+		if _, err := checkDecorAndGetParam(nil, targetPkg, "tagging", in); err == nil {
+			t.Fatal("checkDecorAndGetParam list should return err but got nil, index:", i)
+		}
+	}
+	if _, err := checkDecorAndGetParam(nil, targetPkg, "logging", map[string]string{"s": `{"a"}`}); err == nil {
+		t.Fatal("checkDecorAndGetParam list value for string param should return err but got nil")
+	}
+
+	// constants of a named type declared in the decorator's package, the target file imports it as decorator
+	targetFile, err := parser.ParseFile(token.NewFileSet(), "", "package a\nimport \""+targetPkg+"\"\nimport y \"fmt\"\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetImp := newImporter(targetFile)
+	constCas := []struct {
+		in map[string]string
+		r  []string
+	}{
+		{map[string]string{"level": "decorator.levelInfo"}, []string{"decorator.levelInfo", `""`}},
+		{map[string]string{"level": "decorator.levelDebug", "s": `"a"`}, []string{"decorator.levelDebug", `"a"`}},
+		{map[string]string{}, []string{"0", `""`}},
+	}
+	for index, c := range constCas {
+		param, err := checkDecorAndGetParam(targetImp, targetPkg, "leveled", c.in)
+		if err != nil {
+			t.Fatal("checkDecorAndGetParam const should err == nil but got error", err)
+		}
+		if strings.Join(param, ",") != strings.Join(c.r, ",") {
+			t.Fatalf("checkDecorAndGetParam const should param == r but got: %q != %q, case index: %d", param, c.r, index)
+		}
+	}
+	for i, in := range []map[string]string{
+		{"level": "decorator.levelUntyped"}, // not of type logLevel
+		{"level": "decorator.levelNone"},    // not declared
+		{"level": "1"},                      // not a constant
+		{"s": "decorator.levelInfo"},        // qualified identifier for a string param
+		{"level": "x.levelDebug"},           // x is not imported by the target file
+		{"level": "y.levelDebug"},           // y is not the package of logLevel
+		{"level": "main.levelDebug"},        // the target file imports it as decorator
+	} {
+		if _, err := checkDecorAndGetParam(targetImp, targetPkg, "leveled", in); err == nil {
+			t.Fatal("checkDecorAndGetParam const should return err but got nil, index:", i)
+		}
+	}
+	if _, err := checkDecorAndGetParam(nil, targetPkg, "leveled", map[string]string{"level": "decorator.levelInfo"}); err == nil {
+		t.Fatal("checkDecorAndGetParam const without the imports of the target file should return err but got nil")
+	}
+	// the decorator and the type are declared in the package of the target, the qualifier is dropped
+	if param, err := checkDecorAndGetParam(nil, "", "leveled", map[string]string{"level": "main.levelInfo"}); err != nil || strings.Join(param, ",") != `levelInfo,""` {
+		t.Fatal("checkDecorAndGetParam const of the current package should drop the qualifier, but got", param, err)
+	}
+
+	// pattern, prefix and suffix of string params
+	formatCas := []struct {
+		in  map[string]string
+		msg string
+	}{
+		{map[string]string{"route": `"/api/users"`, "metric": `"app_requests_total"`, "tags": `{"env:prod"}`, "kind": "decorator.routeAPI"}, ""},
+		{map[string]string{"metric": `"svc_latency_seconds"`}, ""},
+		{map[string]string{"route": `"api"`}, `lint: key 'route' value '"api"' can't pass lint pattern:["^/[a-z/]*$"]`},
+		{map[string]string{"metric": `"requests_total"`}, `lint: key 'metric' value '"requests_total"' can't pass lint prefix:["app_" "svc_"]`},
+		{map[string]string{"metric": `"app_requests"`}, `lint: key 'metric' value '"app_requests"' can't pass lint suffix:["_total" "_seconds"]`},
+		{map[string]string{"tags": `{"env:prod", "zone"}`}, `lint: key 'tags' value '"zone"' can't pass lint prefix:["env:"]`},
+		{map[string]string{"kind": "decorator.routeAdmin"}, ""},
+		{map[string]string{"kind": "decorator.routeWeb"}, `lint: key 'kind' value '"web"' can't pass lint prefix:["a"]`},
+	}
+	for i, c := range formatCas {
+		_, err := checkDecorAndGetParam(targetImp, targetPkg, "routed", c.in)
+		if (err == nil) != (c.msg == "") || (err != nil && err.Error() != c.msg) {
+			t.Fatalf("formatCas[%d] checkDecorAndGetParam(routed) should return err %q but got %v", i, c.msg, err)
+		}
+	}
+
+	// exclusive and requires
+	relationCas := []struct {
+		in  map[string]string
+		msg string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"url": `"https://a"`, "timeout": "10", "retries": "3"}, ""},
+		{map[string]string{"file": `"a"`, "timeout": "10"}, ""},
+		{map[string]string{"file": `"a"`, "data": `"b"`}, "lint: keys 'file' and 'data' are mutually exclusive, pass only one of file, url, data"},
+		{map[string]string{"url": `"https://a"`, "retries": "3"}, "lint: key 'retries' requires key 'timeout', must pass them together"},
+	}
+	for i, c := range relationCas {
+		_, err := checkDecorAndGetParam(nil, targetPkg, "sourced", c.in)
+		if (err == nil) != (c.msg == "") || (err != nil && !strings.HasPrefix(err.Error(), c.msg+"\n\tLint: ")) {
+			t.Fatalf("relationCas[%d] checkDecorAndGetParam(sourced) should return err %q but got %v", i, c.msg, err)
+		}
+	}
+
+	// 装饰器声明的默认值
+	defaultCas := []struct {
+		decor string
+		in    map[string]string
+		out   []string
+		msg   string
+	}{
+		{"defaulted", map[string]string{}, []string{`"info"`, "2", "true", "1"}, ""},
+		{"defaulted", map[string]string{"level": `"debug"`, "ratio": "0.5"}, []string{`"debug"`, "2", "true", "0.5"}, ""},
+		{"defaulted", map[string]string{"level": `"warn"`}, nil, `lint: key 'level' value '"warn"' can't pass lint enum`},
+		{"badDefaulted", map[string]string{"level": `"debug"`}, nil, `default: lint: key 'level' value '"trace"' can't pass lint enum`},
+	}
+	for i, c := range defaultCas {
+		out, err := checkDecorAndGetParam(nil, targetPkg, c.decor, c.in)
+		if (err == nil) != (c.msg == "") || (err != nil && !strings.HasPrefix(err.Error(), c.msg)) {
+			t.Fatalf("defaultCas[%d] checkDecorAndGetParam(%s) should return err %q but got %v", i, c.decor, c.msg, err)
+		}
+		if err == nil && strings.Join(out, ",") != strings.Join(c.out, ",") {
+			t.Fatalf("defaultCas[%d] checkDecorAndGetParam(%s) want %q, but got %q", i, c.decor, c.out, out)
+		}
+	}
+
 	// TODO
 	//failed := []map[string]string{
 	//	{"s": `value`, "a": "0", "b": "true"},
@@ -79,13 +402,50 @@ func TestCheckDecorAndGetParam(t *testing.T) {
 	//	{"s": `"value"`, "a": "0", "b": "true1"},
 	//}
 	//for i, v := range failed {
-	//	_, err := checkDecorAndGetParam(targetPkg, "logging", v)
+	//	_, err := checkDecorAndGetParam(nil, targetPkg, "logging", v)
 	//	if err == nil {
 	//		t.Fatal("checkDecorAndGetParam should return err but got nil, index: ", i)
 	//	}
 	//}
 }
 
+func TestParseDecorDefaults(t *testing.T) {
+	cas := []struct {
+		doc  string
+		want map[string]string
+		msg  string
+	}{
+		{"", map[string]string{}, ""},
+		{`//go:decor-default {s: "a", n: 1, f: 2, b: true, l: ["x", "y"]}`, map[string]string{"s": `"a"`, "n": "1", "f": "2", "b": "true", "l": `{"x", "y"}`}, ""},
+		{`//go:decor-default {s: "a"}` + "\n" + `//go:decor-default {n: 1}`, map[string]string{"s": `"a"`, "n": "1"}, ""},
+		{`//go:decor-default {s: "a"}` + "\n" + `//go:decor-default {s: "b"}`, nil, "default: duplicate key 's'"},
+		{`//go:decor-default {x: 1}`, nil, "default: decorator dec has no parameter 'x'"},
+		{`//go:decor-default {ctx: 1}`, nil, "default: decorator dec has no parameter 'ctx'"},
+		{`//go:decor-default {n: "1"}`, nil, `default: key 'n' value '"1"' doesn't match type int`},
+		{`//go:decor-default {n: 1.5}`, nil, "default: key 'n' value '1.5' doesn't match type int"},
+		{`//go:decor-default {b: 1}`, nil, "default: key 'b' value '1' doesn't match type bool"},
+		{`//go:decor-default {s: someConst}`, nil, "default: key 's' value 'someConst' is not a literal"},
+		{`//go:decor-default {l: [1]}`, nil, "default: key 'l' value '{1}' doesn't match type []string"},
+		{`//go:decor-default {lv: 1}`, nil, "default: key 'lv' of type Level can't have a default value"},
+		{`//go:decor-default s: "a"`, nil, "syntax error using go:decor-default"},
+	}
+	for i, c := range cas {
+		src := "package main\n" + c.doc + "\nfunc dec(ctx *decor.Context, s string, n int, f float64, b bool, l []string, lv Level) {}\n"
+		f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd := f.Decls[0].(*ast.FuncDecl)
+		got, lerr := parseDecorDefaults("dec", fd.Doc, collDeclFuncParamsAnfTypes(fd))
+		if (lerr == nil) != (c.msg == "") || (lerr != nil && lerr.Error() != c.msg) {
+			t.Fatalf("cas[%d] parseDecorDefaults() should return err %q but got %v", i, c.msg, lerr)
+		}
+		if lerr == nil && fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Fatalf("cas[%d] parseDecorDefaults() want %v, but got %v", i, c.want, got)
+		}
+	}
+}
+
 func TestCleanSpaceChar(t *testing.T) {
 	cas := []struct {
 		s,
@@ -159,6 +519,10 @@ func TestParseDecorAndParameters(t *testing.T) {
 		{`function#{   b:true, key:"", f:0.110, age:100   }   `, "function", map[string]string{"b": "true", "key": `""`, "age": "100", "f": "0.110"}},
 		{`function #{   b:true, key:"", f:0.110, age:100   }   `, "function", map[string]string{"b": "true", "key": `""`, "age": "100", "f": "0.110"}},
 		{`function # {   b:true, key:"", f:0.110, age:100   }   `, "function", map[string]string{"b": "true", "key": `""`, "age": "100", "f": "0.110"}},
+		{`function#{names: ["a", "b"]}`, "function", map[string]string{"names": `{"a", "b"}`}},
+		{`function#{names: ["[a]", "b]"], ports: [80, -1], empty: []}`, "function", map[string]string{"names": `{"[a]", "b]"}`, "ports": "{80, -1}", "empty": "{}"}},
+		{`log.Logging#{level: log.Debug, s: ""}`, "log.Logging", map[string]string{"level": "log.Debug", "s": `""`}},
+		{"retry#{attempts: maxRetries, b: true}", "retry", map[string]string{"attempts": "maxRetries", "b": "true"}},
 	}
 	for _, v := range cas {
 		name, p, err := parseDecorAndParameters(v.s)
@@ -224,8 +588,7 @@ func TestParseDecorAndParameters(t *testing.T) {
 		{"function#{key:vv v}", errUsedDecorSyntaxErrorInvalidP},
 		{"function#{key:vv v, ,}", errUsedDecorSyntaxErrorInvalidP},
 		{"function#{key:vv v, ssd,}", errUsedDecorSyntaxErrorInvalidP},
-		{"function#{key:true1,s:false,}", errors.New("invalid parameter value, should be bool")},
-		{"function#{key:vv,key:vv,}", errors.New("invalid parameter value, should be bool")},
+		{"function#{key:vv,key:vv,}", errors.New("duplicate parameters key 'key'")},
 		{`function#{name:"vv",name:"vvccc"}`, errors.New("duplicate parameters key 'name'")},
 		{"function#{key:vv,keys:vv,,,}", errUsedDecorSyntaxErrorInvalidP},
 		{"function#{,,,key:vv,keys:vv,,,}", errUsedDecorSyntaxErrorInvalidP},
@@ -243,6 +606,11 @@ func TestParseDecorAndParameters(t *testing.T) {
 		{`function#{key:if}`, errUsedDecorSyntaxErrorInvalidP},
 		{`function#{for:if}`, errUsedDecorSyntaxErrorInvalidP},
 		{`function#{for:true}`, errUsedDecorSyntaxErrorInvalidP},
+		{`function#{names: ["a", 1]}`, errors.New("invalid parameter value, key 'names': list elements should be of the same type")},
+		{`function#{names: [true]}`, errors.New("invalid parameter value, key 'names': list elements should be string or int")},
+		{`function#{names: [1.5]}`, errors.New("invalid parameter value, key 'names': list elements should be string or int")},
+		{`function#{names: ["a"}`, errUsedDecorSyntaxErrorInvalidP},
+		{`function#{level: log.Debug.X}`, errors.New("invalid parameter value, key 'level'")},
 		{".DO#{}", errUsedDecorSyntaxError},
 		{"a.b.c.#{}", errUsedDecorSyntaxError},
 		{"a,b.c.#{}", errUsedDecorSyntaxError},
@@ -262,12 +630,12 @@ func TestParseDecorAndParameters(t *testing.T) {
 
 func TestResolveLinterFromAnnotation(t *testing.T) {
 	args := decorArgsMap{
-		"name":     &decorArg{1, "name", "string", nil, false},
-		"intVal":   &decorArg{2, "intVal", "int", nil, false},
-		"floatVal": &decorArg{3, "floatVal", "float64", nil, false},
-		"boolVal":  &decorArg{4, "boolVal", "bool", nil, false},
-		"rangeVal": &decorArg{4, "rangeVal", "int64", nil, false},
-		"emptyVal": &decorArg{5, "emptyVal", "string", nil, false},
+		"name":     &decorArg{1, "name", "string", nil, false, nil, nil},
+		"intVal":   &decorArg{2, "intVal", "int", nil, false, nil, nil},
+		"floatVal": &decorArg{3, "floatVal", "float64", nil, false, nil, nil},
+		"boolVal":  &decorArg{4, "boolVal", "bool", nil, false, nil, nil},
+		"rangeVal": &decorArg{4, "rangeVal", "int64", nil, false, nil, nil},
+		"emptyVal": &decorArg{5, "emptyVal", "string", nil, false, nil, nil},
 	}
 	cas := []string{
 		`required: {intVal}`,
@@ -321,9 +689,466 @@ func TestResolveLinterFromAnnotation(t *testing.T) {
 	}
 }
 
+func TestResolveStringLinter(t *testing.T) {
+	newArgs := func() decorArgsMap {
+		return decorArgsMap{
+			"name":  &decorArg{1, "name", "string", nil, false, nil, nil},
+			"names": &decorArg{2, "names", "[]string", nil, false, nil, nil},
+			"count": &decorArg{3, "count", "int", nil, false, nil, nil},
+		}
+	}
+	args := newArgs()
+	for _, v := range []string{
+		`pattern: {name: "^[a-z]+$"}`,
+		`prefix: {name: {"a", "b"}, names: "x"}`,
+		`suffix: {name: "z"}`,
+	} {
+		if err := resolveLinterFromAnnotation(v, args); err != nil {
+			t.Fatalf("resolveLinterFromAnnotation(%s) should pass, err: %v", v, err)
+		}
+	}
+	cas := []struct {
+		arg, value string
+		pass       bool
+	}{
+		{"name", `"abz"`, true},
+		{"name", `"bz"`, true},
+		{"name", `"cz"`, false},
+		{"name", `"ab"`, false},
+		{"name", `"aZ"`, false},
+		{"names", `{"x1", "x2"}`, true},
+		{"names", `{"x1", "y"}`, false},
+		{"count", `1`, true},
+	}
+	for i, c := range cas {
+		if err := args[c.arg].passStringLint(c.value); (err == nil) != c.pass {
+			t.Fatalf("cas[%d] passStringLint(%s) pass want %t, but got %v", i, c.value, c.pass, err)
+		}
+	}
+
+	for _, v := range []string{
+		`pattern: {name: "("}`,
+		`prefix: {count: "1"}`,
+		`prefix: {name: 1}`,
+		`suffix: {name: {}}`,
+		`suffix: {none: "a"}`,
+		`pattern: {name}`,
+	} {
+		if err := resolveLinterFromAnnotation(v, newArgs()); err == nil {
+			t.Fatalf("resolveLinterFromAnnotation(%s) should fail", v)
+		}
+	}
+}
+
+func TestResolveRelationLinter(t *testing.T) {
+	newArgs := func() decorArgsMap {
+		return decorArgsMap{
+			"a": &decorArg{1, "a", "string", nil, false, nil, nil},
+			"b": &decorArg{2, "b", "string", nil, false, nil, nil},
+			"c": &decorArg{3, "c", "int", nil, false, nil, nil},
+		}
+	}
+	args := newArgs()
+	for _, v := range []string{`exclusive: {a, b}`, `requires: {c: {a}, b: {a, c}}`} {
+		if err := resolveLinterFromAnnotation(v, args); err != nil {
+			t.Fatalf("resolveLinterFromAnnotation(%s) should pass, err: %v", v, err)
+		}
+	}
+	if len(args["a"].relations) != 1 || len(args["b"].relations) != 1 || len(args["c"].relations) != 1 {
+		t.Fatal("resolveLinterFromAnnotation() relations not match")
+	}
+	cas := []struct {
+		in   map[string]string
+		pass bool
+	}{
+		{map[string]string{}, true},
+		{map[string]string{"a": `""`}, true},
+		{map[string]string{"a": `""`, "b": `""`}, false},
+		{map[string]string{"c": "1"}, false},
+		{map[string]string{"c": "1", "a": `""`}, true},
+		{map[string]string{"b": `""`, "c": "1"}, false},
+	}
+	for i, c := range cas {
+		if err := checkParamRelations(args, c.in); (err == nil) != c.pass {
+			t.Fatalf("cas[%d] checkParamRelations() pass want %t, but got %v", i, c.pass, err)
+		}
+	}
+
+	for _, v := range []string{
+		`exclusive: {a}`,
+		`exclusive: {a, none}`,
+		`exclusive: {a: {b}}`,
+		`requires: {a}`,
+		`requires: {a: {}}`,
+		`requires: {a: {a}}`,
+		`requires: {a: {none}}`,
+	} {
+		if err := resolveLinterFromAnnotation(v, newArgs()); err == nil {
+			t.Fatalf("resolveLinterFromAnnotation(%s) should fail", v)
+		}
+	}
+}
+
 func TestA(t *testing.T) {
 	s := `map[any]any{a, b:{"str", 1, 1.0, true, gte: -1}, c}`
 	a, err := parser.ParseExpr(s)
 	log.Println(err)
 	ast.Print(token.NewFileSet(), a)
 }
+
+func TestDecorReceiverAware(t *testing.T) {
+	src := `package main
+func onlyTargetDo(ctx *decor.Context) { ctx.TargetDo() }
+func readReceiver(ctx *decor.Context) { _ = ctx.Receiver; _ = ctx.TargetIn[0] }
+func checkKind(ctx *decor.Context) { if ctx.Kind == decor.KFunc { _ = ctx.TargetIn[0] } }
+func indexIn(ctx *decor.Context) { ctx.TargetIn[0] = "first"; ctx.TargetDo() }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal("TestDecorReceiverAware parse error", err)
+	}
+	result := map[string]bool{
+		"onlyTargetDo": true,
+		"readReceiver": true,
+		"checkKind":    true,
+		"indexIn":      false,
+	}
+	for _, v := range f.Decls {
+		fd := v.(*ast.FuncDecl)
+		if decorReceiverAware(fd) != result[fd.Name.Name] {
+			t.Fatalf("decorReceiverAware(%s) should be %+v\n", fd.Name.Name, result[fd.Name.Name])
+		}
+	}
+}
+
+func TestDecorTimed(t *testing.T) {
+	src := `package main
+func onlyTargetDo(ctx *decor.Context) { ctx.TargetDo() }
+func elapsed(ctx *decor.Context) { ctx.TargetDo(); log.Println(ctx.Elapsed()) }
+func readStart(c *decor.Context) { if c.Start.IsZero() { return } }
+func otherStart(ctx *decor.Context) { var t struct{ Start int }; _ = t.Start }
+func helper(ctx *decor.Context) { report(ctx) }
+func shadowed(ctx *decor.Context) { ctx.TargetDo(); { ctx := struct{ Start int }{}; _ = ctx.Start } }
+func loaded(ctx *decor.Context) { ctx.TargetDo(); _ = decor.In[int](ctx, 0); _, _ = decor.LoadT[int](ctx, "k") }
+func closure(ctx *decor.Context) { func() { report(ctx) }() }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal("TestDecorTimed parse error", err)
+	}
+	result := map[string]bool{
+		"elapsed":   true,
+		"readStart": true,
+		"helper":    true,
+		"closure":   true,
+	}
+	for _, v := range f.Decls {
+		fd := v.(*ast.FuncDecl)
+		if decorTimed(fd, "decor") != result[fd.Name.Name] {
+			t.Fatalf("decorTimed(%s) should be %+v\n", fd.Name.Name, result[fd.Name.Name])
+		}
+	}
+}
+
+func TestDecorChainTimed(t *testing.T) {
+	src := `package main
+func elapsed(ctx *decor.Context) { ctx.TargetDo(); log.Println(ctx.Elapsed()) }
+func timings(ctx *decor.Context) { ctx.TargetDo(); log.Println(ctx.ChainTimings()) }
+func selfTimings(c *decor.Context) { c.TargetDo(); log.Println(c.Chain.SelfTimings()) }
+func helper(ctx *decor.Context) { report(ctx) }
+func loaded(ctx *decor.Context) { ctx.TargetDo(); _, _ = decor.LoadT[int](ctx, "k") }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal("TestDecorChainTimed parse error", err)
+	}
+	result := map[string]bool{
+		"timings":     true,
+		"selfTimings": true,
+		"helper":      true,
+	}
+	for _, v := range f.Decls {
+		fd := v.(*ast.FuncDecl)
+		if decorChainTimed(fd, "decor") != result[fd.Name.Name] {
+			t.Fatalf("decorChainTimed(%s) should be %+v\n", fd.Name.Name, result[fd.Name.Name])
+		}
+	}
+}
+
+func TestParseTargetLint(t *testing.T) {
+	cas := []struct {
+		doc string
+		r   targetLint
+		err bool
+	}{
+		{"//go:decor logging\n", targetLint{}, false},
+		{"//go:decor logging\n//go:decor-lint once: true\n", targetLint{once: true}, false},
+		{"//go:decor-lint once: true\n//go:decor logging\n", targetLint{once: true}, false},
+		{"//go:decor logging\n//go:decor-lint once:false\n", targetLint{}, false},
+		{"//go:decor logging\n//go:decor-lint assignable: true\n", targetLint{assignable: true}, false},
+		{"//go:decor-lint once: true\n//go:decor-lint assignable: true\n//go:decor logging\n", targetLint{true, true}, false},
+		{"//go:decor logging\n//go:decor-lint once: 1\n", targetLint{}, true},
+		{"//go:decor logging\n//go:decor-lint assignable: yes\n", targetLint{}, true},
+		{"//go:decor logging\n//go:decor-lint nonzero: {a}\n", targetLint{}, true},
+	}
+	for i, c := range cas {
+		f, err := parser.ParseFile(token.NewFileSet(), "", "package main\n"+c.doc+"func f() {}\n", parser.ParseComments)
+		if err != nil {
+			t.Fatal("parse error", err)
+		}
+		tl, lerr := parseTargetLint(f.Decls[0].(*ast.FuncDecl).Doc)
+		if (lerr != nil) != c.err {
+			t.Fatalf("parseTargetLint() err not match, case %d, got %v", i, lerr)
+		}
+		if lerr == nil && *tl != c.r {
+			t.Fatalf("parseTargetLint() want %+v but got %+v, case %d", c.r, *tl, i)
+		}
+	}
+}
+
+func TestTakeDecorPriority(t *testing.T) {
+	cas := []struct {
+		s        string
+		priority int
+		left     int
+		err      bool
+	}{
+		{"logging", 0, 0, false},
+		{"logging#{priority: 10}", 10, 0, false},
+		{"logging#{priority: -1, msg: \"a\"}", -1, 1, false},
+		{"logging#{priority: 1.5}", 0, 0, true},
+		{"logging#{priority: \"1\"}", 0, 0, true},
+		{"logging#{priority: true}", 0, 0, true},
+	}
+	for i, c := range cas {
+		_, p, err := parseDecorAndParameters(c.s)
+		if err != nil {
+			t.Fatal("parseDecorAndParameters() error", err, "case", i)
+		}
+		priority, err := takeDecorPriority(p)
+		if (err != nil) != c.err {
+			t.Fatalf("takeDecorPriority() err not match, case %d, got %v", i, err)
+		}
+		if priority != c.priority || len(p) != c.left {
+			t.Fatalf("takeDecorPriority() want %d with %d left, but got %d %v, case %d", c.priority, c.left, priority, p, i)
+		}
+	}
+}
+
+func TestTakeDecorAllowMain(t *testing.T) {
+	cas := []struct {
+		s     string
+		allow bool
+		err   bool
+	}{
+		{"boot", false, false},
+		{`boot#{allowMain: true}`, true, false},
+		{`boot#{allowMain: false}`, false, false},
+		{`boot#{allowMain: 1}`, false, true},
+		{`boot#{allowMain: "true"}`, false, true},
+	}
+	for i, c := range cas {
+		_, p, err := parseDecorAndParameters(c.s)
+		if err != nil {
+			t.Fatal("parseDecorAndParameters() error", err, "case", i)
+		}
+		allow, err := takeDecorAllowMain(p)
+		if (err != nil) != c.err {
+			t.Fatalf("takeDecorAllowMain() err not match, case %d, got %v", i, err)
+		}
+		if allow != c.allow || len(p) != 0 {
+			t.Fatalf("takeDecorAllowMain() want %v, but got %v %v, case %d", c.allow, allow, p, i)
+		}
+	}
+}
+
+func TestIsEntryFunc(t *testing.T) {
+	cas := []struct {
+		src   string
+		entry []bool
+	}{
+		{"package main\nfunc main() {}\nfunc init() {}\nfunc run() {}\nfunc (T) main() {}", []bool{true, true, false, false}},
+		{"package a\nfunc main() {}\nfunc init() {}", []bool{false, true}},
+	}
+	for i, c := range cas {
+		f, err := parser.ParseFile(token.NewFileSet(), "", c.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, decl := range f.Decls {
+			if got := isEntryFunc(f, decl.(*ast.FuncDecl)); got != c.entry[j] {
+				t.Fatalf("isEntryFunc() want %v, but got %v, case %d func %d", c.entry[j], got, i, j)
+			}
+		}
+	}
+}
+
+func TestTakeDecorWhen(t *testing.T) {
+	tags := map[string]bool{"prod": true, "linux": true}
+	cas := []struct {
+		s       string
+		enabled bool
+		err     bool
+	}{
+		{"metrics", true, false},
+		{`metrics#{when: "prod"}`, true, false},
+		{`metrics#{when: "debug"}`, false, false},
+		{`metrics#{when: "prod && !debug"}`, true, false},
+		{`metrics#{when: "debug || (prod && linux)"}`, true, false},
+		{`metrics#{when: "!prod"}`, false, false},
+		{`metrics#{when: "prod &&"}`, false, true},
+		{`metrics#{when: 1}`, false, true},
+	}
+	for i, c := range cas {
+		_, p, err := parseDecorAndParameters(c.s)
+		if err != nil {
+			t.Fatal("parseDecorAndParameters() error", err, "case", i)
+		}
+		enabled, err := takeDecorWhen(p, tags)
+		if (err != nil) != c.err {
+			t.Fatalf("takeDecorWhen() err not match, case %d, got %v", i, err)
+		}
+		if enabled != c.enabled || len(p) != 0 {
+			t.Fatalf("takeDecorWhen() want %v, but got %v %v, case %d", c.enabled, enabled, p, i)
+		}
+	}
+}
+
+func TestCheckDecorPure(t *testing.T) {
+	targetPkg := "github.com/dengsgo/go-decorator/cmd/decorator"
+	cas := []struct {
+		name string
+		msg  string
+	}{
+		{"logging", ""},
+		{"pureDecor", ""},
+		{"impureGlobalDecor", "references package-level variable: pureCounter"},
+		{"impureIODecor", "performs I/O: os.Stdout"},
+	}
+	for _, c := range cas {
+		err := checkDecorPure(targetPkg, c.name)
+		if c.msg == "" {
+			if err != nil {
+				t.Fatal("checkDecorPure() should err == nil but got error", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Fatalf("checkDecorPure(%s) should return err contains %q but got %v", c.name, c.msg, err)
+		}
+	}
+}
+
+func TestCheckDecorComparable(t *testing.T) {
+	targetPkg := "github.com/dengsgo/go-decorator/cmd/decorator"
+	src := `package p
+
+func ok(a int, s string, p struct{ x [2]int }, v any, T named) {}
+func ptr(a int, p *int) {}
+func slice(s []string) {}
+func variadic(v ...int) {}
+func unnamed(map[string]int) {}
+func nested(p struct{ f func() }) {}
+func (t *T) method() {}
+func (t T) valueMethod() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"ok":          "",
+		"ptr":         "parameter p is a pointer",
+		"slice":       "parameter s is a slice",
+		"variadic":    "parameter v is a variadic parameter",
+		"unnamed":     "parameter 0 is a map",
+		"nested":      "parameter p is a func",
+		"method":      "the receiver is a pointer",
+		"valueMethod": "",
+	}
+	for _, decl := range f.Decls {
+		fd := decl.(*ast.FuncDecl)
+		err := checkDecorComparable(targetPkg, "memoized", fd)
+		if msg := want[fd.Name.Name]; msg == "" && err != nil || msg != "" && (err == nil || !strings.Contains(err.Error(), msg)) {
+			t.Fatalf("checkDecorComparable(%s) should return err contains %q but got %v", fd.Name.Name, msg, err)
+		}
+		if err := checkDecorComparable(targetPkg, "logging", fd); err != nil {
+			t.Fatal("checkDecorComparable() without the lint should return nil, got", err)
+		}
+	}
+	if err := resolveLinterFromAnnotation("comparable: yes", nil); err == nil {
+		t.Fatal("resolveLinterFromAnnotation() comparable should be true or false")
+	}
+}
+
+func TestCheckDecorDeprecated(t *testing.T) {
+	targetPkg := "github.com/dengsgo/go-decorator/cmd/decorator"
+	cas := []struct {
+		name string
+		msg  string
+	}{
+		{"logging", ""},
+		{"notExist", ""},
+		{"oldLogging", "decorator oldLogging is deprecated: use leveled instead"},
+		{"oldTiming", "decorator oldTiming is deprecated"},
+	}
+	for _, c := range cas {
+		msg, declPos := checkDecorDeprecated(targetPkg, c.name)
+		if msg != c.msg || (msg != "") != strings.Contains(declPos, "fixtures_test.go:") {
+			t.Fatalf("checkDecorDeprecated(%s) want %q, but got %q %q", c.name, c.msg, msg, declPos)
+		}
+	}
+	for s, want := range map[string]bool{
+		"//go:decor-deprecated":                true,
+		"//go:decor-deprecated use b instead":  true,
+		"//go:decor-deprecated\tuse b instead": true,
+		"//go:decor-deprecatedx":               false,
+		"// go:decor-deprecated":               false,
+	} {
+		if isDecorDeprecatedFlag(s) != want {
+			t.Fatalf("isDecorDeprecatedFlag(%q) want %v", s, want)
+		}
+	}
+	// 废弃标记之前的 lint 注释仍然生效
+	if _, err := checkDecorAndGetParam(nil, targetPkg, "oldLogging", map[string]string{"level": `""`}); err == nil {
+		t.Fatal("checkDecorAndGetParam(oldLogging) should fail lint nonzero")
+	}
+}
+
+func TestSuggestName(t *testing.T) {
+	names := []string{"attempts", "backoffMs", "jitter", "maxBackoffMs", "s"}
+	cas := []struct {
+		s, want string
+	}{
+		{"attempt", "attempts"},
+		{"atempts", "attempts"},
+		{"backoffMS", "backoffMs"},
+		{"maxBackoff", "maxBackoffMs"},
+		{"jiter", "jitter"},
+		{"x", "s"},
+		{"ab", ""},
+		{"timeout", ""},
+	}
+	for _, c := range cas {
+		if got := suggestName(c.s, names); got != c.want {
+			t.Fatalf("suggestName(%q) want %q, got %q", c.s, c.want, got)
+		}
+	}
+	if d := editDistance("kitten", "sitting"); d != 3 {
+		t.Fatal("editDistance() want 3, got", d)
+	}
+}
+
+func TestFindFuncPackageErrors(t *testing.T) {
+	loader := newPkgLoader()
+	// 包无法解析和包中没有这个函数是不同的错误
+	_, _, _, err := loader.findFunc("github.com/dengsgo/go-decorator/decor/nothere", "nothere.Logging")
+	if err == nil || !strings.HasPrefix(err.Error(), msgPkgNotResolvable+"github.com/dengsgo/go-decorator/decor/nothere, ") {
+		t.Fatal("findFunc() should report the package is not resolvable, but got", err)
+	}
+	_, _, _, err = loader.findFunc("github.com/dengsgo/go-decorator/decor/logging", "logging.NoSuchFunc")
+	if err == nil || !strings.HasPrefix(err.Error(), "decorator not found: github.com/dengsgo/go-decorator/decor/logging#NoSuchFunc, package ") ||
+		!strings.HasSuffix(err.Error(), "has no function NoSuchFunc") {
+		t.Fatal("findFunc() should report the function is not found in the package, but got", err)
+	}
+}
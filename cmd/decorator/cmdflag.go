@@ -1,24 +1,64 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/dengsgo/go-decorator/cmd/logs"
+	"github.com/dengsgo/go-decorator/decor"
 )
 
-const version = `v0.22.0 beta`
+// 源码中的版本号，从源码构建（go build 、go run）时使用
+const sourceVersion = `v0.22.0 beta`
 const opensourceUrl = `https://github.com/dengsgo/go-decorator`
 
+// decorator 的版本，通过 go install ...@version 安装时为模块的版本
+var version = buildVersion(debug.ReadBuildInfo())
+
+// 伪版本号，如 v0.0.0-20231016114752-25a81ce0b6be 、v0.22.1-0.20231016114752-25a81ce0b6be
+var pseudoVersionRegexp = regexp.MustCompile(`[-.]\d{14}-[0-9a-f]{12}(\+|$)`)
+
+// 从构建信息中取 decorator 模块的版本。没有版本（(devel)）、在源码目录中构建产生的伪版本或 +dirty 版本时为 sourceVersion
+func buildVersion(info *debug.BuildInfo, ok bool) string {
+	if !ok || info.Main.Version == "" || info.Main.Version == "(devel)" ||
+		pseudoVersionRegexp.MatchString(info.Main.Version) || strings.HasSuffix(info.Main.Version, "+dirty") {
+		return sourceVersion
+	}
+	return info.Main.Version
+}
+
 // CmdFlag 存储命令行参数，包括日志级别、临时目录、是否清理工作目录、程序版本号等。
 type CmdFlag struct {
-	Level     string // -d.log          // 指定日志级别
-	TempDir   string // -d.tempDir		// 指定工作目录
-	ClearWork bool   // -d.clearWork	// 完成编译后是否清理工作目录
-	Version   string // -version		// 程序版本号
+	Level            string     // -d.log          // 指定日志级别
+	TempDir          string     // -d.tempDir		// 指定工作目录
+	ClearWork        bool       // -d.clearWork	// 完成编译后是否清理工作目录
+	EmitInlineReport bool       // -d.emitInlineReport // 编译后报告哪些被装饰的函数不再能被内联
+	PrintConfig      bool       // -d.printConfig // 编译前输出最终生效的配置
+	Output           string     // -d.output // 额外把改写后的源码写入这个目录，编译后保留
+	Tags             string     // -d.tags // 逗号分隔的装饰器标签，决定带有 when 参数的装饰器是否生效
+	Cache            string     // -d.cache // on/off ，是否缓存装饰器所在的包的解析结果和改写结果
+	CacheClear       bool       // -d.cache.clear // 编译前清空缓存
+	ErrJSON          bool       // -d.errjson // 以 JSON lines 格式输出装饰器的错误和警告
+	Disable          bool       // -d.disable // 不改写任何代码，和环境变量 GODECOR=off 相同
+	AutoImport       bool       // -d.autoimport // 自动导入文件中没有导入、但包中其他文件导入了的装饰器包
+	Strict           bool       // -d.strict // 把装饰器的警告（如使用了已废弃的装饰器）作为错误
+	ExtLint          bool       // -d.extlint // 执行装饰器声明的 external lint 命令，见 extlint.go
+	Prefix           string     // -d.prefix // 注解的前缀，默认为 //go:decor ，优先于 decor.toml 中的 prefix
+	SkipCgo          bool       // -d.skipCgo // 不改写导入 "C" 的文件，其中被装饰的函数给出警告
+	Patch            bool       // -d.patch // 在原文件的文本中插入生成的代码，而不是重新打印整个文件
+	NoInline         bool       // -d.noInline // 不内联标记了 //go:decor-pure 的装饰器，见 inline.go
+	Toggle           bool       // -d.toggle // 生成在运行时检查 decor.Enabled 的代码，见 toggle.go
+	Plugins          pluginFlag // -d.plugin // 代码生成插件的命令，可以指定多次，见 plugin.go
+	Version          string     // -version		// 程序版本号
 
 	// go build args
 	toolPath  string   // 存储当前执行的工具路径，即运行此程序的命令。
@@ -42,11 +82,97 @@ func initUseFlag() {
 		"d.clearWork",
 		true,
 		"empty workspace when compilation is complete")
+	// 将命令行参数 -d.emitInlineReport 映射到 cmdFlag.EmitInlineReport，决定是否输出被装饰函数的内联报告。
+	flag.BoolVar(&cmdFlag.EmitInlineReport,
+		"d.emitInlineReport",
+		false,
+		"report decorated functions that are no longer inlinable (advisory, compiles the package again with -m)")
+	// 将命令行参数 -d.printConfig 映射到 cmdFlag.PrintConfig，决定是否在编译前输出最终生效的配置。
+	flag.BoolVar(&cmdFlag.PrintConfig,
+		"d.printConfig",
+		false,
+		"print the resolved configuration once per build, before decorating the first package")
+	// 将命令行参数 -d.output 映射到 cmdFlag.Output，改写后的源码会按包的导入路径额外写入这个目录，编译后不会清理。
+	flag.StringVar(&cmdFlag.Output,
+		"d.output",
+		"",
+		"also write rewritten sources into dir/<import path>/ and keep them after the build, a relative dir is based on the module dir")
+	// 将命令行参数 -d.tags 映射到 cmdFlag.Tags，带有 when 参数的装饰器只有在表达式满足这些标签时才会生效。
+	flag.StringVar(&cmdFlag.Tags,
+		"d.tags",
+		"",
+		"comma-separated decorator tags, a decorator with `when` is only applied if the expression is satisfied by them")
+	// 将命令行参数 -d.cache 映射到 cmdFlag.Cache，off 时每次都完整解析装饰器所在的包并重新改写源文件。
+	flag.StringVar(&cmdFlag.Cache,
+		"d.cache",
+		"on",
+		"cache which files of a decorator package need to be parsed and the rewritten files of unchanged packages under the work dir. on/off")
+	// 将命令行参数 -d.cache.clear 映射到 cmdFlag.CacheClear，编译前清空 -d.cache 的缓存。
+	flag.BoolVar(&cmdFlag.CacheClear,
+		"d.cache.clear",
+		false,
+		"clear the caches of -d.cache before compiling")
+	// 将命令行参数 -d.errjson 映射到 cmdFlag.ErrJSON，装饰器的错误和警告每行输出一个 JSON 对象，便于 IDE 插件和 CI 解析。
+	flag.BoolVar(&cmdFlag.ErrJSON,
+		"d.errjson",
+		false,
+		"emit decorator errors and warnings as JSON lines (severity, message, file, line, column, decorator)")
+	// 将命令行参数 -d.disable 映射到 cmdFlag.Disable，不改写任何代码，便于对比装饰前后的性能。
+	flag.BoolVar(&cmdFlag.Disable,
+		"d.disable",
+		false,
+		"compile the original sources without decorating, same as the env "+decorEnvKey+"=off")
+	// 将命令行参数 -d.autoimport 映射到 cmdFlag.AutoImport，装饰器的包没有在文件中导入时从包中其他文件的导入中查找并自动导入。
+	flag.BoolVar(&cmdFlag.AutoImport,
+		"d.autoimport",
+		false,
+		"import the package of a decorator into the file when it is not imported there but by another file of the package")
+	// 将命令行参数 -d.strict 映射到 cmdFlag.Strict，装饰器的警告和错误一样导致编译失败。
+	flag.BoolVar(&cmdFlag.Strict,
+		"d.strict",
+		false,
+		"report decorator warnings, such as uses of deprecated decorators, as errors")
+	// 将命令行参数 -d.extlint 映射到 cmdFlag.ExtLint，编译和 lint 时执行装饰器上 //go:decor-lint external 声明的命令。
+	flag.BoolVar(&cmdFlag.ExtLint,
+		"d.extlint",
+		false,
+		"run the commands declared by //go:decor-lint external on decorators, they are ignored by default")
+	// 将命令行参数 -d.prefix 映射到 cmdFlag.Prefix，所有注解使用这个前缀，如 //acme:decor 、//acme:decor-lint 。
+	flag.StringVar(&cmdFlag.Prefix,
+		"d.prefix",
+		"",
+		"annotation prefix used instead of //go:decor, like //acme:decor, overrides the prefix in "+decorConfigFileName)
+	// 将命令行参数 -d.skipCgo 映射到 cmdFlag.SkipCgo，导入 "C" 的文件保持不变，只给出警告。
+	flag.BoolVar(&cmdFlag.SkipCgo,
+		"d.skipCgo",
+		false,
+		`don't decorate functions in files that import "C", warn about them instead`)
+	// 将命令行参数 -d.patch 映射到 cmdFlag.Patch，改写后的文件保留原文件的格式和注释。
+	flag.BoolVar(&cmdFlag.Patch,
+		"d.patch",
+		false,
+		"splice the generated code into the original source instead of printing the whole rewritten file")
+	// 将命令行参数 -d.noInline 映射到 cmdFlag.NoInline，标记了 //go:decor-pure 的装饰器也生成闭包并调用。
+	flag.BoolVar(&cmdFlag.NoInline,
+		"d.noInline",
+		false,
+		"don't inline decorators marked //go:decor-pure into their targets")
+	// 将命令行参数 -d.toggle 映射到 cmdFlag.Toggle，装饰器可以在运行时通过 decor.Disable 或环境变量 GODECOR_DISABLE 关闭。
+	flag.BoolVar(&cmdFlag.Toggle,
+		"d.toggle",
+		false,
+		"check decor.Enabled before calling each decorator, so decorators can be turned off at run time with decor.Disable or the env GODECOR_DISABLE")
+	// 将命令行参数 -d.plugin 映射到 cmdFlag.Plugins，插件可以在被装饰的函数中插入语句，或者生成和包一起编译的文件。
+	flag.Var(&cmdFlag.Plugins,
+		"d.plugin",
+		"run the code generation plugin `cmd` after decorating a package, it reads the decorated functions as JSON and writes the statements and files to add, can be repeated")
 	// 如果命令行输入 -h 或 --help，会输出这段自定义的帮助信息。
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "decorator [-d.log] [-d.tempDir] chainToolPath chainArgs\n")
 		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n")
+		printSubcommandsUsage(flag.CommandLine.Output())
 	}
 	// 解析命令行参数
 	flag.Parse()
@@ -71,6 +197,15 @@ func initUseFlag() {
 		log.SetFlags(0)
 	}
 
+	if cmdFlag.Cache != "on" && cmdFlag.Cache != "off" {
+		logs.Error("-d.cache must be on or off, but got", cmdFlag.Cache)
+	}
+	if cmdFlag.Prefix != "" {
+		if _, err := normalizeDecorPrefix(cmdFlag.Prefix); err != nil {
+			logs.Error("-d.prefix", err)
+		}
+	}
+
 	// 设置临时目录
 	if cmdFlag.TempDir != "" {
 		tempDir = cmdFlag.TempDir // TODO check
@@ -87,18 +222,120 @@ func initUseFlag() {
 		os.Exit(0)
 	}
 
-	// 遍历命令行参数，检查是否存在以 goToolDir 为前缀的路径。
-	// 如果找到了这个路径，则认为它是工具链的路径，并将其赋值给 cmdFlag.chainName 。
-	// 剩余的参数（如果有的话）会被赋值到 cmdFlag.chainArgs 中。
-	for i, arg := range os.Args[1:] {
-		if goToolDir != "" && strings.HasPrefix(arg, goToolDir) {
-			cmdFlag.chainName = arg
-			if len(os.Args[1:]) > i+1 {
-				cmdFlag.chainArgs = os.Args[i+2:]
-			}
-			break
-		}
+	// 查找工具链的路径，赋值给 cmdFlag.chainName ，它之后的参数赋值给 cmdFlag.chainArgs 。
+	if i := chainToolIndex(os.Args[1:], goToolDir); i >= 0 {
+		cmdFlag.chainName = os.Args[i+1]
+		cmdFlag.chainArgs = os.Args[i+2:]
 	}
 }
 
 var cmdFlag = &CmdFlag{}
+
+// 最终生效的配置，按输出顺序排列
+func resolvedConfig() [][2]string {
+	projectName := ""
+	if packageInfo != nil {
+		projectName = packageInfo.Module.Path
+	}
+	kvs := [][2]string{
+		{"version", version},
+		{"project", projectName},
+		{"projectDir", projectDir},
+		{"decorPrefix", strings.TrimSpace(decoratorScanFlag)},
+		{"lintPrefix", strings.TrimSpace(decorLintScanFlag)},
+		{"tempDir", tempDir},
+		{"d.log", cmdFlag.Level},
+		{"d.tempDir", cmdFlag.TempDir},
+		{"d.clearWork", strconv.FormatBool(cmdFlag.ClearWork)},
+		{"d.emitInlineReport", strconv.FormatBool(cmdFlag.EmitInlineReport)},
+		{"d.printConfig", strconv.FormatBool(cmdFlag.PrintConfig)},
+		{"d.output", cmdFlag.Output},
+		{"d.tags", cmdFlag.Tags},
+		{"d.cache", cmdFlag.Cache},
+		{"d.cache.clear", strconv.FormatBool(cmdFlag.CacheClear)},
+		{"d.errjson", strconv.FormatBool(cmdFlag.ErrJSON)},
+		{"d.disable", strconv.FormatBool(cmdFlag.Disable)},
+		{"d.autoimport", strconv.FormatBool(cmdFlag.AutoImport)},
+		{"d.strict", strconv.FormatBool(cmdFlag.Strict)},
+		{"d.extlint", strconv.FormatBool(cmdFlag.ExtLint)},
+		{"d.prefix", cmdFlag.Prefix},
+		{"d.skipCgo", strconv.FormatBool(cmdFlag.SkipCgo)},
+		{"d.patch", strconv.FormatBool(cmdFlag.Patch)},
+		{"d.noInline", strconv.FormatBool(cmdFlag.NoInline)},
+		{"d.toggle", strconv.FormatBool(cmdFlag.Toggle)},
+		{"d.plugin", cmdFlag.Plugins.String()},
+		{decorEnvKey, os.Getenv(decorEnvKey)},
+		{"chainTool", cmdFlag.chainName},
+	}
+	return append(kvs, decorationConfig()...)
+}
+
+// 装饰器是否生效、decor.toml 中覆盖的设置和运行时关闭的装饰器
+func decorationConfig() [][2]string {
+	state := "enabled"
+	if cmdFlag.Disable {
+		state = "skipped (-d.disable)"
+	} else if decoratorDisabled() {
+		state = "skipped (" + decorEnvKey + "=off)"
+	}
+	kvs := [][2]string{{"decorators", state}}
+
+	var cfg *decorConfig
+	var err error
+	if projectDir != "" {
+		cfg, err = loadDecorConfig(projectDir)
+	}
+	switch {
+	case err != nil:
+		kvs = append(kvs, [2]string{decorConfigFileName, "invalid: " + err.Error()})
+	case cfg == nil:
+		kvs = append(kvs, [2]string{decorConfigFileName, ""})
+	default:
+		kvs = append(kvs, [2]string{decorConfigFileName, filepath.Join(cfg.dir, decorConfigFileName)})
+		if cfg.prefix != "" {
+			kvs = append(kvs, [2]string{decorConfigFileName + " prefix", cfg.prefix})
+		}
+		for _, rule := range cfg.rules {
+			kvs = append(kvs, [2]string{fmt.Sprintf("%s rule:%d", decorConfigFileName, rule.line), rule.String()})
+		}
+		names := make([]string, 0, len(cfg.aliases))
+		for name := range cfg.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			kvs = append(kvs, [2]string{decorConfigFileName + " alias " + name, cfg.aliases[name]})
+		}
+	}
+
+	// 运行时关闭的装饰器，只有 -d.toggle 生成的代码会检查
+	disabled := os.Getenv(decor.DisableEnvKey)
+	if disabled != "" && !cmdFlag.Toggle {
+		disabled += " (ignored without -d.toggle)"
+	}
+	return append(kvs, [2]string{decor.DisableEnvKey, disabled})
+}
+
+// 同一次构建中只有第一个改写包的 compile 输出配置。output 是 compile 的输出 $WORK/bNNN/_pkg_.a ，
+// 在 go 的工作目录 $WORK 中创建标记文件，创建成功的 compile 输出；$WORK 在构建结束后会被 go 删除。
+func printConfigOnce(output string) bool {
+	if output == "" || !filepath.IsAbs(output) {
+		return true
+	}
+	marker := filepath.Join(filepath.Dir(filepath.Dir(output)), "decorator-printconfig")
+	f, err := os.OpenFile(marker, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	if err != nil {
+		return !os.IsExist(err)
+	}
+	_ = f.Close()
+	return true
+}
+
+func configString() string {
+	bf := bytes.NewBuffer([]byte{})
+	bf.WriteString("resolved configuration:")
+	for _, kv := range resolvedConfig() {
+		fmt.Fprintf(bf, "%s%-20s %s", biSymbol, kv[0], kv[1])
+	}
+	return bf.String()
+}
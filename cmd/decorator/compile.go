@@ -3,13 +3,19 @@ package main
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"go/ast"
+	"go/parser"
 	"go/printer"
 	"go/token"
+	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/dengsgo/go-decorator/cmd/logs"
@@ -18,18 +24,65 @@ import (
 const msgDecorPkgNotImported = "decorator used but package not imported (need add `import _ \"" + decoratorPackagePath + "\"`)"
 const msgDecorPkgNotFound = "decor package is not found"
 const msgCantUsedOnDecoratorFunc = `decorators cannot be used on decorators`
+const msgDecorLinknamed = "decorated function is referenced by //go:linkname, code linked to this symbol will run the decorated version"
+const msgDecorEntryNotAllowed = "decorating main or init changes how the program starts, opt in with the allowMain parameter, like //go:decor boot#{allowMain: true}"
+const msgDecorTypedOnFuncValue = "decorators with a typed context cannot decorate a function value, decorate a function declaration or literal instead"
+const msgDecorNotReceiverAware = "decorator reads TargetIn by index but never checks Receiver or Kind, it may not handle the method receiver"
 
 var packageInfo *_packageInfo
 
+// 当前编译的包中被装饰的函数和方法，名称格式和 -gcflags=-m 的输出一致
+var decoratedTargets []string
+
 var printerCfg = &printer.Config{Tabwidth: 8, Mode: printer.SourcePos}
 
-func compile(args []string) error {
+func compile(args []string) ([]string, error) {
+	// 解析 compile 的参数，-p 为包名，标志之后是源文件，相对路径基于工作目录。
+	// 工作目录可能是包所在的目录，也可能是模块根目录（源文件为 a/b/c.go 这样的相对路径），
+	// 只处理工作目录中的 Go 源文件，以及 cgo 由工作目录中导入 "C" 的文件生成的文件（见 cgo.go），
+	// 其他生成的文件位于 $WORK 中，不会被处理。
+	ca := parseToolArgs(args, compileValueFlags, projectDir)
+	packageName := ca.flags["p"]
+	compileTrimPath = ca.flags["trimpath"]
+	files := make([]string, 0, len(ca.files))
+	cgoSourceFiles = map[string]string{}
+	var cgoSkipped []string
+	sourceDir := ""
+	for _, file := range ca.files {
+		if src, ok := cgoSourceFile(file); ok {
+			if !hasPathPrefix(src, projectDir) {
+				continue
+			}
+			if sourceDir == "" {
+				sourceDir = filepath.Dir(src)
+			}
+			if cmdFlag.SkipCgo {
+				cgoSkipped = append(cgoSkipped, file)
+				continue
+			}
+			cgoSourceFiles[file] = src
+			files = append(files, file)
+		} else if hasPathPrefix(file, projectDir) && strings.HasSuffix(file, ".go") {
+			if sourceDir == "" {
+				sourceDir = filepath.Dir(file)
+			}
+			files = append(files, file)
+		}
+	}
+
+	// 没有需要处理的源文件时（如 compile -V=full 查询版本）直接返回，此时工作目录不一定是 Go 包
+	if sourceDir == "" {
+		return args, nil
+	}
+	// 之后的 go list 和装饰器的查找都以包所在的目录为准
+	projectDir = sourceDir
+
 	{
 		var err error
 		// go list -json -find 会返回当前模块下的包信息
 		packageInfo, err = getPackageInfo("")
 		if err != nil || packageInfo.Module.Path == "" {
-			logs.Error("doesn't seem to be a Go project:", err)
+			logs.Error("doesn't seem to be a Go project:", err, biSymbol, "run `decorator doctor` in the module directory to check the setup")
 		}
 	}
 
@@ -37,32 +90,29 @@ func compile(args []string) error {
 	logs.Debug("projectName", projectName)
 	//log.Printf("TOOLEXEC_IMPORTPATH %+v\n", os.Getenv("TOOLEXEC_IMPORTPATH"))
 
-	files := make([]string, 0, len(args))
-	packageName := ""
-	for i, arg := range args {
-		// 如果当前参数是 -p 且后面还有参数（即 i+1 < len(args)），则说明后面跟的是包名。
-		if arg == "-p" && i+1 < len(args) {
-			packageName = args[i+1]
-		}
-		// 如果当前参数是以 - 开头的标志参数（例如 -p），则跳过。
-		if strings.HasPrefix(arg, "-") {
-			continue
-		}
-		// 如果参数是以 $projectDir/ 开头，并且是以 .go 后缀结尾，表示这是一个 Go 源文件的路径。
-		// 将从当前位置开始的所有参数都视为 Go 文件路径，并赋值给 files 。
-		// 找到符合条件的 Go 源文件后，跳出循环。
-		if strings.HasPrefix(arg, projectDir+string(filepath.Separator)) && strings.HasSuffix(arg, ".go") {
-			files = args[i:]
-			break
-		}
+	// 如果包名不是 main 且不属于当前项目（不以项目名作为前缀，也不属于 go.work 工作区的成员模块），则认为包名不符合要求，直接返回；
+	if packageName != "main" && !ownPackage(packageName, projectName, packageInfo.Module.Dir) {
+		return args, nil
 	}
 
-	// 如果包名不是 main 且不是以项目名作为前缀（例如，包名不属于当前 Go 项目），则认为包名不符合要求，直接返回；
-	// 如果没有找到符合条件的 Go 文件路径（即 files 为空），直接返回；
-	if (packageName != "main" && !strings.HasPrefix(packageName, projectName)) || len(files) == 0 {
-		return nil
+	// 注解的前缀可能由 -d.prefix 或 decor.toml 设置，之后的扫描都使用它
+	if err := useDecorPrefix(projectDir); err != nil {
+		logs.Error(err)
+	}
+
+	if cmdFlag.PrintConfig && printConfigOnce(ca.flags["o"]) {
+		log.Println(configString())
 	}
 
+	for _, file := range cgoSkipped {
+		warnCgoSkipped(file)
+	}
+	if len(files) == 0 {
+		return args, nil
+	}
+
+	logs.Debug("packageName", packageName, files, args)
+
 	// 如果能够成功获取到 decoratorPackagePath 包的信息，则生成一个 wrapped_code.go 文件的路径，并将其添加到 files 列表中，供后续处理。
 	decorWrappedCodeFilePath := ""
 	if dpp, err := getPackageInfo(decoratorPackagePath); err == nil {
@@ -70,7 +120,28 @@ func compile(args []string) error {
 		files = append(files, decorWrappedCodeFilePath)
 	}
 
-	logs.Debug("packageName", packageName, files, args)
+	tgDir := path.Join(tempDir, os.Getenv("TOOLEXEC_IMPORTPATH"))
+	_ = os.MkdirAll(tgDir, 0777)
+	if cmdFlag.Output != "" {
+		if err := clearOutputDir(outputPackageDir(cmdFlag.Output, packageInfo.Module.Dir, os.Getenv("TOOLEXEC_IMPORTPATH"))); err != nil {
+			logs.Warn("fail clear output dir", err.Error())
+		}
+	}
+
+	// 输入没有变化时直接使用缓存的改写结果，见 rewritecache.go
+	if cmdFlag.CacheClear {
+		if err := clearCaches(); err != nil {
+			logs.Warn("fail clear cache", err.Error())
+		}
+	}
+	cache := newRewriteCache(files, ca.flags["importcfg"], os.Getenv("TOOLEXEC_IMPORTPATH"))
+	if cache != nil && !cmdFlag.CacheClear && !goFlagsForceRebuild() {
+		if updatedFiles, tmpFiles, ok := cache.load(tgDir); ok {
+			logs.Debug("rewrite cache hit", packageName, updatedFiles)
+			replaceCompileFiles(args, ca, updatedFiles, tmpFiles)
+			return args, nil
+		}
+	}
 
 	// 把每个源文件解析为 ast
 	fset := token.NewFileSet()
@@ -79,13 +150,199 @@ func compile(args []string) error {
 		logs.Error(err)
 	}
 
+	// -d.patch 时记录改写之前的语法树，改写后和它比较得到需要插入的代码，见 patch.go
+	if cmdFlag.Patch {
+		if patchSnapshots, err = snapshotPackage(pkg); err != nil {
+			logs.Error(err)
+		}
+	}
+
+	// 改写其中被装饰的函数
+	updatedFiles, err := decoratePackage(fset, pkg, packageName, decorWrappedCodeFilePath)
+	if err != nil {
+		logs.Error(err)
+	}
+
+	// 并行打印和写入被改写的文件，再按 updatedFiles 的顺序替换构建参数中的源文件
+	// 指定了 -d.plugin 时，插件在被装饰的函数中插入语句，生成的文件和包一起编译，见 plugin.go
+	pluginFiles, err := runPlugins(fset, pkg, packageName, projectDir, tgDir)
+	if err != nil {
+		return args, err
+	}
+
+	tmpFiles, err := writeRewrittenFiles(fset, pkg, updatedFiles, tgDir)
+	if err != nil {
+		return args, err
+	}
+	replaceCompileFiles(args, ca, updatedFiles, tmpFiles)
+	args = append(args, pluginFiles...)
+	if cache != nil {
+		if err := cache.store(updatedFiles, tmpFiles, loadedPkgDirs(pkgILoader)); err != nil {
+			logs.Debug("write rewrite cache fail", err)
+		}
+	}
+
+	return args, nil
+}
+
+// 将构建参数 args 中被改写的源文件 updatedFiles 替换为对应的临时文件 tmpFiles
+func replaceCompileFiles(args []string, ca *toolArgs, updatedFiles, tmpFiles []string) {
+	for i, originPath := range updatedFiles {
+		if j, ok := ca.index[originPath]; ok {
+			args[j] = tmpFiles[i]
+		}
+		logs.Debug("rewrite file", originPath, "=>", tmpFiles[i])
+	}
+	logs.Debug("args updated", args)
+}
+
+// 打印被改写的文件 files 并写入目录 tgDir ，返回与 files 一一对应的临时文件路径。
+// 文件之间相互独立，打印只读取 ast 和 fset ，因此按文件并行处理；出错时返回 files 中第一个出错的文件的错误。
+// 改写本身（decoratePackage）仍然是顺序的，它共享装饰器的查找缓存并收集包级的结果。
+func writeRewrittenFiles(fset *token.FileSet, pkg *astPackage, files []string, tgDir string) ([]string, error) {
+	tmpFiles := make([]string, len(files))
+	errs := make([]error, len(files))
+	parallelEach(len(files), func(i int) {
+		tmpFiles[i], errs[i] = writeRewrittenFile(fset, pkg.Files[files[i]], files[i], tgDir)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tmpFiles, nil
+}
+
+// 将改写后的 f 写入 tgDir 中的同名文件和它的 source map ，指定了 -d.output 时额外写入一份
+func writeRewrittenFile(fset *token.FileSet, f *ast.File, originPath, tgDir string) (string, error) {
+	// 将 AST f 打印到缓冲区，构建约束行保持原样；-d.patch 时修补原文件，失败时仍然打印整个文件
+	var buffer bytes.Buffer
+	if cmdFlag.Patch {
+		if err := patchRewrittenFile(&buffer, fset, f, patchSnapshots[originPath]); err != nil {
+			logs.Warn("fail patch", originPath, "print the whole file instead:", err.Error())
+			buffer.Reset()
+		}
+	}
+	if buffer.Len() == 0 {
+		if err := printRewrittenFile(&buffer, fset, f); err != nil {
+			return "", errors.New("fprint original code")
+		}
+	}
+
+	// 写入临时文件
+	tmpEntryFile := path.Join(tgDir, filepath.Base(originPath))
+	if err := os.WriteFile(tmpEntryFile, buffer.Bytes(), 0777); err != nil {
+		return "", errors.New("fail write into temporary file " + err.Error())
+	}
+	// 写入 source map ，decorator sourcemap 根据它还原栈信息中的位置
+	if err := writeSourceMap(compileSourceFile(originPath), tmpEntryFile, buffer.Bytes(), sourceMapTargets[originPath]); err != nil {
+		logs.Warn("fail write source map", err.Error())
+	}
+
+	// 指定了 -d.output 时，额外写入一份，便于调试生成的代码
+	if cmdFlag.Output != "" {
+		outputFile := outputFilePath(cmdFlag.Output, packageInfo.Module.Dir, os.Getenv("TOOLEXEC_IMPORTPATH"), originPath)
+		_ = os.MkdirAll(filepath.Dir(outputFile), 0777)
+		if err := os.WriteFile(outputFile, buffer.Bytes(), 0666); err != nil {
+			return "", errors.New("fail write into output file " + err.Error())
+		}
+		if err := writeSourceMap(compileSourceFile(originPath), outputFile, buffer.Bytes(), sourceMapTargets[originPath]); err != nil {
+			logs.Warn("fail write source map", err.Error())
+		}
+		logs.Info("rewrite file", originPath, "=>", outputFile)
+	}
+	return tmpEntryFile, nil
+}
+
+// -d.output 的输出路径：<output>/<导入路径>/<文件名> ，output 为相对路径时基于模块目录 moduleDir 。
+func outputFilePath(output, moduleDir, importPath, originPath string) string {
+	return filepath.Join(outputPackageDir(output, moduleDir, importPath), filepath.Base(originPath))
+}
+
+// -d.output 中包的目录。测试时 importPath 形如 "a/b [a/b.test]" ，为测试重新编译的包和包本身可能同时编译，
+// 写入 <output>/_test/<测试的导入路径>/<导入路径> ，不和包本身的文件互相覆盖。
+func outputPackageDir(output, moduleDir, importPath string) string {
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(moduleDir, output)
+	}
+	if pkg, variant, ok := strings.Cut(importPath, " "); ok {
+		variant = strings.TrimSuffix(strings.TrimPrefix(variant, "["), "]")
+		return filepath.Join(output, "_test", filepath.FromSlash(variant), filepath.FromSlash(pkg))
+	}
+	return filepath.Join(output, filepath.FromSlash(importPath))
+}
+
+// 删除 -d.output 中包的目录 dir 里上一次构建写入的文件，不再被装饰的文件不会留下。子目录属于其它包，保留。
+func clearOutputDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 改写包 packageName 中被装饰的函数，返回被改写的文件（按路径排序）。
+// decorWrappedCodeFilePath 不为空时，它也应在 pkg 中，生成代码的位置信息会指向这个文件。
+// 装饰器的用法错误不会立即返回，有错误的目标被跳过，改写结束后返回包含包中所有错误的 error ，见 packageDiagnostics 。
+func decoratePackage(fset *token.FileSet, pkg *astPackage, packageName string, decorWrappedCodeFilePath string) ([]string, error) {
+	diags := &packageDiagnostics{fset: fset}
+	updatedFiles, err := rewritePackage(diags, pkg, packageName, decorWrappedCodeFilePath)
+	if err != nil {
+		logs.Error(err)
+	}
+	if err := diags.err(packageName); err != nil {
+		return nil, err
+	}
+	return updatedFiles, nil
+}
+
+// 改写包 pkg 中被装饰的函数，装饰器用法的错误和警告添加到 diags 中，返回被改写的文件（按路径排序）。
+// compile 和 lint 子命令共用，lint 时 diags.lint 为 true ，改写的结果不会被使用。decor.toml 无法解析时返回错误。
+func rewritePackage(diags *packageDiagnostics, pkg *astPackage, packageName string, decorWrappedCodeFilePath string) ([]string, error) {
+	fset := diags.fset
+	pluginDecorated = nil
+
 	errPos, err := typeDecorRebuild(pkg)
 	if err != nil {
-		logs.Error(err, biSymbol, friendlyIDEPosition(fset, errPos))
+		diags.add(errPos, "", err)
+	}
+	for _, up := range uncoveredPromotions {
+		diags.warn(up.pos, "", up.msg, biSymbol, friendlyIDEPosition(fset, up.pos))
 	}
+	if errPos, err := decorAllRebuild(pkg, decorWrappedCodeFilePath); err != nil {
+		diags.add(errPos, "", err)
+	}
+	// 按模块的 decor.toml 添加装饰注释
+	if err := applyDecorConfig(pkg, decorWrappedCodeFilePath); err != nil {
+		return nil, err
+	}
+	// 把使用了 decor.toml 中别名的装饰注释改写为 包名.装饰器
+	if err := applyDecorAliases(pkg, decorWrappedCodeFilePath); err != nil {
+		return nil, err
+	}
+
+	// 文件中没有导入的装饰器包从包中其他文件的导入中查找，见 resolveDecorImport
+	pkgImports, declared := collectPackageImports(pkg, decorWrappedCodeFilePath), packageDeclNames(pkg)
 
-	// 存储当前处理文件的路径
-	var originPath string
+	// 生成的代码的位置信息指向 wrapped_code.go 中的模板，包中所有的目标共用一次索引的结果
+	wrappedCode := newWrappedCodeTemplate(pkg.Files[decorWrappedCodeFilePath])
+
+	// 收集 //go:linkname 指令引用的函数，装饰它们时给出警告
+	linknames := collectLinknames(pkg, packageName)
+	tags := decorTags()
+
+	var updatedFiles []string
 	for file, f := range pkg.Files {
 		logs.Debug("file Parse", file)
 		if file == decorWrappedCodeFilePath {
@@ -99,68 +356,60 @@ func compile(args []string) error {
 
 		// imp 中存储了 file 的所有导入项
 		imp := newImporter(f)
+		// 填充 decor.Context.TargetFile 的路径，和 runtime.Caller 返回的一致
+		targetFile := trimCompilePath(compileSourceFile(file))
 
 		// 标记文件是否被更新
 		updated := false
+		// 文件中被装饰的函数，每一项为 decor.Register 的参数
+		var registers [][]string
+		// 被装饰的目标的参数名、类型等不变的信息，每个目标一个包级变量，见 targetMetaDecl
+		var metaDecls []ast.Decl
 
 		// 遍历文件 file 中每个函数声明
-		visitAstDecl(f, func(fd *ast.FuncDecl) (r bool) {
+		// 装饰一个函数声明，赋值给包级变量的函数字面量也会被转换成函数声明后交给它处理
+		decorate := func(fd *ast.FuncDecl) (r bool) {
 			// 无注释则忽略
 			if fd.Doc == nil || fd.Doc.List == nil || len(fd.Doc.List) == 0 {
 				return
 			}
 			//log.Printf("%+v\n", fd)
 
-			originPath = file
-			var collDecors []*decorAnnotation
-			mapDecors := newMapV[string, *ast.Comment]()
-
-			// 有注释则遍历
-			for i := len(fd.Doc.List) - 1; i >= 0; i-- {
-				doc := fd.Doc.List[i]
-				// 是否以 "//go:decor " 开头
-				//
-				// 例如：
-				// //go:decor logging
-				// //go:decor appendFile
-				// //go:decor fun1.DecorHandlerFunc
-				// //go:decor levelLogging#{level: "debug"}
-				// //go:decor hit#{msg: "message from decor", repeat: true, count: 10, f:1}
-				// func datetime(timestamp int64) string {
-				//     return time.Unix(timestamp, 0).String()
-				// }
-				if !strings.HasPrefix(doc.Text, decoratorScanFlag) {
-					break
-				}
-				logs.Debug("HIT:", doc.Text)
-				// 从 //go:decor 注释解析出 decorFuncName, decorFuncArgs
-				decorName, decorArgs, err := parseDecorAndParameters(doc.Text[len(decoratorScanFlag):])
-				logs.Debug(decorName, decorArgs, err)
-				if err != nil {
-					logs.Error(err, biSymbol, friendlyIDEPosition(fset, doc.Pos()))
-				}
-				// 不许重复修饰
-				if !mapDecors.put(decorName, doc) {
-					logs.Error("cannot use the same decorator for repeated decoration", biSymbol,
-						"Decor:", friendlyIDEPosition(fset, doc.Pos()), biSymbol,
-						"Repeated:", friendlyIDEPosition(fset, mapDecors.get(decorName).Pos()))
-				}
-				// 保存 decorate 相关注释
-				collDecors = append(collDecors, newDecorAnnotation(doc, decorName, decorArgs))
-			}
-
+			// 有错误时继续检查这个目标的其他装饰器，但不改写它
+			collDecors, failed := collectDecorAnnotations(diags, fd.Doc, tags)
 			// 当前函数无需修饰
 			if len(collDecors) == 0 {
 				return
 			}
+			// 装饰 main 、init 需要 allowMain 参数
+			for _, da := range collDecors {
+				if !da.allowMain && isEntryFunc(f, fd) {
+					diags.add(da.doc.Pos(), da.name, msgDecorEntryNotAllowed)
+					failed = true
+				}
+			}
 
 			logs.Info("find the entry for using the decorator", friendlyIDEPosition(fset, fd.Pos()))
+			tl, lerr := parseTargetLint(fd.Doc)
+			if lerr != nil {
+				diags.add(lerr.pos, "", lerr)
+				failed = true
+			}
+			warnLinknamed(diags, fd, linknames)
+			if pos, err := checkTargetDirectives(fd); err != nil {
+				diags.add(pos, "", err)
+				failed = true
+			}
 			logs.Debug("collDecors", collDecors)
 
+			// 记录被装饰的目标，供 -d.emitInlineReport 使用
+			decoratedTargets = append(decoratedTargets, inlineTargetName(fd))
+
 			// 生成一个随机标识符
 			gi := newGenIdentId()
 
-			// 链式修饰
+			// 检查每一个装饰器，collDecors 按 priority 和从下到上的顺序排列，第一个是最内层
+			anyTyped := false
 			for _, da := range collDecors {
 				logs.Debug("handler:", da.doc.Text)
 				// 检查 decorName 是不是装饰器
@@ -176,10 +425,9 @@ func compile(args []string) error {
 				// 检查装饰器包是否已导入：判断 f 是否已导入 "github.com/dengsgo/go-decorator/decor"
 				pkgDecorName, ok := imp.importedPath(decoratorPackagePath)
 				if !ok {
-					// 未导入报错
-					logs.Error(msgDecorPkgNotImported, biSymbol,
-						"Target:", friendlyIDEPosition(fset, fd.Pos()), biSymbol,
-						"Decor:", friendlyIDEPosition(fset, da.doc.Pos()))
+					// 未导入报错，这个目标的其他装饰器也是同样的错误
+					diags.add(fd.Pos(), "", msgDecorPkgNotImported)
+					return
 				} else if pkgDecorName == "_" {
 					// 若为 "_" 类型导入，强制修改别名为 decor
 					imp.pathObjMap[decoratorPackagePath].Name = nil // rewrite this package import way
@@ -187,137 +435,672 @@ func compile(args []string) error {
 					pkgDecorName = "decor"
 				}
 
-				// 如果当前函数已经是 decoratorFunc ，则不许对其 decorate
-				if funIsDecorator(fd, pkgDecorName) {
-					logs.Error(msgCantUsedOnDecoratorFunc, biSymbol, friendlyIDEPosition(fset, fd.Pos()))
-				}
-
-				// got package path
-				// 存储装饰器所在包的路径
-				decorPkgPath := ""
-				// 获取装饰器的包名 x
-				if x := decorX(decorName); x != "" {
-					// 检查当前文件是否已经导入包 x ，如果导入了，获取包的路径 xPath 。
-					if xPath, ok := imp.importedName(x); ok {
-						// 获取 x 包的别名
-						name, _ := imp.importedPath(xPath)
-						// 如果 x 包的别名为 "_" ，表示包被匿名导入，需要重置其别名以便使用
-						if name == "_" {
-							imp.pathObjMap[xPath].Name = nil // 将 imp.pathObjMap[xPath].Name 设为 nil，这会重写包的导入方式。
-							imp.pathMap[xPath] = x           // 设置别名。
-						}
-						decorPkgPath = xPath
-					} else {
-						// 如果包 x 未导入，记录错误日志，指出包未找到，并提供注释位置
-						logs.Error(x, "package not found", biSymbol, friendlyIDEPosition(fset, da.doc.Pos()))
+				// 如果当前函数已经是 decoratorFunc ，则不许对其 decorate ，绑定装饰器的方法也一样
+				if funIsDecorator(fd, pkgDecorName) || funIsBoundDecorator(fd, pkgDecorName) {
+					diags.add(fd.Pos(), "", msgCantUsedOnDecoratorFunc)
+					return
+				}
+
+				resolved, ok := checkDecorUse(diags, f, imp, pkgImports, declared, da, fd, fd.Pos())
+				if !ok {
+					failed = true
+				}
+				if !resolved {
+					continue
+				}
+				decorPkgPath := da.pkgPath
+
+				// 装饰方法时，检查装饰器是否考虑了接收者，仅给出警告
+				if fd.Recv != nil {
+					if aware, err := checkDecorReceiverAware(decorPkgPath, decorName); err == nil && !aware {
+						diags.warn(da.doc.Pos(), decorName, msgDecorNotReceiverAware, biSymbol,
+							"Target:", friendlyIDEPosition(fset, fd.Pos()), biSymbol,
+							"Decor:", friendlyIDEPosition(fset, da.doc.Pos()))
 					}
 				}
 
-				// 获取指定路径 decorPkgPath 下函数 decorName 的参数信息
-				params, err := checkDecorAndGetParam(decorPkgPath, decorName, decorParams)
-				if err != nil {
-					logs.Error(err, biSymbol, "Decor:", friendlyIDEPosition(fset, da.doc.Pos()))
+				// 装饰器接收类型化的上下文时，参数和返回值不再装箱为 any
+				if in, out, typed, err := checkDecorTypedContext(decorPkgPath, decorName); err == nil && typed {
+					da.typed, da.typedIn, da.typedOut = true, in, out
+					anyTyped = true
+				}
+				// 装饰器调用了 ctx.Elapsed 时记录调用开始的时间，读取 ctx.ChainTimings 时记录每一层的耗时，其它目标没有额外的开销
+				if timed, chainTimed, err := checkDecorTimed(decorPkgPath, decorName); err == nil {
+					da.timed = timed && !da.typed
+					da.chainTimed = chainTimed
+				}
+				// 标记了 //go:decor-pure 的装饰器满足条件时展开到目标中，见 inline.go
+				// -d.toggle 时装饰器在运行时可能被关闭，不内联
+				if !da.typed && !cmdFlag.NoInline && !cmdFlag.Toggle {
+					var reason string
+					if da.inline, reason = checkDecorInline(decorPkgPath, decorName); reason != "" {
+						logs.Debug("decorator", decorName, "is not inlined:", reason)
+					}
+				}
+			}
+			if failed {
+				return
+			}
+			// 目标直接调用的 recover() 需要在装饰后的函数开头调用，见 directRecoverCalls
+			recovers, err := directRecoverCalls(fd, declared)
+			if err != nil {
+				diags.add(fd.Pos(), "", err)
+				return
+			}
+
+			// 目标的所有装饰器都可以内联，并且它们引用的名字在目标中可用时才内联
+			allInline := true
+			var inlineRefs []map[string]string
+			var inlineImports []inlineImport
+			scope := signatureNames(fd)
+			for _, da := range collDecors {
+				if da.inline == nil {
+					allInline = false
+					break
+				}
+				x := ""
+				if da.pkgPath != "" {
+					x = decorX(da.name)
 				}
+				names, adds, reason := inlineNames(da.inline, x, imp, pkgImports, declared, scope)
+				if reason != "" {
+					logs.Debug("decorator", da.name, "is not inlined:", reason)
+					allInline = false
+					break
+				}
+				inlineRefs = append(inlineRefs, names)
+				inlineImports = append(inlineImports, adds...)
+			}
+			if allInline {
+				applyInlineImports(f, imp, inlineImports)
+			}
 
+			// 生成代码前的公共设置，链式装饰的各层共用同一个 metaVarName
+			var metaRA *ReplaceArgs
+			metaVarName := ""
+			newRA := func(decorName string, params []string) *ReplaceArgs {
 				ra := builderReplaceArgs(fd, decorName, params, gi)
+				if metaRA == nil {
+					metaRA, metaVarName = ra, gi.nextStr()
+				}
+				ra.MetaVarName = metaVarName
+				ra.Once = tl.once
+				ra.useLocation(packageName, targetFile, fset.Position(fd.Pos()).Line)
+				if ctxPkgName, ok := imp.importedPath("context"); ok {
+					ra.useCtxArg(fd, ctxPkgName)
+				}
+				if tl.assignable {
+					ra.useAssignableOut()
+				}
+				return ra
+			}
+
+			//	模板 replaceTpl 生成类似的代码：
+			//
+			//		AddDecor := &decor.Context{
+			//		   Kind:       decor.KFunc,
+			//		   TargetName: "Add",
+			//		   Receiver:   "nil",
+			//		   TargetIn:   []any{"a", "b"},
+			//		   TargetOut:  []any{"result"},
+			//		}
+			//		AddDecor.Func = func() {
+			//		   result = Add(a, b)
+			//		}
+			//		AddDecorCall(AddDecor)
+			//		return result
+			//
+			// 返回生成的语句和 genStmts[2] 中对装饰器的调用 "AddDecorCall(AddDecor)"
+			generate := func(ra *ReplaceArgs) ([]ast.Stmt, *ast.CallExpr) {
 				rs, err := replace(ra)
 				if err != nil {
 					logs.Error(err)
 				}
+				genStmts, _, err := getStmtList(rs)
+				if err != nil {
+					logs.Error("getStmtList err", err)
+				}
+				if wrappedCode != nil {
+					wrappedCode.assignPos(genStmts)
+				}
+				// 根据是否有返回值，替换生成的函数体
+				spliceTargetBody(genStmts, ra, fd.Body.List)
+				return genStmts, genStmts[2].(*ast.ExprStmt).X.(*ast.CallExpr)
+			}
 
-				//	模板 replaceTpl 生成类似的代码：
+			// 目标中直接调用的 recover() 改为读取在装饰后的函数开头捕获的值，见 hoistRecover
+			recoverVar, recoverTake := hoistRecover(recovers, gi)
+
+			chainVarName := ""
+			chainTimed := false
+			for _, da := range collDecors {
+				chainTimed = chainTimed || da.chainTimed
+			}
+			if len(collDecors) > 1 && !anyTyped && !allInline {
+				// 多个装饰器共享同一个 Context ，由 decor.Invoke 从最外层开始依次执行：
 				//
 				//		AddDecor := &decor.Context{
-				//		   Kind:       decor.KFunc,
-				//		   TargetName: "Add",
-				//		   Receiver:   "nil",
-				//		   TargetIn:   []any{"a", "b"},
-				//		   TargetOut:  []any{"result"},
+				//		   ...
+				//		   Chain: decor.NewChainState(2), // 有装饰器读取 ChainTimings 时
 				//		}
-				//		AddDecor.Func = func() {
-				//		   result = Add(a, b)
-				//		}
-				//		AddDecorCall(AddDecor)
-				//		return result
+				//		...
+				//		decor.Invoke(AddDecor, func(c *decor.Context) { outer(c) }, func(c *decor.Context) { inner(c, "msg") })
 				//
-				genStmts, _, err := getStmtList(rs)
+				ra := newRA("decor.Invoke", nil)
+				ra.ChainLayers, ra.ChainTimed = len(collDecors), chainTimed
+				ra.HaveDecorParam = true
+				for i := len(collDecors) - 1; i >= 0; i-- {
+					da := collDecors[i]
+					ra.Timed = ra.Timed || da.timed
+					c := gi.nextStr()
+					args := append([]string{c}, da.callParams...)
+					call := fmt.Sprintf("%s(%s)", da.name, strings.Join(args, ", "))
+					ra.DecorCallParams = append(ra.DecorCallParams,
+						fmt.Sprintf("func(%s *decor.Context) { %s }", c, toggleCallText("decor", toggleName(packageName, da), c, call)))
+				}
+				genStmts, ce := generate(ra)
+				outermost := collDecors[len(collDecors)-1]
+				assignCorrectPos(outermost.doc, ce)
+				// 每一层的闭包指向各自的注释
+				for i, arg := range ce.Args[1:] {
+					assignStmtPos(arg, collDecors[len(collDecors)-1-i].doc, true)
+				}
+				fd.Body.List = genStmts
+				updated = true
+			} else {
+				// 只有一个装饰器，或有类型化的上下文、所有装饰器都内联时逐层嵌套改写，
+				// 链式装饰的各层共享同一个 *decor.ChainState ，用来共享 Values 和 Stop ，有装饰器读取 ChainTimings 时
+				// 也记录每一层的耗时。内联的装饰器没有链式装饰的状态。
+				if len(collDecors) > 1 && !allInline {
+					chainVarName = gi.nextStr()
+				}
+				for i, da := range collDecors {
+					ra := newRA(da.name, da.callParams)
+					ra.ChainVarName, ra.ChainLayer, ra.ChainTimed = chainVarName, len(collDecors)-1-i, chainTimed
+					ra.Timed = da.timed
+					if da.typed {
+						if err := ra.useTypedContext(da.typedIn, da.typedOut); err != nil {
+							// 目标函数已经部分改写，但有错误时不会写入改写后的文件
+							diags.add(da.doc.Pos(), da.name, err)
+							return
+						}
+					}
+					genStmts, ce := generate(ra)
+					assignCorrectPos(da.doc, ce)
+					genStmts[2] = toggleStmt("decor", toggleName(packageName, da), ra.DecorVarName, genStmts[2])
+					if allInline {
+						pre, post, err := da.inline.stmts(gi, ra.DecorVarName, da.callParams, inlineRefs[i], da.doc.Pos())
+						if err != nil {
+							diags.add(da.doc.Pos(), da.name, err)
+							return
+						}
+						genStmts = inlineTarget(genStmts, ra, da.inline, pre, post)
+					}
+
+					fd.Body.List = genStmts
+					//x.Body.Rbrace = x.Body.Lbrace + token.Pos(ofs)
+					//log.Printf("fd.Body.Pos() %+v\n", fd.Body.Pos())
+					updated = true
+				}
+			}
+
+			// 在最外层之前创建链式装饰共享的状态，不记录耗时时不需要为每一层分配空间
+			if chainVarName != "" {
+				newChain := fmt.Sprintf("decor.NewChainState(%d)", len(collDecors))
+				if !chainTimed {
+					newChain = "&decor.ChainState{}"
+				}
+				stmts, _, err := getStmtList(fmt.Sprintf("%s := %s", chainVarName, newChain))
 				if err != nil {
 					logs.Error("getStmtList err", err)
 				}
+				fd.Body.List = append(stmts, fd.Body.List...)
+			}
 
-				if wcf, ok := pkg.Files[decorWrappedCodeFilePath]; ok {
-					assignWrappedCodePos(genStmts, wcf.Decls[0].(*ast.FuncDecl).Body.List, wcf.Comments)
+			if recoverVar != "" {
+				head, tail, err := recoverStmts(recoverVar, recoverTake)
+				if err != nil {
+					logs.Error("getStmtList err", err)
 				}
-
-				// 根据是否有返回值，替换生成的函数体
-				// genStmts[1] 对应 "AddDecor.Func = func()..."
-				if len(ra.OutArgNames) == 0 {
-					// non-return
-					genStmts[1].(*ast.AssignStmt).Rhs[0].(*ast.FuncLit).Body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr).Fun.(*ast.FuncLit).Body.List = fd.Body.List
+				// 在返回之前继续没有被目标 recover 的 panic
+				body := fd.Body.List
+				if ret, ok := body[len(body)-1].(*ast.ReturnStmt); ok {
+					body = append(append(body[:len(body)-1:len(body)-1], tail...), ret)
 				} else {
-					// has return
-					genStmts[1].(*ast.AssignStmt).Rhs[0].(*ast.FuncLit).Body.List[0].(*ast.AssignStmt).Rhs[0].(*ast.CallExpr).Fun.(*ast.FuncLit).Body.List = fd.Body.List
+					body = append(body, tail...)
 				}
+				fd.Body.List = append(head, body...)
+			}
 
-				// genStmts[2] 对应 "AddDecorCall(AddDecor)"
-				ce := genStmts[2].(*ast.ExprStmt).X.(*ast.CallExpr)
-				assignCorrectPos(da.doc, ce)
+			// 类型化的上下文和内联的装饰器可能不引用 metaVarName
+			if metaRA != nil && referencesIdent(fd.Body, metaVarName) {
+				metaDecls = append(metaDecls, targetMetaDecl(metaVarName, metaRA))
+			}
 
-				fd.Body.List = genStmts
-				//x.Body.Rbrace = x.Body.Lbrace + token.Pos(ofs)
-				//log.Printf("fd.Body.Pos() %+v\n", fd.Body.Pos())
-				updated = true
+			// 装饰器从最外层到最内层排列
+			register := []string{packageName, inlineTargetName(fd)}
+			for i := len(collDecors) - 1; i >= 0; i-- {
+				register = append(register, collDecors[i].name)
 			}
+			registers = append(registers, register)
+			sourceMapTargets[file] = append(sourceMapTargets[file], sourceMapTarget{
+				Func:       packageName + "." + register[1],
+				Line:       fset.Position(fd.Pos()).Line,
+				Decorators: register[2:],
+			})
+			recordPluginDecl(file, fd, collDecors)
 			return
-		},
-		)
+		}
+		// 装饰值为函数但不是函数字面量的包级变量，在初始化时由 decor.Wrap 包装：
+		//
+		//	//go:decor audit
+		//	var Handler = http.HandlerFunc(serve)
+		//
+		// 改写为
+		//
+		//	var Handler = decor.Wrap("Handler", http.HandlerFunc(serve), func(c *decor.Context) { audit(c) })
+		decorateValue := func(vs *ast.ValueSpec, doc *ast.CommentGroup) (r bool) {
+			collDecors, failed := collectDecorAnnotations(diags, doc, tags)
+			if len(collDecors) == 0 {
+				return
+			}
+			logs.Info("find the entry for using the decorator", friendlyIDEPosition(fset, vs.Pos()))
+
+			pkgDecorName, ok := imp.importedPath(decoratorPackagePath)
+			if !ok {
+				diags.add(vs.Pos(), "", msgDecorPkgNotImported)
+				return
+			} else if pkgDecorName == "_" {
+				imp.pathObjMap[decoratorPackagePath].Name = nil
+				imp.pathMap[decoratorPackagePath] = "decor"
+				pkgDecorName = "decor"
+			}
+			for _, da := range collDecors {
+				resolved, ok := checkDecorUse(diags, f, imp, pkgImports, declared, da, valueTarget(vs), vs.Pos())
+				if !ok {
+					failed = true
+				}
+				if !resolved {
+					continue
+				}
+				// decor.Wrap 在运行时通过反射装饰，只支持 *decor.Context
+				if _, _, typed, err := checkDecorTypedContext(da.pkgPath, da.name); err == nil && typed {
+					diags.add(da.doc.Pos(), da.name, msgDecorTypedOnFuncValue)
+					failed = true
+				}
+			}
+			if failed {
+				return
+			}
+			ce, err := wrapFuncValue(pkgDecorName, packageName, vs, collDecors, newGenIdentId())
+			if err != nil {
+				diags.add(vs.Pos(), "", err)
+				return
+			}
+			vs.Values[0] = ce
+			updated = true
+
+			register := []string{packageName, vs.Names[0].Name}
+			for i := len(collDecors) - 1; i >= 0; i-- {
+				register = append(register, collDecors[i].name)
+			}
+			registers = append(registers, register)
+			sourceMapTargets[file] = append(sourceMapTargets[file], sourceMapTarget{
+				Func:       packageName + "." + register[1],
+				Line:       fset.Position(vs.Pos()).Line,
+				Decorators: register[2:],
+			})
+			return
+		}
+		visitAstDecl(f, decorate)
+		visitAstFuncLitVar(f, decorate)
+		visitAstFuncValueVar(f, decorateValue)
 
 		// 未发生更新，忽略
-		if !updated {
+		if updated {
+			blankUnusedAliasImports(f)
+			pkgDecorName, _ := imp.importedPath(decoratorPackagePath)
+			f.Decls = append(append(f.Decls, metaDecls...), registerInitDecl(pkgDecorName, registers))
+			updatedFiles = append(updatedFiles, file)
+		}
+	}
+	diags.extLints.run(diags)
+	sort.Strings(updatedFiles)
+	return updatedFiles, nil
+}
+
+// 从后向前收集注释组 doc 中的装饰注释，校验注释的解析、重复装饰和保留的参数（priority 、when 、allowMain ），
+// 返回按 priority 从小到大（从内层到外层）排列的装饰器，when 不满足 tags 的装饰器不包括在内（lint 时包括）。
+// 有错误时 failed 为 true ，其余的注释照常收集，以便继续检查。函数和值为函数的包级变量共用。
+func collectDecorAnnotations(diags *packageDiagnostics, doc *ast.CommentGroup, tags map[string]bool) (collDecors []*decorAnnotation, failed bool) {
+	fset := diags.fset
+	mapDecors := newMapV[string, *ast.Comment]()
+	for i := len(doc.List) - 1; i >= 0; i-- {
+		c := doc.List[i]
+		// 例如：
+		// //go:decor logging
+		// //go:decor fun1.DecorHandlerFunc
+		// //go:decor hit#{msg: "message from decor", repeat: true, count: 10, f:1}
+		// 目标函数上的 lint 注释可以和装饰注释混排，由 parseTargetLint 处理，
+		// 构造函数上的 wrap-return 注释装饰的是返回的类型，见 wrapreturn.go
+		if strings.HasPrefix(c.Text, decorLintScanFlag) || strings.HasPrefix(c.Text, decorWrapReturnFlag) {
+			continue
+		}
+		if !strings.HasPrefix(c.Text, decoratorScanFlag) {
+			break
+		}
+		logs.Debug("HIT:", c.Text)
+		decorName, decorArgs, err := parseDecorAndParameters(c.Text[len(decoratorScanFlag):])
+		if err != nil {
+			diags.add(c.Pos(), decorName, err)
+			failed = true
+			continue
+		}
+		// 不许重复修饰
+		if !mapDecors.put(decorName, c) {
+			diags.add(c.Pos(), decorName, "cannot use the same decorator for repeated decoration, repeated:",
+				friendlyIDEPosition(fset, mapDecors.get(decorName).Pos()))
+			failed = true
+			continue
+		}
+		priority, err := takeDecorPriority(decorArgs)
+		if err != nil {
+			diags.add(c.Pos(), decorName, err)
+			failed = true
+		}
+		// when 不满足 -d.tags 时忽略这个装饰器
+		enabled, err := takeDecorWhen(decorArgs, tags)
+		if err != nil {
+			diags.add(c.Pos(), decorName, err)
+			failed = true
+		}
+		allowMain, err := takeDecorAllowMain(decorArgs)
+		if err != nil {
+			diags.add(c.Pos(), decorName, err)
+			failed = true
+		}
+		// lint 时不论 -d.tags 如何，检查所有的装饰器
+		if !enabled && !diags.lint {
+			logs.Info("skip decorator", decorName, "by when", biSymbol, friendlyIDEPosition(fset, c.Pos()))
 			continue
 		}
+		da := newDecorAnnotation(c, decorName, decorArgs)
+		da.priority, da.allowMain = priority, allowMain
+		collDecors = append(collDecors, da)
+	}
+	// 相同的 priority 保持注释的顺序
+	sort.SliceStable(collDecors, func(i, j int) bool {
+		return collDecors[i].priority < collDecors[j].priority
+	})
+	return collDecors, failed
+}
 
-		/// 将修改后的代码写入临时文件，并更新构建参数，使得后续的构建过程使用新的代码文件。
+// 校验装饰器 da 在目标 target 上的用法：查找装饰器所在的包，检查装饰器和参数的绑定、decor-pure 、
+// 废弃、comparable 和 signature ，记录 external lint ，成功时设置 da.pkgPath 和 da.callParams 。
+// 找不到装饰器时 resolved 为 false ，有错误时 ok 为 false 。函数和值为函数的包级变量共用，
+// 值没有声明函数类型时 target.Type.Params 为 nil ，不检查 comparable 和 signature 。
+func checkDecorUse(diags *packageDiagnostics, f *ast.File, imp *importer, pkgImports map[string][]string, declared map[string]bool,
+	da *decorAnnotation, target *ast.FuncDecl, targetPos token.Pos) (resolved, ok bool) {
+	fset := diags.fset
+	// 存储装饰器所在包的路径，为空时表示当前包
+	decorPkgPath := ""
+	// 获取装饰器的包名 x
+	if x := decorX(da.name); x != "" {
+		// 检查当前文件是否已经导入包 x ，如果导入了，获取包的路径 xPath 。
+		if xPath, ok := imp.importedName(x); ok {
+			// 如果 x 包的别名为 "_" ，表示包被匿名导入，需要重置其别名以便使用
+			if name, _ := imp.importedPath(xPath); name == "_" {
+				imp.pathObjMap[xPath].Name = nil // 重写包的导入方式
+				imp.pathMap[xPath] = x           // 设置别名
+			}
+			decorPkgPath = xPath
+		} else if xPath, err := resolveDecorImport(f, imp, pkgImports, declared, x, cmdFlag.AutoImport); err != nil {
+			// 包中的其他文件导入了 x ，-d.autoimport 时自动导入，否则提示
+			diags.add(da.doc.Pos(), da.name, err)
+			return false, false
+		} else if xPath != "" {
+			decorPkgPath = xPath
+		} else if strings.Count(da.name, ".") != 1 {
+			// x.name 中的 x 不是导入的包时，它是当前包的包级变量 x 上的方法（绑定装饰器），由 checkDecorAndGetParam 查找
+			diags.add(da.doc.Pos(), da.name, x, "package not found")
+			return false, false
+		}
+	}
 
-		// 将 AST f 打印到缓冲区
-		var output []byte
-		buffer := bytes.NewBuffer(output)
-		err = printerCfg.Fprint(buffer, fset, f)
-		if err != nil {
-			return errors.New("fprint original code")
+	// 获取指定路径 decorPkgPath 下函数 decorName 的参数信息
+	params, err := checkDecorAndGetParam(imp, decorPkgPath, da.name, da.parameters)
+	if err != nil {
+		diags.add(da.doc.Pos(), da.name, err)
+		return false, false
+	}
+	da.pkgPath, da.callParams = decorPkgPath, params
+	ok = true
+
+	// 装饰器标记了 //go:decor-pure 时，检查它是否引用了包级变量或产生了 I/O
+	if err := checkDecorPure(decorPkgPath, da.name); err != nil {
+		diags.add(da.doc.Pos(), da.name, err)
+		ok = false
+	}
+	// 装饰器标记了 //go:decor-deprecated 时给出警告，-d.strict 时为错误
+	if msg, declPos := checkDecorDeprecated(decorPkgPath, da.name); msg != "" {
+		diags.warn(da.doc.Pos(), da.name, msg, biSymbol,
+			"Target:", friendlyIDEPosition(fset, targetPos), biSymbol,
+			"Decor:", declPos)
+	}
+	if target.Type.Params != nil {
+		// 装饰器标记了 //go:decor-lint comparable: true 时，检查目标函数的参数是否可比较
+		if err := checkDecorComparable(decorPkgPath, da.name, target); err != nil {
+			diags.add(da.doc.Pos(), da.name, err)
+			ok = false
+		}
+		// 装饰器声明了 //go:decor-lint signature 时，检查目标函数的签名
+		if err := checkDecorSignature(decorPkgPath, da.name, target, imp); err != nil {
+			diags.add(da.doc.Pos(), da.name, err)
+			ok = false
+		}
+	}
+	// 装饰器声明了 //go:decor-lint external 时，-d.extlint 下记录这处用法，改写结束后执行外部的 lint 命令
+	if cmdFlag.ExtLint {
+		if err := diags.extLints.add(fset, decorPkgPath, da, target); err != nil {
+			diags.add(da.doc.Pos(), da.name, err)
+			ok = false
 		}
+	}
+	return true, ok
+}
+
+// 改写一个包时发现的装饰器用法错误和警告。compile 不在第一个错误处退出，而是跳过有错误的目标继续检查，
+// 改写结束后把包中所有的错误按位置排序，每行一个（file:line:col: message ，和 lint 子命令相同）一起输出，
+// 再以非 0 状态码退出，一次构建就能看到所有需要修改的地方。-d.errjson 时错误和警告一起以 JSON 输出，见 diagnostic 。
+type packageDiagnostics struct {
+	fset     *token.FileSet
+	issues   []lintIssue
+	lint     bool     // lint 子命令检查时为 true ，警告和错误一样收集到 issues 中
+	extLints extLints // 需要执行 external lint 命令的装饰器用法，改写结束后执行
+}
+
+// 添加一个错误，decorator 为相关的装饰器名称，可以为空
+func (e *packageDiagnostics) add(pos token.Pos, decorator string, v ...any) {
+	e.issues = append(e.issues, lintIssue{
+		pos:       e.fset.Position(pos),
+		msg:       strings.TrimSpace(fmt.Sprintln(v...)),
+		decorator: decorator,
+	})
+}
+
+// 添加一个警告。默认立即通过 logs.Warn 输出 msg 和 detail ；-d.errjson 或 lint 时和错误一起输出，
+// 只保留 msg ，detail 中的位置信息由 pos 表示。-d.strict 时作为错误添加。
+func (e *packageDiagnostics) warn(pos token.Pos, decorator, msg string, detail ...any) {
+	if cmdFlag.Strict {
+		e.add(pos, decorator, msg)
+		return
+	}
+	if !cmdFlag.ErrJSON && !e.lint {
+		logs.Warn(append([]any{msg}, detail...)...)
+		return
+	}
+	e.issues = append(e.issues, lintIssue{pos: e.fset.Position(pos), msg: msg, decorator: decorator, warning: true})
+}
+
+// 合并所有的错误，没有错误时返回 nil 。-d.errjson 时先把所有的诊断写入 stderr ，返回的 error 只包含错误的数量。
+func (e *packageDiagnostics) err(packageName string) error {
+	sortLintIssues(e.issues)
+	if cmdFlag.ErrJSON {
+		if err := writeDiagnostics(os.Stderr, e.issues); err != nil {
+			logs.Warn("fail write diagnostics", err.Error())
+		}
+	}
+	lines := make([]string, 0, len(e.issues))
+	for _, issue := range e.issues {
+		if !issue.warning {
+			lines = append(lines, fmt.Sprintf("%s: %s", issue.pos, issue.msg))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("found %d decorator error(s) in package %s", len(lines), packageName)
+	if cmdFlag.ErrJSON {
+		return errors.New(msg)
+	}
+	return errors.New(msg + ":" + biSymbol + strings.Join(lines, biSymbol))
+}
+
+// 生成注册被装饰函数的 init 函数，运行时可以通过 decor.ListDecorated 查询：
+//
+//	func init() {
+//		decor.Register("main", "datetime", "logging", "appendFile")
+//	}
+//
+// 生成的节点没有位置信息，打印时排在文件的末尾。
+func registerInitDecl(pkgDecorName string, registers [][]string) *ast.FuncDecl {
+	stmts := make([]ast.Stmt, 0, len(registers))
+	for _, register := range registers {
+		args := make([]ast.Expr, 0, len(register))
+		for _, v := range register {
+			args = append(args, &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(v)})
+		}
+		stmts = append(stmts, &ast.ExprStmt{X: &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: ast.NewIdent(pkgDecorName), Sel: ast.NewIdent("Register")},
+			Args: args,
+		}})
+	}
+	return &ast.FuncDecl{
+		Name: ast.NewIdent("init"),
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: stmts},
+	}
+}
+
+// 目标的参数名、返回值名、参数类型、返回值类型和类型参数名在每次调用时都相同，生成为一个包级变量，
+// 每次调用和链式装饰的各层都使用它，不再每次分配：
+//
+//	var _decorGenIdentxxx1 = [5][]string{{"a", "b"}, {"result"}, {"int", "int"}, {"int"}, {}}
+//
+// 生成的节点没有位置信息，打印时排在文件的末尾。
+func targetMetaDecl(name string, ra *ReplaceArgs) *ast.GenDecl {
+	elts := make([]ast.Expr, 0, 5)
+	for _, names := range [][]string{ra.InParamNames, ra.OutParamNames, ra.InArgTypes, ra.OutArgTypes, ra.TypeParams} {
+		lit := &ast.CompositeLit{}
+		for _, name := range names {
+			lit.Elts = append(lit.Elts, &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(name)})
+		}
+		elts = append(elts, lit)
+	}
+	return &ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{&ast.ValueSpec{
+		Names: []*ast.Ident{ast.NewIdent(name)},
+		Values: []ast.Expr{&ast.CompositeLit{
+			Type: &ast.ArrayType{Len: &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(len(elts))}, Elt: &ast.ArrayType{Elt: ast.NewIdent("string")}},
+			Elts: elts,
+		}},
+	}}}
+}
+
+// node 中是否引用了标识符 name
+func referencesIdent(node ast.Node, name string) (found bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return
+}
+
+// 将目标函数的原始函数体放入生成代码的闭包中
+// genStmts[1] 对应 "AddDecor.Func = func()..."
+func spliceTargetBody(genStmts []ast.Stmt, ra *ReplaceArgs, body []ast.Stmt) {
+	if len(ra.OutArgNames) == 0 {
+		// non-return
+		targetCallStmt(genStmts).(*ast.ExprStmt).X.(*ast.CallExpr).Fun.(*ast.FuncLit).Body.List = body
+	} else {
+		// has return
+		targetCallStmt(genStmts).(*ast.AssignStmt).Rhs[0].(*ast.CallExpr).Fun.(*ast.FuncLit).Body.List = body
+	}
+}
 
-		// 写入临时文件
-		tgDir := path.Join(tempDir, os.Getenv("TOOLEXEC_IMPORTPATH"))
-		_ = os.MkdirAll(tgDir, 0777)
-		tmpEntryFile := path.Join(tgDir, filepath.Base(originPath))
-		logs.Debug("originPath", originPath, filepath.Base(originPath))
-		err = os.WriteFile(tmpEntryFile, buffer.Bytes(), 0777)
-		if err != nil {
-			logs.Error("fail write into temporary file", err.Error())
-		}
+// genStmts[1] 的闭包中调用原来的函数体的语句。它是闭包的最后一条语句，目标标记了 once 时之前还有检查 DoRef 的语句
+func targetCallStmt(genStmts []ast.Stmt) ast.Stmt {
+	list := genStmts[1].(*ast.AssignStmt).Rhs[0].(*ast.FuncLit).Body.List
+	return list[len(list)-1]
+}
 
-		// 将原始文件路径替换为临时文件路径
-		for i := range args {
-			if args[i] == originPath {
-				args[i] = tmpEntryFile
+// 收集包内所有 //go:linkname 指令引用的本包函数名，值为指令注释本身。
+//
+//	//go:linkname localname [importpath.name]
+//
+// localname 总是本包的符号；importpath.name 的 importpath 与当前包相同时，name 也是本包的符号。
+func collectLinknames(pkg *astPackage, pkgPath string) map[string]*ast.Comment {
+	linknames := map[string]*ast.Comment{}
+	for _, f := range pkg.Files {
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if !strings.HasPrefix(c.Text, linknameScanFlag) {
+					continue
+				}
+				fields := strings.Fields(c.Text[len(linknameScanFlag):])
+				if len(fields) == 0 {
+					continue
+				}
+				linknames[fields[0]] = c
+				if len(fields) > 1 {
+					if i := strings.LastIndex(fields[1], "."); i > 0 && fields[1][:i] == pkgPath {
+						linknames[fields[1][i+1:]] = c
+					}
+				}
 			}
 		}
-
-		// 记录调试信息
-		logs.Debug("args updated", args)
-		logs.Debug("rewrite file", originPath, "=>", tmpEntryFile)
 	}
+	return linknames
+}
 
-	return nil
+// 被装饰的函数如果被 //go:linkname 引用，通过链接名调用它的代码执行的也是装饰后的版本，
+// 这通常不是链接方所期望的，因此给出警告。
+func warnLinknamed(diags *packageDiagnostics, fd *ast.FuncDecl, linknames map[string]*ast.Comment) bool {
+	if fd.Recv != nil {
+		return false
+	}
+	c, ok := linknames[fd.Name.Name]
+	if !ok {
+		return false
+	}
+	diags.warn(fd.Pos(), "", msgDecorLinknamed, biSymbol,
+		"Target:", friendlyIDEPosition(diags.fset, fd.Pos()), biSymbol,
+		"Linkname:", friendlyIDEPosition(diags.fset, c.Pos()))
+	return true
 }
 
 func decorX(decorName string) string {
 	arr := strings.Split(decorName, ".")
-	if len(arr) != 2 {
-		return ""
+	switch len(arr) {
+	case 2:
+		return arr[0]
+	case 3: // pkg.registry.Logging
+		if arr[1] != "" && arr[2] != "" {
+			return arr[0]
+		}
 	}
-	return arr[0]
+	return ""
 }
 
 func visitAstDecl(f *ast.File, funVisitor func(*ast.FuncDecl) bool) {
@@ -340,7 +1123,198 @@ LOOP:
 	}
 }
 
-func assignWrappedCodePos(from, reset []ast.Stmt, cg []*ast.CommentGroup) {
+// 遍历赋值给包级变量的函数字面量，例如：
+//
+//	//go:decor logging
+//	var handler = func(w http.ResponseWriter, r *http.Request) {}
+//
+// 每个字面量会被转换成一个同名的函数声明交给 funVisitor ，它和字面量共享 Type 和 Body ，
+// 因此 funVisitor 对函数签名和函数体的修改会直接作用在字面量上。
+// 只处理单个变量且值为函数字面量的声明，分组声明 var (...) 中使用各自的注释。
+func visitAstFuncLitVar(f *ast.File, funVisitor func(*ast.FuncDecl) bool) {
+	if f.Decls == nil || funVisitor == nil {
+		return
+	}
+	for _, t := range f.Decls {
+		gd, ok := t.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok || len(vs.Names) != 1 || len(vs.Values) != 1 {
+				continue
+			}
+			lit, ok := vs.Values[0].(*ast.FuncLit)
+			if !ok {
+				continue
+			}
+			doc := vs.Doc
+			if doc == nil && !gd.Lparen.IsValid() {
+				doc = gd.Doc
+			}
+			if funVisitor(&ast.FuncDecl{Doc: doc, Name: vs.Names[0], Type: lit.Type, Body: lit.Body}) {
+				return
+			}
+		}
+	}
+}
+
+// 遍历值为函数但不是函数字面量的包级变量，且注释中有 //go:decor ，例如：
+//
+//	//go:decor audit
+//	var Handler = http.HandlerFunc(serve)
+//
+//	//go:decor logging
+//	var Do func(int) error = do
+//
+// 和 visitAstFuncLitVar 一样只处理单个变量的声明，函数字面量由 visitAstFuncLitVar 处理。
+func visitAstFuncValueVar(f *ast.File, valueVisitor func(*ast.ValueSpec, *ast.CommentGroup) bool) {
+	if f.Decls == nil || valueVisitor == nil {
+		return
+	}
+	for _, t := range f.Decls {
+		gd, ok := t.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs, ok := spec.(*ast.ValueSpec)
+			if !ok || len(vs.Names) != 1 || len(vs.Values) != 1 {
+				continue
+			}
+			if _, ok := vs.Values[0].(*ast.FuncLit); ok {
+				continue
+			}
+			doc := vs.Doc
+			if doc == nil && !gd.Lparen.IsValid() {
+				doc = gd.Doc
+			}
+			if doc == nil || len(doc.List) == 0 || !strings.HasPrefix(doc.List[len(doc.List)-1].Text, decoratorScanFlag) {
+				continue
+			}
+			if valueVisitor(vs, doc) {
+				return
+			}
+		}
+	}
+}
+
+// 值为函数的变量 vs 对应的函数声明，只用于检查。变量声明了函数类型时使用它的签名，
+// 否则签名未知，Type.Params 为 nil 。
+func valueTarget(vs *ast.ValueSpec) *ast.FuncDecl {
+	if ft, ok := vs.Type.(*ast.FuncType); ok {
+		return &ast.FuncDecl{Name: vs.Names[0], Type: ft}
+	}
+	return &ast.FuncDecl{Name: vs.Names[0], Type: &ast.FuncType{Func: vs.Pos()}}
+}
+
+// 生成 decor.Wrap 的调用，collDecors 从内层到外层排列，Wrap 的装饰器从外层到内层排列：
+//
+//	decor.Wrap[T]("Name", value, func(c *decor.Context) { outer(c) }, func(c *decor.Context) { inner(c, "msg") })
+//
+// 变量声明了类型 T 时显式实例化，value 按 T 转换。每层闭包的位置指向各自的注释。
+// packageName 为当前包的导入路径，见 toggleName 。
+func wrapFuncValue(pkgDecorName, packageName string, vs *ast.ValueSpec, collDecors []*decorAnnotation, gi *genIdentId) (*ast.CallExpr, error) {
+	var fun ast.Expr = &ast.SelectorExpr{X: ast.NewIdent(pkgDecorName), Sel: ast.NewIdent("Wrap")}
+	if vs.Type != nil {
+		fun = &ast.IndexExpr{X: fun, Index: vs.Type}
+	}
+	ce := &ast.CallExpr{
+		Fun:  fun,
+		Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(vs.Names[0].Name)}, vs.Values[0]},
+	}
+	for i := len(collDecors) - 1; i >= 0; i-- {
+		da := collDecors[i]
+		c := gi.nextStr()
+		args := append([]string{c}, da.callParams...)
+		call := fmt.Sprintf("%s(%s)", da.name, strings.Join(args, ", "))
+		expr, err := parser.ParseExpr(fmt.Sprintf("func(%s *%s.Context) { %s }",
+			c, pkgDecorName, toggleCallText(pkgDecorName, toggleName(packageName, da), c, call)))
+		if err != nil {
+			return nil, err
+		}
+		assignStmtPos(expr, da.doc, true)
+		ce.Args = append(ce.Args, expr)
+	}
+	return ce, nil
+}
+
+// decor/wrapped_code.go 中的 wrappedTargetCode 是生成的代码的参考模板，生成的代码的位置信息指向它，
+// 这样运行时的调用栈中出现的是这个说明性的文件，而不是无意义的位置。
+// 模板在每次 compile 中只索引一次，包中所有被装饰的目标共用。
+type wrappedCodeTemplate struct {
+	body       []ast.Stmt   // wrappedTargetCode 的函数体
+	inArgs     *ast.Comment // 调用目标闭包的实参处的注释 /* varDecorContext.TargetIn[0], ... */
+	outResults *ast.Comment // return 语句的返回值处的注释 /* varDecorContext.TargetOut[0], ... */
+}
+
+// 索引 wrapped_code.go 的语法树 f 。f 为空或者结构和预期的不一致（例如不兼容的 decor 版本）时返回 nil ，
+// 此时生成的代码不设置位置信息。
+//
+// 注释按它们在语法树中的位置查找，而不是在文件中的序号，模板中增删注释不影响查找的结果。
+func newWrappedCodeTemplate(f *ast.File) *wrappedCodeTemplate {
+	if f == nil {
+		return nil
+	}
+	var fd *ast.FuncDecl
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.FuncDecl); ok && d.Name.Name == "wrappedTargetCode" && d.Body != nil {
+			fd = d
+			break
+		}
+	}
+	if fd == nil || len(fd.Body.List) < 3 {
+		return nil
+	}
+	body := fd.Body.List
+	ctx, ok := body[0].(*ast.AssignStmt)
+	if !ok || len(ctx.Lhs) != 1 || len(ctx.Rhs) != 1 {
+		return nil
+	}
+	if cl, ok := ctx.Rhs[0].(*ast.CompositeLit); !ok || len(cl.Elts) == 0 {
+		return nil
+	}
+	fn, ok := body[1].(*ast.AssignStmt)
+	if !ok || len(fn.Lhs) != 1 || len(fn.Rhs) != 1 {
+		return nil
+	}
+	lit, ok := fn.Rhs[0].(*ast.FuncLit)
+	if !ok || len(lit.Body.List) == 0 {
+		return nil
+	}
+	es, ok := lit.Body.List[0].(*ast.ExprStmt)
+	if !ok {
+		return nil
+	}
+	call, ok := es.X.(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	ret, ok := body[len(body)-1].(*ast.ReturnStmt)
+	if !ok {
+		return nil
+	}
+	return &wrappedCodeTemplate{
+		body:       body,
+		inArgs:     commentWithin(f.Comments, call.Lparen, call.Rparen),
+		outResults: commentWithin(f.Comments, ret.Return, fd.Body.Rbrace),
+	}
+}
+
+// comments 中第一个位于 (from, to) 之间的注释，没有时返回 nil
+func commentWithin(comments []*ast.CommentGroup, from, to token.Pos) *ast.Comment {
+	for _, cg := range comments {
+		if cg.Pos() > from && cg.End() < to && len(cg.List) > 0 {
+			return cg.List[0]
+		}
+	}
+	return nil
+}
+
+// 把生成的语句 from 的位置信息设置为模板中对应的位置
+func (t *wrappedCodeTemplate) assignPos(from []ast.Stmt) {
+	reset := t.body
 	{
 		partFrom := from[0].(*ast.AssignStmt)
 		partReset := reset[0].(*ast.AssignStmt)
@@ -354,11 +1328,13 @@ func assignWrappedCodePos(from, reset []ast.Stmt, cg []*ast.CommentGroup) {
 			l.Lbrace = r.Lbrace
 			l.Rbrace = r.Rbrace
 			assignStmtPos(l.Type, r.Type, true)
-			//l.Type.(*ast.SelectorExpr).X.(*ast.Ident).NamePos = r.Type.(*ast.Ident).NamePos
 			for i, kv := range l.Elts {
-				rv := r.Elts[i].(*ast.KeyValueExpr)
-				v := kv.(*ast.KeyValueExpr)
-				assignStmtPos(v, rv, true)
+				// 生成的字段多于模板中的字段时，其余的字段指向模板的最后一个字段
+				rv := r.Elts[len(r.Elts)-1]
+				if i < len(r.Elts) {
+					rv = r.Elts[i]
+				}
+				assignStmtPos(kv, rv, true)
 			}
 		}
 	}
@@ -366,49 +1342,34 @@ func assignWrappedCodePos(from, reset []ast.Stmt, cg []*ast.CommentGroup) {
 		partFrom := from[1].(*ast.AssignStmt)
 		partReset := reset[1].(*ast.AssignStmt)
 		assignStmtPos(partFrom.Lhs[0], partReset.Lhs[0], true)
-		//partFrom.Lhs[0].(*ast.SelectorExpr).X.(*ast.Ident).NamePos = partReset.Lhs[0].(*ast.SelectorExpr).X.(*ast.Ident).NamePos
-		//partFrom.Lhs[0].(*ast.SelectorExpr).Sel.NamePos = partReset.Lhs[0].(*ast.SelectorExpr).Sel.NamePos
 		partFrom.Tok = partReset.Tok
-		//partFrom.Rhs[0].(*ast.FuncLit)
 		assignStmtPos(partFrom.Rhs[0], partReset.Rhs[0], true)
 		var flit *ast.CallExpr
 		r := partReset.Rhs[0].(*ast.FuncLit).Body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr)
-		if astmt, ok := partFrom.Rhs[0].(*ast.FuncLit).Body.List[0].(*ast.AssignStmt); ok {
+		if astmt, ok := targetCallStmt(from).(*ast.AssignStmt); ok {
 			assignStmtPos(astmt.Lhs[0], r, true)
-			flit = partFrom.Rhs[0].(*ast.FuncLit).Body.List[0].(*ast.AssignStmt).Rhs[0].(*ast.CallExpr)
+			flit = astmt.Rhs[0].(*ast.CallExpr)
 		} else {
-			flit = partFrom.Rhs[0].(*ast.FuncLit).Body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr)
+			flit = targetCallStmt(from).(*ast.ExprStmt).X.(*ast.CallExpr)
 		}
-		//flit.Lparen = r.Lparen
-		//TODO
-		if flit.Args != nil {
-			inParams := getIndexComment(cg, 12)
+		if flit.Args != nil && t.inArgs != nil {
 			for _, arg := range flit.Args {
-				assignStmtPos(arg, inParams, true)
+				assignStmtPos(arg, t.inArgs, true)
 			}
 		}
 	}
 	// has-return
-	if len(from) > 3 {
-		l := from[3].(*ast.ReturnStmt)
-		r := reset[2].(*ast.ReturnStmt)
+	if l, ok := from[len(from)-1].(*ast.ReturnStmt); ok && len(from) > 3 {
+		r := reset[len(reset)-1].(*ast.ReturnStmt)
 		l.Return = r.Return
-		outParams := getIndexComment(cg, 14)
-		if l.Results != nil && outParams != nil {
+		if l.Results != nil && t.outResults != nil {
 			for _, v := range l.Results {
-				assignStmtPos(v, outParams, true)
+				assignStmtPos(v, t.outResults, true)
 			}
 		}
 	}
 }
 
-func getIndexComment(cg []*ast.CommentGroup, index int) *ast.Comment {
-	if len(cg) > index && cg[index] != nil && cg[index].List != nil && len(cg[index].List) > 0 {
-		return cg[index].List[0]
-	}
-	return nil
-}
-
 // Reset the line of the behavior annotation where the decorator call is located
 func assignCorrectPos(doc *ast.Comment, ce *ast.CallExpr) {
 	ce.Lparen = doc.Pos()
@@ -474,7 +1435,8 @@ func typeDeclVisitor(decls []ast.Decl, fn func(*ast.TypeSpec, *ast.CommentGroup)
 	}
 }
 
-func typeDecorRebuild(pkg *ast.Package) (pos token.Pos, err error) {
+func typeDecorRebuild(pkg *astPackage) (pos token.Pos, err error) {
+	uncoveredPromotions = nil
 	// 从注释组中提取以特定前缀（decoratorScanFlag）开头的装饰器注释。
 	findAndCollDecorComments := func(cg *ast.CommentGroup) []*ast.Comment {
 		// 从后向前收集以 "//go:decor " 开头的注释
@@ -493,7 +1455,10 @@ func typeDecorRebuild(pkg *ast.Package) (pos token.Pos, err error) {
 	}
 
 	// 存储每个类型对应的装饰器注释。键是类型名，值是注释列表。
-	typeNameMapDecorComments := map[string][]*ast.Comment{}
+	typeNameMapDecorComments := map[string][]*typeDecorComment{}
+
+	// 函数上的 //go:decor-wrap-return 注释，解析出返回的类型后并入 typeNameMapDecorComments
+	var wrapReturns []*wrapReturnComment
 
 	// 存储错误信息，包括位置和错误详情
 	type errSet struct {
@@ -504,6 +1469,7 @@ func typeDecorRebuild(pkg *ast.Package) (pos token.Pos, err error) {
 
 	// 遍历包中的每个文件
 	for _, f := range pkg.Files {
+		wrapReturns = append(wrapReturns, collectWrapReturns(f)...)
 		// 遍历每个文件中的每个类型声明
 		typeDeclVisitor(f.Decls, func(spec *ast.TypeSpec, typeDoc *ast.CommentGroup) {
 			// 如果类型声明 (spec.Doc) 和类型注释 (typeDoc) 都不存在或为空，则返回。
@@ -545,8 +1511,17 @@ func typeDecorRebuild(pkg *ast.Package) (pos token.Pos, err error) {
 				})
 				return
 			}
-			// 保存类型名称的注释
-			typeNameMapDecorComments[spec.Name.Name] = comments
+			// 取出方法过滤参数，保存类型名称的注释
+			tdcs := make([]*typeDecorComment, 0, len(comments))
+			for _, c := range comments {
+				tdc, err := newTypeDecorComment(c)
+				if err != nil {
+					errs = append(errs, &errSet{pos: c.Pos(), err: err})
+					return
+				}
+				tdcs = append(tdcs, tdc)
+			}
+			typeNameMapDecorComments[spec.Name.Name] = tdcs
 		})
 		if len(errs) > 0 {
 			return errs[0].pos, errs[0].err
@@ -555,7 +1530,7 @@ func typeDecorRebuild(pkg *ast.Package) (pos token.Pos, err error) {
 
 	//log.Printf("typeNameMapDecorComments: %+v \n", typeNameMapDecorComments)
 	//log.Printf("errs: %+v \n", errs)
-	if len(typeNameMapDecorComments) == 0 {
+	if len(typeNameMapDecorComments) == 0 && len(wrapReturns) == 0 {
 		return
 	}
 
@@ -607,22 +1582,191 @@ func typeDecorRebuild(pkg *ast.Package) (pos token.Pos, err error) {
 		return ""
 	}
 
+	// 为嵌入字段提升的方法生成转发的方法，它们只使用 promoted 的注释，见 promotedMethodDecls
+	types := map[string]*ast.TypeSpec{}
+	methods := map[string][]*ast.FuncDecl{}
+	methodFiles := map[*ast.FuncDecl]*ast.File{}
+	for _, f := range pkg.Files {
+		typeDeclVisitor(f.Decls, func(spec *ast.TypeSpec, _ *ast.CommentGroup) {
+			types[spec.Name.Name] = spec
+		})
+	}
+	// 类型别名 type A = B 、type IntBox = Box[int] 解析为包中声明的类型 B 、Box ，
+	// 别名链 A = B 、B = C 一直解析到最终的类型。
+	// 别名指向其他包中的类型或非具名的类型时返回 false 。不是别名的类型名原样返回。
+	resolveAlias := func(name string) (string, bool) {
+		for i := 0; i <= len(types); i++ {
+			spec, ok := types[name]
+			if !ok || !spec.Assign.IsValid() {
+				return name, true
+			}
+			switch spec.Type.(type) {
+			case *ast.Ident, *ast.IndexExpr, *ast.IndexListExpr:
+				name = identName(spec.Type)
+			default:
+				return "", false
+			}
+			if name == "" {
+				return "", false
+			}
+			if _, ok := types[name]; !ok {
+				// 预声明的类型，如 type A = int
+				return "", false
+			}
+		}
+		// 循环的别名，由编译器报告错误
+		return "", false
+	}
+	// 别名链中第一个指向泛型的实例化类型的别名所指的类型，如 type IntBox = Box[int] 的 Box[int] ，没有时为空。
+	// 方法声明在泛型类型上，这样的别名上的注释会装饰所有实例的方法，因此不允许。
+	aliasInstance := func(name string) string {
+		for i := 0; i <= len(types); i++ {
+			spec, ok := types[name]
+			if !ok || !spec.Assign.IsValid() {
+				return ""
+			}
+			var tparams []string
+			if spec.TypeParams != nil {
+				for _, field := range spec.TypeParams.List {
+					for _, id := range field.Names {
+						tparams = append(tparams, id.Name)
+					}
+				}
+			}
+			if instantiatedType(spec.Type, tparams) {
+				return typeString(spec.Type)
+			}
+			if name = identName(spec.Type); name == "" {
+				return ""
+			}
+		}
+		return ""
+	}
+	for _, f := range pkg.Files {
+		pkgDecorName, _ := newImporter(f).importedPath(decoratorPackagePath)
+		if pkgDecorName == "_" {
+			pkgDecorName = "decor"
+		}
+		visitAstDecl(f, func(decl *ast.FuncDecl) bool {
+			if decl.Recv == nil || len(decl.Recv.List) != 1 || funIsBoundDecorator(decl, pkgDecorName) {
+				return false
+			}
+			if name, _ := resolveAlias(identName(decl.Recv.List[0].Type)); name != "" {
+				methods[name] = append(methods[name], decl)
+				methodFiles[decl] = f
+			}
+			return false
+		})
+	}
+	// 构造函数上的注释等同于它返回的类型（别名解析为它所指的类型）上的注释，相同的注释只保留一个
+	for _, wr := range wrapReturns {
+		name, inst := "", ""
+		if results := wr.fd.Type.Results; results != nil && len(results.List) > 0 {
+			typ := results.List[0].Type
+			if instantiatedType(typ, typeParamNames(wr.fd)) {
+				inst = typeString(typ)
+			} else {
+				inst = aliasInstance(identName(typ))
+			}
+			name, _ = resolveAlias(identName(typ))
+		}
+		if inst != "" {
+			return wr.comment.Pos(), errors.New(wr.errorMsg("returns the instantiated generic type " + inst +
+				", whose methods are shared by every instantiation, decorate the generic type instead"))
+		}
+		if spec, ok := types[name]; !ok {
+			return wr.comment.Pos(), errors.New(wr.errorMsg("must return T or *T, T is a type declared in this package"))
+		} else if _, ok := spec.Type.(*ast.InterfaceType); ok {
+			return wr.comment.Pos(), errors.New(wr.errorMsg("returns the interface " + name + ", whose methods can't be decorated"))
+		}
+		tdc, err := newTypeDecorComment(wr.comment)
+		if err != nil {
+			return wr.comment.Pos(), err
+		}
+		if !hasTypeDecorComment(typeNameMapDecorComments[name], tdc) {
+			typeNameMapDecorComments[name] = append(typeNameMapDecorComments[name], tdc)
+		}
+	}
+	// 别名上的注释装饰它所指的类型的方法。Go 不允许在泛型的实例化类型上声明方法，
+	// type IntBox = Box[int] 上的注释会装饰 Box 所有实例的方法，超出了注释的范围，因此报告错误。
+	aliasNames := make([]string, 0)
+	for name := range typeNameMapDecorComments {
+		if spec, ok := types[name]; ok && spec.Assign.IsValid() {
+			aliasNames = append(aliasNames, name)
+		}
+	}
+	sort.Strings(aliasNames)
+	for _, name := range aliasNames {
+		base, ok := resolveAlias(name)
+		if !ok {
+			spec := types[name]
+			return spec.Name.NamePos, errors.New(fmt.Sprintf("type %s is an alias of %s, which is not declared in this package, its methods can't be decorated",
+				name, typeString(spec.Type)))
+		}
+		if inst := aliasInstance(name); inst != "" {
+			return types[name].Name.NamePos, errors.New(fmt.Sprintf("type %s is an alias of the instantiated generic type %s, "+
+				"its methods are shared by every instantiation and can't be decorated for %s alone, decorate the generic type instead",
+				name, inst, inst))
+		}
+		typeNameMapDecorComments[base] = append(typeNameMapDecorComments[base], typeNameMapDecorComments[name]...)
+		delete(typeNameMapDecorComments, name)
+	}
+	typeNames := make([]string, 0, len(typeNameMapDecorComments))
+	for name := range typeNameMapDecorComments {
+		typeNames = append(typeNames, name)
+	}
+	sort.Strings(typeNames)
+	generated := map[*ast.FuncDecl]bool{}
+	for _, name := range typeNames {
+		spec, ok := types[name]
+		if !ok {
+			continue
+		}
+		for f, decls := range promotedMethodDecls(spec, typeNameMapDecorComments[name], types, methods, methodFiles) {
+			for _, decl := range decls {
+				generated[decl] = true
+				f.Decls = append(f.Decls, decl)
+			}
+		}
+	}
+
 	// 遍历包中的每个文件
 	for _, f := range pkg.Files {
+		pkgDecorName, _ := newImporter(f).importedPath(decoratorPackagePath)
+		if pkgDecorName == "_" {
+			pkgDecorName = "decor"
+		}
 		// 遍历文件中的每个声明，寻找函数声明 (ast.FuncDecl)
 		visitAstDecl(f, func(decl *ast.FuncDecl) (r bool) {
 			// 确保函数是一个方法（即，必须有一个接收者），检查接收者列表是否存在且仅有一个接收者。
 			if decl.Recv == nil || decl.Recv.List == nil || len(decl.Recv.List) != 1 || decl.Recv.List[0].Type == nil {
 				return
 			}
-			// 获取接收者类型的名称。
-			typeIdName := identName(decl.Recv.List[0].Type)
+			// 类型上的绑定装饰器方法不会被装饰
+			if funIsBoundDecorator(decl, pkgDecorName) || hasUnsupportedDirective(decl) {
+				return
+			}
+			// 获取接收者类型的名称，接收者的类型是别名时为它所指的类型。
+			typeIdName, _ := resolveAlias(identName(decl.Recv.List[0].Type))
 			if typeIdName == "" {
 				return
 			}
 			// 查找该类型的装饰器注释，如果找不到或注释列表为空，则返回
-			comments, ok := typeNameMapDecorComments[typeIdName]
-			if !ok || len(comments) == 0 {
+			tdcs, ok := typeNameMapDecorComments[typeIdName]
+			if !ok || len(tdcs) == 0 {
+				return
+			}
+			// 只保留 methods/exclude 匹配当前方法的注释
+			comments := make([]*ast.Comment, 0, len(tdcs))
+			for _, tdc := range tdcs {
+				if generated[decl] && !tdc.promoted {
+					continue
+				}
+				if tdc.match(decl.Name.Name) {
+					comments = append(comments, tdc.comment)
+				}
+			}
+			if len(comments) == 0 {
 				return
 			}
 			//log.Printf("decl: %+v, comments: %+v\n", decl, comments)
@@ -641,6 +1785,326 @@ func typeDecorRebuild(pkg *ast.Package) (pos token.Pos, err error) {
 	return
 }
 
+// 类型表达式 expr （去掉 * 后）是否为泛型的实例化类型，如 Box[int] 。类型实参都是 tparams 中的类型参数时不是，
+// 如泛型构造函数 func NewBox[T any]() *Box[T] 的返回值，它对应 Box 的所有实例。
+func instantiatedType(expr ast.Expr, tparams []string) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	var indices []ast.Expr
+	switch x := expr.(type) {
+	case *ast.IndexExpr:
+		indices = []ast.Expr{x.Index}
+	case *ast.IndexListExpr:
+		indices = x.Indices
+	}
+	for _, index := range indices {
+		id, ok := index.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		found := false
+		for _, name := range tparams {
+			found = found || name == id.Name
+		}
+		if !found {
+			return true
+		}
+	}
+	return false
+}
+
+// 类型上的装饰注释，可以通过保留的 methods/exclude 参数限定装饰哪些方法：
+//
+//	//go:decor logging#{methods: "Get*,Set*", exclude: "String"}
+//	type T struct{}
+//
+// methods 和 exclude 都是逗号分隔的方法名，支持 path.Match 的通配符，不能为空。
+// 没有 methods 时匹配所有方法，exclude 匹配的方法总是被排除。
+// 这两个参数不传给装饰器，comment 为去掉它们之后的注释。
+// 保留参数 promoted: true 让注释同样装饰嵌入字段提升的方法，见 promotedMethodDecls 。
+type typeDecorComment struct {
+	comment *ast.Comment
+	methods,
+	exclude []string
+	promoted bool
+}
+
+func newTypeDecorComment(c *ast.Comment) (*typeDecorComment, error) {
+	tdc := &typeDecorComment{comment: c}
+	name, parameters, err := parseDecorAndParameters(c.Text[len(decoratorScanFlag):])
+	if err != nil {
+		// 语法错误在装饰方法时报告
+		return tdc, nil
+	}
+	found := false
+	if value, ok := parameters[typeDecorPromotedKey]; ok {
+		found = true
+		delete(parameters, typeDecorPromotedKey)
+		switch value {
+		case "true":
+			tdc.promoted = true
+		case "false":
+		default:
+			return nil, errors.New("type decorator " + typeDecorPromotedKey + " must be true or false, but got " + value)
+		}
+	}
+	for _, v := range []struct {
+		key      string
+		patterns *[]string
+	}{
+		{typeDecorMethodsKey, &tdc.methods},
+		{typeDecorExcludeKey, &tdc.exclude},
+	} {
+		value, ok := parameters[v.key]
+		if !ok {
+			continue
+		}
+		found = true
+		delete(parameters, v.key)
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, errors.New("type decorator " + v.key + " must be a string, but got " + value)
+		}
+		for _, pattern := range strings.Split(s, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.New("type decorator " + v.key + " has an invalid pattern: " + pattern)
+			}
+			*v.patterns = append(*v.patterns, pattern)
+		}
+		// methods: "" 不应该匹配所有的方法，exclude: "" 也多半是写错了
+		if len(*v.patterns) == 0 {
+			return nil, errors.New("type decorator " + v.key + " must list at least one method name, but got " + value)
+		}
+	}
+	if found {
+		tdc.comment = &ast.Comment{Slash: c.Slash, Text: decoratorScanFlag + decorAnnotationText(name, parameters)}
+	}
+	return tdc, nil
+}
+
+// 方法 name 是否需要使用这个装饰注释
+func (t *typeDecorComment) match(name string) bool {
+	matchAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if t.methods != nil && !matchAny(t.methods) {
+		return false
+	}
+	return !matchAny(t.exclude)
+}
+
+// 由装饰器名和参数重新生成装饰注释的内容，例如 logging#{level: "debug"} ，参数按名称排序
+func decorAnnotationText(name string, parameters map[string]string) string {
+	if len(parameters) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(parameters))
+	for k := range parameters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, k+": "+parameters[k])
+	}
+	return name + "#{" + strings.Join(kvs, ", ") + "}"
+}
+
+// 批量装饰指令，给文件中名称匹配 pattern 的函数和方法使用装饰器，写在 doc.go 中时作用于整个包：
+//
+//	//go:decor-all ^(Get|Set) logging#{level: "debug"}
+//
+// pattern 为正则表达式，和 go test -run 一样只要部分匹配即可，方法的名称为 Type.Method 。
+// 指令必须写在不属于任何声明的注释中，装饰器所在的包通过指令所在文件的导入确定。
+type decorAllDirective struct {
+	comment *ast.Comment
+	pattern *regexp.Regexp
+	decor   string // 装饰注释的内容，如 logging#{level: "debug"}
+	name    string // 装饰器名
+	pkgPath string // 装饰器所在的包，当前包时为空
+}
+
+func newDecorAllDirective(c *ast.Comment, imp *importer) (*decorAllDirective, error) {
+	text := strings.TrimSpace(c.Text[len(decorAllScanFlag):])
+	i := strings.IndexAny(text, " \t")
+	if i < 0 {
+		return nil, errors.New("usage: " + decorAllScanFlag + "<pattern> <decorator>")
+	}
+	re, err := regexp.Compile(text[:i])
+	if err != nil {
+		return nil, errors.New("decor-all has an invalid pattern: " + err.Error())
+	}
+	decor := strings.TrimSpace(text[i:])
+	name, _, err := parseDecorAndParameters(decor)
+	if err != nil {
+		return nil, err
+	}
+	d := &decorAllDirective{comment: c, pattern: re, decor: decor, name: name}
+	if x := decorX(name); x != "" {
+		if xPath, ok := imp.importedName(x); ok {
+			d.pkgPath = xPath
+		} else if strings.Count(name, ".") != 1 {
+			return nil, errors.New(x + " package not found")
+		}
+	}
+	return d, nil
+}
+
+// 收集文件 f 中的 //go:decor-all 指令
+func collectDecorAllDirectives(f *ast.File) ([]*decorAllDirective, token.Pos, error) {
+	// 声明的文档注释不能使用指令
+	attached := map[*ast.CommentGroup]bool{}
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			attached[decl.Doc] = true
+		case *ast.GenDecl:
+			attached[decl.Doc] = true
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					attached[spec.Doc] = true
+				case *ast.ValueSpec:
+					attached[spec.Doc] = true
+				case *ast.ImportSpec:
+					attached[spec.Doc] = true
+				}
+			}
+		}
+	}
+	inDecl := func(pos token.Pos) bool {
+		for _, decl := range f.Decls {
+			if decl.Pos() <= pos && pos < decl.End() {
+				return true
+			}
+		}
+		return false
+	}
+	var imp *importer
+	var directives []*decorAllDirective
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, decorAllScanFlag) {
+				continue
+			}
+			if attached[cg] || inDecl(c.Pos()) {
+				return nil, c.Pos(), errors.New("decor-all must be used in a file-level comment, not on a declaration")
+			}
+			if imp == nil {
+				imp = newImporter(f)
+			}
+			d, err := newDecorAllDirective(c, imp)
+			if err != nil {
+				return nil, c.Pos(), err
+			}
+			directives = append(directives, d)
+		}
+	}
+	return directives, token.NoPos, nil
+}
+
+// 按 //go:decor-all 指令给包 pkg 中匹配的函数添加 //go:decor 注释，并导入需要的包。
+// 函数上已经使用了同名的装饰器时以函数上的注释为准，文件中的指令优先于 doc.go 中的指令。
+// skipFile 为不处理的文件（wrapped_code.go），可以为空。
+func decorAllRebuild(pkg *astPackage, skipFile string) (pos token.Pos, err error) {
+	files := make([]string, 0, len(pkg.Files))
+	for file := range pkg.Files {
+		if file != skipFile {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	var pkgDirectives []*decorAllDirective
+	fileDirectives := map[string][]*decorAllDirective{}
+	for _, file := range files {
+		directives, pos, err := collectDecorAllDirectives(pkg.Files[file])
+		if err != nil {
+			return pos, err
+		}
+		if filepath.Base(file) == "doc.go" {
+			pkgDirectives = append(pkgDirectives, directives...)
+		} else {
+			fileDirectives[file] = directives
+		}
+	}
+
+	for _, file := range files {
+		directives := fileDirectives[file]
+		if !strings.HasSuffix(file, "_test.go") {
+			directives = append(directives, pkgDirectives...)
+		}
+		if len(directives) == 0 {
+			continue
+		}
+		injectDecorComments(pkg.Files[file], func(fd *ast.FuncDecl) (injects []decorInjection) {
+			name := funcDeclName(fd)
+			for _, d := range directives {
+				if !d.pattern.MatchString(name) {
+					continue
+				}
+				inj := decorInjection{name: d.name, text: d.decor}
+				if d.pkgPath != "" {
+					inj.imports = []string{d.pkgPath}
+				}
+				injects = append(injects, inj)
+			}
+			return injects
+		})
+	}
+	return token.NoPos, nil
+}
+
+// 要注入到函数上的一个装饰器注释
+type decorInjection struct {
+	name    string   // 装饰器名称，用于和函数上已有的注释去重
+	text    string   // //go:decor 之后的注释内容
+	imports []string // 需要匿名导入的包
+}
+
+// 给文件 f 中 match 返回了装饰器的函数添加 //go:decor 注释，并匿名导入需要的包。
+// 函数上已经使用了同名的装饰器时跳过，同名的装饰器只添加第一个。
+func injectDecorComments(f *ast.File, match func(fd *ast.FuncDecl) []decorInjection) {
+	pkgDecorName, _ := newImporter(f).importedPath(decoratorPackagePath)
+	if pkgDecorName == "" || pkgDecorName == "_" {
+		pkgDecorName = "decor"
+	}
+	imports := map[string]bool{}
+	visitAstDecl(f, func(fd *ast.FuncDecl) bool {
+		// 程序的入口需要显式地使用 allowMain 装饰
+		if fd.Body == nil || funIsDecorator(fd, pkgDecorName) || funIsBoundDecorator(fd, pkgDecorName) || isEntryFunc(f, fd) || hasUnsupportedDirective(fd) {
+			return false
+		}
+		used := usedDecorNames(fd)
+		for _, inj := range match(fd) {
+			if used[inj.name] {
+				continue
+			}
+			used[inj.name] = true
+			if fd.Doc == nil {
+				fd.Doc = &ast.CommentGroup{}
+			}
+			fd.Doc.List = append(fd.Doc.List, &ast.Comment{Slash: fd.Pos(), Text: decoratorScanFlag + inj.text})
+			imports[decoratorPackagePath] = true
+			for _, p := range inj.imports {
+				imports[p] = true
+			}
+		}
+		return false
+	})
+	addAnonymousImports(f, imports)
+}
+
 func friendlyIDEPosition(fset *token.FileSet, p token.Pos) string {
 	if runtime.GOOS == "windows" {
 		return fset.Position(p).String()
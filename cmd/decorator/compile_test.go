@@ -1,9 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
+	"go/printer"
 	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -14,6 +22,7 @@ func TestDecorX(t *testing.T) {
 		"x.s",
 		"log.",
 		"decor.Context",
+		"x.registry.Logging",
 	}
 	failCases := []string{
 		"log",
@@ -102,3 +111,873 @@ func inSlice[T comparable](in []T, target T) bool {
 	}
 	return false
 }
+
+func TestWarnLinknamed(t *testing.T) {
+	src := `package main
+import _ "unsafe"
+
+//go:linkname pushed
+//go:decor logging
+func pushed() {}
+
+//go:decor logging
+func pulled() {}
+
+//go:linkname localPulled github.com/dengsgo/go-decorator/cmd/decorator.pulled
+func localPulled()
+
+//go:decor logging
+func normal() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}
+	linknames := collectLinknames(pkg, "github.com/dengsgo/go-decorator/cmd/decorator")
+
+	buffer := bytes.NewBuffer([]byte{})
+	log.SetOutput(buffer)
+	defer log.SetOutput(os.Stderr)
+	result := map[string]bool{
+		"pushed":      true,
+		"pulled":      true,
+		"localPulled": true,
+		"normal":      false,
+	}
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		buffer.Reset()
+		if warnLinknamed(&packageDiagnostics{fset: fset}, fd, linknames) != result[fd.Name.Name] {
+			t.Fatalf("warnLinknamed(%s) should be %+v\n", fd.Name.Name, result[fd.Name.Name])
+		}
+		if result[fd.Name.Name] && !strings.Contains(buffer.String(), msgDecorLinknamed) {
+			t.Fatalf("warnLinknamed(%s) should output warning, but got %s\n", fd.Name.Name, buffer.String())
+		}
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	defer func(c CmdFlag, d string) {
+		*cmdFlag = c
+		tempDir = d
+	}(*cmdFlag, tempDir)
+	cmdFlag.Level = "debug"
+	cmdFlag.TempDir = "/tmp/decorator_config"
+	cmdFlag.EmitInlineReport = true
+	cmdFlag.PrintConfig = true
+	cmdFlag.Output = "gen"
+	tempDir = cmdFlag.TempDir
+
+	s := configString()
+	for _, want := range []string{
+		"d.log                debug",
+		"d.tempDir            /tmp/decorator_config",
+		"tempDir              /tmp/decorator_config",
+		"d.emitInlineReport   true",
+		"d.printConfig        true",
+		"d.output             gen",
+		"decorPrefix          //go:decor",
+		"lintPrefix           //go:decor-lint",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("configString() should contain %q, but got %s\n", want, s)
+		}
+	}
+
+	// decor.toml 中的设置、是否装饰和运行时关闭的装饰器
+	defer func(d string) { projectDir = d }(projectDir)
+	projectDir = t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n",
+		"decor.toml": `prefix = "//acme:decor"
+
+[[rule]]
+packages   = ["./internal/..."]
+exported   = true
+decorators = ["logging"]
+
+[alias]
+log = "example.com/obs.Logging"
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GODECOR_DISABLE", "logging")
+	cmdFlag.Disable = true
+	s = configString()
+	for _, want := range []string{
+		"decorators           skipped (-d.disable)",
+		"decor.toml           " + filepath.Join(projectDir, "decor.toml"),
+		"decor.toml prefix    //acme:decor",
+		"decor.toml rule:3    packages=[./internal/...] funcs=[*] exported=true decorators=[logging]",
+		"decor.toml alias log example.com/obs.Logging",
+		"GODECOR_DISABLE      logging (ignored without -d.toggle)",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("configString() should contain %q, but got %s\n", want, s)
+		}
+	}
+	cmdFlag.Disable, cmdFlag.Toggle = false, true
+	if s = configString(); !strings.Contains(s, "decorators           enabled") || !strings.HasSuffix(s, "GODECOR_DISABLE      logging") {
+		t.Fatalf("configString() should show enabled decorators and GODECOR_DISABLE, but got %s\n", s)
+	}
+}
+
+func TestPrintConfigOnce(t *testing.T) {
+	work := t.TempDir()
+	for i, want := range []bool{true, false, false} {
+		output := filepath.Join(work, fmt.Sprintf("b%03d", i+1), "_pkg_.a")
+		if got := printConfigOnce(output); got != want {
+			t.Fatalf("printConfigOnce(%s) should be %v, but got %v\n", output, want, got)
+		}
+	}
+	if !printConfigOnce(filepath.Join(t.TempDir(), "b001", "_pkg_.a")) || !printConfigOnce("") {
+		t.Fatal("printConfigOnce should print once for every build")
+	}
+}
+
+func TestVisitAstFuncLitVar(t *testing.T) {
+	src := `package main
+
+//go:decor logging
+var a = func() {}
+
+var (
+	//go:decor logging
+	b = func(int) int { return 0 }
+
+	c = 1
+	d, e = func() {}, func() {}
+)
+
+// not a func literal
+var f = a
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal("parse error", err)
+	}
+	var names, docs []string
+	visitAstFuncLitVar(file, func(fd *ast.FuncDecl) bool {
+		names = append(names, fd.Name.Name)
+		if fd.Doc != nil {
+			docs = append(docs, fd.Doc.Text())
+		}
+		// the decl shares Body with the literal
+		fd.Body.List = nil
+		return false
+	})
+	if strings.Join(names, ",") != "a,b" {
+		t.Fatal("visitAstFuncLitVar() names want a,b, but got", names)
+	}
+	if len(docs) != 2 {
+		t.Fatal("visitAstFuncLitVar() should pass the doc of each var, got", docs)
+	}
+	lit := file.Decls[1].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.FuncLit)
+	if lit.Body.List != nil {
+		t.Fatal("visitAstFuncLitVar() changes on the decl should apply to the literal")
+	}
+}
+
+func TestVisitAstFuncValueVar(t *testing.T) {
+	src := `package main
+
+//go:decor audit
+var a = http.HandlerFunc(serve)
+
+// no decorator
+var b = serve
+
+var (
+	//go:decor logging
+	c func(int) error = do
+
+	//go:decor logging
+	d = func() {}
+
+	//go:decor logging
+	e, f = do, do
+)
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal("parse error", err)
+	}
+	var names []string
+	visitAstFuncValueVar(file, func(vs *ast.ValueSpec, doc *ast.CommentGroup) bool {
+		names = append(names, vs.Names[0].Name)
+		return false
+	})
+	if strings.Join(names, ",") != "a,c" {
+		t.Fatal("visitAstFuncValueVar() names want a,c, but got", names)
+	}
+}
+
+func TestWrapFuncValue(t *testing.T) {
+	src := `package main
+
+var A = http.HandlerFunc(serve)
+
+var B func(int) error = do
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal("parse error", err)
+	}
+	doc := &ast.Comment{Text: "//go:decor x"}
+	collDecors := []*decorAnnotation{
+		{doc: doc, name: "inner", callParams: []string{`"msg"`}},
+		{doc: doc, name: "outer"},
+	}
+	cas := []string{
+		`decor.Wrap("A", http.HandlerFunc(serve), func(%[1]s *decor.Context) { outer(%[1]s) }, func(%[2]s *decor.Context) { inner(%[2]s, "msg") })`,
+		`decor.Wrap[func(int) error]("B", do, func(%[1]s *decor.Context) { outer(%[1]s) }, func(%[2]s *decor.Context) { inner(%[2]s, "msg") })`,
+	}
+	for i, want := range cas {
+		vs := file.Decls[i].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+		gi := newGenIdentId()
+		ce, err := wrapFuncValue("decor", "main", vs, collDecors, gi)
+		if err != nil {
+			t.Fatal("wrapFuncValue() error", err)
+		}
+		var buf bytes.Buffer
+		if err := format.Node(&buf, token.NewFileSet(), ce); err != nil {
+			t.Fatal(err)
+		}
+		c1, c2 := gi.ident+"1", gi.ident+"2"
+		// 忽略格式化产生的空白
+		noSpace := func(s string) string { return strings.Join(strings.Fields(s), "") }
+		if got := buf.String(); noSpace(got) != noSpace(fmt.Sprintf(want, c1, c2)) {
+			t.Fatalf("cas[%d] wrapFuncValue() got %s", i, got)
+		}
+	}
+}
+
+func TestTypeDecorRebuildMethodsFilter(t *testing.T) {
+	src := `package main
+
+//go:decor logging#{methods: "Get*, Set*", exclude: "GetSecret", level: "debug"}
+//go:decor timing
+type T struct{}
+
+func (t *T) GetName() {}
+func (t *T) GetSecret() {}
+func (t T) SetName() {}
+func (t T) String() {}
+
+//go:nosplit
+func (t T) Fast() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err != nil {
+		t.Fatal("typeDecorRebuild() error", err)
+	}
+	want := map[string][]string{
+		"GetName":   {`//go:decor logging#{level: "debug"}`, "//go:decor timing"},
+		"GetSecret": {"//go:decor timing"},
+		"SetName":   {`//go:decor logging#{level: "debug"}`, "//go:decor timing"},
+		"String":    {"//go:decor timing"},
+		"Fast":      {"//go:nosplit"},
+	}
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		var got []string
+		for _, c := range fd.Doc.List {
+			got = append(got, c.Text)
+		}
+		if strings.Join(got, "\n") != strings.Join(want[fd.Name.Name], "\n") {
+			t.Fatalf("typeDecorRebuild() %s want %q, but got %q", fd.Name.Name, want[fd.Name.Name], got)
+		}
+	}
+
+	for _, doc := range []string{
+		`//go:decor logging#{methods: 1}`,
+		`//go:decor logging#{methods: ""}`,
+		`//go:decor logging#{methods: " , "}`,
+		`//go:decor logging#{exclude: ""}`,
+		`//go:decor logging#{exclude: "[a"}`,
+		`//go:decor logging#{promoted: 1}`,
+	} {
+		f, err := parser.ParseFile(fset, "main.go", "package main\n"+doc+"\ntype T struct{}\n", parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err == nil {
+			t.Fatal("typeDecorRebuild() should return error", doc)
+		}
+	}
+}
+
+func TestTypeDecorRebuildAlias(t *testing.T) {
+	src := `package main
+
+//go:decor logging
+type Box[T any] struct{}
+
+func (b *Box[T]) Get() {}
+
+type IntBox = Box[int]
+
+//go:decor timing
+type Pair = Box2
+
+type Box2 struct{}
+
+func (b Box2) Len() {}
+
+type Alias2 = PlainAlias
+
+//go:decor tracing
+type Alias3 = Alias2
+
+//go:decor audit
+type Plain struct{}
+
+type PlainAlias = Plain
+
+func (p PlainAlias) Name() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err != nil {
+		t.Fatal("typeDecorRebuild() error", err)
+	}
+	want := map[string][]string{
+		"Get":  {"//go:decor logging"},
+		"Len":  {"//go:decor timing"},
+		"Name": {"//go:decor audit", "//go:decor tracing"},
+	}
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		var got []string
+		if fd.Doc != nil {
+			for _, c := range fd.Doc.List {
+				got = append(got, c.Text)
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(want[fd.Name.Name], "\n") {
+			t.Fatalf("typeDecorRebuild() %s want %q, but got %q", fd.Name.Name, want[fd.Name.Name], got)
+		}
+	}
+
+	for _, decl := range []string{"type T = int", "type T = fmt.Stringer", "type T = *Box[int]"} {
+		f, err := parser.ParseFile(fset, "main.go", "package main\n//go:decor logging\n"+decl+"\ntype Box[T any] struct{}\n", parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err == nil ||
+			!strings.Contains(err.Error(), "not declared in this package") {
+			t.Fatal("typeDecorRebuild() should return error", decl, err)
+		}
+	}
+	// 泛型的实例化类型的别名上的注释会装饰所有的实例
+	for _, decl := range []string{"type T = Box[int]", "type T = Box2[string, int]", "type T = A\ntype A = Box[int]"} {
+		src := "package main\n//go:decor logging\n" + decl + "\ntype Box[T any] struct{}\ntype Box2[K, V any] struct{}\n"
+		f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err == nil ||
+			!strings.Contains(err.Error(), "is an alias of the instantiated generic type") {
+			t.Fatal("typeDecorRebuild() should return error", decl, err)
+		}
+	}
+}
+
+func TestDecorAllRebuild(t *testing.T) {
+	files := map[string]string{
+		"doc.go": `// Package main
+//
+//go:decor-all ^Get timing
+//go:decor-all . metrics.Track
+package main
+
+import _ "example.com/metrics"
+`,
+		"a.go": `package main
+
+import "github.com/dengsgo/go-decorator/decor"
+
+//go:decor-all ^(Get|T\.Set) logging#{level: "debug"}
+
+func GetName() {}
+
+//go:decor logging
+func GetAge() {}
+
+func (t *T) SetName() {}
+
+func init() {}
+
+func main() {}
+
+func logging(ctx *decor.Context) {}
+`,
+		"a_test.go": `package main
+
+func GetTest() {}
+`,
+	}
+	fset := token.NewFileSet()
+	pkg := &astPackage{Name: "main", Files: map[string]*ast.File{}}
+	for name, src := range files {
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg.Files[name] = f
+	}
+	if _, err := decorAllRebuild(pkg, ""); err != nil {
+		t.Fatal("decorAllRebuild() error", err)
+	}
+	want := map[string][]string{
+		"GetName": {`//go:decor logging#{level: "debug"}`, "//go:decor timing", "//go:decor metrics.Track"},
+		"GetAge":  {"//go:decor logging", "//go:decor timing", "//go:decor metrics.Track"},
+		"SetName": {`//go:decor logging#{level: "debug"}`, "//go:decor metrics.Track"},
+		"init":    nil,
+		"main":    nil,
+		"logging": nil,
+		"GetTest": nil,
+	}
+	for _, file := range []string{"a.go", "a_test.go"} {
+		for _, decl := range pkg.Files[file].Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			var got []string
+			if fd.Doc != nil {
+				for _, c := range fd.Doc.List {
+					got = append(got, c.Text)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(want[fd.Name.Name], "\n") {
+				t.Fatalf("decorAllRebuild() %s want %q, but got %q", fd.Name.Name, want[fd.Name.Name], got)
+			}
+		}
+	}
+	imp := newImporter(pkg.Files["a.go"])
+	if _, ok := imp.importedPath("example.com/metrics"); !ok {
+		t.Fatal("decorAllRebuild() should import example.com/metrics")
+	}
+	if len(pkg.Files["a_test.go"].Imports) != 0 {
+		t.Fatal("decorAllRebuild() should not change a_test.go")
+	}
+
+	for _, src := range []string{
+		"package main\n//go:decor-all ^Get\nfunc Get() {}\n",
+		"package main\n\n//go:decor-all ^Get\n",
+		"package main\n\n//go:decor-all [ logging\n",
+		"package main\n\n//go:decor-all . x.logging.Do\n",
+		"package main\n\nfunc f() {\n\t//go:decor-all . logging\n}\n",
+	} {
+		f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decorAllRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}, ""); err == nil {
+			t.Fatalf("decorAllRebuild() should return error for %q", src)
+		}
+	}
+}
+
+func TestRegisterInitDecl(t *testing.T) {
+	decl := registerInitDecl("decor", [][]string{
+		{"main", "datetime", "logging", "appendFile"},
+		{"main", "(*T).Name", "dump\"Name"},
+	})
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), decl); err != nil {
+		t.Fatal(err)
+	}
+	want := `func init() {
+	decor.Register("main", "datetime", "logging", "appendFile")
+	decor.Register("main", "(*T).Name", "dump\"Name")
+}`
+	if buf.String() != want {
+		t.Fatalf("registerInitDecl() want:\n%s\nbut got:\n%s", want, buf.String())
+	}
+}
+
+func TestOutputFilePath(t *testing.T) {
+	cas := []struct {
+		output, importPath, want string
+	}{
+		{"/tmp/out", "example/usages", "/tmp/out/example/usages/main.go"},
+		{"gen", "example/usages", "/mod/gen/example/usages/main.go"},
+		{"gen", "example/usages [example/usages.test]", "/mod/gen/_test/example/usages.test/example/usages/main.go"},
+		{"gen", "example/lib [example/usages.test]", "/mod/gen/_test/example/usages.test/example/lib/main.go"},
+		{"./gen/", "", "/mod/gen/main.go"},
+	}
+	for _, c := range cas {
+		r := outputFilePath(c.output, "/mod", c.importPath, "/mod/example/usages/main.go")
+		if r != filepath.FromSlash(c.want) {
+			t.Fatalf("outputFilePath(%q, %q) want %s, but got %s\n", c.output, c.importPath, c.want, r)
+		}
+	}
+}
+
+func TestClearOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "a.go.map", filepath.Join("sub", "b.go")} {
+		file := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(file), 0777)
+		if err := os.WriteFile(file, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := clearOutputDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "sub" {
+		t.Fatalf("clearOutputDir() should only keep the sub dir, got %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "b.go")); err != nil {
+		t.Fatal("clearOutputDir() should keep the files of other packages", err)
+	}
+	if err := clearOutputDir(filepath.Join(dir, "missing")); err != nil {
+		t.Fatal("clearOutputDir() of a missing dir should succeed", err)
+	}
+}
+
+func TestWriteRewrittenFiles(t *testing.T) {
+	files := writeGoFiles(t, t.TempDir(), 10, 3)
+	fset := token.NewFileSet()
+	pkg, err := parserGOFiles(fset, files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgDir := t.TempDir()
+	tmpFiles, err := writeRewrittenFiles(fset, pkg, files, tgDir)
+	if err != nil {
+		t.Fatal("writeRewrittenFiles() error", err)
+	}
+	for i, file := range files {
+		if tmpFiles[i] != filepath.Join(tgDir, filepath.Base(file)) {
+			t.Fatalf("writeRewrittenFiles() %s want written to %s, but got %s", file, tgDir, tmpFiles[i])
+		}
+		b, err := os.ReadFile(tmpFiles[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want bytes.Buffer
+		if err := printRewrittenFile(&want, fset, pkg.Files[file]); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want.String() {
+			t.Fatalf("writeRewrittenFiles() %s content not match", file)
+		}
+	}
+
+	if _, err := writeRewrittenFiles(fset, pkg, files, filepath.Join(tgDir, "not-exist")); err == nil {
+		t.Fatal("writeRewrittenFiles() should return error when directory not exists")
+	}
+}
+
+func BenchmarkWriteRewrittenFiles(b *testing.B) {
+	files := writeGoFiles(b, b.TempDir(), 50, 100)
+	fset := token.NewFileSet()
+	pkg, err := parserGOFiles(fset, files...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	tgDir := b.TempDir()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := writeRewrittenFiles(fset, pkg, files, tgDir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDecoratePackageErrors(t *testing.T) {
+	files := map[string]string{
+		"a.go": `package main
+
+import "github.com/dengsgo/go-decorator/decor"
+
+//go:decor logging#{a:}
+func broken() {}
+
+//go:decor logging
+//go:decor logging
+func twice() {}
+
+//go:decor logging#{priority: high}
+func badPriority() {}
+
+func logging(ctx *decor.Context) {}
+`,
+		"b.go": `package main
+
+//go:decor logging
+func notImported() {}
+`,
+	}
+	fset := token.NewFileSet()
+	pkg := &astPackage{Name: "main", Files: map[string]*ast.File{}}
+	for name, src := range files {
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg.Files[name] = f
+	}
+	updatedFiles, err := decoratePackage(fset, pkg, "main", "")
+	if err == nil || updatedFiles != nil {
+		t.Fatal("decoratePackage() should return error", updatedFiles)
+	}
+	want := "found 4 decorator error(s) in package main:" + biSymbol +
+		"a.go:5:1: " + errUsedDecorSyntaxError.Error() + biSymbol +
+		"a.go:8:1: cannot use the same decorator for repeated decoration, repeated: a.go:9:1" + biSymbol +
+		"a.go:12:1: decorator priority must be an integer, but got high" + biSymbol +
+		"b.go:4:1: " + msgDecorPkgNotImported
+	if err.Error() != want {
+		t.Fatalf("decoratePackage() error want %q, but got %q", want, err.Error())
+	}
+}
+
+func TestDecoratePackageDeprecated(t *testing.T) {
+	src := `package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+)
+
+//go:decor d.oldTiming
+func timed() {}
+
+var _ = decor.KFunc
+`
+	decorate := func() error {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "a.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = decoratePackage(fset, &astPackage{Name: "main", Files: map[string]*ast.File{"a.go": f}}, "main", "")
+		return err
+	}
+	// 默认只是警告
+	if err := decorate(); err != nil {
+		t.Fatal("decoratePackage() should only warn about deprecated decorators, but got", err)
+	}
+	defer func(strict bool) { cmdFlag.Strict = strict }(cmdFlag.Strict)
+	cmdFlag.Strict = true
+	want := "found 1 decorator error(s) in package main:" + biSymbol + "a.go:8:1: decorator d.oldTiming is deprecated"
+	if err := decorate(); err == nil || err.Error() != want {
+		t.Fatalf("decoratePackage() with -d.strict want %q, but got %v", want, err)
+	}
+}
+
+func TestDecoratePackageTimed(t *testing.T) {
+	src := `package main
+
+import (
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor d.timedDecor
+func timed() {}
+
+//go:decor d.memoized
+//go:decor d.timedDecor
+func chained() {}
+
+//go:decor d.memoized
+func untimed() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "/src/a.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := decoratePackage(fset, &astPackage{Name: "main", Files: map[string]*ast.File{"/src/a.go": f}}, "main", "")
+	if err != nil || len(updated) != 1 {
+		t.Fatal("decoratePackage() error", updated, err)
+	}
+	// 只有调用了 ctx.Elapsed 的装饰器所在的目标记录开始的时间
+	for _, fd := range f.Decls {
+		fd, ok := fd.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, fd); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]int{"timed": 1, "chained": 1}[fd.Name.Name]
+		if n := strings.Count(buf.String(), "decor.Now()"); n != want {
+			t.Fatalf("decoratePackage() %s want %d decor.Now(), but got %d:\n%s", fd.Name.Name, want, n, buf.String())
+		}
+	}
+}
+
+func TestDecoratePackageTargetMeta(t *testing.T) {
+	src := `package main
+
+import (
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor d.memoized
+//go:decor d.timedDecor
+func chained(a int, b string) (n int) { return a }
+
+//go:decor d.memoized
+func single() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "/src/a.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := decoratePackage(fset, &astPackage{Name: "main", Files: map[string]*ast.File{"/src/a.go": f}}, "main", "")
+	if err != nil || len(updated) != 1 {
+		t.Fatal("decoratePackage() error", updated, err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	// 每个目标一个包级变量，链式装饰的各层共用
+	if n := strings.Count(out, "= [5][]string{"); n != 2 {
+		t.Fatalf("decoratePackage() want 2 target meta vars, but got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out, `[5][]string{{"a", "b"}, {"n"}, {"int", "string"}, {"int"}, {}}`) {
+		t.Fatalf("decoratePackage() should declare the meta of chained:\n%s", out)
+	}
+	if strings.Contains(out, "TargetInNames:  []string{") || strings.Contains(out, "TargetInNames: []string{") {
+		t.Fatalf("decoratePackage() should not allocate the names on each call:\n%s", out)
+	}
+}
+
+func TestDecoratePackageHigherOrder(t *testing.T) {
+	src := `package main
+
+import (
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor d.hooked#{name: "ticks"}
+func ticks(n int) <-chan int { return nil }
+
+//go:decor d.memoized
+func adder(n int) func(int) int { return nil }
+
+//go:decor d.memoized
+//go:decor d.hooked#{name: "handlers"}
+func handlers() (map[string]func() error, chan<- func()) { return nil, nil }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "/src/a.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := decoratePackage(fset, &astPackage{Name: "main", Files: map[string]*ast.File{"/src/a.go": f}}, "main", "")
+	if err != nil || len(updated) != 1 {
+		t.Fatal("decoratePackage() error", updated, err)
+	}
+	var buf bytes.Buffer
+	if err := printRewrittenFile(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	// 结果按完整的类型断言，省略的函数、通道、映射等参数为 nil
+	for _, s := range []string{
+		`.(<-chan int)`,
+		`.(func(int) int)`,
+		`.(map[string]func() error)`,
+		`.(chan<- func())`,
+		`d.hooked(_decorGenIdent`,
+		`"ticks", nil, nil, nil, nil, nil, nil)`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("decoratePackage() should contain %q:\n%s", s, out)
+		}
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "a.go", out, 0); err != nil {
+		t.Fatalf("decoratePackage() output can't be parsed: %v\n%s", err, out)
+	}
+}
+
+func TestWrappedCodeTemplate(t *testing.T) {
+	if newWrappedCodeTemplate(nil) != nil {
+		t.Fatal("newWrappedCodeTemplate(nil) should be nil")
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", "package decor\nfunc wrappedTargetCode() {}\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newWrappedCodeTemplate(f) != nil {
+		t.Fatal("newWrappedCodeTemplate() of an unexpected template should be nil")
+	}
+
+	src, err := os.ReadFile("../../decor/wrapped_code.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模板中增加的注释不影响查找
+	for _, s := range []string{string(src), strings.Replace(string(src), "\tvarDecorContext.Func", "\t// extra\n\tvarDecorContext.Func", 1)} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "wrapped_code.go", s, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wct := newWrappedCodeTemplate(f)
+		if wct == nil || wct.inArgs == nil || wct.outResults == nil {
+			t.Fatalf("newWrappedCodeTemplate() should index the template, got %+v", wct)
+		}
+		if !strings.HasPrefix(wct.inArgs.Text, "/* varDecorContext.TargetIn[0]") ||
+			!strings.HasPrefix(wct.outResults.Text, "/* varDecorContext.TargetOut[0]") {
+			t.Fatalf("newWrappedCodeTemplate() found wrong comments %q %q", wct.inArgs.Text, wct.outResults.Text)
+		}
+
+		target, err := parser.ParseFile(fset, "main.go", "package main\nfunc add(a, b int) int { return a + b }\n", parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ra := builderReplaceArgs(target.Decls[0].(*ast.FuncDecl), "logging", nil, newGenIdentId())
+		rs, err := replace(ra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		genStmts, _, err := getStmtList(rs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wct.assignPos(genStmts)
+		ret := genStmts[len(genStmts)-1].(*ast.ReturnStmt)
+		if ret.Return != wct.body[len(wct.body)-1].Pos() || ret.Results[0].Pos() != wct.outResults.Pos() {
+			t.Fatalf("assignPos() return should point to the template, got %s", fset.Position(ret.Results[0].Pos()))
+		}
+	}
+}
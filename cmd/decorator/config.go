@@ -0,0 +1,611 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 模块根目录下的 decor.toml 可以按规则给函数统一使用装饰器，不需要在每个函数上写注释：
+//
+//	prefix = "//acme:decor"                      # 注解的前缀，见 prefix.go ，只能写在所有表之前
+//
+//	[[rule]]
+//	packages   = ["./internal/service/..."]      # 相对模块根目录的包目录，... 匹配所有子目录
+//	funcs      = ["*"]                           # 函数名的匹配模式，方法写作 Type.Method ，默认为 *
+//	exclude    = ["Debug*"]                      # 排除的函数名
+//	exported   = true                            # 只匹配导出的函数和方法
+//	imports    = ["example.com/app/metrics"]     # 装饰器所在的包，文件中没有导入时自动匿名导入
+//	decorators = ["metrics.Track", "logging#{level: \"info\"}"]
+//
+//	[alias]
+//	log = "github.com/acme/obs/decor.Logging"   # //go:decor log 相当于 //go:decor decor.Logging
+//
+// 匹配的函数相当于在最下方添加了对应的 //go:decor 注释；函数上已经使用了同名的装饰器时，以函数上的注释为准。
+// 测试文件（_test.go）和装饰器本身不会被匹配。配置文件只支持 toml 的这个子集：顶层的 prefix 、[[rule]] 表和 [alias] 表，
+// 值为字符串、布尔值或字符串数组。
+
+const decorConfigFileName = "decor.toml"
+
+type decorConfig struct {
+	dir    string // 配置文件所在的目录，即模块根目录
+	prefix string // 注解的前缀，未设置时为空
+	rules  []*decorConfigRule
+	// 装饰器的简称到 导入路径.装饰器 的映射，见 applyDecorAliases
+	aliases map[string]string
+}
+
+type decorConfigRule struct {
+	line       int // 规则在配置文件中的行号
+	packages   []string
+	funcs      []string
+	exclude    []string
+	exported   bool
+	imports    []string
+	decorators []string
+}
+
+// 规则的摘要，用于 -d.printConfig
+func (r *decorConfigRule) String() string {
+	s := fmt.Sprintf("packages=%v funcs=%v", r.packages, r.funcs)
+	if len(r.exclude) > 0 {
+		s += fmt.Sprintf(" exclude=%v", r.exclude)
+	}
+	if r.exported {
+		s += " exported=true"
+	}
+	if len(r.imports) > 0 {
+		s += fmt.Sprintf(" imports=%v", r.imports)
+	}
+	return s + fmt.Sprintf(" decorators=%v", r.decorators)
+}
+
+// 目录 dir 所在模块的根目录，即向上第一个有 go.mod 的目录，找不到时返回空字符串
+func moduleRoot(dir string) string {
+	for root := dir; ; {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+			return root
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return ""
+		}
+		root = parent
+	}
+}
+
+// 以模块根目录为键缓存配置，没有配置文件时为 nil
+var decorConfigs = map[string]*decorConfig{}
+
+// 查找目录 dir 所在模块的配置文件并解析
+func loadDecorConfig(dir string) (*decorConfig, error) {
+	root := moduleRoot(dir)
+	if root == "" {
+		return nil, nil
+	}
+	if cfg, ok := decorConfigs[root]; ok {
+		return cfg, nil
+	}
+	file := filepath.Join(root, decorConfigFileName)
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		decorConfigs[root] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseDecorConfig(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", filepath.Join("./", file), err)
+	}
+	cfg.dir = root
+	decorConfigs[root] = cfg
+	return cfg, nil
+}
+
+// 解析配置文件的内容，错误以 "行号: 原因" 开头
+func parseDecorConfig(s string) (*decorConfig, error) {
+	cfg := &decorConfig{}
+	lines := strings.Split(s, "\n")
+	var rule *decorConfigRule
+	inAlias := false
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripTomlComment(lines[i]))
+		if line == "" {
+			continue
+		}
+		if line == "[[rule]]" {
+			rule = &decorConfigRule{line: lineNo, funcs: []string{"*"}}
+			cfg.rules = append(cfg.rules, rule)
+			inAlias = false
+			continue
+		}
+		if line == "[alias]" {
+			if cfg.aliases != nil {
+				return nil, fmt.Errorf("%d: duplicate table [alias]", lineNo)
+			}
+			cfg.aliases = map[string]string{}
+			inAlias = true
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("%d: unknown table %s", lineNo, line)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%d: expected key = value", lineNo)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if inAlias {
+			if err := cfg.addAlias(key, value); err != nil {
+				return nil, fmt.Errorf("%d: alias %s: %w", lineNo, key, err)
+			}
+			continue
+		}
+		if rule == nil {
+			if key != "prefix" {
+				return nil, fmt.Errorf("%d: key outside of [[rule]]", lineNo)
+			}
+			if cfg.prefix != "" {
+				return nil, fmt.Errorf("%d: duplicate key prefix", lineNo)
+			}
+			prefix, rest, err := parseTomlString(value)
+			if err != nil || strings.TrimSpace(rest) != "" {
+				return nil, fmt.Errorf("%d: prefix: expected a string", lineNo)
+			}
+			if cfg.prefix, err = normalizeDecorPrefix(prefix); err != nil {
+				return nil, fmt.Errorf("%d: prefix: %w", lineNo, err)
+			}
+			continue
+		}
+		// 数组可以跨越多行
+		for strings.HasPrefix(value, "[") && !tomlArrayClosed(value) && i+1 < len(lines) {
+			i++
+			value += " " + strings.TrimSpace(stripTomlComment(lines[i]))
+		}
+		var err error
+		switch key {
+		case "packages":
+			rule.packages, err = parseTomlStrings(value)
+		case "funcs":
+			rule.funcs, err = parseTomlStrings(value)
+		case "exclude":
+			rule.exclude, err = parseTomlStrings(value)
+		case "imports":
+			rule.imports, err = parseTomlStrings(value)
+		case "decorators":
+			rule.decorators, err = parseTomlStrings(value)
+		case "exported":
+			rule.exported, err = strconv.ParseBool(value)
+		default:
+			err = errors.New("unknown key " + key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%d: %s: %w", lineNo, key, err)
+		}
+	}
+	for _, rule := range cfg.rules {
+		if len(rule.packages) == 0 || len(rule.decorators) == 0 {
+			return nil, fmt.Errorf("%d: rule requires packages and decorators", rule.line)
+		}
+		for _, pattern := range append(append([]string{}, rule.funcs...), rule.exclude...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%d: invalid pattern %q", rule.line, pattern)
+			}
+		}
+		for _, d := range rule.decorators {
+			if _, _, err := parseDecorAndParameters(d); err != nil {
+				return nil, fmt.Errorf("%d: decorator %q: %w", rule.line, d, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// 添加别名 name = "importPath.Name" ，Name 可以是 Var.Method 形式的绑定装饰器
+func (cfg *decorConfig) addAlias(name, value string) error {
+	if !token.IsIdentifier(name) {
+		return errors.New("alias must be an identifier")
+	}
+	if _, ok := cfg.aliases[name]; ok {
+		return errors.New("duplicate alias")
+	}
+	target, rest, err := parseTomlString(value)
+	if err != nil || strings.TrimSpace(rest) != "" {
+		return errors.New("expected a string")
+	}
+	if _, _, ok := splitDecorAlias(target); !ok {
+		return fmt.Errorf("%q is not in the form importPath.Decorator", target)
+	}
+	cfg.aliases[name] = target
+	return nil
+}
+
+// 把别名的目标 importPath.Name 拆分为导入路径和装饰器名，装饰器名为 Name 或 Var.Method
+func splitDecorAlias(target string) (importPath, name string, ok bool) {
+	i := strings.Index(target[strings.LastIndex(target, "/")+1:], ".")
+	if i < 0 {
+		return "", "", false
+	}
+	i += strings.LastIndex(target, "/") + 1
+	importPath, name = target[:i], target[i+1:]
+	if importPath == "" || name == "" {
+		return "", "", false
+	}
+	for _, id := range strings.Split(name, ".") {
+		if !token.IsIdentifier(id) {
+			return "", "", false
+		}
+	}
+	return importPath, name, strings.Count(name, ".") <= 1
+}
+
+// 去掉字符串之外的 # 注释
+func stripTomlComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// 数组的方括号是否已经闭合
+func tomlArrayClosed(s string) bool {
+	depth, quote := 0, byte(0)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth == 0
+}
+
+// 解析字符串或字符串数组，字符串可以是 "basic" 或 'literal'
+func parseTomlStrings(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "[") {
+		v, rest, err := parseTomlString(s)
+		if err != nil || strings.TrimSpace(rest) != "" {
+			return nil, errors.New("expected a string or an array of strings")
+		}
+		return []string{v}, nil
+	}
+	if !strings.HasSuffix(s, "]") {
+		return nil, errors.New("unclosed array")
+	}
+	var r []string
+	rest := strings.TrimSpace(s[1 : len(s)-1])
+	for rest != "" {
+		v, next, err := parseTomlString(rest)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, v)
+		rest = strings.TrimSpace(next)
+		if rest != "" {
+			if rest[0] != ',' {
+				return nil, errors.New("expected , between array elements")
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+	return r, nil
+}
+
+// 解析 s 开头的一个字符串，返回它的值和剩余的部分
+func parseTomlString(s string) (string, string, error) {
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return "", "", errors.New("expected a string")
+	}
+	for i := 1; i < len(s); i++ {
+		if s[0] == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] != s[0] {
+			continue
+		}
+		if s[0] == '\'' {
+			return s[1:i], s[i+1:], nil
+		}
+		v, err := strconv.Unquote(s[:i+1])
+		return v, s[i+1:], err
+	}
+	return "", "", errors.New("unclosed string")
+}
+
+// 规则是否匹配相对模块根目录的包目录 dir
+func (r *decorConfigRule) matchPackage(dir string) bool {
+	for _, pattern := range r.packages {
+		pattern = path.Clean(strings.TrimPrefix(pattern, "./"))
+		if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+			prefix := strings.TrimSuffix(pattern, "/...")
+			if pattern == "..." || prefix == "." || dir == prefix || strings.HasPrefix(dir, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, dir); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 规则是否匹配函数 fd ，方法的名称为 Type.Method
+func (r *decorConfigRule) matchFunc(fd *ast.FuncDecl) bool {
+	if r.exported && !fd.Name.IsExported() {
+		return false
+	}
+	name := funcDeclName(fd)
+	for _, pattern := range r.exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	for _, pattern := range r.funcs {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 按模块的 decor.toml 给包 pkg 中匹配的函数添加 //go:decor 注释，并导入需要的包。
+// skipFile 为不处理的文件（wrapped_code.go），可以为空。
+func applyDecorConfig(pkg *astPackage, skipFile string) error {
+	var dir string
+	for file := range pkg.Files {
+		if file != skipFile {
+			dir = filepath.Dir(file)
+			break
+		}
+	}
+	if dir == "" {
+		return nil
+	}
+	cfg, err := loadDecorConfig(dir)
+	if err != nil || cfg == nil {
+		return err
+	}
+	rel, err := filepath.Rel(cfg.dir, dir)
+	if err != nil {
+		return nil
+	}
+	var rules []*decorConfigRule
+	for _, rule := range cfg.rules {
+		if rule.matchPackage(filepath.ToSlash(rel)) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	for file, f := range pkg.Files {
+		if file == skipFile || strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		injectDecorComments(f, func(fd *ast.FuncDecl) (injects []decorInjection) {
+			for _, rule := range rules {
+				if !rule.matchFunc(fd) {
+					continue
+				}
+				for _, d := range rule.decorators {
+					name, _, _ := parseDecorAndParameters(d)
+					injects = append(injects, decorInjection{name: name, text: d, imports: rule.imports})
+				}
+			}
+			return injects
+		})
+	}
+	return nil
+}
+
+// applyDecorAliases 添加的导入，改写后没有被引用时（例如装饰器因为 when 被忽略）由 blankUnusedAliasImports 改为匿名导入
+var aliasImportSpecs = map[*ast.ImportSpec]bool{}
+
+// 按模块 decor.toml 中的 [alias] 表改写包 pkg 中使用了别名的装饰注释，并导入别名所在的包：
+//
+//	[alias]
+//	log = "github.com/acme/obs/decor.Logging"
+//
+//	//go:decor log#{level: "info"}  =>  //go:decor decor2.Logging#{level: "info"}
+//
+// 文件中已经按名称导入了这个包时使用已有的名称，否则添加一个命名导入，名称和文件中的其他导入、包中的顶层声明冲突时
+// 加上数字后缀。包中声明了同名的函数或变量时注释引用的是包中的声明，别名不生效。文件中没有导入 decor 包时自动匿名导入。
+// skipFile 为不处理的文件（wrapped_code.go），可以为空。
+func applyDecorAliases(pkg *astPackage, skipFile string) error {
+	var dir string
+	for file := range pkg.Files {
+		if file != skipFile {
+			dir = filepath.Dir(file)
+			break
+		}
+	}
+	if dir == "" {
+		return nil
+	}
+	cfg, err := loadDecorConfig(dir)
+	if err != nil || cfg == nil || len(cfg.aliases) == 0 {
+		return err
+	}
+	declared := packageDeclNames(pkg)
+	for file, f := range pkg.Files {
+		if file == skipFile {
+			continue
+		}
+		names := map[string]string{} // 别名所在包的导入路径 => 文件中引用它的名称
+		ast.Inspect(f, func(n ast.Node) bool {
+			cg, ok := n.(*ast.CommentGroup)
+			if !ok {
+				return true
+			}
+			for _, c := range cg.List {
+				if !strings.HasPrefix(c.Text, decoratorScanFlag) {
+					continue
+				}
+				s := strings.TrimLeft(c.Text[len(decoratorScanFlag):], " \t")
+				name, _, err := parseDecorAndParameters(s)
+				target, ok := cfg.aliases[name]
+				if err != nil || !ok || declared[name] || !strings.HasPrefix(s, name) {
+					continue
+				}
+				importPath, decorName, _ := splitDecorAlias(target)
+				x, ok := names[importPath]
+				if !ok {
+					x = importDecorAlias(f, importPath, declared)
+					names[importPath] = x
+				}
+				c.Text = decoratorScanFlag + x + "." + decorName + s[len(name):]
+			}
+			return false
+		})
+		if len(names) > 0 {
+			addAnonymousImports(f, map[string]bool{decoratorPackagePath: true})
+		}
+	}
+	return nil
+}
+
+// 返回文件 f 中引用包 importPath 的名称，没有按名称导入时添加一个命名导入。
+// 名称不能和文件中导入的其他包、包中的顶层声明 declared 相同，decor 留给 decor 包。
+func importDecorAlias(f *ast.File, importPath string, declared map[string]bool) string {
+	imp := newImporter(f)
+	if name, ok := imp.importedPath(importPath); ok && name != "_" && name != "." {
+		return name
+	}
+	base := importPathName(importPath)
+	if !token.IsIdentifier(base) {
+		base = "alias"
+	}
+	taken := func(name string) bool {
+		if p, ok := imp.importedName(name); ok && p != importPath {
+			return true
+		}
+		return declared[name] || (name == "decor" && importPath != decoratorPackagePath)
+	}
+	name := base
+	for i := 2; taken(name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+	if spec := imp.pathObjMap[importPath]; spec != nil && spec.Name != nil && spec.Name.Name == "_" {
+		spec.Name.Name = name
+		aliasImportSpecs[spec] = true
+		return name
+	}
+	aliasImportSpecs[addImport(f, name, importPath)] = true
+	return name
+}
+
+// 改写后的文件 f 中没有被引用的别名导入改为匿名导入，避免 imported and not used 的编译错误
+func blankUnusedAliasImports(f *ast.File) {
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		}
+		return true
+	})
+	for _, spec := range f.Imports {
+		if aliasImportSpecs[spec] && spec.Name != nil && !used[spec.Name.Name] {
+			spec.Name.Name = "_"
+		}
+	}
+}
+
+// 函数的名称，方法为 Type.Method
+func funcDeclName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) != 1 {
+		return fd.Name.Name
+	}
+	typ := fd.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	recv, _, _ := strings.Cut(recvTypeName(typ), "[")
+	return recv + "." + fd.Name.Name
+}
+
+// 函数上已经使用的装饰器名
+func usedDecorNames(fd *ast.FuncDecl) map[string]bool {
+	used := map[string]bool{}
+	if fd.Doc != nil {
+		for _, c := range fd.Doc.List {
+			if strings.HasPrefix(c.Text, decoratorScanFlag) {
+				name, _, _ := parseDecorAndParameters(c.Text[len(decoratorScanFlag):])
+				used[name] = true
+			}
+		}
+	}
+	return used
+}
+
+// 按路径顺序匿名导入 imports 中文件 f 还没有导入的包
+func addAnonymousImports(f *ast.File, imports map[string]bool) {
+	paths := make([]string, 0, len(imports))
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	imp := newImporter(f)
+	for _, p := range paths {
+		if _, ok := imp.importedPath(p); !ok {
+			addAnonymousImport(f, p)
+		}
+	}
+}
+
+// 给文件 f 添加匿名导入 import _ "p"
+func addAnonymousImport(f *ast.File, p string) {
+	addImport(f, "_", p)
+}
+
+// 给文件 f 添加导入 import name "p" ，放在第一个导入声明中，没有导入声明时新建一个
+func addImport(f *ast.File, name, p string) *ast.ImportSpec {
+	spec := &ast.ImportSpec{
+		Name: ast.NewIdent(name),
+		Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(p)},
+	}
+	f.Imports = append(f.Imports, spec)
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			gd.Specs = append(gd.Specs, spec)
+			return spec
+		}
+	}
+	f.Decls = append([]ast.Decl{&ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{spec}}}, f.Decls...)
+	return spec
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDecorConfig(t *testing.T) {
+	cfg, err := parseDecorConfig(`# comment
+[[rule]]
+packages = "./internal/service/..." # trailing comment
+funcs = [
+	"*",   # all
+	'Get#*',
+]
+exported = true
+decorators = ["metrics.Track", "logging#{level: \"info\"}"]
+
+[[rule]]
+packages = ["./cmd"]
+imports = ["example.com/app/metrics"]
+decorators = ["metrics.Track"]
+`)
+	if err != nil {
+		t.Fatal("parseDecorConfig() error", err)
+	}
+	if len(cfg.rules) != 2 {
+		t.Fatalf("parseDecorConfig() want 2 rules, got %d", len(cfg.rules))
+	}
+	r := cfg.rules[0]
+	if r.line != 2 || !r.exported || strings.Join(r.packages, ",") != "./internal/service/..." ||
+		strings.Join(r.funcs, ",") != "*,Get#*" || r.decorators[1] != `logging#{level: "info"}` {
+		t.Fatalf("parseDecorConfig() rule[0] not match, got %+v", r)
+	}
+	if r := cfg.rules[1]; strings.Join(r.funcs, ",") != "*" || r.exported || r.imports[0] != "example.com/app/metrics" {
+		t.Fatalf("parseDecorConfig() rule[1] not match, got %+v", r)
+	}
+
+	errCas := []struct {
+		in, msg string
+	}{
+		{"packages = [\"a\"]", "1: key outside of [[rule]]"},
+		{"[rule]", "1: unknown table"},
+		{"[[rule]]\nname = \"x\"", "2: name: unknown key name"},
+		{"[[rule]]\npackages = [\"a\" \"b\"]", "2: packages: expected , between array elements"},
+		{"[[rule]]\nexported = yes", "2: exported"},
+		{"[[rule]]\npackages = [\"a\"]", "1: rule requires packages and decorators"},
+		{"[[rule]]\npackages = [\"a\"]\ndecorators = [\"a b\"]", "1: decorator \"a b\""},
+		{"[[rule]]\npackages = [\"a\"]\nfuncs = [\"[\"]\ndecorators = [\"a\"]", "1: invalid pattern"},
+		{"[alias]\nlog = \"logging\"", "2: alias log: \"logging\" is not in the form importPath.Decorator"},
+		{"[alias]\nlog = \"example.com/obs\"", "2: alias log: \"example.com/obs\" is not in the form"},
+		{"[alias]\nlog = \"example.com/obs.A.B.C\"", "2: alias log: \"example.com/obs.A.B.C\" is not in the form"},
+		{"[alias]\nlog = [\"a\"]", "2: alias log: expected a string"},
+		{"[alias]\nlog-x = \"a.B\"", "2: alias log-x: alias must be an identifier"},
+		{"[alias]\nlog = \"a.B\"\nlog = \"a.C\"", "3: alias log: duplicate alias"},
+		{"[alias]\n[alias]", "2: duplicate table [alias]"},
+	}
+	for i, c := range errCas {
+		if _, err := parseDecorConfig(c.in); err == nil || !strings.HasPrefix(err.Error(), c.msg) {
+			t.Fatalf("errCas[%d] parseDecorConfig() should return err %q, but got %v", i, c.msg, err)
+		}
+	}
+}
+
+func TestParseDecorConfigAlias(t *testing.T) {
+	cfg, err := parseDecorConfig(`[[rule]]
+packages = ["./..."]
+decorators = ["log"]
+
+[alias]
+log   = "github.com/acme/obs/decor.Logging" # comment
+trace = 'example.com/app/v2.Default.Trace'
+`)
+	if err != nil {
+		t.Fatal("parseDecorConfig() error", err)
+	}
+	if len(cfg.rules) != 1 || len(cfg.aliases) != 2 ||
+		cfg.aliases["log"] != "github.com/acme/obs/decor.Logging" || cfg.aliases["trace"] != "example.com/app/v2.Default.Trace" {
+		t.Fatalf("parseDecorConfig() aliases not match, got %+v %v", cfg.rules, cfg.aliases)
+	}
+	importPath, name, ok := splitDecorAlias(cfg.aliases["trace"])
+	if !ok || importPath != "example.com/app/v2" || name != "Default.Trace" {
+		t.Fatalf("splitDecorAlias() got %s %s %v", importPath, name, ok)
+	}
+}
+
+func TestDecorConfigRuleMatch(t *testing.T) {
+	r := &decorConfigRule{
+		packages: []string{"./internal/service/...", "cmd/*"},
+		funcs:    []string{"*"},
+		exclude:  []string{"Debug*", "*.Close"},
+		exported: true,
+	}
+	for dir, want := range map[string]bool{
+		"internal/service":       true,
+		"internal/service/user":  true,
+		"internal/services":      false,
+		"cmd/app":                true,
+		"cmd/app/sub":            false,
+		".":                      false,
+		"internal/service/a/b/c": true,
+	} {
+		if r.matchPackage(dir) != want {
+			t.Fatalf("matchPackage(%q) want %v", dir, want)
+		}
+	}
+	if !(&decorConfigRule{packages: []string{"./..."}}).matchPackage(".") {
+		t.Fatal("matchPackage(.) should match ./...")
+	}
+
+	f := parseTestFile(t, `package p
+func Get() {}
+func get() {}
+func DebugDump() {}
+func (s *Service) Close() {}
+func (s *Service) Open() {}
+func (b Box[T]) Value() {}
+`)
+	want := map[string]bool{"Get": true, "get": false, "DebugDump": false, "Close": false, "Open": true, "Value": true}
+	for _, decl := range f.Decls {
+		fd := decl.(*ast.FuncDecl)
+		if r.matchFunc(fd) != want[fd.Name.Name] {
+			t.Fatalf("matchFunc(%s) want %v", fd.Name.Name, want[fd.Name.Name])
+		}
+	}
+	box := &decorConfigRule{funcs: []string{"Box.*"}}
+	if !box.matchFunc(f.Decls[5].(*ast.FuncDecl)) {
+		t.Fatal("matchFunc(Box[T].Value) should match Box.*")
+	}
+}
+
+func TestApplyDecorConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n",
+		"decor.toml": `[[rule]]
+packages = ["./service"]
+exported = true
+imports = ["example.com/app/metrics"]
+decorators = ["metrics.Track", "logging"]
+`,
+		"service/a.go": `package service
+
+import "fmt"
+
+// Get returns a value.
+func Get() string { return fmt.Sprint(1) }
+
+//go:decor logging#{level: "debug"}
+func Put() {}
+
+func helper() {}
+`,
+		"service/a_test.go": "package service\n\nfunc TestGet() {}\n",
+		"other/b.go":        "package other\n\nfunc Get() {}\n",
+	}
+	for name, src := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer delete(decorConfigs, dir)
+
+	fset := token.NewFileSet()
+	pkg, err := parserGOFiles(fset, filepath.Join(dir, "service/a.go"), filepath.Join(dir, "service/a_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyDecorConfig(pkg, ""); err != nil {
+		t.Fatal("applyDecorConfig() error", err)
+	}
+	docs := map[string]string{}
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Doc != nil {
+				var texts []string
+				for _, c := range fd.Doc.List {
+					texts = append(texts, c.Text)
+				}
+				docs[fd.Name.Name] = strings.Join(texts, "\n")
+			}
+		}
+	}
+	want := map[string]string{
+		"Get": "// Get returns a value.\n//go:decor metrics.Track\n//go:decor logging",
+		"Put": "//go:decor logging#{level: \"debug\"}\n//go:decor metrics.Track",
+	}
+	if len(docs) != len(want) {
+		t.Fatalf("applyDecorConfig() docs not match, got %q", docs)
+	}
+	for name, doc := range want {
+		if docs[name] != doc {
+			t.Fatalf("applyDecorConfig() %s doc want %q, got %q", name, doc, docs[name])
+		}
+	}
+	imp := newImporter(pkg.Files[filepath.Join(dir, "service/a.go")])
+	for _, p := range []string{decoratorPackagePath, "example.com/app/metrics", "fmt"} {
+		if _, ok := imp.importedPath(p); !ok {
+			t.Fatalf("applyDecorConfig() should import %s", p)
+		}
+	}
+
+	// 规则不匹配的包不受影响
+	other, err := parserGOFiles(fset, filepath.Join(dir, "other/b.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyDecorConfig(other, ""); err != nil {
+		t.Fatal("applyDecorConfig() error", err)
+	}
+	for _, f := range other.Files {
+		if len(f.Imports) != 0 || f.Decls[0].(*ast.FuncDecl).Doc != nil {
+			t.Fatal("applyDecorConfig() should not change unmatched package")
+		}
+	}
+}
+
+func TestApplyDecorAliases(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n",
+		"decor.toml": `[alias]
+log   = "example.com/obs/decor.Logging"
+trace = "example.com/obs/decor.Default.Trace"
+local = "example.com/other.Local"
+`,
+		"service/a.go": `package service
+
+import "github.com/dengsgo/go-decorator/decor"
+
+//go:decor log#{level: "debug"}
+//go:decor trace
+func Get() {}
+
+//go:decor local
+func Put() {}
+
+func local(ctx *decor.Context) {}
+`,
+		"service/b.go": `package service
+
+import obs "example.com/obs/decor"
+
+//go:decor log
+func Del() {}
+
+var _ = obs.Logging
+`,
+	}
+	for name, src := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer delete(decorConfigs, dir)
+
+	fset := token.NewFileSet()
+	a, b := filepath.Join(dir, "service/a.go"), filepath.Join(dir, "service/b.go")
+	pkg, err := parserGOFiles(fset, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyDecorAliases(pkg, ""); err != nil {
+		t.Fatal("applyDecorAliases() error", err)
+	}
+	docs := map[string]string{}
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Doc != nil {
+				var texts []string
+				for _, c := range fd.Doc.List {
+					texts = append(texts, c.Text)
+				}
+				docs[fd.Name.Name] = strings.Join(texts, "\n")
+			}
+		}
+	}
+	// decor 已经是 decor 包的名称，别名所在的包使用 decor2 ；包中声明的 local 不受别名影响
+	want := map[string]string{
+		"Get": "//go:decor decor2.Logging#{level: \"debug\"}\n//go:decor decor2.Default.Trace",
+		"Put": "//go:decor local",
+		"Del": "//go:decor obs.Logging",
+	}
+	for name, doc := range want {
+		if docs[name] != doc {
+			t.Fatalf("applyDecorAliases() %s doc want %q, got %q", name, doc, docs[name])
+		}
+	}
+	if name, _ := newImporter(pkg.Files[a]).importedPath("example.com/obs/decor"); name != "decor2" {
+		t.Fatalf("applyDecorAliases() should import example.com/obs/decor as decor2, got %q", name)
+	}
+	if len(pkg.Files[b].Imports) != 2 {
+		t.Fatalf("applyDecorAliases() should only import decor in b.go, got %d imports", len(pkg.Files[b].Imports))
+	}
+
+	// 改写后没有被引用的别名导入改为匿名导入
+	blankUnusedAliasImports(pkg.Files[a])
+	if name, _ := newImporter(pkg.Files[a]).importedPath("example.com/obs/decor"); name != "_" {
+		t.Fatalf("blankUnusedAliasImports() should blank the unused import, got %q", name)
+	}
+}
+
+func parseTestFile(t *testing.T, src string) *ast.File {
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// 参数较多时，注解的参数可以在紧随其后的 //go:decor-args 注释中继续书写，
+// 它们按顺序以空格连接到前面的 //go:decor （或 //go:decor-all）注释上：
+//
+//	//go:decor logging#{
+//	//go:decor-args     level: "info",
+//	//go:decor-args     fields: {"id", "name"},
+//	//go:decor-args }
+//	func handle(id int, name string) {}
+//
+// 源文件解析后立即合并，之后的处理只看到合并后的一行注释，被合并的注释从注释组中移除。
+// 生成的代码中保留合并后的注释，行号由 printer 输出的 //line 指令保持不变。
+
+// 注释 text 是否为续行注释，是时返回去掉前缀后的参数
+func trimDecorArgs(text string) (string, bool) {
+	if !strings.HasPrefix(text, decorArgsFlag) {
+		return text, false
+	}
+	s := text[len(decorArgsFlag):]
+	if s != "" && s[0] != ' ' && s[0] != '\t' {
+		return text, false
+	}
+	return strings.TrimSpace(s), true
+}
+
+// 把文件 f 中的续行注释合并到前面的注解上。续行注释之前不是注解或续行注释时返回错误。
+func joinDecorArgs(fset *token.FileSet, f *ast.File) error {
+	for _, cg := range f.Comments {
+		var list []*ast.Comment
+		var joined *ast.Comment
+		for _, c := range cg.List {
+			args, ok := trimDecorArgs(c.Text)
+			if !ok {
+				joined = nil
+				if strings.HasPrefix(c.Text, decoratorScanFlag) || strings.HasPrefix(c.Text, decorAllScanFlag) {
+					joined = c
+				}
+				list = append(list, c)
+				continue
+			}
+			if joined == nil {
+				return errors.New(fmt.Sprintf("%s: %s must follow a %s or %s annotation", fset.Position(c.Pos()),
+					decorArgsFlag, strings.TrimSpace(decoratorScanFlag), strings.TrimSpace(decorAllScanFlag)))
+			}
+			if args != "" {
+				joined.Text = strings.TrimRight(joined.Text, " \t") + " " + args
+			}
+		}
+		cg.List = list
+	}
+	return nil
+}
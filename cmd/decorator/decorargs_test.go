@@ -0,0 +1,88 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestJoinDecorArgs(t *testing.T) {
+	cas := []struct {
+		src  string
+		want []string // 合并后函数 a 的文档注释
+		err  string
+	}{
+		{
+			"package p\n\n//go:decor logging#{\n//go:decor-args   level: \"info\",\n//go:decor-args\n//go:decor-args }\nfunc a() {}\n",
+			[]string{`//go:decor logging#{ level: "info", }`},
+			"",
+		},
+		{
+			// 每个注解合并各自的续行，其他注释不受影响
+			"package p\n\n// a 说明\n//go:decor logging#{level: 1,\n//go:decor-args name: \"a\"}\n//go:decor-lint required: {level}\n//go:decor tagging#{names: {\"a\",\n//go:decor-args \"b\"}}\nfunc a() {}\n",
+			[]string{"// a 说明", `//go:decor logging#{level: 1, name: "a"}`, "//go:decor-lint required: {level}", `//go:decor tagging#{names: {"a", "b"}}`},
+			"",
+		},
+		{
+			// //go:decor-argsX 不是续行
+			"package p\n\n//go:decor logging\n//go:decor-argsX\nfunc a() {}\n",
+			[]string{"//go:decor logging", "//go:decor-argsX"},
+			"",
+		},
+		{
+			"package p\n\n// a 说明\n//go:decor-args level: 1\nfunc a() {}\n",
+			nil,
+			"p.go:4:1: //go:decor-args must follow a //go:decor or //go:decor-all annotation",
+		},
+		{
+			// 空行之后是新的注释组
+			"package p\n\n//go:decor logging#{\n\n//go:decor-args }\nfunc a() {}\n",
+			nil,
+			"p.go:5:1: //go:decor-args must follow",
+		},
+	}
+	for i, c := range cas {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", c.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = joinDecorArgs(fset, f)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("cas[%d] joinDecorArgs() should fail with %q, but got %v", i, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("cas[%d] joinDecorArgs() error %v", i, err)
+		}
+		var got []string
+		for _, c := range f.Decls[0].(*ast.FuncDecl).Doc.List {
+			got = append(got, c.Text)
+		}
+		if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Fatalf("cas[%d] joinDecorArgs() got %q, want %q", i, got, c.want)
+		}
+	}
+}
+
+func TestJoinDecorArgsPrefix(t *testing.T) {
+	defer setDecorPrefix(defaultDecorPrefix)
+	setDecorPrefix("//acme:decor")
+	src := "package p\n\n//acme:decor logging#{\n//acme:decor-args level: 1}\nfunc a() {}\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := joinDecorArgs(fset, f); err != nil {
+		t.Fatal(err)
+	}
+	doc := f.Decls[0].(*ast.FuncDecl).Doc.List
+	if len(doc) != 1 || doc[0].Text != "//acme:decor logging#{ level: 1}" {
+		t.Fatalf("joinDecorArgs() with a custom prefix got %q", doc[0].Text)
+	}
+}
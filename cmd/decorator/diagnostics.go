@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// -d.errjson 时装饰器的错误和警告以 JSON lines 的格式输出，每行一个对象，便于 IDE 插件和 CI 解析，例如：
+//
+//	{"severity":"error","message":"decorator priority must be an integer, but got high","file":"/path/to/a.go","line":12,"column":1,"decorator":"logging"}
+//
+// 编译时写入 stderr ，lint 子命令写入 stdout 。file 为绝对路径，decorator 为相关的装饰器名称，和装饰器无关时省略。
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// 一条机器可读的诊断信息
+type diagnostic struct {
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	Decorator string `json:"decorator,omitempty"`
+}
+
+func newDiagnostic(issue lintIssue) diagnostic {
+	severity := severityError
+	if issue.warning {
+		severity = severityWarning
+	}
+	return diagnostic{
+		Severity:  severity,
+		Message:   issue.msg,
+		File:      issue.pos.Filename,
+		Line:      issue.pos.Line,
+		Column:    issue.pos.Column,
+		Decorator: issue.decorator,
+	}
+}
+
+// 把 issues 按 JSON lines 的格式写入 w
+func writeDiagnostics(w io.Writer, issues []lintIssue) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, issue := range issues {
+		if err := enc.Encode(newDiagnostic(issue)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
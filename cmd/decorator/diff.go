@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// diff 子命令：输出被装饰的源文件在改写前后的差异，便于在合并前审查装饰器注入的代码。
+//
+//	decorator diff [-d.tags tags] [packages]
+//
+// packages 的写法和 go list 一致，默认为 ./... 。改写过程和 compile 完全相同（decoratePackage），
+// 差异以 unified diff 的格式输出，不会修改任何文件。
+
+const diffContextLines = 3
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.StringVar(&cmdFlag.Tags, "d.tags", cmdFlag.Tags, "comma-separated decorator tags, same as the build flag")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	return diffPackages(os.Stdout, patterns)
+}
+
+func diffPackages(w io.Writer, patterns []string) error {
+	pkgs, err := listPackages(patterns)
+	if err != nil {
+		return err
+	}
+	// 装饰器的包路径为空时表示当前包，compile 以被编译包的目录为工作目录来查找，这里保持一致
+	workDir, prefix := projectDir, decorPrefix
+	defer func() {
+		projectDir = workDir
+		pkgILoader.resetCurrentPkg()
+		setDecorPrefix(prefix)
+	}()
+	for _, pi := range pkgs {
+		projectDir = pi.Dir
+		pkgILoader.resetCurrentPkg()
+		// 注解的前缀可能由包所在模块的 decor.toml 设置
+		if err := useDecorPrefix(pi.Dir); err != nil {
+			return err
+		}
+		for _, group := range packageFileGroups(pi) {
+			if err := diffPackage(w, workDir, group); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 输出一组一起改写的源文件的差异
+func diffPackage(w io.Writer, workDir string, group packageFiles) error {
+	files, packageName := group.files, group.packageName
+	fset := token.NewFileSet()
+	pkg, err := parserGOFiles(fset, files...)
+	if err != nil {
+		return err
+	}
+	// 记录改写前的所有节点，改写后其余的节点都是生成的
+	origin := map[ast.Node]bool{}
+	for _, f := range pkg.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			origin[n] = true
+			return true
+		})
+	}
+	// 不使用 wrapped_code.go 的位置信息
+	updatedFiles, err := decoratePackage(fset, pkg, packageName, "")
+	if err != nil {
+		return err
+	}
+	for _, file := range updatedFiles {
+		resetGeneratedPos(pkg.Files[file], origin)
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		decorated, err := decoratedSource(fset, pkg.Files[file], src, origin)
+		if err != nil {
+			return errors.New("print decorated code fail: " + file + ": " + err.Error())
+		}
+		name := filepath.ToSlash(file)
+		if rel, err := filepath.Rel(workDir, file); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+		fmt.Fprint(w, unifiedDiff("a/"+name, "b/"+name, string(src), string(decorated)))
+	}
+	return nil
+}
+
+// 一组一起改写的源文件，packageName 和 go build 传给 compile 的 -p 参数保持一致
+type packageFiles struct {
+	packageName string
+	files       []string
+}
+
+// 包 pi 中一起改写的源文件。和 go test 编译时一样，包的源文件和内部测试文件为一组，
+// 外部测试包（package xxx_test）的文件为另一组。
+func packageFileGroups(pi *_packageInfo) []packageFiles {
+	join := func(names ...[]string) []string {
+		var files []string
+		for _, ns := range names {
+			for _, name := range ns {
+				files = append(files, filepath.Join(pi.Dir, name))
+			}
+		}
+		return files
+	}
+	packageName := pi.ImportPath
+	if pi.Name == "main" {
+		packageName = "main"
+	}
+	var groups []packageFiles
+	if files := join(pi.GoFiles, pi.TestGoFiles); len(files) > 0 {
+		groups = append(groups, packageFiles{packageName: packageName, files: files})
+	}
+	if len(pi.XTestGoFiles) > 0 {
+		groups = append(groups, packageFiles{packageName: pi.ImportPath + "_test", files: join(pi.XTestGoFiles)})
+	}
+	return groups
+}
+
+// 执行 go list -json patterns 获取所有匹配的包信息
+func listPackages(patterns []string) ([]*_packageInfo, error) {
+	// 在 go.work 工作区的根目录中执行时，./... 替换为各个成员模块的包
+	if ws, err := findWorkspace(projectDir); err != nil {
+		return nil, err
+	} else if ws != nil {
+		patterns = ws.expandPatterns(projectDir, patterns)
+	}
+	cmd := exec.Command("go", append([]string{"list", "-json"}, patterns...)...)
+	cmd.Dir = projectDir
+	cmd.Env = os.Environ()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	bf, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New("go list fail: " + err.Error() + biSymbol + stderr.String())
+	}
+	var pkgs []*_packageInfo
+	dec := json.NewDecoder(bytes.NewReader(bf))
+	for dec.More() {
+		p := &_packageInfo{}
+		if err := dec.Decode(p); err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+// 生成改写后的文件源码。
+// 只有被改写的声明（包括导入声明）会重新打印并替换到原始源码 src 中对应的位置，其余部分保持原样。
+// 每个声明只和它范围内的注释一起打印，避免 printer 把其他位置的注释错放到生成的代码中。
+// 新增的声明（例如注册被装饰函数的 init 函数）没有位置信息，追加到文件末尾；新增的导入声明放在 package 子句之后。
+func decoratedSource(fset *token.FileSet, f *ast.File, src []byte, origin map[ast.Node]bool) ([]byte, error) {
+	tf := fset.File(f.Package)
+	var out, appended bytes.Buffer
+	last := 0
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT && !gd.Pos().IsValid() {
+			nameEnd := tf.Offset(f.Name.End())
+			out.Write(src[last:nameEnd])
+			out.WriteString("\n\n")
+			if err := format.Node(&out, fset, decl); err != nil {
+				return nil, err
+			}
+			last = nameEnd
+			continue
+		}
+		if !declRewritten(decl, origin) {
+			continue
+		}
+		if !decl.Pos().IsValid() {
+			appended.WriteString("\n")
+			if err := format.Node(&appended, fset, decl); err != nil {
+				return nil, err
+			}
+			appended.WriteString("\n")
+			continue
+		}
+		start, end := tf.Offset(decl.Pos()), tf.Offset(decl.End())
+		var comments []*ast.CommentGroup
+		for _, cg := range f.Comments {
+			if cg.Pos() >= decl.Pos() && cg.End() <= decl.End() {
+				comments = append(comments, cg)
+			}
+		}
+		// 文档注释不在替换的范围内，打印时去掉
+		var buf bytes.Buffer
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			doc := decl.Doc
+			decl.Doc = nil
+			defer func() { decl.Doc = doc }()
+		case *ast.GenDecl:
+			doc := decl.Doc
+			decl.Doc = nil
+			defer func() { decl.Doc = doc }()
+		}
+		if err := format.Node(&buf, fset, &printer.CommentedNode{Node: decl, Comments: comments}); err != nil {
+			return nil, err
+		}
+		out.Write(src[last:start])
+		out.Write(buf.Bytes())
+		last = end
+	}
+	out.Write(src[last:])
+	out.Write(appended.Bytes())
+	return out.Bytes(), nil
+}
+
+// 声明是否被改写：包含生成的节点，或者是导入声明（其中的匿名导入可能被改为具名导入）
+func declRewritten(decl ast.Decl, origin map[ast.Node]bool) bool {
+	if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+		return true
+	}
+	rewritten := false
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if n != nil && !origin[n] {
+			rewritten = true
+		}
+		return !rewritten
+	})
+	return rewritten
+}
+
+// 生成的节点带有解析模板时的位置信息，和源码的位置混在一起会让打印的结果布局错乱，
+// 这里将它们清零，由 printer 按默认的格式排版；源码中原有的节点保持不变，注释仍然能够对应到原来的位置。
+func resetGeneratedPos(f *ast.File, origin map[ast.Node]bool) {
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || origin[n] {
+			return true
+		}
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		v = v.Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.Type() == posType && field.CanSet() {
+				field.SetInt(int64(token.NoPos))
+			}
+		}
+		return true
+	})
+}
+
+type diffOp struct {
+	kind byte // ' ' 相同，'-' 删除，'+' 新增
+	line string
+}
+
+// 基于最长公共子序列计算 a 到 b 的逐行差异
+func lineDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// 生成 unified diff 格式的差异，每个修改块前后保留 diffContextLines 行上下文。没有差异时返回空字符串。
+func unifiedDiff(oldName, newName, a, b string) string {
+	ops := lineDiff(splitLines(a), splitLines(b))
+	var out strings.Builder
+	// aLine, bLine 为 ops[k] 之前已经消费的行数
+	aLine, bLine := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			aLine++
+			bLine++
+			k++
+			continue
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		// 向前取上下文
+		start := k
+		for start > 0 && k-start < diffContextLines && ops[start-1].kind == ' ' {
+			start--
+		}
+		// 向后扩展，直到连续相同的行超过两倍上下文
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			same := end
+			for same < len(ops) && ops[same].kind == ' ' {
+				same++
+			}
+			if same == len(ops) || same-end > 2*diffContextLines {
+				if same-end > diffContextLines {
+					same = end + diffContextLines
+				}
+				end = same
+				break
+			}
+			end = same
+		}
+		aStart, bStart := aLine-(k-start), bLine-(k-start)
+		aCount, bCount := 0, 0
+		var body strings.Builder
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+			body.WriteByte(op.kind)
+			body.WriteString(op.line)
+			body.WriteByte('\n')
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n%s", hunkRange(aStart, aCount), hunkRange(bStart, bCount), body.String())
+		aLine, bLine = aStart+aCount, bStart+bCount
+		k = end
+	}
+	return out.String()
+}
+
+// start 为修改块之前的行数，行号从 1 开始
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffPackages(t *testing.T) {
+	var out bytes.Buffer
+	if err := diffPackages(&out, []string{"github.com/dengsgo/go-decorator/example/usages"}); err != nil {
+		t.Fatal("diffPackages() error", err)
+	}
+	s := out.String()
+	for _, want := range []string{
+		"+++ b/../../example/usages/datetime.go\n",
+		"-func datetime(timestamp int) string {\n",
+		"+func datetime(timestamp int) (",
+		`&decor.Context{Kind: decor.KFunc, TargetName: "datetime"`,
+		"+\tlogging(",
+		"+++ b/../../example/usages/record_test.go\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("diffPackages() output should contain %q, but got:\n%s", want, s)
+		}
+	}
+}
+
+func TestPackageFileGroups(t *testing.T) {
+	pi := &_packageInfo{
+		Dir:          "/p",
+		ImportPath:   "example.com/p",
+		Name:         "p",
+		GoFiles:      []string{"a.go"},
+		TestGoFiles:  []string{"a_test.go"},
+		XTestGoFiles: []string{"x_test.go"},
+	}
+	want := []packageFiles{
+		{packageName: "example.com/p", files: []string{filepath.Join("/p", "a.go"), filepath.Join("/p", "a_test.go")}},
+		{packageName: "example.com/p_test", files: []string{filepath.Join("/p", "x_test.go")}},
+	}
+	if got := packageFileGroups(pi); !reflect.DeepEqual(got, want) {
+		t.Fatalf("packageFileGroups() got %+v, want %+v", got, want)
+	}
+	pi.GoFiles, pi.TestGoFiles, pi.XTestGoFiles = nil, nil, nil
+	if got := packageFileGroups(pi); len(got) != 0 {
+		t.Fatalf("packageFileGroups() should return no groups, got %+v", got)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	cas := []struct {
+		a, b, r string
+	}{
+		{"a\nb\nc\n", "a\nb\nc\n", ""},
+		{"a\nb\nc\n", "a\nx\nc\n", "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"", "a\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+	for i, c := range cas {
+		if r := unifiedDiff("a", "b", c.a, c.b); r != c.r {
+			t.Fatalf("unifiedDiff() case %d want:\n%s\nbut got:\n%s", i, c.r, r)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// 目标函数上的编译器指令。装饰后目标原来的函数体移到生成的闭包中，外层函数变为调用装饰器的包装函数，
+// 下面这些指令约束的是函数体本身或它的调用方式，留在包装函数上无法保持原来的含义，而且往往到运行时才出问题：
+//   - //go:nosplit 、//go:systemstack 、//go:nowritebarrier 等要求函数不扩栈、不分配，包装函数做不到
+//   - //go:norace 、//go:nocheckptr 只作用于所在的函数，不覆盖闭包中的原函数体
+//   - //go:uintptrescapes 、//go:uintptrkeepalive 对参数的保证不会传递到闭包
+//   - //go:noescape 、//go:wasmimport 用于没有函数体的声明
+//
+// 装饰带有这些指令的目标时报错，decor.toml 和 //go:decor-all 的规则不匹配这样的函数。
+// //go:noinline 等其他指令原样保留在包装函数上，//go:linkname 引用的函数见 warnLinknamed 。
+var unsupportedTargetDirectives = map[string]bool{
+	"nosplit":            true,
+	"systemstack":        true,
+	"nowritebarrier":     true,
+	"nowritebarrierrec":  true,
+	"yeswritebarrierrec": true,
+	"norace":             true,
+	"nocheckptr":         true,
+	"uintptrescapes":     true,
+	"uintptrkeepalive":   true,
+	"noescape":           true,
+	"cgo_unsafe_args":    true,
+	"wasmimport":         true,
+	"wasmexport":         true,
+}
+
+const directiveScanFlag = "//go:"
+
+// 检查目标 fd 的文档注释中是否有装饰后无法保持的指令，返回第一个这样的指令的位置和错误
+func checkTargetDirectives(fd *ast.FuncDecl) (token.Pos, error) {
+	if fd.Doc == nil {
+		return token.NoPos, nil
+	}
+	for _, c := range fd.Doc.List {
+		if !strings.HasPrefix(c.Text, directiveScanFlag) {
+			continue
+		}
+		fields := strings.Fields(c.Text[len(directiveScanFlag):])
+		if len(fields) == 0 || !unsupportedTargetDirectives[fields[0]] {
+			continue
+		}
+		return c.Pos(), errors.New(directiveScanFlag + fields[0] +
+			" cannot be used on a decorated function, its body runs in a generated closure that the directive doesn't cover")
+	}
+	return token.NoPos, nil
+}
+
+// 目标 fd 上是否有装饰后无法保持的指令。按规则添加装饰器时（//go:decor-all 、decor.toml 和类型上的注释）跳过这样的函数，
+// 只有直接写在函数上的注释才报告错误。
+func hasUnsupportedDirective(fd *ast.FuncDecl) bool {
+	_, err := checkTargetDirectives(fd)
+	return err != nil
+}
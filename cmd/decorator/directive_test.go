@@ -0,0 +1,60 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestCheckTargetDirectives(t *testing.T) {
+	src := `package p
+
+//go:decor logging
+func plain() {}
+
+//go:noinline
+//go:decor logging
+func noinline() {}
+
+// nosplit 说明
+//go:nosplit
+//go:decor logging
+func nosplit() {}
+
+//go:decor logging
+//go:norace
+func norace() {}
+
+// go:nosplit 不是指令
+//go:decor logging
+func notDirective() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"nosplit": "p.go:11:1: //go:nosplit",
+		"norace":  "p.go:16:1: //go:norace",
+	}
+	for _, decl := range f.Decls {
+		fd := decl.(*ast.FuncDecl)
+		pos, err := checkTargetDirectives(fd)
+		w, ok := want[fd.Name.Name]
+		if !ok {
+			if err != nil || hasUnsupportedDirective(fd) {
+				t.Fatalf("checkTargetDirectives(%s) should pass, but got %v", fd.Name.Name, err)
+			}
+			continue
+		}
+		if err == nil || !hasUnsupportedDirective(fd) {
+			t.Fatalf("checkTargetDirectives(%s) should fail", fd.Name.Name)
+		}
+		if got := fset.Position(pos).String() + ": " + err.Error(); !strings.HasPrefix(got, w) {
+			t.Fatalf("checkTargetDirectives(%s) got %q, want prefix %q", fd.Name.Name, got, w)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// -d.disable 或环境变量 GODECOR=off 时 decorator 不改写任何代码，compile 直接编译原始的源文件，
+// 注释保持不变，便于对比装饰前后的性能。decor 包照常编译，直接引用 decor.Context 等的代码不受影响。
+//
+// go build 以 compile -V=full 的输出作为编译缓存的键之一，禁用时给它加上标记，
+// 切换开关后不需要 -a 也不会使用另一种模式的编译缓存，见 toolid.go 。
+
+const decorEnvKey = "GODECOR"
+
+// 是否禁用了装饰
+func decoratorDisabled() bool {
+	return cmdFlag.Disable || strings.EqualFold(os.Getenv(decorEnvKey), "off")
+}
+
+// 给 compile -V=full 的输出加上禁用的标记
+func disabledToolVersion(out string) string {
+	return markToolVersion(out, "off")
+}
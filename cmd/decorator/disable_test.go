@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestDecoratorDisabled(t *testing.T) {
+	t.Setenv(decorEnvKey, "")
+	if decoratorDisabled() {
+		t.Fatal("decoratorDisabled() should be false by default")
+	}
+	t.Setenv(decorEnvKey, "OFF")
+	if !decoratorDisabled() {
+		t.Fatal("decoratorDisabled() should be true with GODECOR=off")
+	}
+	t.Setenv(decorEnvKey, "")
+	cmdFlag.Disable = true
+	defer func() { cmdFlag.Disable = false }()
+	if !decoratorDisabled() {
+		t.Fatal("decoratorDisabled() should be true with -d.disable")
+	}
+}
+
+func TestDisabledToolVersion(t *testing.T) {
+	cas := []struct {
+		args []string
+		out  string
+		r    string
+	}{
+		{[]string{"-V=full"}, "compile version go1.22.1\n", "compile version go1.22.1 decorator=off\n"},
+		{[]string{"-V=full"}, "compile version devel go1.23-abc buildID=a1b2\n", "compile version devel go1.23-abc buildID=a1b2-decoroff\n"},
+		{[]string{"-V=full"}, "", ""},
+	}
+	for i, c := range cas {
+		if !isToolVersionQuery(c.args) {
+			t.Fatalf("cas[%d] isToolVersionQuery(%q) should be true", i, c.args)
+		}
+		if r := disabledToolVersion(c.out); r != c.r {
+			t.Fatalf("cas[%d] disabledToolVersion(%q) got %q, want %q", i, c.out, r, c.r)
+		}
+	}
+	if isToolVersionQuery([]string{"-p", "main", "-o", "a.o"}) {
+		t.Fatal("isToolVersionQuery() should be false for a compilation")
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// version 和 doctor 子命令：
+//
+//	decorator version
+//	decorator doctor
+//
+// version 输出 decorator 的版本，以及当前模块依赖的 decor 包的版本。
+// doctor 检查使用 decorator 所需的环境：Go 版本、GOTOOLDIR、GOFLAGS 中的 -toolexec 、当前模块是否依赖了 decor 包，
+// 以及 decor 包和 decorator 的版本是否一致。每一项都会输出结果，有问题时给出修复的方法，存在错误时以非 0 状态码退出。
+// -toolexec 配置错误时 go build 给出的错误往往难以理解，可以先用 doctor 检查。
+
+// decor 包所在的模块
+var decorModulePath = strings.TrimSuffix(decoratorPackagePath, "/decor")
+
+// decorator 支持的最低 Go 版本，decor 包使用了泛型
+const minGoVersion = "go1.18"
+
+// go env 中 doctor 用到的变量
+type goEnvInfo struct {
+	GOTOOLDIR,
+	GOFLAGS,
+	GOVERSION,
+	GOMOD,
+	GOEXE string
+}
+
+// go list -m -json 的输出
+type _moduleInfo struct {
+	Path,
+	Version,
+	Dir string
+	Main    bool
+	Replace *_moduleInfo
+}
+
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// 一项检查的结果
+type doctorResult struct {
+	name,
+	status,
+	detail,
+	fix string // 修复的方法，status 为 ok 时为空
+}
+
+func runVersion(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	mod, err := decorModule()
+	printVersion(os.Stdout, mod, err)
+	return nil
+}
+
+func printVersion(w io.Writer, mod *_moduleInfo, err error) {
+	fmt.Fprintf(w, "decorator %s (%s %s/%s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		fmt.Fprintf(w, "decor     not found in the current module: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "decor     %s\n", moduleVersionString(mod))
+}
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	results := doctorChecks()
+	printDoctorResults(os.Stdout, results)
+	fails := 0
+	for _, r := range results {
+		if r.status == doctorFail {
+			fails++
+		}
+	}
+	if fails > 0 {
+		return fmt.Errorf("found %d problem(s)", fails)
+	}
+	return nil
+}
+
+func doctorChecks() []*doctorResult {
+	env, err := goEnv()
+	if err != nil {
+		return []*doctorResult{{
+			name:   "go command",
+			status: doctorFail,
+			detail: err.Error(),
+			fix:    "install Go (" + minGoVersion + " or later) and make sure the go command is in PATH",
+		}}
+	}
+	results := []*doctorResult{
+		checkGoVersion(env.GOVERSION),
+		checkGoToolDir(env.GOTOOLDIR, env.GOEXE),
+		checkToolexec(env.GOFLAGS, exec.LookPath),
+	}
+	mod, modErr := decorModule()
+	results = append(results, checkDecorModule(env.GOMOD, mod, modErr))
+	if modErr == nil {
+		results = append(results, checkDecorVersion(mod, version))
+	}
+	return results
+}
+
+func printDoctorResults(w io.Writer, results []*doctorResult) {
+	for _, r := range results {
+		fmt.Fprintf(w, "[%s]\t%s: %s\n", r.status, r.name, r.detail)
+		if r.fix != "" {
+			fmt.Fprintf(w, "\tfix: %s\n", r.fix)
+		}
+	}
+}
+
+func goEnv() (*goEnvInfo, error) {
+	cmd := exec.Command("go", "env", "-json", "GOTOOLDIR", "GOFLAGS", "GOVERSION", "GOMOD", "GOEXE")
+	cmd.Dir = projectDir
+	cmd.Env = os.Environ()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	bf, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New("go env fail: " + err.Error() + " " + strings.TrimSpace(stderr.String()))
+	}
+	env := &goEnvInfo{}
+	if err := json.Unmarshal(bf, env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// 当前模块依赖的 decor 包所在的模块
+func decorModule() (*_moduleInfo, error) {
+	cmd := exec.Command("go", "list", "-m", "-json", decorModulePath)
+	cmd.Dir = projectDir
+	cmd.Env = os.Environ()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	bf, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	mod := &_moduleInfo{}
+	if err := json.Unmarshal(bf, mod); err != nil {
+		return nil, err
+	}
+	return mod, nil
+}
+
+func moduleVersionString(mod *_moduleInfo) string {
+	switch {
+	case mod.Main:
+		return mod.Path + " (main module)"
+	case mod.Replace != nil && mod.Replace.Version == "":
+		return mod.Path + " " + mod.Version + " => " + mod.Replace.Path
+	case mod.Replace != nil:
+		return mod.Path + " " + mod.Version + " => " + mod.Replace.Path + " " + mod.Replace.Version
+	}
+	return mod.Path + " " + mod.Version
+}
+
+// 解析 go1.21.3 、go1.22rc1 这样的版本号，返回主版本和次版本，无法解析（如 devel 版本）时 ok 为 false
+func parseGoVersion(v string) (major, minor int, ok bool) {
+	fields := strings.Fields(v)
+	if len(fields) == 0 {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(fields[0], "go"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	minorStr := parts[1]
+	if k := strings.IndexFunc(minorStr, func(r rune) bool { return r < '0' || r > '9' }); k >= 0 {
+		minorStr = minorStr[:k]
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(minorStr)
+	return major, minor, err1 == nil && err2 == nil
+}
+
+func checkGoVersion(goVersion string) *doctorResult {
+	r := &doctorResult{name: "go version", status: doctorOK, detail: goVersion}
+	major, minor, ok := parseGoVersion(goVersion)
+	if !ok {
+		r.status = doctorWarn
+		r.detail = "can't recognize " + strconv.Quote(goVersion) + ", a development version of Go may not be supported"
+		r.fix = "use a released Go version, " + minGoVersion + " or later"
+		return r
+	}
+	wantMajor, wantMinor, _ := parseGoVersion(minGoVersion)
+	if major < wantMajor || major == wantMajor && minor < wantMinor {
+		r.status = doctorFail
+		r.detail = goVersion + " is older than " + minGoVersion
+		r.fix = "upgrade Go to " + minGoVersion + " or later, the decor package uses generics"
+	}
+	return r
+}
+
+func checkGoToolDir(goToolDir, goExe string) *doctorResult {
+	r := &doctorResult{name: "GOTOOLDIR", status: doctorOK, detail: goToolDir}
+	fix := "GOTOOLDIR should be the pkg/tool/<GOOS>_<GOARCH> directory of the Go installation, " +
+		"unset the GOTOOLDIR environment variable or reinstall Go"
+	if goToolDir == "" {
+		r.status, r.detail, r.fix = doctorFail, "empty", fix
+		return r
+	}
+	for _, tool := range []string{"compile", "link"} {
+		if info, err := os.Stat(filepath.Join(goToolDir, tool+goExe)); err != nil || info.IsDir() {
+			r.status, r.detail, r.fix = doctorFail, goToolDir+" has no "+tool+goExe, fix
+			return r
+		}
+	}
+	return r
+}
+
+// GOFLAGS 中 -toolexec 的值，没有时 ok 为 false
+func goFlagsToolexec(goFlags string) (tool string, ok bool) {
+	for _, f := range strings.Fields(goFlags) {
+		name := strings.TrimPrefix(strings.TrimPrefix(f, "-"), "-")
+		if strings.HasPrefix(name, "toolexec=") {
+			tool, ok = strings.TrimPrefix(name, "toolexec="), true
+		}
+	}
+	return
+}
+
+func checkToolexec(goFlags string, lookPath func(string) (string, error)) *doctorResult {
+	r := &doctorResult{name: "toolexec", status: doctorOK}
+	installFix := "go install " + decorModulePath + "/cmd/decorator@latest, and make sure $GOPATH/bin is in PATH"
+	tool, ok := goFlagsToolexec(goFlags)
+	if !ok {
+		// 没有写入 GOFLAGS 时需要在每个 go 命令中传入 -toolexec decorator ，这时 decorator 需要能在 PATH 中找到
+		r.status = doctorWarn
+		r.detail = "GOFLAGS has no -toolexec, pass -toolexec decorator to go build, go run and go test"
+		r.fix = "go env -w GOFLAGS=-toolexec=decorator"
+		if _, err := lookPath("decorator"); err != nil {
+			r.status = doctorFail
+			r.detail = "GOFLAGS has no -toolexec and decorator is not found in PATH"
+			r.fix = installFix
+		}
+		return r
+	}
+	r.detail = "GOFLAGS -toolexec=" + tool
+	if tool == "" {
+		r.status = doctorFail
+		r.detail = "GOFLAGS has an empty -toolexec"
+		r.fix = "go env -w GOFLAGS=-toolexec=decorator"
+		return r
+	}
+	if chainToolName(tool) != "decorator" {
+		r.status = doctorWarn
+		r.detail += ", which is not decorator"
+		r.fix = "make sure " + tool + " runs decorator, or go env -w GOFLAGS=-toolexec=decorator"
+		return r
+	}
+	path, err := lookPath(tool)
+	if err != nil {
+		r.status = doctorFail
+		r.detail += ", but it is not found: " + err.Error()
+		r.fix = installFix + ", or use its absolute path in GOFLAGS"
+		return r
+	}
+	r.detail += " (" + path + ")"
+	return r
+}
+
+func checkDecorModule(goMod string, mod *_moduleInfo, err error) *doctorResult {
+	r := &doctorResult{name: "decor module", status: doctorOK}
+	if goMod == "" || goMod == os.DevNull {
+		r.status = doctorFail
+		r.detail = "not in a Go module"
+		r.fix = "run decorator in the module directory, or create one with go mod init"
+		return r
+	}
+	if err != nil {
+		r.status = doctorFail
+		r.detail = goMod + " doesn't require " + decorModulePath + ": " + err.Error()
+		r.fix = "go get " + decoratorPackagePath + ", and import it in the packages using //go:decor"
+		return r
+	}
+	r.detail = moduleVersionString(mod)
+	return r
+}
+
+// decor 包和 decorator 的版本不一致时，生成的代码可能用到 decor 包中不存在的 API
+// 从源码构建的 decorator 没有可靠的版本，不做检查
+func checkDecorVersion(mod *_moduleInfo, version string) *doctorResult {
+	toolVersion := strings.Fields(version)[0]
+	r := &doctorResult{name: "decor version", status: doctorOK, detail: "decor and decorator are both " + toolVersion}
+	if mod.Main || mod.Replace != nil {
+		r.detail = "decor is replaced by local code, the version is not checked"
+		return r
+	}
+	if version == sourceVersion {
+		r.detail = "decorator is built from source, the version is not checked"
+		return r
+	}
+	if semverCore(mod.Version) != semverCore(toolVersion) {
+		r.status = doctorWarn
+		r.detail = "decor " + mod.Version + " doesn't match decorator " + toolVersion +
+			", the generated code may use APIs missing in the decor package"
+		r.fix = "go get " + decoratorPackagePath + "@" + toolVersion +
+			", or go install " + decorModulePath + "/cmd/decorator@" + mod.Version
+	}
+	return r
+}
+
+// 版本号中 -pre 、+build 之前的部分，如 v0.22.0-beta 为 v0.22.0
+func semverCore(v string) string {
+	if k := strings.IndexAny(v, "-+"); k >= 0 {
+		return v[:k]
+	}
+	return v
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestParseGoVersion(t *testing.T) {
+	cas := []struct {
+		v            string
+		major, minor int
+		ok           bool
+	}{
+		{"go1.18", 1, 18, true},
+		{"go1.21.3", 1, 21, true},
+		{"go1.22rc1", 1, 22, true},
+		{"go1.23.0 X:rangefunc", 1, 23, true},
+		{"devel go1.24-8d7b8a3 Tue Jan 1", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for i, c := range cas {
+		major, minor, ok := parseGoVersion(c.v)
+		if major != c.major || minor != c.minor || ok != c.ok {
+			t.Fatalf("cas[%d] parseGoVersion(%q) want %d %d %v, got %d %d %v", i, c.v, c.major, c.minor, c.ok, major, minor, ok)
+		}
+	}
+	for v, want := range map[string]string{"go1.17.13": doctorFail, "go1.18": doctorOK, "go1.22.1": doctorOK, "devel": doctorWarn} {
+		if r := checkGoVersion(v); r.status != want {
+			t.Fatalf("checkGoVersion(%q) want %s, got %+v", v, want, r)
+		}
+	}
+}
+
+func TestCheckGoToolDir(t *testing.T) {
+	dir := t.TempDir()
+	if r := checkGoToolDir("", ""); r.status != doctorFail {
+		t.Fatalf("checkGoToolDir() empty want fail, got %+v", r)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "compile.exe"), nil, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if r := checkGoToolDir(dir, ".exe"); r.status != doctorFail || !strings.Contains(r.detail, "link.exe") {
+		t.Fatalf("checkGoToolDir() without link want fail, got %+v", r)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "link.exe"), nil, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if r := checkGoToolDir(dir, ".exe"); r.status != doctorOK || r.fix != "" {
+		t.Fatalf("checkGoToolDir() want ok, got %+v", r)
+	}
+}
+
+func TestCheckToolexec(t *testing.T) {
+	paths := map[string]string{"decorator": "/go/bin/decorator", "/go/bin/decorator": "/go/bin/decorator"}
+	lookPath := func(file string) (string, error) {
+		if p, ok := paths[file]; ok {
+			return p, nil
+		}
+		return "", errors.New("not found")
+	}
+	cas := []struct {
+		goFlags, want string
+	}{
+		{"", doctorWarn},
+		{"-mod=mod -toolexec=decorator", doctorOK},
+		{"--toolexec=/go/bin/decorator", doctorOK},
+		{"-toolexec=/opt/decorator", doctorFail},
+		{"-toolexec=", doctorFail},
+		{"-toolexec=/usr/bin/time", doctorWarn},
+	}
+	for i, c := range cas {
+		if r := checkToolexec(c.goFlags, lookPath); r.status != c.want {
+			t.Fatalf("cas[%d] checkToolexec(%q) want %s, got %+v", i, c.goFlags, c.want, r)
+		}
+	}
+	delete(paths, "decorator")
+	if r := checkToolexec("", lookPath); r.status != doctorFail || !strings.Contains(r.fix, "go install") {
+		t.Fatalf("checkToolexec() without decorator in PATH want fail, got %+v", r)
+	}
+}
+
+func TestCheckDecorModule(t *testing.T) {
+	mod := &_moduleInfo{Path: decorModulePath, Version: "v0.22.0"}
+	if r := checkDecorModule("", mod, nil); r.status != doctorFail {
+		t.Fatalf("checkDecorModule() outside a module want fail, got %+v", r)
+	}
+	if r := checkDecorModule(os.DevNull, mod, nil); r.status != doctorFail {
+		t.Fatalf("checkDecorModule() with GOMOD=%s want fail, got %+v", os.DevNull, r)
+	}
+	if r := checkDecorModule("/m/go.mod", nil, errors.New("not a known dependency")); r.status != doctorFail || !strings.Contains(r.fix, "go get") {
+		t.Fatalf("checkDecorModule() without decor want fail, got %+v", r)
+	}
+	if r := checkDecorModule("/m/go.mod", mod, nil); r.status != doctorOK || r.detail != decorModulePath+" v0.22.0" {
+		t.Fatalf("checkDecorModule() want ok, got %+v", r)
+	}
+}
+
+func TestCheckDecorVersion(t *testing.T) {
+	toolVersion := "v0.23.0"
+	cas := []struct {
+		mod     *_moduleInfo
+		version string
+		want    string
+	}{
+		{&_moduleInfo{Version: toolVersion}, toolVersion, doctorOK},
+		{&_moduleInfo{Version: toolVersion + "-beta.1"}, toolVersion, doctorOK},
+		{&_moduleInfo{Version: "v0.1.0"}, toolVersion, doctorWarn},
+		{&_moduleInfo{Version: "v0.1.0", Replace: &_moduleInfo{Path: "../go-decorator"}}, toolVersion, doctorOK},
+		{&_moduleInfo{Main: true}, toolVersion, doctorOK},
+		{&_moduleInfo{Version: "v0.1.0"}, sourceVersion, doctorOK},
+	}
+	for i, c := range cas {
+		if r := checkDecorVersion(c.mod, c.version); r.status != c.want {
+			t.Fatalf("cas[%d] checkDecorVersion(%+v) want %s, got %+v", i, c.mod, c.want, r)
+		}
+	}
+}
+
+func TestBuildVersion(t *testing.T) {
+	cas := []struct {
+		info *debug.BuildInfo
+		ok   bool
+		want string
+	}{
+		{nil, false, sourceVersion},
+		{&debug.BuildInfo{Main: debug.Module{Version: "(devel)"}}, true, sourceVersion},
+		{&debug.BuildInfo{Main: debug.Module{Version: ""}}, true, sourceVersion},
+		{&debug.BuildInfo{Main: debug.Module{Version: "v0.0.0-20261016114752-25a81ce0b6be+dirty"}}, true, sourceVersion},
+		{&debug.BuildInfo{Main: debug.Module{Version: "v0.22.1-0.20261016114752-25a81ce0b6be"}}, true, sourceVersion},
+		{&debug.BuildInfo{Main: debug.Module{Version: "v0.23.0+dirty"}}, true, sourceVersion},
+		{&debug.BuildInfo{Main: debug.Module{Version: "v0.23.0"}}, true, "v0.23.0"},
+		{&debug.BuildInfo{Main: debug.Module{Version: "v0.23.0-beta.1"}}, true, "v0.23.0-beta.1"},
+	}
+	for i, c := range cas {
+		if got := buildVersion(c.info, c.ok); got != c.want {
+			t.Fatalf("cas[%d] buildVersion() want %q, got %q", i, c.want, got)
+		}
+	}
+}
+
+func TestPrintVersion(t *testing.T) {
+	var out bytes.Buffer
+	printVersion(&out, &_moduleInfo{Path: decorModulePath, Version: "v0.1.0", Replace: &_moduleInfo{Path: "../go-decorator"}}, nil)
+	if s := out.String(); !strings.HasPrefix(s, "decorator "+version) || !strings.Contains(s, decorModulePath+" v0.1.0 => ../go-decorator\n") {
+		t.Fatalf("printVersion() not match, got %q", s)
+	}
+	out.Reset()
+	printVersion(&out, nil, errors.New("go.mod file not found"))
+	if s := out.String(); !strings.Contains(s, "not found in the current module: go.mod file not found") {
+		t.Fatalf("printVersion() with error not match, got %q", s)
+	}
+}
@@ -0,0 +1,9 @@
+// Package e2e 是 decorator 的端到端测试：以 -toolexec 构建 testdata 中的示例模块，运行并检查输出。
+//
+// testdata 下的每个目录是一个示例模块的源码，测试时复制到临时目录，go.mod 由测试生成，
+// 以 replace 指向当前仓库。示例模块也可以自带 go.mod （如有嵌套模块、replace 其他目录的示例），
+// 测试在其末尾追加指向当前仓库的 replace 。目录中的 stdout.txt 为运行构建结果的预期输出；
+// errors.txt 表示构建应该失败，其中的每一行都应出现在构建的错误输出中。
+//
+// 测试需要构建 decorator 和示例模块，耗时较长，go test -short 时跳过。
+package e2e
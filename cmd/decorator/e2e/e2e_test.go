@@ -0,0 +1,202 @@
+package e2e
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// 测试使用的 decorator 可执行文件，由 TestMain 构建
+var decoratorBin string
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		os.Exit(m.Run())
+	}
+	dir, err := os.MkdirTemp("", "decorator-e2e")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	decoratorBin = filepath.Join(dir, "decorator"+exeSuffix())
+	if out, err := exec.Command("go", "build", "-o", decoratorBin, "github.com/dengsgo/go-decorator/cmd/decorator").CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "build decorator: %v\n%s", err, out)
+		_ = os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestFixtures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the fixture modules with -toolexec")
+	}
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			if want, err := os.ReadFile(filepath.Join(fixture, "errors.txt")); err == nil {
+				testBuildFails(t, fixture, string(want))
+				return
+			}
+			want, err := os.ReadFile(filepath.Join(fixture, "stdout.txt"))
+			if err != nil {
+				t.Fatal("fixture has neither stdout.txt nor errors.txt", err)
+			}
+			// 两种改写方式的结果应该相同
+			for _, mode := range []struct {
+				name  string
+				flags []string
+			}{
+				{"print", nil},
+				{"patch", []string{"-d.patch"}},
+			} {
+				t.Run(mode.name, func(t *testing.T) {
+					bin, stderr, err := buildFixture(t, fixture, mode.flags...)
+					if err != nil {
+						t.Fatalf("build %s: %v\n%s", fixture, err, stderr)
+					}
+					out, err := exec.Command(bin).Output()
+					if err != nil {
+						t.Fatalf("run %s: %v", fixture, err)
+					}
+					if string(out) != string(want) {
+						t.Fatalf("%s stdout:\n%s\nwant:\n%s", fixture, out, want)
+					}
+				})
+			}
+		})
+	}
+}
+
+// 构建应该失败，want 中的每一行都出现在错误输出中
+func testBuildFails(t *testing.T, fixture, want string) {
+	_, stderr, err := buildFixture(t, fixture)
+	if err == nil {
+		t.Fatalf("build %s should fail", fixture)
+	}
+	for _, line := range strings.Split(want, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.Contains(stderr, line) {
+			t.Fatalf("build %s should report %q, but got:\n%s", fixture, line, stderr)
+		}
+	}
+}
+
+// 把示例模块 fixture 复制到临时目录，以 -toolexec 构建，返回可执行文件的路径和构建的错误输出。
+// 每次都在新的目录中构建，go 的编译缓存不会复用旧版本的 decorator 改写的结果。
+func buildFixture(t *testing.T, fixture string, flags ...string) (string, string, error) {
+	t.Helper()
+	dir := copyFixtureModule(t, fixture)
+	toolexec := append([]string{decoratorBin, "-d.tempDir", t.TempDir()}, flags...)
+	for i, arg := range toolexec {
+		toolexec[i] = "'" + arg + "'"
+	}
+	bin := filepath.Join(t.TempDir(), "app"+exeSuffix())
+	cmd := exec.Command("go", "build", "-toolexec", strings.Join(toolexec, " "), "-o", bin, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=", "GOPROXY=off", "GODECOR=")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return bin, stderr.String(), err
+}
+
+// 把示例模块 fixture 复制到临时目录，返回这个目录
+func copyFixtureModule(t *testing.T, fixture string) string {
+	t.Helper()
+	root, err := filepath.Abs(filepath.Join("..", "..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	// 示例模块自带的 go.mod （包括嵌套模块的）追加指向当前仓库的 replace ，没有时生成
+	replace := "\nreplace github.com/dengsgo/go-decorator => " + filepath.ToSlash(root) + "\n"
+	if err := copyFixture(fixture, dir, replace); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); os.IsNotExist(err) {
+		gomod := fmt.Sprintf("module e2e.test/%s\n\ngo 1.18\n\nrequire github.com/dengsgo/go-decorator v0.0.0\n%s", filepath.Base(fixture), replace)
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// 默认参数下连续构建两次，第二次使用第一次的改写结果：链接之后清理工作目录时保留缓存，
+// 缓存的键也不受每次构建都不同的 $WORK 影响。-a 使 go 重新编译所有的包，使用独立的 GOCACHE 。
+func TestRewriteCacheAcrossBuilds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a fixture module with -toolexec")
+	}
+	dir := copyFixtureModule(t, filepath.Join("testdata", "methods"))
+	work, gocache := t.TempDir(), t.TempDir()
+	for i := 0; i < 2; i++ {
+		bin := filepath.Join(t.TempDir(), "app"+exeSuffix())
+		cmd := exec.Command("go", "build", "-a", "-toolexec", "'"+decoratorBin+"' -d.tempDir '"+work+"' -d.log debug", "-o", bin, ".")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=", "GOPROXY=off", "GODECOR=", "GOCACHE="+gocache)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("build %d: %v\n%s", i, err, out)
+		}
+		if hit := strings.Contains(string(out), "rewrite cache hit main"); hit != (i == 1) {
+			t.Fatalf("build %d should hit the rewrite cache: %v\n%s", i, i == 1, out)
+		}
+		if _, err := exec.Command(bin).Output(); err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+}
+
+// 复制示例模块中的源文件和 go.mod ，包括子目录，预期结果不复制。go.mod 的末尾追加 replace 。
+func copyFixture(src, dst, replace string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if err := os.Mkdir(filepath.Join(dst, e.Name()), 0777); err != nil {
+				return err
+			}
+			if err := copyFixture(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()), replace); err != nil {
+				return err
+			}
+			continue
+		}
+		if !strings.HasSuffix(e.Name(), ".go") && e.Name() != "go.mod" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			return err
+		}
+		if e.Name() == "go.mod" {
+			b = append(b, replace...)
+		}
+		if err := os.WriteFile(filepath.Join(dst, e.Name()), b, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exeSuffix() string {
+	if runtime.GOOS == "windows" {
+		return ".exe"
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func trace(ctx *decor.Context) {
+	fmt.Println("->", ctx.TargetName, ctx.TypeParams, ctx.TypeArgs)
+	ctx.TargetDo()
+	fmt.Println("<-", ctx.TargetName, ctx.TargetOut)
+}
+
+//go:decor trace
+func Map[T, R any](s []T, f func(T) R) []R {
+	r := make([]R, 0, len(s))
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+type Number interface {
+	~int | ~float64
+}
+
+//go:decor trace
+func Sum[N Number](ns ...N) (total N) {
+	for _, n := range ns {
+		total += n
+	}
+	return
+}
+
+type Stack[T any] struct {
+	items []T
+}
+
+//go:decor trace
+func (s *Stack[T]) Push(v T) {
+	s.items = append(s.items, v)
+}
+
+//go:decor trace
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+type Pair[K comparable, V any] struct {
+	Key K
+	Val V
+}
+
+//go:decor trace
+func (p Pair[K, _]) First() K {
+	return p.Key
+}
+
+func main() {
+	fmt.Println(Map([]int{1, 2, 3}, func(i int) string { return strings.Repeat("x", i) }))
+	fmt.Println(Sum(1.5, 2.5))
+	s := &Stack[string]{}
+	s.Push("a")
+	fmt.Println(s.Pop())
+	fmt.Println(s.Pop())
+	fmt.Println(Pair[string, int]{"k", 1}.First())
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"e2e.test/inline/stats"
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor-pure
+func upper(ctx *decor.Context) {
+	ctx.TargetDo()
+	stats.Observe(strings.ToUpper(ctx.TargetName))
+}
+
+//go:decor stats.Named#{label: "add"}
+func add(a, b int) int {
+	return a + b
+}
+
+//go:decor stats.Named#{label: "sum"}
+//go:decor stats.Result
+//go:decor upper
+func sum(xs ...int) (n int) {
+	for _, x := range xs {
+		n += x
+	}
+	return
+}
+
+type counter struct{ n int }
+
+//go:decor upper
+func (c *counter) inc() {
+	c.n++
+}
+
+func main() {
+	c := &counter{}
+	c.inc()
+	fmt.Println(add(1, 2), sum(1, 2), c.n)
+	fmt.Println(stats.Calls())
+}
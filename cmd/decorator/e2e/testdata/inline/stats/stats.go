@@ -0,0 +1,33 @@
+// Package stats 提供可以内联到目标中的 //go:decor-pure 装饰器
+package stats
+
+import (
+	"strings"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+var calls []string
+
+func Observe(name string) {
+	calls = append(calls, name)
+}
+
+func Calls() string {
+	return strings.Join(calls, ",")
+}
+
+//go:decor-pure
+func Named(ctx *decor.Context, label string) {
+	name := label + ":" + ctx.TargetName
+	ctx.TargetDo()
+	Observe(name)
+}
+
+//go:decor-pure
+func Result(c *decor.Context) {
+	c.TargetDo()
+	if n, ok := c.TargetOut[0].(int); ok {
+		Observe(strings.Repeat("*", n))
+	}
+}
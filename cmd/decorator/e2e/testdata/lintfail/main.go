@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor-lint required: {level: {"debug", "info"}}
+func leveled(ctx *decor.Context, level string) {
+	ctx.TargetDo()
+}
+
+func plain(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+//go:decor leveled#{level: "trace"}
+func badLevel() {}
+
+//go:decor plain
+//go:decor plain
+func twice() {}
+
+//go:decor missing
+func unknown() {}
+
+//go:decor plain
+func plain2(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+func main() {
+	badLevel()
+	twice()
+	unknown()
+	fmt.Println("unreachable")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func receiver(ctx *decor.Context) {
+	if ctx.Kind == decor.KMethod {
+		fmt.Printf("%s on %v(%T) %v\n", ctx.TargetName, ctx.Receiver, ctx.Receiver, ctx.TargetIn)
+	}
+	ctx.TargetDo()
+}
+
+func counted(ctx *decor.Context) {
+	ctx.TargetDo()
+	fmt.Println("counted", ctx.TargetName, ctx.TargetOut)
+}
+
+type Counter struct {
+	n int
+}
+
+//go:decor receiver
+func (c *Counter) Add(d int) int {
+	c.n += d
+	return c.n
+}
+
+//go:decor receiver
+func (c Counter) Value() int {
+	return c.n
+}
+
+// 未命名和名为 _ 的接收者同样可以被装饰器拿到
+//
+//go:decor receiver
+func (Counter) Kind() string {
+	return "counter"
+}
+
+//go:decor receiver
+func (_ *Counter) Reset(_ bool) {}
+
+// 类型上的注解装饰它的所有方法
+//
+//go:decor counted
+type Greeter struct {
+	name string
+}
+
+func (g Greeter) Hello() string {
+	return "hello " + g.name
+}
+
+func (g *Greeter) Rename(name string) {
+	g.name = name
+}
+
+func (c Counter) String() string {
+	return fmt.Sprintf("Counter(%d)", c.n)
+}
+
+func main() {
+	c := &Counter{}
+	fmt.Println(c.Add(2))
+	fmt.Println(c.Add(3))
+	fmt.Println(c.Value())
+	fmt.Println(c.Kind())
+	c.Reset(true)
+
+	g := &Greeter{name: "a"}
+	fmt.Println(g.Hello())
+	g.Rename("b")
+	fmt.Println(g.Hello())
+}
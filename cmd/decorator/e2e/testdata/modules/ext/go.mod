@@ -0,0 +1,5 @@
+module e2e.test/ext
+
+go 1.18
+
+require github.com/dengsgo/go-decorator v0.0.0
@@ -0,0 +1,14 @@
+module e2e.test/modules
+
+go 1.18
+
+require (
+	e2e.test/ext v0.0.0
+	e2e.test/modules/nested v0.0.0
+	github.com/dengsgo/go-decorator v0.0.0
+)
+
+replace (
+	e2e.test/ext => ./ext
+	e2e.test/modules/nested => ./nested
+)
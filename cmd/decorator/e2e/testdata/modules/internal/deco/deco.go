@@ -0,0 +1,13 @@
+// Package deco 是主模块的 internal 包中的装饰器
+package deco
+
+import (
+	"fmt"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func Internal(ctx *decor.Context) {
+	fmt.Println("internal", ctx.TargetName)
+	ctx.TargetDo()
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+
+	"e2e.test/ext"
+	"e2e.test/modules/internal/deco"
+	"e2e.test/modules/nested/nd"
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor ext.Replaced
+//go:decor deco.Internal
+//go:decor nd.Nested
+func hello() {
+	fmt.Println("hello")
+}
+
+func main() {
+	hello()
+}
@@ -0,0 +1,13 @@
+// Package nd 是嵌套在主模块目录中的另一个模块中的装饰器
+package nd
+
+import (
+	"fmt"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func Nested(ctx *decor.Context) {
+	fmt.Println("nested", ctx.TargetName)
+	ctx.TargetDo()
+}
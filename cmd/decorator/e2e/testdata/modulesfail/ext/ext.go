@@ -0,0 +1,13 @@
+// Package ext 是通过 replace 指向本地目录的模块中的装饰器
+package ext
+
+import (
+	"fmt"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func Replaced(ctx *decor.Context) {
+	fmt.Println("replaced", ctx.TargetName)
+	ctx.TargetDo()
+}
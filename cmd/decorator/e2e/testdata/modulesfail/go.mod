@@ -0,0 +1,10 @@
+module e2e.test/modulesfail
+
+go 1.18
+
+require (
+	e2e.test/ext v0.0.0
+	github.com/dengsgo/go-decorator v0.0.0
+)
+
+replace e2e.test/ext => ./ext
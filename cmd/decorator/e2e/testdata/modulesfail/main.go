@@ -0,0 +1,17 @@
+package main
+
+import (
+	"e2e.test/ext"
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor ext.Replaced
+func found() {}
+
+//go:decor ext.Missing
+func missing() {}
+
+func main() {
+	found()
+	missing()
+}
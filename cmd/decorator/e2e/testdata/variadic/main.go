@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 变长参数在 TargetIn 中是一个切片，可以整体替换
+func doubled(ctx *decor.Context) {
+	fmt.Println("in", ctx.TargetIn)
+	if nums, ok := ctx.TargetIn[len(ctx.TargetIn)-1].([]int); ok {
+		out := make([]int, len(nums))
+		for i, n := range nums {
+			out[i] = n * 2
+		}
+		ctx.TargetIn[len(ctx.TargetIn)-1] = out
+	}
+	ctx.TargetDo()
+}
+
+func suffixed(ctx *decor.Context, suffix string) {
+	ctx.TargetDo()
+	ctx.TargetOut[0] = ctx.TargetOut[0].(string) + suffix
+}
+
+//go:decor doubled
+func sum(label string, nums ...int) string {
+	total := 0
+	for _, n := range nums {
+		total += n
+	}
+	return fmt.Sprintf("%s=%d", label, total)
+}
+
+//go:decor suffixed#{suffix: "!"}
+//go:decor doubled
+func join(sep string, parts ...int) string {
+	s := make([]string, 0, len(parts))
+	for _, p := range parts {
+		s = append(s, fmt.Sprint(p))
+	}
+	return strings.Join(s, sep)
+}
+
+//go:decor suffixed#{suffix: "?"}
+func names(prefix string, ns ...string) string {
+	return prefix + strings.Join(ns, ",")
+}
+
+func main() {
+	fmt.Println(sum("none"))
+	fmt.Println(sum("three", 1, 2, 3))
+	nums := []int{10, 20}
+	fmt.Println(sum("spread", nums...))
+	fmt.Println(nums)
+	fmt.Println(join("-", 1, 2, 3))
+	fmt.Println(names("n:", "a", "b"))
+	fmt.Println(names("empty:"))
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 装饰器上的 //go:decor-lint external: {cmd: "mylint -strict"} 声明一个外部的 lint 命令，
+// 用于检查 required 、nonzero 等内置规则无法表达的约束。
+//
+// 命令由依赖中的装饰器声明，编译时执行它们需要 -d.extlint 显式开启，否则忽略 external 规则。
+// 改写一个包时收集每个装饰器的所有用法，改写结束后每个装饰器的命令只执行一次：cmd 按空白分割为程序和参数，
+// 在装饰器所在包的目录下执行，标准输入为描述所有用法的 JSON（见 extLintInput ）。命令以非 0 状态码退出时编译失败，
+// 它的输出作为错误信息，报告在第一处用法的位置。可以声明多个 external 规则，按从上到下的顺序执行。
+
+// 外部 lint 命令的超时时间
+const extLintTimeout = 30 * time.Second
+
+// 传给外部 lint 命令的 JSON ，包中使用这个装饰器的所有地方
+type extLintInput struct {
+	Uses []*extLintRequest `json:"uses"`
+}
+
+// 一处使用装饰器的地方
+type extLintRequest struct {
+	Decorator extLintDecorator  `json:"decorator"`
+	Target    extLintTarget     `json:"target"`
+	Params    map[string]string `json:"params"` // 注解中的参数，值为 Go 字面量，如 "\"hello\"" 、10
+}
+
+type extLintDecorator struct {
+	Package  string `json:"package"` // 装饰器所在包的路径，当前包时为空
+	Name     string `json:"name"`    // 注解中的装饰器名称，如 logging 、pkg.Logging
+	Position string `json:"position"`
+}
+
+type extLintTarget struct {
+	Name     string         `json:"name"`     // 如 datetime 、(*T).Name
+	Receiver string         `json:"receiver"` // 接收者类型，函数为空
+	Params   []extLintField `json:"params"`   // 可变参数 ...T 记为 []T
+	Results  []extLintField `json:"results"`
+	Position string         `json:"position"`
+}
+
+type extLintField struct {
+	Name string `json:"name"` // 未命名时为空
+	Type string `json:"type"`
+}
+
+// 包中使用了声明 external 规则的装饰器的地方，按装饰器分组
+type extLints struct {
+	runs  map[string]*extLintRun // 键为 装饰器所在包的路径.装饰器名称
+	order []string
+}
+
+// 一个装饰器的 external 规则和它在包中的用法
+type extLintRun struct {
+	name string    // 第一处用法中装饰器的名称
+	pos  token.Pos // 第一处用法的位置
+	dir  string    // 装饰器所在包的目录
+	cmds []string
+	uses []*extLintRequest
+}
+
+// 记录 target 上使用装饰器的注解 da ，装饰器声明了 external 规则时在 run 中检查。
+// 找不到装饰器或装饰器没有声明时忽略。签名未知时（如值为函数的变量）参数和返回值为空。
+func (l *extLints) add(fset *token.FileSet, pkgPath string, da *decorAnnotation, target *ast.FuncDecl) error {
+	decorFset, decl, _, err := pkgILoader.findFunc(pkgPath, da.name)
+	if err != nil {
+		return nil
+	}
+	cmds, err := externalLintCommands(decl.Doc)
+	if err != nil {
+		return errors.New(fmt.Sprintf("decorator %s %s", da.name, err.Error()))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	key := pkgPath + "." + decl.Name.Name
+	r, ok := l.runs[key]
+	if !ok {
+		if l.runs == nil {
+			l.runs = map[string]*extLintRun{}
+		}
+		r = &extLintRun{name: da.name, pos: da.doc.Pos(), dir: filepath.Dir(decorFset.Position(decl.Pos()).Filename), cmds: cmds}
+		l.runs[key] = r
+		l.order = append(l.order, key)
+	}
+	r.uses = append(r.uses, newExtLintRequest(fset, pkgPath, da, target))
+	return nil
+}
+
+// 每个装饰器执行一次它的 external 命令，按第一次使用的顺序，失败的命令添加到 diags 中
+func (l *extLints) run(diags *packageDiagnostics) {
+	for _, key := range l.order {
+		r := l.runs[key]
+		for _, cmd := range r.cmds {
+			if err := runExternalLint(cmd, r.dir, &extLintInput{Uses: r.uses}); err != nil {
+				diags.add(r.pos, r.name, "decorator", r.name, err.Error())
+				break
+			}
+		}
+	}
+}
+
+// 解析装饰器注释中所有 external 规则的命令
+func externalLintCommands(doc *ast.CommentGroup) ([]string, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	var cmds []string
+	for _, c := range doc.List {
+		s, ok := trimDecorDeclFlag(c.Text, decorLintScanFlag)
+		if !ok || !strings.HasPrefix(s, "external: ") {
+			continue
+		}
+		cmd, err := parseExternalLint(s)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// 解析 external: {cmd: "mylint"} ，返回命令
+func parseExternalLint(s string) (string, error) {
+	exprList, err := parseDecorParameterStringToExprList(strings.TrimPrefix(s, "external: "))
+	if err != nil || len(exprList) != 1 {
+		return "", errLintSyntaxError
+	}
+	kv, ok := exprList[0].(*ast.KeyValueExpr)
+	if !ok {
+		return "", errLintSyntaxError
+	}
+	if key, ok := kv.Key.(*ast.Ident); !ok || key.Name != "cmd" {
+		return "", errors.New("lint external only allows the key cmd: " + s)
+	}
+	lit := realBasicLit(kv.Value)
+	if lit == nil || lit.Kind != token.STRING {
+		return "", errors.New("lint external cmd must be a string: " + s)
+	}
+	cmd, err := strconv.Unquote(lit.Value)
+	if err != nil || strings.TrimSpace(cmd) == "" {
+		return "", errors.New("lint external cmd can't be empty: " + s)
+	}
+	return cmd, nil
+}
+
+func newExtLintRequest(fset *token.FileSet, pkgPath string, da *decorAnnotation, target *ast.FuncDecl) *extLintRequest {
+	req := &extLintRequest{
+		Decorator: extLintDecorator{Package: pkgPath, Name: da.name, Position: fset.Position(da.doc.Pos()).String()},
+		Target: extLintTarget{
+			Name:     inlineTargetName(target),
+			Params:   []extLintField{},
+			Results:  []extLintField{},
+			Position: fset.Position(target.Pos()).String(),
+		},
+		Params: da.parameters,
+	}
+	if req.Params == nil {
+		req.Params = map[string]string{}
+	}
+	if target.Recv != nil && len(target.Recv.List) > 0 {
+		req.Target.Receiver = typeString(target.Recv.List[0].Type)
+	}
+	req.Target.Params = extLintFields(target.Type.Params)
+	req.Target.Results = extLintFields(target.Type.Results)
+	return req
+}
+
+func extLintFields(fl *ast.FieldList) []extLintField {
+	fields := []extLintField{}
+	if fl == nil {
+		return fields
+	}
+	for _, field := range fl.List {
+		typ := typeString(field.Type)
+		if len(field.Names) == 0 {
+			fields = append(fields, extLintField{Type: typ})
+			continue
+		}
+		for _, id := range field.Names {
+			fields = append(fields, extLintField{Name: id.Name, Type: typ})
+		}
+	}
+	return fields
+}
+
+// 在 dir 下执行命令 cmd ，标准输入为 in 的 JSON ，非 0 状态码退出时返回带有命令输出的错误
+func runExternalLint(cmd, dir string, in *extLintInput) error {
+	input, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	args := strings.Fields(cmd)
+	ctx, cancel := context.WithTimeout(context.Background(), extLintTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, args[0], args[1:]...)
+	c.Dir = dir
+	c.Stdin = bytes.NewReader(input)
+	out, err := c.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return errors.New(fmt.Sprintf("external lint %q timed out after %s", cmd, extLintTimeout))
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return errors.New(fmt.Sprintf("external lint %q can't run: %v", cmd, err))
+	}
+	msg := strings.TrimSpace(string(out))
+	if msg == "" {
+		msg = exitErr.Error()
+	}
+	return errors.New(fmt.Sprintf("external lint %q failed: %s", cmd, msg))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseExternalLint(t *testing.T) {
+	if cmd, err := parseExternalLint(`external: {cmd: "mylint -strict"}`); err != nil || cmd != "mylint -strict" {
+		t.Fatal("parseExternalLint() want mylint -strict, but got", cmd, err)
+	}
+	for _, s := range []string{
+		`external: {}`,
+		`external: {cmd: ""}`,
+		`external: {cmd: 1}`,
+		`external: {path: "mylint"}`,
+		`external: {cmd: "a", cmd: "b"}`,
+		`external: mylint`,
+	} {
+		if _, err := parseExternalLint(s); err == nil {
+			t.Fatalf("parseExternalLint(%s) should fail", s)
+		}
+	}
+	if err := resolveLinterFromAnnotation(`external: {cmd: "mylint"}`, decorArgsMap{}); err != nil {
+		t.Fatal("resolveLinterFromAnnotation() should accept external, but got", err)
+	}
+}
+
+func TestNewExtLintRequest(t *testing.T) {
+	src := `package main
+
+//go:decor hit#{msg: "hello"}
+func (s *T) Get(ctx context.Context, ids ...int) (v string, err error) { return }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := f.Decls[0].(*ast.FuncDecl)
+	da := newDecorAnnotation(fd.Doc.List[0], "hit", map[string]string{"msg": `"hello"`})
+	b, err := json.Marshal(newExtLintRequest(fset, "", da, fd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"decorator":{"package":"","name":"hit","position":"main.go:3:1"},` +
+		`"target":{"name":"(*T).Get","receiver":"*T",` +
+		`"params":[{"name":"ctx","type":"context.Context"},{"name":"ids","type":"[]int"}],` +
+		`"results":[{"name":"v","type":"string"},{"name":"err","type":"error"}],"position":"main.go:4:1"},` +
+		`"params":{"msg":"\"hello\""}}`
+	if string(b) != want {
+		t.Fatalf("newExtLintRequest() want %s, but got %s", want, b)
+	}
+}
+
+func TestRunExternalLint(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh scripts")
+	}
+	dir := t.TempDir()
+	script := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	req := &extLintInput{Uses: []*extLintRequest{{Target: extLintTarget{Name: "datetime"}, Params: map[string]string{"msg": `"hi"`}}}}
+
+	// the request is written to stdin, the command runs in dir
+	save := script("save.sh", `cat > "$1"`)
+	if err := runExternalLint(save+" out.json", dir, req); err != nil {
+		t.Fatal("runExternalLint() should pass, but got", err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "out.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got extLintInput
+	if err := json.Unmarshal(b, &got); err != nil || len(got.Uses) != 1 ||
+		got.Uses[0].Target.Name != "datetime" || got.Uses[0].Params["msg"] != `"hi"` {
+		t.Fatalf("runExternalLint() stdin not match, got %s", b)
+	}
+
+	reject := script("reject.sh", `echo "msg must not be hi" >&2; exit 1`)
+	if err := runExternalLint(reject, dir, req); err == nil ||
+		err.Error() != `external lint "`+reject+`" failed: msg must not be hi` {
+		t.Fatal("runExternalLint() should fail with the output, but got", err)
+	}
+	if err := runExternalLint("decorator-no-such-lint", dir, req); err == nil ||
+		!strings.Contains(err.Error(), "can't run") {
+		t.Fatal("runExternalLint() with a missing command should fail, but got", err)
+	}
+}
+
+func TestExtLints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses true and false")
+	}
+	targetPkg := "github.com/dengsgo/go-decorator/cmd/decorator"
+	fset := token.NewFileSet()
+	doc := &ast.Comment{Text: "//go:decor x"}
+	target := &ast.FuncDecl{Name: ast.NewIdent("datetime"), Type: &ast.FuncType{}}
+	var l extLints
+	for _, name := range []string{"extApproved", "extRejected", "memoized", "notExist", "extApproved", "extRejected"} {
+		if err := l.add(fset, targetPkg, newDecorAnnotation(doc, name, nil), target); err != nil {
+			t.Fatalf("extLints.add(%s) should pass, but got %v", name, err)
+		}
+	}
+	// the uses are grouped by decorator, the ones without external rules are left out
+	if len(l.order) != 2 || len(l.runs[targetPkg+".extApproved"].uses) != 2 || len(l.runs[targetPkg+".extRejected"].uses) != 2 {
+		t.Fatal("extLints.add() should group the uses by decorator, but got", l.order)
+	}
+	diags := &packageDiagnostics{fset: fset}
+	l.run(diags)
+	want := `decorator extRejected external lint "false" failed: exit status 1`
+	if len(diags.issues) != 1 || diags.issues[0].msg != want {
+		t.Fatalf("extLints.run() want one error %q, but got %v", want, diags.issues)
+	}
+}
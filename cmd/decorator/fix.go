@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fix 子命令：自动修复常见的装饰器用法问题，直接改写源文件并用 go/format 格式化。
+//
+//	decorator fix [-n] [packages]
+//
+// packages 的写法和 go list 一致，默认为 ./... 。目前支持的修复：
+//   - 文件中有被装饰的函数但没有导入 decor 包时，添加 import _ "github.com/dengsgo/go-decorator/decor" ，
+//     文件中引用了 decor.Xxx 时按名称导入
+//   - 以 _ 导入了 decor 包，但又通过 decor.Xxx 引用了它时，去掉 _ 别名
+//   - 迁移不会被识别或多余的注释写法：// go:decor name 、//go:decor   name 改为 //go:decor name ，
+//     空的参数 name#{} 改为 name
+//
+// 每个修复按 file:line:col: message 的格式输出。-n 时只输出，不修改文件。
+
+// 看起来像 //go:decor 注释的写法，// 和 go:decor 之间、go:decor 和装饰器之间可以有任意空白。
+// 使用自定义前缀时由 setDecorPrefix 更新
+var annotationLikeRe = regexp.MustCompile(`^//\s*go:decor\s+(.*)$`)
+
+// 一处文本替换，[start, end) 为原文件中的字节偏移
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+func runFix(args []string) error {
+	fs := flag.NewFlagSet("fix", flag.ContinueOnError)
+	dryRun := fs.Bool("n", false, "print the fixes without changing any file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	return fixPackages(os.Stdout, patterns, *dryRun)
+}
+
+func fixPackages(w io.Writer, patterns []string, dryRun bool) error {
+	pkgs, err := listPackages(patterns)
+	if err != nil {
+		return err
+	}
+	// 和 lint 一样，decor.toml 从包所在的目录查找
+	workDir, prefix := projectDir, decorPrefix
+	defer func() {
+		projectDir = workDir
+		pkgILoader.resetCurrentPkg()
+		setDecorPrefix(prefix)
+	}()
+	for _, pi := range pkgs {
+		projectDir = pi.Dir
+		pkgILoader.resetCurrentPkg()
+		// 注解的前缀可能由包所在模块的 decor.toml 设置
+		if err := useDecorPrefix(pi.Dir); err != nil {
+			return err
+		}
+		for _, group := range packageFileGroups(pi) {
+			if err := fixPackage(w, workDir, group.files, dryRun); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 修复一组一起改写的源文件
+func fixPackage(w io.Writer, workDir string, files []string, dryRun bool) error {
+	fset := token.NewFileSet()
+	pkg, err := parserGOFiles(fset, files...)
+	if err != nil {
+		return err
+	}
+	decorated, err := decoratedFiles(pkg)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		fixed, fixes, err := fixFile(fset, pkg.Files[file], src, decorated[file])
+		if err != nil {
+			return errors.New("fix fail: " + file + ": " + err.Error())
+		}
+		name := file
+		if rel, err := filepath.Rel(workDir, file); err == nil {
+			name = rel
+		}
+		for _, s := range fixes {
+			fmt.Fprintf(w, "%s:%s\n", name, s)
+		}
+		if dryRun || len(fixes) == 0 {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, fixed, info.Mode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 包中含有被装饰的函数或函数变量的文件。和 compile 一样先展开类型、//go:decor-all 、decor.toml 上的装饰器和别名，
+// 它们的错误由 lint 报告，这里忽略。只有 decor.toml 无法解析时返回错误。
+func decoratedFiles(pkg *astPackage) (map[string]bool, error) {
+	_, _ = typeDecorRebuild(pkg)
+	_, _ = decorAllRebuild(pkg, "")
+	if err := applyDecorConfig(pkg, ""); err != nil {
+		return nil, err
+	}
+	if err := applyDecorAliases(pkg, ""); err != nil {
+		return nil, err
+	}
+	hasAnnotation := func(doc *ast.CommentGroup) bool {
+		if doc == nil {
+			return false
+		}
+		for _, c := range doc.List {
+			if strings.HasPrefix(c.Text, decoratorScanFlag) {
+				return true
+			}
+		}
+		return false
+	}
+	files := map[string]bool{}
+	for file, f := range pkg.Files {
+		visitAstDecl(f, func(fd *ast.FuncDecl) bool {
+			files[file] = hasAnnotation(fd.Doc)
+			return files[file]
+		})
+		if files[file] {
+			continue
+		}
+		visitAstFuncLitVar(f, func(fd *ast.FuncDecl) bool {
+			files[file] = hasAnnotation(fd.Doc)
+			return files[file]
+		})
+		visitAstFuncValueVar(f, func(*ast.ValueSpec, *ast.CommentGroup) bool {
+			files[file] = true
+			return true
+		})
+	}
+	return files, nil
+}
+
+// 修复文件 f ，src 为它的源码，decorated 表示文件中有被装饰的目标。
+// 返回格式化后的源码和每个修复的说明（line:col: message），没有修复时返回 src 。
+func fixFile(fset *token.FileSet, f *ast.File, src []byte, decorated bool) ([]byte, []string, error) {
+	tf := fset.File(f.Pos())
+	var edits []textEdit
+	var fixPos []token.Pos
+	fixMsg := map[token.Pos]string{}
+	addFix := func(pos token.Pos, msg string) {
+		fixPos = append(fixPos, pos)
+		fixMsg[pos] = msg
+	}
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			// 合并了 //go:decor-args 续行的注释和源码中的不一致，不修改
+			start := tf.Offset(c.Pos())
+			end := start + len(c.Text)
+			if end > len(src) || string(src[start:end]) != c.Text {
+				continue
+			}
+			text, ok := migrateAnnotation(c.Text)
+			if !ok {
+				continue
+			}
+			edits = append(edits, textEdit{start, end, text})
+			addFix(c.Pos(), fmt.Sprintf("rewrite %q to %q", c.Text, text))
+			// 迁移后的注释会被识别，文件需要导入 decor 包
+			decorated = true
+		}
+	}
+
+	var decorSpec *ast.ImportSpec
+	for _, ip := range f.Imports {
+		if p, err := strconv.Unquote(ip.Path.Value); err == nil && p == decoratorPackagePath {
+			decorSpec = ip
+			break
+		}
+	}
+	referenced := unresolvedIdent(f, "decor")
+	switch {
+	case decorSpec == nil && (decorated || referenced):
+		// 引用了 decor 时按名称导入
+		spec := strconv.Quote(decoratorPackagePath)
+		if !referenced {
+			spec = "_ " + spec
+		}
+		edits = append(edits, insertImport(tf, f, src, spec))
+		addFix(f.Name.Pos(), "add import "+spec)
+	case decorSpec != nil && decorSpec.Name != nil && decorSpec.Name.Name == "_" && referenced:
+		edits = append(edits, textEdit{tf.Offset(decorSpec.Name.Pos()), tf.Offset(decorSpec.Path.Pos()), ""})
+		addFix(decorSpec.Pos(), "import "+strconv.Quote(decoratorPackagePath)+" by name, it is referenced as decor")
+	}
+	if len(edits) == 0 {
+		return src, nil, nil
+	}
+
+	// 从后往前替换，前面的偏移不受影响
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	out := append([]byte(nil), src...)
+	for _, e := range edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+	formatted, err := format.Source(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(fixPos, func(i, j int) bool { return fixPos[i] < fixPos[j] })
+	fixes := make([]string, 0, len(fixPos))
+	for _, pos := range fixPos {
+		p := fset.Position(pos)
+		fixes = append(fixes, fmt.Sprintf("%d:%d: %s", p.Line, p.Column, fixMsg[pos]))
+	}
+	return formatted, fixes, nil
+}
+
+// 添加导入 spec ：有 import (...) 时加入第一个分组，否则在 package 子句所在行之后插入新的声明，
+// 由 go/format 调整格式和顺序
+func insertImport(tf *token.File, f *ast.File, src []byte, spec string) textEdit {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			break
+		}
+		if gd.Lparen.IsValid() {
+			offset := tf.Offset(gd.Lparen) + 1
+			return textEdit{offset, offset, "\n\t" + spec}
+		}
+	}
+	offset := tf.Offset(f.Name.End())
+	if i := bytes.IndexByte(src[offset:], '\n'); i >= 0 {
+		offset += i + 1
+	} else {
+		offset = len(src)
+	}
+	return textEdit{offset, offset, "\nimport " + spec + "\n"}
+}
+
+// 把看起来像 //go:decor 但不会被识别或多余的注释改为规范的写法，text 不需要修改时返回 false 。
+// 装饰器部分无法解析的注释（例如普通的说明文字）保持不变。
+func migrateAnnotation(text string) (string, bool) {
+	m := annotationLikeRe.FindStringSubmatch(text)
+	if m == nil {
+		return text, false
+	}
+	s := strings.TrimSpace(m[1])
+	name, parameters, err := parseDecorAndParameters(s)
+	if err != nil {
+		return text, false
+	}
+	migrated := decoratorScanFlag + s
+	if len(parameters) == 0 {
+		migrated = decoratorScanFlag + name
+	}
+	return migrated, migrated != text
+}
+
+// 文件 f 中是否有未解析的标识符 name ，例如以 _ 导入的包被引用时
+func unresolvedIdent(f *ast.File, name string) bool {
+	for _, ident := range f.Unresolved {
+		if ident.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixFile(t *testing.T) {
+	cas := []struct {
+		src, want string
+		fixes     int
+	}{
+		{
+			// 缺少 decor 的导入
+			"package p\n\n//go:decor logging\nfunc a() {}\n",
+			"package p\n\nimport _ \"github.com/dengsgo/go-decorator/decor\"\n\n//go:decor logging\nfunc a() {}\n",
+			1,
+		},
+		{
+			// 迁移注释后才是被装饰的函数
+			"package p // p\n\nimport \"fmt\"\n\n// go:decor logging#{}\nfunc a() { fmt.Println() }\n",
+			"package p // p\n\nimport _ \"github.com/dengsgo/go-decorator/decor\"\n\nimport \"fmt\"\n\n//go:decor logging\nfunc a() { fmt.Println() }\n",
+			2,
+		},
+		{
+			// 加入已有的分组
+			"package p\n\nimport (\n\t\"fmt\"\n)\n\n//go:decor logging\nfunc a() { fmt.Println() }\n",
+			"package p\n\nimport (\n\t\"fmt\"\n\t_ \"github.com/dengsgo/go-decorator/decor\"\n)\n\n//go:decor logging\nfunc a() { fmt.Println() }\n",
+			1,
+		},
+		{
+			// 以 _ 导入但引用了 decor
+			"package p\n\nimport _ \"github.com/dengsgo/go-decorator/decor\"\n\nfunc logging(ctx *decor.Context) {}\n",
+			"package p\n\nimport \"github.com/dengsgo/go-decorator/decor\"\n\nfunc logging(ctx *decor.Context) {}\n",
+			1,
+		},
+		{
+			// 引用了 decor 时按名称导入
+			"package p\n\nfunc logging(ctx *decor.Context) {}\n",
+			"package p\n\nimport \"github.com/dengsgo/go-decorator/decor\"\n\nfunc logging(ctx *decor.Context) {}\n",
+			1,
+		},
+		{
+			// 合并了续行的注释不修改
+			"package p\n\n//go:decor  logging#{\n//go:decor-args level: 1}\nfunc a() {}\n",
+			"package p\n\nimport _ \"github.com/dengsgo/go-decorator/decor\"\n\n//go:decor  logging#{\n//go:decor-args level: 1}\nfunc a() {}\n",
+			1,
+		},
+		{
+			// 普通的说明文字和规范的写法都不修改
+			"package p\n\nimport _ \"github.com/dengsgo/go-decorator/decor\"\n\n// go:decor is used below\n//go:decor d.tagging#{names: {\"a\"}}\nfunc a() {}\n",
+			"",
+			0,
+		},
+	}
+	for i, c := range cas {
+		file := filepath.Join(t.TempDir(), "p.go")
+		if err := os.WriteFile(file, []byte(c.src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		fset := token.NewFileSet()
+		pkg, err := parserGOFiles(fset, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decorated, err := decoratedFiles(pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, fixes, err := fixFile(fset, pkg.Files[file], []byte(c.src), decorated[file])
+		if err != nil {
+			t.Fatalf("cas[%d] fixFile() error %v", i, err)
+		}
+		if len(fixes) != c.fixes {
+			t.Fatalf("cas[%d] fixFile() should make %d fixes, but got %q", i, c.fixes, fixes)
+		}
+		want := c.want
+		if want == "" {
+			want = c.src
+		}
+		if string(got) != want {
+			t.Fatalf("cas[%d] fixFile() got:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
+
+func TestMigrateAnnotation(t *testing.T) {
+	cas := []struct {
+		text, r string
+		ok      bool
+	}{
+		{"//go:decor logging", "//go:decor logging", false},
+		{"// go:decor logging", "//go:decor logging", true},
+		{"//go:decor \t logging#{level: \"info\"}", "//go:decor logging#{level: \"info\"}", true},
+		{"//go:decor logging#{}", "//go:decor logging", true},
+		{"//go:decor-lint required: {a}", "//go:decor-lint required: {a}", false},
+		{"// go:decor annotations are rewritten", "// go:decor annotations are rewritten", false},
+	}
+	for i, c := range cas {
+		r, ok := migrateAnnotation(c.text)
+		if r != c.r || ok != c.ok {
+			t.Fatalf("cas[%d] migrateAnnotation(%q) got (%q, %v), want (%q, %v)", i, c.text, r, ok, c.r, c.ok)
+		}
+	}
+}
+
+func TestFixPackages(t *testing.T) {
+	var out bytes.Buffer
+	if err := fixPackages(&out, []string{"github.com/dengsgo/go-decorator/example/usages"}, true); err != nil {
+		t.Fatal("fixPackages() error", err)
+	}
+	if s := strings.TrimSpace(out.String()); s != "" {
+		t.Fatalf("fixPackages() should make no fixes, but got:\n%s", s)
+	}
+}
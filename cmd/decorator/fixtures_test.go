@@ -0,0 +1,223 @@
+package main
+
+import (
+	"os"
+	"path"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 测试中使用的装饰器、类型和常量。测试的源码通过 d "github.com/dengsgo/go-decorator/cmd/decorator" 引用它们，
+// 包的加载也会解析 _test.go 文件，它们不会编译进 decorator 。
+
+var loggingAlias = logging
+
+var loggingAliasAlias = loggingAlias
+
+var notDecoratorVar = 1
+
+var pureCounter int
+
+//go:decor-pure
+func pureDecor(ctx *decor.Context) {
+	pureCounter := len(ctx.TargetIn)
+	if pureCounter > 0 {
+		ctx.TargetDo()
+	}
+}
+
+//go:decor-pure
+func impureGlobalDecor(ctx *decor.Context) {
+	pureCounter++
+	ctx.TargetDo()
+}
+
+//go:decor-pure
+func impureIODecor(ctx *decor.Context) {
+	ctx.TargetDo()
+	os.Stdout.WriteString(ctx.TargetName)
+}
+
+//go:decor-pure
+func inlinedDecor(ctx *decor.Context, label string) {
+	name := label + ctx.TargetName
+	ctx.TargetDo()
+	_ = path.Base(name)
+}
+
+//go:decor-pure
+func forwardingPureDecor(ctx *decor.Context) {
+	ctx.TargetDo()
+	pureDecor(ctx)
+}
+
+//go:decor-pure
+func modifyingPureDecor(ctx *decor.Context) {
+	ctx.TargetIn[0] = 1
+	ctx.TargetDo()
+}
+
+//go:decor-pure
+func deferringPureDecor(ctx *decor.Context) {
+	defer os.Stdout.WriteString(ctx.TargetName)
+	ctx.TargetDo()
+}
+
+//go:decor-pure
+func deferInLiteralPureDecor(ctx *decor.Context, label string) {
+	func() {
+		defer path.Base(label)
+	}()
+	ctx.TargetDo()
+}
+
+//go:decor-lint required: {names: {"a", "b", "c"}, ports: {gte: 1, lte: 65535}}
+//go:decor-lint nonzero: {names}
+func tagging(ctx *decor.Context, names []string, ports []int) {
+	ctx.TargetDo()
+}
+
+type boundDecorators struct {
+	prefix string
+}
+
+var boundRegistry = &boundDecorators{prefix: "bound"}
+
+var boundRegistryTyped boundDecorators
+
+var boundRegistryUntyped = newBoundDecorators()
+
+func newBoundDecorators() *boundDecorators {
+	return &boundDecorators{}
+}
+
+//go:decor-lint nonzero: {level}
+func (b *boundDecorators) logging(ctx *decor.Context, level string) {
+	ctx.TargetDo()
+}
+
+func typedDecor[A, R any](ctx *decor.Context1In1Out[A, R]) {
+	ctx.TargetDo()
+}
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+)
+
+const levelUntyped = 1
+
+const (
+	greetingConst       = "hello"
+	ratioConst          = 0.5
+	attemptsInt64 int64 = 5
+	attemptsInt   int   = 3
+)
+
+func leveled(ctx *decor.Context, level logLevel, s string) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint comparable: true
+func memoized(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+type routeKind string
+
+const (
+	routeAPI   routeKind = "api"
+	routeAdmin routeKind = "admin"
+	routeWeb   routeKind = "web"
+)
+
+//go:decor-lint pattern: {route: "^/[a-z/]*$"}
+//go:decor-lint prefix: {metric: {"app_", "svc_"}, tags: "env:", kind: "a"}
+//go:decor-lint suffix: {metric: {"_total", "_seconds"}}
+func routed(ctx *decor.Context, route, metric string, tags []string, kind routeKind) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint exclusive: {file, url, data}
+//go:decor-lint requires: {retries: {timeout}}
+func sourced(ctx *decor.Context, file, url, data string, timeout, retries int) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint external: {cmd: "true"}
+func extApproved(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint external: {cmd: "true"}
+//go:decor-lint external: {cmd: "false"}
+func extRejected(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+//go:decor-default {level: "info", repeat: 2}
+//go:decor-lint required: {level: {"debug", "info"}}
+//go:decor-lint nonzero: {level}
+//go:decor-default {verbose: true, ratio: 1}
+func defaulted(ctx *decor.Context, level string, repeat int, verbose bool, ratio float64) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint required: {level: {"debug", "info"}}
+//go:decor-default {level: "trace"}
+func badDefaulted(ctx *decor.Context, level string) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint required: {times: {gte: 1}}
+func optioned(ctx *decor.Context, times int, opts ...decor.Option) {
+	ctx.TargetDo()
+}
+
+func optionedOnly(ctx *decor.Context, opts ...decor.Option) {
+	ctx.TargetDo()
+}
+
+func optionedRest(ctx *decor.Context, rest map[string]string, opts ...decor.Option) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint signature: {in: ["context.Context", "..."], out: ["error"]}
+func ctxErrOnly(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint signature: {in: []}
+func noArgsOnly(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+//go:decor-lint nonzero: {level}
+//go:decor-deprecated use leveled instead
+func oldLogging(ctx *decor.Context, level string) {
+	ctx.TargetDo()
+}
+
+//go:decor-deprecated
+func oldTiming(ctx *decor.Context) {
+	ctx.TargetDo()
+}
+
+func timedDecor(ctx *decor.Context) {
+	ctx.TargetDo()
+	_ = ctx.Elapsed()
+}
+
+func hooked(ctx *decor.Context, name string, onDone func(), ch chan int, m map[string]func() error, p *int, v any, err error) {
+	ctx.TargetDo()
+	if onDone != nil {
+		onDone()
+	}
+}
+
+//go:decor-lint nonzero: {name}
+func forwarding(ctx *decor.Context, name string, rest map[string]string) {
+	ctx.TargetDo()
+}
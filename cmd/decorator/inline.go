@@ -0,0 +1,487 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+
+	"github.com/dengsgo/go-decorator/cmd/logs"
+)
+
+// 标记了 //go:decor-pure 的装饰器满足以下条件时，它的函数体直接展开到目标中（内联），
+// 不再生成保存在 Context.Func 中的闭包，也不再调用装饰器：
+//   - 函数体的顶层恰好有一条 ctx.TargetDo() 语句，其他地方没有调用 TargetDo ，没有 return 、goto 和标签；
+//   - ctx 只用于读取目标的信息（见 inlineCtxFields），不修改、不传给其他函数、不调用其他方法；
+//   - 只引用导入的包、内置的标识符和装饰器所在包的导出声明（和目标在同一个包中时不限于导出的）；
+//   - 不是绑定装饰器、不是泛型函数，除 ctx 之外的参数的类型是内置类型。
+//
+// ctx.TargetDo() 之前的语句在目标原来的函数体之前执行，之后的语句在其后执行：
+//
+//	func add(a, b int) (r int) {
+//		ctx := &decor.Context{...}   // 装饰器没有读取 ctx 时省略
+//		start := time.Now()          // ctx.TargetDo() 之前的语句
+//		r = func(a, b int) int { return a + b }(a, b)
+//		ctx.TargetOut[0] = r         // 装饰器读取 TargetOut 时同步返回值
+//		stats.Observe(ctx.TargetName, time.Since(start))
+//		return r
+//	}
+//
+// 目标的参数直接传给原来的函数体，不再经过 TargetIn 的类型断言，直接调用的函数字面量可以被 Go 编译器内联，
+// ctx 不逃逸时也不再分配在堆上。检查是语法上的：装饰器通过其他函数修改 TargetIn 的元素时，目标看不到修改。
+// 目标的所有装饰器都可以内联时才内联，-d.noInline 关闭内联。
+
+// 内联的装饰器可以读取的 ctx 字段
+var inlineCtxFields = map[string]bool{
+	"Kind": true, "Receiver": true, "Ctx": true,
+	"TargetName": true, "TargetPkg": true, "TargetFile": true, "TargetLine": true,
+	"TargetIn": true, "TargetOut": true, "TargetInNames": true, "TargetOutNames": true,
+	"TargetInTypes": true, "TargetOutTypes": true, "TypeParams": true, "TypeArgs": true,
+}
+
+// 可以内联的装饰器
+type inlineDecor struct {
+	src      string            // 装饰器的函数声明，函数名为 _
+	imports  map[string]string // 函数体引用的导入名 => 导入路径
+	pkgRefs  map[string]bool   // 函数体引用的装饰器所在包的包级声明
+	universe map[string]bool   // 函数体引用的内置标识符
+	usesCtx  bool              // 是否读取了 ctx 的字段
+	readsOut bool              // 是否读取了 ctx.TargetOut
+}
+
+// 检查装饰器能否内联。没有标记 //go:decor-pure 时返回 nil 和空的原因，不能内联时返回 nil 和原因。
+func checkDecorInline(pkgPath, funName string) (*inlineDecor, string) {
+	fset, decl, file, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil || !hasDecorPureFlag(decl.Doc) {
+		return nil, ""
+	}
+	if decl.Recv != nil || decl.Body == nil {
+		return nil, "it is a bound decorator"
+	}
+	if decl.Type.TypeParams != nil || (decl.Type.Results != nil && len(decl.Type.Results.List) > 0) {
+		return nil, "it is generic or has results"
+	}
+	imp := newImporter(file)
+	for _, spec := range file.Imports {
+		if spec.Name != nil && spec.Name.Name == "." {
+			return nil, "its file has dot imports"
+		}
+	}
+	params := decl.Type.Params.List
+	decorName, ok := imp.importedPath(decoratorPackagePath)
+	if len(params) == 0 || len(params[0].Names) != 1 || params[0].Names[0].Name == "_" || !ok ||
+		typeString(params[0].Type) != "*"+decorName+".Context" {
+		return nil, "it doesn't take a named *decor.Context"
+	}
+	for _, field := range params[1:] {
+		id, ok := field.Type.(*ast.Ident)
+		if !ok {
+			return nil, "its parameter type " + typeString(field.Type) + " isn't predeclared"
+		}
+		if _, ok := types.Universe.Lookup(id.Name).(*types.TypeName); !ok {
+			return nil, "its parameter type " + id.Name + " isn't predeclared"
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, &ast.FuncDecl{Name: ast.NewIdent("_"), Type: decl.Type, Body: decl.Body}); err != nil {
+		return nil, err.Error()
+	}
+	di := &inlineDecor{src: buf.String(), imports: map[string]string{}, pkgRefs: map[string]bool{}, universe: map[string]bool{}}
+	_, f, fd, err := di.parse()
+	if err != nil {
+		return nil, err.Error()
+	}
+	if reason := di.checkBody(fd); reason != "" {
+		return nil, reason
+	}
+
+	set, err := pkgILoader.loadPkg(pkgPath)
+	if err != nil {
+		return nil, err.Error()
+	}
+	declared := map[string]bool{}
+	for name, pkg := range set.pkgs {
+		if !strings.HasSuffix(name, "_test") {
+			for name := range packageDeclNames(pkg) {
+				declared[name] = true
+			}
+		}
+	}
+	for _, id := range f.Unresolved {
+		switch {
+		case imp.nameMap[id.Name] != "" && !declared[id.Name]:
+			di.imports[id.Name] = imp.nameMap[id.Name]
+		case declared[id.Name]:
+			if pkgPath != "" && !ast.IsExported(id.Name) {
+				return nil, "it references unexported " + id.Name
+			}
+			di.pkgRefs[id.Name] = true
+		case types.Universe.Lookup(id.Name) != nil:
+			di.universe[id.Name] = true
+		default:
+			return nil, "it references undeclared " + id.Name
+		}
+	}
+	return di, ""
+}
+
+// 解析装饰器的函数声明
+func (di *inlineDecor) parse() (*token.FileSet, *ast.File, *ast.FuncDecl, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "package p\n\n"+di.src, 0)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return fset, f, f.Decls[0].(*ast.FuncDecl), nil
+}
+
+// 检查装饰器的函数体，记录 ctx 的用法，不能内联时返回原因
+func (di *inlineDecor) checkBody(fd *ast.FuncDecl) string {
+	ctx := fd.Type.Params.List[0].Names[0].Obj
+	isCtx := func(expr ast.Expr) bool {
+		id, ok := expr.(*ast.Ident)
+		return ok && id.Obj == ctx
+	}
+	if inlineSplit(fd, ctx) < 0 {
+		return "it doesn't call ctx.TargetDo() exactly once at the top level"
+	}
+	// 作为选择器 ctx.X 读取的 ctx 和出现的所有 ctx
+	selected, total := 0, 0
+	reason := ""
+	modifies := func(exprs ...ast.Expr) {
+		for _, expr := range exprs {
+			ast.Inspect(expr, func(n ast.Node) bool {
+				if e, ok := n.(ast.Expr); ok && isCtx(e) {
+					reason = "it modifies ctx"
+				}
+				return true
+			})
+		}
+	}
+	var visit func(n ast.Node, inFunc bool) bool
+	visit = func(n ast.Node, inFunc bool) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			ast.Inspect(n.Body, func(n ast.Node) bool { return visit(n, true) })
+			return false
+		case *ast.ReturnStmt:
+			if !inFunc {
+				reason = "it returns before the end"
+			}
+		case *ast.DeferStmt:
+			// 内联之后 defer 在目标函数返回时才执行，晚于外层装饰器在 TargetDo 之后的语句
+			if !inFunc {
+				reason = "it defers at the top level"
+			}
+		case *ast.LabeledStmt:
+			reason = "it has labels"
+		case *ast.BranchStmt:
+			if n.Label != nil || n.Tok == token.GOTO {
+				reason = "it has labels"
+			}
+		case *ast.AssignStmt:
+			modifies(n.Lhs...)
+		case *ast.IncDecStmt:
+			modifies(n.X)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				modifies(n.Key, n.Value)
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				modifies(n.X)
+			}
+		case *ast.KeyValueExpr:
+			// 复合字面量的键可能是字段名，和局部变量同名时无法确定是否要改名
+			if id, ok := n.Key.(*ast.Ident); ok && id.Obj != nil {
+				reason = "it has a composite literal key named like a local " + id.Name
+			}
+		case *ast.SelectorExpr:
+			if isCtx(n.X) {
+				selected++
+				if n.Sel.Name == "TargetOut" {
+					di.readsOut = true
+				}
+				if n.Sel.Name != "TargetDo" {
+					di.usesCtx = true
+					if !inlineCtxFields[n.Sel.Name] {
+						reason = "it uses ctx." + n.Sel.Name
+					}
+				}
+			}
+		case *ast.Ident:
+			if n.Obj == ctx {
+				total++
+			}
+		}
+		return reason == ""
+	}
+	ast.Inspect(fd.Body, func(n ast.Node) bool { return visit(n, false) })
+	if reason == "" && selected != total {
+		reason = "it passes ctx on"
+	}
+	return reason
+}
+
+// 函数体顶层的 ctx.TargetDo() 语句的下标，函数体中调用 TargetDo 的次数不是一次或者不在顶层时返回 -1
+func inlineSplit(fd *ast.FuncDecl, ctx *ast.Object) int {
+	calls := 0
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "TargetDo" {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == ctx {
+				calls++
+			}
+		}
+		return true
+	})
+	if calls != 1 {
+		return -1
+	}
+	for i, stmt := range fd.Body.List {
+		es, ok := stmt.(*ast.ExprStmt)
+		if !ok {
+			continue
+		}
+		if call, ok := es.X.(*ast.CallExpr); ok && len(call.Args) == 0 {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "TargetDo" {
+				if id, ok := sel.X.(*ast.Ident); ok && id.Obj == ctx {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// 展开到目标中的 ctx.TargetDo() 之前和之后的语句。装饰器的 ctx 改名为 ctxVar ，其他参数和局部变量改名为新的标识符，
+// 参数声明为 callParams 中对应的值。names 为函数体引用的导入名和包级声明在目标中的写法。
+// 语句的位置都设置为 pos 。
+func (di *inlineDecor) stmts(gi *genIdentId, ctxVar string, callParams []string, names map[string]string, pos token.Pos) (pre, post []ast.Stmt, err error) {
+	fset, f, fd, err := di.parse()
+	if err != nil {
+		return nil, nil, err
+	}
+	renamed := map[*ast.Object]string{}
+	params := fd.Type.Params.List
+	renamed[params[0].Names[0].Obj] = ctxVar
+	var decls []string
+	i := 0
+	for _, field := range params[1:] {
+		for _, id := range field.Names {
+			if i >= len(callParams) {
+				return nil, nil, errors.New("missing decorator parameter " + id.Name)
+			}
+			if id.Name != "_" {
+				renamed[id.Obj] = gi.nextStr()
+				decls = append(decls, fmt.Sprintf("var %s %s = %s", renamed[id.Obj], typeString(field.Type), callParams[i]))
+			}
+			i++
+		}
+	}
+	ast.Inspect(fd.Body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Obj != nil && id.Obj.Kind != ast.Lbl {
+			if _, ok := renamed[id.Obj]; !ok {
+				renamed[id.Obj] = gi.nextStr()
+			}
+			id.Name = renamed[id.Obj]
+		}
+		return true
+	})
+	for _, id := range f.Unresolved {
+		if name, ok := names[id.Name]; ok {
+			id.Name = name
+		}
+	}
+
+	split := inlineSplit(fd, params[0].Names[0].Obj)
+	printStmts := func(list []ast.Stmt) ([]ast.Stmt, error) {
+		var buf bytes.Buffer
+		for _, stmt := range list {
+			if err := printer.Fprint(&buf, fset, stmt); err != nil {
+				return nil, err
+			}
+			buf.WriteString("\n")
+		}
+		stmts, _, err := getStmtList(buf.String())
+		for _, stmt := range stmts {
+			setNodePos(stmt, pos)
+		}
+		return stmts, err
+	}
+	if pre, err = printStmts(fd.Body.List[:split]); err != nil {
+		return nil, nil, err
+	}
+	if post, err = printStmts(fd.Body.List[split+1:]); err != nil {
+		return nil, nil, err
+	}
+	if len(decls) > 0 {
+		stmts, _, err := getStmtList(strings.Join(decls, "\n"))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, stmt := range stmts {
+			setNodePos(stmt, pos)
+		}
+		pre = append(stmts, pre...)
+	}
+	return pre, post, nil
+}
+
+// 内联的装饰器在目标中的引用：导入名、装饰器所在包的包级声明和内置标识符的写法，以及需要导入的包。
+// 引用的包在目标所在的包中都没有导入过时（compile 的 importcfg 中没有这个包），或者写法和 scope 中的名字冲突时，
+// 返回不能内联的原因。
+//
+// x 为目标文件中装饰器所在包的导入名，装饰器和目标在同一个包中时为空。
+type inlineImport struct {
+	name, path string
+	spec       *ast.ImportSpec // 匿名导入的包，需要命名；为 nil 时新增导入
+}
+
+func inlineNames(di *inlineDecor, x string, imp *importer, pkgImports packageImports, declared, scope map[string]bool) (map[string]string, []inlineImport, string) {
+	imported := map[string]bool{}
+	for _, paths := range pkgImports {
+		for _, p := range paths {
+			imported[p] = true
+		}
+	}
+	names := map[string]string{}
+	var adds []inlineImport
+	for name, p := range di.imports {
+		if tname, ok := imp.pathMap[p]; ok && tname != "_" && tname != "." {
+			names[name] = tname
+		} else if !imported[p] {
+			return nil, nil, "package " + p + " isn't imported by the target package"
+		} else if imp.nameMap[name] != "" || declared[name] {
+			return nil, nil, "import name " + name + " conflicts in the target file"
+		} else {
+			names[name] = name
+			adds = append(adds, inlineImport{name, p, imp.pathObjMap[p]})
+		}
+		if scope[names[name]] {
+			return nil, nil, names[name] + " is shadowed in the target"
+		}
+	}
+	for name := range di.pkgRefs {
+		names[name] = name
+		if x != "" {
+			names[name] = x + "." + name
+		} else if scope[name] {
+			return nil, nil, name + " is shadowed in the target"
+		}
+	}
+	if x != "" && scope[x] {
+		return nil, nil, x + " is shadowed in the target"
+	}
+	for name := range di.universe {
+		if declared[name] || scope[name] {
+			return nil, nil, name + " is shadowed in the target"
+		}
+	}
+	return names, adds, ""
+}
+
+// 把内联需要的导入加入文件 f
+func applyInlineImports(f *ast.File, imp *importer, adds []inlineImport) {
+	for _, add := range adds {
+		if _, ok := imp.nameMap[add.name]; ok {
+			continue
+		}
+		spec := add.spec
+		if spec != nil {
+			spec.Name = ast.NewIdent(add.name)
+		} else {
+			spec = addImport(f, add.name, add.path)
+		}
+		imp.nameMap[add.name] = add.path
+		imp.pathMap[add.path] = add.name
+		imp.pathObjMap[add.path] = spec
+	}
+}
+
+// 目标的签名中声明的名字：接收者、参数、返回值和类型参数，它们会遮蔽内联的代码引用的包级名字
+func signatureNames(fd *ast.FuncDecl) map[string]bool {
+	scope := map[string]bool{}
+	for _, fl := range []*ast.FieldList{fd.Recv, fd.Type.TypeParams, fd.Type.Params, fd.Type.Results} {
+		if fl == nil {
+			continue
+		}
+		for _, field := range fl.List {
+			for _, id := range field.Names {
+				scope[id.Name] = true
+			}
+		}
+	}
+	for _, name := range typeParamNames(fd) {
+		scope[name] = true
+	}
+	return scope
+}
+
+// 用内联的语句替换 generate 生成的代码：genStmts[0] 为 ctx 的声明，genStmts[1] 中的闭包调用原来的函数体，
+// 最后一条是 return 语句。调用原来的函数体时直接传入目标的参数，返回值直接赋值给目标的返回值。
+func inlineTarget(genStmts []ast.Stmt, ra *ReplaceArgs, di *inlineDecor, pre, post []ast.Stmt) []ast.Stmt {
+	inner := targetCallStmt(genStmts)
+	var call *ast.CallExpr
+	if as, ok := inner.(*ast.AssignStmt); ok {
+		call = as.Rhs[0].(*ast.CallExpr)
+		for i, name := range ra.OutArgNames {
+			as.Lhs[i] = &ast.Ident{NamePos: as.Lhs[i].Pos(), Name: name}
+		}
+	} else {
+		call = inner.(*ast.ExprStmt).X.(*ast.CallExpr)
+	}
+	for i, name := range ra.InArgNames {
+		call.Args[i] = &ast.Ident{NamePos: call.Args[i].Pos(), Name: name}
+	}
+
+	var stmts []ast.Stmt
+	if di.usesCtx {
+		stmts = append(stmts, genStmts[0])
+	}
+	stmts = append(append(stmts, pre...), inner)
+	if di.readsOut && ra.HaveReturn {
+		sync, _, err := getStmtList(fmt.Sprintf("%s = %s", strings.Join(ra.DecorListOut, ", "), strings.Join(ra.OutArgNames, ", ")))
+		if err != nil {
+			logs.Error("getStmtList err", err)
+		}
+		setNodePos(sync[0], inner.Pos())
+		stmts = append(stmts, sync...)
+	}
+	stmts = append(stmts, post...)
+	if ret, ok := genStmts[len(genStmts)-1].(*ast.ReturnStmt); ok && ra.HaveReturn {
+		for i, name := range ra.OutArgNames {
+			ret.Results[i] = &ast.Ident{NamePos: ret.Results[i].Pos(), Name: name}
+		}
+		stmts = append(stmts, ret)
+	}
+	return stmts
+}
+
+// 把节点 root 及其子节点已有的位置都设置为 pos
+func setNodePos(root ast.Node, pos token.Pos) {
+	posType := reflect.TypeOf(token.NoPos)
+	ast.Inspect(root, func(n ast.Node) bool {
+		if n == nil {
+			return true
+		}
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		v = v.Elem()
+		for i := 0; i < v.NumField(); i++ {
+			// 没有位置的字段（如 CallExpr.Ellipsis）表示语法上不存在，保持不变
+			if field := v.Field(i); field.Type() == posType && field.CanSet() && field.Int() != int64(token.NoPos) {
+				field.SetInt(int64(pos))
+			}
+		}
+		return true
+	})
+}
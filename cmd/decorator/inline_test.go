@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestCheckDecorInline(t *testing.T) {
+	cas := []struct {
+		name, reason string
+	}{
+		{"logging", ""}, // 没有标记 //go:decor-pure
+		{"inlinedDecor", ""},
+		{"pureDecor", "it doesn't call ctx.TargetDo() exactly once at the top level"},
+		{"forwardingPureDecor", "it passes ctx on"},
+		{"modifyingPureDecor", "it modifies ctx"},
+		{"deferringPureDecor", "it defers at the top level"},
+		{"deferInLiteralPureDecor", ""}, // defer 只在函数字面量中
+	}
+	for _, c := range cas {
+		di, reason := checkDecorInline("", c.name)
+		if reason != c.reason {
+			t.Fatalf("checkDecorInline(%s) got reason %q, want %q", c.name, reason, c.reason)
+		}
+		if (di != nil) != (c.reason == "" && c.name != "logging") {
+			t.Fatalf("checkDecorInline(%s) got %v", c.name, di)
+		}
+	}
+	di, _ := checkDecorInline("", "inlinedDecor")
+	if !di.usesCtx || di.readsOut || di.imports["path"] != "path" {
+		t.Fatalf("checkDecorInline(inlinedDecor) got %+v", di)
+	}
+}
+
+func TestDecoratePackageInline(t *testing.T) {
+	const target = `package main
+
+import (
+	"path"
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor d.inlinedDecor#{label: "x"}
+func add(a, b int) int {
+	return a + b
+}
+`
+	decorate := func(src string) string {
+		t.Helper()
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "/src/a.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, err := decoratePackage(fset, &astPackage{Name: "main", Files: map[string]*ast.File{"/src/a.go": f}}, "main", "")
+		if err != nil || len(updated) != 1 {
+			t.Fatal("decoratePackage() error", updated, err)
+		}
+		var buf bytes.Buffer
+		if err := printRewrittenFile(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	// 装饰器展开到目标中，参数直接传给原来的函数体
+	out := decorate(target)
+	for _, s := range []string{"path.Base(", ` string = "x"`, "}(a, b)"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("decoratePackage() should inline the decorator, %q not found:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, ".Func = func()") || strings.Contains(out, "d.inlinedDecor(") {
+		t.Fatalf("decoratePackage() should not call the inlined decorator:\n%s", out)
+	}
+	// 目标所在的包没有导入 path ，不能内联
+	out = decorate(strings.Replace(target, "\t\"path\"\n", "", 1))
+	if !strings.Contains(out, "d.inlinedDecor(") {
+		t.Fatalf("decoratePackage() should not inline without the import:\n%s", out)
+	}
+}
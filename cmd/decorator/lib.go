@@ -2,15 +2,22 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/dengsgo/go-decorator/cmd/logs"
 	"go/ast"
 	"go/parser"
 	"go/printer"
 	"go/token"
+	"io/fs"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 )
 
@@ -18,22 +25,46 @@ const randSeeds = "abcdefghijklmnopqrstuvwxyz"
 
 var emptyFset = token.NewFileSet()
 
-const replaceTpl = `    ${.DecorVarName} := &decor.Context{
+const replaceTpl = `    ${.DecorVarName} := &decor.${.ContextType}{
         Kind:       decor.${.TKind},
         TargetName: ${.TargetName},
-        Receiver:   ${.ReceiverVarName},
+        Receiver:   ${.ReceiverVarName},${if .Typed}${range .TypedFields}
+        ${.},${end}${else}
         TargetIn:   []any{${stringer .InArgNames}},
-        TargetOut:  []any{${stringer .OutArgNames}},
+        TargetOut:  []any{${stringer .OutArgNames}},${if .MetaVarName}
+        TargetInNames:  ${.MetaVarName}[0],
+        TargetOutNames: ${.MetaVarName}[1],
+        TargetInTypes:  ${.MetaVarName}[2],
+        TargetOutTypes: ${.MetaVarName}[3],${else}
+        TargetInNames:  []string{${quoter .InParamNames}},
+        TargetOutNames: []string{${quoter .OutParamNames}},
+        TargetInTypes:  []string{${quoter .InArgTypes}},
+        TargetOutTypes: []string{${quoter .OutArgTypes}},${end}
+        TargetPkg:  ${.TargetPkg},
+        TargetFile: ${.TargetFile},
+        TargetLine: ${.TargetLine},${if .Timed}
+        Start:      decor.Now(),${end}${if .TypeParams}
+        TypeParams: ${if .MetaVarName}${.MetaVarName}[4]${else}[]string{${quoter .TypeParams}}${end},
+        TypeArgs:   []string{${stringer .TypeArgs}},${end}${end}${if .CtxArgName}
+        Ctx:        ${.CtxArgName},${end}${if .ChainVarName}
+        Chain:      ${.ChainVarName}${if .ChainTimed}.Enter(${.ChainLayer})${end},${if not .Typed}
+        Values:     ${.ChainVarName}.Values,${end}${else if .ChainTimed}
+        Chain:      decor.NewChainState(${.ChainLayers}),${end}
     }
-    ${.DecorVarName}.Func = func() {
+    ${.DecorVarName}.Func = func() {${if .Once}
+        if ${.DecorVarName}.DoRef() > 1 {
+            panic("decor: TargetDo of " + ${.TargetName} + " is called more than once, but it is marked once")
+        }${end}
         ${if .HaveReturn}${stringer .DecorListOut} = ${end}${.FuncMain} (${stringer .DecorCallIn})
     }
     ${.DecorCallName}(${.DecorVarName}${if .HaveDecorParam}, ${stringer .DecorCallParams}${end})
+    ${if .ChainTimed}${if .ChainVarName}${.DecorVarName}.Chain.Exit(${.ChainLayer})${end}${end}
     ${if .HaveReturn}return ${stringer .DecorCallOut}${end}`
 
 type ReplaceArgs struct {
 	HaveDecorParam, // 是否有装饰参数，如果有需要引用 DecorCallParams
-	HaveReturn bool // 是否有返回值，如果有需要引用 DecorListOut/DecorCallOut
+	HaveReturn, // 是否有返回值，如果有需要引用 DecorListOut/DecorCallOut
+	Once bool // 目标是否标记了 once ，如果是，装饰器第二次调用 TargetDo 时在执行目标函数之前 panic
 	TKind, // target kind // 目标类型，可能是函数、方法等
 	TargetName, // 目标函数或方法的名称
 	ReceiverVarName, // Receiver var  // 目标函数的接收者（适用于方法）
@@ -48,10 +79,28 @@ type ReplaceArgs struct {
 	DecorListOut, // decor.TargetOut[0], decor.TargetOut[1] // 装饰器的输出参数
 	DecorCallIn, // decor.TargetIn[0].(int), decor.TargetIn[1].(int), decor.TargetIn[2].(int) // 装饰器的输入参数
 	DecorCallOut []string // decor.TargetOut[0].(int), decor.TargetOut[1].(int) // 装饰器的输出参数
+	ChainVarName  string // 链式装饰时所有层共享的 *decor.ChainState 变量名，只有一个装饰器时为空
+	ChainLayer    int    // 当前装饰器在链中的层级，最外层为 0
+	CtxArgName    string // 目标函数的第一个参数是 context.Context 时为它的参数名，用于填充 decor.Context.Ctx
+	InParamNames, // 源码中的参数名，未命名或为 "_" 时为空，用于填充 decor.Context.TargetInNames
+	OutParamNames []string // 源码中的返回值名，用于填充 decor.Context.TargetOutNames
+	ContextType string   // 上下文的类型，默认为 Context ，类型化的上下文如 Context1In1Out[int, string]
+	Typed       bool     // 是否使用类型化的上下文，参数和返回值通过 TypedFields 填充
+	TypedFields []string // In0: a, Out0: c
+	ChainLayers int      // 由 decor.Invoke 执行的装饰链的层数，为 0 时不使用 decor.Invoke
+	TypeParams, // 泛型目标的类型参数名，包括接收者类型的类型参数，如 T, K, V
+	TypeArgs []string // 获取类型实参名称的表达式，如 decor.TypeName[T]()
+	TargetPkg, // 目标所在包的导入路径，带引号，见 useLocation
+	TargetFile string // 目标所在的原始文件，带引号
+	TargetLine  int    // 目标在原始文件中声明的行号
+	Timed       bool   // 是否有装饰器调用了 ctx.Elapsed ，是时记录调用开始的时间 decor.Context.Start
+	ChainTimed  bool   // 链式装饰时是否有装饰器读取了 ctx.ChainTimings ，是时记录每一层的耗时 decor.ChainState
+	MetaVarName string // 保存参数名、类型等不变的信息的包级变量名，见 targetMetaDecl ，为空时每次调用都分配
 }
 
 func newReplaceArgs(gi *genIdentId, targetName, decorName string) *ReplaceArgs {
 	return &ReplaceArgs{
+		false,
 		false,
 		false,
 		"KFunc",                // decor.TKind,
@@ -68,15 +117,103 @@ func newReplaceArgs(gi *genIdentId, targetName, decorName string) *ReplaceArgs {
 		[]string{},
 		[]string{},
 		[]string{},
+		"",
+		0,
+		"",
+		[]string{},
+		[]string{},
+		"Context",
+		false,
+		[]string{},
+		0,
+		[]string{},
+		[]string{},
+		`""`,
+		`""`,
+		0,
+		false,
+		false,
+		"",
+	}
+}
+
+// 目标的位置：包的导入路径 pkg （main 包为 "main"）、原始文件 file 和声明的行号 line ，用于填充
+// decor.Context 的 TargetPkg 、TargetFile 和 TargetLine 。file 应当已经按 compile 的 -trimpath 改写，
+// 和 runtime.Caller 返回的路径一致，见 trimCompilePath 。
+func (ra *ReplaceArgs) useLocation(pkg, file string, line int) {
+	ra.TargetPkg, ra.TargetFile, ra.TargetLine = strconv.Quote(pkg), strconv.Quote(file), line
+}
+
+// 目标函数的第一个参数是 context.Context 时，使用它填充 decor.Context.Ctx 。
+// ctxPkgName 为 context 包在当前文件中的导入名，未导入时为空。
+func (ra *ReplaceArgs) useCtxArg(f *ast.FuncDecl, ctxPkgName string) {
+	if ctxPkgName == "" || len(ra.InArgNames) == 0 {
+		return
+	}
+	first := f.Type.Params.List[0]
+	if len(first.Names) == 0 {
+		return
+	}
+	if sel, ok := first.Type.(*ast.SelectorExpr); ok && sel.Sel.Name == "Context" {
+		if x, ok := sel.X.(*ast.Ident); ok && x.Name == ctxPkgName {
+			ra.CtxArgName = ra.InArgNames[0]
+		}
 	}
 }
 
+// 读取 TargetOut 时使用 decor.Assign 代替类型断言，接受能够赋值给返回值类型的值。
+func (ra *ReplaceArgs) useAssignableOut() {
+	for i, typ := range ra.OutArgTypes {
+		ra.DecorCallOut[i] = fmt.Sprintf("decor.Assign[%s](%s.TargetOut[%d])", typ, ra.DecorVarName, i)
+	}
+}
+
+// 装饰器接收类型化的上下文 decor.ContextNInMOut 时，参数和返回值直接保存在 InN 、OutN 字段中，避免装箱为 any 。
+// in 、out 为装饰器要求的参数和返回值个数，和目标不一致时返回错误。
+func (ra *ReplaceArgs) useTypedContext(in, out int) error {
+	if err := typedContextArity(in, out, len(ra.InArgNames), len(ra.OutArgNames)); err != nil {
+		return err
+	}
+	ra.Typed = true
+	ra.ContextType = fmt.Sprintf("Context%dIn%dOut", in, out)
+	if types := append(append([]string{}, ra.InArgTypes...), ra.OutArgTypes...); len(types) > 0 {
+		ra.ContextType += "[" + strings.Join(types, ", ") + "]"
+	}
+	ra.CtxArgName = "" // 类型化的上下文没有 Ctx 字段
+	for i, name := range ra.InArgNames {
+		ra.TypedFields = append(ra.TypedFields, fmt.Sprintf("In%d: %s", i, name))
+		ra.DecorCallIn[i] = fmt.Sprintf("%s.In%d%s", ra.DecorVarName, i, variadicSuffix(ra.DecorCallIn[i]))
+	}
+	for i, name := range ra.OutArgNames {
+		ra.TypedFields = append(ra.TypedFields, fmt.Sprintf("Out%d: %s", i, name))
+		ra.DecorListOut[i] = fmt.Sprintf("%s.Out%d", ra.DecorVarName, i)
+		ra.DecorCallOut[i] = ra.DecorListOut[i]
+	}
+	return nil
+}
+
+// 检查目标的参数和返回值个数 gotIn 、gotOut 是否符合类型化的上下文 decor.ContextNInMOut
+func typedContextArity(in, out, gotIn, gotOut int) error {
+	if in != gotIn || out != gotOut {
+		return fmt.Errorf("decorator takes decor.Context%dIn%dOut, but the target has %d parameters and %d results",
+			in, out, gotIn, gotOut)
+	}
+	return nil
+}
+
+func variadicSuffix(s string) string {
+	if strings.HasSuffix(s, "...") {
+		return "..."
+	}
+	return ""
+}
+
 func replace(args *ReplaceArgs) (string, error) {
 	// 通过模板引擎将 ReplaceArgs 中的值替换到模板中的占位符位置，最终生成目标的装饰器代码。
 	tpl, err := template.
 		New("decorReplace").
 		Delims("${", "}").
-		Funcs(map[string]any{"stringer": stringer}).
+		Funcs(map[string]any{"stringer": stringer, "quoter": quoter}).
 		Parse(replaceTpl)
 	if err != nil {
 		return "", err
@@ -146,7 +283,15 @@ func builderReplaceArgs(f *ast.FuncDecl, decorName string, decorParams []string,
 	// 判断是否有接收者（方法的接收者），并设置其类型
 	if f.Recv != nil && f.Recv.List != nil && len(f.Recv.List) > 0 {
 		ra.TKind = "KMethod"
-		ra.ReceiverVarName = f.Recv.List[0].Names[0].Name
+		// 接收者未命名或为 "_" 时（如 func (T) foo()），为其生成一个新的名字，
+		// 否则 Receiver 字段无从赋值，装饰器也拿不到接收者。
+		recv := f.Recv.List[0]
+		if len(recv.Names) == 0 {
+			recv.Names = []*ast.Ident{{Name: gi.nextStr()}}
+		} else if recv.Names[0].Name == "_" {
+			recv.Names[0].Name = gi.nextStr()
+		}
+		ra.ReceiverVarName = recv.Names[0].Name
 	}
 
 	// 假设我们有以下泛型函数：
@@ -172,6 +317,12 @@ func builderReplaceArgs(f *ast.FuncDecl, decorName string, decorParams []string,
 	// 在构建闭包时，泛型类型参数并不直接影响闭包的内部逻辑，通常我们只关心 闭包的函数体，而不是类型参数。
 	// 为了简化这个过程，通过暂时移除泛型类型参数，使得 printer.Fprint 打印出的只是函数体部分，而不包含冗余的泛型信息。
 
+	// 泛型目标在运行时通过 decor.TypeName 获取本次调用的类型实参
+	for _, name := range typeParamNames(f) {
+		ra.TypeParams = append(ra.TypeParams, name)
+		ra.TypeArgs = append(ra.TypeArgs, fmt.Sprintf("decor.TypeName[%s]()", name))
+	}
+
 	var tp *ast.FieldList
 	if f.Type != nil && f.Type.TypeParams != nil {
 		tp = f.Type.TypeParams  // 将函数的类型参数保存到变量 tp 中，之后会用来恢复类型参数。
@@ -216,101 +367,281 @@ func builderReplaceArgs(f *ast.FuncDecl, decorName string, decorParams []string,
 	//	ra.DecorListOut = [ "DecorVarName.TargetOut[0]", "DecorVarName.TargetOut[1]" ]
 	//	ra.DecorCallOut = [ "func() int { o, _ := DecorVarName.TargetOut[0].(int); return o }()", "func() error { o, _ := DecorVarName.TargetOut[1].(error); return o }()" ]
 
-	// 检查该函数是否有返回值
-	if f.Type.Results != nil && f.Type.Results.List != nil {
-		// 遍历返回值
-		for _, r := range f.Type.Results.List {
-			// 返回值已经有名称，不需要生成新的名称，跳过
-			if r.Names != nil {
-				continue
-			}
-			// 返回值没有名称，为其生成一个新的名字
-			r.Names = []*ast.Ident{
-				{
-					NamePos: 0,
-					Name:    gi.nextStr(), // 生成新的名称（一个递增的标识符）
-					Obj:     nil,
-				},
+	// 生成的函数体引用的标识符（decor 包、装饰器和装饰器参数中的名字），
+	// 和它们同名的参数或返回值会遮蔽它们，需要改名。
+	reserved := referencedIdents(append([]string{"decor", decorName}, decorParams...)...)
+
+	// 返回值和参数的名称可以是命名、未命名、"_" 的任意组合，例如 (a int, _ string, err error) 、(int, error) 、(a, _ int) ，
+	// nameFields 统一为它们分配在函数体中可以引用的名字。改名只影响函数签名，闭包 FuncMain 已经按原来的名字打印。
+	for i, field := range nameFields(f.Type.Results, reserved, gi) {
+		typ := typeString(field.typ)
+		ra.OutArgNames = append(ra.OutArgNames, field.name)
+		ra.OutParamNames = append(ra.OutParamNames, gi.sourceName(field.name))
+		ra.OutArgTypes = append(ra.OutArgTypes, typ)
+		ra.DecorListOut = append(ra.DecorListOut, fmt.Sprintf("%s.TargetOut[%d]", ra.DecorVarName, i))
+		ra.DecorCallOut = append(ra.DecorCallOut,
+			fmt.Sprintf("func() %s {o,_ := %s.TargetOut[%d].(%s); return o}()", typ, ra.DecorVarName, i, typ))
+	}
+
+	for i, field := range nameFields(f.Type.Params, reserved, gi) {
+		typ := typeString(field.typ)
+		ra.InArgNames = append(ra.InArgNames, field.name)
+		ra.InParamNames = append(ra.InParamNames, gi.sourceName(field.name))
+		ra.InArgTypes = append(ra.InArgTypes, typ)
+		// 闭包函数：func() int { o,_ := decorator.TargetIn[0].(int); return o }()
+		ra.DecorCallIn = append(ra.DecorCallIn,
+			fmt.Sprintf("func() %s {o,_ := %s.TargetIn[%d].(%s); return o}()%s", typ, ra.DecorVarName, i, typ, elString(field.typ)))
+	}
+
+	ra.HaveReturn = len(ra.OutArgNames) != 0
+	return ra
+}
+
+// 参数或返回值列表展开后的一项
+type namedField struct {
+	name string
+	typ  ast.Expr
+}
+
+// 按顺序展开参数或返回值列表 fl ，并为需要的项生成新的名字：
+//   - 未命名的项，如 (int, error)
+//   - 名为 "_" 的项，如 (a int, _ string) 、(a, _ int)（fix issue #10）
+//   - 和 reserved 中的标识符同名的项，如 (decor int)
+//
+// 新名字直接写回 fl ，原来的名字记录在 gi 中，sourceName 返回源码中的名字。
+// Go 要求参数要么全部命名，要么全部未命名，因此生成的名字不会和已有的名字混在一起。
+func nameFields(fl *ast.FieldList, reserved map[string]bool, gi *genIdentId) []namedField {
+	if fl == nil {
+		return nil
+	}
+	var fields []namedField
+	for _, field := range fl.List {
+		if len(field.Names) == 0 {
+			field.Names = []*ast.Ident{{Name: gi.nextStr()}}
+		}
+		for _, ident := range field.Names {
+			if ident.Name == "_" {
+				ident.Name = gi.nextStr()
+			} else if reserved[ident.Name] {
+				ident.Name = gi.rename(ident.Name)
 			}
+			fields = append(fields, namedField{ident.Name, field.Type})
 		}
+	}
+	return fields
+}
 
-		// 返回值序号
-		count := 0
-		// 遍历返回值
-		for _, r := range f.Type.Results.List {
-			if len(r.Names) == 0 {
-				continue
+// 表达式 exprs 中引用的标识符，不包括选择器 x.Sel 中的 Sel 。无法解析的表达式被忽略。
+func referencedIdents(exprs ...string) map[string]bool {
+	idents := map[string]bool{}
+	for _, s := range exprs {
+		expr, err := parser.ParseExpr(s)
+		if err != nil {
+			continue
+		}
+		ast.Inspect(expr, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				ast.Inspect(n.X, func(n ast.Node) bool {
+					if ident, ok := n.(*ast.Ident); ok {
+						idents[ident.Name] = true
+					}
+					return true
+				})
+				return false
+			case *ast.Ident:
+				idents[n.Name] = true
+			}
+			return true
+		})
+	}
+	return idents
+}
+
+// 目标的函数体中直接调用的 recover() 。目标作为延迟函数（defer target()）时，recover 只有在被延迟函数直接调用时才生效，
+// 而装饰后原来的函数体位于闭包中，recover() 总是返回 nil 。因此装饰后的函数在开头调用 recover() ，
+// 函数体中的 recover() 改为读取它的结果，见 hoistRecover 。
+//
+// 提前调用 recover() 只在目标本来就会立即调用它时和装饰前相同，所以函数体中的 recover() 都需要在第一条语句中，
+// 并且不在 && 、|| 的右侧等有条件执行的位置，例如 r := recover() 、if r := recover(); r != nil {...} ，否则返回错误。
+// 嵌套的函数字面量中的 recover() 不受装饰影响，不包括在内。recover 被包或函数中的声明遮蔽时没有需要替换的调用。
+func directRecoverCalls(fd *ast.FuncDecl, declared map[string]bool) ([]*ast.Ident, error) {
+	if fd.Body == nil || declared["recover"] || declaresName(fd, "recover") {
+		return nil, nil
+	}
+	var calls []*ast.Ident
+	for _, stmt := range fd.Body.List {
+		calls = append(calls, recoverCalls(stmt, false)...)
+	}
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	var first []*ast.Ident
+	for _, expr := range unconditionalExprs(fd.Body.List[0]) {
+		first = append(first, recoverCalls(expr, true)...)
+	}
+	if len(first) != len(calls) {
+		return nil, errors.New("target " + fd.Name.Name + " calls recover() after its first statement or conditionally, " +
+			"the decorated function has to recover the panic before the decorators run, " +
+			"call recover() unconditionally in the first statement of the target")
+	}
+	return calls, nil
+}
+
+// 节点 node 中对 recover() 的调用，不包括函数字面量中的。unconditional 为 true 时也不包括 && 、|| 右侧的
+func recoverCalls(node ast.Node, unconditional bool) []*ast.Ident {
+	var calls []*ast.Ident
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BinaryExpr:
+			if unconditional && (n.Op == token.LAND || n.Op == token.LOR) {
+				calls = append(calls, recoverCalls(n.X, true)...)
+				return false
 			}
-			// 遍历当前返回值的名称（每个返回值可能有多个名称）
-			for _, p := range r.Names {
-				// 如果返回值的名称为 "_" ，为它生成一个新的名字。
-				if p.Name == "_" {
-					// fix issue #10. If the parameter name is “_”, we need to create a new name to replace it since the context will use this variable
-					p.Name = gi.nextStr()
+		case *ast.CallExpr:
+			if ident, ok := n.Fun.(*ast.Ident); ok && ident.Name == "recover" && len(n.Args) == 0 {
+				calls = append(calls, ident)
+			}
+		}
+		return true
+	})
+	return calls
+}
+
+// 语句 stmt 执行时一定会求值的表达式
+func unconditionalExprs(stmt ast.Stmt) []ast.Expr {
+	switch s := stmt.(type) {
+	case *ast.ExprStmt:
+		return []ast.Expr{s.X}
+	case *ast.AssignStmt:
+		return s.Rhs
+	case *ast.DeclStmt:
+		var exprs []ast.Expr
+		if gd, ok := s.Decl.(*ast.GenDecl); ok {
+			for _, spec := range gd.Specs {
+				if vs, ok := spec.(*ast.ValueSpec); ok {
+					exprs = append(exprs, vs.Values...)
 				}
-				// 将返回值名称添加到 ra.OutArgNames 中。
-				ra.OutArgNames = append(ra.OutArgNames, p.Name)
-				// 将返回值类型添加到 ra.OutArgTypes 中。typeString 是一个方法，用于将返回值的类型转换为字符串形式。
-				ra.OutArgTypes = append(ra.OutArgTypes, typeString(r.Type))
-				ra.DecorListOut = append(ra.DecorListOut, fmt.Sprintf("%s.TargetOut[%d]", ra.DecorVarName, count))
-				ra.DecorCallOut = append(ra.DecorCallOut,
-					//fmt.Sprintf("%s.TargetOut[%d].(%s)", ra.DecorVarName, count, typeString(r.Type)))
-					fmt.Sprintf(
-						"func() %s {o,_ := %s.TargetOut[%d].(%s); return o}()",
-						typeString(r.Type),
-						ra.DecorVarName,
-						count,
-						typeString(r.Type),
-					),
-				)
-
-				// 处理下一个返回值。
-				count++
 			}
 		}
+		return exprs
+	case *ast.ReturnStmt:
+		return s.Results
+	case *ast.IfStmt:
+		return append(unconditionalExprs(s.Init), s.Cond)
+	case *ast.SwitchStmt:
+		if s.Tag == nil {
+			return unconditionalExprs(s.Init)
+		}
+		return append(unconditionalExprs(s.Init), s.Tag)
+	case *ast.TypeSwitchStmt:
+		return append(unconditionalExprs(s.Init), unconditionalExprs(s.Assign)...)
 	}
+	return nil
+}
 
-	// 检查函数的参数列表是否存在且非空
-	if f.Type.Params.List != nil && len(f.Type.Params.List) > 0 {
-		// 给每个参数分配一个唯一的索引值
-		count := 0
-		// 遍历参数列表
-		for _, r := range f.Type.Params.List {
-			// 如果参数没有名称（例如 _），就跳过
-			if len(r.Names) == 0 {
-				continue
+// 函数 fd 中是否声明了 name （参数、局部变量、常量、类型等），不包括结构体的字段和接口的方法
+func declaresName(fd *ast.FuncDecl, name string) (found bool) {
+	ast.Inspect(fd, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.StructType, *ast.InterfaceType:
+			return false
+		case *ast.Field:
+			found = found || hasIdent(n.Names, name)
+		case *ast.ValueSpec:
+			found = found || hasIdent(n.Names, name)
+		case *ast.TypeSpec:
+			found = found || n.Name.Name == name
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				found = found || hasIdent(n.Lhs, name)
 			}
-			// 遍历每个参数的名称
-			for _, p := range r.Names {
-				// 生成的新名称
-				if p.Name == "_" {
-					// fix issue #10. If the parameter name is “_”, we need to create a new name to replace it since the context will use this variable
-					p.Name = gi.nextStr()
-				}
-				// 存储所有输入参数的名称。
-				ra.InArgNames = append(ra.InArgNames, p.Name)
-				// 存储所有输入参数的类型。
-				ra.InArgTypes = append(ra.InArgTypes, typeString(r.Type))
-
-				// 闭包函数：func() int { o,_ := decorator.TargetIn[0].(int); return o }()
-				ra.DecorCallIn = append(ra.DecorCallIn,
-					//fmt.Sprintf("%s.TargetIn[%d].(%s)%s", ra.DecorVarName, count, typeString(r.Type), elString(r.Type)))
-					fmt.Sprintf(
-						"func() %s {o,_ := %s.TargetIn[%d].(%s); return o}()%s",
-						typeString(r.Type),
-						ra.DecorVarName,
-						count,
-						typeString(r.Type),
-						elString(r.Type),
-					),
-				)
-				count++
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				found = found || hasIdent([]ast.Expr{n.Key, n.Value}, name)
 			}
 		}
+		return !found
+	})
+	return found
+}
+
+// 标识符列表中是否有 name ，ids 的元素为 *ast.Ident 或 ast.Expr
+func hasIdent[T ast.Node](ids []T, name string) bool {
+	for _, id := range ids {
+		if ident, ok := any(id).(*ast.Ident); ok && ident.Name == name {
+			return true
+		}
 	}
+	return false
+}
 
-	ra.HaveReturn = len(ra.OutArgNames) != 0
-	return ra
+// 把 directRecoverCalls 返回的 recover() 替换为读取在外层函数的开头调用 recover() 的结果：
+//
+//	rv := recover()
+//	take := func() any { v := rv; rv = nil; return v }
+//	... // 函数体中的 recover() 改为 take() ，只有第一次调用返回 panic 的值
+//	if rv != nil { panic(rv) } // 装饰器没有调用目标时继续 panic
+//
+// 替换只修改调用的函数名，不影响行号。返回 rv 和 take 的变量名，calls 为空时返回空字符串。
+func hoistRecover(calls []*ast.Ident, gi *genIdentId) (rv, take string) {
+	if len(calls) == 0 {
+		return "", ""
+	}
+	rv, take = gi.nextStr(), gi.nextStr()
+	for _, ident := range calls {
+		ident.Name = take
+	}
+	return rv, take
+}
+
+// hoistRecover 在装饰后的函数体开头和装饰器调用之后添加的语句
+func recoverStmts(rv, take string) (head, tail []ast.Stmt, err error) {
+	head, _, err = getStmtList(fmt.Sprintf("%s := recover()\n%s := func() any { v := %s; %s = nil; return v }", rv, take, rv, rv))
+	if err != nil {
+		return nil, nil, err
+	}
+	tail, _, err = getStmtList(fmt.Sprintf("if %s != nil { panic(%s) }", rv, rv))
+	return head, tail, err
+}
+
+// 泛型目标的类型参数名：方法接收者类型的类型参数在前，函数自己的类型参数在后。
+// 为 "_" 的类型参数无法引用，不包括在内。
+//
+//	func (p Pair[K, V]) Lookup(k K) (V, bool)  // K, V
+//	func Map[T, R any](s []T, f func(T) R) []R // T, R
+func typeParamNames(f *ast.FuncDecl) []string {
+	var names []string
+	if f.Recv != nil && len(f.Recv.List) > 0 {
+		typ := f.Recv.List[0].Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		var indices []ast.Expr
+		switch t := typ.(type) {
+		case *ast.IndexExpr:
+			indices = []ast.Expr{t.Index}
+		case *ast.IndexListExpr:
+			indices = t.Indices
+		}
+		for _, index := range indices {
+			if id, ok := index.(*ast.Ident); ok && id.Name != "_" {
+				names = append(names, id.Name)
+			}
+		}
+	}
+	if f.Type != nil && f.Type.TypeParams != nil {
+		for _, field := range f.Type.TypeParams.List {
+			for _, id := range field.Names {
+				if id.Name != "_" {
+					names = append(names, id.Name)
+				}
+			}
+		}
+	}
+	return names
 }
 
 // typeString 函数的核心功能是将 Go 语言的表达式类型（ast.Expr）转换为对应的字符串表示，并在有特殊情况（如变长参数类型）时进行适当的格式化。
@@ -342,8 +673,9 @@ func typeString(expr ast.Expr) string {
 	return s
 }
 
+// 可变参数 ...T 在闭包调用时需要展开，普通的切片参数 []T 不能展开
 func elString(expr ast.Expr) string {
-	if strings.HasPrefix(typeString(expr), "[]") {
+	if _, ok := expr.(*ast.Ellipsis); ok {
 		return "..."
 	}
 	return ""
@@ -357,6 +689,15 @@ func stringer(elems []string) string {
 	return strings.Join(elems, ", ")
 }
 
+// quoter 将每一项转换为 Go 字符串字面量后，以逗号分隔
+func quoter(elems []string) string {
+	quoted := make([]string, 0, len(elems))
+	for _, v := range elems {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+	return strings.Join(quoted, ", ")
+}
+
 func randStr(le int) string {
 	s := ""
 	for i := 0; i < le; i++ {
@@ -367,8 +708,9 @@ func randStr(le int) string {
 }
 
 type genIdentId struct {
-	id    int
-	ident string
+	id      int
+	ident   string
+	renamed map[string]string // 因为和生成的代码冲突而改名的参数或返回值，值为源码中的名字
 }
 
 func newGenIdentId() *genIdentId {
@@ -389,11 +731,40 @@ func (g *genIdentId) nextStr() string {
 	return g.ident + strconv.Itoa(g.id)
 }
 
-// TODO
+// 为源码中的名称 name 生成一个新的名字，sourceName 仍然返回 name
+func (g *genIdentId) rename(name string) string {
+	s := g.nextStr()
+	if g.renamed == nil {
+		g.renamed = map[string]string{}
+	}
+	g.renamed[s] = name
+	return s
+}
+
+// 源码中的名称，由 nextStr 生成的名称（原本未命名或为 "_"）返回空字符串
+func (g *genIdentId) sourceName(name string) string {
+	if source, ok := g.renamed[name]; ok {
+		return source
+	}
+	if strings.HasPrefix(name, g.ident) {
+		return ""
+	}
+	return name
+}
+
+// 判断函数 fd 是否为装饰器，有接收者的方法不是，方法见 funIsBoundDecorator
 func funIsDecorator(fd *ast.FuncDecl, pkgName string) bool {
+	return fd != nil && fd.Recv == nil && hasDecoratorSignature(fd, pkgName)
+}
+
+// 判断方法 fd 是否可以作为绑定装饰器，即 //go:decor registry.Logging 中包级变量 registry 的类型上的方法
+func funIsBoundDecorator(fd *ast.FuncDecl, pkgName string) bool {
+	return fd != nil && fd.Recv != nil && hasDecoratorSignature(fd, pkgName)
+}
+
+// fd 的参数是否只有一个 *pkgName.Context 或类型化的上下文，不检查接收者
+func hasDecoratorSignature(fd *ast.FuncDecl, pkgName string) bool {
 	if pkgName == "" ||
-		fd == nil ||
-		fd.Recv != nil ||
 		fd.Type == nil ||
 		fd.Type.Params == nil ||
 		fd.Type.Params.NumFields() != 1 ||
@@ -405,10 +776,40 @@ func funIsDecorator(fd *ast.FuncDecl, pkgName string) bool {
 	buffer := bytes.NewBuffer([]byte{})
 	err := printer.Fprint(buffer, emptyFset, expr)
 	if err != nil {
-		logs.Debug("funIsDecorator printer.Fprint fail", err)
+		logs.Debug("hasDecoratorSignature printer.Fprint fail", err)
 		return false
 	}
-	return strings.TrimSpace(buffer.String()) == fmt.Sprintf("*%s.Context", pkgName)
+	_, _, _, ok := decorContextType(strings.TrimSpace(buffer.String()), pkgName)
+	return ok
+}
+
+// 类型化上下文的类型名，如 Context1In1Out
+var typedContextName = regexp.MustCompile(`^Context([0-2])In([0-2])Out$`)
+
+// 判断装饰器第一个参数的类型 typ 是否为 *pkgName.Context 或类型化的上下文 *pkgName.ContextNInMOut[...] ，
+// pkgName 为空时表示在 decor 包内。是类型化的上下文时 typed 为 true ，in 、out 为目标的参数和返回值个数。
+func decorContextType(typ, pkgName string) (in, out int, typed, ok bool) {
+	prefix := "*"
+	if pkgName != "" {
+		prefix += pkgName + "."
+	}
+	if !strings.HasPrefix(typ, prefix) {
+		return
+	}
+	name := typ[len(prefix):]
+	if name == "Context" {
+		return 0, 0, false, true
+	}
+	if k := strings.IndexByte(name, '['); k >= 0 && strings.HasSuffix(name, "]") {
+		name = name[:k]
+	}
+	m := typedContextName.FindStringSubmatch(name)
+	if m == nil {
+		return
+	}
+	in, _ = strconv.Atoi(m[1])
+	out, _ = strconv.Atoi(m[2])
+	return in, out, true, true
 }
 
 func getStmtList(s string) (r []ast.Stmt, i int, err error) {
@@ -423,16 +824,32 @@ func getStmtList(s string) (r []ast.Stmt, i int, err error) {
 	return
 }
 
-// same like /usr/local/go/src/go/parser/interface.go:139#ParseDir
-func parserGOFiles(fset *token.FileSet, files ...string) (*ast.Package, error) {
-	var pkg *ast.Package
-	for _, file := range files {
-		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
-		if err != nil {
+// 一个包的语法树。ast.Package 从 Go 1.22 起已废弃，这里只保留用到的包名和文件。
+// 不改用 golang.org/x/tools/go/packages ：decor 运行时和本工具在同一个模块中，引入它会给所有使用 decor 的项目
+// 带来外部依赖；包目录仍由 go list 解析，vendor 目录和 replace 指令由 go list 处理，装饰器签名由 decorContextType 检查。
+type astPackage struct {
+	Name  string               // 包名
+	Files map[string]*ast.File // 文件路径到语法树
+}
+
+// 文件并行解析，返回 files 中第一个解析出错的文件的错误
+func parserGOFiles(fset *token.FileSet, files ...string) (*astPackage, error) {
+	parsed := make([]*ast.File, len(files))
+	errs := make([]error, len(files))
+	parallelEach(len(files), func(i int) {
+		parsed[i], errs[i] = parser.ParseFile(fset, files[i], nil, parser.ParseComments)
+	})
+	var pkg *astPackage
+	for i, file := range files {
+		if errs[i] != nil {
+			return pkg, errs[i]
+		}
+		f := parsed[i]
+		if err := joinDecorArgs(fset, f); err != nil {
 			return pkg, err
 		}
 		if pkg == nil {
-			pkg = &ast.Package{
+			pkg = &astPackage{
 				Name:  f.Name.Name,
 				Files: make(map[string]*ast.File),
 			}
@@ -442,6 +859,82 @@ func parserGOFiles(fset *token.FileSet, files ...string) (*ast.Package, error) {
 	return pkg, nil
 }
 
+// 解析目录 dir 中的 .go 文件，按包名分组，代替已废弃的 parser.ParseDir 。
+// filter 不为空时只解析 filter 返回 true 的文件。和 parser.ParseDir 一样不处理构建约束，
+// 返回 dir 中按文件名排序后第一个解析出错的文件的错误。
+func parseGoDir(fset *token.FileSet, dir string, filter func(fs.FileInfo) bool) (map[string]*astPackage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+		if filter != nil {
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			if !filter(info) {
+				continue
+			}
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	parsed := make([]*ast.File, len(files))
+	errs := make([]error, len(files))
+	parallelEach(len(files), func(i int) {
+		parsed[i], errs[i] = parser.ParseFile(fset, files[i], nil, parser.ParseComments)
+	})
+	pkgs := map[string]*astPackage{}
+	for i, file := range files {
+		if errs[i] != nil {
+			return pkgs, errs[i]
+		}
+		f := parsed[i]
+		pkg, ok := pkgs[f.Name.Name]
+		if !ok {
+			pkg = &astPackage{Name: f.Name.Name, Files: map[string]*ast.File{}}
+			pkgs[f.Name.Name] = pkg
+		}
+		pkg.Files[file] = f
+	}
+	return pkgs, nil
+}
+
+// 使用最多 GOMAXPROCS 个 goroutine 对 0 到 n-1 执行 fn ，全部完成后返回。
+// fn 只能写入各自下标的结果，不同下标之间不能共享可变的状态。
+func parallelEach(n int, fn func(i int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
 func assignStmtPos(f, t ast.Node, depth bool) {
 	if f == nil || t == nil {
 		return
@@ -545,6 +1038,18 @@ func assignStmtPos(f, t ast.Node, depth bool) {
 				assignStmtPos(name, t, depth)
 			}
 		}
+	case *ast.StarExpr:
+		v.Star = t.Pos()
+		assignStmtPos(v.X, t, depth)
+	case *ast.ExprStmt:
+		assignStmtPos(v.X, t, depth)
+	case *ast.IfStmt:
+		v.If = t.Pos()
+		if depth {
+			assignStmtPos(v.Cond, t, depth)
+			assignStmtPos(v.Body, t, depth)
+			assignStmtPos(v.Else, t, depth)
+		}
 	case *ast.CallExpr:
 		v.Lparen = t.Pos()
 		v.Rparen = t.Pos()
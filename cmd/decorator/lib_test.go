@@ -5,6 +5,11 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -118,6 +123,32 @@ func TestFunIsDecorator(t *testing.T) {
 	check("a", "a")
 }
 
+func TestFunIsDecoratorMethod(t *testing.T) {
+	src := `package main
+import "github.com/dengsgo/go-decorator/decor"
+func (r *registry) Logging(ctx *decor.Context) {}
+func (r registry) Named(ctx *decor.Context, name string) {}
+func (r *registry) Target(s string) string { return s }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"Logging": true, "Named": false, "Target": false}
+	for _, v := range f.Decls {
+		fd, ok := v.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if funIsDecorator(fd, "decor") {
+			t.Fatalf("funIsDecorator(%s) should be false for a method", fd.Name.Name)
+		}
+		if funIsBoundDecorator(fd, "decor") != want[fd.Name.Name] {
+			t.Fatalf("funIsBoundDecorator(%s) want %v", fd.Name.Name, want[fd.Name.Name])
+		}
+	}
+}
+
 func testGetCode(name, pkgName string) string {
 	return fmt.Sprintf(`
 package main
@@ -128,3 +159,702 @@ func notDecorator2(ctx %s.Contex) {}
 func notDecorator3(a int) {}
 `, name, pkgName, pkgName, pkgName)
 }
+
+func TestBuilderReplaceArgsReceiver(t *testing.T) {
+	src := `package main
+func target(s string) string { return s }
+func (m *methodType) named(s string) string { return s }
+func (*methodType) unnamed(s string) string { return s }
+func (_ methodType) blank(s string) string { return s }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal("TestBuilderReplaceArgsReceiver parse error", err)
+	}
+	gi := newGenIdentId()
+	for _, v := range f.Decls {
+		fd := v.(*ast.FuncDecl)
+		ra := builderReplaceArgs(fd, "dumpDecorContext", nil, gi)
+		if fd.Recv == nil {
+			if ra.TKind != "KFunc" || ra.ReceiverVarName != "nil" {
+				t.Fatalf("builderReplaceArgs(%s) want KFunc with nil receiver, but got %s %s\n",
+					fd.Name.Name, ra.TKind, ra.ReceiverVarName)
+			}
+			continue
+		}
+		if ra.TKind != "KMethod" {
+			t.Fatalf("builderReplaceArgs(%s) want KMethod, but got %s\n", fd.Name.Name, ra.TKind)
+		}
+		names := fd.Recv.List[0].Names
+		if len(names) != 1 || names[0].Name == "_" || names[0].Name != ra.ReceiverVarName {
+			t.Fatalf("builderReplaceArgs(%s) receiver not threaded, got %+v, ReceiverVarName %s\n",
+				fd.Name.Name, names, ra.ReceiverVarName)
+		}
+		if fd.Name.Name == "named" && ra.ReceiverVarName != "m" {
+			t.Fatalf("builderReplaceArgs(named) should keep receiver name m, but got %s\n", ra.ReceiverVarName)
+		}
+	}
+}
+
+func TestBuilderReplaceArgsAnonymousStruct(t *testing.T) {
+	src := "package main\n" +
+		"func target(cfg struct{ A int }, tagged struct {\n" +
+		"\tName string `json:\"name\"`\n" +
+		"\tAge  int    `json:\"age,omitempty\"`\n" +
+		"}) struct{ A int `json:\"a\"` } {\n" +
+		"\treturn struct{ A int `json:\"a\"` }{cfg.A}\n" +
+		"}\n"
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := f.Decls[0].(*ast.FuncDecl)
+	ra := builderReplaceArgs(fd, "logging", nil, newGenIdentId())
+	want := []string{
+		"struct{ A int }",
+		"struct {\n\tName\tstring\t`json:\"name\"`\n\tAge\tint\t`json:\"age,omitempty\"`\n}",
+	}
+	for i, v := range want {
+		if ra.InArgTypes[i] != v {
+			t.Fatalf("builderReplaceArgs() InArgTypes[%d] want %q, but got %q\n", i, v, ra.InArgTypes[i])
+		}
+	}
+	if ra.OutArgTypes[0] != "struct {\n\tA int `json:\"a\"`\n}" {
+		t.Fatalf("builderReplaceArgs() OutArgTypes[0] not match, got %q\n", ra.OutArgTypes[0])
+	}
+	rs, err := replace(ra)
+	if err != nil {
+		t.Fatal("replace() error", err)
+	}
+	if _, _, err := getStmtList(rs); err != nil {
+		t.Fatal("getStmtList() generated code with anonymous struct should be valid, error", err, rs)
+	}
+}
+
+func TestBuilderReplaceArgsParamNames(t *testing.T) {
+	src := `package main
+func target(a int, _ string, rest ...int) (_ int, _ error) { return a, nil }
+func named(s struct{ A int ` + "`json:\"a\"`" + ` }) (n int, err error) { return }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas := []struct {
+		in, out []string
+		code    string
+	}{
+		{
+			[]string{"a", "", "rest"}, []string{"", ""},
+			`TargetInNames:  []string{"a", "", "rest"},
+        TargetOutNames: []string{"", ""},
+        TargetInTypes:  []string{"int", "string", "[]int"},
+        TargetOutTypes: []string{"int", "error"},`,
+		},
+		{
+			[]string{"s"}, []string{"n", "err"},
+			"TargetInTypes:  []string{" + strconv.Quote("struct {\n\tA int `json:\"a\"`\n}") + "},",
+		},
+	}
+	for i, c := range cas {
+		fd := f.Decls[i].(*ast.FuncDecl)
+		gi := newGenIdentId()
+		ra := builderReplaceArgs(fd, "logging", nil, gi)
+		// 链式装饰时，后面的层会看到前面的层生成的名称
+		ra = builderReplaceArgs(fd, "logging", nil, gi)
+		if strings.Join(ra.InParamNames, ",") != strings.Join(c.in, ",") ||
+			strings.Join(ra.OutParamNames, ",") != strings.Join(c.out, ",") {
+			t.Fatalf("cas[%d] builderReplaceArgs() param names want %q %q, but got %q %q", i, c.in, c.out, ra.InParamNames, ra.OutParamNames)
+		}
+		rs, err := replace(ra)
+		if err != nil {
+			t.Fatal("replace() error", err)
+		}
+		if !strings.Contains(rs, c.code) {
+			t.Fatalf("cas[%d] replace() should contain %s, but got:\n%s", i, c.code, rs)
+		}
+		if _, _, err := getStmtList(rs); err != nil {
+			t.Fatalf("cas[%d] getStmtList() generated code should be valid, error %v", i, err)
+		}
+	}
+}
+
+func TestBuilderReplaceArgsFieldCombinations(t *testing.T) {
+	cas := []struct {
+		decorName string
+		sig       string
+		in, out   []string // 源码中的名字，未命名或为 "_" 时为空
+	}{
+		{"logging", "()", nil, nil},
+		{"logging", "() int", nil, []string{""}},
+		{"logging", "() (int, string, error)", nil, []string{"", "", ""}},
+		{"logging", "() (a int, _ string, err error)", nil, []string{"a", "", "err"}},
+		{"logging", "() (_ int, _ string, _ error)", nil, []string{"", "", ""}},
+		{"logging", "() (a, _ int, _, s string)", nil, []string{"a", "", "", "s"}},
+		{"logging", "(int, string) (int, error)", []string{"", ""}, []string{"", ""}},
+		{"logging", "(a int, _ string, err error) (_ int, b string)", []string{"a", "", "err"}, []string{"", "b"}},
+		{"logging", "(_, _ int, _ ...string) (_ bool)", []string{"", "", ""}, []string{""}},
+		{"logging", "(a, _, c int) (x, _, z int)", []string{"a", "", "c"}, []string{"x", "", "z"}},
+		// 和生成的代码引用的 decor 包、装饰器同名的参数和返回值
+		{"logging", "(decor int, logging string) (decor2 int, err error)", []string{"decor", "logging"}, []string{"decor2", "err"}},
+		{"logging", "(a int) (decor, _ int)", []string{"a"}, []string{"decor", ""}},
+		{"pkg.Logging", "(pkg, Logging int) (decor error)", []string{"pkg", "Logging"}, []string{"decor"}},
+	}
+	for i, c := range cas {
+		f, err := parser.ParseFile(token.NewFileSet(), "main.go", "package main\nfunc target"+c.sig+" { panic(0) }\n", 0)
+		if err != nil {
+			t.Fatalf("cas[%d] %s", i, err)
+		}
+		fd := f.Decls[0].(*ast.FuncDecl)
+		ra := builderReplaceArgs(fd, c.decorName, nil, newGenIdentId())
+		if strings.Join(ra.InParamNames, ",") != strings.Join(c.in, ",") ||
+			strings.Join(ra.OutParamNames, ",") != strings.Join(c.out, ",") {
+			t.Fatalf("cas[%d] %s param names want %q %q, but got %q %q", i, c.sig, c.in, c.out, ra.InParamNames, ra.OutParamNames)
+		}
+		if len(ra.InArgNames) != len(ra.DecorCallIn) || len(ra.OutArgNames) != len(ra.DecorCallOut) || ra.HaveReturn != (len(c.out) > 0) {
+			t.Fatalf("cas[%d] %s arguments and results not match: %+v", i, c.sig, ra)
+		}
+		// 函数体中引用的名字互不相同，且不会遮蔽 decor 包和装饰器
+		seen := map[string]bool{"_": true, "decor": true, "pkg": true, "logging": true}
+		for _, name := range append(append([]string{}, ra.InArgNames...), ra.OutArgNames...) {
+			if seen[name] {
+				t.Fatalf("cas[%d] %s duplicate or shadowing name %q", i, c.sig, name)
+			}
+			seen[name] = true
+		}
+		// 签名被同步改名
+		var sigNames []string
+		for _, fl := range []*ast.FieldList{fd.Type.Params, fd.Type.Results} {
+			if fl == nil {
+				continue
+			}
+			for _, field := range fl.List {
+				for _, ident := range field.Names {
+					sigNames = append(sigNames, ident.Name)
+				}
+			}
+		}
+		if strings.Join(sigNames, ",") != strings.Join(append(append([]string{}, ra.InArgNames...), ra.OutArgNames...), ",") {
+			t.Fatalf("cas[%d] %s signature names %q not match %q %q", i, c.sig, sigNames, ra.InArgNames, ra.OutArgNames)
+		}
+		rs, err := replace(ra)
+		if err != nil {
+			t.Fatal("replace() error", err)
+		}
+		if _, _, err := getStmtList(rs); err != nil {
+			t.Fatalf("cas[%d] %s generated code should be valid, error %s\n%s", i, c.sig, err, rs)
+		}
+		// 闭包保留源码中的名字
+		if !strings.Contains(ra.FuncMain, "func"+c.sig) {
+			t.Fatalf("cas[%d] FuncMain should keep the source signature %s, but got %s", i, c.sig, ra.FuncMain)
+		}
+	}
+}
+
+func TestReferencedIdents(t *testing.T) {
+	got := referencedIdents("decor", "pkg.Logging", "func(c *decor.Context) { inner(c, level) }", `"msg"`, "#{")
+	for _, name := range []string{"decor", "pkg", "c", "inner", "level"} {
+		if !got[name] {
+			t.Fatalf("referencedIdents() should contain %q, got %v", name, got)
+		}
+	}
+	for _, name := range []string{"Logging", "Context", "msg"} {
+		if got[name] {
+			t.Fatalf("referencedIdents() should not contain %q, got %v", name, got)
+		}
+	}
+}
+
+func TestReplaceArgsUseCtxArg(t *testing.T) {
+	src := `package main
+func first(ctx context.Context, a int) {}
+func (m *methodType) method(c context.Context) {}
+func aliased(ctx stdctx.Context) {}
+func second(a int, ctx context.Context) {}
+func unnamed(context.Context) {}
+func other(ctx other.Context) {}
+func none() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas := []struct {
+		ctxPkgName, want string
+	}{
+		{"context", "ctx"},
+		{"context", "c"},
+		{"stdctx", "ctx"},
+		{"context", ""},
+		{"context", "*"}, // 未命名的参数使用生成的名字
+		{"context", ""},
+		{"context", ""},
+	}
+	gi := newGenIdentId()
+	for i, v := range cas {
+		fd := f.Decls[i].(*ast.FuncDecl)
+		ra := builderReplaceArgs(fd, "logging", nil, gi)
+		ra.useCtxArg(fd, v.ctxPkgName)
+		if v.want == "*" && ra.CtxArgName != "" && gi.sourceName(ra.CtxArgName) == "" {
+			continue
+		}
+		if ra.CtxArgName != v.want {
+			t.Fatalf("useCtxArg(%s) want %q, but got %q\n", fd.Name.Name, v.want, ra.CtxArgName)
+		}
+	}
+	// context 未导入
+	fd := f.Decls[0].(*ast.FuncDecl)
+	ra := builderReplaceArgs(fd, "logging", nil, gi)
+	ra.useCtxArg(fd, "")
+	if ra.CtxArgName != "" {
+		t.Fatal("useCtxArg() without context imported should be empty, but got", ra.CtxArgName)
+	}
+	rs, err := replace(ra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(rs, "Ctx:") {
+		t.Fatal("replace() should not set Ctx, got", rs)
+	}
+	ra.CtxArgName = "ctx"
+	if rs, err = replace(ra); err != nil || !strings.Contains(rs, "Ctx:        ctx,") {
+		t.Fatal("replace() should set Ctx, got", rs, err)
+	}
+}
+
+func TestBuilderReplaceArgsGenericReceiver(t *testing.T) {
+	src := `package main
+func (b *Box[T]) Merge(o *Box[T], fs ...func(T) T) *Box[T] { return o }
+func (p Pair[K, V]) Lookup(k K, def V) (V, bool) { return def, false }
+func (Pair[_, V]) Zero() (v V) { return }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas := []struct {
+		in, out []string
+	}{
+		{[]string{"*Box[T]", "[]func(T) T"}, []string{"*Box[T]"}},
+		{[]string{"K", "V"}, []string{"V", "bool"}},
+		{nil, []string{"V"}},
+	}
+	gi := newGenIdentId()
+	for i, c := range cas {
+		fd := f.Decls[i].(*ast.FuncDecl)
+		ra := builderReplaceArgs(fd, "logging", nil, gi)
+		if ra.TKind != "KMethod" || ra.ReceiverVarName == "nil" || ra.ReceiverVarName == "_" {
+			t.Fatalf("builderReplaceArgs(%s) receiver not match, got %s %s\n", fd.Name.Name, ra.TKind, ra.ReceiverVarName)
+		}
+		for j, typ := range c.in {
+			if !strings.Contains(ra.DecorCallIn[j], ".("+typ+")") {
+				t.Fatalf("builderReplaceArgs(%s) DecorCallIn[%d] should assert %s, got %s\n", fd.Name.Name, j, typ, ra.DecorCallIn[j])
+			}
+		}
+		for j, typ := range c.out {
+			if !strings.Contains(ra.DecorCallOut[j], ".("+typ+")") {
+				t.Fatalf("builderReplaceArgs(%s) DecorCallOut[%d] should assert %s, got %s\n", fd.Name.Name, j, typ, ra.DecorCallOut[j])
+			}
+		}
+		rs, err := replace(ra)
+		if err != nil {
+			t.Fatal("replace() error", err)
+		}
+		if _, _, err := getStmtList(rs); err != nil {
+			t.Fatal("getStmtList() generated code with generic receiver should be valid, error", err, rs)
+		}
+	}
+}
+
+func TestTypeParamNames(t *testing.T) {
+	src := `package main
+func plain(a int) {}
+func Map[T, R any](s []T, f func(T) R) []R { return nil }
+func Skip[_ any, T any]() {}
+func (b *Box[T]) Get() T { var v T; return v }
+func (p *Pair[K, V]) Swap() {}
+func (Pair[_, V]) Zero() (v V) { return }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas := []string{"", "T,R", "T", "T", "K,V", "V"}
+	for i, want := range cas {
+		fd := f.Decls[i].(*ast.FuncDecl)
+		if got := strings.Join(typeParamNames(fd), ","); got != want {
+			t.Fatalf("typeParamNames(%s) want %q, but got %q", fd.Name.Name, want, got)
+		}
+	}
+
+	ra := builderReplaceArgs(f.Decls[1].(*ast.FuncDecl), "logging", nil, newGenIdentId())
+	rs, err := replace(ra)
+	if err != nil {
+		t.Fatal("replace() error", err)
+	}
+	if !strings.Contains(rs, `TypeParams: []string{"T", "R"},`) ||
+		!strings.Contains(rs, `TypeArgs:   []string{decor.TypeName[T](), decor.TypeName[R]()},`) {
+		t.Fatal("replace() should fill TypeParams and TypeArgs, got", rs)
+	}
+	ra = builderReplaceArgs(f.Decls[0].(*ast.FuncDecl), "logging", nil, newGenIdentId())
+	if rs, _ := replace(ra); strings.Contains(rs, "TypeParams") {
+		t.Fatal("replace() of a non-generic target should not fill TypeParams, got", rs)
+	}
+}
+
+func TestDecorContextType(t *testing.T) {
+	cas := []struct {
+		typ, pkgName string
+		in, out      int
+		typed, ok    bool
+	}{
+		{"*decor.Context", "decor", 0, 0, false, true},
+		{"*d.Context1In1Out[A, R]", "d", 1, 1, true, true},
+		{"*decor.Context2In0Out[int, []string]", "decor", 2, 0, true, true},
+		{"*decor.Context0In0Out", "decor", 0, 0, true, true},
+		{"*Context0In2Out[A, B]", "", 0, 2, true, true},
+		{"*decor.Context3In0Out[A, B, C]", "decor", 0, 0, false, false},
+		{"decor.Context1In1Out[A, R]", "decor", 0, 0, false, false},
+		{"*other.Context1In1Out[A, R]", "decor", 0, 0, false, false},
+		{"*decor.ChainState", "decor", 0, 0, false, false},
+	}
+	for i, c := range cas {
+		in, out, typed, ok := decorContextType(c.typ, c.pkgName)
+		if in != c.in || out != c.out || typed != c.typed || ok != c.ok {
+			t.Fatalf("cas[%d] decorContextType(%s) want %d %d %v %v, got %d %d %v %v",
+				i, c.typ, c.in, c.out, c.typed, c.ok, in, out, typed, ok)
+		}
+	}
+}
+
+func TestReplaceArgsUseTypedContext(t *testing.T) {
+	src := `package main
+func add(a, b int) int { return a + b }
+func sum(ctx context.Context, nums ...int) (total int) { return }
+func none() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas := []struct {
+		in, out int
+		want    []string
+	}{
+		{2, 1, []string{"&decor.Context2In1Out[int, int, int]{", "In0: a,", "In1: b,", "Out0: ", ".Out0 = func(a, b int) int", ".In0, ", ".In1)", "return "}},
+		{2, 1, []string{"&decor.Context2In1Out[context.Context, []int, int]{", "In1: nums,", "Out0: total,", ".In1...)"}},
+		{0, 0, []string{"&decor.Context0In0Out{"}},
+	}
+	gi := newGenIdentId()
+	for i, c := range cas {
+		fd := f.Decls[i].(*ast.FuncDecl)
+		ra := builderReplaceArgs(fd, "typed", nil, gi)
+		ra.useCtxArg(fd, "context")
+		if err := ra.useTypedContext(c.in, c.out); err != nil {
+			t.Fatal("useTypedContext() error", err)
+		}
+		rs, err := replace(ra)
+		if err != nil {
+			t.Fatal("replace() error", err)
+		}
+		if _, _, err := getStmtList(rs); err != nil {
+			t.Fatal("getStmtList() generated code with typed context should be valid, error", err, rs)
+		}
+		for _, s := range c.want {
+			if !strings.Contains(rs, s) {
+				t.Fatalf("%s: replace() should contain %q, got\n%s", fd.Name.Name, s, rs)
+			}
+		}
+		if strings.Contains(rs, "[]any") || strings.Contains(rs, "Ctx:") {
+			t.Fatalf("%s: replace() with typed context should not box values, got\n%s", fd.Name.Name, rs)
+		}
+	}
+
+	ra := builderReplaceArgs(f.Decls[0].(*ast.FuncDecl), "typed", nil, gi)
+	if err := ra.useTypedContext(1, 1); err == nil || !strings.Contains(err.Error(), "2 parameters and 1 results") {
+		t.Fatal("useTypedContext() should report the arity of the target, got", err)
+	}
+}
+
+func TestBuilderReplaceArgsInlineTypes(t *testing.T) {
+	src := `package main
+func target(s interface {
+	String() string
+	Len() int
+}, check func(int) error, in <-chan int, out chan<- int, nested chan (<-chan int), list []int, rest ...func() error) (<-chan int, func(func(int) bool) (int, error)) {
+	return nil, nil
+}
+func unnamed(int, func(int) error) interface{ M() } { return nil }
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas := []struct {
+		in, out []string
+	}{
+		{
+			[]string{"interface {\n\tString() string\n\tLen() int\n}", "func(int) error", "<-chan int", "chan<- int", "chan (<-chan int)", "[]int", "[]func() error"},
+			[]string{"<-chan int", "func(func(int) bool) (int, error)"},
+		},
+		{[]string{"int", "func(int) error"}, []string{"interface{ M() }"}},
+	}
+	gi := newGenIdentId()
+	for i, c := range cas {
+		fd := f.Decls[i].(*ast.FuncDecl)
+		ra := builderReplaceArgs(fd, "logging", nil, gi)
+		if strings.Join(ra.InArgTypes, ",") != strings.Join(c.in, ",") || strings.Join(ra.OutArgTypes, ",") != strings.Join(c.out, ",") {
+			t.Fatalf("builderReplaceArgs(%s) types not match, got %q %q\n", fd.Name.Name, ra.InArgTypes, ra.OutArgTypes)
+		}
+		for j, typ := range c.in {
+			if !strings.Contains(ra.DecorCallIn[j], ".("+typ+")") {
+				t.Fatalf("builderReplaceArgs(%s) DecorCallIn[%d] should assert %s, got %s\n", fd.Name.Name, j, typ, ra.DecorCallIn[j])
+			}
+			// 只有可变参数需要展开
+			if variadic := fd.Name.Name == "target" && j == len(c.in)-1; strings.HasSuffix(ra.DecorCallIn[j], "...") != variadic {
+				t.Fatalf("builderReplaceArgs(%s) DecorCallIn[%d] expand %v, got %s\n", fd.Name.Name, j, variadic, ra.DecorCallIn[j])
+			}
+		}
+		if len(ra.InArgNames) != len(c.in) {
+			t.Fatalf("builderReplaceArgs(%s) should name every parameter, got %q\n", fd.Name.Name, ra.InArgNames)
+		}
+		for _, use := range []func(){func() {}, ra.useAssignableOut, func() { _ = ra.useTypedContext(len(c.in), len(c.out)) }} {
+			use()
+			rs, err := replace(ra)
+			if err != nil {
+				t.Fatal("replace() error", err)
+			}
+			if _, _, err := getStmtList(rs); err != nil {
+				t.Fatal("getStmtList() generated code with inline types should be valid, error", err, rs)
+			}
+		}
+	}
+}
+
+func TestReplaceChainValues(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", "package main\nfunc add(a, b int) int { return a + b }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := f.Decls[0].(*ast.FuncDecl)
+	gi := newGenIdentId()
+	ra := builderReplaceArgs(fd, "logging", nil, gi)
+	rs, err := replace(ra)
+	if err != nil || strings.Contains(rs, "Values:") {
+		t.Fatal("replace() of a single decorator should not share Values, got", err, rs)
+	}
+	ra.ChainVarName, ra.ChainLayer = "chain", 1
+	if rs, err = replace(ra); err != nil || !strings.Contains(rs, "Values:     chain.Values,") {
+		t.Fatal("replace() in a chain should share chain.Values, got", err, rs)
+	}
+	if err := ra.useTypedContext(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if rs, err = replace(ra); err != nil || strings.Contains(rs, "Values:") || !strings.Contains(rs, "Chain:      chain,") {
+		t.Fatal("replace() of a typed context in a chain should not set Values, got", err, rs)
+	}
+	if strings.Contains(rs, ".Enter(") || strings.Contains(rs, ".Exit(") {
+		t.Fatal("replace() in a chain should not time the layers unless ChainTimed, got", rs)
+	}
+	ra.ChainTimed = true
+	if rs, err = replace(ra); err != nil || !strings.Contains(rs, "chain.Enter(1)") || !strings.Contains(rs, ".Chain.Exit(1)") {
+		t.Fatal("replace() in a chain with ChainTimed should time the layer, got", err, rs)
+	}
+}
+
+func TestReplaceChainLayers(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", "package main\nfunc add(a, b int) int { return a + b }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := f.Decls[0].(*ast.FuncDecl)
+	gi := newGenIdentId()
+	ra := builderReplaceArgs(fd, "decor.Invoke", []string{
+		"func(c *decor.Context) { tracing(c) }",
+		`func(c *decor.Context) { logging(c, "info") }`,
+	}, gi)
+	ra.ChainLayers = 2
+	rs, err := replace(ra)
+	if err != nil || strings.Contains(rs, "Chain:") {
+		t.Fatal("replace() with ChainLayers should not create a ChainState unless ChainTimed, got", err, rs)
+	}
+	ra.ChainTimed = true
+	if rs, err = replace(ra); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Chain:      decor.NewChainState(2),",
+		`decor.Invoke(` + ra.DecorVarName + `, func(c *decor.Context) { tracing(c) }, func(c *decor.Context) { logging(c, "info") })`,
+	} {
+		if !strings.Contains(rs, want) {
+			t.Fatalf("replace() with ChainLayers should contain %q, got %s", want, rs)
+		}
+	}
+	if strings.Contains(rs, "Values:") || strings.Contains(rs, ".Enter(") || strings.Contains(rs, ".Exit(") {
+		t.Fatal("replace() with ChainLayers should leave Values and the layer timing to decor.Invoke, got", rs)
+	}
+	if _, _, err := getStmtList(rs); err != nil {
+		t.Fatal("getStmtList() generated code with ChainLayers should be valid, error", err, rs)
+	}
+}
+
+func TestParallelEach(t *testing.T) {
+	for _, n := range []int{0, 1, 3, 100} {
+		got := make([]int, n)
+		parallelEach(n, func(i int) {
+			got[i] = i * i
+		})
+		for i, v := range got {
+			if v != i*i {
+				t.Fatalf("parallelEach(%d) index %d want %d, but got %d", n, i, i*i, v)
+			}
+		}
+	}
+}
+
+func TestParserGOFiles(t *testing.T) {
+	files := writeGoFiles(t, t.TempDir(), 20, 3)
+	pkg, err := parserGOFiles(token.NewFileSet(), files...)
+	if err != nil {
+		t.Fatal("parserGOFiles() error", err)
+	}
+	if pkg.Name != "p" || len(pkg.Files) != len(files) {
+		t.Fatalf("parserGOFiles() want package p with %d files, but got %s with %d", len(files), pkg.Name, len(pkg.Files))
+	}
+	for _, file := range files {
+		if f := pkg.Files[file]; f == nil || len(f.Decls) != 4 {
+			t.Fatalf("parserGOFiles() file %s not parsed", file)
+		}
+	}
+
+	// 多个文件出错时，返回 files 中第一个出错的文件的错误
+	bad := func(name string) string {
+		file := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(file, []byte("package p\nfunc {"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	b1, b2 := bad("b1.go"), bad("b2.go")
+	_, err = parserGOFiles(token.NewFileSet(), append(append([]string{}, files...), b1, b2)...)
+	if err == nil || !strings.Contains(err.Error(), "b1.go") {
+		t.Fatalf("parserGOFiles() want error of b1.go, but got %v", err)
+	}
+}
+
+func TestParseGoDir(t *testing.T) {
+	dir := t.TempDir()
+	files := writeGoFiles(t, dir, 3, 1)
+	ext := filepath.Join(dir, "p_ext_test.go")
+	if err := os.WriteFile(ext, []byte("package p_test\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("package x\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := parseGoDir(token.NewFileSet(), dir, nil)
+	if err != nil {
+		t.Fatal("parseGoDir() error", err)
+	}
+	if len(pkgs) != 2 || len(pkgs["p"].Files) != len(files) || pkgs["p_test"].Files[ext] == nil {
+		t.Fatalf("parseGoDir() want packages p with %d files and p_test, but got %v", len(files), pkgs)
+	}
+
+	// filter 返回 false 的文件不解析
+	pkgs, err = parseGoDir(token.NewFileSet(), dir, func(info fs.FileInfo) bool { return info.Name() != filepath.Base(ext) })
+	if err != nil {
+		t.Fatal("parseGoDir() error", err)
+	}
+	if len(pkgs) != 1 || pkgs["p"] == nil {
+		t.Fatalf("parseGoDir() with filter want package p only, but got %v", pkgs)
+	}
+}
+
+func BenchmarkParserGOFiles(b *testing.B) {
+	files := writeGoFiles(b, b.TempDir(), 50, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parserGOFiles(token.NewFileSet(), files...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 在 dir 中写入 n 个属于包 p 的源文件，每个文件有 funcs 个被装饰的函数，返回文件路径
+func writeGoFiles(tb testing.TB, dir string, n, funcs int) []string {
+	files := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		var sb strings.Builder
+		sb.WriteString("package p\n\nimport \"github.com/dengsgo/go-decorator/decor\"\n")
+		for j := 0; j < funcs; j++ {
+			fmt.Fprintf(&sb, "\n//go:decor logging\nfunc f%d_%d(a, b int) (int, error) {\n\tif a > b {\n\t\treturn a - b, nil\n\t}\n\treturn b - a, nil\n}\n", i, j)
+		}
+		file := filepath.Join(dir, fmt.Sprintf("f%d.go", i))
+		if err := os.WriteFile(file, []byte(sb.String()), 0666); err != nil {
+			tb.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func TestHoistRecover(t *testing.T) {
+	cas := []struct {
+		body  string
+		calls int  // 被替换的 recover() 个数
+		err   bool // 是否不能在装饰后的函数开头调用 recover()
+	}{
+		{"x := 1; _ = x", 0, false},
+		{"r := recover(); _ = r", 1, false},
+		{"if r := recover(); r != nil { println(r) }", 1, false},
+		{"var a, b = recover(), recover(); _, _ = a, b", 2, false},
+		{"switch recover() { case nil: }", 1, false},
+		{"defer func() { recover() }()", 0, false},
+		{"recover := func() any { return nil }; _ = recover()", 0, false},
+		{"if true { recover() }", 0, true},
+		{"x := 1; _ = recover(); _ = x", 0, true},
+		{"_ = recover(); _ = recover()", 0, true},
+		{"_ = x != nil && recover() != nil", 0, true},
+	}
+	for i, c := range cas {
+		f, err := parser.ParseFile(token.NewFileSet(), "main.go", "package main\nfunc target() {"+c.body+"}\n", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd := f.Decls[0].(*ast.FuncDecl)
+		recovers, err := directRecoverCalls(fd, nil)
+		if (err != nil) != c.err {
+			t.Fatalf("cas[%d] directRecoverCalls() want error %v, but got %v", i, c.err, err)
+		}
+		rv, take := hoistRecover(recovers, newGenIdentId())
+		if (rv == "") != (c.calls == 0) || (take == "") != (c.calls == 0) {
+			t.Fatalf("cas[%d] hoistRecover() got %q %q", i, rv, take)
+		}
+		calls := 0
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && take != "" && ident.Name == take {
+				calls++
+			}
+			return true
+		})
+		if calls != c.calls {
+			t.Fatalf("cas[%d] hoistRecover() should replace %d calls, but got %d", i, c.calls, calls)
+		}
+		if rv == "" {
+			continue
+		}
+		head, tail, err := recoverStmts(rv, take)
+		if err != nil || len(head) != 2 || len(tail) != 1 {
+			t.Fatalf("cas[%d] recoverStmts() got %d %d %v", i, len(head), len(tail), err)
+		}
+	}
+
+	// 包中声明的 recover 不是内置函数
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", "package main\nfunc target() { if true { recover() } }\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovers, err := directRecoverCalls(f.Decls[0].(*ast.FuncDecl), map[string]bool{"recover": true}); recovers != nil || err != nil {
+		t.Fatal("directRecoverCalls() should ignore a package-level recover, but got", recovers, err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// lint 子命令：不编译，只检查模块中所有 //go:decor 注释的用法，便于在 CI 中快速校验。
+//
+//	decorator lint [packages]
+//
+// 检查和 compile 共用改写的过程（包括 //go:decor-all 和 decor.toml 添加的装饰注释、值为函数的包级变量上的装饰注释；注释解析、重复装饰、priority/when 参数、目标函数的 lint 注释、
+// 装饰器的签名和参数绑定、decor-pure 、-d.extlint 时的 external lint 命令等），但不会在第一个出错的包处停止，而是输出所有包中的错误，
+// 每行的格式为 file:line:col: message 。存在错误时以非 0 状态码退出。使用了 //go:decor-deprecated 的装饰器是警告，
+// 不影响退出状态，-d.strict 时为错误。
+
+// 一个装饰器用法错误或警告
+type lintIssue struct {
+	pos       token.Position
+	msg       string
+	decorator string // 相关的装饰器名称，可以为空
+	warning   bool   // 是否只是警告
+}
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	issues, err := lintPackages(patterns)
+	if err != nil {
+		return err
+	}
+	if cmdFlag.ErrJSON {
+		if err := writeDiagnostics(os.Stdout, issues); err != nil {
+			return err
+		}
+	} else {
+		printLintIssues(os.Stdout, issues, projectDir)
+	}
+	errs := 0
+	for _, issue := range issues {
+		if !issue.warning {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("found %d decorator issue(s)", errs)
+	}
+	return nil
+}
+
+// 检查所有匹配 patterns 的包，返回按位置排序的错误
+func lintPackages(patterns []string) ([]lintIssue, error) {
+	pkgs, err := listPackages(patterns)
+	if err != nil {
+		return nil, err
+	}
+	// 和 diff 一样，装饰器的包路径为空时表示当前包
+	workDir, prefix := projectDir, decorPrefix
+	defer func() {
+		projectDir = workDir
+		pkgILoader.resetCurrentPkg()
+		setDecorPrefix(prefix)
+	}()
+	var issues []lintIssue
+	for _, pi := range pkgs {
+		projectDir = pi.Dir
+		pkgILoader.resetCurrentPkg()
+		// 注解的前缀可能由包所在模块的 decor.toml 设置
+		if err := useDecorPrefix(pi.Dir); err != nil {
+			return nil, err
+		}
+		// 测试文件和 go test 编译时一样检查
+		for _, group := range packageFileGroups(pi) {
+			fset := token.NewFileSet()
+			pkg, err := parserGOFiles(fset, group.files...)
+			if err != nil {
+				return nil, err
+			}
+			pkgIssues, err := lintPackage(fset, pkg, group.packageName)
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, pkgIssues...)
+		}
+	}
+	sortLintIssues(issues)
+	return issues, nil
+}
+
+// 按位置排序
+func sortLintIssues(issues []lintIssue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i].pos, issues[j].pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// 检查包 pkg 中所有的装饰器用法，pkgPath 和 compile 的 -p 一致。检查和 compile 共用改写的过程，见 rewritePackage 。
+// decor.toml 无法解析时返回错误
+func lintPackage(fset *token.FileSet, pkg *astPackage, pkgPath string) ([]lintIssue, error) {
+	diags := &packageDiagnostics{fset: fset, lint: true}
+	if _, err := rewritePackage(diags, pkg, pkgPath, ""); err != nil {
+		return nil, err
+	}
+	return diags.issues, nil
+}
+
+// 按 file:line:col: message 的格式输出，警告的 message 以 warning: 开头，文件路径尽量使用相对 workDir 的路径
+func printLintIssues(w io.Writer, issues []lintIssue, workDir string) {
+	for _, issue := range issues {
+		pos := issue.pos
+		if rel, err := filepath.Rel(workDir, pos.Filename); err == nil {
+			pos.Filename = rel
+		}
+		msg := issue.msg
+		if issue.warning {
+			msg = "warning: " + msg
+		}
+		fmt.Fprintf(w, "%s: %s\n", pos, msg)
+	}
+}
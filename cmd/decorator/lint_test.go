@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintPackage(t *testing.T) {
+	src := `package p
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+)
+
+//go:decor d.tagging#{names: {"a"}, ports: {80}}
+func ok() {}
+
+//go:decor d.tagging#{names: {"x"}, ports: {80}}
+func notInEnum() {}
+
+//go:decor d.notExist
+//go:decor d.tagging#{names: {"a"}, ports: {80}, priority: "x"}
+func twoIssues() {}
+
+//go:decor d.tagging#{names: {"a"}, ports: {80}, when: "prod &&"}
+func badWhen() {}
+
+//go:decor d.tagging#{names: {"a"}, ports: {80}}
+//go:decor d.tagging#{names: {"a"}, ports: {80}}
+func repeated() {}
+
+//go:decor x.logging
+func notImported() {}
+
+func logging(ctx *decor.Context) {}
+
+//go:decor-all ^viaAll$ d.tagging#{names: {"y"}, ports: {80}}
+
+func viaAll() {}
+
+//go:decor d.typedDecor
+func typedOk(a int) string { return "" }
+
+//go:decor d.typedDecor
+func typedArity(a, b int) {}
+
+//go:decor d.tagging#{names: {"a"}, ports: {80}}
+func init() {}
+
+//go:decor d.tagging#{names: {"a"}, ports: {80}, allowMain: true}
+func init() {}
+
+//go:decor d.tagging#{names: {"a"}, port: {80}}
+func typo() {}
+
+//go:decor d.tagging#{names: {"a"}, ports: {80}}
+var okValue = typo
+
+//go:decor d.tagging#{names: {"z"}, ports: {80}}
+var badValue func() = typo
+
+//go:decor d.typedDecor
+var typedValue = typedOk
+
+//go:decor d.oldLogging#{level: "info"}
+func deprecatedUse() {}
+
+//go:nosplit
+//go:decor d.tagging#{names: {"a"}, ports: {80}}
+func nosplit() {}
+
+//go:noinline
+//go:decor d.tagging#{names: {"a"}, ports: {80}}
+func noinline() {}
+
+//go:linkname linked
+//go:decor d.tagging#{names: {"a"}, ports: {80}}
+func linked() {}
+`
+	file := filepath.Join(t.TempDir(), "p.go")
+	if err := os.WriteFile(file, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	pkg, err := parserGOFiles(fset, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := lintPackage(fset, pkg, "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := map[int][]string{}
+	for _, issue := range issues {
+		lines[issue.pos.Line] = append(lines[issue.pos.Line], issue.msg)
+	}
+	cas := []struct {
+		line int
+		want string
+	}{
+		{11, "can't pass lint enum"},
+		{14, "notExist"},
+		{15, "priority must be an integer"},
+		{18, "not a valid tag expression"},
+		{21, "repeated decoration"},
+		{25, "x is neither an imported package nor a package-level variable"},
+		{32, "can't pass lint enum"},
+		{37, "decorator takes decor.Context1In1Out, but the target has 2 parameters and 0 results"},
+		{40, "opt in with the allowMain parameter"},
+		{46, "has no parameter 'port', did you mean 'ports'?"},
+		{52, "can't pass lint enum"},
+		{55, "typed context cannot decorate a function value"},
+		{58, "decorator d.oldLogging is deprecated: use leveled instead"},
+		{61, "//go:nosplit cannot be used on a decorated function"},
+		{71, "decorated function is referenced by //go:linkname"},
+	}
+	for i, c := range cas {
+		found := false
+		for _, msg := range lines[c.line] {
+			found = found || strings.Contains(msg, c.want)
+		}
+		if !found {
+			t.Fatalf("cas[%d] line %d should report %q, but got %q", i, c.line, c.want, lines[c.line])
+		}
+	}
+	if len(issues) != len(cas) {
+		t.Fatalf("lintPackage() should report %d issues, but got %+v", len(cas), issues)
+	}
+	// 使用已废弃的装饰器、装饰 //go:linkname 引用的函数只是警告
+	for _, issue := range issues {
+		if issue.warning != (issue.pos.Line == 58 || issue.pos.Line == 71) {
+			t.Fatalf("lintPackage() issue %s: %s warning should be %v", issue.pos, issue.msg, !issue.warning)
+		}
+	}
+}
+
+func TestLintPackages(t *testing.T) {
+	issues, err := lintPackages([]string{"github.com/dengsgo/go-decorator/example/usages"})
+	if err != nil {
+		t.Fatal("lintPackages() error", err)
+	}
+	var out bytes.Buffer
+	printLintIssues(&out, issues, projectDir)
+	if len(issues) != 0 {
+		t.Fatalf("lintPackages() should report no issues, but got:\n%s", out.String())
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	issues := []lintIssue{
+		{pos: token.Position{Filename: "/p/a.go", Line: 12, Column: 1}, msg: "decorator priority must be an integer, but got high", decorator: "logging"},
+		{pos: token.Position{Filename: "/p/b.go", Line: 4, Column: 1}, msg: "<decor> & main", warning: true},
+	}
+	var out bytes.Buffer
+	if err := writeDiagnostics(&out, issues); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"severity":"error","message":"decorator priority must be an integer, but got high","file":"/p/a.go","line":12,"column":1,"decorator":"logging"}
+{"severity":"warning","message":"<decor> & main","file":"/p/b.go","line":4,"column":1}
+`
+	if out.String() != want {
+		t.Fatalf("writeDiagnostics() got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
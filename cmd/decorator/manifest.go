@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// manifest 子命令：在每个包的目录中生成 zz_generated_decorators.go ，用包级变量 DecoratedFunctions 列出
+// 包中被装饰的函数（方法）和它们的装饰器，go doc 和其他工具不用读注释就能知道哪些函数被装饰了：
+//
+//	decorator manifest [packages]
+//
+// packages 的写法和 go list 一致，默认为 ./... ，也可以在包中用 //go:generate decorator manifest . 生成。
+// 和 report 一样先展开类型、//go:decor-all 、decor.toml 上的装饰器和别名。生成的文件：
+//
+//	// Code generated by decorator manifest. DO NOT EDIT.
+//
+//	package main
+//
+//	// DecoratedFunctions lists ...
+//	var DecoratedFunctions = map[string][]string{
+//		"(*T).Name": {"logging"},
+//		"datetime":  {"logging", "appendFile"},
+//	}
+//
+// 内容没有变化时不会重写文件，包中不再有被装饰的函数时删除之前生成的文件。
+// _test.go 中的目标不会写入，外部测试包（package x_test）不生成。包中的其他文件已经声明了
+// DecoratedFunctions 时报错。
+
+const manifestFileName = "zz_generated_decorators.go"
+
+const manifestHeader = "// Code generated by decorator manifest. DO NOT EDIT."
+
+// 清单中列出被装饰的函数的包级变量
+const manifestVarName = "DecoratedFunctions"
+
+func runManifest(args []string) error {
+	fs := flag.NewFlagSet("manifest", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	return visitPackages(patterns, func(pi *_packageInfo, fset *token.FileSet, pkg *astPackage) error {
+		// 之前生成的清单不参与统计
+		for file := range pkg.Files {
+			if filepath.Base(file) == manifestFileName {
+				delete(pkg.Files, file)
+			}
+		}
+		if declaresManifestVar(pkg) {
+			return fmt.Errorf("%s: %s is already declared in package %s", pi.Dir, manifestVarName, pkg.Name)
+		}
+		rp, err := reportPackageFuncs(fset, pkg, pi.ImportPath, pi.Dir, nil)
+		if err != nil {
+			return err
+		}
+		return writeManifest(pi.Dir, pkg.Name, manifestTargets(rp))
+	})
+}
+
+// 包中是否已经声明了包级的 DecoratedFunctions
+func declaresManifestVar(pkg *astPackage) bool {
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Name.Name == manifestVarName {
+					return true
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							if name.Name == manifestVarName {
+								return true
+							}
+						}
+					case *ast.TypeSpec:
+						if spec.Name.Name == manifestVarName {
+							return true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// 包中被装饰的目标，以不带包名的函数名为键（如 datetime 、(*T).Name ），值为从外到内的装饰器。
+func manifestTargets(rp *reportPackage) map[string][]string {
+	m := make(map[string][]string, len(rp.Decorated))
+	for _, fn := range rp.Decorated {
+		m[fn.Name] = fn.Decorators
+	}
+	return m
+}
+
+// 生成 zz_generated_decorators.go 的内容，pkgName 为包名
+func manifestSource(pkgName string, targets map[string][]string) ([]byte, error) {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n\npackage %s\n\n", manifestHeader, pkgName)
+	fmt.Fprintf(&buf, "// %s lists the functions and methods of this package that are\n", manifestVarName)
+	buf.WriteString("// decorated by decorator, and their decorators from the outermost to the innermost.\n")
+	fmt.Fprintf(&buf, "var %s = map[string][]string{\n", manifestVarName)
+	for _, name := range names {
+		decors := make([]string, 0, len(targets[name]))
+		for _, d := range targets[name] {
+			decors = append(decors, strconv.Quote(d))
+		}
+		fmt.Fprintf(&buf, "%s: {%s},\n", strconv.Quote(name), strings.Join(decors, ", "))
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+// 在包目录 dir 中写入或删除 zz_generated_decorators.go
+func writeManifest(dir, pkgName string, targets map[string][]string) error {
+	file := filepath.Join(dir, manifestFileName)
+	old, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// 同名的文件不是 decorator 生成的，不覆盖也不删除
+	if err == nil && !bytes.HasPrefix(old, []byte("// Code generated by decorator ")) {
+		return fmt.Errorf("%s exists and is not generated by decorator", file)
+	}
+	if len(targets) == 0 {
+		if old == nil {
+			return nil
+		}
+		return os.Remove(file)
+	}
+	src, err := manifestSource(pkgName, targets)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(src, old) {
+		return nil
+	}
+	// 先写临时文件再重命名，避免 go 命令读到写了一半的文件
+	tmp, err := os.CreateTemp(dir, ".zz_generated_decorators_*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(src); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	_ = os.Chmod(tmp.Name(), 0644)
+	return os.Rename(tmp.Name(), file)
+}
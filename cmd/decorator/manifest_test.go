@@ -0,0 +1,104 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestTargets(t *testing.T) {
+	m := manifestTargets(&reportPackage{Decorated: []reportFunc{
+		{Name: "datetime", Decorators: []string{"logging", "timing"}},
+		{Name: "(*T).Name", Decorators: []string{"logging"}},
+	}})
+	if len(m) != 2 || strings.Join(m["datetime"], ",") != "logging,timing" || len(m["(*T).Name"]) != 1 {
+		t.Fatal("manifestTargets() not match, got", m)
+	}
+}
+
+func TestDeclaresManifestVar(t *testing.T) {
+	for src, want := range map[string]bool{
+		"package a\nvar DecoratedFunctions = 1\n":           true,
+		"package a\nvar x, DecoratedFunctions int\n":        true,
+		"package a\ntype DecoratedFunctions struct{}\n":     true,
+		"package a\nfunc DecoratedFunctions() {}\n":         true,
+		"package a\nfunc (T) DecoratedFunctions() {}\n":     false,
+		"package a\nfunc f() { DecoratedFunctions := 1 }\n": false,
+	} {
+		f, err := parser.ParseFile(token.NewFileSet(), "a.go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := declaresManifestVar(&astPackage{Files: map[string]*ast.File{"a.go": f}}); got != want {
+			t.Fatalf("declaresManifestVar(%q) want %v, got %v", src, want, got)
+		}
+	}
+}
+
+func TestManifestSource(t *testing.T) {
+	src, err := manifestSource("a", map[string][]string{
+		"datetime":  {"logging", "timing"},
+		"(*T).Name": {"logging"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(src)
+	if !strings.HasPrefix(s, manifestHeader+"\n\npackage a\n") ||
+		!strings.Contains(s, `"(*T).Name": {"logging"},`) ||
+		!strings.Contains(s, `"datetime":  {"logging", "timing"},`) ||
+		strings.Index(s, "(*T).Name") > strings.Index(s, "datetime") {
+		t.Fatal("manifestSource() not match, got", s)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), manifestFileName, src, 0); err != nil {
+		t.Fatal("manifestSource() should be valid Go, error", err)
+	}
+}
+
+func TestWriteManifest(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, manifestFileName)
+	targets := map[string][]string{"datetime": {"logging"}}
+	if err := writeManifest(dir, "a", targets); err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.ReadFile(file)
+	if err != nil || !strings.Contains(string(src), `"datetime": {"logging"},`) {
+		t.Fatal("writeManifest() should write the manifest, got", string(src), err)
+	}
+
+	// unchanged content is not rewritten
+	old := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(file, old, old)
+	if err := writeManifest(dir, "a", targets); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(file); !info.ModTime().Equal(old) {
+		t.Fatal("writeManifest() should not rewrite an unchanged manifest")
+	}
+
+	// no decorated functions, the manifest is removed
+	if err := writeManifest(dir, "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatal("writeManifest() should remove the manifest without targets, got", err)
+	}
+	if err := writeManifest(dir, "a", nil); err != nil {
+		t.Fatal("writeManifest() without targets and manifest should do nothing, got", err)
+	}
+
+	// a file of the same name not generated by decorator is kept
+	_ = os.WriteFile(file, []byte("package a\n"), 0644)
+	if err := writeManifest(dir, "a", targets); err == nil {
+		t.Fatal("writeManifest() should not overwrite a file not generated by decorator")
+	}
+	if src, _ := os.ReadFile(file); string(src) != "package a\n" {
+		t.Fatal("writeManifest() should keep a file not generated by decorator, got", string(src))
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 改写后的文件默认由 go/printer 重新打印整个语法树（见 buildline.go），原文件的格式会被 printer 规范化。
+// -d.patch 时改为修补原文件的文本：生成的代码按字节偏移插入原文件中，其余的文本，包括格式、注释和
+// printer 无法原样输出的写法都保持不变，改写前后的差异只有生成的代码。
+//
+// 修补由解析之后、改写之前的快照（patchSnapshot）和改写后的语法树的差异得到：
+//   - 函数体的语句列表被替换时，新的语句列表在原来的语句之前和之后的部分分别打印，插入在 { 之后和 } 之前，
+//     原来的语句仍然是原文件中的文本
+//   - 包级变量的值被包装时同样只插入包装的前后两部分
+//   - 原来的标识符被改名（如名为 _ 的接收者、直接调用的 recover()）时替换标识符，未命名的参数和返回值的新名字插入在类型之前
+//   - 导入的别名变化时修改别名，新增的导入以 ; import 的形式追加在最后一个导入声明之后
+//   - 新增的声明（如注册被装饰函数的 init 函数）追加在文件末尾
+//
+// 每段插入的代码之后都有 /*line file:line:col*/ 指令，之后的文本的位置和原文件完全相同。
+// 无法得到修补时（如原来的语句在新的语法树中找不到）回退为重新打印整个文件。
+
+// 打印时代替原来的语句或表达式的标识符，打印后在它的位置插入原文本
+const patchPlaceholder = "_decorPatchPlaceholder_"
+
+// 文件在改写之前的快照，节点以指针比较
+type patchSnapshot struct {
+	src     []byte
+	decls   map[ast.Decl]bool
+	imports map[*ast.ImportSpec]*ast.Ident // 原来的别名，没有时为 nil
+	bodies  []patchBody
+	values  map[*ast.ValueSpec]ast.Expr
+}
+
+// 函数声明或赋值给包级变量的函数字面量的函数体和签名
+type patchBody struct {
+	block *ast.BlockStmt
+	list  []ast.Stmt
+	recv  *ast.FieldList
+	typ   *ast.FuncType
+}
+
+// 当前编译的包中每个文件的快照，以文件路径为键，只在 -d.patch 时记录
+var patchSnapshots = map[string]*patchSnapshot{}
+
+// 记录包 pkg 中每个文件在改写之前的快照
+func snapshotPackage(pkg *astPackage) (map[string]*patchSnapshot, error) {
+	snaps := make(map[string]*patchSnapshot, len(pkg.Files))
+	for file, f := range pkg.Files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		snaps[file] = snapshotFile(f, src)
+	}
+	return snaps, nil
+}
+
+// 记录文件 f 在改写之前的快照，src 为它的源码
+func snapshotFile(f *ast.File, src []byte) *patchSnapshot {
+	snap := &patchSnapshot{
+		src:     src,
+		decls:   map[ast.Decl]bool{},
+		imports: map[*ast.ImportSpec]*ast.Ident{},
+		values:  map[*ast.ValueSpec]ast.Expr{},
+	}
+	for _, spec := range f.Imports {
+		snap.imports[spec] = spec.Name
+	}
+	for _, decl := range f.Decls {
+		snap.decls[decl] = true
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Body != nil {
+				snap.bodies = append(snap.bodies, patchBody{decl.Body, decl.Body.List, decl.Recv, decl.Type})
+			}
+		case *ast.GenDecl:
+			if decl.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				vs, ok := spec.(*ast.ValueSpec)
+				if !ok || len(vs.Values) != 1 {
+					continue
+				}
+				snap.values[vs] = vs.Values[0]
+				if lit, ok := vs.Values[0].(*ast.FuncLit); ok {
+					snap.bodies = append(snap.bodies, patchBody{lit.Body, lit.Body.List, nil, lit.Type})
+				}
+			}
+		}
+	}
+	return snap
+}
+
+// 对原文件的一处修改，start == end 时为插入
+type patchEdit struct {
+	start, end int
+	text       string
+}
+
+// 修补一个文件
+type filePatcher struct {
+	fset   *token.FileSet
+	tf     *token.File
+	src    []byte
+	adjust bool // 文件中有 //line 指令，打印的代码中的指令需要换算，见 adjustLineDirectives
+	edits  []patchEdit
+}
+
+// 按快照 snap 修补改写后的文件 f ，写入 w
+func patchRewrittenFile(w io.Writer, fset *token.FileSet, f *ast.File, snap *patchSnapshot) error {
+	tf := fset.File(f.Pos())
+	if snap == nil || tf == nil || tf.Size() != len(snap.src) {
+		return errors.New("no snapshot of the original file")
+	}
+	p := &filePatcher{fset: fset, tf: tf, src: snap.src, adjust: hasLineDirectives(f)}
+	// 和 printer 一样以 //line 指令开始，source map 从第一行起就有对应的位置
+	p.edits = append(p.edits, patchEdit{0, 0, "//line " + tf.Name() + ":1:1\n"})
+	p.patchImports(f, snap)
+	for _, b := range snap.bodies {
+		if err := p.patchBody(b); err != nil {
+			return err
+		}
+	}
+	for vs, orig := range snap.values {
+		if err := p.patchValue(vs, orig); err != nil {
+			return err
+		}
+	}
+	if err := p.patchDecls(f, snap); err != nil {
+		return err
+	}
+	return p.write(w)
+}
+
+// 修改导入的别名，追加新增的导入
+func (p *filePatcher) patchImports(f *ast.File, snap *patchSnapshot) {
+	var added []string
+	for _, spec := range f.Imports {
+		orig, ok := snap.imports[spec]
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if !ok {
+			added = append(added, strings.TrimSpace("import "+name+" "+spec.Path.Value))
+			continue
+		}
+		if orig == nil {
+			if name != "" {
+				p.insert(spec.Path.Pos(), name+" "+p.anchor(spec.Path.Pos()))
+			}
+			continue
+		}
+		start := p.tf.Offset(orig.Pos())
+		end := start + len(identAt(p.src, start))
+		if string(p.src[start:end]) != name {
+			p.edits = append(p.edits, patchEdit{start, end, name + p.anchor(p.tf.Pos(end))})
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	pos := f.Name.End()
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT && snap.decls[gd] {
+			pos = gd.End()
+		}
+	}
+	p.insert(pos, "; "+strings.Join(added, "; ")+p.anchor(pos))
+}
+
+// 函数体的语句列表被替换时，插入生成的代码，原来的语句保持原样
+func (p *filePatcher) patchBody(b patchBody) error {
+	if sameStmts(b.block.List, b.list) {
+		return nil
+	}
+	p.patchFields(b.recv, false)
+	p.patchFields(b.typ.Params, false)
+	p.patchFields(b.typ.Results, true)
+
+	lbrace, rbrace := b.block.Lbrace+1, b.block.Rbrace
+	if len(b.list) == 0 {
+		// 原来的函数体中没有语句，只有空白和注释
+		text, err := p.print(b.block.List)
+		if err != nil {
+			return err
+		}
+		p.insert(lbrace, "\n"+text+"\n"+p.anchor(lbrace))
+		return nil
+	}
+	holder := findStmtList(b.block.List, b.list)
+	if holder == nil {
+		return fmt.Errorf("%s: original statements not found in the rewritten function", p.fset.Position(b.block.Lbrace))
+	}
+	holder.List = []ast.Stmt{&ast.ExprStmt{X: ast.NewIdent(patchPlaceholder)}}
+	text, err := p.print(b.block.List)
+	holder.List = b.list
+	if err != nil {
+		return err
+	}
+	before, after, ok := splitPlaceholder(text)
+	if !ok {
+		return fmt.Errorf("%s: fail print the rewritten function", p.fset.Position(b.block.Lbrace))
+	}
+	p.insert(lbrace, "\n"+before+p.anchor(lbrace))
+	for _, stmt := range b.list {
+		p.patchIdents(stmt)
+	}
+	p.insert(rbrace, after+"\n"+p.anchor(rbrace))
+	return nil
+}
+
+// 包级变量的值被包装时，插入包装的前后两部分，原来的值保持原样
+func (p *filePatcher) patchValue(vs *ast.ValueSpec, orig ast.Expr) error {
+	if len(vs.Values) != 1 || vs.Values[0] == orig {
+		return nil
+	}
+	holder := findCallArg(vs.Values[0], orig)
+	if holder == nil {
+		return fmt.Errorf("%s: original value not found in the rewritten variable", p.fset.Position(vs.Pos()))
+	}
+	i := 0
+	for holder.Args[i] != orig {
+		i++
+	}
+	holder.Args[i] = ast.NewIdent(patchPlaceholder)
+	text, err := p.print(vs.Values[0])
+	holder.Args[i] = orig
+	if err != nil {
+		return err
+	}
+	before, after, ok := splitPlaceholder(text)
+	if !ok {
+		return fmt.Errorf("%s: fail print the rewritten variable", p.fset.Position(vs.Pos()))
+	}
+	p.insert(orig.Pos(), "\n"+before+p.anchor(orig.Pos()))
+	p.insert(orig.End(), after+p.anchor(orig.End()))
+	return nil
+}
+
+// 新增的声明追加在文件末尾，新增的导入声明由 patchImports 处理
+func (p *filePatcher) patchDecls(f *ast.File, snap *patchSnapshot) error {
+	var sb strings.Builder
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); snap.decls[decl] || (ok && gd.Tok == token.IMPORT) {
+			continue
+		}
+		text, err := p.print(decl)
+		if err != nil {
+			return err
+		}
+		sb.WriteString("\n\n" + text)
+	}
+	if sb.Len() > 0 {
+		p.edits = append(p.edits, patchEdit{len(p.src), len(p.src), "\n" + sb.String() + "\n"})
+	}
+	return nil
+}
+
+// 为参数、返回值或接收者列表 fl 中新增的名字插入名字，改名的名字替换为新的名字
+func (p *filePatcher) patchFields(fl *ast.FieldList, results bool) {
+	if fl == nil {
+		return
+	}
+	for _, field := range fl.List {
+		if len(field.Names) == 0 || field.Names[0].Pos().IsValid() {
+			for _, ident := range field.Names {
+				p.patchIdent(ident)
+			}
+			continue
+		}
+		names := make([]string, 0, len(field.Names))
+		for _, ident := range field.Names {
+			names = append(names, ident.Name)
+		}
+		text := strings.Join(names, ", ") + " "
+		// func f() int 只有一个未命名的返回值时没有括号
+		if results && !fl.Opening.IsValid() {
+			p.insert(field.Type.Pos(), "("+text+p.anchor(field.Type.Pos()))
+			p.insert(field.Type.End(), ")"+p.anchor(field.Type.End()))
+			continue
+		}
+		p.insert(field.Type.Pos(), text+p.anchor(field.Type.Pos()))
+	}
+}
+
+// 替换 n 中被改名的标识符
+func (p *filePatcher) patchIdents(n ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			p.patchIdent(ident)
+		}
+		return true
+	})
+}
+
+// 标识符 ident 被改名时替换为新的名字
+func (p *filePatcher) patchIdent(ident *ast.Ident) {
+	if !p.inFile(ident.Pos()) {
+		return
+	}
+	start := p.tf.Offset(ident.Pos())
+	end := start + len(identAt(p.src, start))
+	if string(p.src[start:end]) == ident.Name {
+		return
+	}
+	text := ident.Name
+	if end-start != len(text) {
+		text += p.anchor(p.tf.Pos(end))
+	}
+	p.edits = append(p.edits, patchEdit{start, end, text})
+}
+
+// 在 pos 处插入 text
+func (p *filePatcher) insert(pos token.Pos, text string) {
+	off := p.tf.Offset(pos)
+	p.edits = append(p.edits, patchEdit{off, off, text})
+}
+
+// 使之后的文本的位置回到原文件中 pos 的位置的指令
+func (p *filePatcher) anchor(pos token.Pos) string {
+	position := p.fset.PositionFor(pos, true)
+	if position.Column == 0 {
+		return fmt.Sprintf("/*line %s:%d*/", position.Filename, position.Line)
+	}
+	return fmt.Sprintf("/*line %s:%d:%d*/", position.Filename, position.Line, position.Column)
+}
+
+// 打印生成的代码，和重新打印整个文件时一样输出 //line 指令
+func (p *filePatcher) print(node any) (string, error) {
+	var buf bytes.Buffer
+	if err := printerCfg.Fprint(&buf, p.fset, node); err != nil {
+		return "", err
+	}
+	out := buf.Bytes()
+	if p.adjust {
+		out = adjustLineDirectives(out, p.fset, p.tf)
+	}
+	// 单独打印没有位置信息的节点（如新增的 init 函数）时，printer 会输出没有文件名的 //line :1 ，去掉它们
+	lines := bytes.Split(out, []byte("\n"))
+	kept := lines[:0]
+	for _, line := range lines {
+		if !bytes.HasPrefix(line, []byte("//line :")) {
+			kept = append(kept, line)
+		}
+	}
+	return string(bytes.Join(kept, []byte("\n"))), nil
+}
+
+func (p *filePatcher) inFile(pos token.Pos) bool {
+	return pos.IsValid() && int(pos) >= p.tf.Base() && int(pos) <= p.tf.Base()+p.tf.Size()
+}
+
+// 按偏移依次应用所有的修改，写入 w 。相同偏移的插入保持添加的顺序。
+func (p *filePatcher) write(w io.Writer) error {
+	sort.SliceStable(p.edits, func(i, j int) bool {
+		return p.edits[i].start < p.edits[j].start
+	})
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range p.edits {
+		if e.start < last {
+			return fmt.Errorf("%s: overlapping patches", p.fset.Position(p.tf.Pos(e.start)))
+		}
+		buf.Write(p.src[last:e.start])
+		buf.WriteString(e.text)
+		last = e.end
+	}
+	buf.Write(p.src[last:])
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// 两个语句列表的元素是否完全相同
+func sameStmts(a, b []ast.Stmt) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 在 stmts 中查找语句列表为 list 的块
+func findStmtList(stmts []ast.Stmt, list []ast.Stmt) (holder *ast.BlockStmt) {
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if b, ok := n.(*ast.BlockStmt); ok && holder == nil && sameStmts(b.List, list) {
+				holder = b
+			}
+			return holder == nil
+		})
+	}
+	return holder
+}
+
+// 在 expr 中查找参数包括 arg 的调用
+func findCallArg(expr ast.Expr, arg ast.Expr) (holder *ast.CallExpr) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if ce, ok := n.(*ast.CallExpr); ok && holder == nil {
+			for _, a := range ce.Args {
+				if a == arg {
+					holder = ce
+				}
+			}
+		}
+		return holder == nil
+	})
+	return holder
+}
+
+// 以占位符为界把打印的代码分为前后两部分
+func splitPlaceholder(text string) (before, after string, ok bool) {
+	i := strings.Index(text, patchPlaceholder)
+	if i < 0 || strings.Count(text, patchPlaceholder) != 1 {
+		return "", "", false
+	}
+	return text[:i], text[i+len(patchPlaceholder):], true
+}
+
+// src 中偏移 off 处的标识符
+func identAt(src []byte, off int) string {
+	end := off
+	for end < len(src) {
+		r, size := utf8.DecodeRune(src[end:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		end += size
+	}
+	return string(src[off:end])
+}
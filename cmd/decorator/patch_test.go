@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestPatchRewrittenFile(t *testing.T) {
+	src := `package main
+
+import (
+	_ "github.com/dengsgo/go-decorator/decor"
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+)
+
+type T struct{}
+
+//go:decor d.defaulted
+func kept(a,   b int) int {
+	x  :=  a + b // keep  the   spacing
+	/* and this */ return x
+}
+
+//go:decor d.defaulted
+func unnamed() (int, error) { return 1, nil }
+
+//go:decor d.defaulted
+func single() int {
+	return 2
+}
+
+//go:decor d.defaulted
+func (T) method(_ string) {}
+
+//go:decor d.defaulted
+func empty() {
+	// nothing here
+}
+
+//go:decor d.defaulted
+func deferred() (v any) {
+	v = recover()
+	return
+}
+
+//go:decor d.defaulted
+var lit = func(s string) string { return s }
+
+//go:decor d.defaulted
+var value func(string) string = lit
+
+func plain() {
+	y :=   3
+	_ = y
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "/src/a.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 改写之前记录原来的标识符的位置
+	origIdents := map[token.Position]string{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name != "_" && ident.Name != "recover" {
+			origIdents[fset.Position(ident.Pos())] = ident.Name
+		}
+		return true
+	})
+	snap := snapshotFile(f, []byte(src))
+	updated, err := decoratePackage(fset, &astPackage{Name: "main", Files: map[string]*ast.File{"/src/a.go": f}}, "main", "")
+	if err != nil || len(updated) != 1 {
+		t.Fatal("decoratePackage() error", updated, err)
+	}
+	var buf bytes.Buffer
+	if err := patchRewrittenFile(&buf, fset, f, snap); err != nil {
+		t.Fatal("patchRewrittenFile() error", err)
+	}
+	out := buf.String()
+
+	// 注释和原来的格式保持不变
+	for _, s := range []string{"x  :=  a + b // keep  the   spacing", "/* and this */ return x", "// nothing here", "y :=   3", "func kept(a,   b int)"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("patchRewrittenFile() lost %q:\n%s", s, out)
+		}
+	}
+	outFset := token.NewFileSet()
+	of, err := parser.ParseFile(outFset, "/tmp/a.go", out, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("patchRewrittenFile() output doesn't parse: %v\n%s", err, out)
+	}
+	// 按 //line 指令得到的位置，原来的每个标识符都在原来的位置
+	outIdents := map[token.Position]string{}
+	ast.Inspect(of, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			p := outFset.Position(ident.Pos())
+			p.Offset = 0
+			outIdents[p] = ident.Name
+		}
+		return true
+	})
+	for pos, name := range origIdents {
+		pos.Offset = 0
+		if outIdents[pos] != name {
+			t.Fatalf("patchRewrittenFile() %s at %s, but got %q:\n%s", name, pos, outIdents[pos], out)
+		}
+	}
+	for _, s := range []string{"\t/*line /src/a.go:4:3*/ \"github.com/dengsgo/go-decorator/decor\"", "recover()\n", "func init()"} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("patchRewrittenFile() should contain %q:\n%s", s, out)
+		}
+	}
+	// 目标中直接调用的 recover() 被改名
+	if strings.Contains(out, "v = recover()") {
+		t.Fatalf("patchRewrittenFile() should rename recover() in the target:\n%s", out)
+	}
+}
+
+func TestPatchRewrittenFileWithoutSnapshot(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", "package p\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := patchRewrittenFile(&bytes.Buffer{}, fset, f, nil); err == nil {
+		t.Fatal("patchRewrittenFile() should fail without a snapshot")
+	}
+	if err := patchRewrittenFile(&bytes.Buffer{}, fset, f, snapshotFile(f, []byte("package p\n\n"))); err == nil {
+		t.Fatal("patchRewrittenFile() should fail when the source changed")
+	}
+}
+
+func TestIdentAt(t *testing.T) {
+	src := []byte("a := recover() + _x1 + 变量2;")
+	cas := []struct {
+		off  int
+		want string
+	}{
+		{0, "a"},
+		{5, "recover"},
+		{12, ""},
+		{17, "_x1"},
+		{23, "变量2"},
+	}
+	for _, c := range cas {
+		if got := identAt(src, c.off); got != c.want {
+			t.Fatalf("identAt(%d) got %q, want %q", c.off, got, c.want)
+		}
+	}
+}
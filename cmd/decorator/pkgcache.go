@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dengsgo/go-decorator/cmd/logs"
+)
+
+// 装饰器所在的包的解析缓存。
+//
+// 每个 compile 进程都要解析装饰器所在的包，但查找装饰器、别名、绑定装饰器和 decor-pure 检查只会用到
+// 装饰器函数（方法）和包级变量。第一次完整解析包之后，把含有这些声明的文件记录在 tempDir/pkgcache 中，
+// 之后的 compile 进程只解析这些文件。缓存以包的目录和 go.mod 的内容为键，目录中 .go 文件的大小或修改时间
+// 变化时失效。-d.cache=off 时不使用缓存。
+
+const pkgCacheDirName = "pkgcache"
+
+type pkgCacheEntry struct {
+	Dir   string                  `json:"dir"`
+	Files map[string]pkgCacheStat `json:"files"` // 目录中所有的 .go 文件
+	Parse []string                `json:"parse"` // 需要解析的文件
+}
+
+type pkgCacheStat struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"modTime"`
+}
+
+func pkgCacheEnabled() bool {
+	return cmdFlag.Cache != "off"
+}
+
+// 包目录 dir 的缓存文件，goMod 为包所在模块的 go.mod ，可以为空
+func pkgCacheFile(dir, goMod string) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, version+"\x00"+dir+"\x00")
+	if b, err := os.ReadFile(goMod); err == nil {
+		_, _ = h.Write(b)
+	}
+	return filepath.Join(tempDir, pkgCacheDirName, hex.EncodeToString(h.Sum(nil))[:32]+".json")
+}
+
+// 目录 dir 中所有 .go 文件的大小和修改时间
+func statGoFiles(dir string) (map[string]pkgCacheStat, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]pkgCacheStat{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		stats[entry.Name()] = pkgCacheStat{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	}
+	return stats, nil
+}
+
+// 读取缓存，目录 dir 中的文件有变化时返回 false
+func readPkgCache(file, dir string) ([]string, bool) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	entry := &pkgCacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil || entry.Dir != dir {
+		return nil, false
+	}
+	stats, err := statGoFiles(dir)
+	if err != nil || len(stats) != len(entry.Files) {
+		return nil, false
+	}
+	for name, stat := range stats {
+		if entry.Files[name] != stat {
+			return nil, false
+		}
+	}
+	return entry.Parse, true
+}
+
+// 写入缓存。多个 compile 进程可能同时写入，先写临时文件再重命名
+func writePkgCache(file string, entry *pkgCacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	return writeFileAtomic(file, b)
+}
+
+// 解析目录 dir 中的包。useCache 为 true 时，缓存有效则只解析缓存中记录的文件（set.partial 为 true），
+// 否则完整解析并更新缓存。
+func parsePkgDir(dir, goMod string, useCache bool) (*pkgSet, error) {
+	set := &pkgSet{fset: token.NewFileSet(), dir: dir, goMod: goMod}
+	cacheFile := ""
+	var filter func(fs.FileInfo) bool
+	var stats map[string]pkgCacheStat
+	if useCache && pkgCacheEnabled() {
+		cacheFile = pkgCacheFile(dir, goMod)
+		if names, ok := readPkgCache(cacheFile, dir); ok {
+			parse := map[string]bool{}
+			for _, name := range names {
+				parse[name] = true
+			}
+			filter = func(info fs.FileInfo) bool { return parse[info.Name()] }
+			set.partial = true
+			logs.Debug("pkg cache hit", dir, names)
+		} else {
+			stats, _ = statGoFiles(dir)
+		}
+	}
+	var err error
+	set.pkgs, err = parseGoDir(set.fset, dir, filter)
+	if err != nil {
+		return nil, err
+	}
+	if cacheFile != "" && !set.partial && stats != nil {
+		entry := &pkgCacheEntry{Dir: dir, Files: stats, Parse: decorRelevantFiles(set.pkgs)}
+		if err := writePkgCache(cacheFile, entry); err != nil {
+			logs.Debug("write pkg cache fail", err)
+		}
+	}
+	return set, nil
+}
+
+// 包中含有装饰器函数（方法）或包级变量的文件名，按名称排序
+func decorRelevantFiles(pkgs map[string]*astPackage) []string {
+	names := []string{}
+	for _, pkg := range pkgs {
+		for file, f := range pkg.Files {
+			if fileHasDecorDecls(f) {
+				names = append(names, filepath.Base(file))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 文件中是否有包级变量，或第一个参数为 *decor.Context （或类型化的上下文）的函数（方法）
+func fileHasDecorDecls(f *ast.File) bool {
+	pkgDecorName, _ := newImporter(f).importedPath(decoratorPackagePath)
+	if pkgDecorName == "" || pkgDecorName == "_" {
+		pkgDecorName = "decor"
+	}
+	if f.Name.Name == "decor" {
+		pkgDecorName = "" // decor 包自身
+	}
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			if decl.Tok == token.VAR {
+				return true
+			}
+		case *ast.FuncDecl:
+			params := decl.Type.Params
+			if params == nil || len(params.List) == 0 {
+				continue
+			}
+			var buf strings.Builder
+			if printer.Fprint(&buf, emptyFset, params.List[0].Type) != nil {
+				continue
+			}
+			if _, _, _, ok := decorContextType(buf.String(), pkgDecorName); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var pkgCacheTestFiles = map[string]string{
+	"go.mod": "module example.com/cache\n\ngo 1.18\n",
+	"decor.go": `package cache
+
+import "github.com/dengsgo/go-decorator/decor"
+
+func Logging(ctx *decor.Context) { ctx.TargetDo() }
+`,
+	"alias.go": `package cache
+
+import d "github.com/dengsgo/go-decorator/decor"
+
+func (r *Registry) Trace(ctx *d.Context, name string) { ctx.TargetDo() }
+`,
+	"vars.go": "package cache\n\nvar registry = &Registry{}\n",
+	"plain.go": `package cache
+
+type Registry struct{}
+
+func Plain(s string) string { return s }
+`,
+}
+
+func writePkgCacheTestFiles(t *testing.T) string {
+	dir := t.TempDir()
+	for name, src := range pkgCacheTestFiles {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func parsedFiles(set *pkgSet) []string {
+	var names []string
+	for _, pkg := range set.pkgs {
+		for file := range pkg.Files {
+			names = append(names, filepath.Base(file))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestParsePkgDirCache(t *testing.T) {
+	defer func(d, c string) { tempDir, cmdFlag.Cache = d, c }(tempDir, cmdFlag.Cache)
+	tempDir, cmdFlag.Cache = t.TempDir(), "on"
+	dir := writePkgCacheTestFiles(t)
+	goMod := filepath.Join(dir, "go.mod")
+
+	set, err := parsePkgDir(dir, goMod, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.partial || len(parsedFiles(set)) != 4 {
+		t.Fatalf("parsePkgDir() first parse should parse all files, got %q", parsedFiles(set))
+	}
+	if _, err := os.Stat(pkgCacheFile(dir, goMod)); err != nil {
+		t.Fatal("parsePkgDir() should write cache", err)
+	}
+
+	set, err = parsePkgDir(dir, goMod, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "alias.go,decor.go,vars.go"
+	if !set.partial || strings.Join(parsedFiles(set), ",") != want {
+		t.Fatalf("parsePkgDir() cached parse want %s, got %q", want, parsedFiles(set))
+	}
+
+	// 文件变化后缓存失效
+	if err := os.WriteFile(filepath.Join(dir, "plain.go"), []byte(pkgCacheTestFiles["plain.go"]+"\nvar x = 1\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	set, err = parsePkgDir(dir, goMod, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.partial {
+		t.Fatal("parsePkgDir() should parse all files after plain.go changed")
+	}
+	set, _ = parsePkgDir(dir, goMod, true)
+	if want := "alias.go,decor.go,plain.go,vars.go"; strings.Join(parsedFiles(set), ",") != want {
+		t.Fatalf("parsePkgDir() cached parse want %s, got %q", want, parsedFiles(set))
+	}
+
+	// go.mod 变化后使用新的缓存文件
+	if err := os.WriteFile(goMod, []byte("module example.com/cache\n\ngo 1.20\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if set, _ := parsePkgDir(dir, goMod, true); set.partial {
+		t.Fatal("parsePkgDir() should not use the cache of another go.mod")
+	}
+}
+
+func TestParsePkgDirCacheOff(t *testing.T) {
+	defer func(d, c string) { tempDir, cmdFlag.Cache = d, c }(tempDir, cmdFlag.Cache)
+	tempDir, cmdFlag.Cache = t.TempDir(), "off"
+	dir := writePkgCacheTestFiles(t)
+	for i := 0; i < 2; i++ {
+		set, err := parsePkgDir(dir, "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if set.partial {
+			t.Fatal("parsePkgDir() should not use cache with -d.cache=off")
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, pkgCacheDirName)); !os.IsNotExist(err) {
+		t.Fatal("parsePkgDir() should not write cache with -d.cache=off")
+	}
+}
+
+func TestFindTargetCacheFallback(t *testing.T) {
+	defer func(d, c, p string, loader *pkgLoader) {
+		tempDir, cmdFlag.Cache, projectDir, pkgILoader = d, c, p, loader
+	}(tempDir, cmdFlag.Cache, projectDir, pkgILoader)
+	tempDir, cmdFlag.Cache = t.TempDir(), "on"
+	projectDir = writePkgCacheTestFiles(t)
+
+	// 第一个 loader 写入缓存，第二个 loader 只解析缓存中的文件
+	pkgILoader = newPkgLoader()
+	if _, _, _, err := pkgILoader.findFunc("", "Logging"); err != nil {
+		t.Fatal(err)
+	}
+	pkgILoader = newPkgLoader()
+	if _, decl, _, err := pkgILoader.findFunc("", "registry.Trace"); err != nil || decl.Name.Name != "Trace" {
+		t.Fatal("findFunc(registry.Trace) error", err)
+	}
+	if !pkgILoader.pkg[""].partial {
+		t.Fatal("findFunc() should use the cache")
+	}
+	// Plain 不在缓存记录的文件中，完整解析后找到
+	if _, decl, _, err := pkgILoader.findFunc("", "Plain"); err != nil || decl.Name.Name != "Plain" {
+		t.Fatal("findFunc(Plain) error", err)
+	}
+	if pkgILoader.pkg[""].partial {
+		t.Fatal("findFunc() should parse all files when not found in the cached files")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// -d.plugin "cmd args" 指定一个代码生成插件，组织内部的代码生成（如审计的桩代码）可以在同一次 -toolexec 中完成，
+// 不需要再写一个改写源码的工具。可以指定多个 -d.plugin ，按顺序执行。
+//
+// 包中被装饰的函数改写之后，每个插件执行一次：cmd 按空白分割为程序和参数，在包所在的目录下执行，
+// 标准输入为描述包中被装饰的函数的 JSON（见 pluginRequest ），标准输出为 JSON（见 pluginResponse ）：
+//
+//	{"stmts": [{"target": "(*T).Get", "code": "auditGet(id)"}], "files": [{"name": "zz_audit.go", "content": "package main ..."}]}
+//
+// stmts 中的语句插入到目标函数的开头，在所有装饰器之前执行，可以引用目标的参数和文件中导入的包；
+// files 中的文件作为包的一部分一起编译，只能导入包已经导入的包（importcfg 中只有它们）。
+// 插件以非 0 状态码退出时编译失败，它的标准错误作为错误信息。包中没有被装饰的函数时不执行插件。
+// 值为函数的包级变量由 decor.Wrap 包装，没有函数体，不在 targets 中。
+//
+// go build 的编译缓存只知道插件的命令（见 toolIDInputs），不知道它的输出，因此插件必须是确定的：
+// 相同的输入总是输出相同的代码。修改插件的行为时要改变命令（如加上版本号）或使用 -a 重新编译。
+
+// 插件的超时时间
+const pluginTimeout = 30 * time.Second
+
+// 指定了 -d.plugin 时，改写时记录的被装饰的函数，按改写的顺序排列
+var pluginDecorated []pluginDecl
+
+type pluginDecl struct {
+	file   string             // 函数所在的源文件
+	fd     *ast.FuncDecl      // 改写后的函数
+	decors []*decorAnnotation // 从最内层到最外层
+}
+
+// 传给插件的 JSON
+type pluginRequest struct {
+	Package string         `json:"package"` // 包的导入路径，main 包为 "main"
+	Name    string         `json:"name"`    // 包名
+	Dir     string         `json:"dir"`     // 包所在的目录
+	Targets []pluginTarget `json:"targets"`
+}
+
+// 被装饰的函数，参数为改写后的名字，未命名的参数也有名字
+type pluginTarget struct {
+	extLintTarget
+	File       string            `json:"file"`
+	Decorators []pluginDecorator `json:"decorators"` // 从最外层到最内层
+}
+
+type pluginDecorator struct {
+	extLintDecorator
+	Params map[string]string `json:"params"` // 注解中的参数，值为 Go 字面量
+}
+
+// 插件输出的 JSON
+type pluginResponse struct {
+	Stmts []pluginStmts `json:"stmts"`
+	Files []pluginFile  `json:"files"`
+}
+
+type pluginStmts struct {
+	Target string `json:"target"` // 目标的名称，和 pluginTarget 的 name 一致
+	Code   string `json:"code"`   // 一条或多条语句
+}
+
+type pluginFile struct {
+	Name    string `json:"name"` // 文件名，不含目录，不能和包中的文件重名
+	Content string `json:"content"`
+}
+
+// pluginFlag 实现 flag.Value ，-d.plugin 可以指定多次
+type pluginFlag []string
+
+func (p *pluginFlag) String() string {
+	return strings.Join(*p, "; ")
+}
+
+func (p *pluginFlag) Set(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return errors.New("plugin command can't be empty")
+	}
+	*p = append(*p, s)
+	return nil
+}
+
+// 记录改写后的函数 fd ，供插件使用
+func recordPluginDecl(file string, fd *ast.FuncDecl, decors []*decorAnnotation) {
+	if len(cmdFlag.Plugins) > 0 {
+		pluginDecorated = append(pluginDecorated, pluginDecl{file: file, fd: fd, decors: decors})
+	}
+}
+
+// 对包 pkg 依次执行所有的插件，把生成的语句插入目标函数，生成的文件写入 tgDir ，返回这些文件的路径。
+// 被插入语句的文件应当已经在改写的文件中。
+func runPlugins(fset *token.FileSet, pkg *astPackage, packageName, dir, tgDir string) ([]string, error) {
+	if len(cmdFlag.Plugins) == 0 || len(pluginDecorated) == 0 {
+		return nil, nil
+	}
+	req := newPluginRequest(fset, pkg.Name, packageName, dir, pluginDecorated)
+	fds := map[string]*ast.FuncDecl{}
+	for i, decl := range pluginDecorated {
+		fds[req.Targets[i].Name] = decl.fd
+	}
+	names := map[string]bool{}
+	for file := range pkg.Files {
+		names[filepath.Base(file)] = true
+	}
+	var files []string
+	for _, cmd := range cmdFlag.Plugins {
+		resp, err := runPlugin(cmd, dir, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range resp.Stmts {
+			fd, ok := fds[s.Target]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("plugin %q: %s is not a decorated function of package %s", cmd, s.Target, packageName))
+			}
+			stmts, _, err := getStmtList(s.Code)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("plugin %q: invalid statements for %s: %v", cmd, s.Target, err))
+			}
+			for _, stmt := range stmts {
+				setNodePos(stmt, fd.Body.Lbrace)
+			}
+			fd.Body.List = append(stmts, fd.Body.List...)
+		}
+		for _, pf := range resp.Files {
+			if pf.Name != filepath.Base(pf.Name) || !strings.HasSuffix(pf.Name, ".go") || names[pf.Name] {
+				return nil, errors.New(fmt.Sprintf("plugin %q: invalid file name %q, it must be a new .go file name without directories", cmd, pf.Name))
+			}
+			f, err := parser.ParseFile(token.NewFileSet(), pf.Name, pf.Content, parser.PackageClauseOnly)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("plugin %q: %v", cmd, err))
+			}
+			if f.Name.Name != pkg.Name {
+				return nil, errors.New(fmt.Sprintf("plugin %q: file %s is in package %s, but want %s", cmd, pf.Name, f.Name.Name, pkg.Name))
+			}
+			names[pf.Name] = true
+			file := filepath.Join(tgDir, pf.Name)
+			if err := os.WriteFile(file, []byte(pf.Content), 0666); err != nil {
+				return nil, errors.New("fail write plugin file " + err.Error())
+			}
+			// 指定了 -d.output 时，和改写后的文件放在一起
+			if cmdFlag.Output != "" {
+				outputFile := outputFilePath(cmdFlag.Output, packageInfo.Module.Dir, os.Getenv("TOOLEXEC_IMPORTPATH"), file)
+				_ = os.MkdirAll(filepath.Dir(outputFile), 0777)
+				if err := os.WriteFile(outputFile, []byte(pf.Content), 0666); err != nil {
+					return nil, errors.New("fail write into output file " + err.Error())
+				}
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func newPluginRequest(fset *token.FileSet, name, packageName, dir string, decls []pluginDecl) *pluginRequest {
+	req := &pluginRequest{Package: packageName, Name: name, Dir: dir, Targets: []pluginTarget{}}
+	for _, decl := range decls {
+		target := pluginTarget{File: decl.file, Decorators: []pluginDecorator{}}
+		// 和 extlint 一样描述目标，装饰器按从最外层到最内层排列
+		target.extLintTarget = newExtLintRequest(fset, "", decl.decors[0], decl.fd).Target
+		for i := len(decl.decors) - 1; i >= 0; i-- {
+			da := decl.decors[i]
+			params := da.parameters
+			if params == nil {
+				params = map[string]string{}
+			}
+			target.Decorators = append(target.Decorators, pluginDecorator{
+				extLintDecorator: extLintDecorator{Package: da.pkgPath, Name: da.name, Position: fset.Position(da.doc.Pos()).String()},
+				Params:           params,
+			})
+		}
+		req.Targets = append(req.Targets, target)
+	}
+	return req
+}
+
+// 在 dir 下执行插件 cmd ，标准输入为 req 的 JSON ，返回解析后的标准输出
+func runPlugin(cmd, dir string, req *pluginRequest) (*pluginResponse, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	args := strings.Fields(cmd)
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, args[0], args[1:]...)
+	c.Dir = dir
+	c.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.New(fmt.Sprintf("plugin %q timed out after %s", cmd, pluginTimeout))
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, errors.New(fmt.Sprintf("plugin %q can't run: %v", cmd, err))
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = exitErr.Error()
+		}
+		return nil, errors.New(fmt.Sprintf("plugin %q failed: %s", cmd, msg))
+	}
+	resp := &pluginResponse{}
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return resp, nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return nil, errors.New(fmt.Sprintf("plugin %q: invalid output: %v", cmd, err))
+	}
+	return resp, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh scripts")
+	}
+	src := `package main
+
+import (
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+type T struct{}
+
+//go:decor d.memoized
+//go:decor d.timedDecor
+func (t T) Get(id int) string { return "" }
+
+func plain() {}
+`
+	dir := t.TempDir()
+	script := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	decorate := func(plugins ...string) (*token.FileSet, *ast.File, []string, error) {
+		defer func(plugins pluginFlag) { cmdFlag.Plugins = plugins }(cmdFlag.Plugins)
+		cmdFlag.Plugins = plugins
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "/src/a.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := &astPackage{Name: "main", Files: map[string]*ast.File{"/src/a.go": f}}
+		if updated, err := decoratePackage(fset, pkg, "main", ""); err != nil || len(updated) != 1 {
+			t.Fatal("decoratePackage() error", updated, err)
+		}
+		files, err := runPlugins(fset, pkg, "main", dir, filepath.Join(dir, "out"))
+		return fset, f, files, err
+	}
+	if err := os.Mkdir(filepath.Join(dir, "out"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	// the request is written to stdin, the statements and files are added
+	audit := script("audit.sh", `cat > req.json
+cat <<'EOF'
+{"stmts": [{"target": "T.Get", "code": "_ = id\nauditCount++"}],
+ "files": [{"name": "zz_audit.go", "content": "package main\n\nvar auditCount int\n"}]}
+EOF`)
+	fset, f, files, err := decorate(audit)
+	if err != nil {
+		t.Fatal("runPlugins() error", err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "req.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req pluginRequest
+	if err := json.Unmarshal(b, &req); err != nil || req.Package != "main" || req.Name != "main" || len(req.Targets) != 1 {
+		t.Fatalf("runPlugins() request not match, got %s", b)
+	}
+	target := req.Targets[0]
+	if target.Name != "T.Get" || target.Receiver != "T" || target.File != "/src/a.go" ||
+		len(target.Decorators) != 2 || target.Decorators[0].Name != "d.memoized" || target.Decorators[1].Name != "d.timedDecor" {
+		t.Fatalf("runPlugins() target not match, got %s", b)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, f.Decls[2]); err != nil {
+		t.Fatal(err)
+	}
+	if body := buf.String(); !strings.Contains(body, "{\n\t_ = id\n\tauditCount++\n") {
+		t.Fatalf("runPlugins() should insert the statements first, got:\n%s", body)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(dir, "out", "zz_audit.go") {
+		t.Fatal("runPlugins() want zz_audit.go, but got", files)
+	}
+	if b, err := os.ReadFile(files[0]); err != nil || string(b) != "package main\n\nvar auditCount int\n" {
+		t.Fatalf("runPlugins() file content not match, got %q %v", b, err)
+	}
+
+	for _, c := range []struct {
+		body, err string
+	}{
+		{`echo "no audit rules" >&2; exit 1`, `failed: no audit rules`},
+		{`echo '{"stmts": [{"target": "plain", "code": "x++"}]}'`, `plain is not a decorated function of package main`},
+		{`echo '{"stmts": [{"target": "T.Get", "code": "x :="}]}'`, `invalid statements for T.Get`},
+		{`echo '{"files": [{"name": "a.go", "content": "package main"}]}'`, `invalid file name "a.go"`},
+		{`echo '{"files": [{"name": "../b.go", "content": "package main"}]}'`, `invalid file name "../b.go"`},
+		{`echo '{"files": [{"name": "b.go", "content": "package other"}]}'`, `file b.go is in package other, but want main`},
+		{`echo 'stmts'`, `invalid output`},
+	} {
+		if _, _, _, err := decorate(script("fail.sh", c.body)); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("runPlugins() %s want error %q, but got %v", c.body, c.err, err)
+		}
+	}
+
+	// without -d.plugin nothing is recorded
+	if _, _, files, err := decorate(); err != nil || files != nil || pluginDecorated != nil {
+		t.Fatal("runPlugins() without plugins should do nothing, but got", files, err)
+	}
+}
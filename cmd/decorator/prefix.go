@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// 注解的前缀默认为 //go:decor ，和其他同样使用 //go:decor 的 toolexec 改写工具冲突，或者希望使用自己的命名空间时，
+// 可以通过 -d.prefix 或 decor.toml 顶层的 prefix 修改，如 //acme:decor 。修改后所有的注解都使用新的前缀：
+//
+//	//acme:decor logging
+//	//acme:decor-lint once: true
+//	//acme:decor-all ^Handle logging
+//	//acme:decor-args level: "info"
+//	//acme:decor-wrap-return logging
+//
+// 装饰器声明上的注释（-lint 、-default 、-pure 、-deprecated）同时接受默认前缀，
+// 装饰器可能来自使用默认前缀的其他模块，如 decor/std 。
+
+const defaultDecorPrefix = "//go:decor"
+
+// 当前使用的前缀
+var decorPrefix = defaultDecorPrefix
+
+// 前缀的格式和 Go 的指令注释相同：//namespace:name ，不能包含空白
+var decorPrefixRe = regexp.MustCompile(`^//[a-z0-9]+:[a-z0-9][a-zA-Z0-9_.]*$`)
+
+// 规范化前缀 s ，可以省略开头的 //
+func normalizeDecorPrefix(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "//") {
+		s = "//" + s
+	}
+	if !decorPrefixRe.MatchString(s) {
+		return "", errors.New("invalid annotation prefix " + s + ", it should look like //namespace:decor")
+	}
+	return s, nil
+}
+
+// 设置注解的前缀 prefix （已规范化），更新所有注解的写法
+func setDecorPrefix(prefix string) {
+	decorPrefix = prefix
+	decoratorScanFlag = prefix + " "
+	decorLintScanFlag = prefix + "-lint "
+	decorDefaultScanFlag = prefix + "-default "
+	decorAllScanFlag = prefix + "-all "
+	decorPureFlag = prefix + "-pure"
+	decorDeprecatedFlag = prefix + "-deprecated"
+	decorArgsFlag = prefix + "-args"
+	decorWrapReturnFlag = prefix + "-wrap-return "
+	annotationLikeRe = regexp.MustCompile(`^//\s*` + regexp.QuoteMeta(prefix[len("//"):]) + `\s+(.*)$`)
+}
+
+// 按 -d.prefix 、目录 dir 所在模块的 decor.toml 的顺序确定注解的前缀，都没有设置时使用默认前缀
+func useDecorPrefix(dir string) error {
+	prefix := defaultDecorPrefix
+	if cmdFlag.Prefix != "" {
+		p, err := normalizeDecorPrefix(cmdFlag.Prefix)
+		if err != nil {
+			return err
+		}
+		prefix = p
+	} else {
+		cfg, err := loadDecorConfig(dir)
+		if err != nil {
+			return err
+		}
+		if cfg != nil && cfg.prefix != "" {
+			prefix = cfg.prefix
+		}
+	}
+	setDecorPrefix(prefix)
+	return nil
+}
+
+// 去掉装饰器声明上的注释 text 开头的 flag （如 decorLintScanFlag ），text 不以 flag 开头时返回 false 。
+// 使用自定义前缀时，默认前缀的写法同样有效。
+func trimDecorDeclFlag(text, flag string) (string, bool) {
+	if s := strings.TrimPrefix(text, flag); s != text {
+		return s, true
+	}
+	if decorPrefix != defaultDecorPrefix && strings.HasPrefix(flag, decorPrefix) {
+		if s := strings.TrimPrefix(text, defaultDecorPrefix+flag[len(decorPrefix):]); s != text {
+			return s, true
+		}
+	}
+	return text, false
+}
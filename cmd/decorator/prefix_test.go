@@ -0,0 +1,116 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDecorPrefix(t *testing.T) {
+	for s, want := range map[string]string{
+		"//acme:decor":  "//acme:decor",
+		"acme:decor":    "//acme:decor",
+		" //go:decor ":  "//go:decor",
+		"//x1:my_decor": "//x1:my_decor",
+	} {
+		if got, err := normalizeDecorPrefix(s); err != nil || got != want {
+			t.Fatalf("normalizeDecorPrefix(%q) want %q, but got %q %v", s, want, got, err)
+		}
+	}
+	for _, s := range []string{"", "//", "// acme:decor", "//acme decor", "//Acme:decor", "//acme:", "acme"} {
+		if _, err := normalizeDecorPrefix(s); err == nil {
+			t.Fatalf("normalizeDecorPrefix(%q) should fail", s)
+		}
+	}
+}
+
+func TestSetDecorPrefix(t *testing.T) {
+	defer setDecorPrefix(defaultDecorPrefix)
+	setDecorPrefix("//acme:decor")
+	if decoratorScanFlag != "//acme:decor " || decorLintScanFlag != "//acme:decor-lint " || decorAllScanFlag != "//acme:decor-all " ||
+		decorDefaultScanFlag != "//acme:decor-default " || decorPureFlag != "//acme:decor-pure" || decorDeprecatedFlag != "//acme:decor-deprecated" ||
+		decorWrapReturnFlag != "//acme:decor-wrap-return " {
+		t.Fatal("setDecorPrefix() should update all flags, got", decoratorScanFlag, decorLintScanFlag, decorPureFlag)
+	}
+	// 装饰器声明上的注释同时接受默认前缀
+	cas := []struct {
+		text, flag string
+		rest       string
+		ok         bool
+	}{
+		{"//acme:decor-lint once: true", decorLintScanFlag, "once: true", true},
+		{"//go:decor-lint once: true", decorLintScanFlag, "once: true", true},
+		{"//go:decor-pure", decorPureFlag, "", true},
+		{"//other:decor-lint once: true", decorLintScanFlag, "", false},
+	}
+	for i, c := range cas {
+		if rest, ok := trimDecorDeclFlag(c.text, c.flag); ok != c.ok || (ok && rest != c.rest) {
+			t.Fatalf("cas[%d] trimDecorDeclFlag(%q, %q) want %q %v, but got %q %v", i, c.text, c.flag, c.rest, c.ok, rest, ok)
+		}
+	}
+	if m := annotationLikeRe.FindStringSubmatch("// acme:decor   logging"); m == nil || m[1] != "logging" {
+		t.Fatal("setDecorPrefix() should update annotationLikeRe, got", m)
+	}
+	setDecorPrefix(defaultDecorPrefix)
+	if _, ok := trimDecorDeclFlag("//acme:decor-lint once: true", decorLintScanFlag); ok {
+		t.Fatal("trimDecorDeclFlag() with the default prefix should only accept //go:decor")
+	}
+}
+
+func TestParseDecorConfigPrefix(t *testing.T) {
+	cfg, err := parseDecorConfig(`prefix = "acme:decor" # comment
+
+[[rule]]
+packages = ["./..."]
+decorators = ["logging"]
+`)
+	if err != nil || cfg.prefix != "//acme:decor" || len(cfg.rules) != 1 {
+		t.Fatalf("parseDecorConfig() want prefix //acme:decor, but got %+v %v", cfg, err)
+	}
+	for s, want := range map[string]string{
+		"prefix = \"acme decor\"":            "1: prefix: invalid annotation prefix",
+		"prefix = 1":                         "1: prefix: expected a string",
+		"prefix = \"a:b\"\nprefix = \"c:d\"": "2: duplicate key prefix",
+		"[alias]\nprefix = \"a:b\"":          "2: alias prefix",
+	} {
+		if _, err := parseDecorConfig(s); err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Fatalf("parseDecorConfig(%q) want error %q, but got %v", s, want, err)
+		}
+	}
+}
+
+func TestDecoratePackagePrefix(t *testing.T) {
+	defer setDecorPrefix(defaultDecorPrefix)
+	setDecorPrefix("//acme:decor")
+	src := `package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+)
+
+//go:decor d.notExist
+func ignored() {}
+
+//acme:decor d.oldLogging#{level: ""}
+func lintFailed() {}
+
+//acme:decor d.memoized
+func decorated(a int) {}
+
+var _ = decor.KFunc
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = decoratePackage(fset, &astPackage{Name: "main", Files: map[string]*ast.File{"a.go": f}}, "main", "")
+	// 只识别新前缀的注解，装饰器上默认前缀的 lint 规则仍然生效
+	want := "found 1 decorator error(s) in package main:" + biSymbol + "a.go:11:1: lint: key 'level' value '\"\"' can't pass nonzero lint"
+	if err == nil || err.Error() != want {
+		t.Fatalf("decoratePackage() with prefix //acme:decor want %q, but got %v", want, err)
+	}
+}
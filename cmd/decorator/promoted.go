@@ -0,0 +1,229 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// 类型上的装饰注释只装饰在类型上声明的方法，嵌入字段提升的方法不会被装饰。
+// 注释中的保留参数 promoted: true 让它同样覆盖提升的方法：
+//
+//	//go:decor logging#{promoted: true}
+//	type Service struct {
+//		*Store
+//	}
+//
+// 对 Store 上声明的每个方法 Get ，在 Service 上生成一个转发的方法，再按类型装饰的规则装饰它：
+//
+//	func (s Service) Get(key string) (string, error) { return s.Store.Get(key) }
+//
+// 只处理直接嵌入的、在当前包中声明的非泛型类型（ T 或 *T ）。Service 自己声明的方法、同名的字段，
+// 以及在多个嵌入字段中同名（有歧义，不会被提升）的方法都会被跳过。
+// 方法为指针接收者而 T 按值嵌入时，生成的方法使用指针接收者 *Service 。
+// 生成的方法放在 Get 所在的文件中，参数和返回值的类型在那里总是可以解析。
+
+// 类型装饰没有覆盖的提升方法，由 typeDecorRebuild 收集，编译时作为警告输出
+var uncoveredPromotions []uncoveredPromotion
+
+type uncoveredPromotion struct {
+	pos token.Pos
+	msg string
+}
+
+// 嵌入字段 field 提升的方法
+type promotion struct {
+	field   *ast.Field
+	typName string        // 嵌入的类型名称
+	ptr     bool          // 是否按指针嵌入
+	method  *ast.FuncDecl // 嵌入类型上声明的方法
+}
+
+// 生成结构体 spec 上覆盖提升方法的转发方法，返回方法所在的文件和生成的方法。
+// types 为包中所有的类型声明，methods 为每个类型上声明的方法（不含绑定的装饰器），
+// files 为方法所在的文件。无法覆盖的嵌入字段记录到 uncoveredPromotions 。
+func promotedMethodDecls(spec *ast.TypeSpec, tdcs []*typeDecorComment, types map[string]*ast.TypeSpec,
+	methods map[string][]*ast.FuncDecl, files map[*ast.FuncDecl]*ast.File) map[*ast.File][]*ast.FuncDecl {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok || st.Fields == nil || spec.Assign.IsValid() {
+		return nil
+	}
+	promoted := false
+	for _, tdc := range tdcs {
+		promoted = promoted || tdc.promoted
+	}
+
+	// 深度为 1 的名称：字段名、嵌入字段的类型名和它们的方法名，出现多次的方法名有歧义
+	owned := map[string]bool{}
+	for _, m := range methods[spec.Name.Name] {
+		owned[m.Name.Name] = true
+	}
+	counts := map[string]int{}
+	var proms []*promotion
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			owned[name.Name] = true
+		}
+		if len(field.Names) > 0 {
+			continue
+		}
+		typName, ptr := embeddedTypeName(field.Type)
+		ts := types[typName]
+		if ts == nil || ts.TypeParams != nil || ts.Assign.IsValid() {
+			// 其它包中的类型、泛型、别名等无法生成转发方法
+			if promoted {
+				uncoveredPromotions = append(uncoveredPromotions, uncoveredPromotion{
+					pos: field.Pos(),
+					msg: "promoted methods of embedded field " + typeString(field.Type) +
+						" are not decorated, promoted only covers non-generic types declared in the same package",
+				})
+			}
+			continue
+		}
+		owned[typName] = true
+		if _, ok := ts.Type.(*ast.InterfaceType); ok {
+			if promoted {
+				uncoveredPromotions = append(uncoveredPromotions, uncoveredPromotion{
+					pos: field.Pos(),
+					msg: "promoted methods of embedded interface " + typName + " are not decorated",
+				})
+			}
+			continue
+		}
+		for _, m := range methods[typName] {
+			counts[m.Name.Name]++
+			proms = append(proms, &promotion{field: field, typName: typName, ptr: ptr, method: m})
+		}
+	}
+
+	// 没有使用 promoted 时，列出不会被装饰的提升方法
+	uncovered := map[*ast.Field][]string{}
+	var fields []*ast.Field
+	decls := map[*ast.File][]*ast.FuncDecl{}
+	gi := newGenIdentId()
+	for _, p := range proms {
+		name := p.method.Name.Name
+		if owned[name] || counts[name] > 1 || name == "_" {
+			continue
+		}
+		matched, covered := false, false
+		for _, tdc := range tdcs {
+			if tdc.match(name) {
+				matched = true
+				covered = covered || tdc.promoted
+			}
+		}
+		if !matched {
+			continue
+		}
+		if !covered {
+			if uncovered[p.field] == nil {
+				fields = append(fields, p.field)
+			}
+			uncovered[p.field] = append(uncovered[p.field], name)
+			continue
+		}
+		f := files[p.method]
+		decls[f] = append(decls[f], promotedMethodDecl(spec, p, gi))
+	}
+	for _, field := range fields {
+		names := uncovered[field]
+		sort.Strings(names)
+		uncoveredPromotions = append(uncoveredPromotions, uncoveredPromotion{
+			pos: field.Pos(),
+			msg: "promoted methods of embedded field " + typeString(field.Type) + " are not decorated: " +
+				strings.Join(names, ", ") + ", add promoted: true to the type decorator to decorate them",
+		})
+	}
+	return decls
+}
+
+// 嵌入字段的类型名称，只支持 T 和 *T ，其它形式（如 pkg.T 、T[int]）返回空字符串
+func embeddedTypeName(expr ast.Expr) (name string, ptr bool) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr, ptr = star.X, true
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name, ptr
+	}
+	return "", false
+}
+
+// 生成 spec 上转发提升方法 p 的方法：
+//
+//	func (r Service) Get(key string) (string, error) { return r.Store.Get(key) }
+//
+// 未命名或名为 _ 的参数使用生成的名称，参数和返回值的类型与原方法共用，位置都指向原方法。
+func promotedMethodDecl(spec *ast.TypeSpec, p *promotion, gi *genIdentId) *ast.FuncDecl {
+	m := p.method
+	pos := m.Pos()
+	ident := func(name string) *ast.Ident {
+		return &ast.Ident{NamePos: pos, Name: name}
+	}
+
+	var recvType ast.Expr = ident(spec.Name.Name)
+	if _, ok := m.Recv.List[0].Type.(*ast.StarExpr); ok && !p.ptr {
+		recvType = &ast.StarExpr{Star: pos, X: recvType}
+	}
+	recv := gi.nextStr()
+
+	params := &ast.FieldList{Opening: pos, Closing: pos}
+	var args []ast.Expr
+	ellipsis := token.NoPos
+	if m.Type.Params != nil {
+		for _, field := range m.Type.Params.List {
+			nf := &ast.Field{Type: field.Type}
+			names := field.Names
+			if len(names) == 0 {
+				names = []*ast.Ident{{Name: "_"}}
+			}
+			for _, name := range names {
+				n := name.Name
+				if n == "_" {
+					n = gi.nextStr()
+				}
+				nf.Names = append(nf.Names, ident(n))
+				args = append(args, ident(n))
+			}
+			if _, ok := field.Type.(*ast.Ellipsis); ok {
+				ellipsis = pos
+			}
+			params.List = append(params.List, nf)
+		}
+	}
+
+	var results *ast.FieldList
+	if m.Type.Results != nil {
+		results = &ast.FieldList{Opening: m.Type.Results.Opening, Closing: m.Type.Results.Closing}
+		for _, field := range m.Type.Results.List {
+			nf := &ast.Field{Type: field.Type}
+			for _, name := range field.Names {
+				nf.Names = append(nf.Names, ident(name.Name))
+			}
+			results.List = append(results.List, nf)
+		}
+	}
+
+	call := &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   &ast.SelectorExpr{X: ident(recv), Sel: ident(p.typName)},
+			Sel: ident(m.Name.Name),
+		},
+		Lparen:   pos,
+		Args:     args,
+		Ellipsis: ellipsis,
+		Rparen:   pos,
+	}
+	var stmt ast.Stmt = &ast.ExprStmt{X: call}
+	if results != nil && len(results.List) > 0 {
+		stmt = &ast.ReturnStmt{Return: pos, Results: []ast.Expr{call}}
+	}
+
+	return &ast.FuncDecl{
+		Recv: &ast.FieldList{Opening: pos, List: []*ast.Field{{Names: []*ast.Ident{ident(recv)}, Type: recvType}}, Closing: pos},
+		Name: ident(m.Name.Name),
+		Type: &ast.FuncType{Func: pos, Params: params, Results: results},
+		Body: &ast.BlockStmt{Lbrace: pos, List: []ast.Stmt{stmt}, Rbrace: pos},
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTypeDecorRebuildPromoted(t *testing.T) {
+	src := `package main
+
+import "strings"
+
+type Store struct{}
+
+func (s *Store) Get(key string) (string, error) { return key, nil }
+func (s Store) Put(_ string, values ...int) {}
+func (s *Store) Close() {}
+
+type Closer interface{ Close() }
+
+type Other struct{}
+
+func (o Other) Close() {}
+
+//go:decor logging#{promoted: true, methods: "Get,Put,Close"}
+//go:decor timing
+type Service struct {
+	*Store
+	strings.Builder
+}
+
+//go:decor logging#{promoted: true}
+type Cache struct {
+	Store
+	Other
+	Closer
+}
+
+func (c Cache) Get(key string) (string, error) { return key, nil }
+
+//go:decor logging
+type Plain struct {
+	*Store
+	Closer
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(f.Decls)
+	if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err != nil {
+		t.Fatal("typeDecorRebuild() error", err)
+	}
+
+	// 按类型名称排序生成；Cache.Get 自己声明，Close 在 Store 和 Other 中有歧义，只剩 Put
+	want := []string{
+		"func (g1 Cache) Put(g2 string, values ...int) { g1.Store.Put(g2, values...) }",
+		"func (g1 Service) Get(key string) (string, error) { return g1.Store.Get(key) }",
+		"func (g2 Service) Put(g3 string, values ...int) { g2.Store.Put(g3, values...) }",
+		"func (g4 Service) Close() { g4.Store.Close() }",
+	}
+	if len(f.Decls)-n != len(want) {
+		t.Fatalf("typeDecorRebuild() want %d promoted methods, but got %d", len(want), len(f.Decls)-n)
+	}
+	genIdent := regexp.MustCompile(`_decorGenIdent[0-9A-Za-z]{6}(\d+)`)
+	noSpace := func(s string) string { return strings.Join(strings.Fields(s), "") }
+	for i, decl := range f.Decls[n:] {
+		fd := decl.(*ast.FuncDecl)
+		var buf bytes.Buffer
+		if err := format.Node(&buf, token.NewFileSet(), &ast.FuncDecl{Recv: fd.Recv, Name: fd.Name, Type: fd.Type, Body: fd.Body}); err != nil {
+			t.Fatal(err)
+		}
+		if got := genIdent.ReplaceAllString(buf.String(), "g$1"); noSpace(got) != noSpace(want[i]) {
+			t.Fatalf("promoted method %d want %s, but got %s", i, want[i], got)
+		}
+		// 生成的方法只使用 promoted 的注释
+		if len(fd.Doc.List) != 1 || fd.Doc.List[0].Text != "//go:decor logging" {
+			t.Fatalf("promoted method %s got doc %v", fd.Name.Name, fd.Doc.List)
+		}
+	}
+
+	// 没有使用 promoted 的 Plain 只列出同一个包中的提升方法
+	wantWarns := []string{
+		"embedded interface Closer",
+		"embedded field *Store are not decorated: Close, Get, Put",
+		"embedded field strings.Builder are not decorated",
+	}
+	if len(uncoveredPromotions) != len(wantWarns) {
+		t.Fatalf("uncoveredPromotions want %d, but got %+v", len(wantWarns), uncoveredPromotions)
+	}
+	for i, up := range uncoveredPromotions {
+		if !strings.Contains(up.msg, wantWarns[i]) {
+			t.Fatalf("uncoveredPromotions[%d] want %q, but got %q", i, wantWarns[i], up.msg)
+		}
+	}
+}
+
+func TestEmbeddedTypeName(t *testing.T) {
+	for src, want := range map[string]struct {
+		name string
+		ptr  bool
+	}{
+		"T":        {"T", false},
+		"*T":       {"T", true},
+		"pkg.T":    {"", false},
+		"T[int]":   {"", false},
+		"*pkg.T":   {"", false},
+		"*T[K, V]": {"", false},
+	} {
+		expr, err := parser.ParseExpr(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, ptr := embeddedTypeName(expr); name != want.name || ptr != want.ptr {
+			t.Fatalf("embeddedTypeName(%s) want %s %v, but got %s %v", src, want.name, want.ptr, name, ptr)
+		}
+	}
+}
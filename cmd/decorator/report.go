@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// report 子命令：统计模块中被装饰的函数，用于审计可观测性等装饰器的覆盖情况。
+//
+//	decorator report [-json] [-funcs patterns] [packages]
+//
+// packages 的写法和 go list 一致，默认为 ./... 。按包列出被装饰的函数（方法、函数变量）和它们的装饰器，
+// 以及没有被装饰的导出函数，最后按装饰器汇总被装饰的函数的数量。
+// 和 compile 一样先展开类型、//go:decor-all 、decor.toml 上的装饰器和别名。
+//
+// -funcs 为逗号分隔的 path.Match 模式，和 decor.toml 的 funcs 一样，方法的名称为 Type.Method ，
+// 只有匹配的导出函数才会作为未装饰的函数列出，默认为 * 。装饰器本身和 _test.go 中的函数不统计。
+// -json 时输出一个 JSON 对象。
+
+// 报告中的一个函数
+type reportFunc struct {
+	Name       string   `json:"name"`                 // 函数名，方法为 (*T).Name 或 T.Name
+	Position   string   `json:"position"`             // file:line ，相对于执行命令的目录
+	Decorators []string `json:"decorators,omitempty"` // 按注释顺序排列的装饰器
+}
+
+// 一个包的统计
+type reportPackage struct {
+	ImportPath  string       `json:"importPath"`
+	Decorated   []reportFunc `json:"decorated"`
+	Undecorated []reportFunc `json:"undecorated"`
+}
+
+type decorationReport struct {
+	Packages    []*reportPackage `json:"packages"`
+	Decorators  map[string]int   `json:"decorators"` // 装饰器名 => 使用它的函数数量
+	Decorated   int              `json:"decorated"`
+	Undecorated int              `json:"undecorated"`
+}
+
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	funcs := fs.String("funcs", "*", "comma separated patterns of the undecorated exported functions to list, methods are named Type.Method")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	funcPatterns, err := parseReportFuncs(*funcs)
+	if err != nil {
+		return err
+	}
+	r, err := reportPackages(patterns, funcPatterns)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	printReport(os.Stdout, r)
+	return nil
+}
+
+// 解析 -funcs ，空的模式被忽略
+func parseReportFuncs(s string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid -funcs pattern %q: %v", p, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// 统计所有匹配 patterns 的包，funcPatterns 为 -funcs 的模式
+func reportPackages(patterns, funcPatterns []string) (*decorationReport, error) {
+	workDir := projectDir
+	r := &decorationReport{Packages: []*reportPackage{}, Decorators: map[string]int{}}
+	err := visitPackages(patterns, func(pi *_packageInfo, fset *token.FileSet, pkg *astPackage) error {
+		rp, err := reportPackageFuncs(fset, pkg, pi.ImportPath, workDir, funcPatterns)
+		if err != nil {
+			return err
+		}
+		if len(rp.Decorated) == 0 && len(rp.Undecorated) == 0 {
+			return nil
+		}
+		r.Packages = append(r.Packages, rp)
+		r.Decorated += len(rp.Decorated)
+		r.Undecorated += len(rp.Undecorated)
+		for _, fn := range rp.Decorated {
+			for _, d := range fn.Decorators {
+				r.Decorators[d]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(r.Packages, func(i, j int) bool { return r.Packages[i].ImportPath < r.Packages[j].ImportPath })
+	return r, nil
+}
+
+// 解析所有匹配 patterns 的包中的非测试文件，逐个交给 fn 。和 lint 一样，decor.toml 从包所在的目录查找，
+// fn 执行时 projectDir 为包所在的目录。
+func visitPackages(patterns []string, fn func(pi *_packageInfo, fset *token.FileSet, pkg *astPackage) error) error {
+	pkgs, err := listPackages(patterns)
+	if err != nil {
+		return err
+	}
+	workDir, prefix := projectDir, decorPrefix
+	defer func() {
+		projectDir = workDir
+		pkgILoader.resetCurrentPkg()
+		setDecorPrefix(prefix)
+	}()
+	for _, pi := range pkgs {
+		if len(pi.GoFiles) == 0 {
+			continue
+		}
+		projectDir = pi.Dir
+		pkgILoader.resetCurrentPkg()
+		// 注解的前缀可能由包所在模块的 decor.toml 设置
+		if err := useDecorPrefix(pi.Dir); err != nil {
+			return err
+		}
+		files := make([]string, 0, len(pi.GoFiles))
+		for _, name := range pi.GoFiles {
+			files = append(files, filepath.Join(pi.Dir, name))
+		}
+		fset := token.NewFileSet()
+		pkg, err := parserGOFiles(fset, files...)
+		if err != nil {
+			return err
+		}
+		if err := fn(pi, fset, pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 统计包 pkg 中的函数，位置相对于 workDir 。decor.toml 无法解析时返回错误，其他错误由 lint 报告，这里忽略。
+func reportPackageFuncs(fset *token.FileSet, pkg *astPackage, importPath, workDir string, funcPatterns []string) (*reportPackage, error) {
+	_, _ = typeDecorRebuild(pkg)
+	_, _ = decorAllRebuild(pkg, "")
+	if err := applyDecorConfig(pkg, ""); err != nil {
+		return nil, err
+	}
+	if err := applyDecorAliases(pkg, ""); err != nil {
+		return nil, err
+	}
+	rp := &reportPackage{ImportPath: importPath, Decorated: []reportFunc{}, Undecorated: []reportFunc{}}
+	position := func(pos token.Pos) string {
+		p := fset.Position(pos)
+		name := p.Filename
+		if rel, err := filepath.Rel(workDir, name); err == nil {
+			name = rel
+		}
+		return fmt.Sprintf("%s:%d", name, p.Line)
+	}
+	for _, f := range pkg.Files {
+		pkgDecorName, _ := newImporter(f).importedPath(decoratorPackagePath)
+		if pkgDecorName == "_" || pkgDecorName == "" {
+			pkgDecorName = "decor"
+		}
+		visit := func(fd *ast.FuncDecl, named string) bool {
+			if decors := annotationDecorators(fd.Doc); len(decors) > 0 {
+				rp.Decorated = append(rp.Decorated, reportFunc{Name: named, Position: position(fd.Pos()), Decorators: decors})
+			} else if fd.Name.IsExported() && !funIsDecorator(fd, pkgDecorName) && !funIsBoundDecorator(fd, pkgDecorName) && matchReportFunc(funcPatterns, funcDeclName(fd)) {
+				rp.Undecorated = append(rp.Undecorated, reportFunc{Name: named, Position: position(fd.Pos())})
+			}
+			return false
+		}
+		visitAstDecl(f, func(fd *ast.FuncDecl) bool {
+			return visit(fd, inlineTargetName(fd))
+		})
+		visitAstFuncLitVar(f, func(fd *ast.FuncDecl) bool {
+			return visit(fd, fd.Name.Name)
+		})
+		visitAstFuncValueVar(f, func(vs *ast.ValueSpec, doc *ast.CommentGroup) bool {
+			if decors := annotationDecorators(doc); len(decors) > 0 {
+				rp.Decorated = append(rp.Decorated, reportFunc{Name: vs.Names[0].Name, Position: position(vs.Pos()), Decorators: decors})
+			}
+			return false
+		})
+	}
+	for _, list := range [][]reportFunc{rp.Decorated, rp.Undecorated} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	return rp, nil
+}
+
+// 注释 doc 末尾连续的 //go:decor 注释中的装饰器名，按注释顺序排列，无法解析的注释被忽略
+func annotationDecorators(doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	var decors []string
+	for i := len(doc.List) - 1; i >= 0; i-- {
+		text := doc.List[i].Text
+		if strings.HasPrefix(text, decorLintScanFlag) {
+			continue
+		}
+		if !strings.HasPrefix(text, decoratorScanFlag) {
+			break
+		}
+		name, _, err := parseDecorAndParameters(text[len(decoratorScanFlag):])
+		if err != nil {
+			continue
+		}
+		decors = append([]string{name}, decors...)
+	}
+	return decors
+}
+
+// name 是否匹配 patterns 中的任意一个
+func matchReportFunc(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 输出文本格式的报告
+func printReport(w io.Writer, r *decorationReport) {
+	for _, rp := range r.Packages {
+		fmt.Fprintf(w, "%s: %d decorated, %d undecorated\n", rp.ImportPath, len(rp.Decorated), len(rp.Undecorated))
+		for _, fn := range rp.Decorated {
+			fmt.Fprintf(w, "  + %s (%s) %s\n", fn.Name, fn.Position, strings.Join(fn.Decorators, ", "))
+		}
+		for _, fn := range rp.Undecorated {
+			fmt.Fprintf(w, "  - %s (%s)\n", fn.Name, fn.Position)
+		}
+	}
+	names := make([]string, 0, len(r.Decorators))
+	for name := range r.Decorators {
+		names = append(names, name)
+	}
+	// 使用最多的装饰器在前
+	sort.Slice(names, func(i, j int) bool {
+		if r.Decorators[names[i]] != r.Decorators[names[j]] {
+			return r.Decorators[names[i]] > r.Decorators[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > 0 {
+		fmt.Fprintln(w, "decorators:")
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s: %d\n", name, r.Decorators[name])
+		}
+	}
+	fmt.Fprintf(w, "total: %d decorated, %d undecorated in %d package(s)\n", r.Decorated, r.Undecorated, len(r.Packages))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportPackageFuncs(t *testing.T) {
+	src := `package p
+
+import "github.com/dengsgo/go-decorator/decor"
+
+func logging(ctx *decor.Context) { ctx.TargetDo() }
+
+// Decorator 是装饰器，不统计
+func Decorator(ctx *decor.Context) { ctx.TargetDo() }
+
+// Get 说明
+//go:decor logging
+//go:decor d.tagging#{names: {"a"}}
+func Get() {}
+
+func Put() {}
+
+func (s *Service) Handle() {}
+
+func (s *Service) close() {}
+
+//go:decor logging
+var Handler = func() {}
+
+var Other = func() {}
+
+type Service struct{}
+`
+	dir := t.TempDir()
+	file := filepath.Join(dir, "p.go")
+	if err := os.WriteFile(file, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cas := []struct {
+		funcs       []string
+		undecorated []string
+	}{
+		{[]string{"*"}, []string{"(*Service).Handle", "Other", "Put"}},
+		{[]string{"Service.*"}, []string{"(*Service).Handle"}},
+		{[]string{"P*", "Oth*"}, []string{"Other", "Put"}},
+		{nil, nil},
+	}
+	for i, c := range cas {
+		fset := token.NewFileSet()
+		pkg, err := parserGOFiles(fset, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rp, err := reportPackageFuncs(fset, pkg, "example.com/p", dir, c.funcs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decorated []string
+		for _, fn := range rp.Decorated {
+			decorated = append(decorated, fn.Name+" "+strings.Join(fn.Decorators, ","))
+		}
+		if want := []string{"Get logging,d.tagging", "Handler logging"}; !reflect.DeepEqual(decorated, want) {
+			t.Fatalf("cas[%d] decorated got %q, want %q", i, decorated, want)
+		}
+		var undecorated []string
+		for _, fn := range rp.Undecorated {
+			undecorated = append(undecorated, fn.Name)
+		}
+		if !reflect.DeepEqual(undecorated, c.undecorated) {
+			t.Fatalf("cas[%d] undecorated got %q, want %q", i, undecorated, c.undecorated)
+		}
+		if len(rp.Decorated) > 0 && rp.Decorated[0].Position != "p.go:13" {
+			t.Fatalf("cas[%d] position got %q, want p.go:13", i, rp.Decorated[0].Position)
+		}
+	}
+}
+
+func TestParseReportFuncs(t *testing.T) {
+	got, err := parseReportFuncs(" Get* , ,Service.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Get*", "Service.*"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseReportFuncs() got %q, want %q", got, want)
+	}
+	if _, err := parseReportFuncs("Get["); err == nil {
+		t.Fatal("parseReportFuncs() should fail on a bad pattern")
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	r := &decorationReport{
+		Packages: []*reportPackage{{
+			ImportPath:  "example.com/p",
+			Decorated:   []reportFunc{{Name: "Get", Position: "p.go:3", Decorators: []string{"logging", "tracing"}}, {Name: "Put", Position: "p.go:6", Decorators: []string{"tracing"}}},
+			Undecorated: []reportFunc{{Name: "Delete", Position: "p.go:9"}},
+		}},
+		Decorators:  map[string]int{"logging": 1, "tracing": 2},
+		Decorated:   2,
+		Undecorated: 1,
+	}
+	var buf bytes.Buffer
+	printReport(&buf, r)
+	want := `example.com/p: 2 decorated, 1 undecorated
+  + Get (p.go:3) logging, tracing
+  + Put (p.go:6) tracing
+  - Delete (p.go:9)
+decorators:
+  tracing: 2
+  logging: 1
+total: 2 decorated, 1 undecorated in 1 package(s)
+`
+	if buf.String() != want {
+		t.Fatalf("printReport() got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
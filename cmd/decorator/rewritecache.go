@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 改写结果的缓存。
+//
+// go 的编译缓存被清理或使用 -a 时，即使包和它的依赖都没有变化，compile 也要重新解析、查找装饰器并生成代码。
+// 改写完成后，把改写后的文件和它们的 source map 按内容哈希保存在 tempDir/rewritecache 中，
+// 之后的构建中相同的输入直接使用缓存的文件，跳过解析和代码生成。链接之后清理工作目录时保留缓存，见 clearWorkDir 。
+//
+// 缓存以 decorator 可执行文件、包的所有源文件的内容、importcfg 中依赖的 build ID （依赖变化时它也会变化）、decor.toml 、
+// -d.tags 、-d.autoimport 、-trimpath 等为键；查找装饰器时解析过的包目录中 .go 文件的大小或修改时间变化时失效，
+// 因为装饰器的 lint 规则等不一定会体现在导出数据中。
+// 命中缓存时不会重复输出改写时的警告。GOFLAGS 含有 -a 时不读取缓存，-d.cache=off 时不使用缓存，
+// -d.cache.clear 清空缓存。-d.output 、-d.emitInlineReport 需要完整的改写过程，
+// -d.plugin 的输出取决于插件本身，都不使用缓存。
+//
+// 和 go 的编译缓存一样，命中时更新缓存文件的修改时间（最多每小时一次），保存时最多每天清理一次，
+// 删除超过 5 天没有使用的文件，上次清理的时间记录在 trim.txt 中。
+
+const rewriteCacheDirName = "rewritecache"
+
+const (
+	rewriteCacheTrimFile      = "trim.txt"
+	rewriteCacheTrimInterval  = 24 * time.Hour     // 两次清理的最短间隔
+	rewriteCacheTrimLimit     = 5 * 24 * time.Hour // 超过这个时间没有使用的文件被清理
+	rewriteCacheMtimeInterval = time.Hour          // 命中时更新修改时间的最短间隔
+)
+
+type rewriteCacheEntry struct {
+	Files map[string]string                  `json:"files"` // 被改写的源文件 => 缓存中改写后的文件名
+	Deps  map[string]map[string]pkgCacheStat `json:"deps"`  // 查找装饰器时解析过的包目录 => 目录中的 .go 文件
+}
+
+type rewriteCache struct {
+	dir    string
+	key    string
+	hashes map[string]string // 源文件 => 内容哈希
+}
+
+func rewriteCacheDir() string {
+	return filepath.Join(tempDir, rewriteCacheDirName)
+}
+
+// 源文件 files 的改写缓存，不使用缓存时返回 nil
+func newRewriteCache(files []string, importcfg, importPath string) *rewriteCache {
+	// cgo 生成的文件位于每次构建都不同的 $WORK 中，不会命中缓存
+	if !pkgCacheEnabled() || cmdFlag.Output != "" || cmdFlag.EmitInlineReport || len(cmdFlag.Plugins) > 0 || len(cgoSourceFiles) > 0 {
+		return nil
+	}
+	c := &rewriteCache{dir: rewriteCacheDir(), hashes: map[string]string{}}
+	h := sha256.New()
+	// 生成的代码中的 TargetFile 随 compile 的 -trimpath 变化，$WORK 每次构建都不同，因此以改写后的路径为键
+	trimmed := ""
+	if len(files) > 0 {
+		trimmed = trimCompilePath(files[0])
+	}
+	_, _ = io.WriteString(h, strings.Join([]string{version, toolStamp(), tempDir, importPath, cmdFlag.Tags, strconv.FormatBool(cmdFlag.AutoImport), strconv.FormatBool(cmdFlag.Strict), strconv.FormatBool(cmdFlag.Patch), strconv.FormatBool(cmdFlag.NoInline), strconv.FormatBool(cmdFlag.Toggle), strconv.FormatBool(cmdFlag.ExtLint), decorPrefix, trimmed}, "\x00"))
+	// importcfg 的路径和其中导出数据的路径都在每次构建都不同的 $WORK 中，按依赖的 build ID 计算
+	deps, err := importcfgDigest(importcfg)
+	if err != nil {
+		return nil
+	}
+	_, _ = io.WriteString(h, "importcfg\x00"+deps+"\x00")
+	inputs := append([]string{}, files...)
+	if packageInfo != nil && packageInfo.Module.Dir != "" {
+		inputs = append(inputs, filepath.Join(packageInfo.Module.Dir, decorConfigFileName))
+	}
+	for _, file := range inputs {
+		sum := ""
+		if b, err := os.ReadFile(file); err == nil {
+			s := sha256.Sum256(b)
+			sum = hex.EncodeToString(s[:])
+		} else if !os.IsNotExist(err) {
+			return nil
+		}
+		c.hashes[file] = sum
+		_, _ = io.WriteString(h, file+"\x00"+sum+"\x00")
+	}
+	c.key = hex.EncodeToString(h.Sum(nil))[:32]
+	return c
+}
+
+// importcfg 的摘要。其中的导出数据（如 $WORK/b002/_pkg_.a）以依赖的 build ID 代替路径：go 根据依赖的输入
+// 和编译结果确定 build ID ，依赖变化时它也变化，没有变化时在不同的构建中相同。读不到 build ID 时使用文件的内容的哈希。
+func importcfgDigest(importcfg string) (string, error) {
+	b, err := os.ReadFile(importcfg)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	h := sha256.New()
+	for _, line := range strings.Split(string(b), "\n") {
+		if rest := strings.TrimPrefix(line, "packagefile "); rest != line {
+			if pkg, file, ok := strings.Cut(rest, "="); ok {
+				// 不存在的文件保留原样，compile 会报告错误
+				if id, err := exportBuildID(file); err == nil {
+					line = "packagefile " + pkg + "=" + id
+				} else if !os.IsNotExist(err) {
+					return "", err
+				}
+			}
+		}
+		_, _ = io.WriteString(h, line+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 导出数据文件（go 的归档文件）的 build ID ，它记录在文件开头的 __.PKGDEF 中：build id "..."
+func exportBuildID(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 1024)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	const marker = "\nbuild id \""
+	if bytes.HasPrefix(head, []byte("!<arch>\n")) {
+		if i := bytes.Index(head, []byte(marker)); i >= 0 {
+			id := head[i+len(marker):]
+			if j := bytes.IndexByte(id, '"'); j > 0 {
+				return string(id[:j]), nil
+			}
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// decorator 可执行文件的路径、大小和修改时间。开发中重新构建的 decorator 的 version 不变，
+// 生成的代码却可能不同，不能使用旧的改写结果
+func toolStamp() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return exe
+	}
+	return fmt.Sprintf("%s:%d:%d", exe, info.Size(), info.ModTime().UnixNano())
+}
+
+// GOFLAGS 中是否有 -a
+func goFlagsForceRebuild() bool {
+	for _, f := range strings.Fields(os.Getenv("GOFLAGS")) {
+		if f == "-a" || f == "-a=true" || f == "--a" || f == "--a=true" {
+			return true
+		}
+	}
+	return false
+}
+
+// 清空改写缓存和装饰器所在的包的解析缓存
+func clearCaches() error {
+	if err := os.RemoveAll(rewriteCacheDir()); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(tempDir, pkgCacheDirName))
+}
+
+func (c *rewriteCache) entryFile() string {
+	return filepath.Join(c.dir, c.key+".json")
+}
+
+// 改写后的文件在缓存中的名称，由缓存的键和源文件的内容决定
+func (c *rewriteCache) blobName(file string) string {
+	h := sha256.Sum256([]byte(c.key + "\x00" + file + "\x00" + c.hashes[file]))
+	return hex.EncodeToString(h[:])[:32] + filepath.Ext(file)
+}
+
+// 读取缓存，把改写后的文件（和 source map）复制到 tgDir ，返回被改写的源文件和对应的临时文件。
+// 缓存不存在或已经失效时返回 false 。
+func (c *rewriteCache) load(tgDir string) (updatedFiles, tmpFiles []string, ok bool) {
+	b, err := os.ReadFile(c.entryFile())
+	if err != nil {
+		return nil, nil, false
+	}
+	entry := &rewriteCacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, nil, false
+	}
+	for dir, files := range entry.Deps {
+		stats, err := statGoFiles(dir)
+		if err != nil || len(stats) != len(files) {
+			return nil, nil, false
+		}
+		for name, stat := range stats {
+			if files[name] != stat {
+				return nil, nil, false
+			}
+		}
+	}
+	for file := range entry.Files {
+		updatedFiles = append(updatedFiles, file)
+	}
+	sort.Strings(updatedFiles)
+	for _, file := range updatedFiles {
+		blob := filepath.Join(c.dir, entry.Files[file])
+		tmpFile := filepath.Join(tgDir, filepath.Base(file))
+		if err := copyFile(blob, tmpFile); err != nil {
+			return nil, nil, false
+		}
+		if err := copyFile(blob+sourceMapExt, tmpFile+sourceMapExt); err != nil && !os.IsNotExist(err) {
+			return nil, nil, false
+		}
+		tmpFiles = append(tmpFiles, tmpFile)
+		markUsed(blob)
+		markUsed(blob + sourceMapExt)
+	}
+	markUsed(c.entryFile())
+	return updatedFiles, tmpFiles, true
+}
+
+// 更新缓存文件的修改时间，表示它刚被使用过，在 rewriteCacheMtimeInterval 内不重复更新
+func markUsed(file string) {
+	now := time.Now()
+	if info, err := os.Stat(file); err == nil && now.Sub(info.ModTime()) >= rewriteCacheMtimeInterval {
+		_ = os.Chtimes(file, now, now)
+	}
+}
+
+// 删除缓存中超过 rewriteCacheTrimLimit 没有使用的文件，距上次清理不到 rewriteCacheTrimInterval 时不做任何事。
+// 多个 compile 进程可能同时清理，删除失败的文件忽略。
+func (c *rewriteCache) trim(now time.Time) {
+	trimFile := filepath.Join(c.dir, rewriteCacheTrimFile)
+	if b, err := os.ReadFile(trimFile); err == nil {
+		if sec, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64); err == nil &&
+			now.Sub(time.Unix(sec, 0)) < rewriteCacheTrimInterval {
+			return
+		}
+	}
+	if err := writeFileAtomic(trimFile, []byte(strconv.FormatInt(now.Unix(), 10)+"\n")); err != nil {
+		return
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.Name() == rewriteCacheTrimFile {
+			continue
+		}
+		if info, err := e.Info(); err == nil && now.Sub(info.ModTime()) > rewriteCacheTrimLimit {
+			_ = os.Remove(filepath.Join(c.dir, e.Name()))
+		}
+	}
+}
+
+// 保存改写结果，updatedFiles 和 tmpFiles 一一对应，deps 为查找装饰器时解析过的包目录
+func (c *rewriteCache) store(updatedFiles, tmpFiles, deps []string) error {
+	entry := &rewriteCacheEntry{Files: map[string]string{}, Deps: map[string]map[string]pkgCacheStat{}}
+	for _, dir := range deps {
+		stats, err := statGoFiles(dir)
+		if err != nil {
+			return err
+		}
+		entry.Deps[dir] = stats
+	}
+	if err := os.MkdirAll(c.dir, 0777); err != nil {
+		return err
+	}
+	for i, file := range updatedFiles {
+		name := c.blobName(file)
+		if err := copyFile(tmpFiles[i], filepath.Join(c.dir, name)); err != nil {
+			return err
+		}
+		if err := copyFile(tmpFiles[i]+sourceMapExt, filepath.Join(c.dir, name)+sourceMapExt); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		entry.Files[file] = name
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.entryFile(), b); err != nil {
+		return err
+	}
+	c.trim(time.Now())
+	return nil
+}
+
+// 复制文件，多个 compile 进程可能同时写入同一个文件，先写临时文件再重命名
+func copyFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dst, b)
+}
+
+func writeFileAtomic(file string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "tmp_*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// 查找装饰器时解析过的包目录，按路径排序
+func loadedPkgDirs(loader *pkgLoader) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, set := range loader.pkg {
+		if set == nil || set.dir == "" || seen[set.dir] {
+			continue
+		}
+		seen[set.dir] = true
+		dirs = append(dirs, set.dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRewriteCache(t *testing.T) {
+	defer func(d, c string) { tempDir, cmdFlag.Cache = d, c }(tempDir, cmdFlag.Cache)
+	tempDir, cmdFlag.Cache = t.TempDir(), "on"
+	src := t.TempDir()
+	files := []string{filepath.Join(src, "a.go"), filepath.Join(src, "b.go")}
+	for _, file := range files {
+		if err := os.WriteFile(file, []byte("package p\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	importcfg := filepath.Join(src, "importcfg")
+	if err := os.WriteFile(importcfg, []byte("packagefile fmt=/cache/fmt-d\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	dep := writePkgCacheTestFiles(t)
+
+	// 只改写了 a.go
+	tgDir := t.TempDir()
+	tmpFile := filepath.Join(tgDir, "a.go")
+	if err := os.WriteFile(tmpFile, []byte("package p // rewritten\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tmpFile+sourceMapExt, []byte("{}"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cache := newRewriteCache(files, importcfg, "p")
+	if _, _, ok := cache.load(tgDir); ok {
+		t.Fatal("rewriteCache.load() should miss before store")
+	}
+	if err := cache.store(files[:1], []string{tmpFile}, []string{dep}); err != nil {
+		t.Fatal("rewriteCache.store() error", err)
+	}
+
+	loadDir := t.TempDir()
+	updatedFiles, tmpFiles, ok := newRewriteCache(files, importcfg, "p").load(loadDir)
+	if !ok || len(updatedFiles) != 1 || updatedFiles[0] != files[0] || tmpFiles[0] != filepath.Join(loadDir, "a.go") {
+		t.Fatalf("rewriteCache.load() should hit, got %v %v %v", updatedFiles, tmpFiles, ok)
+	}
+	for _, name := range []string{"a.go", "a.go" + sourceMapExt} {
+		want, _ := os.ReadFile(filepath.Join(tgDir, name))
+		if got, err := os.ReadFile(filepath.Join(loadDir, name)); err != nil || string(got) != string(want) {
+			t.Fatalf("rewriteCache.load() %s want %q, but got %q", name, want, got)
+		}
+	}
+
+	// 其它的导入路径、源文件、importcfg 变化时不命中
+	if _, _, ok := newRewriteCache(files, importcfg, "q").load(loadDir); ok {
+		t.Fatal("rewriteCache.load() should miss for another import path")
+	}
+	if err := os.WriteFile(files[1], []byte("package p\n\nvar x = 1\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := newRewriteCache(files, importcfg, "p").load(loadDir); ok {
+		t.Fatal("rewriteCache.load() should miss when a source file changes")
+	}
+	if err := os.WriteFile(files[1], []byte("package p\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(importcfg, []byte("packagefile fmt=/cache/fmt2-d\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := newRewriteCache(files, importcfg, "p").load(loadDir); ok {
+		t.Fatal("rewriteCache.load() should miss when importcfg changes")
+	}
+	if err := os.WriteFile(importcfg, []byte("packagefile fmt=/cache/fmt-d\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := newRewriteCache(files, importcfg, "p").load(loadDir); !ok {
+		t.Fatal("rewriteCache.load() should hit when inputs are restored")
+	}
+
+	// 装饰器所在的包变化时不命中
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dep, "decor.go"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := newRewriteCache(files, importcfg, "p").load(loadDir); ok {
+		t.Fatal("rewriteCache.load() should miss when a decorator package changes")
+	}
+
+	if err := clearCaches(); err != nil {
+		t.Fatal("clearCaches() error", err)
+	}
+	if _, err := os.Stat(rewriteCacheDir()); !os.IsNotExist(err) {
+		t.Fatal("clearCaches() should remove the rewrite cache")
+	}
+}
+
+func TestRewriteCacheTrim(t *testing.T) {
+	c := &rewriteCache{dir: t.TempDir()}
+	now := time.Now()
+	old, used := filepath.Join(c.dir, "old.json"), filepath.Join(c.dir, "used.json")
+	for _, file := range []string{old, used} {
+		if err := os.WriteFile(file, []byte("{}"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, now.Add(-6*24*time.Hour), now.Add(-6*24*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 命中时更新修改时间
+	markUsed(used)
+	c.trim(now)
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("rewriteCache.trim() should remove the files not used for 5 days")
+	}
+	if _, err := os.Stat(used); err != nil {
+		t.Fatal("rewriteCache.trim() should keep the used files, but got", err)
+	}
+
+	// 一天内不再清理
+	if err := os.Chtimes(used, now.Add(-6*24*time.Hour), now.Add(-6*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	c.trim(now.Add(time.Hour))
+	if _, err := os.Stat(used); err != nil {
+		t.Fatal("rewriteCache.trim() should run at most once a day, but got", err)
+	}
+	c.trim(now.Add(25 * time.Hour))
+	if _, err := os.Stat(used); !os.IsNotExist(err) {
+		t.Fatal("rewriteCache.trim() should run again after a day")
+	}
+}
+
+func TestRewriteCacheDisabled(t *testing.T) {
+	defer func(c, o string) { cmdFlag.Cache, cmdFlag.Output = c, o }(cmdFlag.Cache, cmdFlag.Output)
+	cmdFlag.Cache = "off"
+	if newRewriteCache(nil, "", "p") != nil {
+		t.Fatal("newRewriteCache() should return nil with -d.cache=off")
+	}
+	cmdFlag.Cache, cmdFlag.Output = "on", "out"
+	if newRewriteCache(nil, "", "p") != nil {
+		t.Fatal("newRewriteCache() should return nil with -d.output")
+	}
+}
+
+func TestGoFlagsForceRebuild(t *testing.T) {
+	for goflags, want := range map[string]bool{
+		"":                      false,
+		"-a":                    true,
+		"-mod=mod -a":           true,
+		"-a=true":               true,
+		"-a=false":              false,
+		"-asmflags=-S -mod=mod": false,
+	} {
+		t.Setenv("GOFLAGS", goflags)
+		if got := goFlagsForceRebuild(); got != want {
+			t.Fatalf("goFlagsForceRebuild() GOFLAGS=%q want %v, but got %v", goflags, want, got)
+		}
+	}
+}
+
+func TestImportcfgDigest(t *testing.T) {
+	// 每次构建的 $WORK 不同，按依赖的 build ID 计算的摘要相同
+	writeCfg := func(buildID string) string {
+		work := t.TempDir()
+		archive := filepath.Join(work, "_pkg_.a")
+		content := "!<arch>\n__.PKGDEF       0           0     0     644     100       `\ngo object linux amd64 go1.22\nbuild id \"" + buildID + "\"\n"
+		if err := os.WriteFile(archive, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		cfg := filepath.Join(work, "importcfg")
+		if err := os.WriteFile(cfg, []byte("# import config\npackagefile fmt="+archive+"\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	a, err := importcfgDigest(writeCfg("aaa/bbb"))
+	if err != nil {
+		t.Fatal("importcfgDigest() error", err)
+	}
+	if b, _ := importcfgDigest(writeCfg("aaa/bbb")); a != b {
+		t.Fatal("importcfgDigest() should not depend on $WORK")
+	}
+	if b, _ := importcfgDigest(writeCfg("aaa/ccc")); a == b {
+		t.Fatal("importcfgDigest() should change with the build ID of a dependency")
+	}
+	if id, err := exportBuildID(filepath.Join(filepath.Dir(writeCfg("x/y")), "_pkg_.a")); err != nil || id != "x/y" {
+		t.Fatal("exportBuildID() want x/y, but got", id, err)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// 装饰器上的 //go:decor-lint signature: {in: ["context.Context", "..."], out: ["error"]} 限制它能装饰的目标的签名，
+// 例如只装饰第一个参数为 context.Context 、返回 error 的函数。
+//
+// in 和 out 分别按顺序列出目标的参数和返回值的类型（不包括方法的接收者），省略时不限制，[] 表示没有参数（返回值）。
+// 列表的最后一个元素可以是 "..." ，匹配其余任意个参数（返回值）。类型按源码比较：包名使用包的默认名称，
+// 目标文件中导入包时使用的别名不影响比较；可变参数 ...T 记为 []T 。
+//
+// 值为函数的变量只在声明了函数类型时检查。
+
+// 匹配其余任意个参数或返回值
+const signatureLintRest = "..."
+
+// 解析后的 signature 规则，in 、out 为 nil 时不限制
+type signatureLint struct {
+	in, out []string
+}
+
+// 解析 signature: {in: [...], out: [...]}
+func parseSignatureLint(s string) (*signatureLint, error) {
+	exprList, err := parseDecorParameterStringToExprList(strings.TrimPrefix(s, "signature: "))
+	if err != nil {
+		return nil, errLintSyntaxError
+	}
+	sl := &signatureLint{}
+	for _, expr := range exprList {
+		kv, ok := expr.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, errLintSyntaxError
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return nil, errLintSyntaxError
+		}
+		var list *[]string
+		switch key.Name {
+		case "in":
+			list = &sl.in
+		case "out":
+			list = &sl.out
+		default:
+			return nil, errors.New("lint signature only allows the keys in and out: " + s)
+		}
+		if *list != nil {
+			return nil, errors.New("lint signature key " + key.Name + " is repeated: " + s)
+		}
+		types, err := signatureLintTypes(kv.Value)
+		if err != nil {
+			return nil, errors.New("lint signature " + key.Name + " " + err.Error() + ": " + s)
+		}
+		*list = types
+	}
+	return sl, nil
+}
+
+// 解析 ["context.Context", "..."] 中的类型，返回规范化的写法
+func signatureLintTypes(v ast.Expr) ([]string, error) {
+	cl, ok := v.(*ast.CompositeLit)
+	if !ok {
+		return nil, errors.New("should be a list of types")
+	}
+	types := make([]string, 0, len(cl.Elts))
+	for i, elt := range cl.Elts {
+		lit := realBasicLit(elt)
+		if lit == nil || lit.Kind != token.STRING {
+			return nil, errors.New("should be a list of types")
+		}
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return nil, errors.New("should be a list of types")
+		}
+		if s == signatureLintRest {
+			if i != len(cl.Elts)-1 {
+				return nil, errors.New(`can only end with "..."`)
+			}
+			types = append(types, s)
+			continue
+		}
+		expr, err := parser.ParseExpr(s)
+		if err != nil {
+			return nil, errors.New("has an invalid type " + strconv.Quote(s))
+		}
+		types = append(types, typeString(expr))
+	}
+	return types, nil
+}
+
+// 装饰器 doc 上的 signature 规则，没有时返回 nil 。多条规则时使用最后一条。
+func decorSignatureLint(doc *ast.CommentGroup) (*signatureLint, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	var sl *signatureLint
+	for _, c := range doc.List {
+		s, ok := trimDecorDeclFlag(c.Text, decorLintScanFlag)
+		if !ok || !strings.HasPrefix(s, "signature: ") {
+			continue
+		}
+		var err error
+		if sl, err = parseSignatureLint(s); err != nil {
+			return nil, err
+		}
+	}
+	return sl, nil
+}
+
+// 装饰器声明了 //go:decor-lint signature 时，检查目标 target 的签名。imp 为目标所在文件的导入器，
+// 用于把类型中包的别名还原为默认名称。找不到装饰器时返回 nil ，这个错误由 checkDecorAndGetParam 报告。
+func checkDecorSignature(pkgPath, funName string, target *ast.FuncDecl, imp *importer) error {
+	_, decl, _, err := pkgILoader.findFunc(pkgPath, funName)
+	if err != nil {
+		return nil
+	}
+	sl, err := decorSignatureLint(decl.Doc)
+	if err != nil {
+		return errors.New(fmt.Sprintf("decorator %s %s", funName, err.Error()))
+	}
+	if sl == nil {
+		return nil
+	}
+	in := signatureTypes(target.Type.Params, imp)
+	out := signatureTypes(target.Type.Results, imp)
+	if (sl.in == nil || matchSignatureTypes(sl.in, in)) && (sl.out == nil || matchSignatureTypes(sl.out, out)) {
+		return nil
+	}
+	return errors.New(fmt.Sprintf("decorator %s requires the target signature %s, but got %s",
+		funName, sl.String(), signatureString(in, out)))
+}
+
+func (sl *signatureLint) String() string {
+	in, out := sl.in, sl.out
+	if in == nil {
+		in = []string{signatureLintRest}
+	}
+	if out == nil {
+		out = []string{signatureLintRest}
+	}
+	return signatureString(in, out)
+}
+
+// 形如 func(context.Context, ...) error 的签名
+func signatureString(in, out []string) string {
+	s := "func(" + strings.Join(in, ", ") + ")"
+	switch {
+	case len(out) == 1:
+		s += " " + out[0]
+	case len(out) > 1:
+		s += " (" + strings.Join(out, ", ") + ")"
+	}
+	return s
+}
+
+// want 是否匹配 got ，want 可以以 "..." 结尾
+func matchSignatureTypes(want, got []string) bool {
+	for i, w := range want {
+		if w == signatureLintRest {
+			return true
+		}
+		if i >= len(got) || w != got[i] {
+			return false
+		}
+	}
+	return len(want) == len(got)
+}
+
+// 参数或返回值列表中每一项的类型，a, b int 记为两项
+func signatureTypes(fl *ast.FieldList, imp *importer) []string {
+	types := []string{}
+	if fl == nil {
+		return types
+	}
+	for _, field := range fl.List {
+		typ := signatureTypeString(field.Type, imp)
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// 类型 expr 的写法，其中以别名导入的包使用包的默认名称，如 ctx.Context => context.Context
+func signatureTypeString(expr ast.Expr, imp *importer) string {
+	s := typeString(expr)
+	if imp == nil {
+		return s
+	}
+	// 重新解析得到一份副本，不修改目标的语法树
+	copied, err := parser.ParseExpr(s)
+	if err != nil {
+		return s
+	}
+	ast.Inspect(copied, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if path, ok := imp.importedName(x.Name); ok {
+					x.Name = importPathName(path)
+				}
+			}
+		}
+		return true
+	})
+	return typeString(copied)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestParseSignatureLint(t *testing.T) {
+	cas := []struct {
+		s       string
+		in, out []string
+	}{
+		{`signature: {in: ["context.Context", "..."], out: ["error"]}`, []string{"context.Context", "..."}, []string{"error"}},
+		{`signature: {out: ["map[string] int", "error"]}`, nil, []string{"map[string]int", "error"}},
+		{`signature: {in: []}`, []string{}, nil},
+	}
+	for i, c := range cas {
+		sl, err := parseSignatureLint(c.s)
+		if err != nil {
+			t.Fatalf("cas[%d] parseSignatureLint(%s) error %v", i, c.s, err)
+		}
+		if !reflect.DeepEqual(sl.in, c.in) || !reflect.DeepEqual(sl.out, c.out) {
+			t.Fatalf("cas[%d] parseSignatureLint(%s) want %v %v, but got %v %v", i, c.s, c.in, c.out, sl.in, sl.out)
+		}
+	}
+	for _, s := range []string{
+		`signature: {}x`,
+		`signature: {args: ["int"]}`,
+		`signature: {in: "int"}`,
+		`signature: {in: [1]}`,
+		`signature: {in: ["...", "int"]}`,
+		`signature: {in: ["func("]}`,
+		`signature: {in: ["int"], in: ["string"]}`,
+	} {
+		if _, err := parseSignatureLint(s); err == nil {
+			t.Fatalf("parseSignatureLint(%s) should fail", s)
+		}
+	}
+	if err := resolveLinterFromAnnotation(`signature: {out: ["error"]}`, decorArgsMap{}); err != nil {
+		t.Fatal("resolveLinterFromAnnotation() should accept signature, but got", err)
+	}
+}
+
+func TestCheckDecorSignature(t *testing.T) {
+	src := `package main
+
+import stdctx "context"
+
+func a(ctx stdctx.Context, ids ...int) error { return nil }
+func b(ctx stdctx.Context) (string, error) { return "", nil }
+func c(x, y int) error { return nil }
+func d() {}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp := newImporter(f)
+	targets := map[string]*ast.FuncDecl{}
+	for _, decl := range f.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok {
+			targets[fd.Name.Name] = fd
+		}
+	}
+	targetPkg := "github.com/dengsgo/go-decorator/cmd/decorator"
+	cas := []struct {
+		decor, target string
+		msg           string
+	}{
+		{"ctxErrOnly", "a", ""},
+		{"ctxErrOnly", "b", "decorator ctxErrOnly requires the target signature func(context.Context, ...) error, but got func(context.Context) (string, error)"},
+		{"ctxErrOnly", "c", "decorator ctxErrOnly requires the target signature func(context.Context, ...) error, but got func(int, int) error"},
+		{"noArgsOnly", "d", ""},
+		{"noArgsOnly", "a", "decorator noArgsOnly requires the target signature func() ..., but got func(context.Context, []int) error"},
+		{"memoized", "c", ""},
+		{"notExist", "c", ""},
+	}
+	for i, c := range cas {
+		err := checkDecorSignature(targetPkg, c.decor, targets[c.target], imp)
+		if (err == nil) != (c.msg == "") || (err != nil && err.Error() != c.msg) {
+			t.Fatalf("cas[%d] checkDecorSignature(%s, %s) want %q, but got %v", i, c.decor, c.target, c.msg, err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sourcemap 子命令：把 panic 等输出的栈信息中指向生成代码的位置还原为原始代码的位置。
+//
+//	decorator sourcemap [-dir dir] [file]
+//
+// compile 写入每个改写后的文件时，会在它旁边写入 <文件名>.map ，记录改写后的行号和原始文件/行号的对应关系，
+// 以及文件中被装饰的目标。栈信息从 file 读取，默认为标准输入；dir 为查找 .map 文件的目录，默认为工作目录
+// （需要 -d.clearWork=false 保留），也可以是 -d.output 指定的目录。
+
+const sourceMapExt = ".map"
+
+// 改写后的文件的 source map
+type sourceMap struct {
+	File      string             `json:"file"`      // 原始文件
+	Generated string             `json:"generated"` // 改写后的文件
+	Segments  []sourceMapSegment `json:"segments"`  // 按 Generated 升序排列
+	Targets   []sourceMapTarget  `json:"targets"`   // 文件中被装饰的目标
+}
+
+// 从改写后的第 Generated 行开始，依次对应原始文件 File 的第 Line 行，直到下一个 segment
+type sourceMapSegment struct {
+	Generated int    `json:"generated"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+}
+
+// 被装饰的目标
+type sourceMapTarget struct {
+	Func       string   `json:"func"` // 和栈信息中的函数名一致，如 main.datetime 、main.(*T).Name
+	Line       int      `json:"line"` // 在原始文件中声明的行号
+	Decorators []string `json:"decorators"`
+}
+
+// 当前编译的包中每个文件被装饰的目标，以原始文件路径为键
+var sourceMapTargets = map[string][]sourceMapTarget{}
+
+// 根据改写后的文件中的 //line 指令和 /*line*/ 指令（-d.patch 时插入，cgo 生成的文件中也有），生成改写后的文件 generated 的 source map
+func newSourceMap(file, generated string, src []byte, targets []sourceMapTarget) *sourceMap {
+	sm := &sourceMap{File: file, Generated: generated, Segments: []sourceMapSegment{}, Targets: targets}
+	if sm.Targets == nil {
+		sm.Targets = []sourceMapTarget{}
+	}
+	for i, line := range bytes.Split(src, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("//line ")) {
+			// 指令作用于它的下一行，行号从 1 开始
+			sm.addSegment(i+2, string(line[len("//line "):]))
+			continue
+		}
+		// /*line*/ 指令作用于它之后的文本，同一行中以最后一个为准
+		for {
+			k := bytes.Index(line, []byte("/*line "))
+			if k < 0 {
+				break
+			}
+			line = line[k+len("/*line "):]
+			e := bytes.Index(line, []byte("*/"))
+			if e < 0 {
+				break
+			}
+			sm.addSegment(i+1, string(line[:e]))
+			line = line[e:]
+		}
+	}
+	return sm
+}
+
+// 添加指令 directive 开始的 segment 。文件名为空时沿用上一个 segment 的文件，和编译器的处理相同
+func (sm *sourceMap) addSegment(generated int, directive string) {
+	file, n, ok := parseLineDirective(directive)
+	if !ok {
+		return
+	}
+	k := len(sm.Segments)
+	if file == "" && k > 0 {
+		file = sm.Segments[k-1].File
+	}
+	seg := sourceMapSegment{Generated: generated, File: file, Line: n}
+	if k > 0 && sm.Segments[k-1].Generated == generated {
+		sm.Segments[k-1] = seg
+		return
+	}
+	sm.Segments = append(sm.Segments, seg)
+}
+
+// //line 或 /*line*/ 指令 filename:line[:col] 中的文件名和行号，文件名可以为空
+func parseLineDirective(s string) (string, int, bool) {
+	k := strings.LastIndexByte(s, ':')
+	if k < 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(s[k+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	if j := strings.LastIndexByte(s[:k], ':'); j >= 0 {
+		if line, err := strconv.Atoi(s[j+1 : k]); err == nil {
+			return s[:j], line, true
+		}
+	}
+	return s[:k], n, true
+}
+
+// 将 source map 写入 generated 旁边的 <generated>.map
+func writeSourceMap(file, generated string, src []byte, targets []sourceMapTarget) error {
+	b, err := json.MarshalIndent(newSourceMap(file, generated, src, targets), "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(generated+sourceMapExt, b, 0666)
+}
+
+// 改写后的第 line 行对应的原始位置
+func (sm *sourceMap) position(line int) (string, int, bool) {
+	for i := len(sm.Segments) - 1; i >= 0; i-- {
+		seg := sm.Segments[i]
+		if seg.Generated <= line {
+			return seg.File, seg.Line + line - seg.Generated, true
+		}
+	}
+	return "", 0, false
+}
+
+// 读取 dir 下所有的 source map
+func loadSourceMaps(dir string) ([]*sourceMap, error) {
+	var sms []*sourceMap
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".go"+sourceMapExt) {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sm := &sourceMap{}
+		if err := json.Unmarshal(b, sm); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		sms = append(sms, sm)
+		return nil
+	})
+	return sms, err
+}
+
+func runSourcemap(args []string) error {
+	fs := flag.NewFlagSet("sourcemap", flag.ContinueOnError)
+	dir := fs.String("dir", tempDir, "directory containing the .map files, the work directory or -d.output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("usage: decorator sourcemap [-dir dir] [file]")
+	}
+	sms, err := loadSourceMaps(*dir)
+	if err != nil {
+		return err
+	}
+	if len(sms) == 0 {
+		return fmt.Errorf("no source map found in %s (build with -d.clearWork=false or -d.output)", *dir)
+	}
+	in := io.Reader(os.Stdin)
+	if fs.NArg() == 1 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	return resolveStack(os.Stdout, in, sms)
+}
+
+var (
+	// 栈信息中的位置行，如 "\t/path/main.go:12 +0x1a5"
+	stackPosRegexp = regexp.MustCompile(`^(\s+)(.+\.go):(\d+)(.*)$`)
+	// 栈信息中的函数行，如 "main.datetime.func1(...)"
+	stackFuncRegexp = regexp.MustCompile(`^(\S+)\(.*\)$`)
+	// 闭包在函数名中的后缀，如 .func1 、.1 、.gowrap1
+	stackClosureRegexp = regexp.MustCompile(`\.(func|gowrap)?\d+$`)
+)
+
+// 逐行读取栈信息 r ，还原其中的位置后写入 w ：
+// 位于改写后文件中的位置，还原为原始文件的位置；
+// 位于 decor/wrapped_code.go 中的位置，还原为被装饰的目标声明的位置，并注明使用的装饰器。
+func resolveStack(w io.Writer, r io.Reader, sms []*sourceMap) error {
+	generated := map[string]*sourceMap{}
+	targets := map[string]sourceMapTarget{}
+	files := map[string]string{}
+	for _, sm := range sms {
+		generated[sm.Generated] = sm
+		for _, t := range sm.Targets {
+			targets[t.Func] = t
+			files[t.Func] = sm.File
+		}
+	}
+	funcName := ""
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if m := stackFuncRegexp.FindStringSubmatch(line); m != nil {
+			funcName = m[1]
+		} else if m := stackPosRegexp.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[3])
+			if sm, ok := generated[m[2]]; ok {
+				if file, ln, ok := sm.position(n); ok {
+					line = fmt.Sprintf("%s%s:%d%s", m[1], file, ln, m[4])
+				}
+			} else if isWrappedCodeFile(m[2]) {
+				name := funcName
+				for stackClosureRegexp.MatchString(name) {
+					name = stackClosureRegexp.ReplaceAllString(name, "")
+				}
+				if t, ok := targets[name]; ok {
+					line = fmt.Sprintf("%s%s:%d%s (decorated by %s, generated at %s:%d)",
+						m[1], files[name], t.Line, m[4], strings.Join(t.Decorators, ", "), m[2], n)
+				}
+			}
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// 是否为 decor 包中生成代码的模板文件
+func isWrappedCodeFile(p string) bool {
+	return filepath.Base(p) == "wrapped_code.go" && filepath.Base(filepath.Dir(p)) == "decor"
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSourceMapPosition(t *testing.T) {
+	src := "//line /a/main.go:1\npackage main\n\nfunc boom() {\n//line /d/decor/wrapped_code.go:18\n\tx := 1\n//line /a/main.go:11\n\t_ = x\n}\n"
+	sm := newSourceMap("/a/main.go", "/tmp/main.go", []byte(src), nil)
+	if len(sm.Segments) != 3 {
+		t.Fatalf("newSourceMap() segments = %+v, want 3 segments", sm.Segments)
+	}
+	cas := []struct {
+		line int
+		file string
+		want int
+	}{
+		{2, "/a/main.go", 1},
+		{4, "/a/main.go", 3},
+		{6, "/d/decor/wrapped_code.go", 18},
+		{8, "/a/main.go", 11},
+		{9, "/a/main.go", 12},
+	}
+	for i, c := range cas {
+		file, line, ok := sm.position(c.line)
+		if !ok || file != c.file || line != c.want {
+			t.Fatalf("cas[%d] position(%d) = %s:%d %v, want %s:%d", i, c.line, file, line, ok, c.file, c.want)
+		}
+	}
+	if _, _, ok := sm.position(1); ok {
+		t.Fatal("position(1) should not be resolved before the first //line directive")
+	}
+}
+
+func TestSourceMapInlineDirectives(t *testing.T) {
+	// -d.patch 时生成的代码之后用 /*line*/ 指令回到原文件的位置，cgo 的指令可以省略文件名
+	src := "package main\n\nfunc boom() {\n//line /d/decor/wrapped_code.go:18\n\tx := 1\n\t/*line /a/main.go:3:14*/ _ = x\n\t/*line :20:2*/ y := 2 /*line :21:9*/ _ = y\n}\n"
+	sm := newSourceMap("/a/main.go", "/tmp/main.go", []byte(src), nil)
+	cas := []struct {
+		line int
+		file string
+		want int
+	}{
+		{5, "/d/decor/wrapped_code.go", 18},
+		{6, "/a/main.go", 3},
+		{7, "/a/main.go", 21},
+		{8, "/a/main.go", 22},
+	}
+	for i, c := range cas {
+		file, line, ok := sm.position(c.line)
+		if !ok || file != c.file || line != c.want {
+			t.Fatalf("cas[%d] position(%d) = %s:%d %v, want %s:%d", i, c.line, file, line, ok, c.file, c.want)
+		}
+	}
+}
+
+func TestParseLineDirective(t *testing.T) {
+	cas := []struct {
+		s    string
+		file string
+		line int
+		ok   bool
+	}{
+		{"/src/p.go:1", "/src/p.go", 1, true},
+		{"/src/p.go:3:1", "/src/p.go", 3, true},
+		{`C:\src\p.go:10:2`, `C:\src\p.go`, 10, true},
+		{"/src/a:b.go:3", "/src/a:b.go", 3, true},
+		{":17:21", "", 17, true},
+		{"/src/p.go", "", 0, false},
+	}
+	for _, c := range cas {
+		file, line, ok := parseLineDirective(c.s)
+		if file != c.file || line != c.line || ok != c.ok {
+			t.Fatalf("parseLineDirective(%q) got (%q, %d, %v), want (%q, %d, %v)", c.s, file, line, ok, c.file, c.line, c.ok)
+		}
+	}
+}
+
+func TestResolveStack(t *testing.T) {
+	dir := t.TempDir()
+	generated := filepath.Join(dir, "main.go")
+	src := "//line /a/main.go:1\npackage main\n//line /a/main.go:20\nfunc f() {}\n"
+	targets := []sourceMapTarget{
+		{Func: "main.boom", Line: 10, Decorators: []string{"logging", "hit"}},
+		{Func: "main.(*T).Name", Line: 30, Decorators: []string{"logging"}},
+	}
+	if err := writeSourceMap("/a/main.go", generated, []byte(src), targets); err != nil {
+		t.Fatal("writeSourceMap() error", err)
+	}
+	sms, err := loadSourceMaps(dir)
+	if err != nil || len(sms) != 1 {
+		t.Fatal("loadSourceMaps() =", sms, err)
+	}
+	trace := strings.Join([]string{
+		"goroutine 1 [running]:",
+		"main.boom.func1.1(...)",
+		"\t/a/main.go:12",
+		"main.boom.func1()",
+		"\t/d/decor/wrapped_code.go:33 +0x59",
+		"main.(*T).Name.func1()",
+		"\t/d/decor/wrapped_code.go:33 +0x10",
+		"main.other.func1()",
+		"\t/d/decor/wrapped_code.go:33 +0x20",
+		"main.f()",
+		"\t" + generated + ":4 +0x18",
+	}, "\n")
+	var out bytes.Buffer
+	if err := resolveStack(&out, strings.NewReader(trace), sms); err != nil {
+		t.Fatal("resolveStack() error", err)
+	}
+	want := strings.Join([]string{
+		"goroutine 1 [running]:",
+		"main.boom.func1.1(...)",
+		"\t/a/main.go:12",
+		"main.boom.func1()",
+		"\t/a/main.go:10 +0x59 (decorated by logging, hit, generated at /d/decor/wrapped_code.go:33)",
+		"main.(*T).Name.func1()",
+		"\t/a/main.go:30 +0x10 (decorated by logging, generated at /d/decor/wrapped_code.go:33)",
+		"main.other.func1()",
+		"\t/d/decor/wrapped_code.go:33 +0x20",
+		"main.f()",
+		"\t/a/main.go:20 +0x18",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Fatalf("resolveStack() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
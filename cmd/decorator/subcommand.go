@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dengsgo/go-decorator/cmd/logs"
+)
+
+// decorator 除了作为 -toolexec 工具被 go build 调用外，也提供了一些可以直接执行的子命令：
+//
+//	decorator bench <pkgpath>#<func>
+//	decorator diff [packages]
+//	decorator doctor
+//	decorator fix [-n] [packages]
+//	decorator lint [packages]
+//	decorator manifest [packages]
+//	decorator report [-json] [-funcs patterns] [packages]
+//	decorator sourcemap [file]
+//	decorator version
+//
+// 子命令的参数由各自的 flag.FlagSet 解析，互不影响。
+type subcommand struct {
+	usage string                    // 用法说明
+	run   func(args []string) error // 执行入口，args 不包含子命令名称
+}
+
+var subcommands = map[string]*subcommand{
+	"bench": {
+		usage: "bench [-benchtime t] <pkgpath>#<func>  compare the per-call overhead of a decorated function",
+		run:   runBench,
+	},
+	"diff": {
+		usage: "diff [packages]  print a unified diff of the decorated source of each file, default ./...",
+		run:   runDiff,
+	},
+	"doctor": {
+		usage: "doctor  check the Go toolchain, GOFLAGS -toolexec and the decor module of the current directory",
+		run:   runDoctor,
+	},
+	"fix": {
+		usage: "fix [-n] [packages]  fix missing decor imports and migrate annotation syntax in place, -n only prints the fixes, default ./...",
+		run:   runFix,
+	},
+	"lint": {
+		usage: "lint [packages]  check the //go:decor annotations without building, default ./...",
+		run:   runLint,
+	},
+	"manifest": {
+		usage: "manifest [packages]  write " + manifestFileName + " listing the decorated functions into the directory of each package, default ./...",
+		run:   runManifest,
+	},
+	"report": {
+		usage: "report [-json] [-funcs patterns] [packages]  count the decorated functions per package and decorator, and list undecorated exported functions, default ./...",
+		run:   runReport,
+	},
+	"sourcemap": {
+		usage: "sourcemap [-dir dir] [file]  resolve positions in a stack trace back to the original source",
+		run:   runSourcemap,
+	},
+	"version": {
+		usage: "version  print the version of decorator and of the decor package required by the current module",
+		run:   runVersion,
+	},
+}
+
+// 如果 args 的第一个参数是已注册的子命令，执行它并返回 true 。
+func runSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	sc, ok := subcommands[args[0]]
+	if !ok {
+		return false
+	}
+	if err := sc.run(args[1:]); err != nil {
+		logs.Error(err)
+	}
+	return true
+}
+
+// 按名称顺序输出所有子命令的用法
+func printSubcommandsUsage(w io.Writer) {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  decorator %s\n", subcommands[name].usage)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strconv"
+
+	"github.com/dengsgo/go-decorator/cmd/logs"
+)
+
+// -d.toggle 时，生成的代码在调用每个装饰器之前检查 decor.Enabled ，装饰器在运行时被关闭
+// （decor.Disable 或环境变量 GODECOR_DISABLE）时跳过它，直接调用内一层或目标函数：
+//
+//	if decor.Enabled("example.com/app.logging") {
+//		logging(AddDecor)
+//	} else {
+//		AddDecor.TargetDo()
+//	}
+//
+// 装饰器按所在包的导入路径和函数名命名，见 toggleName 。没有关闭任何装饰器时 decor.Enabled 只有一次原子读取。
+// 被检查的装饰器不会被内联，见 inline.go 。
+
+// 装饰器 da 在 decor.Enabled 中的名称：所在包的导入路径和函数名，如 github.com/dengsgo/go-decorator/decor/std.Logging ，
+// 不同包中的同名装饰器分别开关。packageName 为当前包的导入路径，装饰器在当前包中时使用它。
+func toggleName(packageName string, da *decorAnnotation) string {
+	pkgPath := da.pkgPath
+	if pkgPath == "" {
+		pkgPath = packageName
+	}
+	names := da.splitName()
+	return pkgPath + "." + names[len(names)-1]
+}
+
+// 返回调用装饰器的代码 call ，-d.toggle 时加上 decor.Enabled 的检查，name 为 toggleName ，ctxVar 为传给装饰器的上下文变量
+func toggleCallText(pkgDecorName, name, ctxVar, call string) string {
+	if !cmdFlag.Toggle {
+		return call
+	}
+	return fmt.Sprintf("if %s.Enabled(%s) { %s } else { %s.TargetDo() }",
+		pkgDecorName, strconv.Quote(name), call, ctxVar)
+}
+
+// -d.toggle 时把生成的代码中调用装饰器的语句 stmt （genStmts[2]）放进 decor.Enabled 的检查中，
+// 生成的 if 语句使用 stmt 的位置
+func toggleStmt(pkgDecorName, name, ctxVar string, stmt ast.Stmt) ast.Stmt {
+	if !cmdFlag.Toggle {
+		return stmt
+	}
+	stmts, _, err := getStmtList(toggleCallText(pkgDecorName, name, ctxVar, "_()"))
+	if err != nil {
+		logs.Error("getStmtList err", err)
+	}
+	ifStmt := stmts[0].(*ast.IfStmt)
+	setNodePos(ifStmt, stmt.Pos())
+	ifStmt.Body.List[0] = stmt
+	return ifStmt
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestDecoratePackageToggle(t *testing.T) {
+	src := `package main
+
+import (
+	"path"
+	d "github.com/dengsgo/go-decorator/cmd/decorator"
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+//go:decor d.inlinedDecor#{label: "x"}
+func single(a, b int) int {
+	return a + b
+}
+
+//go:decor d.memoized
+//go:decor d.inlinedDecor#{label: "y"}
+func chained() {}
+
+//go:decor d.memoized
+var value = path.Base
+`
+	defer func(toggle bool) { cmdFlag.Toggle = toggle }(cmdFlag.Toggle)
+	cmdFlag.Toggle = true
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "/src/a.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := decoratePackage(fset, &astPackage{Name: "main", Files: map[string]*ast.File{"/src/a.go": f}}, "main", "")
+	if err != nil || len(updated) != 1 {
+		t.Fatal("decoratePackage() error", updated, err)
+	}
+	var buf bytes.Buffer
+	if err := printRewrittenFile(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	// 每个装饰器的调用都被检查，纯装饰器也不内联
+	for _, s := range []string{
+		`if decor.Enabled("github.com/dengsgo/go-decorator/cmd/decorator.inlinedDecor") {`,
+		`d.inlinedDecor(_decorGenIdent`,
+		`if decor.Enabled("github.com/dengsgo/go-decorator/cmd/decorator.memoized") {`,
+		`} else {`,
+		`.TargetDo()`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Fatalf("decoratePackage() with -d.toggle should contain %q:\n%s", s, out)
+		}
+	}
+	if n := strings.Count(out, "decor.Enabled("); n != 4 {
+		t.Fatalf("decoratePackage() with -d.toggle want 4 checks, but got %d:\n%s", n, out)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// go build -toolexec decorator 以 decorator [decorator 的参数] /path/to/tool [tool 的参数] 的形式调用工具链。
+// 这里找出工具链的路径，并解析 compile 和 link 的参数，不依赖工作目录的布局（b001、b002 等编号）、
+// -trimpath 和平台的路径格式。
+
+// 工具链中的工具名
+var chainToolNames = map[string]bool{
+	"asm": true, "buildid": true, "cgo": true, "compile": true, "cover": true, "link": true, "pack": true, "vet": true,
+}
+
+// compile 中需要一个值的标志，其余的标志都视为布尔标志（或以 -flag=value 的形式传值）
+var compileValueFlags = map[string]bool{
+	"asmhdr": true, "bench": true, "blockprofile": true, "buildid": true, "c": true, "coveragecfg": true,
+	"cpuprofile": true, "D": true, "d": true, "embedcfg": true, "env": true, "goversion": true, "I": true,
+	"importcfg": true, "importmap": true, "installsuffix": true, "json": true, "lang": true, "linkobj": true,
+	"memprofile": true, "memprofilerate": true, "mutexprofile": true, "o": true, "p": true, "pgoprofile": true,
+	"spectre": true, "symabis": true, "traceprofile": true, "trimpath": true,
+}
+
+// link 中需要一个值的标志
+var linkValueFlags = map[string]bool{
+	"B": true, "buildid": true, "buildmode": true, "cpuprofile": true, "E": true, "extar": true, "extld": true,
+	"extldflags": true, "fieldtrack": true, "H": true, "I": true, "importcfg": true, "installsuffix": true,
+	"k": true, "L": true, "libgcc": true, "linkmode": true, "memprofile": true, "memprofilerate": true, "o": true,
+	"pluginpath": true, "R": true, "r": true, "T": true, "tmpdir": true, "X": true,
+}
+
+// 文件系统是否不区分大小写，测试时可以修改
+var pathCaseInsensitive = runtime.GOOS == "windows"
+
+// 工具链工具的参数
+type toolArgs struct {
+	flags map[string]string // 标志的值，布尔标志为 "true" ，重复的标志保留最后一个值
+	files []string          // 标志之后的参数，相对路径已转换为绝对路径
+	index map[string]int    // files 中每一项在原始参数中的下标
+}
+
+// 按 valueFlags 解析工具的参数 args ，相对路径基于 dir 。和 flag 包一样，第一个非标志参数或 -- 之后都是文件。
+func parseToolArgs(args []string, valueFlags map[string]bool, dir string) *toolArgs {
+	ta := &toolArgs{flags: map[string]string{}, index: map[string]int{}}
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+		name := strings.TrimPrefix(arg[1:], "-")
+		if k := strings.IndexByte(name, '='); k >= 0 {
+			ta.flags[name[:k]] = name[k+1:]
+		} else if valueFlags[name] && i+1 < len(args) {
+			ta.flags[name] = args[i+1]
+			i++
+		} else {
+			ta.flags[name] = "true"
+		}
+	}
+	for ; i < len(args); i++ {
+		file := args[i]
+		if !filepath.IsAbs(file) && !isWindowsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		ta.files = append(ta.files, file)
+		ta.index[file] = i
+	}
+	return ta
+}
+
+// 返回 args 中工具链路径的下标，没有找到时返回 -1 。
+// 优先查找位于 goToolDir 中的路径；goToolDir 为空或都不匹配时，查找第一个工具名已知的参数。
+func chainToolIndex(args []string, goToolDir string) int {
+	if goToolDir != "" {
+		for i, arg := range args {
+			if hasPathPrefix(arg, goToolDir) {
+				return i
+			}
+		}
+	}
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") && chainToolNames[chainToolName(arg)] {
+			return i
+		}
+	}
+	return -1
+}
+
+// 工具链路径中的工具名，如 /go/pkg/tool/linux_amd64/compile 、C:\Go\pkg\tool\windows_amd64\compile.exe 都为 compile
+func chainToolName(p string) string {
+	if k := strings.LastIndexAny(p, `/\`); k >= 0 {
+		p = p[k+1:]
+	}
+	if ext := path.Ext(p); strings.EqualFold(ext, ".exe") {
+		p = p[:len(p)-len(ext)]
+	}
+	return p
+}
+
+// compile 的 -trimpath 参数，改写包之前由 compile 设置，见 trimCompilePath
+var compileTrimPath string
+
+// 按 compile 的 -trimpath 改写源文件的路径 file ，结果和编译后 runtime.Caller 返回的路径一致。
+// -trimpath 为分号分隔的 prefix=>replacement 规则，使用第一个匹配的规则，replacement 为空时去掉前缀，
+// 例如 go build -trimpath 时 /home/u/app/main.go 改写为 example.com/app/main.go 。
+func trimCompilePath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	for _, rule := range strings.Split(compileTrimPath, ";") {
+		prefix, replacement := rule, ""
+		if i := strings.LastIndex(rule, "=>"); i >= 0 {
+			prefix, replacement = rule[:i], rule[i+len("=>"):]
+		}
+		if prefix == "" || !hasPathPrefix(file, prefix) {
+			continue
+		}
+		rest := strings.TrimLeft(slashPath(file)[len(slashPath(prefix)):], "/")
+		switch {
+		case replacement == "":
+			return rest
+		case rest == "":
+			return replacement
+		}
+		return strings.TrimSuffix(replacement, "/") + "/" + rest
+	}
+	return filepath.ToSlash(file)
+}
+
+// 路径 p 是否为 dir 或位于 dir 中，同时接受 / 和 \ 作为分隔符
+func hasPathPrefix(p, dir string) bool {
+	p, dir = slashPath(p), slashPath(dir)
+	if dir == "." {
+		return false
+	}
+	if pathCaseInsensitive {
+		p, dir = strings.ToLower(p), strings.ToLower(dir)
+	}
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+func slashPath(p string) string {
+	return path.Clean(strings.ReplaceAll(p, `\`, "/"))
+}
+
+// 是否为 Windows 的绝对路径，如 C:\a 、C:/a 、\\host\share
+func isWindowsAbs(p string) bool {
+	if strings.HasPrefix(p, `\\`) {
+		return true
+	}
+	return len(p) >= 3 && p[1] == ':' && (p[2] == '\\' || p[2] == '/') &&
+		('a' <= p[0] && p[0] <= 'z' || 'A' <= p[0] && p[0] <= 'Z')
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseToolArgs(t *testing.T) {
+	dir := filepath.FromSlash("/work/module")
+	args := []string{
+		"-o", "$WORK/b002/_pkg_.a",
+		"-trimpath", "$WORK/b002=>;/work/module=>example.com/m",
+		"-p", "example.com/m/a",
+		"-lang=go1.18", "-complete",
+		"-D", "",
+		"--buildid", "x/y",
+		"-pack",
+		"a/a.go", filepath.FromSlash("/work/module/a/b.go"), "$WORK/b002/_cgo_gotypes.go",
+	}
+	ta := parseToolArgs(args, compileValueFlags, dir)
+	for k, v := range map[string]string{
+		"o":        "$WORK/b002/_pkg_.a",
+		"trimpath": "$WORK/b002=>;/work/module=>example.com/m",
+		"p":        "example.com/m/a",
+		"lang":     "go1.18",
+		"complete": "true",
+		"D":        "",
+		"buildid":  "x/y",
+		"pack":     "true",
+	} {
+		if got, ok := ta.flags[k]; !ok || got != v {
+			t.Fatalf("parseToolArgs() flag %s want %q, got %q", k, v, got)
+		}
+	}
+	wantFiles := []string{
+		filepath.Join(dir, "a/a.go"),
+		filepath.FromSlash("/work/module/a/b.go"),
+		filepath.Join(dir, "$WORK/b002/_cgo_gotypes.go"),
+	}
+	if strings.Join(ta.files, ",") != strings.Join(wantFiles, ",") {
+		t.Fatalf("parseToolArgs() files want %q, got %q", wantFiles, ta.files)
+	}
+	if ta.index[wantFiles[0]] != 13 || ta.index[wantFiles[1]] != 14 {
+		t.Fatalf("parseToolArgs() index not match, got %v", ta.index)
+	}
+
+	// -- 之后都是文件，即使以 - 开头
+	ta = parseToolArgs([]string{"-p", "main", "--", "-x.go"}, compileValueFlags, dir)
+	if ta.flags["p"] != "main" || len(ta.files) != 1 || ta.index[ta.files[0]] != 3 {
+		t.Fatalf("parseToolArgs() with -- not match, got %+v", ta)
+	}
+	// Windows 的绝对路径不会再拼接 dir
+	ta = parseToolArgs([]string{`C:\work\a.go`, `\\host\share\b.go`}, compileValueFlags, dir)
+	if ta.files[0] != `C:\work\a.go` || ta.files[1] != `\\host\share\b.go` {
+		t.Fatalf("parseToolArgs() windows files not match, got %q", ta.files)
+	}
+
+	// link 的 importcfg 不一定位于 b001 中
+	la := parseToolArgs([]string{
+		"-o", "$WORK/b002/exe/a.out", "-importcfg", "$WORK/b002/importcfg.link",
+		"-X=runtime.godebugDefault=x", "-buildmode=pie", "-extld=gcc", "$WORK/b002/_pkg_.a",
+	}, linkValueFlags, dir)
+	if la.flags["importcfg"] != "$WORK/b002/importcfg.link" || la.flags["buildmode"] != "pie" || len(la.files) != 1 {
+		t.Fatalf("parseToolArgs() link not match, got %+v", la)
+	}
+}
+
+func TestChainToolIndex(t *testing.T) {
+	defer func(v bool) { pathCaseInsensitive = v }(pathCaseInsensitive)
+	pathCaseInsensitive = true
+
+	cas := []struct {
+		args      []string
+		goToolDir string
+		want      int
+	}{
+		{[]string{"-d.log", "debug", "/go/pkg/tool/linux_amd64/compile", "-o", "x"}, "/go/pkg/tool/linux_amd64", 2},
+		{[]string{`c:\go\pkg\tool\windows_amd64\link.exe`, "-o", "x"}, `C:\Go\pkg\tool\windows_amd64`, 0},
+		{[]string{`C:/Go/pkg/tool/windows_amd64/compile.exe`}, `C:\Go\pkg\tool\windows_amd64\`, 0},
+		// GOTOOLDIR 不匹配（如符号链接）或为空时，按工具名查找
+		{[]string{"-d.log", "debug", "/usr/lib/go/pkg/tool/linux_amd64/asm", "-p", "x"}, "/opt/go/pkg/tool", 2},
+		{[]string{"/usr/lib/go/pkg/tool/linux_amd64/compile", "-V=full"}, "", 0},
+		{[]string{"-d.log", "debug", "/usr/bin/gcc"}, "", -1},
+		{[]string{"-compile"}, "", -1},
+	}
+	for i, c := range cas {
+		if got := chainToolIndex(c.args, c.goToolDir); got != c.want {
+			t.Fatalf("cas[%d] chainToolIndex() want %d, got %d", i, c.want, got)
+		}
+	}
+}
+
+func TestChainToolName(t *testing.T) {
+	for p, want := range map[string]string{
+		"/go/pkg/tool/linux_amd64/compile":           "compile",
+		`C:\Go\pkg\tool\windows_amd64\compile.exe`:   "compile",
+		`C:\Go\pkg\tool\windows_amd64\LINK.EXE`:      "LINK",
+		"link":                                       "link",
+		"/go/pkg/tool/linux_amd64/compile.something": "compile.something",
+	} {
+		if got := chainToolName(p); got != want {
+			t.Fatalf("chainToolName(%q) want %q, got %q", p, want, got)
+		}
+	}
+}
+
+func TestHasPathPrefix(t *testing.T) {
+	defer func(v bool) { pathCaseInsensitive = v }(pathCaseInsensitive)
+	cas := []struct {
+		p, dir          string
+		caseInsensitive bool
+		want            bool
+	}{
+		{"/a/b/c.go", "/a/b", false, true},
+		{"/a/b", "/a/b/", false, true},
+		{"/a/bc/c.go", "/a/b", false, false},
+		{"/A/b/c.go", "/a/b", false, false},
+		{`C:\Work\M\a.go`, `c:\work\m`, true, true},
+		{`C:\Work\M\a.go`, `C:/Work/M`, false, true},
+		{`C:\Work\Mx\a.go`, `C:\Work\M`, true, false},
+		{"/a/b/../c/d.go", "/a/c", false, true},
+		{"a.go", "", false, false},
+	}
+	for i, c := range cas {
+		pathCaseInsensitive = c.caseInsensitive
+		if got := hasPathPrefix(c.p, c.dir); got != c.want {
+			t.Fatalf("cas[%d] hasPathPrefix(%q, %q) want %v, got %v", i, c.p, c.dir, c.want, got)
+		}
+	}
+}
+
+func TestTrimCompilePath(t *testing.T) {
+	defer func(v string) { compileTrimPath = v }(compileTrimPath)
+	cas := []struct {
+		trimpath, file, want string
+	}{
+		{"", "/app/main.go", "/app/main.go"},
+		{"/tmp/go-build1/b001=>", "/app/main.go", "/app/main.go"},
+		{"/tmp/go-build1/b001=>;/app=>example.com/app", "/app/internal/a.go", "example.com/app/internal/a.go"},
+		{"/app=>example.com/app;/app/internal=>x", "/app/internal/a.go", "example.com/app/internal/a.go"},
+		{"/app/internal", "/app/internal/a.go", "a.go"},
+		{"/app/internal=>", "/app/internalx/a.go", "/app/internalx/a.go"},
+	}
+	for i, c := range cas {
+		compileTrimPath = c.trimpath
+		if got := trimCompilePath(filepath.FromSlash(c.file)); got != c.want {
+			t.Fatalf("cas[%d] trimCompilePath(%q) with -trimpath %q want %q, got %q", i, c.file, c.trimpath, c.want, got)
+		}
+	}
+}
+
+func TestLinkClearWork(t *testing.T) {
+	defer func(d string, clear bool, do func()) {
+		tempDir, cmdFlag.ClearWork, exitDo = d, clear, do
+	}(tempDir, cmdFlag.ClearWork, exitDo)
+	cmdFlag.ClearWork = true
+
+	cas := []struct {
+		args    []string
+		cleared bool
+	}{
+		{[]string{"-o", "$WORK/b042/exe/a.out", "-importcfg", "$WORK/b042/importcfg.link", "-buildmode=exe", "$WORK/b042/_pkg_.a"}, true},
+		{[]string{"-o", "$WORK/b001/exe/a.out", "-importcfg", "$WORK/b001/importcfg.link", "$WORK/b001/_pkg_.a"}, true},
+		{[]string{"-o", "$WORK/b001/a.so", "-importcfg", "$WORK/b001/importcfg.link", "-buildmode=c-shared", "$WORK/b001/_pkg_.a"}, false},
+		{[]string{"-o", "$WORK/b001/exe/a.out", "-buildmode=exe", "$WORK/b001/_pkg_.a"}, false},
+	}
+	for i, c := range cas {
+		tempDir = t.TempDir()
+		for _, dir := range []string{"example.com/p", pkgCacheDirName, rewriteCacheDirName} {
+			if err := os.MkdirAll(filepath.Join(tempDir, dir), 0777); err != nil {
+				t.Fatal(err)
+			}
+		}
+		exitDo = func() {}
+		link(c.args)
+		exitDo()
+		if _, err := os.Stat(filepath.Join(tempDir, "example.com")); os.IsNotExist(err) != c.cleared {
+			t.Fatalf("cas[%d] link() should clear work dir: %v", i, c.cleared)
+		}
+		// 跨构建使用的缓存保留
+		for _, dir := range []string{pkgCacheDirName, rewriteCacheDirName} {
+			if _, err := os.Stat(filepath.Join(tempDir, dir)); err != nil {
+				t.Fatalf("cas[%d] link() should keep %s: %v", i, dir, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// go build 以 compile -V=full 的输出作为工具的标识，它是编译缓存的键之一，源码和依赖不变时直接使用缓存的编译结果。
+// 改写的结果还取决于 decorator 自身、命令行参数和 decor.toml ，这里把它们的摘要追加到输出中，修改后不需要 -a 也不会使用旧的编译缓存。
+// 禁用时只追加禁用的标记，见 disable.go 。
+
+// 工具的参数是否为查询版本，即 compile -V=full
+func isToolVersionQuery(args []string) bool {
+	for _, arg := range args {
+		if arg == "-V=full" || arg == "-V" {
+			return true
+		}
+	}
+	return false
+}
+
+// 影响改写结果的输入。decorator 的 version 在开发中重新构建时不变，同时使用可执行文件的信息。
+func toolIDInputs() [][2]string {
+	return [][2]string{
+		{"version", version},
+		{"decorator", toolStamp()},
+		{"d.tags", cmdFlag.Tags},
+		{"d.output", cmdFlag.Output},
+		{"d.autoimport", strconv.FormatBool(cmdFlag.AutoImport)},
+		{"d.skipCgo", strconv.FormatBool(cmdFlag.SkipCgo)},
+		{"d.patch", strconv.FormatBool(cmdFlag.Patch)},
+		{"d.noInline", strconv.FormatBool(cmdFlag.NoInline)},
+		{"d.extlint", strconv.FormatBool(cmdFlag.ExtLint)},
+		{"d.strict", strconv.FormatBool(cmdFlag.Strict)},
+		{"d.prefix", cmdFlag.Prefix},
+		{"d.toggle", strconv.FormatBool(cmdFlag.Toggle)},
+		{"d.plugin", cmdFlag.Plugins.String()},
+		{decorConfigFileName, decorConfigDigest(projectDir)},
+	}
+}
+
+// 目录 dir 所在模块的 decor.toml 的内容的哈希，在 go.work 工作区中包括所有成员模块的，都没有时为空字符串。
+// go 命令在自己的工作目录中查询 compile -V=full ，只有这些模块中的包会被改写。
+func decorConfigDigest(dir string) string {
+	var roots []string
+	if ws, err := findWorkspace(dir); err == nil && ws != nil {
+		for _, m := range ws.modules {
+			roots = append(roots, m.Dir)
+		}
+	} else if root := moduleRoot(dir); root != "" {
+		roots = append(roots, root)
+	}
+	h := sha256.New()
+	found := false
+	for _, root := range roots {
+		b, err := os.ReadFile(filepath.Join(root, decorConfigFileName))
+		if err != nil {
+			continue
+		}
+		found = true
+		_, _ = io.WriteString(h, root+"\x00")
+		_, _ = h.Write(b)
+	}
+	if !found {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// toolIDInputs 的摘要
+func toolIDDigest(inputs [][2]string) string {
+	h := sha256.New()
+	for _, kv := range inputs {
+		_, _ = io.WriteString(h, kv[0]+"\x00"+kv[1]+"\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// 给 compile -V=full 的输出加上 decorator 的标识：禁用时为 off ，否则为 toolIDInputs 的摘要
+func toolVersion(out string) string {
+	if decoratorDisabled() {
+		return disabledToolVersion(out)
+	}
+	return markToolVersion(out, toolIDDigest(toolIDInputs()))
+}
+
+// 给 compile -V=full 的输出加上标记 mark 。正式版本以整行作为工具的标识，在行尾追加 decorator=mark ；
+// 开发版本只使用最后的 buildID=xxx ，追加到 buildID 之后。
+func markToolVersion(out, mark string) string {
+	line := strings.TrimRight(out, "\r\n")
+	if line == "" {
+		return out
+	}
+	fields := strings.Fields(line)
+	if strings.HasPrefix(fields[len(fields)-1], "buildID=") {
+		return line + "-decor" + mark + "\n"
+	}
+	return line + " decorator=" + mark + "\n"
+}
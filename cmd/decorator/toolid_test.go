@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToolVersion(t *testing.T) {
+	t.Setenv(decorEnvKey, "")
+	defer func(tags, prefix string, strict, toggle bool, plugins pluginFlag) {
+		cmdFlag.Tags, cmdFlag.Prefix, cmdFlag.Strict, cmdFlag.Toggle, cmdFlag.Plugins = tags, prefix, strict, toggle, plugins
+	}(cmdFlag.Tags, cmdFlag.Prefix, cmdFlag.Strict, cmdFlag.Toggle, cmdFlag.Plugins)
+	out := "compile version go1.22.1\n"
+	r := toolVersion(out)
+	if !strings.HasPrefix(r, "compile version go1.22.1 decorator=") || !strings.HasSuffix(r, "\n") {
+		t.Fatalf("toolVersion(%q) got %q", out, r)
+	}
+	if r2 := toolVersion(out); r2 != r {
+		t.Fatalf("toolVersion() should be stable, got %q and %q", r, r2)
+	}
+	cmdFlag.Tags = "prod"
+	if r2 := toolVersion(out); r2 == r {
+		t.Fatal("toolVersion() should change with -d.tags, got", r2)
+	}
+	r = toolVersion(out)
+	cmdFlag.Strict = true
+	if r2 := toolVersion(out); r2 == r {
+		t.Fatal("toolVersion() should change with -d.strict, got", r2)
+	}
+	r = toolVersion(out)
+	cmdFlag.Prefix = "//acme:decor"
+	if r2 := toolVersion(out); r2 == r {
+		t.Fatal("toolVersion() should change with -d.prefix, got", r2)
+	}
+	r = toolVersion(out)
+	cmdFlag.Toggle = true
+	if r2 := toolVersion(out); r2 == r {
+		t.Fatal("toolVersion() should change with -d.toggle, got", r2)
+	}
+	r = toolVersion(out)
+	cmdFlag.Plugins = pluginFlag{"auditgen -v2"}
+	if r2 := toolVersion(out); r2 == r {
+		t.Fatal("toolVersion() should change with -d.plugin, got", r2)
+	}
+	if r := toolVersion("compile version devel go1.23-abc buildID=a1b2\n"); !strings.HasPrefix(r, "compile version devel go1.23-abc buildID=a1b2-decor") {
+		t.Fatal("toolVersion() should append to buildID of a devel version, got", r)
+	}
+	t.Setenv(decorEnvKey, "off")
+	if r := toolVersion(out); r != "compile version go1.22.1 decorator=off\n" {
+		t.Fatal("toolVersion() should mark the disabled decorator, got", r)
+	}
+}
+
+func TestDecorConfigDigest(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "a", "b")
+	_ = os.MkdirAll(sub, 0777)
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if d := decorConfigDigest(sub); d != "" {
+		t.Fatal("decorConfigDigest() without decor.toml should be empty, got", d)
+	}
+	toml := filepath.Join(dir, decorConfigFileName)
+	_ = os.WriteFile(toml, []byte(`prefix = "//acme:decor"`), 0666)
+	d1 := decorConfigDigest(sub)
+	_ = os.WriteFile(toml, []byte(`prefix = "//corp:decor"`), 0666)
+	d2 := decorConfigDigest(sub)
+	if d1 == "" || d1 == d2 {
+		t.Fatalf("decorConfigDigest() should change with the content of decor.toml, got %q and %q", d1, d2)
+	}
+}
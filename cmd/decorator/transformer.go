@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"go/ast"
 	"os"
 	"os/exec"
@@ -29,6 +31,8 @@ type _packageInfo struct {
 	}
 	Match,
 	GoFiles, // Go 源文件列表
+	TestGoFiles, // 包内的测试文件列表
+	XTestGoFiles, // 外部测试包（package xxx_test）的测试文件列表
 	Imports, // TODO remove -find
 	Deps []string // TODO remove -find
 }
@@ -38,8 +42,11 @@ type _packageInfo struct {
 // 通过执行 go list -json -find 命令来获取包信息，并解析 JSON 输出。
 // 具体步骤如下：
 //   - 根据传入的 pkgPath 参数构造命令行。如果 pkgPath 不为空且不等于 "main"，则将其作为包路径传递给 go list 命令。
-//   - 使用 exec.Command 执行该命令并获取输出。
+//   - 使用 exec.Command 执行该命令并获取输出。失败时返回 go list 的错误输出，如包不在模块依赖中、违反 internal 的可见性规则等。
 //   - 将输出的 JSON 数据解析为 _packageInfo 结构体实例并返回。
+//
+// go list 在 projectDir 中执行，包的解析和 go build 一致：go.mod 的 replace 、vendor 目录、
+// 嵌套模块（子目录中有 go.mod 的包属于另一个模块，需要 require 或 replace 才能导入）都由 go list 处理。
 func getPackageInfo(pkgPath string) (*_packageInfo, error) {
 	command := []string{"go", "list", "-json", "-find"}
 	if pkgPath != "" && pkgPath != "main" {
@@ -50,6 +57,10 @@ func getPackageInfo(pkgPath string) (*_packageInfo, error) {
 	cmd.Env = os.Environ()
 	bf, err := cmd.Output()
 	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(bytes.TrimSpace(ee.Stderr)) > 0 {
+			return nil, errors.New(strings.Join(strings.Fields(string(ee.Stderr)), " "))
+		}
 		return nil, err
 	}
 	p := &_packageInfo{}
@@ -181,71 +192,8 @@ func newImporter(f *ast.File) *importer {
 				continue
 			}
 
-			// 我们从路径中提取出包名，忽略版本号或文件扩展名。
-			//  github.com/user/project/v2 => v2 ，在后面确定包名时会忽略 v2 而重置为 project
-			//	github.com/user/project/file.go => file
-			// 	github.com/user/project => project
-			//
-			// 假设 ip.Path.Value 是以下字符串：
-			//
-			//	"github.com/user/project/v2"
-			//	- strconv.Unquote 去掉引号，得到：github.com/user/project/v2
-			//	- filepath.Base(pkg) 提取最后一部分路径：v2
-			//	- filepath.Ext(pkg) 提取扩展名：""（因为没有扩展名）
-			//	- strings.TrimRight 去掉扩展名，结果：v2
-			//
-			//	"github.com/user/project/file.go"
-			//	- strconv.Unquote 去掉引号，得到：github.com/user/project/file.go
-			//	- filepath.Base(pkg) 提取最后一部分路径：file.go
-			//	- filepath.Ext(pkg) 提取扩展名：.go
-			//	- strings.TrimRight 去掉扩展名，结果：file
-			//
-			//	"github.com/user/project"
-			//	- strconv.Unquote 去掉引号，得到：github.com/user/project
-			//	- filepath.Base(pkg) 提取最后一部分路径：project
-			//	- filepath.Ext(pkg) 提取扩展名：""（因为没有扩展名）
-			//	- strings.TrimRight 去掉扩展名，结果：project
 			pkg, _ := strconv.Unquote(ip.Path.Value)
-			extName := strings.TrimRight(filepath.Base(pkg), filepath.Ext(pkg))
-
-			// 如果包路径中包含版本号并且版本号大于 1（如 v2、v3 等），则将包路径中的版本号去掉，只保留版本号之前的部分作为包的基本名称；
-			// 例如：pkg/v2 → pkg 。如果版本号是 v1 或者没有版本号，则不会进行任何更改，包的基本名称保持不变。
-			//
-			// case1: 版本号 v2
-			// 	pkg := "github.com/example/pkg/v2"
-			//	extName := "v2"
-			//
-			// 	strings.HasPrefix("v2", "v")  // 返回 true
-			// 	strings.TrimLeft("v2", "v")   // 返回 "2"
-			// 	strconv.Atoi("2")             // 返回 2, err == nil, 2 > 1, 返回 true
-			//
-			//  arr := strings.Split(pkg, "/")  // ["github.com", "example", "pkg", "v2"]
-			//	extName = arr[len(arr)-2]       // "pkg"
-			//
-			// case2: 版本号 v1
-			// 	pkg := "github.com/example/pkg/v1"
-			//	extName := "v1"
-			//
-			//	strings.HasPrefix("v1", "v")  // 返回 true
-			//	strings.TrimLeft("v1", "v")    // 返回 "1"
-			//	strconv.Atoi("1")             // 返回 1, err == nil, 1 > 1, 返回 false
-			//
-			//	匿名函数返回 false，不进入 `if` 语句块，extName 不做改变，保持为 v1
-			//
-			// case3: 没有版本号
-			//	pkg := "github.com/example/pkg"
-			//	extName := "pkg"
-			//
-			//	strings.HasPrefix("pkg", "v")  // 返回 false
-			if strings.HasPrefix(extName, "v") && func() bool {
-				v, err := strconv.Atoi(strings.TrimLeft(extName, "v"))
-				return err == nil && v > 1
-			}() {
-				arr := strings.Split(pkg, "/")
-				if len(arr) > 1 {
-					extName = arr[len(arr)-2]
-				}
-			}
+			extName := importPathName(pkg)
 
 			// 根据导入语句的不同形式决定 name 的值。这里是对常见 import 语句形式的处理：
 			//	- 无别名：直接使用包路径的最后一部分作为包名。
@@ -319,6 +267,75 @@ func newImporter(f *ast.File) *importer {
 	}
 }
 
+// 导入路径 pkg 默认的包名，即没有指定别名时文件中引用它的名称
+func importPathName(pkg string) string {
+	// 我们从路径中提取出包名，忽略版本号或文件扩展名。
+	//  github.com/user/project/v2 => v2 ，在后面确定包名时会忽略 v2 而重置为 project
+	//	github.com/user/project/file.go => file
+	// 	github.com/user/project => project
+	//
+	// 假设 ip.Path.Value 是以下字符串：
+	//
+	//	"github.com/user/project/v2"
+	//	- strconv.Unquote 去掉引号，得到：github.com/user/project/v2
+	//	- filepath.Base(pkg) 提取最后一部分路径：v2
+	//	- filepath.Ext(pkg) 提取扩展名：""（因为没有扩展名）
+	//	- strings.TrimRight 去掉扩展名，结果：v2
+	//
+	//	"github.com/user/project/file.go"
+	//	- strconv.Unquote 去掉引号，得到：github.com/user/project/file.go
+	//	- filepath.Base(pkg) 提取最后一部分路径：file.go
+	//	- filepath.Ext(pkg) 提取扩展名：.go
+	//	- strings.TrimRight 去掉扩展名，结果：file
+	//
+	//	"github.com/user/project"
+	//	- strconv.Unquote 去掉引号，得到：github.com/user/project
+	//	- filepath.Base(pkg) 提取最后一部分路径：project
+	//	- filepath.Ext(pkg) 提取扩展名：""（因为没有扩展名）
+	//	- strings.TrimRight 去掉扩展名，结果：project
+	extName := strings.TrimRight(filepath.Base(pkg), filepath.Ext(pkg))
+
+	// 如果包路径中包含版本号并且版本号大于 1（如 v2、v3 等），则将包路径中的版本号去掉，只保留版本号之前的部分作为包的基本名称；
+	// 例如：pkg/v2 → pkg 。如果版本号是 v1 或者没有版本号，则不会进行任何更改，包的基本名称保持不变。
+	//
+	// case1: 版本号 v2
+	// 	pkg := "github.com/example/pkg/v2"
+	//	extName := "v2"
+	//
+	// 	strings.HasPrefix("v2", "v")  // 返回 true
+	// 	strings.TrimLeft("v2", "v")   // 返回 "2"
+	// 	strconv.Atoi("2")             // 返回 2, err == nil, 2 > 1, 返回 true
+	//
+	//  arr := strings.Split(pkg, "/")  // ["github.com", "example", "pkg", "v2"]
+	//	extName = arr[len(arr)-2]       // "pkg"
+	//
+	// case2: 版本号 v1
+	// 	pkg := "github.com/example/pkg/v1"
+	//	extName := "v1"
+	//
+	//	strings.HasPrefix("v1", "v")  // 返回 true
+	//	strings.TrimLeft("v1", "v")    // 返回 "1"
+	//	strconv.Atoi("1")             // 返回 1, err == nil, 1 > 1, 返回 false
+	//
+	//	匿名函数返回 false，不进入 `if` 语句块，extName 不做改变，保持为 v1
+	//
+	// case3: 没有版本号
+	//	pkg := "github.com/example/pkg"
+	//	extName := "pkg"
+	//
+	//	strings.HasPrefix("pkg", "v")  // 返回 false
+	if strings.HasPrefix(extName, "v") && func() bool {
+		v, err := strconv.Atoi(strings.TrimLeft(extName, "v"))
+		return err == nil && v > 1
+	}() {
+		arr := strings.Split(pkg, "/")
+		if len(arr) > 1 {
+			extName = arr[len(arr)-2]
+		}
+	}
+	return extName
+}
+
 // 根据导入名称查询包路径
 func (i *importer) importedName(name string) (pat string, ok bool) {
 	pat, ok = i.nameMap[name]
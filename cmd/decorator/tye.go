@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/parser"
 	"go/token"
 	"go/types"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -40,6 +42,10 @@ var (
 		"float64": types.IsFloat,
 
 		"string": types.IsString,
+
+		// 列表参数，typeKind 返回元素的类型
+		"[]string": types.IsString,
+		"[]int":    types.IsInteger,
 	}
 
 	// 标记哪些比较操作符（如 gt、gte 等）是允许的，用于后续的比较验证。
@@ -52,8 +58,11 @@ var (
 )
 
 type pkgSet struct {
-	fset *token.FileSet
-	pkgs map[string]*ast.Package
+	fset    *token.FileSet
+	pkgs    map[string]*astPackage
+	dir     string // 包所在的目录
+	goMod   string // 包所在模块的 go.mod
+	partial bool   // 是否只解析了缓存中记录的文件，见 pkgcache.go
 }
 
 // 对标准库 map 做了封装
@@ -92,6 +101,17 @@ type decorAnnotation struct {
 	doc        *ast.Comment      // ast node for doc
 	name       string            // decorator function name
 	parameters map[string]string // options parameters
+	priority   int               // wrapping order, the higher the outer
+	allowMain  bool              // the decorator may decorate main and init
+
+	pkgPath    string       // package path of the decorator, empty for the current package
+	callParams []string     // arguments passed to the decorator after the context
+	typed      bool         // the decorator receives a typed context
+	typedIn    int          // number of parameters of the typed context
+	typedOut   int          // number of results of the typed context
+	inline     *inlineDecor // the body of the decorator if it can be inlined, see checkDecorInline
+	timed      bool         // the decorator calls ctx.Elapsed, the start time is recorded, see checkDecorTimed
+	chainTimed bool         // the decorator calls ctx.ChainTimings, the timings of the chain are recorded
 }
 
 func newDecorAnnotation(doc *ast.Comment, name string, parameters map[string]string) *decorAnnotation {
@@ -113,6 +133,8 @@ func (d *decorAnnotation) splitName() []string {
 //   - typ: 参数的类型，参考 decorOptionParamTypeMap 的 keys 。
 //   - required: 一个指向 requiredLinter 的指针，用于验证该参数是否符合必需的规则。
 //   - nonzero: 是否需要该参数为非零值。
+//   - format: 字符串参数的格式规则，由 pattern 、prefix 、suffix 注释设置。
+//   - relations: 和其他参数之间的约束，由 exclusive 、requires 注释设置。
 type decorArg struct {
 	index int
 	name,
@@ -120,6 +142,9 @@ type decorArg struct {
 	// decor lint rule
 	required *requiredLinter
 	nonzero  bool
+	format   *stringLinter
+	// 以这个参数开头的 exclusive 、requires 约束
+	relations []*paramRelation
 }
 
 // 根据参数的类型返回对应的 types.BasicInfo。
@@ -130,12 +155,99 @@ func (d *decorArg) typeKind() types.BasicInfo {
 	return types.IsUntyped
 }
 
+// 是否为切片类型的参数，如 []string 、[]int ，它们的值是 {e1, e2} 形式的列表
+func (d *decorArg) isSlice() bool {
+	return strings.HasPrefix(d.typ, "[]")
+}
+
+// 注解中没有传值时参数的零值。基本类型为对应的字面量；切片、指针、函数、通道、映射和接口类型为 nil ，
+// 例如 func(ctx *decor.Context, onDone func(), ch chan int) 的 onDone 、ch 可以省略。
+// 其他类型（如结构体、数组）没有可以写在生成代码中的零值，返回 false 。
+func (d *decorArg) zeroValue() (string, bool) {
+	if d.isSlice() {
+		return "nil", true
+	}
+	switch d.typeKind() {
+	case types.IsInteger:
+		return "0", true
+	case types.IsFloat:
+		return "0.0", true
+	case types.IsString:
+		return `""`, true
+	case types.IsBoolean:
+		return "false", true
+	}
+	expr, err := parser.ParseExpr(d.typ)
+	if err != nil {
+		return "", false
+	}
+	switch t := expr.(type) {
+	case *ast.StarExpr, *ast.FuncType, *ast.ChanType, *ast.MapType, *ast.InterfaceType:
+		return "nil", true
+	case *ast.Ident:
+		if t.Name == "any" || t.Name == "error" {
+			return "nil", true
+		}
+	}
+	return "", false
+}
+
+// 解析列表参数的值 {e1, e2} ，返回每个元素的字面值
+func (d *decorArg) sliceElems(value string) []string {
+	expr, err := parser.ParseExpr("[]any" + value)
+	if err != nil {
+		return nil
+	}
+	cl, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	elems := make([]string, 0, len(cl.Elts))
+	for _, elt := range cl.Elts {
+		if lit := realBasicLit(elt); lit != nil {
+			elems = append(elems, lit.Value)
+		}
+	}
+	return elems
+}
+
+// 检查列表参数和切片类型的形参是否匹配
+func (d *decorArg) passSliceType(value string) error {
+	list := strings.HasPrefix(value, "{")
+	if !list && !d.isSlice() {
+		return nil
+	}
+	if list != d.isSlice() {
+		return errors.New(fmt.Sprintf("key '%s' value '%s' doesn't match type %s", d.name, value, d.typ))
+	}
+	if _, ok := decorOptionParamTypeMap[d.typ]; !ok {
+		return errors.New("unsupported types '" + d.typ + "'")
+	}
+	for _, elem := range d.sliceElems(value) {
+		if strings.HasPrefix(elem, `"`) != (d.typeKind() == types.IsString) {
+			return errors.New(fmt.Sprintf("key '%s' value '%s' doesn't match type %s", d.name, value, d.typ))
+		}
+	}
+	return nil
+}
+
 // 根据装饰器参数的 required 规则验证参数值是否符合取值范围或合法枚举值。
+// 列表参数的每个元素都需要通过检查。
 func (d *decorArg) passRequiredLint(value string) error {
 	// 如果没有设置 `required` 规则，直接返回 nil，不做任何验证。
 	if d.required == nil {
 		return nil
 	}
+	if d.isSlice() {
+		elem := *d
+		elem.typ = d.typ[len("[]"):]
+		for _, v := range d.sliceElems(value) {
+			if err := elem.passRequiredLint(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	// 1. 检查传入的值是否在允许的枚举值中，如果不在枚举值中，返回错误信息。
 	if !d.required.inEnum(value) {
 		return errors.New(fmt.Sprintf("lint: key '%s' value '%s' can't pass lint enum", d.name, value))
@@ -179,9 +291,38 @@ func (d *decorArg) passRequiredLint(value string) error {
 	return nil
 }
 
+// 根据 pattern 、prefix 、suffix 规则检查字符串参数的值，value 为带引号的字符串字面量。
+// 列表参数的每个元素都需要通过检查。
+func (d *decorArg) passStringLint(value string) error {
+	if d.format == nil {
+		return nil
+	}
+	if d.isSlice() {
+		elem := *d
+		elem.typ = d.typ[len("[]"):]
+		for _, v := range d.sliceElems(value) {
+			if err := elem.passStringLint(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, err := strconv.Unquote(value)
+	if err != nil {
+		return errors.New(fmt.Sprintf("lint: key '%s' value '%s' is not a string", d.name, value))
+	}
+	if rule, ok := d.format.pass(s); !ok {
+		return errors.New(fmt.Sprintf("lint: key '%s' value '%s' can't pass lint %s", d.name, value, rule))
+	}
+	return nil
+}
+
 // 检查参数值是否为零，如果 nonzero 为 true，则要求参数值不能为零。
 func (d *decorArg) passNonzeroLint(value string) error {
 	isZero := func() bool {
+		if d.isSlice() {
+			return len(d.sliceElems(value)) == 0
+		}
 		switch d.typeKind() {
 		case types.IsInteger, types.IsFloat:
 			value, _ := strconv.ParseFloat(value, 64)
@@ -199,6 +340,45 @@ func (d *decorArg) passNonzeroLint(value string) error {
 	return nil
 }
 
+// 检查装饰器声明的默认值：只能是和形参类型一致的字面量，并需要通过形参上的 lint 规则。
+func (d *decorArg) passDefault(value string) error {
+	if !d.isSlice() {
+		kind := d.typeKind()
+		if kind == types.IsUntyped {
+			return errors.New(fmt.Sprintf("key '%s' of type %s can't have a default value", d.name, d.typ))
+		}
+		expr, err := parser.ParseExpr(value)
+		if err != nil {
+			return err
+		}
+		match := false
+		if ident, ok := expr.(*ast.Ident); ok {
+			if ident.Name != "true" && ident.Name != "false" {
+				return errors.New(fmt.Sprintf("key '%s' value '%s' is not a literal", d.name, value))
+			}
+			match = kind == types.IsBoolean
+		} else if lit := realBasicLit(expr); lit != nil {
+			// 整数字面量也可以作为浮点数的默认值
+			match = (lit.Kind == token.STRING && kind == types.IsString) ||
+				(lit.Kind == token.INT && (kind == types.IsInteger || kind == types.IsFloat)) ||
+				(lit.Kind == token.FLOAT && kind == types.IsFloat)
+		}
+		if !match {
+			return errors.New(fmt.Sprintf("key '%s' value '%s' doesn't match type %s", d.name, value, d.typ))
+		}
+	}
+	if err := d.passSliceType(value); err != nil {
+		return err
+	}
+	if err := d.passNonzeroLint(value); err != nil {
+		return err
+	}
+	if err := d.passRequiredLint(value); err != nil {
+		return err
+	}
+	return d.passStringLint(value)
+}
+
 // 装饰器参数的名称与 decorArg 结构体的映射。
 type decorArgsMap map[string]*decorArg
 
@@ -231,3 +411,85 @@ func (r *requiredLinter) inEnum(value string) bool {
 	}
 	return false
 }
+
+// 字符串参数的格式规则，每种规则给出多个值时满足其中一个即可，不同的规则需要同时满足：
+//   - pattern: 值匹配其中一个正则表达式。
+//   - prefix: 值以其中一个前缀开头。
+//   - suffix: 值以其中一个后缀结尾。
+type stringLinter struct {
+	pattern []*regexp.Regexp
+	prefix  []string
+	suffix  []string
+}
+
+// 检查 s 是否满足所有规则，不满足时返回第一个不满足的规则，如 prefix:["app_" "svc_"]
+func (r *stringLinter) pass(s string) (string, bool) {
+	if len(r.pattern) > 0 {
+		matched := false
+		exprs := make([]string, 0, len(r.pattern))
+		for _, re := range r.pattern {
+			matched = matched || re.MatchString(s)
+			exprs = append(exprs, re.String())
+		}
+		if !matched {
+			return fmt.Sprintf("pattern:%q", exprs), false
+		}
+	}
+	if len(r.prefix) > 0 && !anyString(r.prefix, func(v string) bool { return strings.HasPrefix(s, v) }) {
+		return fmt.Sprintf("prefix:%q", r.prefix), false
+	}
+	if len(r.suffix) > 0 && !anyString(r.suffix, func(v string) bool { return strings.HasSuffix(s, v) }) {
+		return fmt.Sprintf("suffix:%q", r.suffix), false
+	}
+	return "", true
+}
+
+func anyString(list []string, f func(string) bool) bool {
+	for _, v := range list {
+		if f(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// 参数之间的约束，记录在 exclusive 的第一个参数或 requires 的键上：
+//   - exclusive: names 中最多只能传递一个参数。
+//   - requires: 传递了 owner 时 names 中的参数都需要传递。
+type paramRelation struct {
+	rule  string
+	owner string
+	names []string
+	pos   token.Pos // go:decor-lint 注释的位置
+}
+
+// 检查注解 annotationMap 中传递的参数是否满足约束，不满足时返回错误
+func (r *paramRelation) check(annotationMap map[string]string) error {
+	given := func(name string) bool {
+		_, ok := annotationMap[name]
+		return ok
+	}
+	switch r.rule {
+	case "exclusive":
+		var got []string
+		for _, name := range r.names {
+			if given(name) {
+				got = append(got, name)
+			}
+		}
+		if len(got) > 1 {
+			return errors.New(fmt.Sprintf("lint: keys '%s' and '%s' are mutually exclusive, pass only one of %s",
+				got[0], got[1], strings.Join(r.names, ", ")))
+		}
+	case "requires":
+		if !given(r.owner) {
+			return nil
+		}
+		for _, name := range r.names {
+			if !given(name) {
+				return errors.New(fmt.Sprintf("lint: key '%s' requires key '%s', must pass them together", r.owner, name))
+			}
+		}
+	}
+	return nil
+}
@@ -17,3 +17,31 @@ func TestNewMapV(t *testing.T) {
 		t.Fatal("r.put(\"a\", \"b\") == false should be false")
 	}
 }
+
+func TestDecorArgZeroValue(t *testing.T) {
+	for typ, want := range map[string]string{
+		"int":                     "0",
+		"float64":                 "0.0",
+		"string":                  `""`,
+		"bool":                    "false",
+		"[]string":                "nil",
+		"*int":                    "nil",
+		"func()":                  "nil",
+		"func(int) (int, error)":  "nil",
+		"chan int":                "nil",
+		"<-chan int":              "nil",
+		"map[string]func() error": "nil",
+		"interface{ M() }":        "nil",
+		"any":                     "nil",
+		"error":                   "nil",
+		"[2]int":                  "",
+		"struct{}":                "",
+		"logLevel":                "",
+		"time.Duration":           "",
+	} {
+		v, ok := (&decorArg{typ: typ}).zeroValue()
+		if v != want || ok != (want != "") {
+			t.Fatalf("zeroValue() of %s want %q, but got %q %v", typ, want, v, ok)
+		}
+	}
+}
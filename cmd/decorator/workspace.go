@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dengsgo/go-decorator/cmd/logs"
+)
+
+// go.work 工作区的支持。工作区中的所有成员模块都是主模块，它们的包都需要改写，
+// 但 go list 只返回包所在的模块，compile 以模块路径作为前缀判断包是否属于当前项目。
+// 这里直接读取 go.work 和成员模块的 go.mod ，不执行 go 命令，
+// 因此对模块缓存中的依赖包（向上找不到 go.work）几乎没有额外的开销。
+
+const goWorkFileName = "go.work"
+
+// 工作区中的一个成员模块
+type workspaceModule struct {
+	Path string // 模块路径
+	Dir  string // 模块目录
+}
+
+type workspace struct {
+	file    string // go.work 的路径
+	modules []workspaceModule
+}
+
+// 查找 dir 所在的工作区，和 go 命令一样优先使用环境变量 GOWORK ，GOWORK=off 或找不到 go.work 时返回 nil
+func findWorkspace(dir string) (*workspace, error) {
+	file := os.Getenv("GOWORK")
+	switch file {
+	case "off":
+		return nil, nil
+	case "":
+		for d := dir; ; {
+			if fi, err := os.Stat(filepath.Join(d, goWorkFileName)); err == nil && !fi.IsDir() {
+				file = filepath.Join(d, goWorkFileName)
+				break
+			}
+			parent := filepath.Dir(d)
+			if parent == d {
+				return nil, nil
+			}
+			d = parent
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ws := &workspace{file: file}
+	for _, use := range parseGoWorkUses(data) {
+		if !filepath.IsAbs(use) {
+			use = filepath.Join(filepath.Dir(file), use)
+		}
+		modPath, err := readModulePath(filepath.Join(use, "go.mod"))
+		if err != nil {
+			return nil, err
+		}
+		ws.modules = append(ws.modules, workspaceModule{Path: modPath, Dir: filepath.Clean(use)})
+	}
+	return ws, nil
+}
+
+// go.work 中 use 指令的所有目录，支持 use dir 和 use ( ... ) 两种写法
+func parseGoWorkUses(data []byte) []string {
+	var uses []string
+	inBlock := false
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case line == "use (" || line == "use(":
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "use "):
+			line = strings.TrimSpace(line[len("use "):])
+		default:
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if s, err := strconv.Unquote(line); err == nil {
+			line = s
+		}
+		uses = append(uses, line)
+	}
+	return uses
+}
+
+// 读取 go.mod 中 module 指令声明的模块路径
+func readModulePath(gomod string) (string, error) {
+	data, err := os.ReadFile(gomod)
+	if err != nil {
+		return "", err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "module"))
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if s, err := strconv.Unquote(line); err == nil {
+			line = s
+		}
+		if line != "" {
+			return line, nil
+		}
+	}
+	return "", errors.New("no module directive in " + gomod)
+}
+
+// 包 importPath 是否属于工作区的某个成员模块，外部测试包 xxx_test 和 xxx 相同
+func (w *workspace) owns(importPath string) bool {
+	importPath = strings.TrimSuffix(importPath, "_test")
+	for _, m := range w.modules {
+		if importPath == m.Path || strings.HasPrefix(importPath, m.Path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 工作区根目录往往不属于任何模块，go list ./... 在这里会失败。dir 不在任何成员模块中时，
+// 把 ./... 这样的相对路径模式替换为该目录下各个成员模块的 modpath/... ，其他模式保持不变。
+func (w *workspace) expandPatterns(dir string, patterns []string) []string {
+	for _, m := range w.modules {
+		if hasPathPrefix(dir, m.Dir) {
+			return patterns
+		}
+	}
+	var r []string
+	for _, pattern := range patterns {
+		rel := strings.TrimSuffix(pattern, "/...")
+		if rel == pattern || !(rel == "." || strings.HasPrefix(rel, "./") || strings.HasPrefix(rel, "../")) {
+			r = append(r, pattern)
+			continue
+		}
+		base := filepath.Join(dir, rel)
+		for _, m := range w.modules {
+			if hasPathPrefix(m.Dir, base) {
+				r = append(r, m.Path+"/...")
+			}
+		}
+	}
+	if len(r) == 0 {
+		return patterns
+	}
+	return r
+}
+
+// 包 packageName 是否属于当前项目：以当前模块路径 projectName 为前缀，或属于 moduleDir 所在工作区的成员模块
+func ownPackage(packageName, projectName, moduleDir string) bool {
+	if strings.HasPrefix(packageName, projectName) {
+		return true
+	}
+	ws, err := findWorkspace(moduleDir)
+	if err != nil {
+		logs.Debug("find workspace fail", err)
+		return false
+	}
+	return ws != nil && ws.owns(packageName)
+}
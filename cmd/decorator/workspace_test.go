@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGoWorkUses(t *testing.T) {
+	data := `go 1.22
+
+use ./a // first
+use (
+	./b
+	"./c d"
+	// ./e
+)
+
+replace example.com/x => ./x
+`
+	want := []string{"./a", "./b", "./c d"}
+	if got := parseGoWorkUses([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseGoWorkUses() got %q, want %q", got, want)
+	}
+}
+
+func TestFindWorkspace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	root := t.TempDir()
+	files := map[string]string{
+		"go.work":        "go 1.22\n\nuse (\n\t./a\n\t./tools/b\n)\n",
+		"a/go.mod":       "module example.com/a\n\ngo 1.22\n",
+		"tools/b/go.mod": "module \"example.com/b\" // b\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := findWorkspace(filepath.Join(root, "a", "pkg"))
+	if err != nil || ws == nil {
+		t.Fatal("findWorkspace() should find the workspace", ws, err)
+	}
+	want := []workspaceModule{
+		{Path: "example.com/a", Dir: filepath.Join(root, "a")},
+		{Path: "example.com/b", Dir: filepath.Join(root, "tools", "b")},
+	}
+	if !reflect.DeepEqual(ws.modules, want) {
+		t.Fatalf("findWorkspace() modules got %+v, want %+v", ws.modules, want)
+	}
+	for path, r := range map[string]bool{
+		"example.com/a":      true,
+		"example.com/b/x/y":  true,
+		"example.com/a_test": true,
+		"example.com/ab":     false,
+		"example.com/c/main": false,
+	} {
+		if ws.owns(path) != r {
+			t.Fatalf("owns(%q) should be %v", path, r)
+		}
+	}
+
+	cas := []struct {
+		dir      string
+		patterns []string
+		r        []string
+	}{
+		{root, []string{"./..."}, []string{"example.com/a/...", "example.com/b/..."}},
+		{root, []string{"./tools/...", "example.com/x"}, []string{"example.com/b/...", "example.com/x"}},
+		{filepath.Join(root, "a"), []string{"./..."}, []string{"./..."}},
+		{root, []string{"./other/..."}, []string{"./other/..."}},
+	}
+	for i, c := range cas {
+		if r := ws.expandPatterns(c.dir, c.patterns); !reflect.DeepEqual(r, c.r) {
+			t.Fatalf("cas[%d] expandPatterns(%q) got %q, want %q", i, c.patterns, r, c.r)
+		}
+	}
+
+	t.Setenv("GOWORK", "off")
+	if ws, err := findWorkspace(root); ws != nil || err != nil {
+		t.Fatal("findWorkspace() should return nil when GOWORK=off", ws, err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// 构造函数上的 //go:decor-wrap-return 注释装饰它返回的类型的所有方法，便于依赖注入风格的代码统一装饰服务：
+//
+//	//go:decor-wrap-return logging
+//	func NewService(db *DB) (*Service, error) {
+//		return &Service{db: db}, nil
+//	}
+//
+// 等同于类型上的注释 //go:decor logging ，由 typeDecorRebuild 一起处理，methods 、exclude 、promoted 参数同样有效。
+// 第一个返回值必须是包中声明的类型 T 或 *T （别名解析为它所指的类型），不能是接口或泛型的实例化类型 Box[int] ，
+// 类型实参是构造函数自己的类型参数时（如 *Box[T] ）除外。
+// 装饰是编译期的，T 的所有值的方法都被装饰，而不只是构造函数返回的值。
+// 多个构造函数（或类型本身）上相同的注释只装饰一次。构造函数本身不被这个注释装饰。
+
+type wrapReturnComment struct {
+	fd      *ast.FuncDecl
+	comment *ast.Comment // 改写为 //go:decor 的注释，位置和原来的注释相同
+}
+
+// 收集文件 f 中函数和方法上的 //go:decor-wrap-return 注释
+func collectWrapReturns(f *ast.File) []*wrapReturnComment {
+	var wrs []*wrapReturnComment
+	visitAstDecl(f, func(fd *ast.FuncDecl) bool {
+		if fd.Doc == nil {
+			return false
+		}
+		for _, c := range fd.Doc.List {
+			if strings.HasPrefix(c.Text, decorWrapReturnFlag) {
+				wrs = append(wrs, &wrapReturnComment{
+					fd:      fd,
+					comment: &ast.Comment{Slash: c.Slash, Text: decoratorScanFlag + c.Text[len(decorWrapReturnFlag):]},
+				})
+			}
+		}
+		return false
+	})
+	return wrs
+}
+
+func (wr *wrapReturnComment) errorMsg(msg string) string {
+	return strings.TrimSpace(decorWrapReturnFlag) + " on " + wr.fd.Name.Name + ": the function " + msg
+}
+
+// tdcs 中是否已有和 tdc 相同的注释
+func hasTypeDecorComment(tdcs []*typeDecorComment, tdc *typeDecorComment) bool {
+	for _, v := range tdcs {
+		if v.comment.Text == tdc.comment.Text && v.promoted == tdc.promoted &&
+			strings.Join(v.methods, ",") == strings.Join(tdc.methods, ",") &&
+			strings.Join(v.exclude, ",") == strings.Join(tdc.exclude, ",") {
+			return true
+		}
+	}
+	return false
+}
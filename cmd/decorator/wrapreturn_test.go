@@ -0,0 +1,100 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestTypeDecorRebuildWrapReturn(t *testing.T) {
+	src := `package main
+
+//go:decor timing
+type Service struct{}
+
+type Alias = Service
+
+type Store struct{}
+
+//go:decor-wrap-return logging#{exclude: "Close"}
+//go:decor trace
+func NewService() (*Service, error) { return &Service{}, nil }
+
+//go:decor-wrap-return logging#{exclude: "Close"}
+//go:decor-wrap-return timing
+func newAlias() Alias { return Alias{} }
+
+//go:decor-wrap-return logging
+func (s *Service) Store() Store { return Store{} }
+
+func (s *Service) Get() {}
+func (s Service) Close() {}
+func (Store) Put() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err != nil {
+		t.Fatal("typeDecorRebuild() error", err)
+	}
+	// 相同的注释只装饰一次，构造函数本身不变
+	want := map[string][]string{
+		"NewService": {`//go:decor-wrap-return logging#{exclude: "Close"}`, "//go:decor trace"},
+		"newAlias":   {`//go:decor-wrap-return logging#{exclude: "Close"}`, "//go:decor-wrap-return timing"},
+		"Store":      {"//go:decor-wrap-return logging", "//go:decor timing", "//go:decor logging"}, // 它也是 Service 的方法
+		"Get":        {"//go:decor timing", "//go:decor logging"},
+		"Close":      {"//go:decor timing"},
+		"Put":        {"//go:decor logging"},
+	}
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		var got []string
+		for _, c := range fd.Doc.List {
+			got = append(got, c.Text)
+		}
+		if strings.Join(got, "\n") != strings.Join(want[fd.Name.Name], "\n") {
+			t.Fatalf("typeDecorRebuild() %s want %q, but got %q", fd.Name.Name, want[fd.Name.Name], got)
+		}
+	}
+
+	for _, c := range []struct {
+		src, err string
+	}{
+		{"func New() {}", "//go:decor-wrap-return on New: the function must return T or *T"},
+		{"func New() int { return 0 }", "must return T or *T"},
+		{"func New() *strings.Builder { return nil }", "must return T or *T"},
+		{"type I interface{ M() }\nfunc New() I { return nil }", "returns the interface I"},
+		{"type T struct{}\nfunc New() **T { return nil }", "must return T or *T"},
+		{"type B[T any] struct{}\nfunc New() *B[int] { return nil }", "returns the instantiated generic type *B[int]"},
+		{"type B[T any] struct{}\ntype I = B[int]\nfunc New() I { return I{} }", "returns the instantiated generic type B[int]"},
+		{"type B[K, V any] struct{}\nfunc New[K any]() B[K, int] { return B[K, int]{} }", "returns the instantiated generic type B[K, int]"},
+	} {
+		src := "package main\n" + strings.Replace(c.src, "func New", "//go:decor-wrap-return logging\nfunc New", 1)
+		f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("typeDecorRebuild() %q want error %q, but got %v", c.src, c.err, err)
+		}
+	}
+
+	// 类型实参是构造函数自己的类型参数时，返回的是泛型类型本身
+	for _, fn := range []string{"func New[T any]() *B[T] { return nil }", "func (b *B[T]) New() B[T] { return *b }"} {
+		src := "package main\ntype B[T any] struct{}\n//go:decor-wrap-return logging\n" + fn + "\n"
+		f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := typeDecorRebuild(&astPackage{Name: "main", Files: map[string]*ast.File{"main.go": f}}); err != nil {
+			t.Fatalf("typeDecorRebuild() %q should pass, but got %v", fn, err)
+		}
+	}
+}
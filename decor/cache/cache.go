@@ -0,0 +1,231 @@
+// Package cache provides Memoize, a decorator caching the results of the target
+// by its parameters:
+//
+//	import _ "github.com/dengsgo/go-decorator/decor/cache"
+//
+//	//go:decor cache.Memoize#{ttlMs: 60000, maxEntries: 100}
+//	func price(sku string, qty int) (float64, error) {
+//		// code...
+//	}
+//
+// A call with the same parameters as a cached call returns the cached results
+// without calling the target, and stops the call (see decor.Context.Stop).
+// Calls that panic or return a non-nil error as their last result are not cached.
+//
+// cache 包提供了以参数为键缓存目标函数返回值的装饰器 Memoize 。
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// maxKeyArgs is the maximum number of parameters (with the receiver) of the
+// targets that the default key function supports.
+const maxKeyArgs = 8
+
+// defaultMaxEntries is the size of a cache when maxEntries isn't given.
+const defaultMaxEntries = 1024
+
+// KeyFunc builds the cache key of a call from the context, the key must be
+// comparable. If ok is false, the call is not cached.
+type KeyFunc func(ctx *decor.Context) (key any, ok bool)
+
+var (
+	keysMu sync.RWMutex
+	keys   = map[string]KeyFunc{}
+)
+
+// RegisterKey registers a key function with name for MemoizeBy, for example
+// when a parameter is a pointer to a request:
+//
+//	cache.RegisterKey("userID", func(ctx *decor.Context) (any, bool) {
+//		return decor.In[*Request](ctx, 0).UserID, true
+//	})
+//
+//	//go:decor cache.MemoizeBy#{key: "userID"}
+//	func profile(r *Request) (*Profile, error) {}
+//
+// RegisterKey 注册一个名为 name 的键函数，供 MemoizeBy 使用。
+func RegisterKey(name string, fn KeyFunc) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	keys[name] = fn
+}
+
+func keyFunc(name string) KeyFunc {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	fn, ok := keys[name]
+	if !ok {
+		panic(fmt.Sprintf("decor/cache: key function %q is not registered", name))
+	}
+	return fn
+}
+
+// DefaultKey is the key function of Memoize. The key is
+// made of the receiver and the parameters of the target. The call is not
+// cached if one of them isn't comparable, or the target has more than 8 of them.
+// Pointers are compared by address, not by the value they point to.
+func DefaultKey(ctx *decor.Context) (any, bool) {
+	var key [maxKeyArgs + 1]any
+	if len(ctx.TargetIn) > maxKeyArgs {
+		return nil, false
+	}
+	key[0] = ctx.Receiver
+	for i, v := range ctx.TargetIn {
+		key[i+1] = v
+	}
+	for _, v := range key {
+		if v != nil && !reflect.TypeOf(v).Comparable() {
+			return nil, false
+		}
+	}
+	return key, true
+}
+
+// Memoize returns the cached results of the target for the same parameters:
+//
+//	//go:decor cache.Memoize#{ttlMs: 60000, maxEntries: 100}
+//
+// The results are cached for ttlMs milliseconds, forever if it is 0. A cache
+// holds at most maxEntries results (1024 if it is 0), and evicts the least
+// recently used one when it is full. The key is built by DefaultKey.
+//
+// The parameters of the target must be comparable, the compiler checks that the
+// target has no pointer, slice, map, func, chan or variadic parameter, and isn't
+// a method with a pointer receiver, use MemoizeBy for such targets. The cached
+// results are shared by the calls, don't modify them if they are reference
+// types. Concurrent calls missing the cache all call the target.
+//
+// Memoize 以参数为键缓存目标函数的返回值，命中时不调用目标函数。
+//
+//go:decor-lint comparable: true
+func Memoize(ctx *decor.Context, ttlMs, maxEntries int) {
+	memoize(ctx, DefaultKey, ttlMs, maxEntries)
+}
+
+// MemoizeBy is like Memoize, but builds the key with the key function
+// registered with RegisterKey as key, so the parameters don't have to be
+// comparable:
+//
+//	//go:decor cache.MemoizeBy#{key: "userID", ttlMs: 60000}
+//
+// MemoizeBy 和 Memoize 相同，但使用通过 RegisterKey 注册的键函数 key 生成缓存的键。
+//
+//go:decor-lint required: {key}
+//go:decor-lint nonzero: {key}
+func MemoizeBy(ctx *decor.Context, key string, ttlMs, maxEntries int) {
+	memoize(ctx, keyFunc(key), ttlMs, maxEntries)
+}
+
+func memoize(ctx *decor.Context, keyFn KeyFunc, ttlMs, maxEntries int) {
+	k, ok := keyFn(ctx)
+	if !ok {
+		ctx.TargetDo()
+		return
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	c := cacheOf(ctx)
+	if c.get(k, ctx.TargetOut, time.Now()) {
+		ctx.Stop()
+		return
+	}
+	ctx.TargetDo()
+	if err, _ := ctx.LastError(); err != nil {
+		return
+	}
+	var expires time.Time
+	if ttlMs > 0 {
+		expires = time.Now().Add(time.Duration(ttlMs) * time.Millisecond)
+	}
+	c.put(k, ctx.TargetOut, expires, maxEntries)
+}
+
+// Reset removes the cached results of all targets.
+//
+// Reset 清空所有目标函数的缓存。
+func Reset() {
+	caches.Range(func(k, _ any) bool {
+		caches.Delete(k)
+		return true
+	})
+}
+
+var caches sync.Map // map[targetKey]*cache
+
+// targetKey identifies a target by the import path of its package, the type of
+// its receiver and its name. Func of the context can't be used, decorators and
+// Invoke may replace it with a closure of their own.
+type targetKey struct {
+	pkg  string
+	recv reflect.Type
+	name string
+}
+
+// cacheOf returns the cache of the target of ctx.
+// 以包路径、接收者类型和函数名区分目标函数。
+func cacheOf(ctx *decor.Context) *cache {
+	k := targetKey{pkg: ctx.TargetPkg, recv: reflect.TypeOf(ctx.Receiver), name: ctx.TargetName}
+	c, _ := caches.LoadOrStore(k, &cache{entries: map[any]*list.Element{}, lru: list.New()})
+	return c.(*cache)
+}
+
+// cache is a LRU cache of the results of a target.
+type cache struct {
+	mu      sync.Mutex
+	entries map[any]*list.Element
+	lru     *list.List // 最近使用的在前
+}
+
+type entry struct {
+	key     any
+	out     []any
+	expires time.Time // 零值表示不过期
+}
+
+// get copies the results cached for key into out, it reports whether they
+// are found and not expired at now.
+func (c *cache) get(key any, out []any, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	e := el.Value.(*entry)
+	if !e.expires.IsZero() && !now.Before(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return false
+	}
+	c.lru.MoveToFront(el)
+	copy(out, e.out)
+	return true
+}
+
+// put caches a copy of out for key, and evicts the least recently used results
+// if there are more than max.
+func (c *cache) put(key any, out []any, expires time.Time, max int) {
+	e := &entry{key: key, out: append([]any(nil), out...), expires: expires}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > max {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.entries, el.Value.(*entry).key)
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+var calls int
+
+// target returns a function building the context of a call of
+// func name(a int, s string) (string, error) that fails if err is set.
+func target(name string, err error) func(a int, s string) *decor.Context {
+	var ctx *decor.Context
+	fn := func() {
+		calls++
+		ctx.TargetOut[0] = ctx.TargetIn[1].(string)
+		ctx.TargetOut[1] = err
+	}
+	return func(a int, s string) *decor.Context {
+		ctx = &decor.Context{
+			TargetIn:       []any{a, s},
+			TargetOut:      []any{"", nil},
+			TargetOutTypes: []string{"string", "error"},
+			TargetName:     name,
+			TargetPkg:      "example.com/a",
+			Func:           fn,
+		}
+		return ctx
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	Reset()
+	calls = 0
+	call := target("get", nil)
+	for i, s := range []string{"a", "a", "b", "a"} {
+		ctx := call(1, s)
+		Memoize(ctx, 0, 0)
+		if ctx.TargetOut[0] != s {
+			t.Fatalf("Memoize() want %s, got %v", s, ctx.TargetOut)
+		}
+		// the hits stop the call
+		if hit := i == 1 || i == 3; ctx.Stopped() != hit {
+			t.Fatalf("Memoize() call %d want Stopped %v, got %v", i, hit, ctx.Stopped())
+		}
+	}
+	if calls != 2 {
+		t.Fatal("Memoize() should call the target once for the same parameters, got", calls)
+	}
+
+	// targets with the same name share the cache, whatever their Func
+	calls = 0
+	Memoize(target("get", nil)(1, "a"), 0, 0)
+	Memoize(target("put", nil)(1, "a"), 0, 0)
+	if calls != 1 {
+		t.Fatal("Memoize() should key the cache by the target, got", calls)
+	}
+
+	Reset()
+	calls = 0
+	fail := target("get", errors.New("foo"))
+	Memoize(fail(1, "a"), 0, 0)
+	Memoize(fail(1, "a"), 0, 0)
+	if calls != 2 {
+		t.Fatal("Memoize() should not cache a failed call, got", calls)
+	}
+}
+
+func TestMemoizeTTL(t *testing.T) {
+	Reset()
+	calls = 0
+	call := target("get", nil)
+	Memoize(call(1, "a"), 10, 0)
+	Memoize(call(1, "a"), 10, 0)
+	time.Sleep(15 * time.Millisecond)
+	Memoize(call(1, "a"), 10, 0)
+	if calls != 2 {
+		t.Fatal("Memoize() should call the target again after the ttl, got", calls)
+	}
+}
+
+func TestMemoizeBy(t *testing.T) {
+	Reset()
+	calls = 0
+	RegisterKey("first", func(ctx *decor.Context) (any, bool) { return ctx.TargetIn[0], true })
+	call := target("get", nil)
+	MemoizeBy(call(1, "a"), "first", 0, 0)
+	ctx := call(1, "b")
+	MemoizeBy(ctx, "first", 0, 0)
+	if calls != 1 || ctx.TargetOut[0] != "a" {
+		t.Fatal("MemoizeBy() should use the registered key function, got", calls, ctx.TargetOut)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("MemoizeBy() should panic with an unregistered key function")
+		}
+	}()
+	MemoizeBy(call(1, "a"), "notExist", 0, 0)
+}
+
+func TestDefaultKey(t *testing.T) {
+	a, ok := DefaultKey(&decor.Context{TargetIn: []any{1, "a", nil}})
+	b, _ := DefaultKey(&decor.Context{TargetIn: []any{1, "a", nil}})
+	if !ok || a != b {
+		t.Fatal("DefaultKey() should return the same key for the same parameters")
+	}
+	if c, _ := DefaultKey(&decor.Context{TargetIn: []any{1, "b", nil}}); a == c {
+		t.Fatal("DefaultKey() should return different keys for different parameters")
+	}
+	if c, _ := DefaultKey(&decor.Context{TargetIn: []any{1, "a", nil}, Receiver: 1}); a == c {
+		t.Fatal("DefaultKey() should include the receiver")
+	}
+	if _, ok := DefaultKey(&decor.Context{TargetIn: []any{[]int{1}}}); ok {
+		t.Fatal("DefaultKey() should not build a key with a slice parameter")
+	}
+	if _, ok := DefaultKey(&decor.Context{TargetIn: make([]any, maxKeyArgs+1)}); ok {
+		t.Fatal("DefaultKey() should not build a key with too many parameters")
+	}
+}
+
+func TestCacheEvict(t *testing.T) {
+	c := cacheOf(&decor.Context{TargetName: "evict"})
+	now := time.Now()
+	c.put(1, []any{"a"}, time.Time{}, 2)
+	c.put(2, []any{"b"}, time.Time{}, 2)
+	out := []any{nil}
+	c.get(1, out, now)
+	c.put(3, []any{"c"}, time.Time{}, 2)
+	if c.get(2, out, now) || !c.get(1, out, now) || out[0] != "a" || !c.get(3, out, now) {
+		t.Fatal("cache should evict the least recently used results")
+	}
+	c.put(4, []any{"d"}, now.Add(time.Second), 2)
+	if c.get(4, out, now.Add(time.Second)) || len(c.entries) != 1 {
+		t.Fatal("cache should remove the expired results")
+	}
+}
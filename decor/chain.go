@@ -0,0 +1,169 @@
+package decor
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Chain decorates fn with decorators at runtime and returns a function of the
+// same type, without the compile-time rewrite of `-toolexec decorator`.
+// decorators[0] is the outermost layer, the same order as the `//go:decor`
+// annotations from top to bottom:
+//
+//	add := decor.Chain(func(a, b int) int { return a + b }, logging, timing)
+//	add(1, 2)
+//
+// Each call of the returned function builds a Context with the same semantics
+// as the generated code: TargetIn holds the arguments (a variadic parameter is
+// one []T element), TargetOut holds the results, Ctx is the first argument if
+// it is a context.Context, and TargetDo calls the next layer. The layers are
+// run by Invoke, the same as the generated code. Chain is nil, ChainTimings
+// are only recorded by the generated code.
+// Decorators with extra parameters can be adapted with a closure:
+//
+//	decor.Chain(fn, func(ctx *decor.Context) { hit(ctx, "msg", 10) })
+//
+// Chain panics if fn is not a non-nil function. It uses reflect, so calls are
+// slower than the generated code.
+//
+// Chain 在运行时使用 decorators 装饰 fn ，返回一个类型相同的函数，decorators[0] 为最外层。
+// Context 的语义和编译时生成的代码保持一致。
+func Chain[F any](fn F, decorators ...func(*Context)) F {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		panic("decor: Chain fn must be a non-nil function, got " + fv.Kind().String())
+	}
+	return chainLayers(fn, fv, decorators, funcName(fv))
+}
+
+// Wrap is Chain with an explicit TargetName. The generated code uses it to
+// decorate a package-level variable whose value is a function but not a
+// function literal:
+//
+//	//go:decor audit
+//	var Handler = http.HandlerFunc(serve)
+//
+// is initialized as
+//
+//	var Handler = decor.Wrap("Handler", http.HandlerFunc(serve), func(c *decor.Context) { audit(c) })
+//
+// A nil fn is returned as is, so the variable stays nil. Wrap panics if fn is
+// not a function.
+//
+// Wrap 和 Chain 相同，但 TargetName 由 name 指定，用于装饰值为函数的包级变量。
+func Wrap[F any](name string, fn F, decorators ...func(*Context)) F {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		panic("decor: Wrap " + name + " must be a function, got " + fv.Kind().String())
+	}
+	if fv.IsNil() {
+		return fn
+	}
+	return chainLayers(fn, fv, decorators, name)
+}
+
+// chainLayers drops the nil decorators and wraps fn with the rest.
+func chainLayers[F any](fn F, fv reflect.Value, decorators []func(*Context), name string) F {
+	layers := make([]func(*Context), 0, len(decorators))
+	for _, decorator := range decorators {
+		if decorator != nil {
+			layers = append(layers, decorator)
+		}
+	}
+	if len(layers) == 0 {
+		return fn
+	}
+	return chainFunc(fv, layers, name).Interface().(F)
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// chainFunc wraps inner with the decorators, a call builds one Context and runs them by Invoke.
+func chainFunc(inner reflect.Value, decorators []func(*Context), name string) reflect.Value {
+	ft := inner.Type()
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		ctx := &Context{
+			Kind:           KFunc,
+			TargetName:     name,
+			TargetIn:       make([]any, len(args)),
+			TargetOut:      make([]any, ft.NumOut()),
+			TargetInNames:  make([]string, len(args)),
+			TargetOutNames: make([]string, ft.NumOut()),
+			TargetInTypes:  make([]string, len(args)),
+			TargetOutTypes: make([]string, ft.NumOut()),
+			Start:          time.Now(),
+		}
+		// names are not available at runtime, types come from reflection
+		for i, arg := range args {
+			ctx.TargetIn[i] = arg.Interface()
+			ctx.TargetInTypes[i] = ft.In(i).String()
+		}
+		for i := range ctx.TargetOutTypes {
+			ctx.TargetOutTypes[i] = ft.Out(i).String()
+		}
+		if ft.NumIn() > 0 && ft.In(0) == contextType {
+			ctx.Ctx, _ = ctx.TargetIn[0].(context.Context)
+		}
+		for i := range ctx.TargetOut {
+			ctx.TargetOut[i] = reflect.Zero(ft.Out(i)).Interface()
+		}
+		ctx.Func = func() {
+			in := make([]reflect.Value, len(ctx.TargetIn))
+			for i, v := range ctx.TargetIn {
+				in[i] = chainValue(v, ft.In(i))
+			}
+			var out []reflect.Value
+			if ft.IsVariadic() {
+				out = inner.CallSlice(in)
+			} else {
+				out = inner.Call(in)
+			}
+			for i, v := range out {
+				ctx.TargetOut[i] = v.Interface()
+			}
+		}
+		Invoke(ctx, decorators...)
+		out := make([]reflect.Value, ft.NumOut())
+		for i := range out {
+			out[i] = chainValue(ctx.TargetOut[i], ft.Out(i))
+		}
+		return out
+	})
+}
+
+// chainValue converts v to a value of type t like the type assertion `o, _ := v.(t)`
+// in the generated code, the zero value is used if v is not of type t.
+func chainValue(v any, t reflect.Type) reflect.Value {
+	if v != nil {
+		rv := reflect.ValueOf(v)
+		if rv.Type() == t {
+			return rv
+		}
+		if t.Kind() == reflect.Interface && rv.Type().Implements(t) {
+			r := reflect.New(t).Elem()
+			r.Set(rv)
+			return r
+		}
+	}
+	return reflect.Zero(t)
+}
+
+// funcName returns the name of fn without the package path,
+// for example "plus" for "github.com/a/b.plus".
+func funcName(fn reflect.Value) string {
+	f := runtime.FuncForPC(fn.Pointer())
+	if f == nil {
+		return ""
+	}
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
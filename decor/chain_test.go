@@ -0,0 +1,139 @@
+package decor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func chainPlus(a, b int) int {
+	return a + b
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	layer := func(name string) func(*Context) {
+		return func(ctx *Context) {
+			trace = append(trace, name+" in")
+			ctx.TargetDo()
+			trace = append(trace, name+" out")
+		}
+	}
+	double := func(ctx *Context) {
+		ctx.TargetIn[0] = ctx.TargetIn[0].(int) * 2
+		ctx.TargetDo()
+		if ctx.Kind != KFunc || ctx.TargetName != "chainPlus" || ctx.DoRef() != 1 {
+			t.Fatalf("Chain() context not match, got %+v", ctx)
+		}
+		ctx.TargetOut[0] = ctx.TargetOut[0].(int) + 100
+	}
+	plus := Chain(chainPlus, layer("outer"), double, nil, layer("inner"))
+	if r := plus(1, 2); r != 104 {
+		t.Fatal("Chain() plus(1, 2) want 104, but get", r)
+	}
+	if strings.Join(trace, ",") != "outer in,inner in,inner out,outer out" {
+		t.Fatal("Chain() layer order not match, get", trace)
+	}
+
+	// no decorators
+	if r := Chain(chainPlus)(1, 2); r != 3 {
+		t.Fatal("Chain() without decorators want 3, but get", r)
+	}
+}
+
+func TestChainVariadicAndInterface(t *testing.T) {
+	join := Chain(func(sep string, s ...string) (string, error) {
+		if len(s) == 0 {
+			return "", errors.New("empty")
+		}
+		return strings.Join(s, sep), nil
+	}, func(ctx *Context) {
+		if _, ok := ctx.TargetIn[1].([]string); !ok {
+			t.Fatalf("Chain() variadic TargetIn should be []string, got %T", ctx.TargetIn[1])
+		}
+		if strings.Join(ctx.TargetInTypes, ",") != "string,[]string" || strings.Join(ctx.TargetOutTypes, ",") != "string,error" ||
+			len(ctx.TargetInNames) != 2 || ctx.TargetInNames[0] != "" || len(ctx.TargetOutNames) != 2 {
+			t.Fatalf("Chain() types and names not match, got %q %q %q %q",
+				ctx.TargetInTypes, ctx.TargetOutTypes, ctx.TargetInNames, ctx.TargetOutNames)
+		}
+		ctx.TargetDo()
+	})
+	if r, err := join("-", "a", "b"); r != "a-b" || err != nil {
+		t.Fatal("Chain() join want a-b, but get", r, err)
+	}
+	if _, err := join("-"); err == nil {
+		t.Fatal("Chain() join should return error")
+	}
+
+	// the decorator skips TargetDo, results are zero values
+	skip := Chain(func() (int, error) { return 1, errors.New("x") }, func(ctx *Context) {})
+	if r, err := skip(); r != 0 || err != nil {
+		t.Fatal("Chain() skip should return zero values, but get", r, err)
+	}
+
+	// a value of the wrong type is replaced with the zero value
+	wrong := Chain(func() int { return 1 }, func(ctx *Context) {
+		ctx.TargetDo()
+		ctx.TargetOut[0] = "1"
+	})
+	if r := wrong(); r != 0 {
+		t.Fatal("Chain() wrong type should return zero value, but get", r)
+	}
+}
+
+func TestChainCtx(t *testing.T) {
+	type ctxKey struct{}
+	get := Chain(func(ctx context.Context) any {
+		return ctx.Value(ctxKey{})
+	}, func(ctx *Context) {
+		if ctx.Ctx == nil {
+			t.Fatal("Chain() Ctx should be the first argument")
+		}
+		ctx.WithValue(ctxKey{}, "v")
+		ctx.TargetDo()
+	})
+	if r := get(context.Background()); r != "v" {
+		t.Fatal("Chain() target should see the value set by WithValue, but get", r)
+	}
+}
+
+func TestChainPanic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Chain() with a nil function should panic")
+		}
+	}()
+	var fn func()
+	Chain(fn)
+}
+
+type chainHandler func(string) string
+
+func TestWrap(t *testing.T) {
+	var names []string
+	record := func(ctx *Context) {
+		names = append(names, ctx.TargetName)
+		ctx.TargetDo()
+	}
+	h := Wrap("Handler", chainHandler(strings.ToUpper), record)
+	if r := h("ok"); r != "OK" {
+		t.Fatal("Wrap() h(\"ok\") want OK, but get", r)
+	}
+	if strings.Join(names, ",") != "Handler" {
+		t.Fatal("Wrap() TargetName want Handler, but get", names)
+	}
+
+	// nil function value stays nil
+	var fn func()
+	if Wrap("fn", fn, record) != nil {
+		t.Fatal("Wrap() with a nil function should return nil")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Wrap() with a non-function should panic")
+		}
+	}()
+	Wrap("n", 1, record)
+}
@@ -0,0 +1,100 @@
+package decor
+
+import "time"
+
+// ChainState is the state shared by all layers of a decorator chain, that is a
+// target using more than one decorator:
+//
+//	//go:decor tracing   // layer 0, the outermost
+//	//go:decor logging   // layer 1
+//	//go:decor timeout   // layer 2, the innermost
+//	func work() {}
+//
+// The generated code creates one ChainState for each call of the target and
+// records the timings of every layer into it, only if a decorator of the target
+// reads ChainTimings. See Context.Chain.
+//
+// ChainState 是链式装饰中所有层共享的状态。只有目标的某个装饰器读取了 ChainTimings 时，
+// 目标函数的每次调用才会创建一个新的 ChainState 并记录每一层的耗时。
+type ChainState struct {
+	// ChainTimings holds how long each layer takes, from entering the decorator
+	// until it returns, including the inner layers. Index 0 is the outermost layer.
+	// A layer still running (for example the caller's own layer) is zero.
+	//
+	// 每一层从进入装饰器到返回的耗时（包括内层），下标 0 为最外层。尚未返回的层为 0 。
+	ChainTimings []time.Duration
+
+	// Values is the map shared by the Context.Values of all layers, it is nil
+	// until the first Context.Store of any layer.
+	// Values 是所有层的 Context.Values 共享的 map ，在任意一层第一次 Store 时分配。
+	Values map[string]any
+
+	starts  []time.Time
+	stopped int32 // 某一层调用了 Context.Stop ，原子读写
+}
+
+// NewChainState creates the state of a chain with layers decorators,
+// it is called by the generated code.
+func NewChainState(layers int) *ChainState {
+	return &ChainState{
+		ChainTimings: make([]time.Duration, layers),
+		starts:       make([]time.Time, layers),
+	}
+}
+
+// Enter records the time layer is entered and returns c, it is called by the generated code.
+func (c *ChainState) Enter(layer int) *ChainState {
+	c.starts[layer] = time.Now()
+	return c
+}
+
+// Exit records the timing of layer, it is called by the generated code.
+func (c *ChainState) Exit(layer int) {
+	c.ChainTimings[layer] = time.Since(c.starts[layer])
+}
+
+// SelfTimings returns the time spent by each layer itself, that is its timing
+// minus the timing of the next inner layer. Index 0 is the outermost layer.
+//
+// SelfTimings 返回每一层自身的耗时，即该层的耗时减去内一层的耗时。
+func (c *ChainState) SelfTimings() []time.Duration {
+	self := make([]time.Duration, len(c.ChainTimings))
+	for i, d := range c.ChainTimings {
+		self[i] = d
+		if i+1 < len(c.ChainTimings) {
+			self[i] -= c.ChainTimings[i+1]
+		}
+	}
+	return self
+}
+
+// Store saves a value with the key in Values, allocating it on first use.
+// Layers using a typed context, which has no Store, can call it through ctx.Chain.
+//
+// Store 在 Values 中保存一个值，第一次调用时分配 Values 。使用类型化上下文的层可以通过 ctx.Chain 调用。
+func (c *ChainState) Store(key string, v any) {
+	if c.Values == nil {
+		c.Values = map[string]any{}
+	}
+	c.Values[key] = v
+}
+
+// Load reads the value stored with the key, ok is false if it does not exist.
+//
+// Load 读取通过 Store 保存的值，不存在时 ok 为 false 。
+func (c *ChainState) Load(key string) (v any, ok bool) {
+	v, ok = c.Values[key]
+	return
+}
+
+// ChainTimings returns the timings of all layers of the chain d belongs to,
+// or nil if the target doesn't use more than one decorator, or the timings are
+// not recorded. See ChainState.
+//
+// ChainTimings 返回 d 所在装饰链每一层的耗时，目标函数只有一个装饰器或不记录耗时时返回 nil 。
+func (d *Context) ChainTimings() []time.Duration {
+	if d.Chain == nil {
+		return nil
+	}
+	return d.Chain.ChainTimings
+}
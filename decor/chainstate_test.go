@@ -0,0 +1,75 @@
+package decor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChainState(t *testing.T) {
+	c := NewChainState(3)
+	outer := &Context{Chain: c.Enter(0)}
+	middle := &Context{Chain: c.Enter(1)}
+	inner := &Context{Chain: c.Enter(2)}
+	time.Sleep(time.Millisecond)
+	inner.Chain.Exit(2)
+	middle.Chain.Exit(1)
+	if timings := outer.ChainTimings(); len(timings) != 3 || timings[0] != 0 || timings[1] < timings[2] || timings[2] < time.Millisecond {
+		t.Fatal("ChainTimings() before the outermost layer exits not match, get", timings)
+	}
+	outer.Chain.Exit(0)
+	for i, d := range c.SelfTimings() {
+		if d < 0 {
+			t.Fatal("SelfTimings() should not be negative, layer", i, d)
+		}
+	}
+	if (&Context{}).ChainTimings() != nil {
+		t.Fatal("ChainTimings() without chain should be nil")
+	}
+}
+
+func TestChainStateValues(t *testing.T) {
+	c := NewChainState(2)
+	outer := &Context{Chain: c.Enter(0), Values: c.Values}
+	inner := &Context{Chain: c.Enter(1), Values: c.Values}
+	outer.Store("span", 1)
+	if v, ok := LoadT[int](inner, "span"); !ok || v != 1 {
+		t.Fatal("inner layer should load the value stored by the outer layer, get", v, ok)
+	}
+	inner.Store("status", "done")
+	if v, ok := outer.Load("status"); !ok || v != "done" {
+		t.Fatal("outer layer should load the value stored by the inner layer, get", v, ok)
+	}
+	single := &Context{}
+	if _, ok := single.Load("span"); ok || single.Values != nil {
+		t.Fatal("Values of a single decorator should be nil before Store")
+	}
+
+	// 各层创建时 Values 还没有分配
+	c = NewChainState(2)
+	if c.Values != nil {
+		t.Fatal("NewChainState() Values should be nil before Store")
+	}
+	outer = &Context{Chain: c.Enter(0), Values: c.Values}
+	inner = &Context{Chain: c.Enter(1), Values: c.Values}
+	if _, ok := outer.Load("span"); ok || c.Values != nil {
+		t.Fatal("Load() should not allocate Values")
+	}
+	inner.Store("status", "done")
+	if v, ok := outer.Load("status"); !ok || v != "done" {
+		t.Fatal("outer layer should load the value stored by the inner layer before its Values is set, get", v, ok)
+	}
+	outer.Store("span", 1)
+	if v, ok := inner.Load("span"); !ok || v != 1 || len(c.Values) != 2 {
+		t.Fatal("all layers should share ChainState.Values, get", v, ok, c.Values)
+	}
+
+	// 类型化的上下文通过 Chain 读写
+	c = NewChainState(2)
+	c.Store("span", 1)
+	if v, ok := c.Load("span"); !ok || v != 1 {
+		t.Fatal("ChainState.Load() should load the value stored by ChainState.Store(), get", v, ok)
+	}
+	if v, ok := (&Context{Chain: c}).Load("span"); !ok || v != 1 {
+		t.Fatal("Context.Load() should load the value stored by ChainState.Store(), get", v, ok)
+	}
+}
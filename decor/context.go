@@ -1,5 +1,14 @@
 package decor
 
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
 // This file defines the context required for the decorator.
 //
 // If the function defined is of type func (* decor. Context), it is a decorator function,
@@ -43,8 +52,25 @@ const (
 // You can only change the value of the input and output parameters. Don't try to change
 // their type and quantity, as this will trigger runtime panic!!!
 //
+// A decorator may call TargetDo, TargetDoSafe, Stop, Stopped and DoRef from
+// goroutines it starts. DoRef and Stop are atomic, and the calls of the target
+// are serialized, because each of them writes its results into the same TargetOut.
+// The other fields are not synchronized: read TargetOut, and write TargetIn
+// and Values, only after the goroutines calling TargetDo have finished, for
+// example after sync.WaitGroup.Wait. In a chain run by Invoke, the calls of
+// TargetDo from the goroutines of an outer decorator run the inner decorators
+// one at a time.
+//
 // Context 提供了装饰器所需的所有信息，包括输入参数、输出结果、目标函数名称等。
+// 装饰器可以在自己启动的 goroutine 中调用 TargetDo 、TargetDoSafe 、Stop 、Stopped 和 DoRef ，
+// 目标函数的多次调用会依次执行；其他字段没有同步，要在调用 TargetDo 的 goroutine 都结束之后再读写。
 type Context struct {
+	// The number of times the objective function was called (doRef) and the
+	// serialization of the calls, it is the first field to be 64-bit aligned for
+	// sync/atomic on 32-bit platforms. Its mu also guards the writes of Panic and Func.
+	// 记录目标函数被调用的次数并保证依次调用，放在第一个字段以满足 32 位平台上原子操作的对齐要求。
+	calls
+
 	// Target types above and below the decorator
 	// 目标类型: 函数 or 方法
 	Kind TKind
@@ -57,35 +83,632 @@ type Context struct {
 	// 输出结果，它是一个 []any 类型，表示可以接受任意类型的返回值。
 	TargetOut []any
 
+	// The parameter and result names of the target, in the same order as
+	// TargetIn and TargetOut. The name is empty if it is unnamed or "_" in the source.
+	// 参数名和返回值名，和 TargetIn 、TargetOut 一一对应，未命名或为 "_" 时为空字符串。
+	TargetInNames,
+	TargetOutNames []string
+
+	// The parameter and result types of the target as written in the source,
+	// a variadic parameter ...T is reported as []T, the type of its value in TargetIn.
+	// 参数和返回值的类型，即源码中的写法，可变参数 ...T 记为 []T 。
+	//
+	// The names, the types and TypeParams are shared by all calls of the target,
+	// decorators must not modify them.
+	// 参数名、类型和 TypeParams 由目标的所有调用共用，装饰器不能修改。
+	TargetInTypes,
+	TargetOutTypes []string
+
+	// The type parameters of a generic target as written in the source, including
+	// those of the receiver type of a method, and the type arguments of the current
+	// call in the same order, for example ["T"] and ["int"] when Sum[int] is called.
+	// Both are nil if the target is not generic.
+	// 泛型目标的类型参数（包括方法接收者类型的类型参数）和本次调用的类型实参，一一对应，非泛型目标为 nil 。
+	TypeParams,
+	TypeArgs []string
+
 	// The function or method name of the target
 	// 目标名称
 	TargetName string
 
+	// TargetPkg, TargetFile and TargetLine locate the declaration of the target:
+	// the import path of its package ("main" for the main package), its source
+	// file and line. They are filled in at compile time, so reporting them costs
+	// nothing at run time, unlike runtime.Caller. TargetFile is the original
+	// file rather than the rewritten one, and is trimmed like the paths reported
+	// by runtime.Caller when building with -trimpath. They are empty for
+	// functions decorated by Chain or Wrap.
+	// 目标声明的位置：包的导入路径、原始文件和行号，编译时填充。
+	TargetPkg  string
+	TargetFile string
+	TargetLine int
+
 	// If Kind is 'KMethod', it is the Receiver of the target
 	// 如果目标是一个方法，这里保存该方法的接收者（即方法所属的对象）。如果目标是函数，则该字段为 nil。
 	Receiver any
 
+	// Ctx is the context.Context carried through the decorator chain, it may be nil.
+	// It is populated automatically when the first parameter of the target is a
+	// context.Context. Use SetCtx or WithValue to replace it, so the target sees
+	// the new context too. StartSpan stores the span context here.
+	// 在装饰器链中传递的 context.Context ，可能为 nil 。目标函数的第一个参数是 context.Context 时自动填充。
+	Ctx context.Context
+
+	// Start is the time the call of the target started, when the context was built
+	// before the outermost decorator runs. To keep timing free for the targets that
+	// don't need it, the generated code only records it if a decorator of the target
+	// calls Elapsed, reads Start or passes ctx on, otherwise it is zero. See Elapsed.
+	// 本次调用开始的时间。只有目标的某个装饰器调用了 Elapsed 、读取了 Start 或者把 ctx 传给了其他函数时，
+	// 生成的代码才会记录它，否则为零值。
+	Start time.Time
+
+	// Chain is the state shared by all layers when the target uses more than one
+	// decorator and one of them reads ChainTimings, it is nil for a single decorator.
+	// See ChainTimings.
+	// 链式装饰时所有层共享的状态，只有一个装饰器或没有装饰器读取 ChainTimings 时为 nil 。
+	Chain *ChainState
+
+	// Panic is the value recovered by the last TargetDoSafe call, nil if the
+	// target returned normally. Decorators can inspect it or set it, for example
+	// an outer layer can re-panic with it after giving up retrying.
+	// 最近一次 TargetDoSafe 捕获的 panic 值，目标函数正常返回时为 nil 。
+	Panic any
+
 	// The Non-parameter Packaging of the Objective Function // inner
+	// It is set by the generated code, use ReplaceFunc to replace it in a decorator.
+	// 目标函数的无参包装，由生成的代码设置，装饰器中使用 ReplaceFunc 替换。
 	Func func()
 
-	// The number of times the objective function was called
-	// 记录目标函数被调用的次数。
-	doRef int64
+	// Values holds the data shared by the decorators of the target, see Store and Load.
+	// It is nil until the first Store. When the target uses more than one
+	// decorator, all layers share the same map (ChainState.Values),
+	// so a tracing decorator can store a span that an inner logging decorator reads.
+	// An outer layer sees what inner layers stored after its TargetDo returns, and
+	// an inner layer sees what outer layers stored before calling TargetDo.
+	// 装饰器之间共享的数据，在第一次 Store 时分配；链式装饰时所有层共享同一个 map ，
+	// 外层在 TargetDo 之前写入的值内层可见，内层写入的值外层在 TargetDo 返回后可见。
+	Values map[string]any
+
+	// Whether Stop was called (1), see Stopped
+	// 是否调用过 Stop
+	stopped int32
+
+	// The chain run by Invoke: layers is set on the context of the outermost layer,
+	// outer points to it from the other layers. layer is the index of the decorator
+	// receiving this context, inner is the context of the next layer, and innerMu
+	// serializes the calls into it.
+	// 由 Invoke 执行的装饰链：最外层的 layers 为所有装饰器，其他层的 outer 指向最外层；
+	// layer 为本层装饰器的下标，inner 为内一层的上下文，innerMu 保证依次进入内一层。
+	layers  []func(*Context)
+	layer   int
+	outer   *Context
+	inner   *Context
+	innerMu sync.Mutex
 }
 
 // TargetDo : Call the target function.
 //
 // Calling this method once will automatically increment doRef by 1.
+// In a chain run by Invoke, it calls the next inner decorator instead,
+// and doRef is only incremented when the target function itself is called.
+// It can be called from several goroutines, the target is called by one of
+// them at a time, see Context.
 //
 // Any problem can trigger panic, and a good habit is to capture it
 // in the decorator function.
 func (d *Context) TargetDo() {
-	d.doRef++
+	if d.Stopped() {
+		return
+	}
+	if d.hasInnerLayer() {
+		d.nextLayer()
+		return
+	}
+	outer := d.shared()
+	n := outer.enter()
+	defer outer.exit(n)
+	if outer != d {
+		// 生成的代码通过最外层的上下文读取参数、写入返回值
+		outer.copyCallState(d)
+		defer d.copyCallState(outer)
+	}
 	d.Func()
 }
 
+// TargetDoSafe calls the target function like TargetDo, but recovers the panic
+// raised by it (or by the inner decorators). The recovered value is returned
+// and saved in Panic, both are nil if the target returned normally:
+//
+//	func retry(ctx *decor.Context, times int) {
+//		for i := 0; i < times; i++ {
+//			if ctx.TargetDoSafe() == nil {
+//				return
+//			}
+//		}
+//		panic(ctx.Panic)
+//	}
+//
+// A target calling panic(nil) is recovered as a *PanicNilError when the main
+// module's go version is older than 1.21 (it is a *runtime.PanicNilError since),
+// so panic(ctx.Panic) always panics again.
+//
+// TargetDoSafe 和 TargetDo 一样调用目标函数，但会捕获其中的 panic ，捕获的值既作为返回值也保存在 Panic 中。
+// go 1.21 之前 panic(nil) 的 recover() 返回 nil ，这时改为 *PanicNilError ，保证 panic(ctx.Panic) 会继续 panic 。
+func (d *Context) TargetDoSafe() (recovered any) {
+	d.setPanic(nil)
+	returned := false
+	defer func() {
+		recovered = recover()
+		if recovered == nil && !returned {
+			recovered = &PanicNilError{}
+		}
+		d.setPanic(recovered)
+	}()
+	d.TargetDo()
+	returned = true
+	return
+}
+
+// PanicNilError is recovered by TargetDoSafe when the target calls panic(nil)
+// and the main module's go version is older than 1.21.
+// go 1.21 之前目标函数调用 panic(nil) 时 TargetDoSafe 捕获的值。
+type PanicNilError struct{}
+
+func (*PanicNilError) Error() string { return "panic called with nil argument" }
+
+// setPanic sets Panic, TargetDoSafe may be called from several goroutines.
+func (d *Context) setPanic(v any) {
+	outer := d.shared()
+	outer.mu.Lock()
+	d.Panic = v
+	outer.mu.Unlock()
+}
+
+// Stop marks the call as short-circuited: the decorator decided that the target
+// must not be called, for example on a cache hit or an authentication failure,
+// and it usually fills TargetOut itself:
+//
+//	func cached(ctx *decor.Context) {
+//		if v, ok := cache.Get(ctx.TargetName); ok {
+//			ctx.TargetOut[0] = v
+//			ctx.Stop()
+//			return
+//		}
+//		ctx.TargetDo()
+//	}
+//
+// After Stop, TargetDo does nothing, so neither the target nor the inner
+// decorators run. Stopped reports it to every layer of the chain, an outer
+// decorator can check it after its TargetDo returns to tell that the target
+// wasn't called, for example to skip logging the results.
+//
+// Stop 标记本次调用被短路：装饰器决定不调用目标函数（例如命中缓存、鉴权失败）。之后 TargetDo 不再执行，
+// 装饰链的每一层都可以通过 Stopped 得知目标函数没有被调用。
+func (d *Context) Stop() {
+	atomic.StoreInt32(&d.shared().stopped, 1)
+	if d.Chain != nil {
+		atomic.StoreInt32(&d.Chain.stopped, 1)
+	}
+}
+
+// Stopped reports whether Stop was called by a decorator of the chain during this call.
+//
+// Stopped 返回本次调用中装饰链的某一层是否调用了 Stop 。
+func (d *Context) Stopped() bool {
+	return atomic.LoadInt32(&d.shared().stopped) != 0 || (d.Chain != nil && atomic.LoadInt32(&d.Chain.stopped) != 0)
+}
+
+// ReplaceFunc replaces the target with fn for the rest of this call and returns
+// the previous one, so a decorator for tests can stub the target or inject a
+// fault. TargetDo calls fn instead and still increments DoRef, fn sets the
+// results through TargetOut (or SetLastError), and calls the returned function
+// if it wants to run the real target:
+//
+//	func flaky(ctx *decor.Context) {
+//		var target func()
+//		target = ctx.ReplaceFunc(func() {
+//			if rand.Intn(10) == 0 {
+//				ctx.SetLastError(errors.New("injected"))
+//				return
+//			}
+//			target()
+//		})
+//		ctx.TargetDo()
+//	}
+//
+// If fn returns without calling the target, the call is stopped like by Stop,
+// so Stopped tells the other decorators that the target wasn't called, and the
+// later TargetDo of this call do nothing. In a chain run by Invoke fn is passed
+// to the inner layers like TargetIn, so they still run and the innermost one
+// calls fn. It panics if fn is nil.
+//
+// ReplaceFunc 把本次调用的目标函数替换为 fn 并返回原来的函数，用于测试中的桩函数或故障注入。
+// TargetDo 改为调用 fn ，DoRef 照常计数；fn 通过 TargetOut 设置返回值，需要时调用返回的原函数。
+// fn 没有调用原函数时，和 Stop 一样标记本次调用被短路。
+func (d *Context) ReplaceFunc(fn func()) (target func()) {
+	if fn == nil {
+		panic("decor: ReplaceFunc of " + d.TargetName + " with a nil function")
+	}
+	outer := d.shared()
+	outer.mu.Lock()
+	defer outer.mu.Unlock()
+	prev := d.Func
+	called := false // TargetDo 串行调用 Func
+	target = func() {
+		called = true
+		prev()
+	}
+	d.Func = func() {
+		called = false
+		fn()
+		if !called {
+			d.Stop()
+		}
+	}
+	return target
+}
+
+// calls serializes the calls of a target without a lock in the usual case of
+// one call at a time. doRef is the number of calls started and the ticket of the
+// last one, done the number of calls finished: a call runs when done reaches the
+// ticket before its own. Only a call finding another one still running waits on
+// cond, and only a call finishing while others wait takes mu to wake them.
+// 目标函数的调用按 doRef 的顺序依次执行。没有其他调用在执行时不加锁，只有并发调用时才在 cond 上等待。
+type calls struct {
+	doRef int64 // the first field, 64-bit aligned for sync/atomic
+	done  int64
+	mu    sync.Mutex
+	cond  *sync.Cond // created by the first call that waits
+}
+
+// enter starts a call of the target and waits for the calls started before it,
+// the returned ticket is passed to exit.
+func (c *calls) enter() int64 {
+	n := atomic.AddInt64(&c.doRef, 1)
+	if atomic.LoadInt64(&c.done) == n-1 {
+		return n
+	}
+	c.mu.Lock()
+	if c.cond == nil {
+		c.cond = sync.NewCond(&c.mu)
+	}
+	for atomic.LoadInt64(&c.done) != n-1 {
+		c.cond.Wait()
+	}
+	c.mu.Unlock()
+	return n
+}
+
+// exit finishes the call with ticket n, it must be called even if the target panicked.
+func (c *calls) exit(n int64) {
+	atomic.StoreInt64(&c.done, n)
+	if atomic.LoadInt64(&c.doRef) == n {
+		return
+	}
+	c.mu.Lock()
+	if c.cond != nil {
+		c.cond.Broadcast()
+	}
+	c.mu.Unlock()
+}
+
 // DoRef gets the number of times an anonymous wrapper class has been executed.
 // Usually, it shows the number of times TargetDo() was called in the decorator function.
+// It is safe to call it while other goroutines call TargetDo.
 func (d *Context) DoRef() int64 {
-	return d.doRef
+	return atomic.LoadInt64(&d.shared().doRef)
+}
+
+// Elapsed returns the time since Start, it is usually called after TargetDo to
+// time the target together with the inner decorators, without calling time.Now
+// in every timing decorator:
+//
+//	func timing(ctx *decor.Context) {
+//		ctx.TargetDo()
+//		log.Println(ctx.TargetName, ctx.Elapsed())
+//	}
+//
+// Start is recorded at compile time only for the targets with a decorator calling
+// Elapsed, or passing ctx to a function other than those of this package, which
+// may call it. Otherwise Elapsed returns 0. It is always recorded by Chain and Wrap.
+//
+// Elapsed 返回从 Start 开始经过的时间，通常在 TargetDo 之后调用，得到目标函数和内层装饰器的耗时。
+// 只有装饰器调用了 Elapsed ，或者把 ctx 传给了 decor 包以外的函数时才会记录 Start ，否则返回 0 。
+func (d *Context) Elapsed() time.Duration {
+	if d.Start.IsZero() {
+		return 0
+	}
+	return time.Since(d.Start)
+}
+
+// Now returns time.Now(), the generated code uses it to record Start, so the
+// file of the target doesn't need to import time.
+func Now() time.Time {
+	return time.Now()
+}
+
+// LastError returns the last result of the target if its type is error, ok is
+// false if the target has no error result. err is nil if the result is nil.
+//
+//	if err, ok := ctx.LastError(); ok && err != nil {
+//		ctx.SetLastError(fmt.Errorf("%s: %w", ctx.TargetName, err))
+//	}
+//
+// LastError 返回类型为 error 的最后一个返回值，目标函数没有 error 返回值时 ok 为 false 。
+func (d *Context) LastError() (err error, ok bool) {
+	n := len(d.TargetOut)
+	if n == 0 || len(d.TargetOutTypes) != n || d.TargetOutTypes[n-1] != "error" {
+		return nil, false
+	}
+	err, _ = d.TargetOut[n-1].(error)
+	return err, true
+}
+
+// SetLastError sets the last result of the target to err, it panics if the
+// target has no error result, see LastError.
+//
+// SetLastError 设置类型为 error 的最后一个返回值，目标函数没有 error 返回值时 panic 。
+func (d *Context) SetLastError(err error) {
+	if _, ok := d.LastError(); !ok {
+		panic(fmt.Sprintf("decor: SetLastError on %s, which has no error result", d.TargetName))
+	}
+	d.TargetOut[len(d.TargetOut)-1] = err
+}
+
+// SetCtx replaces Ctx with ctx. If the first parameter of the target is a
+// context.Context (TargetIn[0] holds one), it is replaced as well, so the
+// target is called with ctx.
+//
+// SetCtx 替换 Ctx ；如果目标函数的第一个参数是 context.Context ，同时替换 TargetIn[0] ，使目标函数收到新的 context 。
+func (d *Context) SetCtx(ctx context.Context) {
+	d.Ctx = ctx
+	if len(d.TargetIn) > 0 {
+		if _, ok := d.TargetIn[0].(context.Context); ok {
+			d.TargetIn[0] = ctx
+		}
+	}
+}
+
+// WithValue is a shortcut of SetCtx(context.WithValue(Ctx, key, val)),
+// context.Background() is used if Ctx is nil.
+//
+// WithValue 向 Ctx 中添加一个值，并通过 SetCtx 写回。
+func (d *Context) WithValue(key, val any) {
+	d.SetCtx(context.WithValue(d.context(), key, val))
+}
+
+// context returns Ctx, or context.Background() if it is nil.
+func (d *Context) context() context.Context {
+	if d.Ctx == nil {
+		return context.Background()
+	}
+	return d.Ctx
+}
+
+// Store saves a value with the key in the context, it can be read by Load later,
+// for example by another decorator in the chain.
+//
+// Store 在上下文中保存一个值，之后可以通过 Load 读取。
+func (d *Context) Store(key string, v any) {
+	if d.Values == nil && d.Chain != nil {
+		// 链式装饰时保存在 Chain 上，其他层也能读到
+		d.Chain.Store(key, v)
+		d.Values = d.Chain.Values
+		return
+	}
+	if d.Values == nil {
+		d.Values = map[string]any{}
+	}
+	d.Values[key] = v
+}
+
+// Load reads the value stored with the key, ok is false if it does not exist.
+//
+// Load 读取通过 Store 保存的值，不存在时 ok 为 false 。
+func (d *Context) Load(key string) (v any, ok bool) {
+	if d.Values == nil && d.Chain != nil {
+		// 本层创建时其他层还没有 Store
+		return d.Chain.Load(key)
+	}
+	v, ok = d.Values[key]
+	return
+}
+
+// StoreT is the typed version of Context.Store.
+//
+// StoreT 是 Store 的泛型版本。
+func StoreT[T any](d *Context, key string, v T) {
+	d.Store(key, v)
+}
+
+// LoadT is the typed version of Context.Load. ok is false if the key does not
+// exist or the value stored is not of type T.
+//
+// LoadT 是 Load 的泛型版本，值不存在或者类型不是 T 时 ok 为 false 。
+func LoadT[T any](d *Context, key string) (v T, ok bool) {
+	value, ok := d.Load(key)
+	if !ok {
+		return
+	}
+	v, ok = value.(T)
+	return
+}
+
+// InValues returns TargetIn as a slice of reflect.Value, one for each input parameter.
+// It is meant for reflection-based decorators that handle arguments uniformly.
+//
+// Every call converts all the inputs through reflect.ValueOf, which is noticeably
+// slower than reading TargetIn directly. Prefer TargetIn on hot paths.
+//
+// InValues 将 TargetIn 转换为 []reflect.Value ，每次调用都会经过反射转换，性能不如直接访问 TargetIn 。
+func (d *Context) InValues() []reflect.Value {
+	values := make([]reflect.Value, len(d.TargetIn))
+	for i, in := range d.TargetIn {
+		values[i] = reflect.ValueOf(in)
+	}
+	return values
+}
+
+// SetInValue writes v back into TargetIn[i].
+//
+// The kind of v must match the kind of the current input value, otherwise it panics,
+// because the target function would silently receive a zero value.
+// If the current input is an untyped nil, any value is accepted.
+//
+// SetInValue 将 v 写回 TargetIn[i] ，v 的 Kind 必须和原值一致，否则 panic 。
+func (d *Context) SetInValue(i int, v reflect.Value) {
+	if i < 0 || i >= len(d.TargetIn) {
+		panic(fmt.Sprintf("decor: SetInValue index %d out of range [0, %d)", i, len(d.TargetIn)))
+	}
+	if !v.IsValid() {
+		panic(fmt.Sprintf("decor: SetInValue index %d with invalid reflect.Value", i))
+	}
+	if d.TargetIn[i] != nil {
+		if want := reflect.ValueOf(d.TargetIn[i]).Kind(); want != v.Kind() {
+			panic(fmt.Sprintf("decor: SetInValue index %d kind mismatch, want %s but got %s", i, want, v.Kind()))
+		}
+	}
+	d.TargetIn[i] = v.Interface()
+}
+
+// TargetDoTee calls the target function like TargetDo, then calls shadow with
+// a copy of the same inputs and compares the outputs of both.
+// If they are not deeply equal, onDiff is called with the outputs of the
+// target (want) and of the shadow (got).
+//
+// The outputs of the target are kept in TargetOut, the shadow never affects
+// what the decorated function returns. It is useful for verifying a new
+// implementation against the current one (dark launch).
+//
+// TargetDoTee 执行目标函数和影子函数，输出不一致时调用 onDiff ，影子函数不影响目标函数的返回值。
+func (d *Context) TargetDoTee(shadow func(in []any) []any, onDiff func(want, got []any)) {
+	in := append([]any{}, d.TargetIn...)
+	d.TargetDo()
+	if shadow == nil {
+		return
+	}
+	got := shadow(in)
+	want := append([]any{}, d.TargetOut...)
+	if !reflect.DeepEqual(want, got) && onDiff != nil {
+		onDiff(want, got)
+	}
+}
+
+// Assign returns v as a value of type T. Unlike the type assertion v.(T), it also
+// accepts a v whose dynamic type is assignable to T but not identical to it, for
+// example a named slice type stored into TargetOut of a target returning []int.
+// If v can't be assigned to T, the zero value of T is returned.
+//
+// The generated code of targets marked with `//go:decor-lint assignable: true` uses
+// it to read TargetOut. It falls back to reflect when the type assertion fails, so it
+// is slower than the plain assertion.
+//
+// Assign 将 v 转换为 T 类型的值。和类型断言 v.(T) 不同，v 的动态类型只要能赋值给 T 即可，不要求完全相同。
+// 无法赋值时返回 T 的零值。
+func Assign[T any](v any) T {
+	if o, ok := v.(T); ok {
+		return o
+	}
+	var o T
+	if v == nil {
+		return o
+	}
+	rv, ov := reflect.ValueOf(v), reflect.ValueOf(&o).Elem()
+	if rv.Type().AssignableTo(ov.Type()) {
+		ov.Set(rv)
+	}
+	return o
+}
+
+// In returns TargetIn[i] as a value of type T, the declared type of the i-th
+// parameter of the target, instead of a hand-written assertion such as
+// ctx.TargetIn[0].(int). It panics with a message naming the target if i is
+// out of range or the parameter isn't of type T. A nil parameter of an
+// interface, pointer, slice, map, chan or func type returns the zero value.
+//
+//	n := decor.In[int](ctx, 0)
+//
+// In 返回 T 类型的第 i 个参数，T 为参数声明的类型。i 越界或参数不是 T 类型时 panic 。
+func In[T any](d *Context, i int) T {
+	return typedArg[T](d, "In", d.TargetIn, i)
+}
+
+// Out returns TargetOut[i] as a value of type T, like In does for the
+// parameters. Read the results after TargetDo, before it they hold zero values.
+//
+// Out 返回 T 类型的第 i 个返回值，和 In 一样检查 i 的范围和类型。
+func Out[T any](d *Context, i int) T {
+	return typedArg[T](d, "Out", d.TargetOut, i)
+}
+
+// SetIn sets TargetIn[i] to v. Call it before TargetDo to change the
+// argument the target receives. It panics if i is out of range, or if T is
+// a concrete type different from the type of the current value, because the
+// target would silently receive a zero value. Use the declared type of the
+// parameter as T, for example SetIn[error] for an error parameter.
+//
+// SetIn 在 TargetDo 之前修改第 i 个参数。i 越界，或 T 是具体类型但和当前值的类型不同时 panic ，
+// T 应为参数声明的类型。
+func SetIn[T any](d *Context, i int, v T) {
+	setTypedArg(d, "SetIn", d.TargetIn, i, v)
+}
+
+// SetOut sets TargetOut[i] to v. Call it after TargetDo to change the
+// result returned to the caller. It checks i and T like SetIn.
+//
+//	if decor.Out[int](ctx, 0) < 0 {
+//		decor.SetOut(ctx, 0, 0)
+//	}
+//
+// SetOut 在 TargetDo 之后修改第 i 个返回值，和 SetIn 一样检查 i 的范围和类型。
+func SetOut[T any](d *Context, i int, v T) {
+	setTypedArg(d, "SetOut", d.TargetOut, i, v)
+}
+
+func typedArg[T any](d *Context, fn string, values []any, i int) T {
+	if i < 0 || i >= len(values) {
+		panic(fmt.Sprintf("decor: %s index %d out of range [0, %d) of %s", fn, i, len(values), d.TargetName))
+	}
+	v, ok := values[i].(T)
+	if !ok && values[i] != nil {
+		panic(fmt.Sprintf("decor: %s[%s] index %d of %s holds %T", fn, TypeName[T](), i, d.TargetName, values[i]))
+	}
+	return v
+}
+
+func setTypedArg[T any](d *Context, fn string, values []any, i int, v T) {
+	if i < 0 || i >= len(values) {
+		panic(fmt.Sprintf("decor: %s index %d out of range [0, %d) of %s", fn, i, len(values), d.TargetName))
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if values[i] != nil && t.Kind() != reflect.Interface && reflect.TypeOf(values[i]) != t {
+		panic(fmt.Sprintf("decor: %s[%s] index %d of %s holds %T", fn, t, i, d.TargetName, values[i]))
+	}
+	values[i] = v
+}
+
+// ReceiverAs returns the receiver of a method target as a value of type T. The
+// receiver is the pointer for a pointer receiver and a copy for a value receiver,
+// ReceiverAs[V] also accepts a non-nil *V receiver and returns a copy of the value
+// it points to, so a decorator can read a receiver of type V either way. ok is
+// false if the target is a function or the receiver isn't of type T.
+//
+// ReceiverAs 返回 T 类型的接收者，接收者为 *V 时 ReceiverAs[V] 返回它指向的值的副本。
+// 目标是函数或接收者不是 T 类型时 ok 为 false 。
+func ReceiverAs[T any](d *Context) (r T, ok bool) {
+	if r, ok = d.Receiver.(T); ok {
+		return r, true
+	}
+	if p, ok := d.Receiver.(*T); ok && p != nil {
+		return *p, true
+	}
+	return r, false
+}
+
+// TypeName returns the name of the type T, such as "int", "[]string" or
+// "main.Point". The generated code of generic targets uses it to fill TypeArgs.
+//
+// TypeName 返回类型 T 的名称，泛型目标生成的代码用它填充 TypeArgs 。
+func TypeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
 }
@@ -1,6 +1,15 @@
 package decor
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestContext_DoRef(t *testing.T) {
 	ctx := &Context{
@@ -36,3 +45,462 @@ func TestContext_TargetDo(t *testing.T) {
 		t.Fatal("s want `TargetDo()`, but get `", i, "`")
 	}
 }
+
+func TestContext_InValues(t *testing.T) {
+	s := ""
+	ctx := &Context{
+		TargetIn: []any{"hello", 100},
+	}
+	ctx.Func = func() {
+		s = fmt.Sprintf("%s %d", ctx.TargetIn[0].(string), ctx.TargetIn[1].(int))
+	}
+	values := ctx.InValues()
+	if len(values) != 2 || values[0].Kind() != reflect.String || values[1].Int() != 100 {
+		t.Fatal("ctx.InValues() not match TargetIn, got", values)
+	}
+	ctx.SetInValue(0, reflect.ValueOf("world"))
+	ctx.TargetDo()
+	if s != "world 100" {
+		t.Fatal("s want `world 100`, but get", s)
+	}
+
+	failCases := []struct {
+		i int
+		v reflect.Value
+	}{
+		{1, reflect.ValueOf("string")},
+		{2, reflect.ValueOf(1)},
+		{-1, reflect.ValueOf(1)},
+		{0, reflect.Value{}},
+	}
+	for _, cas := range failCases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("ctx.SetInValue() should panic, case", cas)
+				}
+			}()
+			ctx.SetInValue(cas.i, cas.v)
+		}()
+	}
+}
+
+func TestContext_TargetDoTee(t *testing.T) {
+	newCtx := func() *Context {
+		ctx := &Context{
+			TargetIn:  []any{2, 3},
+			TargetOut: []any{0},
+		}
+		ctx.Func = func() {
+			ctx.TargetOut[0] = ctx.TargetIn[0].(int) + ctx.TargetIn[1].(int)
+		}
+		return ctx
+	}
+
+	ctx := newCtx()
+	var want, got []any
+	ctx.TargetDoTee(func(in []any) []any {
+		return []any{in[0].(int) * in[1].(int)}
+	}, func(w, g []any) {
+		want, got = w, g
+	})
+	if ctx.TargetOut[0] != 5 || ctx.DoRef() != 1 {
+		t.Fatal("ctx.TargetOut[0] want 5, but get", ctx.TargetOut[0])
+	}
+	if len(want) != 1 || want[0] != 5 || len(got) != 1 || got[0] != 6 {
+		t.Fatal("onDiff want [5] [6], but get", want, got)
+	}
+
+	ctx = newCtx()
+	ctx.TargetDoTee(func(in []any) []any {
+		return []any{in[1].(int) + in[0].(int)}
+	}, func(w, g []any) {
+		t.Fatal("onDiff should not be called, but get", w, g)
+	})
+	if ctx.TargetOut[0] != 5 {
+		t.Fatal("ctx.TargetOut[0] want 5, but get", ctx.TargetOut[0])
+	}
+}
+
+func TestContext_StoreLoad(t *testing.T) {
+	ctx := &Context{}
+	if _, ok := ctx.Load("key"); ok {
+		t.Fatal("ctx.Load(key) should not be ok before Store")
+	}
+	ctx.Store("key", "value")
+	if v, ok := ctx.Load("key"); !ok || v != "value" {
+		t.Fatal("ctx.Load(key) want value, but get", v, ok)
+	}
+}
+
+func TestStoreTLoadT(t *testing.T) {
+	type user struct {
+		name string
+	}
+	ctx := &Context{}
+	StoreT(ctx, "count", 100)
+	StoreT(ctx, "user", &user{"decor"})
+	if v, ok := LoadT[int](ctx, "count"); !ok || v != 100 {
+		t.Fatal("LoadT[int](count) want 100, but get", v, ok)
+	}
+	if v, ok := LoadT[*user](ctx, "user"); !ok || v.name != "decor" {
+		t.Fatal("LoadT[*user](user) want decor, but get", v, ok)
+	}
+	if v, ok := LoadT[string](ctx, "count"); ok || v != "" {
+		t.Fatal("LoadT[string](count) should be zero value and false, but get", v, ok)
+	}
+	if _, ok := LoadT[int](ctx, "notFound"); ok {
+		t.Fatal("LoadT[int](notFound) should be false")
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	type point struct{ x, y int }
+	cas := []struct{ got, want string }{
+		{TypeName[int](), "int"},
+		{TypeName[[]string](), "[]string"},
+		{TypeName[point](), "decor.point"},
+		{TypeName[any](), "interface {}"},
+		{TypeName[error](), "error"},
+		{TypeName[map[string]*int](), "map[string]*int"},
+	}
+	for i, c := range cas {
+		if c.got != c.want {
+			t.Fatalf("cas[%d] TypeName() want %s, but get %s", i, c.want, c.got)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	type intList []int
+	if v := Assign[int](1); v != 1 {
+		t.Fatal("Assign[int](1) want 1, but get", v)
+	}
+	if v := Assign[[]int](intList{1, 2}); len(v) != 2 || v[1] != 2 {
+		t.Fatal("Assign[[]int](intList) want [1 2], but get", v)
+	}
+	ch := make(chan int, 1)
+	ch <- 1
+	if v := Assign[<-chan int](ch); v == nil || <-v != 1 {
+		t.Fatal("Assign[<-chan int](chan int) should receive 1")
+	}
+	if v := Assign[string](1); v != "" {
+		t.Fatal("Assign[string](1) should be zero value, but get", v)
+	}
+	if v := Assign[error](nil); v != nil {
+		t.Fatal("Assign[error](nil) should be nil, but get", v)
+	}
+}
+
+func TestReceiverAs(t *testing.T) {
+	type service struct{ name string }
+	s := &service{name: "a"}
+	ctx := &Context{Kind: KMethod, Receiver: s}
+	if r, ok := ReceiverAs[*service](ctx); !ok || r != s {
+		t.Fatal("ReceiverAs[*service]() should return the pointer receiver, but get", r, ok)
+	}
+	if r, ok := ReceiverAs[service](ctx); !ok || r.name != "a" {
+		t.Fatal("ReceiverAs[service]() should return the value of a pointer receiver, but get", r, ok)
+	}
+	ctx.Receiver = service{name: "b"}
+	if r, ok := ReceiverAs[service](ctx); !ok || r.name != "b" {
+		t.Fatal("ReceiverAs[service]() should return the value receiver, but get", r, ok)
+	}
+	if r, ok := ReceiverAs[*service](ctx); ok || r != nil {
+		t.Fatal("ReceiverAs[*service]() should fail for a value receiver, but get", r, ok)
+	}
+	ctx.Receiver = (*service)(nil)
+	if _, ok := ReceiverAs[service](ctx); ok {
+		t.Fatal("ReceiverAs[service]() should fail for a nil pointer receiver")
+	}
+	if _, ok := ReceiverAs[service](&Context{Kind: KFunc}); ok {
+		t.Fatal("ReceiverAs[service]() should fail for a function")
+	}
+}
+
+func TestInOut(t *testing.T) {
+	errFoo := errors.New("foo")
+	ctx := &Context{TargetName: "plus", TargetIn: []any{1, "a", nil}, TargetOut: []any{0, nil}}
+	if In[int](ctx, 0) != 1 || In[string](ctx, 1) != "a" || In[error](ctx, 2) != nil {
+		t.Fatal("In() should return the typed parameters, got", ctx.TargetIn)
+	}
+	SetIn(ctx, 0, 2)
+	SetIn[error](ctx, 2, errFoo)
+	if In[int](ctx, 0) != 2 || In[error](ctx, 2) != errFoo {
+		t.Fatal("SetIn() should set the parameters, got", ctx.TargetIn)
+	}
+	SetOut(ctx, 0, 3)
+	SetOut[error](ctx, 1, errFoo)
+	if Out[int](ctx, 0) != 3 || Out[error](ctx, 1) != errFoo {
+		t.Fatal("SetOut() should set the results, got", ctx.TargetOut)
+	}
+
+	mustPanic := func(name, want string, f func()) {
+		defer func() {
+			if r := recover(); r != want {
+				t.Fatalf("%s should panic %q, but got %v", name, want, r)
+			}
+		}()
+		f()
+	}
+	mustPanic("In(3)", "decor: In index 3 out of range [0, 3) of plus", func() { In[int](ctx, 3) })
+	mustPanic("Out(-1)", "decor: Out index -1 out of range [0, 2) of plus", func() { Out[int](ctx, -1) })
+	mustPanic("In[string](0)", "decor: In[string] index 0 of plus holds int", func() { In[string](ctx, 0) })
+	mustPanic("SetIn[int64](0)", "decor: SetIn[int64] index 0 of plus holds int", func() { SetIn[int64](ctx, 0, 1) })
+	mustPanic("SetOut(2)", "decor: SetOut index 2 out of range [0, 2) of plus", func() { SetOut(ctx, 2, 1) })
+}
+
+func TestContext_LastError(t *testing.T) {
+	errFoo := errors.New("foo")
+	ctx := &Context{TargetOut: []any{1, errFoo}, TargetOutTypes: []string{"int", "error"}}
+	if err, ok := ctx.LastError(); !ok || err != errFoo {
+		t.Fatal("LastError() should return the error result, got", err, ok)
+	}
+	ctx.SetLastError(nil)
+	if err, ok := ctx.LastError(); !ok || err != nil || ctx.TargetOut[1] != nil {
+		t.Fatal("SetLastError() should set the error result, got", err, ok)
+	}
+
+	ctx = &Context{TargetName: "plus", TargetOut: []any{1}, TargetOutTypes: []string{"int"}}
+	if err, ok := ctx.LastError(); ok || err != nil {
+		t.Fatal("LastError() without error result should return false, got", err, ok)
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "plus") {
+			t.Fatal("SetLastError() without error result should panic, got", r)
+		}
+	}()
+	ctx.SetLastError(errFoo)
+}
+
+func TestContext_SetCtx(t *testing.T) {
+	type ctxKey struct{}
+	ctx := &Context{TargetIn: []any{context.Background(), 1}}
+	ctx.WithValue(ctxKey{}, "v")
+	if ctx.Ctx == nil || ctx.Ctx.Value(ctxKey{}) != "v" {
+		t.Fatal("WithValue() Ctx should carry the value")
+	}
+	if c, ok := ctx.TargetIn[0].(context.Context); !ok || c != ctx.Ctx {
+		t.Fatal("WithValue() TargetIn[0] should be replaced with Ctx")
+	}
+
+	// the first parameter is not a context.Context
+	ctx = &Context{TargetIn: []any{1}}
+	ctx.SetCtx(context.TODO())
+	if ctx.Ctx != context.TODO() || ctx.TargetIn[0] != 1 {
+		t.Fatal("SetCtx() should not replace TargetIn[0] which is not a context.Context, got", ctx.TargetIn)
+	}
+	ctx = &Context{}
+	ctx.WithValue(ctxKey{}, 1)
+	if ctx.Ctx.Value(ctxKey{}) != 1 {
+		t.Fatal("WithValue() without Ctx should use context.Background()")
+	}
+}
+
+func TestContext_TargetDoSafe(t *testing.T) {
+	calls := 0
+	ctx := &Context{
+		TargetIn:  []any{},
+		TargetOut: []any{0},
+	}
+	ctx.Func = func() {
+		calls++
+		if calls < 3 {
+			panic(fmt.Sprintf("fail %d", calls))
+		}
+		ctx.TargetOut[0] = calls
+	}
+	if r := ctx.TargetDoSafe(); r != "fail 1" || ctx.Panic != "fail 1" {
+		t.Fatal("TargetDoSafe() should recover fail 1, but get", r, ctx.Panic)
+	}
+	if r := ctx.TargetDoSafe(); r != "fail 2" || ctx.Panic != "fail 2" {
+		t.Fatal("TargetDoSafe() should recover fail 2, but get", r, ctx.Panic)
+	}
+	if r := ctx.TargetDoSafe(); r != nil || ctx.Panic != nil {
+		t.Fatal("TargetDoSafe() should not recover anything, but get", r, ctx.Panic)
+	}
+	if ctx.DoRef() != 3 || ctx.TargetOut[0] != 3 {
+		t.Fatal("TargetDoSafe() DoRef want 3, but get", ctx.DoRef(), ctx.TargetOut)
+	}
+}
+
+func TestContext_TargetDoSafePanicNil(t *testing.T) {
+	ctx := &Context{TargetIn: []any{}, TargetOut: []any{}}
+	ctx.Func = func() { panic(nil) }
+	if r := ctx.TargetDoSafe(); r == nil || ctx.Panic == nil {
+		t.Fatal("TargetDoSafe() should recover panic(nil) as non-nil, but get", r, ctx.Panic)
+	}
+	rePanicked := func() (r any) {
+		defer func() { r = recover() }()
+		panic(ctx.Panic)
+	}()
+	if rePanicked == nil {
+		t.Fatal("panic(ctx.Panic) should be recovered as non-nil")
+	}
+}
+
+func TestContext_ReplaceFunc(t *testing.T) {
+	called := 0
+	ctx := &Context{TargetName: "div", TargetOut: []any{0, nil}, TargetOutTypes: []string{"int", "error"}}
+	ctx.Func = func() {
+		called++
+		ctx.TargetOut[0] = 42
+	}
+	// the stub runs the real target on the first call and fails the second one
+	var target func()
+	target = ctx.ReplaceFunc(func() {
+		if ctx.DoRef()%2 == 0 {
+			ctx.SetLastError(errors.New("injected"))
+			return
+		}
+		target()
+	})
+	ctx.TargetDo()
+	if ctx.TargetOut[0] != 42 || ctx.TargetOut[1] != nil || called != 1 || ctx.DoRef() != 1 || ctx.Stopped() {
+		t.Fatal("ReplaceFunc() stub should call the target the first time, but get", ctx.TargetOut, called, ctx.DoRef())
+	}
+	ctx.TargetDo()
+	if err, _ := ctx.LastError(); err == nil || err.Error() != "injected" || called != 1 || ctx.DoRef() != 2 || !ctx.Stopped() {
+		t.Fatal("ReplaceFunc() stub should fail and stop the second call, but get", ctx.TargetOut, called, ctx.DoRef(), ctx.Stopped())
+	}
+	// the stub didn't call the target, the call is stopped
+	ctx.TargetDo()
+	if ctx.DoRef() != 2 {
+		t.Fatal("TargetDo() after a stub stopped the call should do nothing, but get", ctx.DoRef())
+	}
+
+	// an outer layer replaces the target, the inner layer still runs
+	var trace []string
+	ctx = &Context{TargetOut: []any{0}, Func: func() { trace = append(trace, "target") }}
+	mock := func(c *Context) {
+		c.ReplaceFunc(func() { c.TargetOut[0] = 7 })
+		c.TargetDo()
+	}
+	inner := func(c *Context) {
+		trace = append(trace, "inner")
+		c.TargetDo()
+	}
+	Invoke(ctx, mock, inner)
+	if strings.Join(trace, ",") != "inner" || ctx.TargetOut[0] != 7 || ctx.DoRef() != 1 || !ctx.Stopped() {
+		t.Fatal("ReplaceFunc() in a chain want inner and 7, but get", trace, ctx.TargetOut, ctx.DoRef())
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "ReplaceFunc of div") {
+			t.Fatal("ReplaceFunc(nil) should panic, but get", r)
+		}
+	}()
+	(&Context{TargetName: "div"}).ReplaceFunc(nil)
+}
+
+func TestContext_Elapsed(t *testing.T) {
+	ctx := &Context{Func: func() { time.Sleep(10 * time.Millisecond) }}
+	ctx.TargetDo()
+	if ctx.Elapsed() != 0 {
+		t.Fatal("Elapsed() should be 0 without Start, but get", ctx.Elapsed())
+	}
+	ctx = &Context{Start: Now(), Func: func() { time.Sleep(10 * time.Millisecond) }}
+	ctx.TargetDo()
+	if ctx.Elapsed() < 10*time.Millisecond {
+		t.Fatal("Elapsed() should include the target, but get", ctx.Elapsed())
+	}
+
+	// Chain always records Start
+	var elapsed time.Duration
+	fn := Chain(func() { time.Sleep(10 * time.Millisecond) }, func(c *Context) {
+		c.TargetDo()
+		elapsed = c.Elapsed()
+	})
+	fn()
+	if elapsed < 10*time.Millisecond {
+		t.Fatal("Elapsed() of Chain should include the target, but get", elapsed)
+	}
+}
+
+func TestContext_Stop(t *testing.T) {
+	called := 0
+	ctx := &Context{TargetOut: []any{0}, Func: func() { called++ }}
+	if ctx.Stopped() {
+		t.Fatal("ctx.Stopped() should be false before Stop")
+	}
+	ctx.TargetOut[0] = 42
+	ctx.Stop()
+	ctx.TargetDo()
+	if !ctx.Stopped() || called != 0 || ctx.DoRef() != 0 || ctx.TargetOut[0] != 42 {
+		t.Fatalf("TargetDo() after Stop should do nothing, got called %d, %+v", called, ctx)
+	}
+
+	// an inner layer stops, the outer layer sees it after TargetDo returns
+	var trace []string
+	ctx = &Context{Func: func() { trace = append(trace, "target") }}
+	outer := func(c *Context) {
+		c.TargetDo()
+		trace = append(trace, fmt.Sprint("outer stopped ", c.Stopped()))
+	}
+	auth := func(c *Context) { c.Stop() }
+	inner := func(c *Context) { trace = append(trace, "inner") }
+	Invoke(ctx, outer, auth, inner)
+	if strings.Join(trace, ",") != "outer stopped true" || ctx.DoRef() != 0 {
+		t.Fatal("Invoke() should not run the layers after Stop, get", trace)
+	}
+
+	// layers with their own contexts share the state through Chain
+	c := NewChainState(2)
+	outerCtx := &Context{Chain: c.Enter(0)}
+	innerCtx := &Context{Chain: c.Enter(1)}
+	innerCtx.Stop()
+	if !outerCtx.Stopped() || !innerCtx.Stopped() {
+		t.Fatal("Stopped() should be true for every layer of the chain")
+	}
+}
+
+func TestContext_ConcurrentTargetDo(t *testing.T) {
+	// run with -race: the decorator calls the target from its own goroutines
+	ctx := &Context{TargetOut: []any{0}}
+	calls := 0
+	ctx.Func = func() {
+		calls++
+		ctx.TargetOut[0] = calls
+		if calls%10 == 0 {
+			panic("fail")
+		}
+	}
+	parallel := func(c *Context) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if i%2 == 0 {
+					c.TargetDoSafe()
+				} else {
+					_ = c.DoRef() + 1
+					_ = c.Stopped()
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+	Invoke(ctx, parallel)
+	if ctx.DoRef() != 25 || calls != 25 || ctx.TargetOut[0] != 25 {
+		t.Fatal("concurrent TargetDo() want DoRef 25, but get", ctx.DoRef(), calls, ctx.TargetOut)
+	}
+
+	// Stop from one goroutine, the others may still see the target
+	ctx = &Context{Func: func() {}}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i == 5 {
+				ctx.Stop()
+			}
+			ctx.TargetDo()
+		}(i)
+	}
+	wg.Wait()
+	if !ctx.Stopped() || ctx.DoRef() > 9 {
+		t.Fatal("concurrent Stop() want Stopped, but get", ctx.Stopped(), ctx.DoRef())
+	}
+}
@@ -0,0 +1,156 @@
+// Package decortest provides Record, a decorator for tests that records the
+// calls of the target so a test can assert on them:
+//
+//	import "github.com/dengsgo/go-decorator/decor/decortest"
+//
+//	//go:decor decortest.Record
+//	func send(to, msg string) error {
+//		// code...
+//	}
+//
+//	func TestNotify(t *testing.T) {
+//		rec := decortest.Start(t)
+//		notify("alice")
+//		if calls := rec.CallsOf("send"); len(calls) != 1 || calls[0].In[0] != "alice" {
+//			t.Fatalf("send calls: %+v", calls)
+//		}
+//	}
+//
+// The decorated functions are usually test helpers in _test.go files, which are
+// rewritten like any other file when `go test` runs with -toolexec decorator.
+// The package is named after net/http/httptest so that it doesn't shadow the
+// testing package in test files.
+//
+// Calls are recorded by every Recorder started by a running test. Tests using
+// t.Parallel see the calls of each other, use distinct targets or arguments
+// to tell them apart.
+//
+// decortest 包提供了测试用的装饰器 Record ，记录目标函数的每次调用，供测试断言。
+package decortest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// Call is a recorded call of a target.
+type Call struct {
+	// Name is the TargetName of the target.
+	Name string
+
+	// Receiver is the receiver of a method, nil for a function.
+	Receiver any
+
+	// In is a copy of the parameters the target was called with.
+	In []any
+
+	// Out is a copy of the results the target returned, the zero values if it panicked.
+	Out []any
+
+	// Panic is the value the target panicked with, nil if it returned.
+	Panic any
+}
+
+// Recorder holds the calls recorded since it was started.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+var (
+	activeMu sync.Mutex
+	active   = map[*Recorder]struct{}{}
+)
+
+// Start starts recording the calls of the targets decorated with Record,
+// the recording stops when the test tb and its subtests complete.
+//
+// Start 开始记录被 Record 装饰的函数的调用，测试结束时停止。
+func Start(tb testing.TB) *Recorder {
+	tb.Helper()
+	r := &Recorder{}
+	activeMu.Lock()
+	active[r] = struct{}{}
+	activeMu.Unlock()
+	tb.Cleanup(func() {
+		activeMu.Lock()
+		delete(active, r)
+		activeMu.Unlock()
+	})
+	return r
+}
+
+// Calls returns the recorded calls in the order they returned.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// CallsOf returns the recorded calls of the target named name, a method is
+// named like its TargetName.
+func (r *Recorder) CallsOf(name string) []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var calls []Call
+	for _, c := range r.calls {
+		if c.Name == name {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Count returns the number of recorded calls of the target named name.
+func (r *Recorder) Count(name string) int {
+	return len(r.CallsOf(name))
+}
+
+// Reset discards the recorded calls.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
+
+func (r *Recorder) add(c Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, c)
+}
+
+// Record is a decorator recording each call of the target to the recorders
+// started by the running tests, a panic of the target is recorded and then
+// propagated. The target is just called when no recorder is started.
+//
+// Record 把目标函数的每次调用记录到正在运行的测试启动的 Recorder 中。
+func Record(ctx *decor.Context) {
+	activeMu.Lock()
+	recorders := make([]*Recorder, 0, len(active))
+	for r := range active {
+		recorders = append(recorders, r)
+	}
+	activeMu.Unlock()
+	if len(recorders) == 0 {
+		ctx.TargetDo()
+		return
+	}
+	c := Call{
+		Name:     ctx.TargetName,
+		Receiver: ctx.Receiver,
+		In:       append([]any(nil), ctx.TargetIn...),
+	}
+	defer func() {
+		c.Panic = recover()
+		c.Out = append([]any(nil), ctx.TargetOut...)
+		for _, r := range recorders {
+			r.add(c)
+		}
+		if c.Panic != nil {
+			panic(c.Panic)
+		}
+	}()
+	ctx.TargetDo()
+}
@@ -0,0 +1,67 @@
+package decortest
+
+import (
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// call builds the context of a call of func(a int) int named name.
+func call(name string, a int, panics bool) *decor.Context {
+	ctx := &decor.Context{TargetName: name, TargetIn: []any{a}, TargetOut: []any{0}}
+	ctx.Func = func() {
+		if panics {
+			panic("boom")
+		}
+		ctx.TargetOut[0] = ctx.TargetIn[0].(int) * 2
+	}
+	return ctx
+}
+
+func TestRecord(t *testing.T) {
+	Record(call("before", 1, false)) // no recorder is started
+
+	rec := Start(t)
+	Record(call("double", 1, false))
+	Record(call("double", 2, false))
+	Record(call("other", 3, false))
+	func() {
+		defer func() {
+			if recover() != "boom" {
+				t.Fatal("Record() should propagate the panic of the target")
+			}
+		}()
+		Record(call("other", 4, true))
+	}()
+
+	calls := rec.CallsOf("double")
+	if len(calls) != 2 || calls[1].In[0] != 2 || calls[1].Out[0] != 4 {
+		t.Fatalf("CallsOf() got %+v", calls)
+	}
+	if rec.Count("other") != 2 || rec.Count("before") != 0 || len(rec.Calls()) != 4 {
+		t.Fatalf("Calls() got %+v", rec.Calls())
+	}
+	if c := rec.Calls()[3]; c.Panic != "boom" || c.Out[0] != 0 {
+		t.Fatalf("Record() should record the panic, got %+v", c)
+	}
+
+	t.Run("sub", func(t *testing.T) {
+		sub := Start(t)
+		Record(call("double", 5, false))
+		if sub.Count("double") != 1 {
+			t.Fatal("Start() should only record the calls after it")
+		}
+	})
+	if rec.Count("double") != 3 {
+		t.Fatal("Record() should record to every started recorder")
+	}
+	Record(call("double", 6, false))
+	if rec.Count("double") != 4 {
+		t.Fatal("Record() should stop recording to the recorder of a completed subtest")
+	}
+
+	rec.Reset()
+	if len(rec.Calls()) != 0 {
+		t.Fatal("Reset() should discard the calls")
+	}
+}
@@ -0,0 +1,301 @@
+// Package http provides decorators for HTTP handlers, the functions and methods
+// of the form func(w http.ResponseWriter, r *http.Request), such as ServeHTTP:
+//
+//	import dhttp "github.com/dengsgo/go-decorator/decor/http"
+//
+//	//go:decor accessLog
+//	//go:decor dhttp.Recover
+//	//go:decor dhttp.Route#{route: "/users/{id}"}
+//	func getUser(w http.ResponseWriter, r *http.Request) {
+//		// code...
+//	}
+//
+//	func accessLog(ctx *decor.Context) {
+//		ctx.TargetDo()
+//		log.Println(dhttp.RouteOf(ctx), dhttp.StatusOf(ctx), dhttp.SizeOf(ctx))
+//	}
+//
+// The files declaring handlers usually import net/http, so import this package
+// with another name, such as dhttp. Every decorator of the package checks the
+// signature of its targets with //go:decor-lint at compile time.
+//
+// Status and Recover replace the ResponseWriter of the handler with one that
+// records the status code and the size of the response, the decorators of the
+// target read them with StatusOf and SizeOf after TargetDo. Put the decorators
+// reading them outside (above) the decorators of this package.
+//
+// http 包提供了装饰 HTTP 处理函数的装饰器：记录响应的状态码、注入路由模板、把 panic 转换为 500 响应，
+// 其他装饰器通过 StatusOf 、RouteOf 等函数读取它们记录的信息。
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// routeKey is the key of the route in decor.Context.Values.
+const routeKey = "decor/http.route"
+
+// routeContextKey is the key of the route in the context of the request.
+type routeContextKey struct{}
+
+// Status records the status code and the size of the response written by the
+// handler, read them with StatusOf and SizeOf:
+//
+//	//go:decor accessLog
+//	//go:decor dhttp.Status
+//	func getUser(w http.ResponseWriter, r *http.Request) {}
+//
+// Status 记录处理函数写入的响应的状态码和长度。
+//
+//go:decor-lint signature: {in: ["http.ResponseWriter", "*http.Request"], out: []}
+func Status(ctx *decor.Context) {
+	response(ctx)
+	ctx.TargetDo()
+}
+
+// Route injects the route template of the handler, which is usually not known
+// from the request itself, for example to label metrics by route instead of by
+// path. The decorators read it with RouteOf, and the handler with RouteFrom:
+//
+//	//go:decor dhttp.Route#{route: "/users/{id}"}
+//	func getUser(w http.ResponseWriter, r *http.Request) {
+//		route := dhttp.RouteFrom(r) // "/users/{id}"
+//	}
+//
+// Route 注入处理函数的路由模板，装饰器通过 RouteOf 读取，处理函数通过 RouteFrom 读取。
+//
+//go:decor-lint required: {route}
+//go:decor-lint nonzero: {route}
+//go:decor-lint signature: {in: ["http.ResponseWriter", "*http.Request"], out: []}
+func Route(ctx *decor.Context, route string) {
+	ctx.Store(routeKey, route)
+	if r := Request(ctx); r != nil {
+		ctx.TargetIn[1] = r.WithContext(context.WithValue(r.Context(), routeContextKey{}, route))
+	}
+	ctx.TargetDo()
+}
+
+// Recover converts a panic of the handler to a 500 Internal Server Error
+// response. The recovered value is kept in ctx.Panic, and StatusOf returns 500,
+// so an outer decorator can log it. The status is recorded like Status does.
+//
+// If the handler has written the header before it panics, the response can't
+// be changed any more, Recover panics with http.ErrAbortHandler, and net/http
+// aborts the response. A panic with http.ErrAbortHandler is not recovered.
+//
+// Recover 把处理函数的 panic 转换为 500 响应，捕获的值保存在 ctx.Panic 中。
+// 处理函数已经写入了响应头时无法修改响应，以 http.ErrAbortHandler 中止响应。
+//
+//go:decor-lint signature: {in: ["http.ResponseWriter", "*http.Request"], out: []}
+func Recover(ctx *decor.Context) {
+	w := response(ctx)
+	recovered := ctx.TargetDoSafe()
+	if recovered == nil {
+		return
+	}
+	if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+		panic(recovered)
+	}
+	if w.status != 0 {
+		panic(http.ErrAbortHandler)
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// Writer returns the http.ResponseWriter passed to the handler, it is the one
+// recording the response once Status or Recover has run.
+//
+// Writer 返回传给处理函数的 http.ResponseWriter 。
+func Writer(ctx *decor.Context) http.ResponseWriter {
+	return decor.In[http.ResponseWriter](ctx, 0)
+}
+
+// Request returns the *http.Request passed to the handler.
+//
+// Request 返回传给处理函数的 *http.Request 。
+func Request(ctx *decor.Context) *http.Request {
+	return decor.In[*http.Request](ctx, 1)
+}
+
+// StatusOf returns the status code of the response recorded by Status or
+// Recover, or 0 if the handler hasn't written anything yet (net/http responds
+// 200 then) or neither decorator is used. Writing the body without calling
+// WriteHeader first records 200.
+//
+// StatusOf 返回 Status 或 Recover 记录的状态码，处理函数还没有写入响应或没有使用这两个装饰器时为 0 。
+func StatusOf(ctx *decor.Context) int {
+	if w, ok := Writer(ctx).(recorder); ok {
+		return w.record().status
+	}
+	return 0
+}
+
+// SizeOf returns the number of bytes of the response body recorded by Status
+// or Recover.
+//
+// SizeOf 返回 Status 或 Recover 记录的响应体的字节数。
+func SizeOf(ctx *decor.Context) int64 {
+	if w, ok := Writer(ctx).(recorder); ok {
+		return w.record().size
+	}
+	return 0
+}
+
+// RouteOf returns the route template injected by Route, or "" if there is none.
+//
+// RouteOf 返回 Route 注入的路由模板，没有时返回空字符串。
+func RouteOf(ctx *decor.Context) string {
+	if route, ok := decor.LoadT[string](ctx, routeKey); ok {
+		return route
+	}
+	return RouteFrom(Request(ctx))
+}
+
+// RouteFrom returns the route template injected by Route into the request, the
+// handler uses it to read its route.
+//
+// RouteFrom 返回 Route 注入请求中的路由模板，供处理函数使用。
+func RouteFrom(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	route, _ := r.Context().Value(routeContextKey{}).(string)
+	return route
+}
+
+// response returns the recording ResponseWriter of the handler, it replaces
+// the one passed to the handler the first time.
+func response(ctx *decor.Context) *responseWriter {
+	w := Writer(ctx)
+	if rec, ok := w.(recorder); ok {
+		return rec.record()
+	}
+	rw := &responseWriter{ResponseWriter: w}
+	ctx.TargetIn[0] = rw.wrap()
+	return rw
+}
+
+// recorder is implemented by the ResponseWriter replaced by response.
+type recorder interface {
+	record() *responseWriter
+}
+
+// responseWriter records the status code and the size of the response, and
+// Unwrap returns the original one for http.ResponseController.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// wrap returns w with the optional interfaces of the original ResponseWriter,
+// http.Flusher, http.Hijacker and io.ReaderFrom, and only those, so that a
+// handler checking for one of them sees what the original one supports.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	_, f := w.ResponseWriter.(http.Flusher)
+	_, h := w.ResponseWriter.(http.Hijacker)
+	_, r := w.ResponseWriter.(io.ReaderFrom)
+	switch {
+	case f && h && r:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, flusher{w}, hijacker{w}, readerFrom{w}}
+	case f && h:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{w, flusher{w}, hijacker{w}}
+	case f && r:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{w, flusher{w}, readerFrom{w}}
+	case h && r:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{w, hijacker{w}, readerFrom{w}}
+	case f:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{w, flusher{w}}
+	case h:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{w, hijacker{w}}
+	case r:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+		}{w, readerFrom{w}}
+	}
+	return w
+}
+
+func (w *responseWriter) record() *responseWriter {
+	return w
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	// informational responses (1xx) may come before the final one
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flusher, hijacker and readerFrom implement the optional interfaces of the
+// original ResponseWriter, see wrap.
+type flusher struct{ *responseWriter }
+
+func (w flusher) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct{ *responseWriter }
+
+func (w hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type readerFrom struct{ *responseWriter }
+
+func (w readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	w.size += n
+	return n, err
+}
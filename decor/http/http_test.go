@@ -0,0 +1,146 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// handlerCtx returns the context of a call of the handler h, like the generated code builds it.
+func handlerCtx(h http.HandlerFunc) (*decor.Context, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	ctx := &decor.Context{
+		Kind:       decor.KFunc,
+		TargetName: "handler",
+		TargetIn:   []any{http.ResponseWriter(rec), httptest.NewRequest(http.MethodGet, "/users/1", nil)},
+		TargetOut:  []any{},
+	}
+	ctx.Func = func() {
+		h(ctx.TargetIn[0].(http.ResponseWriter), ctx.TargetIn[1].(*http.Request))
+	}
+	return ctx, rec
+}
+
+// readerFromWriter is a ResponseWriter implementing io.ReaderFrom but not http.Flusher.
+type readerFromWriter struct {
+	http.ResponseWriter
+}
+
+func (w *readerFromWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(w.ResponseWriter, r)
+}
+
+func TestStatus(t *testing.T) {
+	ctx, rec := handlerCtx(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "created")
+	})
+	if StatusOf(ctx) != 0 || SizeOf(ctx) != 0 {
+		t.Fatal("StatusOf() should be 0 before Status, but got", StatusOf(ctx))
+	}
+	Status(ctx)
+	if StatusOf(ctx) != http.StatusCreated || SizeOf(ctx) != 7 || rec.Code != http.StatusCreated || rec.Body.String() != "created" {
+		t.Fatal("Status() should record 201 and 7 bytes, but got", StatusOf(ctx), SizeOf(ctx), rec.Code)
+	}
+	if _, ok := Writer(ctx).(http.Flusher); !ok || Writer(ctx).(interface{ Unwrap() http.ResponseWriter }).Unwrap() != rec {
+		t.Fatal("Status() should keep Flush and Unwrap to the original ResponseWriter")
+	}
+	if _, ok := Writer(ctx).(http.Hijacker); ok {
+		t.Fatal("Status() shouldn't add Hijack to a ResponseWriter without it")
+	}
+
+	// only the optional interfaces of the original ResponseWriter are exposed
+	ctx, _ = handlerCtx(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.(io.ReaderFrom).ReadFrom(strings.NewReader("body"))
+	})
+	ctx.TargetIn[0] = &readerFromWriter{ResponseWriter: httptest.NewRecorder()}
+	Status(ctx)
+	if _, ok := Writer(ctx).(http.Flusher); ok || StatusOf(ctx) != http.StatusOK || SizeOf(ctx) != 4 {
+		t.Fatal("Status() should record ReadFrom without adding Flush, but got", StatusOf(ctx), SizeOf(ctx))
+	}
+
+	// writing the body records 200, 1xx responses are skipped
+	ctx, _ = handlerCtx(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		fmt.Fprint(w, "ok")
+	})
+	Status(ctx)
+	if StatusOf(ctx) != http.StatusOK {
+		t.Fatal("Status() should record 200 for a body without WriteHeader, but got", StatusOf(ctx))
+	}
+}
+
+func TestRoute(t *testing.T) {
+	route := ""
+	ctx, _ := handlerCtx(func(w http.ResponseWriter, r *http.Request) {
+		route = RouteFrom(r)
+	})
+	if RouteOf(ctx) != "" || RouteFrom(nil) != "" {
+		t.Fatal("RouteOf() should be empty before Route")
+	}
+	Route(ctx, "/users/{id}")
+	if route != "/users/{id}" || RouteOf(ctx) != "/users/{id}" || Request(ctx).URL.Path != "/users/1" {
+		t.Fatal("Route() should inject the route, but got", route, RouteOf(ctx))
+	}
+}
+
+func TestRecover(t *testing.T) {
+	ctx, rec := handlerCtx(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	Recover(ctx)
+	if ctx.Panic != "boom" || StatusOf(ctx) != http.StatusInternalServerError || rec.Code != http.StatusInternalServerError {
+		t.Fatal("Recover() should respond 500, but got", ctx.Panic, StatusOf(ctx), rec.Code)
+	}
+
+	// the header is written already, or the handler aborts itself
+	for _, h := range []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "partial")
+			panic("boom")
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		},
+	} {
+		ctx, _ := handlerCtx(h)
+		func() {
+			defer func() {
+				if err, _ := recover().(error); !errors.Is(err, http.ErrAbortHandler) {
+					t.Fatal("Recover() should abort the response, but got", err)
+				}
+			}()
+			Recover(ctx)
+		}()
+	}
+}
+
+func TestChain(t *testing.T) {
+	var logged string
+	accessLog := func(ctx *decor.Context) {
+		ctx.TargetDo()
+		logged = fmt.Sprintf("%s %d %d %v", RouteOf(ctx), StatusOf(ctx), SizeOf(ctx), ctx.Panic)
+	}
+	h := decor.Chain(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			panic("boom")
+		}
+		fmt.Fprint(w, RouteFrom(r))
+	}, accessLog, Recover, func(ctx *decor.Context) { Route(ctx, "/users/{id}") })
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if logged != "/users/{id} 200 11 <nil>" || rec.Body.String() != "/users/{id}" {
+		t.Fatal("Chain() want /users/{id} 200 11 <nil>, but got", logged, rec.Body.String())
+	}
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1?fail=1", nil))
+	if logged != "/users/{id} 500 22 boom" {
+		t.Fatal("Chain() want /users/{id} 500 22 boom, but got", logged)
+	}
+}
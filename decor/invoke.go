@@ -0,0 +1,113 @@
+package decor
+
+// Invoke runs the decorators of a chain for the single call described by d,
+// decorators[0] is the outermost layer. It is called by the generated code
+// of a target using more than one decorator:
+//
+//	//go:decor tracing
+//	//go:decor logging#{level: "info"}
+//	func work() {}
+//
+// is rewritten to build one Context for each call of work and run
+//
+//	decor.Invoke(ctx, func(c *decor.Context) { tracing(c) }, func(c *decor.Context) { logging(c, "info") })
+//
+// Calling TargetDo in a decorator calls the next inner decorator, and the
+// innermost one calls the target. The outermost layer runs on d, every inner
+// layer on its own Context with the same TargetName, Kind and other fields
+// describing the target. TargetIn, TargetOut, Ctx, Values, Panic and Func are
+// passed to the inner layer when TargetDo is called and back when it returns,
+// so the layers see each other's changes as if they shared d, while the position
+// of each layer in the chain is never shared. DoRef counts how many times the
+// target itself was called, and Stop stops every layer. If d.Chain is not nil,
+// the timing of each layer is recorded into it, and Values is ChainState.Values.
+//
+// The generated code only uses Invoke if every decorator of the target takes a
+// *Context. If one of them takes a typed context, each layer is rewritten into
+// its own wrapper of the target: the inner layer is called with the arguments
+// in TargetIn and builds a new context for every call, so DoRef of an outer
+// layer counts the calls of the next inner layer, and only ChainState is
+// shared, which carries Values and Stop.
+//
+// Invoke 执行装饰链，decorators[0] 为最外层。装饰器中调用 TargetDo 时执行内一层的装饰器，最内层调用目标函数。
+// 最外层使用 d ，内层各自使用一个 Context ，TargetIn 、TargetOut 、Ctx 、Values 、Panic 和 Func
+// 在调用 TargetDo 时传给内层，返回时传回外层；每一层在链中的位置不共享，DoRef 为目标函数本身被调用的次数。
+// 链中有装饰器接收类型化的上下文时不使用 Invoke ，每一层各自包装目标函数，只共享 ChainState 。
+func Invoke(d *Context, decorators ...func(*Context)) {
+	if d.Chain != nil && d.Values == nil {
+		d.Values = d.Chain.Values
+	}
+	if len(decorators) == 0 {
+		d.TargetDo()
+		return
+	}
+	d.layers = decorators
+	d.runLayer()
+}
+
+// runLayer runs the decorator of the layer d belongs to.
+func (d *Context) runLayer() {
+	if d.Chain != nil {
+		d.Chain.Enter(d.layer)
+		defer d.Chain.Exit(d.layer)
+	}
+	d.shared().layers[d.layer](d)
+}
+
+// shared returns the context of the outermost layer, which holds the state
+// shared by all layers of a chain run by Invoke: DoRef, Stop and the lock of the target.
+func (d *Context) shared() *Context {
+	if d.outer != nil {
+		return d.outer
+	}
+	return d
+}
+
+// hasInnerLayer reports whether TargetDo of d runs another decorator rather than the target.
+func (d *Context) hasInnerLayer() bool {
+	return d.layer+1 < len(d.shared().layers)
+}
+
+// nextLayer passes the call state of d to the context of the next inner layer
+// and runs it. The calls from the goroutines of d's decorator run one at a time.
+func (d *Context) nextLayer() {
+	d.innerMu.Lock()
+	defer d.innerMu.Unlock()
+	outer := d.shared()
+	inner := d.inner
+	if inner == nil {
+		inner = &Context{
+			Kind:           d.Kind,
+			TargetInNames:  d.TargetInNames,
+			TargetOutNames: d.TargetOutNames,
+			TargetInTypes:  d.TargetInTypes,
+			TargetOutTypes: d.TargetOutTypes,
+			TypeParams:     d.TypeParams,
+			TypeArgs:       d.TypeArgs,
+			TargetName:     d.TargetName,
+			TargetPkg:      d.TargetPkg,
+			TargetFile:     d.TargetFile,
+			TargetLine:     d.TargetLine,
+			Receiver:       d.Receiver,
+			Start:          d.Start,
+			Chain:          d.Chain,
+			outer:          outer,
+			layer:          d.layer + 1,
+		}
+		d.inner = inner
+	}
+	outer.mu.Lock()
+	inner.copyCallState(d)
+	outer.mu.Unlock()
+	defer func() {
+		outer.mu.Lock()
+		d.copyCallState(inner)
+		outer.mu.Unlock()
+	}()
+	inner.runLayer()
+}
+
+// copyCallState copies the fields a decorator may change during the call from src.
+func (d *Context) copyCallState(src *Context) {
+	d.TargetIn, d.TargetOut, d.Ctx, d.Values, d.Panic, d.Func = src.TargetIn, src.TargetOut, src.Ctx, src.Values, src.Panic, src.Func
+}
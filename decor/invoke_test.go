@@ -0,0 +1,142 @@
+package decor
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestInvoke(t *testing.T) {
+	var trace []string
+	layer := func(name string) func(*Context) {
+		return func(ctx *Context) {
+			trace = append(trace, name+" in")
+			ctx.TargetDo()
+			trace = append(trace, name+" out")
+		}
+	}
+	ctx := &Context{
+		Kind:       KFunc,
+		TargetName: "plus",
+		TargetIn:   []any{1, 2},
+		TargetOut:  []any{0},
+		Chain:      NewChainState(3),
+	}
+	ctx.Func = func() {
+		trace = append(trace, "target")
+		ctx.TargetOut[0] = ctx.TargetIn[0].(int) + ctx.TargetIn[1].(int)
+	}
+	var seen *Context
+	double := func(c *Context) {
+		seen = c
+		c.TargetIn[0] = c.TargetIn[0].(int) * 2
+		c.TargetDo()
+	}
+	Invoke(ctx, layer("outer"), double, layer("inner"))
+	if strings.Join(trace, ",") != "outer in,inner in,target,inner out,outer out" {
+		t.Fatal("Invoke() layer order not match, get", trace)
+	}
+	if seen == nil || seen.TargetName != "plus" || ctx.TargetIn[0] != 2 || ctx.TargetOut[0] != 4 || ctx.DoRef() != 1 || seen.DoRef() != 1 {
+		t.Fatalf("Invoke() should pass the call state through all layers, got %+v", ctx)
+	}
+	if timings := ctx.ChainTimings(); len(timings) != 3 || timings[0] < timings[1] || timings[1] < timings[2] {
+		t.Fatal("Invoke() should record the timing of every layer, get", timings)
+	}
+
+	// without decorators the target is called directly
+	ctx = &Context{}
+	called := false
+	ctx.Func = func() { called = true }
+	Invoke(ctx)
+	if !called || ctx.DoRef() != 1 {
+		t.Fatal("Invoke() without decorators should call the target, get", called, ctx.DoRef())
+	}
+}
+
+func TestInvokeRetry(t *testing.T) {
+	fails := 2
+	ctx := &Context{TargetOut: []any{""}}
+	ctx.Func = func() {
+		if fails > 0 {
+			fails--
+			panic("fail")
+		}
+		ctx.TargetOut[0] = "ok"
+	}
+	inner := 0
+	retry := func(c *Context) {
+		for c.TargetDoSafe() != nil {
+		}
+	}
+	count := func(c *Context) {
+		inner++
+		c.TargetDo()
+	}
+	Invoke(ctx, retry, count)
+	if ctx.TargetOut[0] != "ok" || inner != 3 || ctx.DoRef() != 3 {
+		t.Fatal("Invoke() TargetDo after a recovered panic should run the inner layers again, get",
+			ctx.TargetOut[0], inner, ctx.DoRef())
+	}
+}
+
+func TestInvokeValues(t *testing.T) {
+	ctx := &Context{Chain: NewChainState(2)}
+	ctx.Func = func() {}
+	Invoke(ctx, func(c *Context) {
+		c.Store("span", 1)
+		c.TargetDo()
+	}, func(c *Context) {
+		if v, ok := LoadT[int](c, "span"); !ok || v != 1 {
+			t.Fatal("inner layer should load the value stored by the outer layer, get", v, ok)
+		}
+		c.TargetDo()
+	})
+	if v, ok := ctx.Chain.Values["span"]; !ok || v != 1 {
+		t.Fatal("Invoke() Values should be ChainState.Values, get", v, ok)
+	}
+}
+
+func TestInvokeConcurrentOuter(t *testing.T) {
+	ctx := &Context{TargetOut: []any{0}}
+	ctx.Func = func() {
+		ctx.TargetOut[0] = ctx.TargetOut[0].(int) + 1
+	}
+	var inner int64
+	parallel := func(c *Context) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.TargetDo()
+			}()
+		}
+		wg.Wait()
+	}
+	count := func(c *Context) {
+		atomic.AddInt64(&inner, 1)
+		c.TargetDo()
+	}
+	Invoke(ctx, parallel, count, count)
+	if inner != 16 || ctx.DoRef() != 8 || ctx.TargetOut[0] != 8 {
+		t.Fatal("Invoke() TargetDo from the goroutines of an outer layer should run every inner layer, get",
+			inner, ctx.DoRef(), ctx.TargetOut[0])
+	}
+}
+
+func TestInvokeStop(t *testing.T) {
+	ctx := &Context{}
+	ctx.Func = func() { t.Fatal("the target should not be called after Stop") }
+	var outerStopped bool
+	Invoke(ctx, func(c *Context) {
+		c.TargetDo()
+		outerStopped = c.Stopped()
+	}, func(c *Context) {
+		c.Stop()
+		c.TargetDo()
+	})
+	if !outerStopped || ctx.DoRef() != 0 {
+		t.Fatal("Stop() in an inner layer should stop the outer layers, get", outerStopped, ctx.DoRef())
+	}
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Func adapts a function to Logger.
+type Func func(ctx context.Context, level Level, msg string, fields ...Field)
+
+func (f Func) Log(ctx context.Context, level Level, msg string, fields ...Field) {
+	f(ctx, level, msg, fields...)
+}
+
+// Std returns a Logger writing records to l as lines like
+// "INFO plus a=1 duration=1µs", the standard logger is used if l is nil.
+//
+// Std 返回写入标准库 *log.Logger 的后端，l 为 nil 时使用 log.Default() 。
+func Std(l *log.Logger) Logger {
+	return Func(func(_ context.Context, level Level, msg string, fields ...Field) {
+		out := l
+		if out == nil {
+			out = log.Default()
+		}
+		out.Print(level.String() + " " + msg + formatFields(fields))
+	})
+}
+
+// SugaredLogger is the logging methods of zap's *zap.SugaredLogger.
+type SugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// Sugared returns a Logger writing records to a zap logger, the fields are
+// passed as key-value pairs:
+//
+//	logging.SetLogger(logging.Sugared(zapLogger.Sugar()))
+//
+// Sugared 返回写入 zap 的后端。
+func Sugared(l SugaredLogger) Logger {
+	return Func(func(_ context.Context, level Level, msg string, fields ...Field) {
+		kvs := make([]interface{}, 0, len(fields)*2)
+		for _, f := range fields {
+			kvs = append(kvs, f.Key, f.Value)
+		}
+		switch {
+		case level < Info:
+			l.Debugw(msg, kvs...)
+		case level < Warn:
+			l.Infow(msg, kvs...)
+		case level < Error:
+			l.Warnw(msg, kvs...)
+		default:
+			l.Errorw(msg, kvs...)
+		}
+	})
+}
+
+// LeveledLogger is the leveled logging methods of logrus' *logrus.Logger and
+// *logrus.Entry, which many other loggers have as well.
+type LeveledLogger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Leveled returns a Logger writing records to a logrus logger, the fields are
+// appended to the message as key=value:
+//
+//	logging.SetLogger(logging.Leveled(logrus.StandardLogger()))
+//
+// Leveled 返回写入 logrus 的后端，字段以 key=value 的形式追加到消息中。
+func Leveled(l LeveledLogger) Logger {
+	return Func(func(_ context.Context, level Level, msg string, fields ...Field) {
+		s := msg + formatFields(fields)
+		switch {
+		case level < Info:
+			l.Debugf("%s", s)
+		case level < Warn:
+			l.Infof("%s", s)
+		case level < Error:
+			l.Warnf("%s", s)
+		default:
+			l.Errorf("%s", s)
+		}
+	})
+}
+
+// formatFields formats fields as " k1=v1 k2=v2", values containing spaces or
+// quotes are quoted.
+func formatFields(fields []Field) string {
+	var b strings.Builder
+	for _, f := range fields {
+		v := fmt.Sprint(f.Value)
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		b.WriteString(" " + f.Key + "=" + v)
+	}
+	return b.String()
+}
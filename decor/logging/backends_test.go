@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStd(t *testing.T) {
+	var buf bytes.Buffer
+	Std(log.New(&buf, "", 0)).Log(context.Background(), Warn, "plus", Field{"a", 1}, Field{"s", "x y"}, Field{"e", ""})
+	if s := buf.String(); s != "WARN plus a=1 s=\"x y\" e=\"\"\n" {
+		t.Fatal("Std() output not match, got", s)
+	}
+}
+
+type fakeLogger struct {
+	calls []string
+}
+
+func (f *fakeLogger) add(method, msg string, args []interface{}) {
+	f.calls = append(f.calls, method+" "+msg+" "+fmt.Sprint(args))
+}
+
+func (f *fakeLogger) Debugw(msg string, kvs ...interface{}) { f.add("Debugw", msg, kvs) }
+func (f *fakeLogger) Infow(msg string, kvs ...interface{})  { f.add("Infow", msg, kvs) }
+func (f *fakeLogger) Warnw(msg string, kvs ...interface{})  { f.add("Warnw", msg, kvs) }
+func (f *fakeLogger) Errorw(msg string, kvs ...interface{}) { f.add("Errorw", msg, kvs) }
+
+func (f *fakeLogger) Debugf(format string, args ...interface{}) { f.add("Debugf", format, args) }
+func (f *fakeLogger) Infof(format string, args ...interface{})  { f.add("Infof", format, args) }
+func (f *fakeLogger) Warnf(format string, args ...interface{})  { f.add("Warnf", format, args) }
+func (f *fakeLogger) Errorf(format string, args ...interface{}) { f.add("Errorf", format, args) }
+
+func TestSugared(t *testing.T) {
+	f := &fakeLogger{}
+	l := Sugared(f)
+	for _, level := range []Level{Debug, Info, Warn, Error} {
+		l.Log(context.Background(), level, "plus", Field{"a", 1})
+	}
+	want := "Debugw plus [a 1],Infow plus [a 1],Warnw plus [a 1],Errorw plus [a 1]"
+	if s := strings.Join(f.calls, ","); s != want {
+		t.Fatal("Sugared() calls not match, got", s)
+	}
+}
+
+func TestLeveled(t *testing.T) {
+	f := &fakeLogger{}
+	l := Leveled(f)
+	for _, level := range []Level{Debug, Info, Warn, Error} {
+		l.Log(context.Background(), level, "plus", Field{"a", 1})
+	}
+	want := "Debugf %s [plus a=1],Infof %s [plus a=1],Warnf %s [plus a=1],Errorf %s [plus a=1]"
+	if s := strings.Join(f.calls, ","); s != want {
+		t.Fatal("Leveled() calls not match, got", s)
+	}
+}
@@ -0,0 +1,143 @@
+// Package logging provides Logging, a decorator that logs each call of the
+// target as a structured record, with a pluggable Logger backend:
+//
+//	import _ "github.com/dengsgo/go-decorator/decor/logging"
+//
+//	//go:decor logging.Logging#{level: logging.Info, includeArgs: true, includeResults: true}
+//	func fetch(ctx context.Context, url string) ([]byte, error) {
+//		// code...
+//	}
+//
+// Records are written by the standard log package by default, use SetLogger to
+// log with slog (Slog), zap (Sugared) or logrus (Leveled) instead.
+//
+// logging 包提供了记录结构化调用日志的装饰器 Logging ，通过 SetLogger 替换日志后端。
+package logging
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// Level is the level of a record, the values are the same as slog.Level.
+type Level int
+
+const (
+	Debug Level = -4
+	Info  Level = 0
+	Warn  Level = 4
+	Error Level = 8
+)
+
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "DEBUG"
+	case Info:
+		return "INFO"
+	case Warn:
+		return "WARN"
+	case Error:
+		return "ERROR"
+	}
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Field is a key-value pair of a record.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Logger is the backend writing the records of Logging.
+type Logger interface {
+	// Log writes a record, ctx is the Ctx of the decorator context, or
+	// context.Background() if it is nil.
+	Log(ctx context.Context, level Level, msg string, fields ...Field)
+}
+
+var (
+	mu     sync.RWMutex
+	logger Logger = Std(nil)
+)
+
+// SetLogger replaces the backend of Logging, a nil l restores the default
+// backend writing to the standard log package.
+//
+// SetLogger 替换 Logging 的日志后端，l 为 nil 时恢复默认的标准库 log 后端。
+func SetLogger(l Logger) {
+	if l == nil {
+		l = Std(nil)
+	}
+	mu.Lock()
+	logger = l
+	mu.Unlock()
+}
+
+func getLogger() Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return logger
+}
+
+// Logging calls the target and logs a record named after the target at level,
+// with the duration of the call:
+//
+//	//go:decor logging.Logging#{level: logging.Debug, includeArgs: true}
+//	func plus(a, b int) int {}
+//
+// With includeArgs and includeResults the parameters and results of the target
+// are added as the fields arg.<name> and result.<name>, the index is used for
+// unnamed ones, and context.Context parameters are skipped. They are not logged
+// by default because they may hold sensitive data.
+//
+// If the target returns a non-nil error as its last result, the record is logged
+// at Error with the field error. If the target panics, the record is logged at
+// Error with the field panic, and the panic is raised again.
+//
+// Logging 调用目标函数并记录一条以目标函数命名的日志，includeArgs 、includeResults 为 true 时记录参数和返回值。
+func Logging(ctx *decor.Context, level Level, includeArgs, includeResults bool) {
+	var fields []Field
+	if includeArgs {
+		for i, v := range ctx.TargetIn {
+			if _, ok := v.(context.Context); ok {
+				continue
+			}
+			fields = append(fields, Field{"arg." + fieldName(ctx.TargetInNames, i), v})
+		}
+	}
+	start := time.Now()
+	recovered := ctx.TargetDoSafe()
+	fields = append(fields, Field{"duration", time.Since(start)})
+
+	c := ctx.Ctx
+	if c == nil {
+		c = context.Background()
+	}
+	if recovered != nil {
+		getLogger().Log(c, Error, ctx.TargetName, append(fields, Field{"panic", recovered})...)
+		panic(recovered)
+	}
+	if includeResults {
+		for i, v := range ctx.TargetOut {
+			fields = append(fields, Field{"result." + fieldName(ctx.TargetOutNames, i), v})
+		}
+	}
+	if err, _ := ctx.LastError(); err != nil {
+		level = Error
+		fields = append(fields, Field{"error", err})
+	}
+	getLogger().Log(c, level, ctx.TargetName, fields...)
+}
+
+// fieldName returns names[i], or i if it is empty.
+func fieldName(names []string, i int) string {
+	if i < len(names) && names[i] != "" {
+		return names[i]
+	}
+	return strconv.Itoa(i)
+}
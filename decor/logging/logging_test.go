@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+type record struct {
+	level  Level
+	msg    string
+	fields map[string]any
+}
+
+// recordLogger sets a backend recording the records, and restores the default
+// backend when the test ends.
+func recordLogger(t *testing.T) *[]record {
+	var records []record
+	SetLogger(Func(func(_ context.Context, level Level, msg string, fields ...Field) {
+		m := map[string]any{}
+		for _, f := range fields {
+			m[f.Key] = f.Value
+		}
+		records = append(records, record{level, msg, m})
+	}))
+	t.Cleanup(func() { SetLogger(nil) })
+	return &records
+}
+
+func plusCtx() *decor.Context {
+	ctx := &decor.Context{
+		TargetName:     "plus",
+		TargetIn:       []any{context.Background(), 1, 2},
+		TargetInNames:  []string{"ctx", "a", ""},
+		TargetOut:      []any{0},
+		TargetOutNames: []string{"sum"},
+		TargetOutTypes: []string{"int"},
+	}
+	ctx.Func = func() { ctx.TargetOut[0] = ctx.TargetIn[1].(int) + ctx.TargetIn[2].(int) }
+	return ctx
+}
+
+func TestLogging(t *testing.T) {
+	records := recordLogger(t)
+	ctx := plusCtx()
+	Logging(ctx, Debug, true, true)
+	if len(*records) != 1 {
+		t.Fatal("Logging() should log one record, got", *records)
+	}
+	r := (*records)[0]
+	if r.level != Debug || r.msg != "plus" || r.fields["arg.a"] != 1 || r.fields["arg.2"] != 2 || r.fields["result.sum"] != 3 {
+		t.Fatalf("Logging() record not match, got %+v", r)
+	}
+	if _, ok := r.fields["arg.ctx"]; ok {
+		t.Fatal("Logging() should not log context.Context parameters")
+	}
+	if _, ok := r.fields["duration"]; !ok {
+		t.Fatal("Logging() should log the duration")
+	}
+
+	*records = nil
+	Logging(plusCtx(), Info, false, false)
+	if r := (*records)[0]; r.level != Info || len(r.fields) != 1 {
+		t.Fatalf("Logging() should not log args and results by default, got %+v", r)
+	}
+}
+
+func TestLoggingError(t *testing.T) {
+	records := recordLogger(t)
+	errFoo := errors.New("foo")
+	ctx := &decor.Context{TargetName: "f", TargetOut: []any{nil}, TargetOutTypes: []string{"error"}}
+	ctx.Func = func() { ctx.TargetOut[0] = errFoo }
+	Logging(ctx, Info, false, false)
+	if r := (*records)[0]; r.level != Error || r.fields["error"] != errFoo {
+		t.Fatalf("Logging() should log the error at Error, got %+v", r)
+	}
+
+	*records = nil
+	ctx = &decor.Context{TargetName: "f"}
+	ctx.Func = func() { panic("boom") }
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Fatal("Logging() should panic again, got", p)
+		}
+		if r := (*records)[0]; r.level != Error || r.fields["panic"] != "boom" {
+			t.Fatalf("Logging() should log the panic at Error, got %+v", r)
+		}
+	}()
+	Logging(ctx, Info, false, false)
+}
+
+func TestLevelString(t *testing.T) {
+	for l, s := range map[Level]string{Debug: "DEBUG", Info: "INFO", Warn: "WARN", Error: "ERROR", 1: "LEVEL(1)"} {
+		if l.String() != s {
+			t.Fatalf("Level.String() want %s, got %s", s, l.String())
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Slog returns a Logger writing records to l, the default slog logger is used
+// if l is nil. The fields are passed as attributes, and the level is checked
+// with Enabled first.
+//
+// Slog 返回写入 log/slog 的后端，l 为 nil 时使用 slog.Default() 。
+func Slog(l *slog.Logger) Logger {
+	return Func(func(ctx context.Context, level Level, msg string, fields ...Field) {
+		out := l
+		if out == nil {
+			out = slog.Default()
+		}
+		if !out.Enabled(ctx, slog.Level(level)) {
+			return
+		}
+		attrs := make([]slog.Attr, 0, len(fields))
+		for _, f := range fields {
+			attrs = append(attrs, slog.Any(f.Key, f.Value))
+		}
+		out.LogAttrs(ctx, slog.Level(level), msg, attrs...)
+	})
+}
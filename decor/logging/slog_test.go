@@ -0,0 +1,29 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := Slog(slog.New(h))
+	l.Log(context.Background(), Debug, "plus", Field{"a", 1})
+	l.Log(context.Background(), Warn, "plus", Field{"a", 1})
+	if s := buf.String(); s != "level=WARN msg=plus a=1\n" {
+		t.Fatal("Slog() output not match, got", s)
+	}
+}
@@ -0,0 +1,214 @@
+// Package metrics provides Prom, a decorator recording the calls of the target
+// as Prometheus metrics. It is a separate module, so the decor package doesn't
+// depend on Prometheus:
+//
+//	go get github.com/dengsgo/go-decorator/decor/metrics
+//
+//	import _ "github.com/dengsgo/go-decorator/decor/metrics"
+//
+//	//go:decor metrics.Prom
+//	func fetch(url string) ([]byte, error) {
+//		// code...
+//	}
+//
+// The metrics are labeled by package and function:
+//
+//	decor_calls_total              counter, the number of calls
+//	decor_errors_total             counter, the number of failed calls
+//	decor_call_duration_seconds    histogram, the duration of calls
+//
+// A call fails if the target panics, or if its last result is a non-nil error,
+// the error rate is decor_errors_total / decor_calls_total.
+//
+// metrics 包提供了将目标函数的调用记录为 Prometheus 指标的装饰器 Prom 。
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options configures the metrics created by New.
+type Options struct {
+	// Namespace is the prefix of the metric names, "decor" if it is empty.
+	Namespace string
+	// Buckets of the duration histogram, prometheus.DefBuckets if it is nil.
+	Buckets []float64
+}
+
+// Metrics is a set of metrics registered by New. Its method Prom is a bound
+// decorator, use it to record into a custom registry:
+//
+//	var appMetrics = metrics.MustNew(registry, metrics.Options{Namespace: "app"})
+//
+//	//go:decor appMetrics.Prom
+//	func fetch(url string) ([]byte, error) {}
+//
+// Metrics 是通过 New 注册的一组指标，它的方法 Prom 可以作为绑定装饰器使用。
+type Metrics struct {
+	calls    *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+var labels = []string{"package", "function"}
+
+// New creates the metrics and registers them with reg. If reg already has the
+// same metrics, for example registered by another New, they are shared.
+func New(reg prometheus.Registerer, opts Options) (*Metrics, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = "decor"
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.DefBuckets
+	}
+	m := &Metrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "calls_total",
+			Help:      "The number of calls of the decorated functions.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "errors_total",
+			Help:      "The number of calls of the decorated functions that panicked or returned a non-nil error.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "call_duration_seconds",
+			Help:      "The duration of calls of the decorated functions.",
+			Buckets:   opts.Buckets,
+		}, labels),
+	}
+	var err error
+	if m.calls, err = register(reg, m.calls); err != nil {
+		return nil, err
+	}
+	if m.errors, err = register(reg, m.errors); err != nil {
+		return nil, err
+	}
+	if m.duration, err = register(reg, m.duration); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MustNew is like New but panics if the metrics can't be registered.
+func MustNew(reg prometheus.Registerer, opts Options) *Metrics {
+	m, err := New(reg, opts)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// register registers c with reg, or returns the collector already registered.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// Prom calls the target and records the call.
+func (m *Metrics) Prom(ctx *decor.Context) {
+	start := time.Now()
+	recovered := ctx.TargetDoSafe()
+	lvs := []string{packageOf(ctx), ctx.TargetName}
+	m.calls.WithLabelValues(lvs...).Inc()
+	m.duration.WithLabelValues(lvs...).Observe(time.Since(start).Seconds())
+	if err, _ := ctx.LastError(); recovered != nil || err != nil {
+		m.errors.WithLabelValues(lvs...).Inc()
+	}
+	if recovered != nil {
+		panic(recovered)
+	}
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultMetrics *Metrics
+)
+
+// SetDefault replaces the metrics used by Prom, for example to record into a
+// custom registry without declaring a Metrics variable for the bound decorator.
+func SetDefault(m *Metrics) {
+	defaultMu.Lock()
+	defaultMetrics = m
+	defaultMu.Unlock()
+}
+
+// Default returns the metrics used by Prom, they are registered with
+// prometheus.DefaultRegisterer on the first call, unless SetDefault was called.
+func Default() *Metrics {
+	defaultMu.RLock()
+	m := defaultMetrics
+	defaultMu.RUnlock()
+	if m != nil {
+		return m
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultMetrics == nil {
+		defaultMetrics = MustNew(prometheus.DefaultRegisterer, Options{})
+	}
+	return defaultMetrics
+}
+
+// Prom calls the target and records the call with the Default metrics.
+//
+// Prom 调用目标函数，并将调用次数、耗时和失败次数记录到默认的指标中。
+func Prom(ctx *decor.Context) {
+	Default().Prom(ctx)
+}
+
+var packages sync.Map // map[uintptr]string
+
+// packageOf returns the import path of the package of the target, TargetPkg
+// of the context. Contexts built by code generated before TargetPkg was set
+// fall back to the package of Func, a closure generated in the target.
+// 目标函数所在的包，优先使用 TargetPkg ，未设置时从 Func 的函数名中解析。
+func packageOf(ctx *decor.Context) string {
+	if ctx.TargetPkg != "" {
+		return ctx.TargetPkg
+	}
+	if ctx.Func == nil {
+		return ""
+	}
+	pc := reflect.ValueOf(ctx.Func).Pointer()
+	if pkg, ok := packages.Load(pc); ok {
+		return pkg.(string)
+	}
+	pkg := ""
+	if f := runtime.FuncForPC(pc); f != nil {
+		pkg = packageOfFuncName(f.Name())
+	}
+	packages.Store(pc, pkg)
+	return pkg
+}
+
+// packageOfFuncName returns the import path of a function name reported by
+// runtime, like "example.com/a/b.(*T).M.func1".
+func packageOfFuncName(name string) string {
+	dir := ""
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		dir, name = name[:i+1], name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return dir + name
+}
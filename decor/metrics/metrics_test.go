@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const pkg = "github.com/dengsgo/go-decorator/decor/metrics"
+
+func errCtx(name string, err error) *decor.Context {
+	ctx := &decor.Context{TargetName: name, TargetOut: []any{nil}, TargetOutTypes: []string{"error"}}
+	ctx.Func = func() { ctx.TargetOut[0] = err }
+	return ctx
+}
+
+func TestProm(t *testing.T) {
+	m := MustNew(prometheus.NewRegistry(), Options{Namespace: "test"})
+	m.Prom(errCtx("fetch", nil))
+	m.Prom(errCtx("fetch", errors.New("foo")))
+
+	if n := testutil.ToFloat64(m.calls.WithLabelValues(pkg, "fetch")); n != 2 {
+		t.Fatal("Prom() calls_total want 2, got", n)
+	}
+	if n := testutil.ToFloat64(m.errors.WithLabelValues(pkg, "fetch")); n != 1 {
+		t.Fatal("Prom() errors_total want 1, got", n)
+	}
+	if n := testutil.CollectAndCount(m.duration, "test_call_duration_seconds"); n != 1 {
+		t.Fatal("Prom() should observe the duration, got", n)
+	}
+
+	ctx := &decor.Context{TargetName: "boom"}
+	ctx.Func = func() { panic("boom") }
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatal("Prom() should panic again, got", r)
+		}
+		if n := testutil.ToFloat64(m.errors.WithLabelValues(pkg, "boom")); n != 1 {
+			t.Fatal("Prom() should count the panic as an error, got", n)
+		}
+	}()
+	m.Prom(ctx)
+}
+
+func TestNewRegistered(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	a := MustNew(reg, Options{})
+	b, err := New(reg, Options{})
+	if err != nil || a.calls != b.calls || a.duration != b.duration {
+		t.Fatal("New() should share the metrics already registered, got", err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	m := MustNew(prometheus.NewRegistry(), Options{})
+	SetDefault(m)
+	defer SetDefault(nil)
+	Prom(errCtx("fetch", nil))
+	if Default() != m || testutil.ToFloat64(m.calls.WithLabelValues(pkg, "fetch")) != 1 {
+		t.Fatal("Prom() should use the metrics set by SetDefault")
+	}
+}
+
+func TestPackageOfFuncName(t *testing.T) {
+	cas := map[string]string{
+		"example.com/a/b.(*T).M.func1":      "example.com/a/b",
+		"main.plus.func1":                   "main",
+		"example.com/a.v2/b.Sum[...].func1": "example.com/a.v2/b",
+	}
+	for name, want := range cas {
+		if got := packageOfFuncName(name); got != want {
+			t.Fatalf("packageOfFuncName(%q) want %s, got %s", name, want, got)
+		}
+	}
+	if got := packageOf(&decor.Context{Func: func() {}}); got != pkg {
+		t.Fatal("packageOf() want", pkg, "got", got)
+	}
+	if got := packageOf(&decor.Context{TargetPkg: "example.com/a", Func: func() {}}); got != "example.com/a" {
+		t.Fatal("packageOf() want example.com/a, got", got)
+	}
+}
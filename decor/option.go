@@ -0,0 +1,43 @@
+package decor
+
+// Option is a parameter passed to a decorator taking options. A decorator
+// declares them as its last, variadic parameter:
+//
+//	func retry(ctx *decor.Context, times int, opts ...decor.Option) {
+//		backoff, _ := decor.OptionAs[string](opts, "backoff")
+//		// code...
+//	}
+//
+//	//go:decor retry#{times: 3, backoff: "exp", codes: {500, 503}}
+//	func call() error {}
+//
+// Keys in the annotation without a matching formal parameter are passed as
+// options sorted by key, here {Key: "backoff", Value: "exp"} and
+// {Key: "codes", Value: []int{500, 503}}. Values keep the default type of
+// their Go literal: string, int, float64 or bool, lists are []string or
+// []int, and an empty list is []any{}.
+//
+// Option 是传给装饰器的选项，注解中没有对应形参的参数按 key 的字典序作为选项传入。
+type Option struct {
+	Key   string
+	Value any
+}
+
+// OptionAs returns the value of the option key as type T. The last option
+// wins if key appears more than once. ok is false if there is no such option
+// or its value isn't of type T.
+//
+//	if codes, ok := decor.OptionAs[[]int](opts, "codes"); ok {
+//		// code...
+//	}
+//
+// OptionAs 返回选项 key 的 T 类型的值，没有这个选项或类型不是 T 时 ok 为 false 。
+func OptionAs[T any](opts []Option, key string) (v T, ok bool) {
+	for i := len(opts) - 1; i >= 0; i-- {
+		if opts[i].Key == key {
+			v, ok = opts[i].Value.(T)
+			return v, ok
+		}
+	}
+	return v, false
+}
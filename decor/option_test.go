@@ -0,0 +1,33 @@
+package decor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptionAs(t *testing.T) {
+	opts := []Option{
+		{"backoff", "exp"},
+		{"codes", []int{500, 503}},
+		{"times", 1},
+		{"times", 3},
+	}
+	if v, ok := OptionAs[string](opts, "backoff"); !ok || v != "exp" {
+		t.Fatalf("OptionAs(backoff) want exp, but got %v %v", v, ok)
+	}
+	if v, ok := OptionAs[[]int](opts, "codes"); !ok || !reflect.DeepEqual(v, []int{500, 503}) {
+		t.Fatalf("OptionAs(codes) want [500 503], but got %v %v", v, ok)
+	}
+	if v, ok := OptionAs[int](opts, "times"); !ok || v != 3 {
+		t.Fatalf("OptionAs(times) should return the last option, but got %v %v", v, ok)
+	}
+	if v, ok := OptionAs[string](opts, "times"); ok || v != "" {
+		t.Fatalf("OptionAs(times) of a mismatched type should return false, but got %q %v", v, ok)
+	}
+	if _, ok := OptionAs[string](opts, "missing"); ok {
+		t.Fatal("OptionAs(missing) should return false")
+	}
+	if _, ok := OptionAs[string](nil, "backoff"); ok {
+		t.Fatal("OptionAs on nil options should return false")
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/dengsgo/go-decorator/decor/otel
+
+go 1.18
+
+require (
+	github.com/dengsgo/go-decorator v0.22.0
+	go.opentelemetry.io/otel v1.11.2
+	go.opentelemetry.io/otel/sdk v1.11.2
+	go.opentelemetry.io/otel/trace v1.11.2
+)
+
+require (
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8 // indirect
+)
+
+replace github.com/dengsgo/go-decorator => ../..
@@ -0,0 +1,132 @@
+// Package otel provides Trace, a decorator that traces the target with
+// OpenTelemetry. It is a separate module, so the decor package doesn't depend
+// on OpenTelemetry:
+//
+//	go get github.com/dengsgo/go-decorator/decor/otel
+//
+//	import _ "github.com/dengsgo/go-decorator/decor/otel"
+//
+//	//go:decor otel.Trace
+//	func fetch(ctx context.Context, url string) ([]byte, error) {
+//		// code...
+//	}
+//
+// Spans are created by the global TracerProvider, set it with
+// otel.SetTracerProvider as usual.
+//
+// otel 包提供了使用 OpenTelemetry 追踪目标函数的装饰器 Trace 。
+package otel
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/dengsgo/go-decorator/decor/otel"
+
+// The global tracer delegates to the TracerProvider set by otel.SetTracerProvider,
+// even if it is set after this.
+var tracer = otel.Tracer(instrumentationName)
+
+// Trace starts a span named after the target for each call:
+//
+//	//go:decor otel.Trace#{args: true}
+//	func fetch(ctx context.Context, url string) ([]byte, error) {}
+//
+// The parent of the span is Ctx, which is the first parameter of the target if
+// it is a context.Context, and the span context is passed to the target through
+// it, so spans started by the target are children of this span.
+//
+// With args the parameters of the target are recorded as span attributes named
+// decor.arg.<name>, they are not recorded by default because they may hold
+// sensitive data. A panic of the target, or a non-nil error as its last result,
+// is recorded on the span and sets its status to Error.
+//
+// Trace 为每次调用开启一个以目标函数命名的 span ，args 为 true 时将参数记录为 span 的属性。
+func Trace(ctx *decor.Context, args bool) {
+	old := ctx.Ctx
+	parent := old
+	if parent == nil {
+		parent = context.Background()
+	}
+	c, span := tracer.Start(parent, ctx.TargetName, trace.WithAttributes(codeAttrs(ctx)...))
+	defer span.End()
+	ctx.SetCtx(c)
+	// 恢复原来的 Ctx ，外层装饰器再次调用时 span 不会嵌套在这次调用的 span 中
+	defer func() {
+		if old == nil {
+			ctx.Ctx = nil
+			return
+		}
+		ctx.SetCtx(old)
+	}()
+	if args {
+		span.SetAttributes(argAttrs(ctx)...)
+	}
+
+	if recovered := ctx.TargetDoSafe(); recovered != nil {
+		err := fmt.Errorf("panic: %v", recovered)
+		span.RecordError(err, trace.WithStackTrace(true))
+		span.SetStatus(codes.Error, err.Error())
+		panic(recovered)
+	}
+	if err, _ := ctx.LastError(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// codeAttrs returns the code.* attributes of the target.
+func codeAttrs(ctx *decor.Context) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("code.function", ctx.TargetName)}
+	if ctx.Kind == decor.KMethod {
+		attrs = append(attrs, attribute.String("code.namespace", fmt.Sprintf("%T", ctx.Receiver)))
+	}
+	return attrs
+}
+
+// argAttrs returns the parameters of the target as attributes, context.Context
+// parameters are skipped.
+func argAttrs(ctx *decor.Context) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(ctx.TargetIn))
+	for i, v := range ctx.TargetIn {
+		if _, ok := v.(context.Context); ok {
+			continue
+		}
+		name := strconv.Itoa(i)
+		if i < len(ctx.TargetInNames) && ctx.TargetInNames[i] != "" {
+			name = ctx.TargetInNames[i]
+		}
+		attrs = append(attrs, attrOf("decor.arg."+name, v))
+	}
+	return attrs
+}
+
+// attrOf converts v to an attribute, the types without a matching attribute
+// type are formatted with fmt.Sprint, which also calls the String method.
+func attrOf(key string, v any) attribute.KeyValue {
+	switch v := v.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	case []int:
+		return attribute.IntSlice(key, v)
+	}
+	return attribute.String(key, fmt.Sprint(v))
+}
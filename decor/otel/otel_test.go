@@ -0,0 +1,110 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var recorder = tracetest.NewSpanRecorder()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+}
+
+// lastSpan returns the last ended span.
+func lastSpan(t *testing.T) sdktrace.ReadOnlySpan {
+	spans := recorder.Ended()
+	if len(spans) == 0 {
+		t.Fatal("no span ended")
+	}
+	return spans[len(spans)-1]
+}
+
+func attrMap(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestTrace(t *testing.T) {
+	parent, root := otel.Tracer("test").Start(context.Background(), "root")
+	defer root.End()
+	ctx := &decor.Context{
+		Kind:           decor.KFunc,
+		TargetName:     "fetch",
+		TargetIn:       []any{parent, "http://a", 3},
+		TargetInNames:  []string{"ctx", "url", ""},
+		TargetOut:      []any{nil},
+		TargetOutTypes: []string{"error"},
+		Ctx:            parent,
+	}
+	var got trace.SpanContext
+	ctx.Func = func() {
+		got = trace.SpanContextFromContext(ctx.TargetIn[0].(context.Context))
+		ctx.TargetOut[0] = errors.New("foo")
+	}
+	Trace(ctx, true)
+
+	span := lastSpan(t)
+	if span.Name() != "fetch" || span.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Fatal("Trace() span should be named after the target and be a child of Ctx, got", span.Name(), span.Parent())
+	}
+	if got.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("Trace() target should receive the span context")
+	}
+	if ctx.Ctx != parent || ctx.TargetIn[0] != parent {
+		t.Fatal("Trace() should restore the context after the call")
+	}
+	attrs := attrMap(span)
+	if attrs["code.function"].AsString() != "fetch" || attrs["decor.arg.url"].AsString() != "http://a" ||
+		attrs["decor.arg.2"].AsInt64() != 3 {
+		t.Fatal("Trace() attributes not match, got", span.Attributes())
+	}
+	if _, ok := attrs["decor.arg.ctx"]; ok {
+		t.Fatal("Trace() should not record context.Context parameters")
+	}
+	if span.Status().Code != codes.Error || span.Status().Description != "foo" {
+		t.Fatal("Trace() should set the error status, got", span.Status())
+	}
+}
+
+func TestTraceWithoutArgs(t *testing.T) {
+	ctx := &decor.Context{
+		TargetName: "plus",
+		TargetIn:   []any{1, 2},
+		TargetOut:  []any{0},
+	}
+	ctx.Func = func() { ctx.TargetOut[0] = 3 }
+	Trace(ctx, false)
+
+	span := lastSpan(t)
+	if _, ok := attrMap(span)["decor.arg.0"]; ok || ctx.Ctx != nil || span.Status().Code == codes.Error {
+		t.Fatal("Trace() should not record args by default, got", span.Attributes(), ctx.Ctx, span.Status())
+	}
+}
+
+func TestTracePanic(t *testing.T) {
+	ctx := &decor.Context{TargetName: "boom"}
+	ctx.Func = func() { panic("boom") }
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatal("Trace() should panic again, got", r)
+		}
+		span := lastSpan(t)
+		if span.Name() != "boom" || span.Status().Code != codes.Error || len(span.Events()) == 0 {
+			t.Fatal("Trace() should record the panic, got", span.Status(), span.Events())
+		}
+	}()
+	Trace(ctx, false)
+}
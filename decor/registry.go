@@ -0,0 +1,60 @@
+package decor
+
+import (
+	"sort"
+	"sync"
+)
+
+// Decorated describes a function decorated with `//go:decor` annotations.
+//
+// Decorated 描述一个使用了 `//go:decor` 注释的函数。
+type Decorated struct {
+	// Pkg is the import path of the package, "main" for the main package.
+	Pkg string
+	// Name is the function name, methods are named like "T.Name" or "(*T).Name".
+	Name string
+	// Decorators are the decorator names as written in the annotations,
+	// from the outermost layer to the innermost one.
+	Decorators []string
+}
+
+var registry struct {
+	sync.Mutex
+	list []Decorated
+}
+
+// Register records a decorated function. It is called by the init function
+// generated for every file that contains decorated functions, and usually
+// there is no need to call it by hand.
+//
+// Register 记录一个被装饰的函数，由生成的 init 函数调用。
+func Register(pkg, name string, decorators ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.list = append(registry.list, Decorated{
+		Pkg:        pkg,
+		Name:       name,
+		Decorators: append([]string(nil), decorators...),
+	})
+}
+
+// ListDecorated returns all registered decorated functions sorted by Pkg and
+// Name, for example to show them on a diagnostics page.
+//
+// ListDecorated 返回所有已注册的被装饰函数，按 Pkg 、Name 排序。
+func ListDecorated() []Decorated {
+	registry.Lock()
+	defer registry.Unlock()
+	list := make([]Decorated, len(registry.list))
+	for i, d := range registry.list {
+		d.Decorators = append([]string(nil), d.Decorators...)
+		list[i] = d
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Pkg != list[j].Pkg {
+			return list[i].Pkg < list[j].Pkg
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
@@ -0,0 +1,27 @@
+package decor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	registry.list = nil
+	defer func() { registry.list = nil }()
+	Register("b", "f", "logging")
+	Register("a", "(*T).Get", "logging", "timing")
+	Register("a", "T.Set")
+	list := ListDecorated()
+	want := []Decorated{
+		{"a", "(*T).Get", []string{"logging", "timing"}},
+		{"a", "T.Set", nil},
+		{"b", "f", []string{"logging"}},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Fatalf("ListDecorated() want %+v, but got %+v", want, list)
+	}
+	list[0].Decorators[0] = "x"
+	if ListDecorated()[0].Decorators[0] != "logging" {
+		t.Fatal("ListDecorated() should return a copy")
+	}
+}
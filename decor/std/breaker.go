@@ -0,0 +1,86 @@
+package std
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// CircuitBreaker stops calling the target after it fails failures times in a row:
+//
+//	//go:decor std.CircuitBreaker#{name: "payment", failures: 5, openMs: 30000}
+//	func charge(order string) error {}
+//
+// While the breaker is open, calls are rejected with ErrCircuitOpen for openMs
+// milliseconds. After that one call is let through as a trial, the breaker is
+// closed if it succeeds and opened again if it fails. A panic of the target counts
+// as a failure and is raised again.
+//
+// The state is shared by the targets using the same name, it defaults to the name
+// of the target (Type.Method for methods) together with the import path of its
+// package, so targets of different packages with the same name don't share it.
+//
+// CircuitBreaker 在目标函数连续失败 failures 次后熔断，openMs 毫秒内的调用直接返回 ErrCircuitOpen 。
+//
+//go:decor-lint required: {failures: {gte: 1}, openMs: {gte: 1}}
+func CircuitBreaker(ctx *decor.Context, name string, failures, openMs int) {
+	b := breakerOf(stateKey(ctx, name))
+	allowed, trial := b.allow(time.Now())
+	if !allowed {
+		reject(ctx, ErrCircuitOpen)
+		return
+	}
+	failed, recovered := call(ctx)
+	b.done(trial, failed, failures, millis(openMs), time.Now())
+	if recovered != nil {
+		panic(recovered)
+	}
+}
+
+var breakers sync.Map // map[string]*breaker
+
+type breaker struct {
+	mu        sync.Mutex
+	failures  int       // 连续失败的次数
+	openUntil time.Time // 熔断的截止时间，零值表示没有熔断
+	trial     bool      // 熔断结束后是否有一个试探调用正在执行
+}
+
+func breakerOf(key string) *breaker {
+	b, _ := breakers.LoadOrStore(key, &breaker{})
+	return b.(*breaker)
+}
+
+// allow reports whether a call can be made at now, and whether it is the trial
+// call after the breaker was open.
+func (b *breaker) allow(now time.Time) (allowed, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true, false
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false, false
+	}
+	b.trial = true
+	return true, true
+}
+
+// done records the result of a call allowed by allow.
+func (b *breaker) done(trial, failed bool, failures int, open time.Duration, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if trial {
+		b.trial = false
+	}
+	if !failed {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= failures || !b.openUntil.IsZero() {
+		b.openUntil = now.Add(open)
+	}
+}
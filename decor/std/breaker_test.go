@@ -0,0 +1,73 @@
+package std
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breakers.Delete("breakerTarget")
+	fail := true
+	n := 0
+	// each call builds its own context, like the generated code
+	call := func() *decor.Context {
+		ctx := errCtx("breakerTarget", func() error {
+			n++
+			if fail {
+				return errors.New("foo")
+			}
+			return nil
+		})
+		CircuitBreaker(ctx, "", 2, 20)
+		return ctx
+	}
+	var ctx *decor.Context
+	for i := 0; i < 3; i++ {
+		ctx = call()
+	}
+	if n != 2 || ctx.TargetOut[0] != ErrCircuitOpen || !ctx.Stopped() {
+		t.Fatal("CircuitBreaker() should open after 2 failures and stop the call, got", n, ctx.TargetOut)
+	}
+
+	// the trial call fails and the breaker opens again
+	time.Sleep(25 * time.Millisecond)
+	if ctx = call(); ctx.Stopped() {
+		t.Fatal("CircuitBreaker() should not stop the trial call")
+	}
+	ctx = call()
+	if n != 3 || ctx.TargetOut[0] != ErrCircuitOpen {
+		t.Fatal("CircuitBreaker() should open again after the trial call fails, got", n, ctx.TargetOut)
+	}
+
+	// the trial call succeeds and the breaker closes
+	time.Sleep(25 * time.Millisecond)
+	fail = false
+	call()
+	ctx = call()
+	if n != 5 || ctx.TargetOut[0] != nil || ctx.Stopped() {
+		t.Fatal("CircuitBreaker() should close after the trial call succeeds, got", n, ctx.TargetOut)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	b := &breaker{}
+	now := time.Now()
+	b.done(false, true, 2, time.Second, now)
+	if allowed, _ := b.allow(now); !allowed {
+		t.Fatal("breaker should be closed before reaching failures")
+	}
+	b.done(false, true, 2, time.Second, now)
+	if allowed, _ := b.allow(now.Add(time.Millisecond)); allowed {
+		t.Fatal("breaker should be open after reaching failures")
+	}
+	allowed, trial := b.allow(now.Add(time.Second))
+	if !allowed || !trial {
+		t.Fatal("breaker should allow a trial call after it was open, got", allowed, trial)
+	}
+	if allowed, _ := b.allow(now.Add(time.Second)); allowed {
+		t.Fatal("breaker should allow only one trial call at a time")
+	}
+}
@@ -0,0 +1,77 @@
+package std
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// RateLimit limits the target to rate calls per second, with bursts of up to
+// burst calls (1 if it is less than 1):
+//
+//	//go:decor std.RateLimit#{rate: 100, burst: 10, wait: true}
+//	func send(msg string) error {}
+//
+// A call exceeding the limit is rejected with ErrRateLimited, or with wait it
+// waits for its turn. A waiting call is rejected if the Ctx of the context is
+// done first.
+//
+// The limit is shared by the targets using the same name, it defaults to the
+// name of the target (Type.Method for methods) together with the import path of
+// its package.
+//
+// RateLimit 使用令牌桶将目标函数限制为每秒 rate 次，超出时返回 ErrRateLimited 或在 wait 为 true 时等待。
+//
+//go:decor-lint required: {rate: {gt: 0}}
+func RateLimit(ctx *decor.Context, name string, rate float64, burst int, wait bool) {
+	if burst < 1 {
+		burst = 1
+	}
+	d := limiterOf(stateKey(ctx, name)).reserve(rate, burst, wait, time.Now())
+	if d < 0 || !sleep(ctx, d) {
+		reject(ctx, ErrRateLimited)
+		return
+	}
+	ctx.TargetDo()
+}
+
+var limiters sync.Map // map[string]*limiter
+
+// limiter is a token bucket.
+type limiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time // tokens 最近一次更新的时间
+}
+
+func limiterOf(key string) *limiter {
+	l, _ := limiters.LoadOrStore(key, &limiter{})
+	return l.(*limiter)
+}
+
+// reserve takes a token at now and returns how long to wait before using it.
+// If there is no token and wait is false, it returns -1 and takes nothing.
+func (l *limiter) reserve(rate float64, burst int, wait bool, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.last.IsZero() {
+		l.tokens = float64(burst)
+	} else if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * rate
+		if l.tokens > float64(burst) {
+			l.tokens = float64(burst)
+		}
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	if !wait {
+		return -1
+	}
+	// 预支一个令牌，等待它生成
+	l.tokens--
+	return time.Duration((-l.tokens) / rate * float64(time.Second))
+}
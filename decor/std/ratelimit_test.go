@@ -0,0 +1,54 @@
+package std
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiters.Delete("rateTarget")
+	limiters.Delete("rateWait")
+	n := 0
+	var ctx *decor.Context
+	for i := 0; i < 3; i++ {
+		ctx = errCtx("rateTarget", func() error { n++; return nil })
+		RateLimit(ctx, "", 1, 2, false)
+		if rejected := i == 2; ctx.Stopped() != rejected {
+			t.Fatalf("RateLimit() call %d want Stopped %v, got %v", i, rejected, ctx.Stopped())
+		}
+	}
+	if n != 2 || ctx.TargetOut[0] != ErrRateLimited {
+		t.Fatal("RateLimit() should reject the calls after the burst, got", n, ctx.TargetOut)
+	}
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		ctx = errCtx("rateWait", func() error { n++; return nil })
+		RateLimit(ctx, "", 100, 1, true)
+	}
+	if d := time.Since(start); ctx.TargetOut[0] != nil || d < 5*time.Millisecond {
+		t.Fatal("RateLimit() with wait should wait for the next token, got", ctx.TargetOut, d)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := &limiter{}
+	now := time.Now()
+	if d := l.reserve(10, 1, false, now); d != 0 {
+		t.Fatal("limiter should start with a full bucket, got", d)
+	}
+	if d := l.reserve(10, 1, false, now); d != -1 {
+		t.Fatal("limiter without tokens should reject, got", d)
+	}
+	if d := l.reserve(10, 1, true, now); d != 100*time.Millisecond {
+		t.Fatal("limiter with wait should wait for the next token, got", d)
+	}
+	if d := l.reserve(10, 1, false, now.Add(time.Hour)); d != 0 {
+		t.Fatal("limiter should refill up to burst, got", d)
+	}
+	if d := l.reserve(10, 1, false, now.Add(time.Hour)); d != -1 {
+		t.Fatal("limiter should not refill above burst, got", d)
+	}
+}
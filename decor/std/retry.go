@@ -0,0 +1,57 @@
+package std
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// Retry calls the target up to attempts times until it succeeds:
+//
+//	//go:decor std.Retry#{attempts: 3, backoffMs: 100, maxBackoffMs: 1000}
+//	func fetch(url string) ([]byte, error) {}
+//
+// It waits backoffMs milliseconds before the second attempt, and multiplies the
+// wait by multiplier (2 if it is less than 1) before each following attempt, up to
+// maxBackoffMs if it is positive. With jitter the wait is a random duration between
+// half of it and all of it, so that clients don't retry at the same time.
+//
+// The target keeps the result of the last attempt, and the panic of the last
+// attempt is raised again. If the Ctx of the context is done while waiting, Retry
+// stops and keeps the last result. Targets marked with //go:decor-lint once: true
+// can't use Retry.
+//
+// Retry 在目标函数失败时重试，最多调用 attempts 次，每次重试前按指数退避等待。
+//
+//go:decor-lint required: {attempts: {gte: 1, lte: 100}}
+func Retry(ctx *decor.Context, attempts, backoffMs, maxBackoffMs int, multiplier float64, jitter bool) {
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	backoff := millis(backoffMs)
+	for i := 1; ; i++ {
+		failed, recovered := call(ctx)
+		if !failed {
+			return
+		}
+		if i >= attempts || !sleep(ctx, wait(backoff, jitter)) {
+			if recovered != nil {
+				panic(recovered)
+			}
+			return
+		}
+		backoff = time.Duration(float64(backoff) * multiplier)
+		if maxBackoffMs > 0 && backoff > millis(maxBackoffMs) {
+			backoff = millis(maxBackoffMs)
+		}
+	}
+}
+
+// wait returns the duration to wait before the next attempt.
+func wait(backoff time.Duration, jitter bool) time.Duration {
+	if !jitter || backoff <= 1 {
+		return backoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
@@ -0,0 +1,77 @@
+package std
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	errFoo := errors.New("foo")
+	n := 0
+	ctx := errCtx("f", func() error {
+		if n++; n < 3 {
+			return errFoo
+		}
+		return nil
+	})
+	Retry(ctx, 5, 0, 0, 0, false)
+	if n != 3 || ctx.TargetOut[0] != nil {
+		t.Fatal("Retry() should stop after the first success, got", n, ctx.TargetOut)
+	}
+
+	n = 0
+	ctx = errCtx("f", func() error { n++; return errFoo })
+	Retry(ctx, 3, 0, 0, 0, false)
+	if n != 3 || ctx.TargetOut[0] != errFoo {
+		t.Fatal("Retry() should keep the last error after all attempts, got", n, ctx.TargetOut)
+	}
+
+	n = 0
+	ctx = errCtx("f", func() error { n++; panic("boom") })
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" || n != 2 {
+				t.Fatal("Retry() should panic with the last panic, got", r, n)
+			}
+		}()
+		Retry(ctx, 2, 0, 0, 0, false)
+	}()
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var at []time.Time
+	ctx := errCtx("f", func() error { at = append(at, time.Now()); return errors.New("foo") })
+	Retry(ctx, 4, 10, 25, 2, false)
+	if len(at) != 4 {
+		t.Fatal("Retry() attempts not match, got", len(at))
+	}
+	for i, want := range []time.Duration{10, 20, 25} {
+		if d := at[i+1].Sub(at[i]); d < want*time.Millisecond {
+			t.Fatalf("Retry() wait before attempt %d should be at least %dms, got %s", i+2, want, d)
+		}
+	}
+
+	// the backoff stops when Ctx is done
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := 0
+	ctx = errCtx("f", func() error { n++; return errors.New("foo") })
+	ctx.Ctx = c
+	Retry(ctx, 3, 1000, 0, 0, false)
+	if n != 1 {
+		t.Fatal("Retry() should stop waiting when Ctx is done, got", n)
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	if d := wait(100, false); d != 100 {
+		t.Fatal("wait() without jitter should be the backoff, got", d)
+	}
+	for i := 0; i < 100; i++ {
+		if d := wait(100, true); d < 50 || d > 100 {
+			t.Fatal("wait() with jitter should be in [backoff/2, backoff], got", d)
+		}
+	}
+}
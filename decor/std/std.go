@@ -0,0 +1,98 @@
+// Package std provides the decorators most projects need: Retry, Timeout,
+// CircuitBreaker, RateLimit and WrapError. They are configured with the annotation
+// parameters and checked by //go:decor-lint at compile time:
+//
+//	import _ "github.com/dengsgo/go-decorator/decor/std"
+//
+//	//go:decor std.Retry#{attempts: 3, backoffMs: 100}
+//	func fetch(ctx context.Context, url string) ([]byte, error) {
+//		// code...
+//	}
+//
+// A call fails if the target panics, or if its last result is an error and
+// it is not nil. When a decorator rejects a call without calling the target,
+// it returns its error (ErrTimeout, ErrCircuitOpen or ErrRateLimited) through
+// that error result, or panics with it if the target has no error result.
+// CircuitBreaker and RateLimit also stop the calls they reject, see
+// decor.Context.Stop.
+//
+// std 包提供了常用的装饰器：重试、超时、熔断、限流和包装错误，通过注解参数配置，编译时由 //go:decor-lint 检查参数。
+package std
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+var (
+	// ErrTimeout is the error result of a call that exceeds the timeout of Timeout.
+	ErrTimeout = errors.New("decor/std: timeout")
+
+	// ErrCircuitOpen is the error result of a call rejected by an open CircuitBreaker.
+	ErrCircuitOpen = errors.New("decor/std: circuit breaker is open")
+
+	// ErrRateLimited is the error result of a call rejected by RateLimit.
+	ErrRateLimited = errors.New("decor/std: rate limited")
+)
+
+// reject stops the call and returns err through the error result of the
+// target without calling it, or panics with err if the target has no error result.
+func reject(ctx *decor.Context, err error) {
+	ctx.Stop()
+	if _, ok := ctx.LastError(); !ok {
+		panic(err)
+	}
+	ctx.SetLastError(err)
+}
+
+// call calls the target and reports whether it failed. A panic of the target
+// is recovered and returned, the caller decides whether to panic again.
+func call(ctx *decor.Context) (failed bool, recovered any) {
+	recovered = ctx.TargetDoSafe()
+	err, _ := ctx.LastError()
+	return recovered != nil || err != nil, recovered
+}
+
+// stateKey names the state shared by the calls of a target, the name given in
+// the annotation or the qualified name of the target, prefixed with the import
+// path of its package when it is known.
+func stateKey(ctx *decor.Context, name string) string {
+	if name != "" {
+		return name
+	}
+	key := ctx.TargetName
+	if ctx.Kind == decor.KMethod {
+		key = fmt.Sprintf("%T.%s", ctx.Receiver, ctx.TargetName)
+	}
+	if ctx.TargetPkg != "" {
+		return ctx.TargetPkg + "." + key
+	}
+	return key
+}
+
+// sleep waits for d, it returns false if Ctx is done before that.
+func sleep(ctx *decor.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	done := context.Background().Done()
+	if ctx.Ctx != nil {
+		done = ctx.Ctx.Done()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func millis(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
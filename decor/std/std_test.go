@@ -0,0 +1,80 @@
+package std
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// errCtx returns a context of a target func() error that calls fn.
+func errCtx(name string, fn func() error) *decor.Context {
+	ctx := &decor.Context{
+		Kind:           decor.KFunc,
+		TargetName:     name,
+		TargetOut:      []any{nil},
+		TargetOutTypes: []string{"error"},
+	}
+	ctx.Func = func() { ctx.TargetOut[0] = fn() }
+	return ctx
+}
+
+func TestReject(t *testing.T) {
+	errFoo := errors.New("foo")
+	ctx := errCtx("f", func() error { return nil })
+	reject(ctx, errFoo)
+	if ctx.TargetOut[0] != errFoo || ctx.DoRef() != 0 || !ctx.Stopped() {
+		t.Fatal("reject() should stop the call and set the error result without calling the target, got", ctx.TargetOut, ctx.DoRef())
+	}
+
+	ctx = &decor.Context{TargetOut: []any{1}, TargetOutTypes: []string{"int"}}
+	defer func() {
+		if r := recover(); r != errFoo || !ctx.Stopped() {
+			t.Fatal("reject() should stop the call and panic without error result, got", r)
+		}
+	}()
+	reject(ctx, errFoo)
+}
+
+func TestCall(t *testing.T) {
+	errFoo := errors.New("foo")
+	cas := []struct {
+		fn        func() error
+		failed    bool
+		recovered any
+	}{
+		{func() error { return nil }, false, nil},
+		{func() error { return errFoo }, true, nil},
+		{func() error { panic("boom") }, true, "boom"},
+	}
+	for i, c := range cas {
+		failed, recovered := call(errCtx("f", c.fn))
+		if failed != c.failed || recovered != c.recovered {
+			t.Fatalf("call() cas[%d] want %v %v, got %v %v", i, c.failed, c.recovered, failed, recovered)
+		}
+	}
+}
+
+type keyT struct{}
+
+func (keyT) m() {}
+
+func TestStateKey(t *testing.T) {
+	cas := []struct {
+		ctx  *decor.Context
+		name string
+		want string
+	}{
+		{&decor.Context{TargetName: "f"}, "", "f"},
+		{&decor.Context{TargetName: "f"}, "api", "api"},
+		{&decor.Context{Kind: decor.KMethod, TargetName: "m", Receiver: &keyT{}}, "", "*std.keyT.m"},
+		{&decor.Context{TargetName: "f", TargetPkg: "example.com/a"}, "", "example.com/a.f"},
+		{&decor.Context{TargetName: "f", TargetPkg: "example.com/a"}, "api", "api"},
+		{&decor.Context{Kind: decor.KMethod, TargetName: "m", Receiver: &keyT{}, TargetPkg: "example.com/a"}, "", "example.com/a.*std.keyT.m"},
+	}
+	for i, c := range cas {
+		if got := stateKey(c.ctx, c.name); got != c.want {
+			t.Fatalf("stateKey() cas[%d] want %s, got %s", i, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package std
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// Timeout sets a deadline of ms milliseconds on the call:
+//
+//	//go:decor std.Timeout#{ms: 500}
+//	func query(ctx context.Context, sql string) (*Rows, error) {}
+//
+// The deadline is added to Ctx with context.WithTimeout, so if the first parameter
+// of the target is a context.Context, the target is called with the new context and
+// should return when it is done. The target is not interrupted otherwise: Go can't
+// stop a running function, and running it in another goroutine would leave it
+// writing the results after the decorator returns.
+//
+// If the call exceeds the deadline and its error result is nil, or is the
+// context.DeadlineExceeded error of that deadline, it is replaced by ErrTimeout.
+//
+// Timeout 为调用设置 ms 毫秒的截止时间，目标函数的第一个参数是 context.Context 时会收到带截止时间的 context 。
+//
+//go:decor-lint required: {ms: {gte: 1}}
+func Timeout(ctx *decor.Context, ms int) {
+	old := ctx.Ctx
+	parent := old
+	if parent == nil {
+		parent = context.Background()
+	}
+	c, cancel := context.WithTimeout(parent, millis(ms))
+	defer cancel()
+	ctx.SetCtx(c)
+	// 恢复原来的 Ctx ，外层的 Retry 重试时重新计算截止时间
+	defer func() {
+		if old == nil {
+			ctx.Ctx = nil
+			return
+		}
+		ctx.SetCtx(old)
+	}()
+	ctx.TargetDo()
+	if c.Err() != context.DeadlineExceeded || parent.Err() != nil {
+		return
+	}
+	if err, ok := ctx.LastError(); ok && (err == nil || errors.Is(err, context.DeadlineExceeded)) {
+		ctx.SetLastError(ErrTimeout)
+	}
+}
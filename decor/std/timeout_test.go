@@ -0,0 +1,48 @@
+package std
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func TestTimeout(t *testing.T) {
+	ctx := errCtx("f", func() error { time.Sleep(20 * time.Millisecond); return nil })
+	Timeout(ctx, 1)
+	if ctx.TargetOut[0] != ErrTimeout || ctx.Ctx != nil {
+		t.Fatal("Timeout() should return ErrTimeout after the deadline, got", ctx.TargetOut, ctx.Ctx)
+	}
+
+	ctx = errCtx("f", func() error { return nil })
+	Timeout(ctx, 1000)
+	if ctx.TargetOut[0] != nil {
+		t.Fatal("Timeout() should keep the result within the deadline, got", ctx.TargetOut)
+	}
+}
+
+func TestTimeoutCtx(t *testing.T) {
+	parent := context.WithValue(context.Background(), keyT{}, 1)
+	ctx := &decor.Context{
+		TargetIn:       []any{parent},
+		TargetOut:      []any{nil},
+		TargetOutTypes: []string{"error"},
+		Ctx:            parent,
+	}
+	ctx.Func = func() {
+		c := ctx.TargetIn[0].(context.Context)
+		if _, ok := c.Deadline(); !ok || c.Value(keyT{}) != 1 {
+			t.Fatal("Timeout() target should receive the context with deadline")
+		}
+		<-c.Done()
+		ctx.TargetOut[0] = c.Err()
+	}
+	Timeout(ctx, 5)
+	if ctx.TargetOut[0] != ErrTimeout {
+		t.Fatal("Timeout() should replace context.DeadlineExceeded by ErrTimeout, got", ctx.TargetOut)
+	}
+	if ctx.Ctx != parent || ctx.TargetIn[0] != parent {
+		t.Fatal("Timeout() should restore the context after the call")
+	}
+}
@@ -0,0 +1,50 @@
+package std
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// WrapError annotates the error returned by the target with the name of the
+// target and the parameters named in args:
+//
+//	//go:decor std.WrapError#{args: ["id"]}
+//	func load(id int, force bool) (*User, error) {}
+//
+// If load(7, true) returns err, the error becomes "load(id=7): err". It wraps
+// err with %w, so errors.Is and errors.As still find it. Names in args that
+// aren't parameters of the target are ignored, and targets without an error
+// result are not changed.
+//
+// WrapError 使用目标函数名和 args 中列出的参数包装目标函数返回的错误。
+func WrapError(ctx *decor.Context, args []string) {
+	ctx.TargetDo()
+	if err, _ := ctx.LastError(); err != nil {
+		ctx.SetLastError(fmt.Errorf("%s(%s): %w", ctx.TargetName, formatArgs(ctx, args), err))
+	}
+}
+
+// formatArgs formats the parameters named in names as "a=1, s=\"x\"", in the
+// order of names.
+func formatArgs(ctx *decor.Context, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		for i, in := range ctx.TargetInNames {
+			if in != name || i >= len(ctx.TargetIn) {
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteString(", ")
+			}
+			if s, ok := ctx.TargetIn[i].(string); ok {
+				fmt.Fprintf(&b, "%s=%q", name, s)
+			} else {
+				fmt.Fprintf(&b, "%s=%v", name, ctx.TargetIn[i])
+			}
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package std
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func TestWrapError(t *testing.T) {
+	errFoo := errors.New("foo")
+	ctx := errCtx("load", func() error { return errFoo })
+	ctx.TargetIn = []any{7, "x", true}
+	ctx.TargetInNames = []string{"id", "name", "force"}
+	WrapError(ctx, []string{"name", "id", "notExist"})
+	err, _ := ctx.LastError()
+	if err == nil || err.Error() != `load(name="x", id=7): foo` || !errors.Is(err, errFoo) {
+		t.Fatal("WrapError() should wrap the error, got", err)
+	}
+
+	ctx = errCtx("load", func() error { return errFoo })
+	WrapError(ctx, nil)
+	if err, _ := ctx.LastError(); err == nil || err.Error() != "load(): foo" {
+		t.Fatal("WrapError() without args should wrap the error with the name, got", err)
+	}
+
+	ctx = errCtx("load", func() error { return nil })
+	WrapError(ctx, []string{"id"})
+	if ctx.TargetOut[0] != nil {
+		t.Fatal("WrapError() should keep a nil error, got", ctx.TargetOut)
+	}
+
+	ctx = &decor.Context{TargetName: "plus", TargetOut: []any{1}, TargetOutTypes: []string{"int"}}
+	ctx.Func = func() {}
+	WrapError(ctx, []string{"a"})
+	if ctx.TargetOut[0] != 1 || ctx.DoRef() != 1 {
+		t.Fatal("WrapError() should not change a target without error result, got", ctx.TargetOut)
+	}
+}
@@ -0,0 +1,104 @@
+package decor
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DisableEnvKey is the environment variable read when the program starts, a
+// comma-separated list of decorators to disable, for example
+//
+//	GODECOR_DISABLE=example.com/app.logging,github.com/dengsgo/go-decorator/decor/std.Logging ./app
+//
+// DisableEnvKey 是程序启动时读取的环境变量，逗号分隔的要禁用的装饰器。
+const DisableEnvKey = "GODECOR_DISABLE"
+
+var toggles struct {
+	sync.Mutex
+	n        int32        // the number of disabled decorators, read atomically
+	disabled atomic.Value // map[string]bool, replaced as a whole on every change
+}
+
+func init() {
+	var names []string
+	for _, name := range strings.Split(os.Getenv(DisableEnvKey), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	Disable(names...)
+}
+
+// Disable turns the decorators off at run time, a disabled decorator is
+// skipped and the next layer (or the target) is called in its place. The
+// decorators are named by the import path of their package and their name,
+// such as "example.com/app.logging" or "github.com/dengsgo/go-decorator/decor/std.Logging",
+// so decorators of the same name in different packages are switched apart.
+// A decorator declared in a main package is named like "main.logging".
+//
+// Only the code generated with `-toolexec 'decorator -d.toggle'` checks it,
+// the decorators of other builds are always on.
+//
+// Disable 在运行时关闭装饰器，被关闭的装饰器被跳过，直接调用内一层（或目标函数）。
+// 装饰器的名字为所在包的导入路径加上函数名，不同包中的同名装饰器分别开关。只有使用 -d.toggle 编译时生成的代码会检查它。
+func Disable(names ...string) {
+	setEnabled(names, false)
+}
+
+// Enable turns the decorators disabled by Disable or GODECOR_DISABLE on again.
+//
+// Enable 重新打开被 Disable 或 GODECOR_DISABLE 关闭的装饰器。
+func Enable(names ...string) {
+	setEnabled(names, true)
+}
+
+// Enabled reports whether the decorator name is on, the generated code calls
+// it before each call of a decorator. While no decorator is disabled, it is
+// a single atomic load.
+//
+// Enabled 返回装饰器 name 是否打开，生成的代码在调用装饰器之前检查它。没有关闭任何装饰器时只有一次原子读取。
+func Enabled(name string) bool {
+	if atomic.LoadInt32(&toggles.n) == 0 {
+		return true
+	}
+	disabled, _ := toggles.disabled.Load().(map[string]bool)
+	return !disabled[name]
+}
+
+// ListDisabled returns the names of the disabled decorators in sorted order.
+//
+// ListDisabled 返回被关闭的装饰器，按名字排序。
+func ListDisabled() []string {
+	disabled, _ := toggles.disabled.Load().(map[string]bool)
+	names := make([]string, 0, len(disabled))
+	for name := range disabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func setEnabled(names []string, on bool) {
+	if len(names) == 0 {
+		return
+	}
+	toggles.Lock()
+	defer toggles.Unlock()
+	old, _ := toggles.disabled.Load().(map[string]bool)
+	disabled := make(map[string]bool, len(old)+len(names))
+	for name := range old {
+		disabled[name] = true
+	}
+	for _, name := range names {
+		if on {
+			delete(disabled, name)
+		} else {
+			disabled[name] = true
+		}
+	}
+	toggles.disabled.Store(disabled)
+	atomic.StoreInt32(&toggles.n, int32(len(disabled)))
+}
@@ -0,0 +1,41 @@
+package decor
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDisable(t *testing.T) {
+	defer Enable(ListDisabled()...)
+	if !Enabled("logging") {
+		t.Fatal("Enabled() should be true before Disable")
+	}
+	Disable("logging", "std.Logging")
+	if Enabled("logging") || Enabled("std.Logging") || !Enabled("timing") {
+		t.Fatal("Enabled() should be false for the disabled decorators only")
+	}
+	if got := ListDisabled(); !reflect.DeepEqual(got, []string{"logging", "std.Logging"}) {
+		t.Fatal("ListDisabled() want [logging std.Logging], but get", got)
+	}
+	Enable("logging")
+	if !Enabled("logging") || Enabled("std.Logging") {
+		t.Fatal("Enable() should turn logging on again")
+	}
+
+	// run with -race: the state can be changed while the targets are called
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				Disable("timing")
+			} else {
+				Enable("timing")
+			}
+			_ = Enabled("timing")
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,36 @@
+package decor
+
+import "context"
+
+// Tracer is a minimal tracing interface used by Context.StartSpan.
+// It has no third-party dependency, adapt your tracing library to it,
+// for example an OpenTelemetry trace.Tracer can be wrapped in a few lines.
+//
+// Tracer 是 StartSpan 使用的最小追踪接口，不依赖任何第三方库。
+type Tracer interface {
+	// Start creates a span named name as a child of ctx, and returns
+	// the context carrying the span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a started span, End must be called once the work is done.
+type Span interface {
+	End()
+}
+
+// StartSpan starts a span named after TargetName with tracer, using d.Ctx as
+// the parent (context.Background() if nil), and stores the span context back
+// into d.Ctx. The returned function ends the span, defer it in the decorator:
+//
+//	func tracing(ctx *decor.Context) {
+//		_, end := ctx.StartSpan(tracer)
+//		defer end()
+//		ctx.TargetDo()
+//	}
+//
+// StartSpan 以 d.Ctx 为父级开启一个 span ，并通过 SetCtx 将新的 context 写回，返回的函数用于结束 span 。
+func (d *Context) StartSpan(tracer Tracer) (context.Context, func()) {
+	ctx, span := tracer.Start(d.context(), d.TargetName)
+	d.SetCtx(ctx)
+	return ctx, span.End
+}
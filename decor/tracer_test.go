@@ -0,0 +1,54 @@
+package decor
+
+import (
+	"context"
+	"testing"
+)
+
+type stubSpanKey struct{}
+
+type stubTracer struct {
+	events []string
+}
+
+type stubSpan struct {
+	name   string
+	tracer *stubTracer
+}
+
+func (s *stubTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s.events = append(s.events, "start "+name)
+	return context.WithValue(ctx, stubSpanKey{}, name), &stubSpan{name, s}
+}
+
+func (s *stubSpan) End() {
+	s.tracer.events = append(s.tracer.events, "end "+s.name)
+}
+
+func TestContext_StartSpan(t *testing.T) {
+	tracer := &stubTracer{}
+	ctx := &Context{TargetName: "target"}
+	ctx.Func = func() {
+		tracer.events = append(tracer.events, "target")
+		if ctx.Ctx.Value(stubSpanKey{}) != "target" {
+			t.Fatal("ctx.Ctx should carry the span, but get", ctx.Ctx)
+		}
+	}
+	func() {
+		spanCtx, end := ctx.StartSpan(tracer)
+		defer end()
+		if spanCtx != ctx.Ctx {
+			t.Fatal("StartSpan() should store the span context into ctx.Ctx")
+		}
+		ctx.TargetDo()
+	}()
+	want := []string{"start target", "target", "end target"}
+	if len(tracer.events) != len(want) {
+		t.Fatal("tracer events want", want, "but get", tracer.events)
+	}
+	for i, v := range want {
+		if tracer.events[i] != v {
+			t.Fatal("tracer events want", want, "but get", tracer.events)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package decor
+
+import "sync/atomic"
+
+// This file defines the typed contexts of the decorator.
+//
+// The TargetIn and TargetOut of Context are []any, so every argument and result of
+// the target is boxed into an interface on each call. A decorator on a hot path can
+// take a typed context instead, which holds the arguments and results in fields of
+// their own types:
+//
+//	func timing[A, R any](ctx *decor.Context1In1Out[A, R]) {
+//		start := time.Now()
+//		ctx.TargetDo()
+//		log.Println(ctx.TargetName, ctx.In0, ctx.Out0, time.Since(start))
+//	}
+//
+// ContextNInMOut is used for targets with N parameters and M results, N and M are 0 to 2.
+// The type arguments are inferred from the target, so the decorator is usually generic.
+// Targets with more parameters or results can only use decorators taking *Context,
+// and a typed decorator used on them is reported at compile time.
+// They have the same concurrency guarantees as Context: DoRef is atomic and the
+// calls of the target are serialized, the other fields are not synchronized.
+//
+// 类型化的上下文，参数和返回值保存在各自类型的字段中，不需要装箱为 any 。
+// ContextNInMOut 用于 N 个参数、M 个返回值的目标，N 和 M 为 0 到 2 ，更多参数或返回值的目标只能使用 *Context 。
+
+// Context0In0Out is the typed context of targets with 0 parameters and 0 results.
+type Context0In0Out struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, the calls from several goroutines are
+// serialized, see Context.TargetDo.
+func (d *Context0In0Out) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context0In0Out) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
+
+// Context0In1Out is the typed context of targets with 0 parameters and 1 result.
+type Context0In1Out[O0 any] struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	Out0       O0
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, see Context.TargetDo.
+func (d *Context0In1Out[O0]) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context0In1Out[O0]) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
+
+// Context0In2Out is the typed context of targets with 0 parameters and 2 results.
+type Context0In2Out[O0, O1 any] struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	Out0       O0
+	Out1       O1
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, see Context.TargetDo.
+func (d *Context0In2Out[O0, O1]) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context0In2Out[O0, O1]) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
+
+// Context1In0Out is the typed context of targets with 1 parameter and 0 results.
+type Context1In0Out[I0 any] struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	In0        I0
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, see Context.TargetDo.
+func (d *Context1In0Out[I0]) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context1In0Out[I0]) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
+
+// Context1In1Out is the typed context of targets with 1 parameter and 1 result.
+type Context1In1Out[I0, O0 any] struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	In0        I0
+	Out0       O0
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, see Context.TargetDo.
+func (d *Context1In1Out[I0, O0]) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context1In1Out[I0, O0]) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
+
+// Context1In2Out is the typed context of targets with 1 parameter and 2 results.
+type Context1In2Out[I0, O0, O1 any] struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	In0        I0
+	Out0       O0
+	Out1       O1
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, see Context.TargetDo.
+func (d *Context1In2Out[I0, O0, O1]) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context1In2Out[I0, O0, O1]) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
+
+// Context2In0Out is the typed context of targets with 2 parameters and 0 results.
+type Context2In0Out[I0, I1 any] struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	In0        I0
+	In1        I1
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, see Context.TargetDo.
+func (d *Context2In0Out[I0, I1]) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context2In0Out[I0, I1]) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
+
+// Context2In1Out is the typed context of targets with 2 parameters and 1 result.
+type Context2In1Out[I0, I1, O0 any] struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	In0        I0
+	In1        I1
+	Out0       O0
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, see Context.TargetDo.
+func (d *Context2In1Out[I0, I1, O0]) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context2In1Out[I0, I1, O0]) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
+
+// Context2In2Out is the typed context of targets with 2 parameters and 2 results.
+type Context2In2Out[I0, I1, O0, O1 any] struct {
+	calls // the first field, 64-bit aligned for sync/atomic
+
+	Kind       TKind
+	TargetName string
+	Receiver   any
+	In0        I0
+	In1        I1
+	Out0       O0
+	Out1       O1
+	Chain      *ChainState
+	Func       func()
+}
+
+// TargetDo calls the target function, see Context.TargetDo.
+func (d *Context2In2Out[I0, I1, O0, O1]) TargetDo() {
+	n := d.enter()
+	defer d.exit(n)
+	d.Func()
+}
+
+// DoRef gets the number of times TargetDo was called.
+func (d *Context2In2Out[I0, I1, O0, O1]) DoRef() int64 {
+	return atomic.LoadInt64(&d.doRef)
+}
@@ -0,0 +1,49 @@
+package decor
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestContext1In1Out_TargetDo(t *testing.T) {
+	ctx := &Context1In1Out[int, string]{In0: 2}
+	ctx.Func = func() {
+		ctx.Out0 = string(rune('a' + ctx.In0))
+	}
+	ctx.TargetDo()
+	ctx.In0 = 3
+	ctx.TargetDo()
+	if ctx.Out0 != "d" || ctx.DoRef() != 2 {
+		t.Fatalf("Context1In1Out want Out0 d and DoRef 2, got %q %d", ctx.Out0, ctx.DoRef())
+	}
+}
+
+func TestContext2In2Out_TargetDo(t *testing.T) {
+	ctx := &Context2In2Out[int, int, int, error]{In0: 7, In1: 2}
+	ctx.Func = func() {
+		ctx.Out0, ctx.Out1 = ctx.In0/ctx.In1, nil
+	}
+	ctx.TargetDo()
+	if ctx.Out0 != 3 || ctx.Out1 != nil || ctx.DoRef() != 1 {
+		t.Fatalf("Context2In2Out want Out0 3, got %d %v %d", ctx.Out0, ctx.Out1, ctx.DoRef())
+	}
+}
+
+func TestContext0In1Out_ConcurrentTargetDo(t *testing.T) {
+	ctx := &Context0In1Out[int]{}
+	ctx.Func = func() {
+		ctx.Out0++
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx.TargetDo()
+		}()
+	}
+	wg.Wait()
+	if ctx.Out0 != 20 || ctx.DoRef() != 20 {
+		t.Fatalf("Context0In1Out want Out0 20 and DoRef 20, got %d %d", ctx.Out0, ctx.DoRef())
+	}
+}
@@ -16,11 +16,23 @@ package decor
 
 func wrappedTargetCode( /* in1, in2, ... */ ) /* (out1, out2, ...) */ {
 	varDecorContext := Context{
-		Kind:       KFunc, // KFunc / KMethod
-		TargetName: "",    // wrapped function/method name
-		Receiver:   nil,   // wrapped method receiver
-		TargetIn:   []any{ /*in1, in2, ....*/ },
-		TargetOut:  []any{ /*out1, out2, ....*/ },
+		Kind:           KFunc, // KFunc / KMethod
+		TargetName:     "",    // wrapped function/method name
+		Receiver:       nil,   // wrapped method receiver
+		TargetIn:       []any{ /*in1, in2, ....*/ },
+		TargetOut:      []any{ /*out1, out2, ....*/ },
+		TargetInNames:  []string{},
+		TargetOutNames: []string{},
+		TargetInTypes:  []string{},
+		TargetOutTypes: []string{},
+		TargetPkg:      "",  // import path of the package
+		TargetFile:     "",  // original source file
+		TargetLine:     0,   // line of the declaration
+		TypeParams:     nil, // type parameters of a generic target
+		TypeArgs:       nil, // type arguments of the call
+		Ctx:            nil,
+		Chain:          nil,
+		Values:         nil,
 	}
 	varDecorContext.Func = func() {
 		/* varDecorContext.TargetOut[0], varDecorContext.TargetOut[1], ... = */ func( /* in1, in2, ... */ ) /* (out1, out2, ...) */ {
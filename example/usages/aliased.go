@@ -0,0 +1,9 @@
+package main
+
+// 这个文件中的 trace 是模块根目录的 decor.toml 中声明的别名，指向 externala.DefaultTracer.Trace 。
+// externala 包和 decor 包都没有在这个文件中导入，编译时会自动导入。
+
+//go:decor trace
+func aliasedTraced(n int) int {
+	return n + 1
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+func TestAliasedDecorator(t *testing.T) {
+	defer g.ResetTestBuffers()
+	if r := aliasedTraced(1); r != 2 {
+		t.Fatalf("aliasedTraced want 2, got %d", r)
+	}
+	if s, want := g.TestBuffers.String(), "trace: aliasedTraced\n"; s != want {
+		t.Fatalf("TestAliasedDecorator want %q, got %q", want, s)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 这个文件演示装饰程序的入口。装饰 main 函数和 init 函数会改变程序启动的方式，
+// 装饰器需要使用 allowMain 参数显式地声明，否则编译时报错：
+//
+//	//go:decor boot#{allowMain: true}
+//	func init() {}
+//
+// 适合配置加载、性能分析这类生命周期的装饰器。
+
+var bootTrace []string
+
+func boot(ctx *decor.Context) {
+	bootTrace = append(bootTrace, "boot "+ctx.TargetName)
+	ctx.TargetDo()
+	bootTrace = append(bootTrace, "booted")
+}
+
+//go:decor boot#{allowMain: true}
+func init() {
+	bootTrace = append(bootTrace, "init")
+}
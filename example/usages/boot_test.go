@@ -0,0 +1,12 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBootInit(t *testing.T) {
+	if s := strings.Join(bootTrace, ","); s != "boot init,init,booted" {
+		t.Fatalf("TestBootInit init should be decorated by boot, got %s", s)
+	}
+}
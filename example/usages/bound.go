@@ -0,0 +1,39 @@
+package main
+
+// 这个文件演示了绑定装饰器的用法：装饰器是包级变量的方法，可以使用变量中保存的状态。
+// 当前包中写作 //go:decor counter.Count ，其他包中写作 //go:decor externala.DefaultTracer.Trace 。
+// 变量的类型根据声明的类型或复合字面量得出，像 var counter = newCallCounter() 这样的声明需要显式写出类型。
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	_ "github.com/dengsgo/go-decorator/example/usages/externala"
+)
+
+type callCounter struct {
+	counts map[string]int
+}
+
+var counter = &callCounter{counts: map[string]int{}}
+
+func (c *callCounter) Count(ctx *decor.Context) {
+	c.counts[ctx.TargetName]++
+	ctx.TargetDo()
+}
+
+func (c *callCounter) CountAs(ctx *decor.Context, name string) {
+	c.counts[name]++
+	ctx.TargetDo()
+}
+
+//go:decor counter.Count
+func countedHello(s string) string {
+	return "hello " + s
+}
+
+//go:decor counter.CountAs#{name: "alias"}
+func countedAlias() {}
+
+//go:decor externala.DefaultTracer.Trace
+func tracedAdd(a, b int) int {
+	return a + b
+}
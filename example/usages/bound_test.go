@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"testing"
+)
+
+func TestBoundDecorator(t *testing.T) {
+	defer g.ResetTestBuffers()
+	counter.counts = map[string]int{}
+	if r := countedHello("bound"); r != "hello bound" {
+		t.Fatalf("countedHello want hello bound, got %s", r)
+	}
+	countedHello("bound")
+	countedAlias()
+	if counter.counts["countedHello"] != 2 || counter.counts["alias"] != 1 {
+		t.Fatalf("bound decorator counts want countedHello:2 alias:1, got %v", counter.counts)
+	}
+}
+
+func TestBoundDecoratorExternal(t *testing.T) {
+	defer g.ResetTestBuffers()
+	if r := tracedAdd(1, 2); r != 3 {
+		t.Fatalf("tracedAdd want 3, got %d", r)
+	}
+	if s := g.TestBuffers.String(); s != "trace: tracedAdd\n" {
+		t.Fatalf("externala.DefaultTracer.Trace want output %q, got %q", "trace: tracedAdd\n", s)
+	}
+}
@@ -0,0 +1,33 @@
+// 有构建约束的文件被改写后，构建约束保持原样，行号不变。
+
+//go:build !nodecorexample
+// +build !nodecorexample
+
+//go:generate echo buildtags.go
+
+package main
+
+import (
+	"runtime"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+var constrainedCalls int
+
+// 文件中第一个被装饰的函数之前的代码没有生成的代码，行号同样不能偏移
+func undecoratedLine() int {
+	_, _, line, _ := runtime.Caller(0)
+	return line
+}
+
+func constrainedDecor(ctx *decor.Context) {
+	constrainedCalls++
+	ctx.TargetDo()
+}
+
+//go:decor constrainedDecor
+func constrainedLine() int {
+	_, _, line, _ := runtime.Caller(0)
+	return line
+}
@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+func TestBuildConstraintsLine(t *testing.T) {
+	if line := undecoratedLine(); line != 20 {
+		t.Fatalf("undecoratedLine should report line 20, but got %d", line)
+	}
+	constrainedCalls = 0
+	if line := constrainedLine(); line != 31 || constrainedCalls != 1 {
+		t.Fatalf("constrainedLine should be decorated and report line 31, but got line %d after %d calls", line, constrainedCalls)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build cgo
+
+// 导入 "C" 的文件中的函数同样可以被装饰，cgo 的序言原样保留，行号仍然指向这个文件。
+
+package main
+
+/*
+static int cgoAdd(int a, int b) {
+	return a + b;
+}
+*/
+import "C"
+
+import (
+	"runtime"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+var cgoCalls int
+
+func cgoDecor(ctx *decor.Context) {
+	cgoCalls++
+	ctx.TargetDo()
+}
+
+//go:decor cgoDecor
+func cgoAdd(a, b int) (int, int) {
+	_, _, line, _ := runtime.Caller(0)
+	return int(C.cgoAdd(C.int(a), C.int(b))), line
+}
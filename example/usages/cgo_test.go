@@ -0,0 +1,12 @@
+//go:build cgo
+
+package main
+
+import "testing"
+
+func TestCgoDecorated(t *testing.T) {
+	cgoCalls = 0
+	if sum, line := cgoAdd(1, 2); sum != 3 || line != 29 || cgoCalls != 1 {
+		t.Fatalf("cgoAdd should be decorated and return 3 at line 29, but got %d at line %d after %d calls", sum, line, cgoCalls)
+	}
+}
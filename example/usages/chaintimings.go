@@ -0,0 +1,35 @@
+package main
+
+import (
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 这个文件演示链式装饰时如何获取每一层的耗时。
+// 目标函数使用多个装饰器时，所有层共享同一个 ctx.Chain ，其中记录了每一层从进入到返回的耗时。
+
+var lastChain *decor.ChainState
+
+func chainOuter(ctx *decor.Context) {
+	ctx.TargetDo()
+	// 内层此时都已经返回，最外层自己的耗时在返回后才会记录
+	lastChain = ctx.Chain
+}
+
+func chainMiddle(ctx *decor.Context) {
+	time.Sleep(time.Millisecond)
+	ctx.TargetDo()
+}
+
+func chainInner(ctx *decor.Context) {
+	time.Sleep(time.Millisecond)
+	ctx.TargetDo()
+}
+
+//go:decor chainOuter
+//go:decor chainMiddle
+//go:decor chainInner
+func chainedWork(n int) int {
+	return n * 2
+}
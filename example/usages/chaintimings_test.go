@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChainTimings(t *testing.T) {
+	if r := chainedWork(2); r != 4 {
+		t.Fatalf("TestChainTimings chainedWork want 4, got %d", r)
+	}
+	if lastChain == nil || len(lastChain.ChainTimings) != 3 {
+		t.Fatalf("TestChainTimings should record three layers, got %+v", lastChain)
+	}
+	outer, middle, inner := lastChain.ChainTimings[0], lastChain.ChainTimings[1], lastChain.ChainTimings[2]
+	if inner < time.Millisecond || middle < inner+time.Millisecond || outer < middle {
+		t.Fatalf("TestChainTimings timings not match, got %v", lastChain.ChainTimings)
+	}
+}
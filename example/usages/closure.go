@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示装饰赋值给包级变量的函数字面量（闭包），常见于 HTTP handler 的注册。
+// 用法和普通函数一样，TargetName 是变量名。
+
+//go:decor logging
+var greetHandler = func(name string) string {
+	return "hello " + name
+}
+
+var (
+	// 分组声明中，注释写在各自的变量上
+	//
+	//go:decor dumpClosureName
+	countHandler = func(n int) (int, error) {
+		return n + 1, nil
+	}
+
+	plainHandler = func() string { return "plain" }
+)
+
+func dumpClosureName(ctx *decor.Context) {
+	ctx.TargetDo()
+	decor.SetOut(ctx, 0, decor.Out[int](ctx, 0)*10)
+	g.PrintfLn("dumpClosureName: TargetName: %s", ctx.TargetName)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestGreetHandler(t *testing.T) {
+	out := `logging print target in [decor]
+logging print target out [hello decor]`
+	if r := greetHandler("decor"); r != "hello decor" {
+		t.Fatalf("TestGreetHandler fail, got %s", r)
+	}
+	if strings.TrimSpace(g.TestBuffers.String()) != out {
+		t.Fatalf("TestGreetHandler output fail, got %s", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
+
+func TestCountHandler(t *testing.T) {
+	if r, err := countHandler(1); r != 20 || err != nil {
+		t.Fatalf("TestCountHandler fail, got %d %v", r, err)
+	}
+	if strings.TrimSpace(g.TestBuffers.String()) != "dumpClosureName: TargetName: countHandler" {
+		t.Fatalf("TestCountHandler output fail, got %s", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+	if r := plainHandler(); r != "plain" || g.TestBuffers.Len() != 0 {
+		t.Fatalf("TestCountHandler plainHandler should not be decorated, got %s", r)
+	}
+}
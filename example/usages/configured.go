@@ -0,0 +1,17 @@
+package main
+
+// 这个文件中的函数没有 //go:decor 注释，它们的装饰器来自模块根目录的 decor.toml ：
+// 名称以 configured 开头的函数使用 logging（configuredSkipped 除外），configuredTraced 还使用了
+// externala.DefaultTracer.Trace 。装饰器所在的包和 decor 包都没有在这个文件中导入，编译时会自动匿名导入。
+
+func configuredHello(s string) string {
+	return "hello " + s
+}
+
+func configuredSkipped() int {
+	return 1
+}
+
+func configuredTraced(n int) int {
+	return n * 2
+}
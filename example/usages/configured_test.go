@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"testing"
+)
+
+func TestConfiguredDecorators(t *testing.T) {
+	defer g.ResetTestBuffers()
+	if r := configuredHello("config"); r != "hello config" {
+		t.Fatalf("configuredHello want hello config, got %s", r)
+	}
+	configuredSkipped()
+	if r := configuredTraced(2); r != 4 {
+		t.Fatalf("configuredTraced want 4, got %d", r)
+	}
+	want := `logging print target in [config]
+logging print target out [hello config]
+logging print target in [2]
+trace: configuredTraced
+logging print target out [4]
+`
+	if s := g.TestBuffers.String(); s != want {
+		t.Fatalf("TestConfiguredDecorators want %q, got %q", want, s)
+	}
+}
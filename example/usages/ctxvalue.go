@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 这个文件演示 ctx.Ctx 的自动填充。
+// 目标函数的第一个参数是 context.Context 时，ctx.Ctx 就是这个参数，
+// 装饰器通过 ctx.WithValue / ctx.SetCtx 修改后，目标函数收到的也是新的 context 。
+
+type requestIDKey struct{}
+
+// withRequestID sets a request id into the context of the target.
+func withRequestID(ctx *decor.Context, id string) {
+	ctx.WithValue(requestIDKey{}, id)
+	ctx.TargetDo()
+}
+
+//go:decor withRequestID#{id: "req-1"}
+func handleRequest(ctx context.Context, path string) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id + " " + path
+}
+
+// 没有 context.Context 参数时 ctx.Ctx 为 nil ，WithValue 以 context.Background() 为父级，目标函数不受影响
+//
+//go:decor withRequestID#{id: "req-2"}
+func handleNoCtx(path string) string {
+	return path
+}
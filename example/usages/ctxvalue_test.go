@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHandleRequest(t *testing.T) {
+	if r := handleRequest(context.Background(), "/a"); r != "req-1 /a" {
+		t.Fatalf("TestHandleRequest want %q, but got %q", "req-1 /a", r)
+	}
+}
+
+func TestHandleNoCtx(t *testing.T) {
+	if r := handleNoCtx("/b"); r != "/b" {
+		t.Fatalf("TestHandleNoCtx want %q, but got %q", "/b", r)
+	}
+}
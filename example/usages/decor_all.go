@@ -0,0 +1,23 @@
+package main
+
+// 这个文件演示了 //go:decor-all 指令：文件中名称匹配正则表达式的函数和方法（方法写作 Type.Method）
+// 都会使用指令中的装饰器，不需要逐个添加 //go:decor 注释。写在 doc.go 中的指令作用于整个包。
+
+//go:decor-all ^decorAll counter.Count
+//go:decor-all ^allService\. logging
+
+type allService struct {
+	name string
+}
+
+func (s *allService) Name() string {
+	return s.name
+}
+
+func decorAllHello(s string) string {
+	return "hello " + s
+}
+
+func decorAllAdd(a, b int) int {
+	return a + b
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"testing"
+)
+
+func TestDecorAll(t *testing.T) {
+	defer g.ResetTestBuffers()
+	counter.counts = map[string]int{}
+	if r := decorAllHello("all"); r != "hello all" {
+		t.Fatalf("decorAllHello want hello all, got %s", r)
+	}
+	if r := decorAllAdd(1, 2); r != 3 {
+		t.Fatalf("decorAllAdd want 3, got %d", r)
+	}
+	if counter.counts["decorAllHello"] != 1 || counter.counts["decorAllAdd"] != 1 {
+		t.Fatalf("decor-all counts want decorAllHello:1 decorAllAdd:1, got %v", counter.counts)
+	}
+	s := &allService{name: "svc"}
+	if r := s.Name(); r != "svc" {
+		t.Fatalf("allService.Name want svc, got %s", r)
+	}
+	want := "logging print target in []\nlogging print target out [svc]\n"
+	if s := g.TestBuffers.String(); s != want {
+		t.Fatalf("TestDecorAll want %q, got %q", want, s)
+	}
+}
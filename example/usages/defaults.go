@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 装饰器通过 //go:decor-default 声明参数的默认值，注解中没有传的参数使用它们，而不是类型的零值。
+// 默认值和注解中的值一样需要通过 //go:decor-lint 检查。
+
+var defaultsTrace []string
+
+//go:decor-default {prefix: "call", repeat: 1}
+//go:decor-lint required: {repeat: {gte: 1, lte: 3}}
+func traced(ctx *decor.Context, prefix string, repeat int) {
+	defaultsTrace = append(defaultsTrace, strings.Repeat(prefix+":"+ctx.TargetName+" ", repeat))
+	ctx.TargetDo()
+}
+
+//go:decor traced
+func tracedByDefault() {}
+
+//go:decor traced#{repeat: 2}
+func tracedTwice() {}
+
+//go:decor traced#{prefix: "hit"}
+func tracedHit() {}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecorDefaults(t *testing.T) {
+	defaultsTrace = nil
+	tracedByDefault()
+	tracedTwice()
+	tracedHit()
+	want := "call:tracedByDefault |call:tracedTwice call:tracedTwice |hit:tracedHit "
+	if got := strings.Join(defaultsTrace, "|"); got != want {
+		t.Fatalf("TestDecorDefaults want %q, but got %q", want, got)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 这个文件演示被装饰的目标中 defer 和 recover 的语义和装饰前相同：
+//   - 目标中的 defer 在目标返回时执行，可以修改命名返回值
+//   - 目标通过 defer func() { recover() }() 捕获自己的 panic
+//   - 目标作为延迟函数（defer target()）直接调用 recover() 时，捕获调用方的 panic 。
+//     recover() 需要在目标的第一条语句中无条件调用，否则编译失败
+
+var deferTraces []string
+
+func deferTrace(ctx *decor.Context) {
+	deferTraces = append(deferTraces, "before "+ctx.TargetName)
+	ctx.TargetDo()
+	deferTraces = append(deferTraces, "after "+ctx.TargetName)
+}
+
+//go:decor deferTrace
+func safeDivide(a, b int) (q int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("recovered: %v", r)
+		}
+	}()
+	defer func() {
+		deferTraces = append(deferTraces, "defer safeDivide")
+	}()
+	return a / b, nil
+}
+
+var recoveredValue any
+
+//go:decor deferTrace
+func recoverHandler() {
+	recoveredValue = recover()
+}
+
+func panicWithHandler() {
+	defer recoverHandler()
+	panic("boom")
+}
+
+// deferSkip 不调用目标函数
+func deferSkip(ctx *decor.Context) {
+	deferTraces = append(deferTraces, "skip "+ctx.TargetName)
+	ctx.Stop()
+}
+
+//go:decor deferSkip
+func skippedRecoverHandler() {
+	recoveredValue = recover()
+}
+
+type deferPanicError struct {
+	code int
+}
+
+func (e *deferPanicError) Error() string {
+	return fmt.Sprintf("code %d", e.code)
+}
+
+// 装饰器没有调用 skippedRecoverHandler 时，装饰后的函数以相同的值重新 panic
+func panicWithValue(v any) {
+	defer skippedRecoverHandler()
+	panic(v)
+}
+
+func panicWithIndex(s []int, i int) int {
+	defer skippedRecoverHandler()
+	return s[i]
+}
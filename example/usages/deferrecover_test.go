@@ -0,0 +1,65 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDeferInTarget(t *testing.T) {
+	deferTraces = nil
+	if q, err := safeDivide(6, 3); q != 2 || err != nil {
+		t.Fatalf("TestDeferInTarget safeDivide(6, 3) want 2 <nil>, got %v %v", q, err)
+	}
+	if q, err := safeDivide(1, 0); q != 0 || err == nil || !strings.HasPrefix(err.Error(), "recovered: ") {
+		t.Fatalf("TestDeferInTarget safeDivide(1, 0) should recover, got %v %v", q, err)
+	}
+	want := "before safeDivide|defer safeDivide|after safeDivide|before safeDivide|defer safeDivide|after safeDivide"
+	if got := strings.Join(deferTraces, "|"); got != want {
+		t.Fatalf("TestDeferInTarget want %q, but got %q", want, got)
+	}
+}
+
+func TestRecoverInDeferredTarget(t *testing.T) {
+	deferTraces, recoveredValue = nil, nil
+	panicWithHandler()
+	if recoveredValue != "boom" {
+		t.Fatalf("TestRecoverInDeferredTarget should recover boom, got %v", recoveredValue)
+	}
+	if got := strings.Join(deferTraces, "|"); got != "before recoverHandler|after recoverHandler" {
+		t.Fatalf("TestRecoverInDeferredTarget got %q", got)
+	}
+
+	// 装饰器没有调用目标时，panic 继续传播
+	deferTraces, recoveredValue = nil, nil
+	defer func() {
+		if r := recover(); r != "boom" || recoveredValue != nil || strings.Join(deferTraces, "|") != "skip skippedRecoverHandler" {
+			t.Fatalf("TestRecoverInDeferredTarget should keep panicking, got %v %v %v", r, recoveredValue, deferTraces)
+		}
+	}()
+	func() {
+		defer skippedRecoverHandler()
+		panic("boom")
+	}()
+}
+
+func TestRepanicInDeferredTarget(t *testing.T) {
+	// 重新 panic 的值和原来的相同，类型不变
+	err := &deferPanicError{code: 7}
+	func() {
+		defer func() {
+			if r, ok := recover().(*deferPanicError); !ok || r != err {
+				t.Fatalf("TestRepanicInDeferredTarget should keep the value, got %v", r)
+			}
+		}()
+		panicWithValue(err)
+	}()
+	func() {
+		defer func() {
+			if r, ok := recover().(runtime.Error); !ok || !strings.Contains(r.Error(), "index out of range") {
+				t.Fatalf("TestRepanicInDeferredTarget should keep the runtime error, got %#v", r)
+			}
+		}()
+		panicWithIndex(nil, 1)
+	}()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示使用 ctx.Elapsed 统计目标函数的耗时。elapsedTiming 调用了 ctx.Elapsed ，编译时
+// 它装饰的目标才会记录开始的时间 ctx.Start ；elapsedHelper 把 ctx 传给了 elapsedRecorded ，
+// 它可能读取开始的时间，它装饰的目标也会记录。
+
+func elapsedTiming(ctx *decor.Context) {
+	ctx.TargetDo()
+	g.PrintfLn("%s took at least 5ms: %v", ctx.TargetName, ctx.Elapsed() >= 5*time.Millisecond)
+}
+
+func elapsedHelper(ctx *decor.Context) {
+	ctx.TargetDo()
+	g.PrintfLn("%s timed: %v", ctx.TargetName, elapsedRecorded(ctx))
+}
+
+func elapsedRecorded(ctx *decor.Context) bool {
+	return !ctx.Start.IsZero()
+}
+
+//go:decor elapsedTiming
+func elapsedSlow() {
+	time.Sleep(5 * time.Millisecond)
+}
+
+//go:decor elapsedHelper
+func elapsedFast() {}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestElapsed(t *testing.T) {
+	elapsedSlow()
+	elapsedFast()
+	out := "elapsedSlow took at least 5ms: true\nelapsedFast timed: true"
+	if strings.TrimSpace(g.TestBuffers.String()) != out {
+		t.Fatalf("TestElapsed fail, got %q", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
@@ -25,3 +25,10 @@ func useExternalaDecor() {
 func plus(a, b int) int {
 	return externalb.MathIntegerPlus(a, b)
 }
+
+// 装饰器也可以是一个指向装饰器函数的包级变量
+//
+//go:decor externalb.DoubleValue
+func minus(a, b int) int {
+	return a - b
+}
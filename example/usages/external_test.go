@@ -38,3 +38,19 @@ func TestPlus(t *testing.T) {
 	}
 	g.ResetTestBuffers()
 }
+
+func TestMinus(t *testing.T) {
+	cas := []struct {
+		a, b, r int
+	}{
+		{3, 2, 2},
+		{10, 20, -20},
+	}
+	for i, v := range cas {
+		num := minus(v.a, v.b)
+		if num != v.r {
+			t.Fatalf("TestMinus fail case %+v: minus(%+v, %+v) = %+v, but got %v",
+				i, v.a, v.b, num, v.r)
+		}
+	}
+}
@@ -17,3 +17,41 @@ func OnlyPrintSelf(ctx *decor.Context) {
 func UseDeepExternalDecor() string {
 	return "UseDeepExternalDecor return string, It will be modified by the decorator"
 }
+
+// Tracer 的方法可以作为绑定装饰器使用，在其他包中写作 //go:decor externala.DefaultTracer.Trace
+type Tracer struct {
+	Prefix string
+}
+
+var DefaultTracer = &Tracer{Prefix: "trace"}
+
+func (t *Tracer) Trace(ctx *decor.Context) {
+	g.PrintfLn("%s: %s", t.Prefix, ctx.TargetName)
+	ctx.TargetDo()
+}
+
+// Level 是 LevelLogging 的日志级别，注解中以常量传入：//go:decor externala.LevelLogging#{level: externala.Warn}
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+)
+
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Warn:
+		return "warn"
+	}
+	return "unknown"
+}
+
+func LevelLogging(ctx *decor.Context, level Level) {
+	g.PrintfLn("[%s] %s", level, ctx.TargetName)
+	ctx.TargetDo()
+}
@@ -17,3 +17,6 @@ func DoubleIntegerValue(ctx *decor.Context) {
 		ctx.TargetOut[0] = firstValue * 2
 	}
 }
+
+// DoubleValue is an alias of DoubleIntegerValue, decorators can also be referenced by a function-valued variable.
+var DoubleValue = DoubleIntegerValue
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示装饰值为函数、但不是函数字面量的包级变量，例如转换成 http.HandlerFunc 的函数、
+// 或者由其他包提供的函数。变量在初始化时由 decor.Wrap 包装，TargetName 是变量名。
+
+type textHandler func(string) string
+
+//go:decor auditValue
+var upperHandler = textHandler(strings.ToUpper)
+
+var (
+	// 声明了函数类型时，值按这个类型包装
+	//
+	//go:decor auditValue
+	//go:decor suffixValue#{suffix: "!"}
+	trimHandler func(string) string = strings.TrimSpace
+
+	plainValueHandler = textHandler(strings.ToLower)
+)
+
+func auditValue(ctx *decor.Context) {
+	g.PrintfLn("auditValue: %s%v", ctx.TargetName, ctx.TargetIn)
+	ctx.TargetDo()
+}
+
+func suffixValue(ctx *decor.Context, suffix string) {
+	ctx.TargetDo()
+	decor.SetOut(ctx, 0, decor.Out[string](ctx, 0)+suffix)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+func TestUpperHandler(t *testing.T) {
+	if r := upperHandler("decor"); r != "DECOR" {
+		t.Fatalf("TestUpperHandler fail, got %s", r)
+	}
+	if strings.TrimSpace(g.TestBuffers.String()) != "auditValue: upperHandler[decor]" {
+		t.Fatalf("TestUpperHandler output fail, got %s", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
+
+func TestTrimHandler(t *testing.T) {
+	if r := trimHandler(" decor "); r != "decor!" {
+		t.Fatalf("TestTrimHandler fail, got %q", r)
+	}
+	if strings.TrimSpace(g.TestBuffers.String()) != "auditValue: trimHandler[ decor ]" {
+		t.Fatalf("TestTrimHandler output fail, got %s", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+	if r := plainValueHandler("DECOR"); r != "decor" || g.TestBuffers.Len() != 0 {
+		t.Fatalf("TestTrimHandler plainValueHandler should not be decorated, got %s", r)
+	}
+}
@@ -3,7 +3,10 @@ package main
 // 这个文件演示了泛型函数使用装饰器的用法。
 // 它和普通函数的用法没有任何区别。
 
-import _ "github.com/dengsgo/go-decorator/decor"
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
 
 //go:decor logging
 func Sum[T int8 | int16 | int | int32 | int64 | float32 | float64](a ...T) T {
@@ -13,3 +16,29 @@ func Sum[T int8 | int16 | int | int32 | int64 | float32 | float64](a ...T) T {
 	}
 	return r
 }
+
+// 泛型工厂函数返回的闭包的参数类型引用了类型参数 T ，装饰器同样适用。
+//
+//go:decor logging
+func NewCollector[T any](prefix string) func(T) []T {
+	var items []T
+	return func(v T) []T {
+		items = append(items, v)
+		return items
+	}
+}
+
+// ctx.TypeParams 为类型参数名，ctx.TypeArgs 为本次调用的类型实参，可以据此区分 Max[int] 和 Max[float64] 。
+//
+//go:decor dumpTypeArgs
+func Max[T int | float64](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func dumpTypeArgs(ctx *decor.Context) {
+	g.PrintfLn("dumpTypeArgs: %s%v = %v", ctx.TargetName, ctx.TypeParams, ctx.TypeArgs)
+	ctx.TargetDo()
+}
@@ -4,6 +4,7 @@ import (
 	_ "github.com/dengsgo/go-decorator/decor"
 	_ "github.com/dengsgo/go-decorator/example/usages/externala"
 	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
 	"testing"
 )
 
@@ -23,3 +24,34 @@ func TestSum(t *testing.T) {
 	})
 	g.ResetTestBuffers()
 }
+
+func TestNewCollector(t *testing.T) {
+	ints := NewCollector[int]("int")
+	ints(1)
+	if r := ints(2); len(r) != 2 || r[0] != 1 || r[1] != 2 {
+		t.Fatalf("TestNewCollector fail, ints got %+v", r)
+	}
+	strs := NewCollector[string]("string")
+	if r := strs("decor"); len(r) != 1 || r[0] != "decor" {
+		t.Fatalf("TestNewCollector fail, strs got %+v", r)
+	}
+	if g.TestBuffers.Len() == 0 {
+		t.Fatalf("TestNewCollector fail, decorator not called")
+	}
+	g.ResetTestBuffers()
+}
+
+func TestMax(t *testing.T) {
+	if r := Max(1, 2); r != 2 {
+		t.Fatalf("TestMax fail, got %d", r)
+	}
+	if r := Max(2.5, 1.5); r != 2.5 {
+		t.Fatalf("TestMax fail, got %f", r)
+	}
+	out := `dumpTypeArgs: Max[T] = [int]
+dumpTypeArgs: Max[T] = [float64]`
+	if strings.TrimSpace(g.TestBuffers.String()) != out {
+		t.Fatalf("TestMax output fail, got %s", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
@@ -0,0 +1,50 @@
+package main
+
+// 这个文件演示了泛型类型的方法使用装饰器的用法。
+// 接收者带有类型参数（Box[T] 、Pair[K, V]）时，用法和普通方法没有区别，
+// 参数、返回值中的类型参数在生成的代码中同样会被正确断言。
+
+import _ "github.com/dengsgo/go-decorator/decor"
+
+type genericBox[T any] struct {
+	v T
+}
+
+//go:decor logging
+func (b *genericBox[T]) Get() T {
+	return b.v
+}
+
+//go:decor logging
+func (b *genericBox[T]) Set(v T) {
+	b.v = v
+}
+
+//go:decor logging
+func (b *genericBox[T]) Merge(o *genericBox[T], fs ...func(T) T) *genericBox[T] {
+	v := o.v
+	for _, f := range fs {
+		v = f(v)
+	}
+	return &genericBox[T]{v: v}
+}
+
+type genericPair[K comparable, V any] struct {
+	m map[K]V
+}
+
+//go:decor logging
+func (p genericPair[K, V]) Lookup(k K, def V) (V, bool) {
+	v, ok := p.m[k]
+	if !ok {
+		return def, false
+	}
+	return v, true
+}
+
+// 接收者中未使用的类型参数可以写作 _
+//
+//go:decor logging
+func (p *genericPair[_, V]) Len() int {
+	return len(p.m)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"testing"
+)
+
+func TestGenericBox(t *testing.T) {
+	defer g.ResetTestBuffers()
+	b := &genericBox[int]{}
+	b.Set(3)
+	if r := b.Get(); r != 3 {
+		t.Fatalf("TestGenericBox Get want 3, got %d", r)
+	}
+	if r := b.Merge(&genericBox[int]{v: 1}, func(i int) int { return i + 1 }).Get(); r != 2 {
+		t.Fatalf("TestGenericBox Merge want 2, got %d", r)
+	}
+	// 类型参数为接口类型
+	e := &genericBox[error]{}
+	e.Set(errors.New("x"))
+	if r := e.Get(); r == nil || r.Error() != "x" {
+		t.Fatalf("TestGenericBox error want x, got %v", r)
+	}
+}
+
+func TestGenericPair(t *testing.T) {
+	defer g.ResetTestBuffers()
+	p := genericPair[string, []int]{m: map[string][]int{"a": {1}}}
+	if v, ok := p.Lookup("a", nil); !ok || v[0] != 1 {
+		t.Fatalf("TestGenericPair Lookup(a) want [1] true, got %v %v", v, ok)
+	}
+	if v, ok := p.Lookup("b", []int{2}); ok || v[0] != 2 {
+		t.Fatalf("TestGenericPair Lookup(b) want [2] false, got %v %v", v, ok)
+	}
+	if r := p.Len(); r != 1 {
+		t.Fatalf("TestGenericPair Len want 1, got %d", r)
+	}
+}
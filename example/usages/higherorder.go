@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示装饰返回通道、函数和函数映射的目标（高阶函数）。装饰器通过 ctx.TargetOut 读取、替换这些结果，
+// 例如包装返回的函数。装饰器的形参也可以是函数、通道、映射等类型，注解中不能传值，省略时为 nil 。
+
+func wrapResultFunc(ctx *decor.Context, label string, onDone func()) {
+	ctx.TargetDo()
+	if f, ok := ctx.TargetOut[0].(func(int) int); ok {
+		ctx.TargetOut[0] = func(n int) int {
+			r := f(n)
+			g.PrintfLn("%s(%d) = %d", label, n, r)
+			return r
+		}
+	}
+	if onDone != nil {
+		onDone()
+	}
+}
+
+func countResults(ctx *decor.Context) {
+	ctx.TargetDo()
+	switch v := ctx.TargetOut[0].(type) {
+	case <-chan int:
+		g.PrintfLn("%s returns a channel with %d buffered values", ctx.TargetName, len(v))
+	case map[string]func() error:
+		g.PrintfLn("%s returns %d functions", ctx.TargetName, len(v))
+	}
+}
+
+//go:decor wrapResultFunc#{label: "add2"}
+func higherAdder(n int) func(int) int {
+	return func(m int) int {
+		return n + m
+	}
+}
+
+//go:decor countResults
+func higherTicks(n int) <-chan int {
+	ch := make(chan int, n)
+	for i := 0; i < n; i++ {
+		ch <- i
+	}
+	close(ch)
+	return ch
+}
+
+//go:decor countResults
+func higherHandlers() map[string]func() error {
+	return map[string]func() error{
+		"start": func() error { return nil },
+		"stop":  func() error { return nil },
+	}
+}
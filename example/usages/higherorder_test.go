@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestHigherOrder(t *testing.T) {
+	if r := higherAdder(2)(3); r != 5 {
+		t.Fatal("higherAdder(2)(3) should be 5, but got", r)
+	}
+	sum := 0
+	for v := range higherTicks(3) {
+		sum += v
+	}
+	if sum != 3 {
+		t.Fatal("higherTicks(3) should send 0, 1, 2, but got sum", sum)
+	}
+	if len(higherHandlers()) != 2 {
+		t.Fatal("higherHandlers() should return 2 functions")
+	}
+	out := "add2(3) = 5\nhigherTicks returns a channel with 3 buffered values\nhigherHandlers returns 2 functions"
+	if strings.TrimSpace(g.TestBuffers.String()) != out {
+		t.Fatalf("TestHigherOrder fail, got %q", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
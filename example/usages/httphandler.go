@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dengsgo/go-decorator/decor"
+	dhttp "github.com/dengsgo/go-decorator/decor/http"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示 decor/http 包中装饰 HTTP 处理函数的装饰器。文件中已经导入了 net/http ，
+// 因此以 dhttp 导入 decor/http 。httpAccessLog 在最外层，在 TargetDo 之后读取 Route 注入的路由模板、
+// Recover 记录的状态码和响应的长度。
+
+func httpAccessLog(ctx *decor.Context) {
+	ctx.TargetDo()
+	g.PrintfLn("%s %s %d %d", dhttp.Request(ctx).URL.Path, dhttp.RouteOf(ctx), dhttp.StatusOf(ctx), dhttp.SizeOf(ctx))
+}
+
+//go:decor httpAccessLog
+//go:decor dhttp.Recover
+//go:decor dhttp.Route#{route: "/users/{id}"}
+func httpGetUser(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("panic") != "" {
+		panic("boom")
+	}
+	fmt.Fprintf(w, "user of %s", dhttp.RouteFrom(r))
+}
+
+type httpHealth struct{}
+
+//go:decor httpAccessLog
+//go:decor dhttp.Status
+func (httpHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	httpGetUser(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "user of /users/{id}" {
+		t.Fatal("TestHTTPHandler want 200, got", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	httpGetUser(rec, httptest.NewRequest(http.MethodGet, "/users/2?panic=1", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatal("TestHTTPHandler want 500, got", rec.Code)
+	}
+	httpHealth{}.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	out := "/users/1 /users/{id} 200 19\n/users/2 /users/{id} 500 22\n/health  204 0"
+	if strings.TrimSpace(g.TestBuffers.String()) != out {
+		t.Fatalf("TestHTTPHandler fail, got %q", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
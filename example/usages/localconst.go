@@ -0,0 +1,17 @@
+package main
+
+// 注解中的参数可以是当前包中声明的常量，decorator 在编译时解析它的值和类型，
+// 不需要在注解中重复魔法数字。生成的代码使用常量的值，目标函数中同名的参数不影响它。
+
+import _ "github.com/dengsgo/go-decorator/decor"
+
+const maxRetries = 4
+
+//go:decor retryOnPanic#{times: maxRetries}
+func flakyConst(maxRetries int) int {
+	flakyCalls++
+	if flakyCalls <= maxRetries {
+		panic("flaky fail")
+	}
+	return flakyCalls
+}
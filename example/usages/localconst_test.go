@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLocalConst(t *testing.T) {
+	flakyCalls = 0
+	if r := flakyConst(3); r != 4 {
+		t.Fatalf("TestLocalConst should succeed at the fourth call, got %d", r)
+	}
+	flakyCalls = 0
+	defer func() {
+		if r := recover(); r != "flaky fail" || flakyCalls != maxRetries {
+			t.Fatalf("TestLocalConst should give up after %d calls, got %d %v", maxRetries, flakyCalls, r)
+		}
+	}()
+	flakyConst(maxRetries)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// decor.Context 的 TargetPkg 、TargetFile 和 TargetLine 是目标声明的位置，编译时填充，
+// TargetFile 和目标中 runtime.Caller 返回的路径一致，而不是改写后的临时文件。
+
+var locationTrace string
+
+func located(ctx *decor.Context) {
+	locationTrace = fmt.Sprintf("%s %s:%d", ctx.TargetPkg, ctx.TargetFile, ctx.TargetLine)
+	ctx.TargetDo()
+}
+
+//go:decor located
+func locatedTarget() (pkg, file string, line int) {
+	pc, file, line, _ := runtime.Caller(0)
+	// 装饰后函数体在闭包中执行，函数名形如 main.locatedTarget.func1.1
+	pkg, _, _ = strings.Cut(runtime.FuncForPC(pc).Name(), ".locatedTarget")
+	return pkg, file, line
+}
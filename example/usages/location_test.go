@@ -0,0 +1,14 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTargetLocation(t *testing.T) {
+	pkg, file, line := locatedTarget()
+	// runtime.Caller 在声明的下一行
+	if want := fmt.Sprintf("%s %s:%d", pkg, file, line-1); locationTrace != want {
+		t.Fatalf("TestTargetLocation want %q, but got %q", want, locationTrace)
+	}
+}
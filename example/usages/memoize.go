@@ -0,0 +1,18 @@
+package main
+
+import (
+	_ "github.com/dengsgo/go-decorator/decor"
+	_ "github.com/dengsgo/go-decorator/decor/cache"
+)
+
+// 这个文件演示 decor/cache 的 Memoize ：参数相同的调用直接返回缓存的结果，不再调用目标函数。
+// Memoize 标记了 //go:decor-lint comparable: true ，目标函数有指针、切片等参数时编译失败，
+// 这类目标函数可以使用 cache.MemoizeBy 和 cache.RegisterKey 注册的键函数。
+
+var memoizeCalls int
+
+//go:decor cache.Memoize#{ttlMs: 60000, maxEntries: 100}
+func memoizeSquare(n int) int {
+	memoizeCalls++
+	return n * n
+}
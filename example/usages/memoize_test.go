@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+func TestMemoizeSquare(t *testing.T) {
+	memoizeCalls = 0
+	for _, n := range []int{3, 3, 4, 3} {
+		if got := memoizeSquare(n); got != n*n {
+			t.Fatalf("TestMemoizeSquare want %d, got %d", n*n, got)
+		}
+	}
+	if memoizeCalls != 2 {
+		t.Fatal("TestMemoizeSquare should call the target once for each parameter, got", memoizeCalls)
+	}
+}
@@ -31,7 +31,7 @@ func (m *methodTestStruct) todo() {}
 func validCtxReceiver(ctx *decor.Context) {
 	if ctx.Kind == decor.KMethod &&
 		ctx.TargetName == "todo" && func() bool {
-		_, ok := ctx.Receiver.(*methodTestStruct)
+		_, ok := decor.ReceiverAs[*methodTestStruct](ctx)
 		return ok
 	}() {
 		g.Printf("validCtxReceiver OK")
@@ -39,3 +39,25 @@ func validCtxReceiver(ctx *decor.Context) {
 		g.Printf("validCtxReceiver FAIL")
 	}
 }
+
+// 同一个装饰器既可以用在函数上，也可以用在方法上。
+// 方法的接收者即使未命名，装饰器也能通过 ctx.Receiver 拿到它。
+
+type methodTestUnnamedReceiver struct {
+	name string
+}
+
+//go:decor dumpReceiverName
+func (methodTestUnnamedReceiver) hello() {}
+
+//go:decor dumpReceiverName
+func receiverTestFunc() {}
+
+func dumpReceiverName(ctx *decor.Context) {
+	ctx.TargetDo()
+	if r, ok := decor.ReceiverAs[methodTestUnnamedReceiver](ctx); ok && ctx.Kind == decor.KMethod {
+		g.Printf("%s receiver: %s\n", ctx.TargetName, r.name)
+		return
+	}
+	g.Printf("%s receiver: %+v\n", ctx.TargetName, ctx.Receiver)
+}
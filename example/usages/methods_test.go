@@ -42,3 +42,13 @@ func TestMethodTestStruct_todo(t *testing.T) {
 	}
 	g.ResetTestBuffers()
 }
+
+func TestMethodTestUnnamedReceiver_hello(t *testing.T) {
+	receiverTestFunc()
+	methodTestUnnamedReceiver{name: "unnamed"}.hello()
+	s := "receiverTestFunc receiver: <nil>\nhello receiver: unnamed\n"
+	if s != g.TestBuffers.String() {
+		t.Fatalf("TestMethodTestUnnamedReceiver_hello fail, got %s", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
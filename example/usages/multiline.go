@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+
+	_ "github.com/dengsgo/go-decorator/decor"
+)
+
+// 参数较多时，注解的参数可以写在后续的 //go:decor-args 注释中，它们依次以空格连接到前面的注解上。
+
+var (
+	multilineCalls int
+	multilineLine  int
+)
+
+//go:decor retrying#{
+//go:decor-args     times: 4,
+//go:decor-args     codes: {500, 503},
+//go:decor-args }
+func unavailableMultiline() (int, error) {
+	multilineCalls++
+	_, _, multilineLine, _ = runtime.Caller(0)
+	if multilineCalls < 3 {
+		return 500, errors.New("internal error")
+	}
+	return 0, nil
+}
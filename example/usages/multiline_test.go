@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestDecorArgsMultiline(t *testing.T) {
+	multilineCalls = 0
+	if code, err := unavailableMultiline(); code != 0 || err != nil || multilineCalls != 3 {
+		t.Fatalf("unavailableMultiline should be retried until it succeeds, but got %d %v after %d calls", code, err, multilineCalls)
+	}
+	// 续行注释被合并后，函数体中的行号不变
+	if multilineLine != 23 {
+		t.Fatalf("runtime.Caller in unavailableMultiline should report line 23, but got %d", multilineLine)
+	}
+}
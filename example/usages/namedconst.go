@@ -0,0 +1,15 @@
+package main
+
+// 装饰器的参数可以是具名类型，如 externala.LevelLogging 的 level externala.Level ，
+// 注解中以限定标识符传入该类型的常量，没有传值时为零值 externala.Debug 。
+
+import (
+	_ "github.com/dengsgo/go-decorator/decor"
+	_ "github.com/dengsgo/go-decorator/example/usages/externala"
+)
+
+//go:decor externala.LevelLogging#{level: externala.Warn}
+func namedConstWarn() {}
+
+//go:decor externala.LevelLogging
+func namedConstDefault() {}
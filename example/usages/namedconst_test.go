@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestNamedConst(t *testing.T) {
+	namedConstWarn()
+	namedConstDefault()
+	out := "[warn] namedConstWarn\n[debug] namedConstDefault"
+	if strings.TrimSpace(g.TestBuffers.String()) != out {
+		t.Fatalf("TestNamedConst fail, got %q", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
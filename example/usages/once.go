@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示目标函数上的 once lint 。
+// 非幂等的目标函数标记 `//go:decor-lint once: true` 后，如果装饰器调用 TargetDo 超过一次，
+// 第二次调用会在再次执行目标函数之前 panic ，避免有缺陷的重试装饰器重复执行它。
+
+// retryTwice is a buggy retry decorator, it always calls TargetDo twice.
+// It doesn't touch any package-level state or do I/O, so it can be marked pure.
+//
+//go:decor-pure
+func retryTwice(ctx *decor.Context) {
+	ctx.TargetDo()
+	ctx.TargetDo()
+}
+
+var paidTimes int
+
+//go:decor retryTwice
+//go:decor-lint once: true
+func payOnce(amount int) int {
+	paidTimes++
+	g.PrintfLn("payOnce: amount: %d, times: %d", amount, paidTimes)
+	return paidTimes
+}
+
+//go:decor retryTwice
+func payRetry(amount int) int {
+	paidTimes++
+	return paidTimes
+}
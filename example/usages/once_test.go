@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestPayOnce(t *testing.T) {
+	paidTimes = 0
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("TestPayOnce should panic but not")
+		}
+		msg, _ := r.(string)
+		if !strings.Contains(msg, "payOnce") || !strings.Contains(msg, "more than once") {
+			t.Fatalf("TestPayOnce panic message not clear: %v", r)
+		}
+		if paidTimes != 1 {
+			t.Fatalf("TestPayOnce should panic before paying again, but paid %d times", paidTimes)
+		}
+		g.ResetTestBuffers()
+	}()
+	_ = payOnce(10)
+}
+
+func TestPayRetry(t *testing.T) {
+	paidTimes = 0
+	if r := payRetry(10); r != 2 {
+		t.Fatalf("TestPayRetry should run twice without once lint, got %d", r)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 装饰器的最后一个参数为 opts ...decor.Option 时，注解中没有对应形参的参数按 key 的字典序作为选项传入，
+// 装饰器通过 decor.OptionAs 读取需要的选项。
+
+var optionsTrace []string
+
+func retrying(ctx *decor.Context, times int, opts ...decor.Option) {
+	codes, _ := decor.OptionAs[[]int](opts, "codes")
+	for _, o := range opts {
+		optionsTrace = append(optionsTrace, o.Key)
+	}
+	for i := 0; i < times; i++ {
+		ctx.TargetDo()
+		if code := decor.Out[int](ctx, 0); code == 0 || !containsInt(codes, code) {
+			return
+		}
+	}
+}
+
+func containsInt(list []int, v int) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+var optionsCalls int
+
+//go:decor retrying#{times: 3, codes: {503}, backoff: "exp"}
+func unavailableTwice() (int, error) {
+	optionsCalls++
+	if optionsCalls < 3 {
+		return 503, errors.New("unavailable")
+	}
+	return 0, nil
+}
+
+//go:decor retrying#{times: 3}
+func unavailableOnce() (int, error) {
+	optionsCalls++
+	return 503, errors.New("unavailable")
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecorOptions(t *testing.T) {
+	optionsTrace, optionsCalls = nil, 0
+	if code, err := unavailableTwice(); code != 0 || err != nil || optionsCalls != 3 {
+		t.Fatalf("unavailableTwice should be retried until it succeeds, but got %d %v after %d calls", code, err, optionsCalls)
+	}
+	optionsCalls = 0
+	if code, _ := unavailableOnce(); code != 503 || optionsCalls != 1 {
+		t.Fatalf("unavailableOnce should not be retried without codes, but got %d after %d calls", code, optionsCalls)
+	}
+	if got := strings.Join(optionsTrace, ","); got != "backoff,codes" {
+		t.Fatalf("options should be passed sorted by key, but got %q", got)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 这个文件演示如何使用 priority 参数控制多个装饰器的包装顺序。
+// priority 默认为 0 ，值越大越靠外层（越先执行）；相同时保持注释的顺序，上面的在外层。
+
+var priorityTrace []string
+
+func priorityRecover(ctx *decor.Context) {
+	priorityTrace = append(priorityTrace, "recover")
+	ctx.TargetDo()
+}
+
+func priorityAuth(ctx *decor.Context) {
+	priorityTrace = append(priorityTrace, "auth")
+	ctx.TargetDo()
+}
+
+func priorityLogging(ctx *decor.Context, msg string) {
+	priorityTrace = append(priorityTrace, "logging: "+msg)
+	ctx.TargetDo()
+}
+
+// 执行顺序为 recover, auth, logging
+//
+//go:decor priorityLogging#{msg: "handle", priority: -1}
+//go:decor priorityAuth
+//go:decor priorityRecover#{priority: 10}
+func priorityHandle(n int) int {
+	priorityTrace = append(priorityTrace, "handle")
+	return n
+}
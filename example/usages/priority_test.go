@@ -0,0 +1,16 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPriorityHandle(t *testing.T) {
+	priorityTrace = nil
+	if r := priorityHandle(1); r != 1 {
+		t.Fatalf("TestPriorityHandle want 1, got %d", r)
+	}
+	if s := strings.Join(priorityTrace, ","); s != "recover,auth,logging: handle,handle" {
+		t.Fatalf("TestPriorityHandle order not match, got %s", s)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/decor/decortest"
+)
+
+// 测试文件中的装饰器和被装饰的函数和普通文件一样会被改写，decortest.Record 记录被装饰的函数在测试中的调用。
+
+func testDouble(ctx *decor.Context) {
+	ctx.TargetDo()
+	ctx.TargetOut[0] = ctx.TargetOut[0].(int) * 2
+}
+
+//go:decor decortest.Record
+//go:decor testDouble
+func recordedAdd(a, b int) int {
+	return a + b
+}
+
+func TestRecordInTestFile(t *testing.T) {
+	rec := decortest.Start(t)
+	if recordedAdd(1, 2) != 6 || recordedAdd(3, 4) != 14 {
+		t.Fatal("TestRecordInTestFile results not match")
+	}
+	calls := rec.CallsOf("recordedAdd")
+	if len(calls) != 2 || calls[1].In[0] != 3 || calls[1].In[1] != 4 || calls[1].Out[0] != 14 {
+		t.Fatalf("TestRecordInTestFile calls not match, got %+v", calls)
+	}
+}
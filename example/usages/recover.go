@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 这个文件演示使用 ctx.TargetDoSafe 编写重试装饰器。
+// TargetDoSafe 捕获目标函数的 panic ，返回捕获的值并保存在 ctx.Panic 中，装饰器不再需要自己编写 recover 代码。
+
+// retryOnPanic calls the target up to times, until it doesn't panic.
+// If all attempts panic, it panics again with the last value.
+func retryOnPanic(ctx *decor.Context, times int) {
+	for i := 0; i < times; i++ {
+		if ctx.TargetDoSafe() == nil {
+			return
+		}
+	}
+	panic(ctx.Panic)
+}
+
+var flakyCalls int
+
+//go:decor retryOnPanic#{times: 3}
+func flaky(failures int) int {
+	flakyCalls++
+	if flakyCalls <= failures {
+		panic("flaky fail")
+	}
+	return flakyCalls
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFlaky(t *testing.T) {
+	flakyCalls = 0
+	if r := flaky(2); r != 3 {
+		t.Fatalf("TestFlaky should succeed at the third call, got %d", r)
+	}
+}
+
+func TestFlakyGiveUp(t *testing.T) {
+	flakyCalls = 0
+	defer func() {
+		if r := recover(); r != "flaky fail" {
+			t.Fatalf("TestFlakyGiveUp should panic with flaky fail, got %v", r)
+		}
+		if flakyCalls != 3 {
+			t.Fatalf("TestFlakyGiveUp should call 3 times, got %d", flakyCalls)
+		}
+	}()
+	flaky(5)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+func TestListDecorated(t *testing.T) {
+	want := map[string]string{
+		"datetime":            "logging",
+		"chainedWork":         "chainOuter,chainMiddle,chainInner",
+		"priorityHandle":      "priorityRecover,priorityAuth,priorityLogging",
+		"(*structType).Name":  "dumpTargetType",
+		"trimHandler":         "auditValue,suffixValue",
+		"promotedService.Get": "dumpDecorTextMore",
+	}
+	got := map[string]string{}
+	for _, d := range decor.ListDecorated() {
+		got[d.Name] = strings.Join(d.Decorators, ",")
+	}
+	for name, decorators := range want {
+		if got[name] != decorators {
+			t.Fatalf("TestListDecorated %s want %q, got %q", name, decorators, got[name])
+		}
+	}
+	if _, ok := got["plainHandler"]; ok {
+		t.Fatal("TestListDecorated plainHandler is not decorated")
+	}
+}
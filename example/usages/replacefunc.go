@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示使用 ctx.ReplaceFunc 替换目标函数。replaceFault 在打开故障注入时把目标函数替换为
+// 返回错误的桩函数，之后的 TargetDo 调用桩函数，DoRef 照常计数。
+
+var replaceFaultOn bool
+
+func replaceFault(ctx *decor.Context) {
+	if replaceFaultOn {
+		ctx.ReplaceFunc(func() {
+			ctx.SetLastError(errors.New("injected fault"))
+		})
+	}
+	ctx.TargetDo()
+	g.PrintfLn("%s(%v) = %v, %v, DoRef: %d", ctx.TargetName, ctx.TargetIn[0], ctx.TargetOut[0], ctx.TargetOut[1], ctx.DoRef())
+}
+
+//go:decor replaceFault
+func replaceFetch(id int) (string, error) {
+	return "item" + string(rune('0'+id)), nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestReplaceFunc(t *testing.T) {
+	if v, err := replaceFetch(1); v != "item1" || err != nil {
+		t.Fatal("TestReplaceFunc want item1, got", v, err)
+	}
+	replaceFaultOn = true
+	defer func() { replaceFaultOn = false }()
+	if v, err := replaceFetch(2); v != "" || err == nil || err.Error() != "injected fault" {
+		t.Fatal("TestReplaceFunc want the injected fault, got", v, err)
+	}
+	out := "replaceFetch(1) = item1, <nil>, DoRef: 1\nreplaceFetch(2) = , injected fault, DoRef: 1"
+	if strings.TrimSpace(g.TestBuffers.String()) != out {
+		t.Fatalf("TestReplaceFunc fail, got %q", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
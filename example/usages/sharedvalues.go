@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示链式装饰的各层通过 ctx.Values （Store/Load）共享数据。
+// 外层的 traceSpan 在调用 TargetDo 之前保存 span ，内层的 spanLogging 读取它，
+// 内层写入的值外层在 TargetDo 返回后读取。
+
+// traceSpan stores a span id for the inner layers.
+func traceSpan(ctx *decor.Context) {
+	ctx.Store("span", "span-"+ctx.TargetName)
+	ctx.TargetDo()
+	status, _ := decor.LoadT[string](ctx, "status")
+	g.PrintfLn("traceSpan %s status %s", ctx.TargetName, status)
+}
+
+// spanLogging reads the span stored by an outer layer.
+func spanLogging(ctx *decor.Context) {
+	span, _ := decor.LoadT[string](ctx, "span")
+	g.PrintfLn("spanLogging %s in %v", span, ctx.TargetIn)
+	ctx.TargetDo()
+	ctx.Store("status", "done")
+}
+
+//go:decor traceSpan
+//go:decor spanLogging
+func sharedValues(name string) string {
+	return "hello " + name
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"testing"
+)
+
+func TestSharedValues(t *testing.T) {
+	defer g.ResetTestBuffers()
+	if r := sharedValues("decor"); r != "hello decor" {
+		t.Fatalf("sharedValues want hello decor, got %s", r)
+	}
+	want := "spanLogging span-sharedValues in [decor]\ntraceSpan sharedValues status done\n"
+	if s := g.TestBuffers.String(); s != want {
+		t.Fatalf("TestSharedValues want %q, got %q", want, s)
+	}
+}
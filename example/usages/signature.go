@@ -0,0 +1,29 @@
+package main
+
+import (
+	stdctx "context"
+	"errors"
+
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 这个文件演示 //go:decor-lint signature ：errorCounted 只能装饰第一个参数为 context.Context 、
+// 返回 error 的函数，其他签名的目标函数编译失败。目标文件以别名导入 context 包不影响检查。
+
+var countedErrors int
+
+//go:decor-lint signature: {in: ["context.Context", "..."], out: ["error"]}
+func errorCounted(ctx *decor.Context) {
+	ctx.TargetDo()
+	if err, _ := ctx.TargetOut[0].(error); err != nil {
+		countedErrors++
+	}
+}
+
+//go:decor errorCounted
+func findUser(ctx stdctx.Context, id int) error {
+	if id <= 0 {
+		return errors.New("invalid id")
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestErrorCounted(t *testing.T) {
+	countedErrors = 0
+	for _, id := range []int{1, 0, 2, -1} {
+		_ = findUser(context.Background(), id)
+	}
+	if countedErrors != 2 {
+		t.Fatal("TestErrorCounted want 2 errors, got", countedErrors)
+	}
+}
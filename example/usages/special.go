@@ -1,7 +1,9 @@
 package main
 
 import (
-	_ "github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
 	"time"
 )
 
@@ -18,3 +20,66 @@ func nilInAndOut(a any, b *time.Time, s string) (bool, error) {
 func ellipsisIn(i int, s ...string) []int {
 	return []int{2024, 1, 1}
 }
+
+// 参数和返回值是匿名结构体（包括带有 tag 的字段）时，装饰器同样能够正确的进行类型转换。
+//
+//go:decor logging
+func anonymousStructIn(cfg struct{ A int }, tagged struct {
+	Name string `json:"name"`
+	Age  int    `json:"age,omitempty"`
+}) struct {
+	A    int
+	Name string `json:"name"`
+} {
+	return struct {
+		A    int
+		Name string `json:"name"`
+	}{cfg.A + tagged.Age, tagged.Name}
+}
+
+type intList []int
+
+// 装饰器向 TargetOut 中写入了可以赋值给返回值类型、但类型不完全相同的值（intList 之于 []int）。
+// 默认的类型断言会失败并返回零值，标记 assignable 后生成的代码通过 decor.Assign 读取，调用方能拿到这个值。
+//
+//go:decor storeIntList
+//go:decor-lint assignable: true
+func assignableOut() []int {
+	return nil
+}
+
+//go:decor storeIntList
+func strictOut() []int {
+	return nil
+}
+
+func storeIntList(ctx *decor.Context) {
+	ctx.TargetDo()
+	ctx.TargetOut[0] = intList{1, 2, 3}
+}
+
+// 参数和返回值是内联的接口、函数类型、有方向的通道、普通切片（非可变参数），
+// 或者参数未命名时，装饰器同样能够正确的进行类型转换。
+//
+//go:decor printTypes
+func inlineTypesIn(s interface{ String() string }, check func(int) error, in <-chan int, out chan<- int, list []int) (interface{ Len() int }, func() int) {
+	v := <-in
+	out <- v + len(list)
+	if err := check(v); err != nil {
+		return nil, nil
+	}
+	b := &strings.Builder{}
+	b.WriteString(s.String())
+	return b, func() int { return v }
+}
+
+//go:decor printTypes
+func unnamedIn(int, func(int) error) bool {
+	return true
+}
+
+func printTypes(ctx *decor.Context) {
+	g.PrintfLn("printTypes in %q", ctx.TargetInTypes)
+	ctx.TargetDo()
+	g.PrintfLn("printTypes out %q", ctx.TargetOutTypes)
+}
@@ -27,3 +27,49 @@ logging print target out [[2024 1 1]]`
 	}
 	g.ResetTestBuffers()
 }
+
+func TestAnonymousStructIn(t *testing.T) {
+	out := `logging print target in [{A:1} {Name:decor Age:2}]
+logging print target out [{A:3 Name:decor}]`
+	r := anonymousStructIn(struct{ A int }{1}, struct {
+		Name string `json:"name"`
+		Age  int    `json:"age,omitempty"`
+	}{"decor", 2})
+	if r.A != 3 || r.Name != "decor" {
+		t.Fatalf("TestAnonymousStructIn fail, got %+v", r)
+	}
+	if strings.TrimSpace(g.TestBuffers.String()) != strings.TrimSpace(out) {
+		t.Fatalf("TestAnonymousStructIn fail, out %s", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
+
+func TestAssignableOut(t *testing.T) {
+	if r := assignableOut(); len(r) != 3 || r[2] != 3 {
+		t.Fatalf("TestAssignableOut fail, got %v", r)
+	}
+	if r := strictOut(); r != nil {
+		t.Fatalf("TestAssignableOut strictOut should be zero value, got %v", r)
+	}
+}
+
+func TestInlineTypesIn(t *testing.T) {
+	defer g.ResetTestBuffers()
+	in, out := make(chan int, 1), make(chan int, 1)
+	in <- 5
+	r, f := inlineTypesIn(&strings.Builder{}, func(int) error { return nil }, in, out, []int{1, 2})
+	if r == nil || f == nil || f() != 5 || <-out != 7 {
+		t.Fatalf("TestInlineTypesIn fail, got %v", r)
+	}
+	if !unnamedIn(1, nil) {
+		t.Fatal("TestInlineTypesIn unnamedIn want true")
+	}
+	want := `printTypes in ["interface{ String() string }" "func(int) error" "<-chan int" "chan<- int" "[]int"]
+printTypes out ["interface{ Len() int }" "func() int"]
+printTypes in ["int" "func(int) error"]
+printTypes out ["bool"]
+`
+	if s := g.TestBuffers.String(); s != want {
+		t.Fatalf("TestInlineTypesIn want %s, got %s", want, s)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+
+	_ "github.com/dengsgo/go-decorator/decor"
+	_ "github.com/dengsgo/go-decorator/decor/std"
+)
+
+// 这个文件演示 decor/std 包中的标准装饰器：重试、超时、熔断、限流和包装错误。
+// 它们都通过注解参数配置，参数在编译时由 //go:decor-lint 检查，例如 std.Retry 的 attempts 必须在 [1, 100] 之间。
+
+var errStdUnavailable = errors.New("unavailable")
+
+var stdFlakyCalls int
+
+// 前两次调用失败，第三次成功
+//
+//go:decor std.Retry#{attempts: 3, backoffMs: 1, multiplier: 1.5, jitter: true}
+func stdFlaky() (string, error) {
+	stdFlakyCalls++
+	if stdFlakyCalls < 3 {
+		return "", errStdUnavailable
+	}
+	return "ok", nil
+}
+
+//go:decor std.CircuitBreaker#{name: "example.std", failures: 2, openMs: 60000}
+func stdBroken() error {
+	return errStdUnavailable
+}
+
+//go:decor std.RateLimit#{rate: 1, burst: 2}
+//go:decor std.Timeout#{ms: 1000}
+func stdLimited() error {
+	return nil
+}
+
+//go:decor std.WrapError#{args: ["id"]}
+func stdLoad(id int, force bool) error {
+	return errStdUnavailable
+}
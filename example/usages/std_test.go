@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/decor/std"
+)
+
+func TestStdRetry(t *testing.T) {
+	stdFlakyCalls = 0
+	if s, err := stdFlaky(); s != "ok" || err != nil || stdFlakyCalls != 3 {
+		t.Fatal("TestStdRetry should succeed on the third attempt, got", s, err, stdFlakyCalls)
+	}
+}
+
+func TestStdCircuitBreaker(t *testing.T) {
+	for _, want := range []error{errStdUnavailable, errStdUnavailable, std.ErrCircuitOpen} {
+		if err := stdBroken(); err != want {
+			t.Fatalf("TestStdCircuitBreaker want %v, got %v", want, err)
+		}
+	}
+}
+
+func TestStdRateLimit(t *testing.T) {
+	for _, want := range []error{nil, nil, std.ErrRateLimited} {
+		if err := stdLimited(); err != want {
+			t.Fatalf("TestStdRateLimit want %v, got %v", want, err)
+		}
+	}
+}
+
+func TestStdWrapError(t *testing.T) {
+	err := stdLoad(7, true)
+	if err == nil || err.Error() != "stdLoad(id=7): unavailable" || !errors.Is(err, errStdUnavailable) {
+		t.Fatal("TestStdWrapError should wrap the error, got", err)
+	}
+}
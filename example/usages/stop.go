@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示使用 ctx.Stop 短路装饰链。内层的 stopCache 命中缓存时填充返回值并调用 Stop ，
+// 目标函数不再执行；外层的 stopAudit 通过 ctx.Stopped 得知目标函数没有被调用。
+
+var stopCached = map[int]int{2: 200}
+
+func stopAudit(ctx *decor.Context) {
+	ctx.TargetDo()
+	g.PrintfLn("%s(%v) = %v, stopped: %v", ctx.TargetName, ctx.TargetIn[0], ctx.TargetOut[0], ctx.Stopped())
+}
+
+func stopCache(ctx *decor.Context) {
+	if v, ok := stopCached[decor.In[int](ctx, 0)]; ok {
+		decor.SetOut(ctx, 0, v)
+		ctx.Stop()
+		return
+	}
+	ctx.TargetDo()
+}
+
+//go:decor stopAudit
+//go:decor stopCache
+func stopSquare(n int) int {
+	return n * n
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestStop(t *testing.T) {
+	if stopSquare(3) != 9 || stopSquare(2) != 200 {
+		t.Fatal("TestStop results not match")
+	}
+	out := "stopSquare(3) = 9, stopped: false\nstopSquare(2) = 200, stopped: true"
+	if strings.TrimSpace(g.TestBuffers.String()) != out {
+		t.Fatalf("TestStop fail, got %q", g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示 ctx.TargetInNames / ctx.TargetOutNames 的用法。
+// 参数名和 TargetIn 、TargetOut 一一对应，装饰器可以按名称输出结构化的日志，
+// 未命名的参数和返回值名称为空字符串，这里用它的序号代替。
+
+func structuredLogging(ctx *decor.Context) {
+	ctx.TargetDo()
+	in, _ := json.Marshal(namedValues(ctx.TargetInNames, ctx.TargetIn))
+	out, _ := json.Marshal(namedValues(ctx.TargetOutNames, ctx.TargetOut))
+	g.PrintfLn("%s in=%s out=%s", ctx.TargetName, in, out)
+}
+
+func namedValues(names []string, values []any) map[string]any {
+	m := make(map[string]any, len(values))
+	for i, v := range values {
+		name := names[i]
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		m[name] = v
+	}
+	return m
+}
+
+//go:decor structuredLogging
+func structuredPlus(a, b int) (sum int) {
+	return a + b
+}
+
+//go:decor structuredLogging
+func structuredJoin(sep string, _ bool, s ...string) string {
+	r := ""
+	for i, v := range s {
+		if i > 0 {
+			r += sep
+		}
+		r += v
+	}
+	return r
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"testing"
+)
+
+func TestStructuredLogging(t *testing.T) {
+	defer g.ResetTestBuffers()
+	structuredPlus(1, 2)
+	structuredJoin("-", true, "a", "b")
+	want := `structuredPlus in={"a":1,"b":2} out={"sum":3}
+structuredJoin in={"1":true,"s":["a","b"],"sep":"-"} out={"0":"a-b"}
+`
+	if s := g.TestBuffers.String(); s != want {
+		t.Fatalf("TestStructuredLogging want %q, but got %q", want, s)
+	}
+}
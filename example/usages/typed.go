@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+// 这个文件演示类型化的上下文 decor.ContextNInMOut 。
+// 装饰器的第一个参数是类型化的上下文时，目标的参数和返回值保存在 InN 、OutN 字段中，不会装箱为 any ，
+// 适合装饰调用频繁的函数。装饰器通常是泛型函数，类型参数由目标推断。
+
+// typedTrace prints the inputs and the output of targets with two parameters and one result.
+func typedTrace[A, B, R any](ctx *decor.Context2In1Out[A, B, R]) {
+	g.PrintfLn("typedTrace %s in %v %v", ctx.TargetName, ctx.In0, ctx.In1)
+	ctx.TargetDo()
+	g.PrintfLn("typedTrace %s out %v", ctx.TargetName, ctx.Out0)
+}
+
+// typedLimit limits the result of the target to max.
+func typedLimit[A any](ctx *decor.Context1In1Out[A, int], max int) {
+	ctx.TargetDo()
+	if ctx.Out0 > max {
+		ctx.Out0 = max
+	}
+}
+
+//go:decor typedTrace
+func typedAdd(a, b int) int {
+	return a + b
+}
+
+// 类型化的装饰器和 *decor.Context 装饰器可以一起使用，logging 在内层，看到的是限制之前的结果
+//
+//go:decor typedLimit#{max: 10}
+//go:decor logging
+func typedSum(nums ...int) int {
+	sum := 0
+	for _, n := range nums {
+		sum += n
+	}
+	return sum
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"testing"
+)
+
+func TestTypedContext(t *testing.T) {
+	defer g.ResetTestBuffers()
+	if r := typedAdd(1, 2); r != 3 {
+		t.Fatalf("typedAdd want 3, got %d", r)
+	}
+	want := "typedTrace typedAdd in 1 2\ntypedTrace typedAdd out 3\n"
+	if s := g.TestBuffers.String(); s != want {
+		t.Fatalf("typedAdd want %q, got %q", want, s)
+	}
+	g.ResetTestBuffers()
+	if r := typedSum(1, 2, 3); r != 6 {
+		t.Fatalf("typedSum want 6, got %d", r)
+	}
+	if r := typedSum(5, 6, 7); r != 10 {
+		t.Fatalf("typedSum want 10, got %d", r)
+	}
+	want = "logging print target in [[1 2 3]]\nlogging print target out [6]\n" +
+		"logging print target in [[5 6 7]]\nlogging print target out [18]\n"
+	if s := g.TestBuffers.String(); s != want {
+		t.Fatalf("typedSum want %q, got %q", want, s)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import _ "github.com/dengsgo/go-decorator/decor"
+
+// 类型别名上的装饰器装饰它所指的类型的方法。
+// Go 不允许在泛型的实例化类型上声明方法，aliasedIntBox = aliasedBox[int] 这样的别名上不能使用装饰器，
+// 否则会装饰 aliasedBox 所有实例的方法。装饰器写在泛型类型上，通过别名调用的方法同样被装饰。
+
+//go:decor dumpDecorTextMore#{text: "from aliasedBox"}
+type aliasedBox[T any] struct {
+	v T
+}
+
+type aliasedIntBox = aliasedBox[int]
+
+func (b *aliasedBox[T]) Get() T {
+	return b.v
+}
+
+// 以别名作为接收者声明的方法，同样会被它所指的类型上的装饰器装饰
+
+//go:decor dumpDecorTextMore#{text: "from aliasTarget"}
+type aliasTarget struct{}
+
+type aliasOfTarget = aliasTarget
+
+func (aliasOfTarget) Name() string {
+	return "aliasTarget"
+}
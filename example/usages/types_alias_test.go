@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dengsgo/go-decorator/example/usages/g"
+)
+
+func TestTypeAlias(t *testing.T) {
+	defer g.ResetTestBuffers()
+	b := &aliasedIntBox{v: 1}
+	if r := b.Get(); r != 1 {
+		t.Fatalf("TestTypeAlias Get want 1, got %d", r)
+	}
+	s := &aliasedBox[string]{v: "a"}
+	if r := s.Get(); r != "a" {
+		t.Fatalf("TestTypeAlias Get want a, got %s", r)
+	}
+	if r := (aliasTarget{}).Name(); r != "aliasTarget" {
+		t.Fatalf("TestTypeAlias Name want aliasTarget, got %s", r)
+	}
+	out := strings.TrimSpace(g.TestBuffers.String())
+	r := `dumpDecorTextMore: TargetName: Get, text: from aliasedBox
+dumpDecorTextMore: TargetName: Get, text: from aliasedBox
+dumpDecorTextMore: TargetName: Name, text: from aliasTarget`
+	if out != r {
+		t.Fatalf("TestTypeAlias fail, out : %s, \nshould : %s", out, r)
+	}
+}
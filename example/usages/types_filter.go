@@ -0,0 +1,28 @@
+package main
+
+import _ "github.com/dengsgo/go-decorator/decor"
+
+// 下面演示使用 methods/exclude 限定类型上的装饰器装饰哪些方法。
+// methods 和 exclude 都是逗号分隔的方法名，支持 * 等通配符；没有 methods 时匹配所有方法，exclude 匹配的方法总是被排除。
+// 比如下面的 filteredStructType ，dumpDecorTextMore 只装饰 GetName、SetName ，不装饰 GetSecret、String 。
+
+//go:decor dumpDecorTextMore#{text: "from filteredStructType", methods: "Get*,Set*", exclude: "GetSecret"}
+type filteredStructType struct {
+	name string
+}
+
+func (f *filteredStructType) GetName() string {
+	return f.name
+}
+
+func (f *filteredStructType) SetName(name string) {
+	f.name = name
+}
+
+func (f *filteredStructType) GetSecret() string {
+	return "secret"
+}
+
+func (f *filteredStructType) String() string {
+	return "filteredStructType(" + f.name + ")"
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestFilteredStructType(t *testing.T) {
+	f := &filteredStructType{}
+	f.SetName("a")
+	_ = f.GetName()
+	_ = f.GetSecret()
+	_ = f.String()
+	out := strings.TrimSpace(g.TestBuffers.String())
+	r := `dumpDecorTextMore: TargetName: SetName, text: from filteredStructType
+dumpDecorTextMore: TargetName: GetName, text: from filteredStructType`
+	if out != r {
+		t.Fatalf("TestFilteredStructType fail, out : %s, \nshould : %s", out, r)
+	}
+	g.ResetTestBuffers()
+}
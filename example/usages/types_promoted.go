@@ -0,0 +1,30 @@
+package main
+
+import _ "github.com/dengsgo/go-decorator/decor"
+
+// 下面演示使用 promoted: true 让类型上的装饰器同样装饰嵌入字段提升的方法。
+// promotedStore 的 Get、Put 被提升到 promotedService ，编译时会在 promotedService 上生成转发的方法 Get、Put 并装饰它们，
+// 通过 promotedService 调用时经过装饰器，直接调用 promotedStore 的方法时不经过。
+// 不使用 promoted 时，编译时会给出警告，列出没有被装饰的提升方法。
+
+type promotedStore struct {
+	data map[string]string
+}
+
+func (s *promotedStore) Get(key string) string {
+	return s.data[key]
+}
+
+func (s *promotedStore) Put(key, value string) {
+	s.data[key] = value
+}
+
+//go:decor dumpDecorTextMore#{text: "from promotedService", promoted: true}
+type promotedService struct {
+	*promotedStore
+	name string
+}
+
+func (s *promotedService) Name() string {
+	return s.name
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestPromotedStructType(t *testing.T) {
+	s := &promotedService{promotedStore: &promotedStore{data: map[string]string{}}, name: "service"}
+	s.Put("a", "1")
+	if v := s.Get("a"); v != "1" {
+		t.Fatalf("TestPromotedStructType Get want 1, got %s", v)
+	}
+	_ = s.Name()
+	_ = s.promotedStore.Get("a")
+	out := strings.TrimSpace(g.TestBuffers.String())
+	r := `dumpDecorTextMore: TargetName: Put, text: from promotedService
+dumpDecorTextMore: TargetName: Get, text: from promotedService
+dumpDecorTextMore: TargetName: Name, text: from promotedService`
+	if out != r {
+		t.Fatalf("TestPromotedStructType fail, out : %s, \nshould : %s", out, r)
+	}
+	g.ResetTestBuffers()
+}
@@ -28,3 +28,8 @@ func underscoresParamIn0Out2() (_ int, f float32) {
 func underscoresParamIn2Out0(_ int, f float32) {
 	//nothing
 }
+
+//go:decor dumpDecorContext
+func underscoresParamMixed(a, _ int, decor string) (_ int, dumpDecorContext string, err error) {
+	return a, decor, nil
+}
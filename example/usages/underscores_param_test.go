@@ -56,3 +56,16 @@ func TestUnderscoresParamIn2Out0(t *testing.T) {
 	}
 	g.ResetTestBuffers()
 }
+
+func TestUnderscoresParamMixed(t *testing.T) {
+	n, s, err := underscoresParamMixed(1, 2, "x")
+	if n != 1 || s != "x" || err != nil {
+		t.Fatalf("TestUnderscoresParamMixed fail, got %v %v %v", n, s, err)
+	}
+	out := `=> dumpDecorContext: Kind: 0, TargetName: underscoresParamMixed, Receiver: <nil>, TargetIn: [1 2 x], TargetOut: [0  <nil>], doRef: 0
+<= dumpDecorContext: Kind: 0, TargetName: underscoresParamMixed, Receiver: <nil>, TargetIn: [1 2 x], TargetOut: [1 x <nil>], doRef: 1`
+	if strings.TrimSpace(g.TestBuffers.String()) != strings.TrimSpace(out) {
+		t.Fatalf("TestUnderscoresParamMixed fail, out not match. \nshould: %+v\n, but: %+v", out, g.TestBuffers.String())
+	}
+	g.ResetTestBuffers()
+}
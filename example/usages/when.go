@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/decor"
+)
+
+// 这个文件演示使用 when 参数按标签启用装饰器。
+// when 是构建标签风格的表达式，只有满足 -d.tags 指定的标签时装饰器才会生效：
+//
+//	go build -toolexec 'decorator -d.tags prod'
+//
+// 不满足时编译期间直接忽略这个装饰器，没有任何运行时开销。示例的测试没有指定 -d.tags 。
+
+var whenTrace []string
+
+func whenMetrics(ctx *decor.Context) {
+	whenTrace = append(whenTrace, "metrics")
+	ctx.TargetDo()
+}
+
+func whenDebug(ctx *decor.Context) {
+	whenTrace = append(whenTrace, "debug")
+	ctx.TargetDo()
+}
+
+//go:decor whenMetrics#{when: "prod"}
+//go:decor whenDebug#{when: "!prod"}
+func whenHandle() {
+	whenTrace = append(whenTrace, "handle")
+}
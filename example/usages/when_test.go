@@ -0,0 +1,14 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWhenHandle(t *testing.T) {
+	whenTrace = nil
+	whenHandle()
+	if s := strings.Join(whenTrace, ","); s != "debug,handle" {
+		t.Fatalf("TestWhenHandle without prod tag should only use whenDebug, got %s", s)
+	}
+}
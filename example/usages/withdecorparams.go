@@ -89,3 +89,68 @@ func useHitBothUseLint() (s string) {
 func useHitUseMultilineLintDecor() (s string) {
 	return
 }
+
+// The trailing `map[string]string` parameter receives the keys that have no matching
+// formal parameter, so the decorator can forward them downstream. They bypass lint.
+//
+//go:decor-lint nonzero: {msg}
+func hitForward(ctx *decor.Context, msg string, rest map[string]string) {
+	ctx.TargetDo()
+	ctx.TargetOut[0] = fmt.Sprintf("hitForward received: msg=%s, rest=%v", msg, rest)
+}
+
+//go:decor hitForward#{msg: "forward", env: "prod", timeout: 10, retry: true}
+func useHitForward() (s string) {
+	return
+}
+
+// List parameters are written as `["a", "b"]` and passed to `[]string` / `[]int` parameters.
+// Lint rules apply to every element, nonzero means the list can't be empty.
+//
+//go:decor-lint required: {tags: {"api", "admin", "internal"}, ports: {gte: 1, lte: 65535}}
+//go:decor-lint nonzero: {tags}
+func hitTags(ctx *decor.Context, tags []string, ports []int) {
+	ctx.TargetDo()
+	ctx.TargetOut[0] = fmt.Sprintf("hitTags received: tags=%v, ports=%v", tags, ports)
+}
+
+//go:decor hitTags#{tags: ["api", "admin"], ports: [80, 443]}
+func useHitTags() (s string) {
+	return
+}
+
+//go:decor hitTags#{tags: ["internal"]}
+func useHitTagsWithoutPorts() (s string) {
+	return
+}
+
+// pattern, prefix and suffix constrain the format of string parameters, such as a route or a metric name.
+// Each rule passes if one of its values matches.
+//
+//go:decor-lint pattern: {route: "^/[a-z0-9/]*$"}
+//go:decor-lint prefix: {metric: {"app_", "svc_"}}
+//go:decor-lint suffix: {metric: {"_total", "_seconds"}}
+func hitRoute(ctx *decor.Context, route, metric string) {
+	ctx.TargetDo()
+	ctx.TargetOut[0] = fmt.Sprintf("hitRoute received: route=%s, metric=%s", route, metric)
+}
+
+//go:decor hitRoute#{route: "/api/v1/users", metric: "app_users_total"}
+func useHitRoute() (s string) {
+	return
+}
+
+// exclusive declares parameters that can't be passed together, requires declares parameters
+// that must be passed together with another one.
+//
+//go:decor-lint exclusive: {file, url}
+//go:decor-lint requires: {retries: {timeout}}
+func hitSource(ctx *decor.Context, file, url string, timeout, retries int) {
+	ctx.TargetDo()
+	ctx.TargetOut[0] = fmt.Sprintf("hitSource received: file=%s, url=%s, timeout=%d, retries=%d", file, url, timeout, retries)
+}
+
+//go:decor hitSource#{url: "https://example.com", timeout: 10, retries: 3}
+func useHitSource() (s string) {
+	return
+}
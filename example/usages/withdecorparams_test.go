@@ -50,3 +50,36 @@ func TestUseHitUseMultilineLintDecor(t *testing.T) {
 	}
 	g.ResetTestBuffers()
 }
+
+func TestUseHitForward(t *testing.T) {
+	s := `hitForward received: msg=forward, rest=map[env:prod retry:true timeout:10]`
+	r := useHitForward()
+	if r != s {
+		t.Fatalf("TestUseHitForward fail, got %s", r)
+	}
+	g.ResetTestBuffers()
+}
+
+func TestUseHitTags(t *testing.T) {
+	if r := useHitTags(); r != "hitTags received: tags=[api admin], ports=[80 443]" {
+		t.Fatalf("TestUseHitTags fail, got %s", r)
+	}
+	if r := useHitTagsWithoutPorts(); r != "hitTags received: tags=[internal], ports=[]" {
+		t.Fatalf("TestUseHitTags without ports fail, got %s", r)
+	}
+	g.ResetTestBuffers()
+}
+
+func TestUseHitRoute(t *testing.T) {
+	if r := useHitRoute(); r != "hitRoute received: route=/api/v1/users, metric=app_users_total" {
+		t.Fatalf("TestUseHitRoute fail, got %s", r)
+	}
+	g.ResetTestBuffers()
+}
+
+func TestUseHitSource(t *testing.T) {
+	if r := useHitSource(); r != "hitSource received: file=, url=https://example.com, timeout=10, retries=3" {
+		t.Fatalf("TestUseHitSource fail, got %s", r)
+	}
+	g.ResetTestBuffers()
+}
@@ -0,0 +1,26 @@
+package main
+
+import _ "github.com/dengsgo/go-decorator/decor"
+
+// 下面演示构造函数上的 //go:decor-wrap-return ，它装饰构造函数返回的类型的所有方法，和写在类型上的 //go:decor 相同。
+// 比如下面的 newWrapReturnService ，dumpDecorTextMore 装饰 wrapReturnService 的 Get 和 Put ，不装饰 Close 。
+// 构造函数本身不被装饰。
+
+type wrapReturnService struct {
+	data map[string]string
+}
+
+//go:decor-wrap-return dumpDecorTextMore#{text: "from newWrapReturnService", exclude: "Close"}
+func newWrapReturnService() (*wrapReturnService, error) {
+	return &wrapReturnService{data: map[string]string{}}, nil
+}
+
+func (s *wrapReturnService) Get(key string) string {
+	return s.data[key]
+}
+
+func (s *wrapReturnService) Put(key, value string) {
+	s.data[key] = value
+}
+
+func (s *wrapReturnService) Close() {}
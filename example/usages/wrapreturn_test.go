@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/dengsgo/go-decorator/example/usages/g"
+	"strings"
+	"testing"
+)
+
+func TestWrapReturn(t *testing.T) {
+	s, _ := newWrapReturnService()
+	s.Put("a", "1")
+	if s.Get("a") != "1" {
+		t.Fatal("TestWrapReturn Get want 1")
+	}
+	s.Close()
+	out := strings.TrimSpace(g.TestBuffers.String())
+	r := `dumpDecorTextMore: TargetName: Put, text: from newWrapReturnService
+dumpDecorTextMore: TargetName: Get, text: from newWrapReturnService`
+	if out != r {
+		t.Fatalf("TestWrapReturn fail, out : %s, \nshould : %s", out, r)
+	}
+	g.ResetTestBuffers()
+}